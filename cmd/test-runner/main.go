@@ -16,6 +16,9 @@ func main() {
 		fmt.Println("Examples:")
 		fmt.Println("  test-runner testdata/rdf-tests/sparql/sparql11/syntax-query/manifest.ttl")
 		fmt.Println("  test-runner testdata/rdf-tests/sparql/sparql11/syntax-query")
+		fmt.Println("  test-runner json-ld-api/tests/toRdf-manifest.jsonld")
+		fmt.Println("  test-runner json-ld-framing/tests/frame-manifest.jsonld")
+		fmt.Println("  test-runner rdf-canon/tests/manifest.ttl")
 		os.Exit(1)
 	}
 
@@ -38,14 +41,24 @@ func main() {
 	}
 
 	if info.IsDir() {
-		// Run all manifest files in directory
-		manifestPath := filepath.Join(path, "manifest.ttl")
-		if _, err := os.Stat(manifestPath); err == nil {
+		// Run the manifest in the directory: a Turtle manifest, or the JSON-LD API manifests
+		var manifests []string
+		for _, name := range []string{"manifest.ttl", "toRdf-manifest.jsonld", "fromRdf-manifest.jsonld", "compact-manifest.jsonld", "frame-manifest.jsonld"} {
+			manifestPath := filepath.Join(path, name)
+			if _, err := os.Stat(manifestPath); err == nil {
+				manifests = append(manifests, manifestPath)
+				if name == "manifest.ttl" {
+					break
+				}
+			}
+		}
+		if len(manifests) == 0 {
+			log.Fatalf("No manifest.ttl or JSON-LD API manifest found in directory: %s", path)
+		}
+		for _, manifestPath := range manifests {
 			if err := runner.RunManifest(manifestPath); err != nil {
 				log.Fatalf("Failed to run manifest: %v", err)
 			}
-		} else {
-			log.Fatalf("No manifest.ttl found in directory: %s", path)
 		}
 	} else {
 		// Run single manifest file
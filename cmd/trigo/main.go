@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/aleksaelezovic/trigo/internal/encoding"
 	"github.com/aleksaelezovic/trigo/internal/storage"
+	"github.com/aleksaelezovic/trigo/pkg/access"
+	"github.com/aleksaelezovic/trigo/pkg/auth"
 	"github.com/aleksaelezovic/trigo/pkg/rdf"
 	"github.com/aleksaelezovic/trigo/pkg/server"
 	"github.com/aleksaelezovic/trigo/pkg/sparql/executor"
@@ -20,8 +29,11 @@ func main() {
 		fmt.Println("Usage: trigo <command> [args]")
 		fmt.Println("Commands:")
 		fmt.Println("  demo         - Run a demo with sample data")
-		fmt.Println("  query <q>    - Execute a SPARQL query")
-		fmt.Println("  serve [addr] - Start HTTP SPARQL endpoint (default: localhost:8080)")
+		fmt.Println("  load [-format type] [-lenient] <file> - Stream an RDF file into the database")
+		fmt.Println("  query [flags] <q> - Execute a SPARQL query (-explain, -analyze and -json show the plan)")
+		fmt.Println("  analyze      - Collect optimizer statistics for the database and all datasets")
+		fmt.Println("  serve [flags] [addr] - Start HTTP SPARQL endpoint (default: localhost:8080)")
+		fmt.Println("               (trigo serve -h lists access control, authentication and CORS flags)")
 		os.Exit(1)
 	}
 
@@ -30,18 +42,52 @@ func main() {
 	switch command {
 	case "demo":
 		runDemo()
+	case "load":
+		flags := flag.NewFlagSet("load", flag.ExitOnError)
+		format := flags.String("format", "", "content type of the file (default: guessed from its extension)")
+		lenient := flags.Bool("lenient", false, "skip N-Triples and N-Quads lines with syntax errors")
+		_ = flags.Parse(os.Args[2:]) // #nosec G104 - ExitOnError handles parse errors
+		if flags.NArg() < 1 {
+			fmt.Println("Usage: trigo load [-format content-type] [-lenient] <file>")
+			os.Exit(1)
+		}
+		runLoad(flags.Arg(0), *format, *lenient)
 	case "query":
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: trigo query <sparql-query>")
+		flags := flag.NewFlagSet("query", flag.ExitOnError)
+		var opts queryOptions
+		flags.BoolVar(&opts.explain, "explain", false, "print the query plan with estimated cardinalities instead of results")
+		flags.BoolVar(&opts.analyze, "analyze", false, "execute the query and print the plan with per-operator rows, calls and time")
+		flags.BoolVar(&opts.json, "json", false, "print the plan as JSON")
+		flags.IntVar(&opts.parallelism, "parallelism", runtime.GOMAXPROCS(0), "goroutines the query may run on")
+		_ = flags.Parse(os.Args[2:]) // #nosec G104 - ExitOnError handles parse errors
+		if flags.NArg() < 1 {
+			fmt.Println("Usage: trigo query [-explain|-analyze] [-json] [-parallelism n] <sparql-query>")
 			os.Exit(1)
 		}
-		runQuery(os.Args[2])
+		runQuery(flags.Arg(0), opts)
+	case "analyze":
+		runAnalyze()
 	case "serve":
+		flags := flag.NewFlagSet("serve", flag.ExitOnError)
+		var opts serveOptions
+		flags.StringVar(&opts.aclPath, "acl", "", "JSON access policy mapping principals to graph permissions")
+		flags.StringVar(&opts.apiKeysPath, "api-keys", "", "JSON file of static API keys accepted in the X-API-Key header")
+		flags.StringVar(&opts.htpasswdPath, "htpasswd", "", "htpasswd-style bcrypt credential file for HTTP Basic authentication")
+		flags.StringVar(&opts.jwtKeyPath, "jwt-key", "", "PEM public key or HMAC secret file for verifying JWT bearer tokens")
+		flags.StringVar(&opts.jwtIssuer, "jwt-issuer", "", "required JWT issuer (iss)")
+		flags.StringVar(&opts.jwtAudience, "jwt-audience", "", "required JWT audience (aud)")
+		flags.BoolVar(&opts.jwtAllowNoExpiry, "jwt-allow-no-exp", false, "accept JWTs without an exp claim, which never expire")
+		flags.BoolVar(&opts.allowAnonymousRead, "allow-anonymous-read", false, "allow unauthenticated queries when authentication is enabled")
+		flags.StringVar(&opts.corsOrigins, "cors-origins", "*", "comma-separated list of allowed CORS origins")
+		flags.DurationVar(&opts.queryTimeout, "query-timeout", server.DefaultQueryTimeout, "default and maximum query execution time (0 disables)")
+		flags.Int64Var(&opts.queryMemoryMB, "query-memory", executor.DefaultMemoryBudget>>20, "MiB each sort, DISTINCT and GROUP BY may use before spilling to disk")
+		flags.IntVar(&opts.parallelism, "parallelism", runtime.GOMAXPROCS(0), "maximum goroutines a query may run on")
+		_ = flags.Parse(os.Args[2:]) // #nosec G104 - ExitOnError handles parse errors
 		addr := "localhost:8080"
-		if len(os.Args) >= 3 {
-			addr = os.Args[2]
+		if flags.NArg() >= 1 {
+			addr = flags.Arg(0)
 		}
-		runServer(addr)
+		runServer(addr, opts)
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		os.Exit(1)
@@ -156,7 +202,7 @@ func runDemo() {
 	// Optimize query
 	stats := &optimizer.Statistics{TotalTriples: count}
 	opt := optimizer.NewOptimizer(stats)
-	optimizedQuery, err := opt.Optimize(query)
+	optimizedQuery, err := opt.Optimize(context.Background(), query)
 	if err != nil {
 		log.Fatalf("Failed to optimize query: %v", err)
 	}
@@ -164,7 +210,7 @@ func runDemo() {
 
 	// Execute query
 	exec := executor.NewExecutor(tripleStore)
-	result, err := exec.Execute(optimizedQuery)
+	result, err := exec.Execute(context.Background(), optimizedQuery)
 	if err != nil {
 		log.Fatalf("Failed to execute query: %v", err)
 	}
@@ -205,7 +251,87 @@ func runDemo() {
 	fmt.Println("\n=== Demo Complete ===")
 }
 
-func runQuery(sparqlQuery string) {
+// queryOptions holds the flags of the query command
+type queryOptions struct {
+	explain     bool
+	analyze     bool
+	json        bool
+	parallelism int
+}
+
+// fileContentTypes maps RDF file extensions to the content type they are parsed as
+var fileContentTypes = map[string]string{
+	".nt":     "application/n-triples",
+	".nq":     "application/n-quads",
+	".ttl":    "text/turtle",
+	".trig":   "application/trig",
+	".rdf":    "application/rdf+xml",
+	".owl":    "application/rdf+xml",
+	".xml":    "application/rdf+xml",
+	".jsonld": "application/ld+json",
+}
+
+// runLoad streams an RDF file into the default database, skipping bad lines of N-Triples and
+// N-Quads files when lenient
+func runLoad(path, format string, lenient bool) {
+	if format == "" {
+		format = fileContentTypes[strings.ToLower(filepath.Ext(path))]
+		if format == "" {
+			log.Fatalf("Cannot tell the format of %s; use -format", path)
+		}
+	}
+	rdfParser, err := rdf.NewParser(format)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	lenientParser, canSkip := rdfParser.(rdf.LenientParser)
+	if lenient {
+		if !canSkip {
+			log.Fatalf("-lenient is only supported for N-Triples and N-Quads")
+		}
+		lenientParser.SetLenient(true)
+	}
+
+	file, err := os.Open(path) // #nosec G304 - the user names the file to load
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	dbPath := "./trigo_data"
+	badgerStorage, err := storage.NewBadgerStorage(dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer badgerStorage.Close()
+	tripleStore := store.NewTripleStore(badgerStorage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+
+	startTime := time.Now()
+	var inserted int
+	if streamParser, ok := rdfParser.(rdf.StreamParser); ok {
+		inserted, err = tripleStore.LoadQuads(file, streamParser)
+	} else {
+		var quads []*rdf.Quad
+		if quads, err = rdfParser.Parse(file); err == nil {
+			if err = tripleStore.InsertQuadsBatch(quads); err == nil {
+				inserted = len(quads)
+			}
+		}
+	}
+	if err != nil {
+		log.Fatalf("Failed to load %s after inserting %d quads: %v", path, inserted, err)
+	}
+	fmt.Printf("Loaded %d quads from %s in %v\n", inserted, path, time.Since(startTime).Round(time.Millisecond))
+	if lenient {
+		skipped := lenientParser.Errors()
+		fmt.Printf("Skipped %d statements with syntax errors\n", len(skipped))
+		for _, parseErr := range skipped[:min(len(skipped), 10)] {
+			fmt.Printf("  %v\n", parseErr)
+		}
+	}
+}
+
+func runQuery(sparqlQuery string, opts queryOptions) {
 	// Open existing database
 	dbPath := "./trigo_data"
 	badgerStorage, err := storage.NewBadgerStorage(dbPath)
@@ -224,19 +350,24 @@ func runQuery(sparqlQuery string) {
 	}
 
 	// Get statistics
-	count, _ := tripleStore.Count()
-	stats := &optimizer.Statistics{TotalTriples: count}
+	stats, _ := optimizer.LoadStatistics(tripleStore)
 
 	// Optimize query
 	opt := optimizer.NewOptimizer(stats)
-	optimizedQuery, err := opt.Optimize(query)
+	optimizedQuery, err := opt.Optimize(context.Background(), query)
 	if err != nil {
 		log.Fatalf("Failed to optimize query: %v", err)
 	}
 
-	// Execute query
 	exec := executor.NewExecutor(tripleStore)
-	result, err := exec.Execute(optimizedQuery)
+	exec.SetParallelism(opts.parallelism)
+	if opts.explain || opts.analyze {
+		printExplain(opt, exec, optimizedQuery, opts)
+		return
+	}
+
+	// Execute query
+	result, err := exec.Execute(context.Background(), optimizedQuery)
 	if err != nil {
 		log.Fatalf("Failed to execute query: %v", err)
 	}
@@ -269,7 +400,143 @@ func runQuery(sparqlQuery string) {
 	}
 }
 
-func runServer(addr string) {
+// printExplain prints the plan of a query, annotated with runtime statistics with -analyze
+func printExplain(opt *optimizer.Optimizer, exec *executor.Executor, query *optimizer.OptimizedQuery, opts queryOptions) {
+	plan := opt.Explain(query.Plan)
+
+	var elapsed time.Duration
+	var lookups int64
+	if opts.analyze {
+		_, profile, err := exec.Analyze(context.Background(), query)
+		if err != nil {
+			log.Fatalf("Failed to execute query: %v", err)
+		}
+		profile.Annotate(plan)
+		elapsed = profile.Elapsed
+		lookups = profile.TermLookups
+	}
+
+	if opts.json {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(plan); err != nil {
+			log.Fatalf("Failed to encode plan: %v", err)
+		}
+		return
+	}
+
+	fmt.Print(plan)
+	if opts.analyze {
+		fmt.Printf("Execution time: %v\n", elapsed)
+		fmt.Printf("Term lookups: %d\n", lookups)
+	}
+}
+
+func runAnalyze() {
+	// Analyze the default database
+	dbPath := "./trigo_data"
+	badgerStorage, err := storage.NewBadgerStorage(dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer badgerStorage.Close()
+
+	tripleStore := store.NewTripleStore(badgerStorage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+	analyzeStore(dbPath, tripleStore)
+
+	// Analyze every named dataset
+	datasetsPath := "./trigo_datasets"
+	provider, err := storage.NewBadgerProvider(datasetsPath)
+	if err != nil {
+		log.Fatalf("Failed to open datasets: %v", err)
+	}
+	registry, err := store.NewRegistry(provider, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+	if err != nil {
+		log.Fatalf("Failed to open datasets: %v", err)
+	}
+	defer registry.Close()
+
+	for _, name := range registry.Names() {
+		if ts, ok := registry.Get(name); ok {
+			analyzeStore("dataset "+name, ts)
+		}
+	}
+}
+
+// analyzeStore collects and persists the statistics of one store and prints a summary
+func analyzeStore(label string, ts *store.TripleStore) {
+	startTime := time.Now()
+	stats, err := ts.Analyze(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to analyze %s: %v", label, err)
+	}
+
+	fmt.Printf("Analyzed %s in %v\n", label, time.Since(startTime).Round(time.Millisecond))
+	fmt.Printf("  %d quads, %d subjects, %d objects\n", stats.Quads, stats.Subjects, stats.Objects)
+	fmt.Printf("  %d predicates, %d graphs, %d characteristic sets\n",
+		len(stats.Predicates), len(stats.Graphs), len(stats.CharacteristicSets))
+}
+
+// serveOptions holds the flags of the serve command
+type serveOptions struct {
+	aclPath            string
+	apiKeysPath        string
+	htpasswdPath       string
+	jwtKeyPath         string
+	jwtIssuer          string
+	jwtAudience        string
+	jwtAllowNoExpiry   bool
+	allowAnonymousRead bool
+	corsOrigins        string
+	queryTimeout       time.Duration
+	queryMemoryMB      int64
+	parallelism        int
+}
+
+// authenticator builds the authenticator chain from the configured credential sources, or nil if none are set
+func (opts serveOptions) authenticator() (auth.Authenticator, error) {
+	var chain auth.Chain
+
+	if opts.apiKeysPath != "" {
+		keys, err := auth.LoadAPIKeys(opts.apiKeysPath)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+	if opts.htpasswdPath != "" {
+		basic, err := auth.LoadBasicCredentials(opts.htpasswdPath)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, basic)
+	}
+	if opts.jwtKeyPath != "" {
+		key, err := auth.LoadJWTKey(opts.jwtKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		verifier, err := auth.NewJWTVerifier(key, auth.JWTOptions{
+			Issuer:   opts.jwtIssuer,
+			Audience: opts.jwtAudience,
+			Leeway:   time.Minute,
+
+			AllowMissingExpiry: opts.jwtAllowNoExpiry,
+		})
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, verifier)
+	}
+
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+func runServer(addr string, opts serveOptions) {
 	// Open existing database or create new one
 	dbPath := "./trigo_data"
 	fmt.Printf("Opening database at: %s\n", dbPath)
@@ -286,10 +553,52 @@ func runServer(addr string) {
 	count, _ := tripleStore.Count()
 	fmt.Printf("Database loaded with %d triples\n", count)
 
+	// Open named datasets, each in its own directory
+	datasetsPath := "./trigo_datasets"
+	provider, err := storage.NewBadgerProvider(datasetsPath)
+	if err != nil {
+		log.Fatalf("Failed to open datasets: %v", err)
+	}
+	registry, err := store.NewRegistry(provider, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+	if err != nil {
+		log.Fatalf("Failed to open datasets: %v", err)
+	}
+	defer registry.Close()
+	fmt.Printf("Loaded %d named datasets from %s\n", len(registry.Names()), datasetsPath)
+
 	// Create and start server
-	srv := server.NewServer(tripleStore, addr)
+	srv := server.NewServer(tripleStore, addr).WithDatasets(registry)
+	if opts.aclPath != "" {
+		policy, err := access.LoadPolicy(opts.aclPath)
+		if err != nil {
+			log.Fatalf("Failed to load access policy: %v", err)
+		}
+		srv.WithAccessPolicy(policy)
+		fmt.Printf("Access policy loaded from %s (%d principals)\n", opts.aclPath, len(policy.Principals))
+	}
+
+	authenticator, err := opts.authenticator()
+	if err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+	if authenticator != nil {
+		srv.WithAuth(authenticator, opts.allowAnonymousRead)
+		fmt.Printf("Authentication enabled (anonymous read: %t)\n", opts.allowAnonymousRead)
+	}
+
+	var origins []string
+	for _, origin := range strings.Split(opts.corsOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	srv.WithCORSOrigins(origins)
+	srv.WithQueryTimeout(opts.queryTimeout)
+	srv.WithMemoryBudget(opts.queryMemoryMB << 20)
+	srv.WithParallelism(opts.parallelism)
 	fmt.Printf("\n🚀 Trigo SPARQL endpoint starting...\n")
 	fmt.Printf("   Endpoint: http://%s/sparql\n", addr)
+	fmt.Printf("   Datasets: http://%s/datasets\n", addr)
 	fmt.Printf("   Web UI:   http://%s/\n\n", addr)
 	fmt.Printf("Press Ctrl+C to stop\n\n")
 
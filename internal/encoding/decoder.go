@@ -123,11 +123,8 @@ func (d *TermDecoder) DecodeTerm(encoded store.EncodedTerm, stringValue *string)
 		return rdf.NewDefaultGraph(), nil
 
 	case rdf.TermTypeQuotedTriple:
-		if stringValue == nil {
-			return nil, fmt.Errorf("string value required for quoted triple")
-		}
-		// Parse the serialized quoted triple string
-		return parseQuotedTripleString(*stringValue)
+		// Triple terms are stored structurally (triples table), the store decodes their components
+		return nil, fmt.Errorf("triple terms must be decoded from their components")
 
 	default:
 		return nil, fmt.Errorf("unknown term type: %d", termType)
@@ -144,12 +141,3 @@ func findDirectionSeparator(langDir string) int {
 	}
 	return -1
 }
-
-// parseQuotedTripleString parses a quoted triple from its string representation
-// Format: << subject predicate object >>
-func parseQuotedTripleString(s string) (*rdf.QuotedTriple, error) {
-	// This is a simplified parser for the stored string representation
-	// The actual parsing logic will be in the RDF parsers
-	// For now, we'll return an error indicating this needs proper parser integration
-	return nil, fmt.Errorf("quoted triple parsing from string requires integration with RDF parser: %s", s)
-}
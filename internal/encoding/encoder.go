@@ -54,8 +54,13 @@ func (e *TermEncoder) EncodeTerm(term rdf.Term) (store.EncodedTerm, *string, err
 		return e.encodeLiteral(t)
 	case *rdf.DefaultGraph:
 		return e.encodeDefaultGraph()
+	case *rdf.TripleTerm:
+		return e.encodeTripleTerm(t.Subject, t.Predicate, t.Object)
 	case *rdf.QuotedTriple:
-		return e.encodeQuotedTriple(t)
+		return e.encodeTripleTerm(t.Subject, t.Predicate, t.Object)
+	case *rdf.ReifiedTriple:
+		// A reified triple denotes its reifier; the rdf:reifies statement is stored separately
+		return e.EncodeTerm(t.Identifier)
 	default:
 		return encoded, nil, fmt.Errorf("unknown term type: %T", term)
 	}
@@ -315,20 +320,41 @@ func (e *TermEncoder) encodeDateLiteral(lit *rdf.Literal) (store.EncodedTerm, *s
 	return encoded, nil, nil
 }
 
-func (e *TermEncoder) encodeQuotedTriple(qt *rdf.QuotedTriple) (store.EncodedTerm, *string, error) {
+func (e *TermEncoder) encodeTripleTerm(subject, predicate, object rdf.Term) (store.EncodedTerm, *string, error) {
 	var encoded store.EncodedTerm
 	encoded[0] = byte(rdf.TermTypeQuotedTriple)
 
-	// Serialize the quoted triple to canonical string form for hashing
-	// Format: << subject predicate object >>
-	serialized := qt.String()
+	components, err := e.encodeTripleComponents(subject, predicate, object)
+	if err != nil {
+		return encoded, nil, err
+	}
 
-	// Hash the serialized form (128-bit xxhash3)
-	hash := e.Hash128(serialized)
-	copy(encoded[1:], hash[:])
+	// The identifier is a hash of the encoded components, so equal triple terms
+	// always get the same ID regardless of how their nested terms are spelled
+	hash := xxh3.Hash128(components)
+	binary.BigEndian.PutUint64(encoded[1:9], hash.Hi)
+	binary.BigEndian.PutUint64(encoded[9:17], hash.Lo)
+
+	// Components are kept in the triples table by the store, not in id2str
+	return encoded, nil, nil
+}
 
-	// Store serialized form in id2str table for reconstruction
-	return encoded, &serialized, nil
+// encodeTripleComponents encodes the subject, predicate and object of a triple term
+// Returns the three encoded terms concatenated (3 * EncodedTermSize bytes)
+func (e *TermEncoder) encodeTripleComponents(subject, predicate, object rdf.Term) ([]byte, error) {
+	subjEnc, _, err := e.EncodeTerm(subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode triple term subject: %w", err)
+	}
+	predEnc, _, err := e.EncodeTerm(predicate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode triple term predicate: %w", err)
+	}
+	objEnc, _, err := e.EncodeTerm(object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode triple term object: %w", err)
+	}
+	return e.EncodeQuadKey(subjEnc, predEnc, objEnc), nil
 }
 
 func (e *TermEncoder) encodeDefaultGraph() (store.EncodedTerm, *string, error) {
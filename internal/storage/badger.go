@@ -81,7 +81,7 @@ func (t *BadgerTransaction) Set(table store.Table, key, value []byte) error {
 	}
 
 	prefixedKey := store.PrefixKey(table, key)
-	return t.txn.Set(prefixedKey, value)
+	return translateError(t.txn.Set(prefixedKey, value))
 }
 
 // Delete removes a key
@@ -91,12 +91,21 @@ func (t *BadgerTransaction) Delete(table store.Table, key []byte) error {
 	}
 
 	prefixedKey := store.PrefixKey(table, key)
-	return t.txn.Delete(prefixedKey)
+	return translateError(t.txn.Delete(prefixedKey))
+}
+
+// translateError maps BadgerDB write errors to their store equivalents
+func translateError(err error) error {
+	if err == badger.ErrTxnTooBig {
+		return store.ErrTxnTooBig
+	}
+	return err
 }
 
 // Scan iterates over a key range [start, end)
 func (t *BadgerTransaction) Scan(table store.Table, start, end []byte) (store.Iterator, error) {
 	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
 
 	// Seek to start position
 	var seekKey []byte
@@ -163,7 +172,23 @@ func (i *BadgerIterator) Next() bool {
 	} else {
 		i.it.Next()
 	}
+	return i.valid()
+}
+
+// Seek moves to the first key at or after the given key (without the table prefix)
+// within the scanned range, and reports whether there is one
+func (i *BadgerIterator) Seek(key []byte) bool {
+	target := append(append([]byte{}, i.prefix...), key...)
+	if bytes.Compare(target, i.seekKey) < 0 {
+		target = i.seekKey
+	}
+	i.it.Seek(target)
+	i.started = true
+	return i.valid()
+}
 
+// valid checks the position of the underlying iterator against the end of the scan
+func (i *BadgerIterator) valid() bool {
 	// Check if iterator is still valid
 	if !i.it.Valid() {
 		i.hasValue = false
@@ -1,9 +1,15 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aleksaelezovic/trigo/internal/encoding"
+	"github.com/aleksaelezovic/trigo/pkg/access"
 	"github.com/aleksaelezovic/trigo/pkg/rdf"
 	"github.com/aleksaelezovic/trigo/pkg/store"
 )
@@ -64,7 +70,7 @@ func TestBatchInsertAndQuery(t *testing.T) {
 		Graph:     rdf.NewDefaultGraph(),
 	}
 
-	iter, err := tripleStore.Query(pattern)
+	iter, err := tripleStore.Query(context.Background(), pattern)
 	if err != nil {
 		t.Fatalf("failed to query: %v", err)
 	}
@@ -99,7 +105,7 @@ func TestBatchInsertAndQuery(t *testing.T) {
 		Graph:     rdf.NewNamedNode("http://example.org/graph1"),
 	}
 
-	iter2, err := tripleStore.Query(namedGraphPattern)
+	iter2, err := tripleStore.Query(context.Background(), namedGraphPattern)
 	if err != nil {
 		t.Fatalf("failed to query named graph: %v", err)
 	}
@@ -178,7 +184,7 @@ func TestBatchInsertAndQuerySpecificValues(t *testing.T) {
 		Graph:     rdf.NewDefaultGraph(),
 	}
 
-	iter, err := tripleStore.Query(pattern)
+	iter, err := tripleStore.Query(context.Background(), pattern)
 	if err != nil {
 		t.Fatalf("failed to query: %v", err)
 	}
@@ -274,7 +280,7 @@ func TestBatchDeleteAndQuery(t *testing.T) {
 		Graph:     rdf.NewDefaultGraph(),
 	}
 
-	iter, err := tripleStore.Query(pattern)
+	iter, err := tripleStore.Query(context.Background(), pattern)
 	if err != nil {
 		t.Fatalf("failed to query after delete: %v", err)
 	}
@@ -309,3 +315,750 @@ func TestBatchDeleteAndQuery(t *testing.T) {
 		t.Error("Alice should be deleted")
 	}
 }
+
+func TestTripleTermsStoredStructurally(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage, err := NewBadgerStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	tripleStore := store.NewTripleStore(storage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+
+	alice := rdf.NewNamedNode("http://example.org/alice")
+	bob := rdf.NewNamedNode("http://example.org/bob")
+	knows := rdf.NewNamedNode("http://xmlns.com/foaf/0.1/knows")
+	source := rdf.NewNamedNode("http://example.org/source")
+	inner := &rdf.TripleTerm{Subject: alice, Predicate: knows, Object: bob}
+	nested := &rdf.TripleTerm{Subject: alice, Predicate: source, Object: inner}
+
+	quads := []*rdf.Quad{
+		rdf.NewQuad(rdf.NewNamedNode("http://example.org/r1"), rdf.RDFReifies, inner, rdf.NewDefaultGraph()),
+		rdf.NewQuad(rdf.NewNamedNode("http://example.org/r2"), rdf.RDFReifies, nested, rdf.NewDefaultGraph()),
+		rdf.NewQuad(rdf.NewNamedNode("http://example.org/r3"), rdf.RDFReifies, rdf.NewLiteral("not a triple"), rdf.NewDefaultGraph()),
+	}
+	if err := tripleStore.InsertQuadsBatch(quads); err != nil {
+		t.Fatalf("failed to batch insert: %v", err)
+	}
+
+	// Triple terms decode back to their components, including nested ones
+	iter, err := tripleStore.Query(context.Background(), &store.Pattern{
+		Subject:   rdf.NewNamedNode("http://example.org/r2"),
+		Predicate: &store.Variable{Name: "p"},
+		Object:    &store.Variable{Name: "o"},
+	})
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	if !iter.Next() {
+		t.Fatalf("expected a result for r2")
+	}
+	quad, err := iter.Quad()
+	if err != nil {
+		t.Fatalf("failed to decode quad: %v", err)
+	}
+	if !quad.Object.Equals(nested) {
+		t.Errorf("expected %s, got %s", nested, quad.Object)
+	}
+	iter.Close()
+
+	// Triple term patterns match inside triple terms
+	iter, err = tripleStore.Query(context.Background(), &store.Pattern{
+		Subject:   &store.Variable{Name: "r"},
+		Predicate: rdf.RDFReifies,
+		Object: &store.TripleTermPattern{
+			Subject:   &store.Variable{Name: "s"},
+			Predicate: knows,
+			Object:    &store.Variable{Name: "o"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	defer iter.Close()
+
+	var matched []*rdf.Quad
+	for iter.Next() {
+		quad, err := iter.Quad()
+		if err != nil {
+			t.Fatalf("failed to decode quad: %v", err)
+		}
+		matched = append(matched, quad)
+	}
+	if len(matched) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(matched))
+	}
+	if !matched[0].Subject.Equals(rdf.NewNamedNode("http://example.org/r1")) {
+		t.Errorf("expected r1, got %s", matched[0].Subject)
+	}
+}
+
+func TestReifiedTripleInsertsReifiesStatement(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage, err := NewBadgerStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	tripleStore := store.NewTripleStore(storage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+
+	reifier := rdf.NewNamedNode("http://example.org/claim")
+	quoted, err := rdf.NewQuotedTriple(
+		rdf.NewNamedNode("http://example.org/alice"),
+		rdf.NewNamedNode("http://xmlns.com/foaf/0.1/knows"),
+		rdf.NewNamedNode("http://example.org/bob"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create quoted triple: %v", err)
+	}
+	reified := &rdf.ReifiedTriple{Identifier: reifier, Triple: quoted}
+	quad := rdf.NewQuad(reified, rdf.NewNamedNode("http://example.org/certainty"), rdf.NewLiteral("high"), rdf.NewDefaultGraph())
+	if err := tripleStore.InsertQuad(quad); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	count, err := tripleStore.Count()
+	if err != nil {
+		t.Fatalf("failed to count: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected count 2 (annotation + rdf:reifies), got %d", count)
+	}
+
+	contains, err := tripleStore.ContainsQuad(rdf.NewQuad(reifier, rdf.RDFReifies, &rdf.TripleTerm{
+		Subject:   reified.Triple.Subject,
+		Predicate: reified.Triple.Predicate,
+		Object:    reified.Triple.Object,
+	}, rdf.NewDefaultGraph()))
+	if err != nil {
+		t.Fatalf("failed to check quad: %v", err)
+	}
+	if !contains {
+		t.Errorf("expected rdf:reifies statement for reifier")
+	}
+}
+
+func TestRegistryWithBadgerProvider(t *testing.T) {
+	root := t.TempDir()
+	provider, err := NewBadgerProvider(root)
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	registry, err := store.NewRegistry(provider, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	people, err := registry.Create("people")
+	if err != nil {
+		t.Fatalf("failed to create dataset: %v", err)
+	}
+	if _, err := registry.Create("places"); err != nil {
+		t.Fatalf("failed to create dataset: %v", err)
+	}
+	if _, err := registry.Create("people"); !errors.Is(err, store.ErrDatasetExists) {
+		t.Errorf("expected ErrDatasetExists, got %v", err)
+	}
+	if _, err := registry.Create("../escape"); !errors.Is(err, store.ErrInvalidDatasetName) {
+		t.Errorf("expected ErrInvalidDatasetName, got %v", err)
+	}
+
+	triple := rdf.NewTriple(
+		rdf.NewNamedNode("http://example.org/alice"),
+		rdf.NewNamedNode("http://xmlns.com/foaf/0.1/name"),
+		rdf.NewLiteral("Alice"),
+	)
+	if err := people.InsertTriple(triple); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	// Datasets are independent stores
+	places, _ := registry.Get("places")
+	if count, _ := places.Count(); count != 0 {
+		t.Errorf("expected empty places dataset, got %d triples", count)
+	}
+
+	if err := registry.Delete("places"); err != nil {
+		t.Fatalf("failed to delete dataset: %v", err)
+	}
+	if err := registry.Delete("places"); !errors.Is(err, store.ErrDatasetNotFound) {
+		t.Errorf("expected ErrDatasetNotFound, got %v", err)
+	}
+	if err := registry.Close(); err != nil {
+		t.Fatalf("failed to close registry: %v", err)
+	}
+
+	// Reopening picks up the remaining datasets with their data
+	registry, err = store.NewRegistry(provider, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+	if err != nil {
+		t.Fatalf("failed to reopen registry: %v", err)
+	}
+	defer registry.Close()
+
+	names := registry.Names()
+	if len(names) != 1 || names[0] != "people" {
+		t.Fatalf("expected [people], got %v", names)
+	}
+	people, _ = registry.Get("people")
+	if count, _ := people.Count(); count != 1 {
+		t.Errorf("expected 1 triple in people dataset, got %d", count)
+	}
+}
+
+func TestRegistryDeleteWaitsForUsers(t *testing.T) {
+	provider, err := NewBadgerProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	registry, err := store.NewRegistry(provider, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	defer registry.Close()
+	if _, err := registry.Create("people"); err != nil {
+		t.Fatalf("failed to create dataset: %v", err)
+	}
+
+	people, release, err := registry.Acquire("people")
+	if err != nil {
+		t.Fatalf("failed to acquire dataset: %v", err)
+	}
+	deleted := make(chan error, 1)
+	go func() { deleted <- registry.Delete("people") }()
+
+	// The deleted dataset can't be acquired or recreated, but the acquired store stays open
+	for {
+		_, again, err := registry.Acquire("people")
+		if errors.Is(err, store.ErrDatasetNotFound) {
+			break
+		}
+		again()
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := registry.Create("people"); !errors.Is(err, store.ErrDatasetExists) {
+		t.Errorf("expected ErrDatasetExists while deleting, got %v", err)
+	}
+	triple := rdf.NewTriple(
+		rdf.NewNamedNode("http://example.org/alice"),
+		rdf.NewNamedNode("http://xmlns.com/foaf/0.1/name"),
+		rdf.NewLiteral("Alice"),
+	)
+	if err := people.InsertTriple(triple); err != nil {
+		t.Fatalf("failed to insert into acquired store: %v", err)
+	}
+	if count, err := people.Count(); err != nil || count != 1 {
+		t.Errorf("expected 1 triple, got %d (%v)", count, err)
+	}
+	select {
+	case err := <-deleted:
+		t.Fatalf("delete finished before the store was released: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	release() // releasing twice is harmless
+	if err := <-deleted; err != nil {
+		t.Fatalf("failed to delete dataset: %v", err)
+	}
+
+	// The name is free again, for an empty dataset
+	recreated, err := registry.Create("people")
+	if err != nil {
+		t.Fatalf("failed to recreate dataset: %v", err)
+	}
+	if count, _ := recreated.Count(); count != 0 {
+		t.Errorf("expected the recreated dataset to be empty, got %d triples", count)
+	}
+}
+
+func TestGraphAccessControl(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage, err := NewBadgerStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	tripleStore := store.NewTripleStore(storage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+
+	name := rdf.NewNamedNode("http://xmlns.com/foaf/0.1/name")
+	graphA := rdf.NewNamedNode("http://example.org/customers/a/data")
+	graphB := rdf.NewNamedNode("http://example.org/customers/b/data")
+	quads := []*rdf.Quad{
+		rdf.NewQuad(rdf.NewNamedNode("http://example.org/alice"), name, rdf.NewLiteral("Alice"), graphA),
+		rdf.NewQuad(rdf.NewNamedNode("http://example.org/bob"), name, rdf.NewLiteral("Bob"), graphB),
+		rdf.NewQuad(rdf.NewNamedNode("http://example.org/carol"), name, rdf.NewLiteral("Carol"), rdf.NewDefaultGraph()),
+	}
+	if err := tripleStore.InsertQuadsBatch(quads); err != nil {
+		t.Fatalf("failed to batch insert: %v", err)
+	}
+
+	policy := &access.Policy{Principals: map[string][]access.Rule{
+		"alice": {{Graph: "http://example.org/customers/a/*", Permissions: []string{"read", "write"}}},
+		"*":     {{Graph: "default", Permissions: []string{"read"}}},
+	}}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("invalid policy: %v", err)
+	}
+	aliceStore := tripleStore.WithAuthorizer(policy.Authorizer("alice"))
+
+	// Graph variables only range over readable named graphs
+	iter, err := aliceStore.Query(context.Background(), &store.Pattern{
+		Subject:   &store.Variable{Name: "s"},
+		Predicate: name,
+		Object:    &store.Variable{Name: "o"},
+		Graph:     &store.Variable{Name: "g"},
+	})
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	var graphs []rdf.Term
+	for iter.Next() {
+		quad, err := iter.Quad()
+		if err != nil {
+			t.Fatalf("failed to decode quad: %v", err)
+		}
+		graphs = append(graphs, quad.Graph)
+	}
+	iter.Close()
+	if len(graphs) != 1 || !graphs[0].Equals(graphA) {
+		t.Errorf("expected only graph A, got %v", graphs)
+	}
+
+	// Bound unreadable graphs return nothing
+	iter, err = aliceStore.Query(context.Background(), &store.Pattern{
+		Subject:   &store.Variable{Name: "s"},
+		Predicate: &store.Variable{Name: "p"},
+		Object:    &store.Variable{Name: "o"},
+		Graph:     graphB,
+	})
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	if iter.Next() {
+		t.Errorf("expected no quads from graph B")
+	}
+	iter.Close()
+
+	if count, _ := aliceStore.Count(); count != 2 {
+		t.Errorf("expected 2 readable quads, got %d", count)
+	}
+
+	// Writes to graphs without write permission fail atomically
+	err = aliceStore.InsertQuadsBatch([]*rdf.Quad{
+		rdf.NewQuad(rdf.NewNamedNode("http://example.org/dave"), name, rdf.NewLiteral("Dave"), graphA),
+		rdf.NewQuad(rdf.NewNamedNode("http://example.org/eve"), name, rdf.NewLiteral("Eve"), graphB),
+	})
+	if !errors.Is(err, store.ErrAccessDenied) {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+	if count, _ := tripleStore.Count(); count != 3 {
+		t.Errorf("expected rejected batch to insert nothing, got %d quads", count)
+	}
+
+	// Anonymous callers only get the rules for every principal
+	anonymousStore := tripleStore.WithAuthorizer(policy.Authorizer(""))
+	if count, _ := anonymousStore.Count(); count != 1 {
+		t.Errorf("expected 1 readable quad for anonymous caller, got %d", count)
+	}
+
+	// Only callers who can read every graph may see what is derived from all of them
+	readAll := &access.Policy{Principals: map[string][]access.Rule{
+		"admin": {{Graph: "*", Permissions: []string{"read"}}},
+		"*":     {{Graph: "default", Permissions: []string{"read"}}, {Graph: "http://example.org/customers/*", Permissions: []string{"read"}}},
+		"bob":   {{Graph: "http://example.org/customers/*", Permissions: []string{"read"}}},
+	}}
+	if err := readAll.Validate(); err != nil {
+		t.Fatalf("invalid policy: %v", err)
+	}
+	for _, tt := range []struct {
+		store    *store.TripleStore
+		expected bool
+	}{
+		{tripleStore, true},
+		{tripleStore.WithAuthorizer(readAll.Authorizer("admin")), true},
+		{tripleStore.WithAuthorizer(readAll.Authorizer("bob")), true},
+		{aliceStore, false},
+		{anonymousStore, false},
+	} {
+		if readsAll, err := tt.store.CanReadAll(); err != nil || readsAll != tt.expected {
+			t.Errorf("expected CanReadAll %v, got %v (%v)", tt.expected, readsAll, err)
+		}
+	}
+}
+
+func TestLoadQuadsBeyondTransactionLimit(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage, err := NewBadgerStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	tripleStore := store.NewTripleStore(storage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+
+	// More quads than one batch, each with distinct terms, so a single transaction per
+	// batch would exceed Badger's size limit
+	total := store.LoadBatchSize + 2500
+	var doc strings.Builder
+	for i := 0; i < total; i++ {
+		fmt.Fprintf(&doc, "<http://example.org/s%d> <http://example.org/p> \"value %d\" .\n", i, i)
+	}
+
+	inserted, err := tripleStore.LoadQuads(strings.NewReader(doc.String()), &rdf.NTriplesIOParser{})
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if inserted != total {
+		t.Errorf("expected %d quads inserted, got %d", total, inserted)
+	}
+
+	count, err := tripleStore.Count()
+	if err != nil {
+		t.Fatalf("failed to count: %v", err)
+	}
+	if count != int64(total) {
+		t.Errorf("expected count %d, got %d", total, count)
+	}
+}
+
+func TestQueryCancellation(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage, err := NewBadgerStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	tripleStore := store.NewTripleStore(storage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+
+	name := rdf.NewNamedNode("http://xmlns.com/foaf/0.1/name")
+	var quads []*rdf.Quad
+	for _, person := range []string{"alice", "bob", "charlie"} {
+		quads = append(quads, rdf.NewQuad(rdf.NewNamedNode("http://example.org/"+person), name, rdf.NewLiteral(person), rdf.NewDefaultGraph()))
+	}
+	if err := tripleStore.InsertQuadsBatch(quads); err != nil {
+		t.Fatalf("failed to batch insert: %v", err)
+	}
+
+	pattern := &store.Pattern{
+		Subject:   &store.Variable{Name: "s"},
+		Predicate: name,
+		Object:    &store.Variable{Name: "o"},
+	}
+
+	// A cancelled context fails the query up front
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tripleStore.Query(cancelled, pattern); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	// Cancelling while iterating stops the iterator
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	iter, err := tripleStore.Query(ctx, pattern)
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	defer iter.Close()
+
+	if !iter.Next() {
+		t.Fatal("expected a first result")
+	}
+	cancel()
+	if iter.Next() {
+		t.Error("expected iteration to stop after cancellation")
+	}
+}
+
+func TestAnalyzeStatistics(t *testing.T) {
+	storage, err := NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	tripleStore := store.NewTripleStore(storage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+
+	stats, err := tripleStore.LoadStatistics()
+	if err != nil {
+		t.Fatalf("failed to load statistics: %v", err)
+	}
+	if stats != nil {
+		t.Fatalf("expected no statistics before analyzing, got %+v", stats)
+	}
+
+	name := rdf.NewNamedNode("http://xmlns.com/foaf/0.1/name")
+	knows := rdf.NewNamedNode("http://xmlns.com/foaf/0.1/knows")
+	alice := rdf.NewNamedNode("http://example.org/alice")
+	bob := rdf.NewNamedNode("http://example.org/bob")
+	carol := rdf.NewNamedNode("http://example.org/carol")
+	graph := rdf.NewNamedNode("http://example.org/graph1")
+
+	quads := []*rdf.Quad{
+		rdf.NewQuad(alice, name, rdf.NewLiteral("Alice"), rdf.NewDefaultGraph()),
+		rdf.NewQuad(alice, knows, bob, rdf.NewDefaultGraph()),
+		rdf.NewQuad(alice, knows, carol, rdf.NewDefaultGraph()),
+		rdf.NewQuad(bob, name, rdf.NewLiteral("Bob"), rdf.NewDefaultGraph()),
+		rdf.NewQuad(bob, knows, carol, rdf.NewDefaultGraph()),
+		rdf.NewQuad(carol, name, rdf.NewLiteral("Carol"), graph),
+	}
+	if err := tripleStore.InsertQuadsBatch(quads); err != nil {
+		t.Fatalf("failed to batch insert: %v", err)
+	}
+
+	if _, err := tripleStore.Analyze(context.Background()); err != nil {
+		t.Fatalf("failed to analyze: %v", err)
+	}
+
+	// The persisted statistics are what the optimizer loads
+	stats, err = tripleStore.LoadStatistics()
+	if err != nil {
+		t.Fatalf("failed to load statistics: %v", err)
+	}
+	if stats == nil {
+		t.Fatal("expected statistics after analyzing")
+	}
+
+	if stats.Quads != 6 || stats.Subjects != 3 || stats.Objects != 5 {
+		t.Errorf("expected 6 quads, 3 subjects and 5 objects, got %d, %d and %d", stats.Quads, stats.Subjects, stats.Objects)
+	}
+	if stats.Graphs[""] != 5 || stats.Graphs[graph.String()] != 1 {
+		t.Errorf("unexpected graph counts: %v", stats.Graphs)
+	}
+
+	expected := map[string]store.PredicateStatistics{
+		name.String():  {Quads: 3, Subjects: 3, Objects: 3},
+		knows.String(): {Quads: 3, Subjects: 2, Objects: 2},
+	}
+	for predicate, want := range expected {
+		got, ok := stats.Predicates[predicate]
+		if !ok {
+			t.Errorf("missing statistics for %s", predicate)
+			continue
+		}
+		if *got != want {
+			t.Errorf("%s: expected %+v, got %+v", predicate, want, *got)
+		}
+	}
+
+	// alice and bob use {knows, name}, carol only {name}
+	if len(stats.CharacteristicSets) != 2 {
+		t.Fatalf("expected 2 characteristic sets, got %d", len(stats.CharacteristicSets))
+	}
+	star := stats.CharacteristicSets[0]
+	if star.Subjects != 2 || len(star.Predicates) != 2 {
+		t.Fatalf("unexpected most frequent characteristic set: %+v", star)
+	}
+	for i, predicate := range star.Predicates {
+		want := map[string]int64{name.String(): 2, knows.String(): 3}[predicate]
+		if star.Occurrences[i] != want {
+			t.Errorf("%s: expected %d occurrences, got %d", predicate, want, star.Occurrences[i])
+		}
+	}
+}
+
+func TestTrieSeek(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage, err := NewBadgerStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	tripleStore := store.NewTripleStore(storage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+
+	knows := rdf.NewNamedNode("http://xmlns.com/foaf/0.1/knows")
+	people := []*rdf.NamedNode{
+		rdf.NewNamedNode("http://example.org/alice"),
+		rdf.NewNamedNode("http://example.org/bob"),
+		rdf.NewNamedNode("http://example.org/carol"),
+	}
+	var quads []*rdf.Quad
+	for _, s := range people {
+		for _, o := range people {
+			if s != o {
+				quads = append(quads, rdf.NewQuad(s, knows, o, rdf.NewDefaultGraph()))
+			}
+		}
+	}
+	if err := tripleStore.InsertQuadsBatch(quads); err != nil {
+		t.Fatalf("failed to batch insert: %v", err)
+	}
+
+	encodedKnows, err := tripleStore.EncodeTerm(knows)
+	if err != nil {
+		t.Fatalf("failed to encode term: %v", err)
+	}
+
+	// Iterator.Seek positions at the first key at or after the target
+	txn, err := storage.Begin(false)
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	defer txn.Rollback()
+	it, err := txn.Scan(store.TablePOS, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to scan: %v", err)
+	}
+	defer it.Close()
+	if !it.Seek(encodedKnows[:]) {
+		t.Fatal("expected a key after seeking to the predicate")
+	}
+	if key := it.Key(); len(key) != 3*len(encodedKnows) || string(key[:len(encodedKnows)]) != string(encodedKnows[:]) {
+		t.Errorf("expected a key starting with the predicate, got %x", key)
+	}
+
+	// The POS trie has one predicate, three objects, and two subjects under each object
+	trie, err := tripleStore.OpenTrie(context.Background(), [3]int{1, 2, 0}, nil)
+	if err != nil {
+		t.Fatalf("failed to open trie: %v", err)
+	}
+	defer trie.Close()
+
+	trie.Open()
+	if trie.AtEnd() || trie.Key() != encodedKnows {
+		t.Fatal("expected the predicate at the first level")
+	}
+	trie.Open()
+	var objects []store.EncodedTerm
+	for ; !trie.AtEnd(); trie.Next() {
+		objects = append(objects, trie.Key())
+
+		trie.Open()
+		subjects := 0
+		for ; !trie.AtEnd(); trie.Next() {
+			if trie.Key() == objects[len(objects)-1] {
+				t.Error("a subject knows itself")
+			}
+			subjects++
+		}
+		trie.Up()
+		if subjects != 2 {
+			t.Errorf("expected 2 subjects, got %d", subjects)
+		}
+	}
+	if len(objects) != 3 {
+		t.Fatalf("expected 3 objects, got %d", len(objects))
+	}
+
+	// Seeking within a level skips to the first key at or after the target
+	trie.Up()
+	trie.Open()
+	trie.Seek(objects[1])
+	if trie.AtEnd() || trie.Key() != objects[1] {
+		t.Error("expected seek to find the second object")
+	}
+	trie.Seek(objects[0])
+	if trie.Key() != objects[1] {
+		t.Error("seeking backwards moved the trie")
+	}
+	after := objects[2]
+	after[len(after)-1]++
+	trie.Seek(after)
+	if !trie.AtEnd() {
+		t.Error("expected the level to end after the last object")
+	}
+}
+
+func TestApplyPatchAndDiffGraphs(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage, err := NewBadgerStorage(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storage.Close()
+
+	tripleStore := store.NewTripleStore(storage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+
+	applyPatch := func(s *store.TripleStore, input string) error {
+		t.Helper()
+		patch, err := rdf.NewPatchParser(input).Parse()
+		if err != nil {
+			t.Fatalf("failed to parse patch: %v", err)
+		}
+		return s.ApplyPatch(patch)
+	}
+
+	err = applyPatch(tripleStore, `TX .
+PA "ex" "http://example.org/" .
+A ex:alice ex:name "Alice" .
+A ex:bob ex:name "Bob" .
+A ex:alice ex:name "Alice" ex:staging .
+A ex:carol ex:name "Carol" ex:staging .
+TC .
+TX .
+A ex:dave ex:name "Dave" .
+TA .
+`)
+	if err != nil {
+		t.Fatalf("failed to apply patch: %v", err)
+	}
+	if count, _ := tripleStore.Count(); count != 4 {
+		t.Fatalf("expected 4 quads, got %d", count)
+	}
+
+	// An unterminated transaction and a denied write both leave the store unchanged
+	err = applyPatch(tripleStore, `TX .
+D <http://example.org/alice> <http://example.org/name> "Alice" .
+`)
+	if !errors.Is(err, store.ErrInvalidData) {
+		t.Errorf("expected ErrInvalidData, got %v", err)
+	}
+	policy := &access.Policy{Principals: map[string][]access.Rule{
+		"*": {{Graph: "default", Permissions: []string{"read", "write"}}},
+	}}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("invalid policy: %v", err)
+	}
+	err = applyPatch(tripleStore.WithAuthorizer(policy.Authorizer("")), `TX .
+D <http://example.org/alice> <http://example.org/name> "Alice" .
+D <http://example.org/carol> <http://example.org/name> "Carol" <http://example.org/staging> .
+TC .
+`)
+	if !errors.Is(err, store.ErrAccessDenied) {
+		t.Errorf("expected ErrAccessDenied, got %v", err)
+	}
+	if count, _ := tripleStore.Count(); count != 4 {
+		t.Errorf("expected rejected patches to change nothing, got %d quads", count)
+	}
+
+	// The diff from the default graph to the staging graph, applied, makes them equal
+	staging := rdf.NewNamedNode("http://example.org/staging")
+	patch, err := tripleStore.DiffGraphs(context.Background(), nil, staging)
+	if err != nil {
+		t.Fatalf("failed to diff graphs: %v", err)
+	}
+	if len(patch.Rows) != 4 || patch.Rows[1].Operation != rdf.PatchDelete || patch.Rows[2].Operation != rdf.PatchAdd {
+		t.Fatalf("expected a transaction deleting Bob and adding Carol, got %d rows", len(patch.Rows))
+	}
+	if err := tripleStore.ApplyPatch(patch); err != nil {
+		t.Fatalf("failed to apply diff: %v", err)
+	}
+	patch, err = tripleStore.DiffGraphs(context.Background(), nil, staging)
+	if err != nil {
+		t.Fatalf("failed to diff graphs: %v", err)
+	}
+	if len(patch.Rows) != 0 {
+		t.Errorf("expected equal graphs after applying the diff, got %d rows", len(patch.Rows))
+	}
+
+	// A patch too large for one transaction is rejected as a whole
+	var large strings.Builder
+	large.WriteString("TX .\n")
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&large, "A <http://example.org/s%d> <http://example.org/value> %d .\n", i, i)
+	}
+	large.WriteString("TC .\n")
+	err = applyPatch(tripleStore, large.String())
+	if !errors.Is(err, store.ErrPatchTooLarge) || !errors.Is(err, store.ErrTxnTooBig) {
+		t.Errorf("expected ErrPatchTooLarge, got %v", err)
+	}
+	if count, _ := tripleStore.Count(); count != 4 {
+		t.Errorf("expected the large patch to change nothing, got %d quads", count)
+	}
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// BadgerProvider implements store.StorageProvider with one Badger directory per dataset
+type BadgerProvider struct {
+	root string
+}
+
+// NewBadgerProvider creates a provider storing datasets under the given root directory
+func NewBadgerProvider(root string) (*BadgerProvider, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create datasets directory: %w", err)
+	}
+	return &BadgerProvider{root: root}, nil
+}
+
+// Open opens the Badger storage of a dataset, creating it if needed
+func (p *BadgerProvider) Open(name string) (store.Storage, error) {
+	return NewBadgerStorage(p.path(name))
+}
+
+// Remove deletes the Badger directory of a dataset
+func (p *BadgerProvider) Remove(name string) error {
+	if err := os.RemoveAll(p.path(name)); err != nil {
+		return fmt.Errorf("failed to remove dataset directory: %w", err)
+	}
+	return nil
+}
+
+// List returns the names of all dataset directories
+func (p *BadgerProvider) List() ([]string, error) {
+	entries, err := os.ReadDir(p.root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read datasets directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (p *BadgerProvider) path(name string) string {
+	return filepath.Join(p.root, name)
+}
@@ -0,0 +1,393 @@
+package testsuite
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+)
+
+// JSONLDTestOptions are the options of a test in a JSON-LD API manifest
+type JSONLDTestOptions struct {
+	SpecVersion           string `json:"specVersion"`
+	ProcessingMode        string `json:"processingMode"`
+	Base                  string `json:"base"`
+	ExpandContext         string `json:"expandContext"`
+	ProduceGeneralizedRDF bool   `json:"produceGeneralizedRdf"`
+	RDFDirection          string `json:"rdfDirection"`
+	ContentType           string `json:"contentType"`
+	HTTPLink              any    `json:"httpLink"`
+	HTTPStatus            int    `json:"httpStatus"`
+	RedirectTo            string `json:"redirectTo"`
+	UseNativeTypes        bool   `json:"useNativeTypes"`
+	UseRDFType            bool   `json:"useRdfType"`
+	CompactArrays         *bool  `json:"compactArrays"`
+	CompactToRelative     *bool  `json:"compactToRelative"`
+	OmitGraph             *bool  `json:"omitGraph"`
+}
+
+// jsonldManifest is a JSON-LD API test manifest, such as toRdf-manifest.jsonld
+type jsonldManifest struct {
+	BaseIRI  string `json:"baseIri"`
+	Sequence []struct {
+		ID              string            `json:"@id"`
+		Type            any               `json:"@type"`
+		Name            string            `json:"name"`
+		Purpose         string            `json:"purpose"`
+		Input           string            `json:"input"`
+		Context         string            `json:"context"`
+		Frame           string            `json:"frame"`
+		Expect          string            `json:"expect"`
+		ExpectErrorCode string            `json:"expectErrorCode"`
+		Option          JSONLDTestOptions `json:"option"`
+	} `json:"sequence"`
+}
+
+// ParseJSONLDManifest parses a manifest of the W3C JSON-LD API test suite
+func ParseJSONLDManifest(path string) (*TestManifest, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - test suite legitimately reads test manifest files
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	var m jsonldManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	manifest := &TestManifest{
+		BaseURI: filepath.Dir(path),
+		BaseIRI: m.BaseIRI,
+	}
+	for _, entry := range m.Sequence {
+		test := TestCase{
+			Name:        strings.TrimPrefix(entry.ID, "#"),
+			Action:      entry.Input,
+			Result:      entry.Expect,
+			Approved:    true,
+			Description: entry.Name,
+			ErrorCode:   entry.ExpectErrorCode,
+			Context:     entry.Context,
+			Frame:       entry.Frame,
+		}
+		option := entry.Option
+		test.JSONLD = &option
+
+		// Tests have a test class and a type naming the algorithm tested
+		var types []string
+		switch t := entry.Type.(type) {
+		case string:
+			types = []string{t}
+		case []any:
+			for _, item := range t {
+				if s, ok := item.(string); ok {
+					types = append(types, s)
+				}
+			}
+		}
+		for _, t := range types {
+			switch TestType(t) {
+			case TestTypeJSONLDPositiveEval, TestTypeJSONLDNegativeEval, TestTypeJSONLDPositiveSyntax:
+				test.Type = TestType(t)
+			case "jld:ToRDFTest":
+				test.Algorithm = "toRdf"
+			case "jld:FromRDFTest":
+				test.Algorithm = "fromRdf"
+			case "jld:CompactTest":
+				test.Algorithm = "compact"
+			case "jld:FrameTest":
+				test.Algorithm = "frame"
+			}
+		}
+		if test.Type == "" && len(types) > 0 {
+			test.Type = TestType(types[0])
+		}
+		manifest.Tests = append(manifest.Tests, test)
+	}
+	return manifest, nil
+}
+
+// runJSONLDToRDFTest converts a JSON-LD document to RDF and compares it with the expected N-Quads
+func (r *TestRunner) runJSONLDToRDFTest(manifest *TestManifest, test *TestCase) TestResult {
+	if !jsonldTestSupported(test) {
+		return TestResultSkip
+	}
+	actualQuads, err := r.parseJSONLDTest(manifest, test)
+	if err != nil {
+		r.recordError(test, fmt.Sprintf("Parser error: %v", err))
+		return TestResultFail
+	}
+	if test.Type == TestTypeJSONLDPositiveSyntax || test.Result == "" {
+		return TestResultPass
+	}
+
+	resultFile := manifest.ResolveFile(test.Result)
+	resultBytes, err := os.ReadFile(resultFile) // #nosec G304 - test suite legitimately reads test result files
+	if err != nil {
+		r.recordError(test, fmt.Sprintf("Failed to read result file: %v", err))
+		return TestResultError
+	}
+	expectedQuads, err := r.parseRDFDataAsQuads(string(resultBytes), "nquads", "")
+	if err != nil {
+		r.recordError(test, fmt.Sprintf("Failed to parse expected results: %v", err))
+		return TestResultError
+	}
+
+	if !r.compareQuads(expectedQuads, actualQuads) {
+		r.recordError(test, fmt.Sprintf("Quads mismatch: expected %d quads, got %d quads", len(expectedQuads), len(actualQuads)))
+		return TestResultFail
+	}
+	return TestResultPass
+}
+
+// runJSONLDNegativeTest checks that converting a JSON-LD document fails with the expected error code
+func (r *TestRunner) runJSONLDNegativeTest(manifest *TestManifest, test *TestCase) TestResult {
+	if !jsonldTestSupported(test) {
+		return TestResultSkip
+	}
+	var err error
+	if test.Algorithm == "toRdf" {
+		_, err = r.parseJSONLDTest(manifest, test)
+	} else {
+		_, err = r.serializeJSONLDTest(manifest, test)
+	}
+	if err == nil {
+		r.recordError(test, fmt.Sprintf("Data parsed successfully but should have failed with %q", test.ErrorCode))
+		return TestResultFail
+	}
+	var jsonldErr *rdf.JSONLDError
+	if !errors.As(err, &jsonldErr) || jsonldErr.Code != test.ErrorCode {
+		r.recordError(test, fmt.Sprintf("Expected error %q, got: %v", test.ErrorCode, err))
+		return TestResultFail
+	}
+	return TestResultPass
+}
+
+// jsonldTestSupported reports whether a JSON-LD test exercises something the parser and
+// serializer do: tests of other API methods such as expand, tests only for JSON-LD 1.0
+// processors, of generalized RDF, of HTML input or of HTTP behaviour are skipped
+func jsonldTestSupported(test *TestCase) bool {
+	option := test.JSONLD
+	if test.Algorithm == "" {
+		return false
+	}
+	if option == nil {
+		return true
+	}
+	switch {
+	case option.SpecVersion == "json-ld-1.0":
+		return false
+	case option.ProduceGeneralizedRDF:
+		return false
+	case option.ContentType != "" && option.ContentType != "application/ld+json":
+		return false
+	case option.HTTPLink != nil, option.HTTPStatus != 0, option.RedirectTo != "":
+		return false
+	}
+	if test.Algorithm == "fromRdf" {
+		return strings.HasSuffix(test.Action, ".nq")
+	}
+	return strings.HasSuffix(test.Action, ".jsonld") || strings.HasSuffix(test.Action, ".json")
+}
+
+// parseJSONLDTest parses the input of a JSON-LD test with the options the test gives. Files
+// referenced by the test, such as remote contexts, are loaded from the manifest's directory.
+func (r *TestRunner) parseJSONLDTest(manifest *TestManifest, test *TestCase) ([]*rdf.Quad, error) {
+	dataFile := manifest.ResolveFile(test.Action)
+	dataBytes, err := os.ReadFile(dataFile) // #nosec G304 - test suite legitimately reads test data files
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	loader, base := r.jsonldTestLoader(manifest, test)
+	parser := rdf.NewJSONLDParser()
+	parser.SetDocumentLoader(loader)
+	parser.SetBaseURI(base)
+	if option := test.JSONLD; option != nil {
+		if option.ProcessingMode != "" {
+			parser.SetProcessingMode(option.ProcessingMode)
+		}
+		if option.RDFDirection != "" {
+			parser.SetRDFDirection(option.RDFDirection)
+		}
+		if option.ExpandContext != "" {
+			contextBytes, err := os.ReadFile(manifest.ResolveFile(option.ExpandContext)) // #nosec G304 - test suite legitimately reads test data files
+			if err != nil {
+				return nil, fmt.Errorf("failed to read expand context: %w", err)
+			}
+			if err := parser.SetExpandContext(contextBytes); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return parser.Parse(strings.NewReader(string(dataBytes)))
+}
+
+// jsonldTestLoader returns a document loader for the files of a JSON-LD test, which are loaded
+// from the manifest's directory, and the base IRI of the test's input
+func (r *TestRunner) jsonldTestLoader(manifest *TestManifest, test *TestCase) (*rdf.LocalDocumentLoader, string) {
+	loader := rdf.NewLocalDocumentLoader()
+	baseIRI := manifest.BaseIRI
+	if baseIRI == "" {
+		baseIRI = r.filePathToURI(manifest.BaseURI) + "/"
+	}
+	loader.AddDirectory(baseIRI, manifest.BaseURI)
+
+	base := baseIRI + test.Action
+	if test.JSONLD != nil && test.JSONLD.Base != "" {
+		base = test.JSONLD.Base
+	}
+	return loader, base
+}
+
+// runJSONLDSerializeTest runs the fromRdf, compact or frame method on the input of a JSON-LD
+// test and compares the JSON-LD written with the expected document
+func (r *TestRunner) runJSONLDSerializeTest(manifest *TestManifest, test *TestCase) TestResult {
+	if !jsonldTestSupported(test) {
+		return TestResultSkip
+	}
+	actual, err := r.serializeJSONLDTest(manifest, test)
+	if err != nil {
+		r.recordError(test, fmt.Sprintf("Serializer error: %v", err))
+		return TestResultFail
+	}
+	if test.Type == TestTypeJSONLDPositiveSyntax || test.Result == "" {
+		return TestResultPass
+	}
+
+	expectedBytes, err := os.ReadFile(manifest.ResolveFile(test.Result)) // #nosec G304 - test suite legitimately reads test result files
+	if err != nil {
+		r.recordError(test, fmt.Sprintf("Failed to read result file: %v", err))
+		return TestResultError
+	}
+	var expected, actualValue any
+	if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+		r.recordError(test, fmt.Sprintf("Failed to parse expected results: %v", err))
+		return TestResultError
+	}
+	if err := json.Unmarshal(actual, &actualValue); err != nil {
+		r.recordError(test, fmt.Sprintf("Failed to parse output: %v", err))
+		return TestResultFail
+	}
+	if !jsonldEqual(expected, actualValue, false) {
+		r.recordError(test, fmt.Sprintf("JSON-LD mismatch: expected %s, got %s", expectedBytes, actual))
+		return TestResultFail
+	}
+	return TestResultPass
+}
+
+// serializeJSONLDTest writes the input of a JSON-LD test with the method and options the test
+// gives: N-Quads input for fromRdf tests, JSON-LD compacted or framed for the others
+func (r *TestRunner) serializeJSONLDTest(manifest *TestManifest, test *TestCase) ([]byte, error) {
+	dataBytes, err := os.ReadFile(manifest.ResolveFile(test.Action)) // #nosec G304 - test suite legitimately reads test data files
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	loader, base := r.jsonldTestLoader(manifest, test)
+	serializer := rdf.NewJSONLDSerializer()
+	serializer.SetDocumentLoader(loader)
+	serializer.SetBaseURI(base)
+	if option := test.JSONLD; option != nil {
+		if option.ProcessingMode != "" {
+			serializer.SetProcessingMode(option.ProcessingMode)
+		}
+		if option.RDFDirection != "" {
+			serializer.SetRDFDirection(option.RDFDirection)
+		}
+		serializer.SetUseNativeTypes(option.UseNativeTypes)
+		serializer.SetUseRDFType(option.UseRDFType)
+		if option.CompactArrays != nil {
+			serializer.SetCompactArrays(*option.CompactArrays)
+		}
+		if option.CompactToRelative != nil {
+			serializer.SetCompactToRelative(*option.CompactToRelative)
+		}
+		if option.OmitGraph != nil {
+			serializer.SetOmitGraph(*option.OmitGraph)
+		}
+	}
+
+	for _, file := range []struct {
+		name string
+		set  func([]byte) error
+	}{
+		{test.Context, serializer.SetContext},
+		{test.Frame, serializer.SetFrame},
+	} {
+		if file.name == "" {
+			continue
+		}
+		data, err := os.ReadFile(manifest.ResolveFile(file.name)) // #nosec G304 - test suite legitimately reads test data files
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.name, err)
+		}
+		if err := file.set(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	if test.Algorithm == "fromRdf" {
+		quads, err := rdf.NewNQuadsParser(string(dataBytes)).Parse()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse input: %w", err)
+		}
+		err = serializer.Serialize(&out, quads)
+		return out.Bytes(), err
+	}
+	err = serializer.SerializeDocument(&out, bytes.NewReader(dataBytes))
+	return out.Bytes(), err
+}
+
+// jsonldEqual compares two JSON values the way the JSON-LD test suite does: arrays are
+// compared without regard to order, except for the values of @list
+func jsonldEqual(a, b any, ordered bool) bool {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, value := range av {
+			other, ok := bv[key]
+			if !ok || !jsonldEqual(value, other, key == "@list") {
+				return false
+			}
+		}
+		return true
+
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		if ordered {
+			for i := range av {
+				if !jsonldEqual(av[i], bv[i], false) {
+					return false
+				}
+			}
+			return true
+		}
+		used := make([]bool, len(bv))
+		for _, item := range av {
+			found := false
+			for j, other := range bv {
+				if !used[j] && jsonldEqual(item, other, false) {
+					used[j], found = true, true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -11,19 +11,26 @@ import (
 // TestManifest represents a SPARQL test manifest
 type TestManifest struct {
 	BaseURI string
+	BaseIRI string // IRI the test files are published under (JSON-LD manifests)
 	Tests   []TestCase
 }
 
 // TestCase represents a single SPARQL test
 type TestCase struct {
-	Name        string
-	Type        TestType
-	Action      string      // Query file
-	Data        []string    // Data files
-	GraphData   []GraphData // Named graph data
-	Result      string      // Expected result file
-	Approved    bool
-	Description string
+	Name          string
+	Type          TestType
+	Action        string      // Query file
+	Data          []string    // Data files
+	GraphData     []GraphData // Named graph data
+	Result        string      // Expected result file
+	Approved      bool
+	Description   string
+	ErrorCode     string             // Expected error code (JSON-LD negative tests)
+	JSONLD        *JSONLDTestOptions // Processing options (JSON-LD tests)
+	Algorithm     string             // API method tested: toRdf, fromRdf, compact or frame (JSON-LD tests)
+	Context       string             // Context file (JSON-LD compact tests)
+	Frame         string             // Frame file (JSON-LD frame tests)
+	HashAlgorithm string             // Hash algorithm such as SHA384, when not SHA256 (RDF canonicalization tests)
 }
 
 // GraphData represents a named graph in a test
@@ -84,11 +91,25 @@ const (
 	// JSON-LD tests (if needed in future)
 	TestTypeJSONLDEval           TestType = "TestJSONLDEval"
 	TestTypeJSONLDNegativeSyntax TestType = "TestJSONLDNegativeSyntax"
+
+	// JSON-LD API tests (JSON-LD manifests such as toRdf-manifest.jsonld)
+	TestTypeJSONLDPositiveEval   TestType = "jld:PositiveEvaluationTest"
+	TestTypeJSONLDNegativeEval   TestType = "jld:NegativeEvaluationTest"
+	TestTypeJSONLDPositiveSyntax TestType = "jld:PositiveSyntaxTest"
+
+	// RDF Dataset Canonicalization tests (rdf-canon)
+	TestTypeRDFC10Eval         TestType = "rdfc:RDFC10EvalTest"
+	TestTypeRDFC10Map          TestType = "rdfc:RDFC10MapTest"
+	TestTypeRDFC10NegativeEval TestType = "rdfc:RDFC10NegativeEvalTest"
 )
 
 // ParseManifest parses a Turtle manifest file (simplified parser)
 // This is a basic implementation - a full parser would use a proper Turtle library
+// Manifests written in JSON-LD (.jsonld) are parsed by ParseJSONLDManifest
 func ParseManifest(path string) (*TestManifest, error) {
+	if strings.HasSuffix(path, ".jsonld") {
+		return ParseJSONLDManifest(path)
+	}
 	return parseManifestWithVisited(path, make(map[string]bool))
 }
 
@@ -120,6 +141,7 @@ func parseManifestWithVisited(path string, visited map[string]bool) (*TestManife
 	var currentTest *TestCase
 	var inTest bool
 	var inInclude bool
+	var pendingTestID bool
 	var includeFiles []string
 
 	for scanner.Scan() {
@@ -161,13 +183,19 @@ func parseManifestWithVisited(path string, visited map[string]bool) (*TestManife
 		// Start of new test (test definition can be <#testname>, :testname, or prefix:testname like trs:test-1)
 		// Handles both "rdf:type" and shorthand "a rdft:" or "a mf:"
 		// Check if line contains "rdf:type" or " a " AND starts with a test identifier
-		hasTestType := strings.Contains(line, "rdf:type") || strings.Contains(line, " a rdft:") || strings.Contains(line, " a mf:")
+		hasTestType := strings.Contains(line, "rdf:type") || strings.Contains(line, " a rdft:") || strings.Contains(line, " a mf:") ||
+			strings.Contains(line, " a rdfc:")
 		// Match lines like: <#test> rdf:type ..., :test rdf:type ..., or trs:test rdf:type ...
 		startsWithTestID := strings.HasPrefix(line, "<#") ||
 			strings.HasPrefix(line, ":") ||
 			(len(line) > 0 && line[0] != ' ' && line[0] != '#' && strings.Contains(line, ":") &&
 				strings.Index(line, ":") < strings.Index(line, " "))
 		isTestStart := startsWithTestID && hasTestType
+		// The rdf-canon manifest puts the type on the line after the test identifier
+		if pendingTestID && strings.HasPrefix(line, "a rdfc:") {
+			isTestStart = true
+		}
+		pendingTestID = startsWithTestID && !strings.Contains(line, " ")
 
 		if isTestStart {
 			if currentTest != nil {
@@ -194,7 +222,7 @@ func parseManifestWithVisited(path string, visited map[string]bool) (*TestManife
 		}
 
 		// Parse test type
-		if strings.Contains(line, "rdf:type") || strings.Contains(line, " a mf:") || strings.Contains(line, "a rdft:") {
+		if strings.Contains(line, "rdf:type") || strings.Contains(line, " a mf:") || strings.Contains(line, "a rdft:") || strings.Contains(line, "a rdfc:") {
 			// SPARQL tests
 			if strings.Contains(line, "PositiveSyntaxTest11") {
 				currentTest.Type = TestTypePositiveSyntax11
@@ -252,6 +280,13 @@ func parseManifestWithVisited(path string, visited map[string]bool) (*TestManife
 				currentTest.Type = TestTypeJSONLDEval
 			} else if strings.Contains(line, "TestJSONLDNegativeSyntax") {
 				currentTest.Type = TestTypeJSONLDNegativeSyntax
+				// RDF canonicalization tests
+			} else if strings.Contains(line, "RDFC10NegativeEvalTest") {
+				currentTest.Type = TestTypeRDFC10NegativeEval
+			} else if strings.Contains(line, "RDFC10EvalTest") {
+				currentTest.Type = TestTypeRDFC10Eval
+			} else if strings.Contains(line, "RDFC10MapTest") {
+				currentTest.Type = TestTypeRDFC10Map
 			}
 		}
 
@@ -305,6 +340,13 @@ func parseManifestWithVisited(path string, visited map[string]bool) (*TestManife
 			}
 		}
 
+		// Parse the hash algorithm of canonicalization tests
+		if strings.Contains(line, "rdfc:hashAlgorithm") {
+			if parts := strings.Split(line, `"`); len(parts) >= 2 {
+				currentTest.HashAlgorithm = parts[1]
+			}
+		}
+
 		// Parse approval status
 		if strings.Contains(line, "mf:approval") && strings.Contains(line, "Approved") {
 			currentTest.Approved = true
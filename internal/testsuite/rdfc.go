@@ -0,0 +1,76 @@
+package testsuite
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+)
+
+// runRDFCTest runs an RDF Dataset Canonicalization test: eval tests compare the canonical
+// N-Quads, map tests the canonical blank node labels, and negative tests expect a failure
+func (r *TestRunner) runRDFCTest(manifest *TestManifest, test *TestCase) TestResult {
+	canonicalizer := rdf.NewCanonicalizer()
+	switch test.HashAlgorithm {
+	case "", "SHA256":
+	case "SHA384":
+		canonicalizer.SetHashAlgorithm(crypto.SHA384)
+	default:
+		return TestResultSkip
+	}
+
+	dataFile := manifest.ResolveFile(test.Action)
+	dataBytes, err := os.ReadFile(dataFile) // #nosec G304 - test suite legitimately reads test data files
+	if err != nil {
+		r.recordError(test, fmt.Sprintf("Failed to read data file: %v", err))
+		return TestResultError
+	}
+	quads, err := r.parseRDFDataAsQuads(string(dataBytes), "nquads", dataFile)
+	if err != nil {
+		r.recordError(test, fmt.Sprintf("Parser error: %v", err))
+		return TestResultError
+	}
+
+	dataset, err := canonicalizer.Canonicalize(quads)
+	if test.Type == TestTypeRDFC10NegativeEval {
+		if err == nil {
+			r.recordError(test, "Canonicalization succeeded but should have failed")
+			return TestResultFail
+		}
+		return TestResultPass
+	}
+	if err != nil {
+		r.recordError(test, fmt.Sprintf("Canonicalization error: %v", err))
+		return TestResultFail
+	}
+
+	resultFile := manifest.ResolveFile(test.Result)
+	expectedBytes, err := os.ReadFile(resultFile) // #nosec G304 - test suite legitimately reads test result files
+	if err != nil {
+		r.recordError(test, fmt.Sprintf("Failed to read result file: %v", err))
+		return TestResultError
+	}
+
+	if test.Type == TestTypeRDFC10Map {
+		var expected map[string]string
+		if err := json.Unmarshal(expectedBytes, &expected); err != nil {
+			r.recordError(test, fmt.Sprintf("Failed to parse expected map: %v", err))
+			return TestResultError
+		}
+		if !maps.Equal(expected, dataset.Identifiers) {
+			r.recordError(test, fmt.Sprintf("Identifier map mismatch: expected %v, got %v", expected, dataset.Identifiers))
+			return TestResultFail
+		}
+		return TestResultPass
+	}
+
+	// Canonical N-Quads must match byte for byte
+	if dataset.NQuads() != string(expectedBytes) {
+		r.recordError(test, "Canonical output mismatch")
+		return TestResultFail
+	}
+	return TestResultPass
+}
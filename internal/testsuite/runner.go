@@ -2,10 +2,12 @@ package testsuite
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aleksaelezovic/trigo/internal/encoding"
 	"github.com/aleksaelezovic/trigo/internal/storage"
@@ -17,6 +19,9 @@ import (
 	"github.com/aleksaelezovic/trigo/pkg/store"
 )
 
+// queryTimeout bounds each test query so a runaway evaluation can't stall the suite
+const queryTimeout = 30 * time.Second
+
 // TestRunner runs W3C SPARQL test suite tests
 type TestRunner struct {
 	store *store.TripleStore
@@ -160,6 +165,17 @@ func (r *TestRunner) runTest(manifest *TestManifest, test *TestCase) TestResult
 		return r.runRDFEvalTest(manifest, test, "jsonld")
 	case TestTypeJSONLDNegativeSyntax:
 		return r.runRDFNegativeSyntaxTest(manifest, test, "jsonld")
+	// JSON-LD API tests
+	case TestTypeJSONLDPositiveEval, TestTypeJSONLDPositiveSyntax:
+		if test.Algorithm == "toRdf" {
+			return r.runJSONLDToRDFTest(manifest, test)
+		}
+		return r.runJSONLDSerializeTest(manifest, test)
+	case TestTypeJSONLDNegativeEval:
+		return r.runJSONLDNegativeTest(manifest, test)
+	// RDF Dataset Canonicalization tests
+	case TestTypeRDFC10Eval, TestTypeRDFC10Map, TestTypeRDFC10NegativeEval:
+		return r.runRDFCTest(manifest, test)
 	default:
 		// Skip unsupported test types
 		// Common skipped types include:
@@ -257,11 +273,14 @@ func (r *TestRunner) runQueryEvaluationTest(manifest *TestManifest, test *TestCa
 		return TestResultFail
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
 	// Optimize query
 	count, _ := r.store.Count()
 	stats := &optimizer.Statistics{TotalTriples: count}
 	opt := optimizer.NewOptimizer(stats)
-	plan, err := opt.Optimize(query)
+	plan, err := opt.Optimize(ctx, query)
 	if err != nil {
 		r.recordError(test, fmt.Sprintf("Optimizer error: %v", err))
 		return TestResultFail
@@ -269,7 +288,7 @@ func (r *TestRunner) runQueryEvaluationTest(manifest *TestManifest, test *TestCa
 
 	// Execute query
 	exec := executor.NewExecutor(r.store)
-	result, err := exec.Execute(plan)
+	result, err := exec.Execute(ctx, plan)
 	if err != nil {
 		r.recordError(test, fmt.Sprintf("Execution error: %v", err))
 		return TestResultFail
@@ -371,7 +390,7 @@ func (r *TestRunner) clearStore() error {
 		Object:    &store.Variable{Name: "o"},
 		Graph:     &store.Variable{Name: "g"},
 	}
-	iter, err := r.store.Query(pattern)
+	iter, err := r.store.Query(context.Background(), pattern)
 	if err != nil {
 		return err
 	}
@@ -695,11 +714,14 @@ func (r *TestRunner) runResultFormatTest(manifest *TestManifest, test *TestCase,
 		return TestResultFail
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
 	// Optimize query
 	count, _ := r.store.Count()
 	stats := &optimizer.Statistics{TotalTriples: count}
 	opt := optimizer.NewOptimizer(stats)
-	plan, err := opt.Optimize(query)
+	plan, err := opt.Optimize(ctx, query)
 	if err != nil {
 		r.recordError(test, fmt.Sprintf("Optimizer error: %v", err))
 		return TestResultFail
@@ -707,7 +729,7 @@ func (r *TestRunner) runResultFormatTest(manifest *TestManifest, test *TestCase,
 
 	// Execute query
 	exec := executor.NewExecutor(r.store)
-	result, err := exec.Execute(plan)
+	result, err := exec.Execute(ctx, plan)
 	if err != nil {
 		r.recordError(test, fmt.Sprintf("Execution error: %v", err))
 		return TestResultFail
@@ -0,0 +1,170 @@
+// Package access maps principals to read/write permissions on named graphs
+package access
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// Permission is a set of operations allowed on a graph
+type Permission uint8
+
+const (
+	PermissionRead Permission = 1 << iota
+	PermissionWrite
+)
+
+const (
+	// AnyPrincipal is the policy key whose rules apply to every caller, including anonymous ones
+	AnyPrincipal = "*"
+
+	// DefaultGraph is the rule graph name for the default graph
+	DefaultGraph = "default"
+
+	// AllGraphs is the rule graph name matching every graph, including the default graph
+	AllGraphs = "*"
+)
+
+// Rule grants permissions on graphs
+// Graph is an IRI, "default", "*" for all graphs, or an IRI prefix ending in "*"
+type Rule struct {
+	Graph       string   `json:"graph"`
+	Permissions []string `json:"permissions"` // "read" and/or "write"
+}
+
+// Policy maps principals to graph rules
+type Policy struct {
+	Principals map[string][]Rule `json:"principals"`
+}
+
+// LoadPolicy reads a JSON policy file:
+//
+//	{"principals": {"alice": [{"graph": "http://example.org/a/*", "permissions": ["read", "write"]}]}}
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - policy path is provided by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access policy: %w", err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse access policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validate checks that all rules name a graph and known permissions
+func (p *Policy) Validate() error {
+	for principal, rules := range p.Principals {
+		for _, rule := range rules {
+			if rule.Graph == "" {
+				return fmt.Errorf("access policy for %q: rule without graph", principal)
+			}
+			if _, err := parsePermissions(rule.Permissions); err != nil {
+				return fmt.Errorf("access policy for %q: %w", principal, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Authorizer returns the graph authorizer for a principal ("" for anonymous callers)
+func (p *Policy) Authorizer(principal string) store.GraphAuthorizer {
+	authorizer := &graphAuthorizer{}
+	for _, rule := range p.rules(principal) {
+		permissions, _ := parsePermissions(rule.Permissions)
+		authorizer.rules = append(authorizer.rules, compiledRule{graph: rule.Graph, permissions: permissions})
+	}
+	return authorizer
+}
+
+// CanWriteAll reports whether a principal ("" for anonymous callers) may write every graph,
+// including graphs that don't exist yet, through a rule for "*"
+func (p *Policy) CanWriteAll(principal string) bool {
+	for _, rule := range p.rules(principal) {
+		permissions, _ := parsePermissions(rule.Permissions)
+		if rule.Graph == AllGraphs && permissions&PermissionWrite != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// rules returns the rules of a principal and those for every caller
+func (p *Policy) rules(principal string) []Rule {
+	var rules []Rule
+	if principal != "" {
+		rules = append(rules, p.Principals[principal]...)
+	}
+	return append(rules, p.Principals[AnyPrincipal]...)
+}
+
+type compiledRule struct {
+	graph       string
+	permissions Permission
+}
+
+// graphAuthorizer implements store.GraphAuthorizer; permissions from all matching rules add up
+type graphAuthorizer struct {
+	rules []compiledRule
+}
+
+func (a *graphAuthorizer) CanRead(graph rdf.Term) bool {
+	return a.permissions(graph)&PermissionRead != 0
+}
+
+func (a *graphAuthorizer) CanWrite(graph rdf.Term) bool {
+	return a.permissions(graph)&PermissionWrite != 0
+}
+
+func (a *graphAuthorizer) permissions(graph rdf.Term) Permission {
+	var permissions Permission
+	for _, rule := range a.rules {
+		if matchesGraph(rule.graph, graph) {
+			permissions |= rule.permissions
+		}
+	}
+	return permissions
+}
+
+// matchesGraph checks if a rule graph name matches a graph term
+func matchesGraph(ruleGraph string, graph rdf.Term) bool {
+	if ruleGraph == AllGraphs {
+		return true
+	}
+
+	switch g := graph.(type) {
+	case *rdf.DefaultGraph:
+		return ruleGraph == DefaultGraph
+	case *rdf.NamedNode:
+		if prefix, ok := strings.CutSuffix(ruleGraph, "*"); ok {
+			return strings.HasPrefix(g.IRI, prefix)
+		}
+		return g.IRI == ruleGraph
+	default:
+		return false
+	}
+}
+
+func parsePermissions(names []string) (Permission, error) {
+	var permissions Permission
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "read":
+			permissions |= PermissionRead
+		case "write":
+			permissions |= PermissionWrite
+		default:
+			return 0, fmt.Errorf("unknown permission %q", name)
+		}
+	}
+	return permissions, nil
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// APIKeyHeader is the request header carrying a static API key
+const APIKeyHeader = "X-API-Key"
+
+// APIKey is a static key entry in an API key file
+type APIKey struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	Role string `json:"role"` // "read" or "write"
+}
+
+// APIKeyAuthenticator authenticates requests by a static key in the X-API-Key header
+type APIKeyAuthenticator struct {
+	// Keys are indexed by their SHA-256 digest so lookups don't leak key prefixes through timing
+	keys map[[sha256.Size]byte]*Principal
+}
+
+// NewAPIKeyAuthenticator creates an authenticator from static keys
+func NewAPIKeyAuthenticator(keys []APIKey) (*APIKeyAuthenticator, error) {
+	a := &APIKeyAuthenticator{keys: make(map[[sha256.Size]byte]*Principal)}
+	for _, key := range keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("API key for %q is empty", key.Name)
+		}
+		role, err := ParseRole(key.Role)
+		if err != nil {
+			return nil, fmt.Errorf("API key for %q: %w", key.Name, err)
+		}
+		a.keys[sha256.Sum256([]byte(key.Key))] = &Principal{Name: key.Name, Role: role}
+	}
+	return a, nil
+}
+
+// LoadAPIKeys reads a JSON API key file:
+//
+//	{"keys": [{"key": "s3cr3t", "name": "ci", "role": "write"}]}
+func LoadAPIKeys(path string) (*APIKeyAuthenticator, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - key file path is provided by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API key file: %w", err)
+	}
+
+	var file struct {
+		Keys []APIKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse API key file: %w", err)
+	}
+	return NewAPIKeyAuthenticator(file.Keys)
+}
+
+// Authenticate implements Authenticator
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	principal, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+	return principal, nil
+}
@@ -0,0 +1,100 @@
+// Package auth provides pluggable authentication for the HTTP server:
+// static API keys, HTTP Basic against a bcrypt credential file, and JWT bearer tokens
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNoCredentials means the request carries no credentials for an authenticator
+	ErrNoCredentials = errors.New("no credentials")
+
+	// ErrInvalidCredentials means the request carries credentials that were rejected
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Role is the level of access granted to a principal
+type Role int
+
+const (
+	// RoleReadOnly allows queries
+	RoleReadOnly Role = iota + 1
+
+	// RoleReadWrite allows queries, data uploads and dataset administration
+	RoleReadWrite
+)
+
+// ParseRole parses "read" / "read-only" and "write" / "read-write"
+func ParseRole(name string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "read", "read-only", "readonly":
+		return RoleReadOnly, nil
+	case "write", "read-write", "readwrite":
+		return RoleReadWrite, nil
+	default:
+		return 0, fmt.Errorf("unknown role %q", name)
+	}
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleReadOnly:
+		return "read-only"
+	case RoleReadWrite:
+		return "read-write"
+	default:
+		return "none"
+	}
+}
+
+// Allows reports whether the role includes the required role
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+// Principal is an authenticated caller
+type Principal struct {
+	Name string
+	Role Role
+}
+
+// Authenticator identifies the caller of a request
+type Authenticator interface {
+	// Authenticate returns the principal for the request's credentials,
+	// ErrNoCredentials if it carries none this authenticator understands,
+	// or an error wrapping ErrInvalidCredentials if they are rejected
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries authenticators in order, returning the first principal found
+type Chain []Authenticator
+
+// Authenticate implements Authenticator
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range c {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated principal, or nil for anonymous requests
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
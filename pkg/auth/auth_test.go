@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBasicAuthenticator(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	content := "# users\nalice:" + string(hash) + ":write\nbob:" + string(hash) + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := LoadBasicCredentials(path)
+	if err != nil {
+		t.Fatalf("failed to load credentials: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		noAuth   bool
+		wantRole Role
+		wantErr  error
+	}{
+		{name: "read-write user", user: "alice", password: "secret", wantRole: RoleReadWrite},
+		{name: "user without a role", user: "bob", password: "secret", wantRole: RoleReadOnly},
+		{name: "wrong password", user: "alice", password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "unknown user", user: "mallory", password: "secret", wantErr: ErrInvalidCredentials},
+		{name: "no credentials", noAuth: true, wantErr: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/sparql", nil)
+			if !tt.noAuth {
+				request.SetBasicAuth(tt.user, tt.password)
+			}
+			principal, err := authenticator.Authenticate(request)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || principal.Name != tt.user || principal.Role != tt.wantRole {
+				t.Errorf("expected %s with role %s, got %+v (%v)", tt.user, tt.wantRole, principal, err)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	authenticator, err := NewAPIKeyAuthenticator([]APIKey{
+		{Key: "ci-key", Name: "ci", Role: "write"},
+		{Key: "dashboard-key", Name: "dashboard", Role: "read"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		wantName string
+		wantErr  error
+	}{
+		{name: "write key", key: "ci-key", wantName: "ci"},
+		{name: "read key", key: "dashboard-key", wantName: "dashboard"},
+		{name: "unknown key", key: "ci-key-2", wantErr: ErrInvalidCredentials},
+		{name: "no key", wantErr: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/sparql", nil)
+			if tt.key != "" {
+				request.Header.Set(APIKeyHeader, tt.key)
+			}
+			principal, err := authenticator.Authenticate(request)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || principal.Name != tt.wantName {
+				t.Errorf("expected %s, got %+v (%v)", tt.wantName, principal, err)
+			}
+		})
+	}
+
+	if _, err := NewAPIKeyAuthenticator([]APIKey{{Key: "", Name: "empty", Role: "read"}}); err == nil {
+		t.Error("expected an error for an empty key")
+	}
+	if _, err := NewAPIKeyAuthenticator([]APIKey{{Key: "k", Name: "admin", Role: "admin"}}); err == nil {
+		t.Error("expected an error for an unknown role")
+	}
+}
+
+func TestChain(t *testing.T) {
+	apiKeys, err := NewAPIKeyAuthenticator([]APIKey{{Key: "ci-key", Name: "ci", Role: "write"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("0123456789abcdef")
+	jwt, err := NewJWTVerifier(secret, JWTOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := Chain{apiKeys, jwt}
+	alice := map[string]any{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		bearer   string
+		wantName string
+		wantErr  error
+	}{
+		// An authenticator without credentials falls through to the next one
+		{name: "API key", apiKey: "ci-key", wantName: "ci"},
+		{name: "falls through to JWT", bearer: signToken(t, "HS256", secret, alice), wantName: "alice"},
+		// Rejected credentials stop the chain instead of falling through
+		{name: "rejected API key with a valid JWT", apiKey: "wrong",
+			bearer: signToken(t, "HS256", secret, alice), wantErr: ErrInvalidCredentials},
+		{name: "rejected JWT", bearer: signToken(t, "HS256", []byte("wrong"), alice),
+			wantErr: ErrInvalidCredentials},
+		{name: "no credentials", wantErr: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/sparql", nil)
+			if tt.apiKey != "" {
+				request.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if tt.bearer != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			principal, err := chain.Authenticate(request)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || principal.Name != tt.wantName {
+				t.Errorf("expected %s, got %+v (%v)", tt.wantName, principal, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basicUser is an entry of a bcrypt credential file
+type basicUser struct {
+	hash []byte
+	role Role
+}
+
+// BasicAuthenticator authenticates HTTP Basic credentials against bcrypt hashes
+type BasicAuthenticator struct {
+	users map[string]basicUser
+	// dummyHash is compared for unknown users so they take as long as known ones
+	dummyHash []byte
+}
+
+// LoadBasicCredentials reads an htpasswd-style bcrypt credential file with an optional role column:
+//
+//	# user:bcrypt-hash[:role]
+//	alice:$2y$10$...:write
+//	bob:$2y$10$...
+//
+// Users without a role are read-only. Hashes can be created with `htpasswd -nB user`.
+func LoadBasicCredentials(path string) (*BasicAuthenticator, error) {
+	file, err := os.Open(path) // #nosec G304 - credential file path is provided by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credential file: %w", err)
+	}
+	defer file.Close()
+
+	a := &BasicAuthenticator{users: make(map[string]basicUser)}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("credential file line %d: expected user:hash[:role]", lineNum)
+		}
+		if _, err := bcrypt.Cost([]byte(parts[1])); err != nil {
+			return nil, fmt.Errorf("credential file line %d: invalid bcrypt hash: %w", lineNum, err)
+		}
+
+		role := RoleReadOnly
+		if len(parts) == 3 {
+			if role, err = ParseRole(parts[2]); err != nil {
+				return nil, fmt.Errorf("credential file line %d: %w", lineNum, err)
+			}
+		}
+		a.users[parts[0]] = basicUser{hash: []byte(parts[1]), role: role}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read credential file: %w", err)
+	}
+
+	a.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	return a, nil
+}
+
+// Authenticate implements Authenticator
+func (a *BasicAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	user, known := a.users[username]
+	if !known {
+		_ = bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password)) // #nosec G104 - only equalizes timing
+		return nil, fmt.Errorf("%w: unknown user", ErrInvalidCredentials)
+	}
+	if err := bcrypt.CompareHashAndPassword(user.hash, []byte(password)); err != nil {
+		return nil, fmt.Errorf("%w: wrong password", ErrInvalidCredentials)
+	}
+
+	return &Principal{Name: username, Role: user.role}, nil
+}
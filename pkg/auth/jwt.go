@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWTOptions configures the claims checked by the JWT verifier
+type JWTOptions struct {
+	Issuer   string // required "iss" value, if set
+	Audience string // required "aud" value, if set
+
+	// RoleClaim names the claim holding the role ("read" or "write"), "role" by default
+	RoleClaim string
+
+	// Leeway tolerates clock skew when checking "exp" and "nbf"
+	Leeway time.Duration
+
+	// AllowMissingExpiry accepts tokens without an "exp" claim, which never expire
+	AllowMissingExpiry bool
+}
+
+// JWTVerifier authenticates "Authorization: Bearer <jwt>" tokens signed with a locally configured key.
+// HMAC secrets verify HS256/384/512; RSA, ECDSA and Ed25519 public keys verify RS*, ES* and EdDSA.
+type JWTVerifier struct {
+	key     any // []byte, *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+	options JWTOptions
+	now     func() time.Time
+}
+
+// NewJWTVerifier creates a verifier for a key
+func NewJWTVerifier(key any, options JWTOptions) (*JWTVerifier, error) {
+	switch k := key.(type) {
+	case []byte:
+		if len(k) == 0 {
+			return nil, fmt.Errorf("JWT secret is empty")
+		}
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported JWT key type %T", key)
+	}
+	if options.RoleClaim == "" {
+		options.RoleClaim = "role"
+	}
+	return &JWTVerifier{key: key, options: options, now: time.Now}, nil
+}
+
+// LoadJWTKey reads a PEM public key (or certificate), or otherwise an HMAC secret
+func LoadJWTKey(path string) (any, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - key file path is provided by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return bytes.TrimSpace(data), nil
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT public key: %w", err)
+		}
+		return key, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT public key: %w", err)
+		}
+		return key, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT certificate: %w", err)
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q for JWT key", block.Type)
+	}
+}
+
+// Authenticate implements Authenticator
+func (v *JWTVerifier) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := v.Verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	roleName, _ := claims[v.options.RoleClaim].(string)
+	role := RoleReadOnly
+	if roleName != "" {
+		if role, err = ParseRole(roleName); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+	}
+
+	return &Principal{Name: subject, Role: role}, nil
+}
+
+// Verify checks a compact JWS token's signature and registered claims, returning its claims
+func (v *JWTVerifier) Verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+	if err := v.verifySignature(header.Alg, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ecdsaCurves is the curve each ES algorithm requires (RFC 7518, section 3.4)
+var ecdsaCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// verifySignature checks the signature with the configured key; the algorithm must match the key type
+func (v *JWTVerifier) verifySignature(alg string, signed, signature []byte) error {
+	hashes := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+
+	if alg == "EdDSA" {
+		key, ok := v.key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(key, signed, signature) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	hash, ok := hashes[alg[2:]]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "HS":
+		secret, ok := v.key.([]byte)
+		if !ok {
+			return fmt.Errorf("algorithm %s does not match the configured key", alg)
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+
+	case "RS":
+		key, ok := v.key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s does not match the configured key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+
+	case "ES":
+		key, ok := v.key.(*ecdsa.PublicKey)
+		if !ok || key.Curve != ecdsaCurves[alg] {
+			return fmt.Errorf("algorithm %s does not match the configured key", alg)
+		}
+		// JWS ECDSA signatures are the fixed-size concatenation r || s
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+
+	default:
+		// "none" and anything unknown are rejected
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+// checkClaims validates exp, nbf, iss and aud
+func (v *JWTVerifier) checkClaims(claims map[string]any) error {
+	now := v.now()
+
+	// A time claim that is present but not a number would otherwise skip its check
+	value, present := claims["exp"]
+	if !present && !v.options.AllowMissingExpiry {
+		return fmt.Errorf("token has no exp claim")
+	}
+	if present {
+		exp, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("exp claim is not a number")
+		}
+		if now.After(time.Unix(int64(exp), 0).Add(v.options.Leeway)) {
+			return fmt.Errorf("token expired")
+		}
+	}
+	if value, present := claims["nbf"]; present {
+		nbf, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("nbf claim is not a number")
+		}
+		if now.Add(v.options.Leeway).Before(time.Unix(int64(nbf), 0)) {
+			return fmt.Errorf("token not valid yet")
+		}
+	}
+
+	if v.options.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.options.Issuer {
+			return fmt.Errorf("unexpected issuer")
+		}
+	}
+
+	if v.options.Audience != "" {
+		matched := false
+		switch aud := claims["aud"].(type) {
+		case string:
+			matched = aud == v.options.Audience
+		case []any:
+			for _, a := range aud {
+				if s, ok := a.(string); ok && s == v.options.Audience {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return fmt.Errorf("unexpected audience")
+		}
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
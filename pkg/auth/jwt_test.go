@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// signToken builds a compact JWS token with the given header algorithm and claims
+func signToken(t *testing.T, alg string, key any, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hashes := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+	var signature []byte
+	switch k := key.(type) {
+	case nil:
+	case []byte:
+		mac := hmac.New(hashes[alg[2:]].New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		h := hashes[alg[2:]].New()
+		h.Write([]byte(signed))
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, hashes[alg[2:]], h.Sum(nil)); err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+	case *ecdsa.PrivateKey:
+		h := hashes[alg[2:]].New()
+		h.Write([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, k, h.Sum(nil))
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	default:
+		t.Fatalf("unsupported signing key %T", key)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTVerifier(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublicPEM, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublicPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPublicPEM})
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func(extra map[string]any) map[string]any {
+		claims := map[string]any{"sub": "alice", "role": "write", "iss": "trigo", "aud": []string{"api"},
+			"exp": now.Add(time.Hour).Unix(), "nbf": now.Add(-time.Hour).Unix()}
+		for name, value := range extra {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+	options := JWTOptions{Issuer: "trigo", Audience: "api"}
+
+	tests := []struct {
+		name     string
+		key      any // verifier key
+		alg      string
+		signWith any
+		claims   map[string]any
+		wantErr  bool
+	}{
+		{"HS256", secret, "HS256", secret, valid(nil), false},
+		{"RS256", &rsaKey.PublicKey, "RS256", rsaKey, valid(nil), false},
+		{"ES256", &p256Key.PublicKey, "ES256", p256Key, valid(nil), false},
+		{"ES384", &p384Key.PublicKey, "ES384", p384Key, valid(nil), false},
+		{"alg none", secret, "none", nil, valid(nil), true},
+		{"HS256 signed with the RSA public key", &rsaKey.PublicKey, "HS256", rsaPublicPEM, valid(nil), true},
+		{"wrong secret", secret, "HS256", []byte("another secret"), valid(nil), true},
+		{"P-384 key used for ES256", &p384Key.PublicKey, "ES256", p384Key, valid(nil), true},
+		{"P-256 key used for ES384", &p256Key.PublicKey, "ES384", p256Key, valid(nil), true},
+		{"expired", secret, "HS256", secret, valid(map[string]any{"exp": now.Add(-time.Minute).Unix()}), true},
+		{"nbf in the future", secret, "HS256", secret, valid(map[string]any{"nbf": now.Add(time.Minute).Unix()}), true},
+		{"exp as a string", secret, "HS256", secret, valid(map[string]any{"exp": "1700000000"}), true},
+		{"nbf as a string", secret, "HS256", secret, valid(map[string]any{"nbf": "0"}), true},
+		{"without nbf", secret, "HS256", secret, valid(map[string]any{"nbf": nil}), false},
+		{"without exp", secret, "HS256", secret, valid(map[string]any{"exp": nil}), true},
+		{"wrong issuer", secret, "HS256", secret, valid(map[string]any{"iss": "other"}), true},
+		{"missing issuer", secret, "HS256", secret, valid(map[string]any{"iss": nil}), true},
+		{"wrong audience", secret, "HS256", secret, valid(map[string]any{"aud": "other"}), true},
+		{"audience as a string", secret, "HS256", secret, valid(map[string]any{"aud": "api"}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, err := NewJWTVerifier(tt.key, options)
+			if err != nil {
+				t.Fatalf("failed to create verifier: %v", err)
+			}
+			verifier.now = func() time.Time { return now }
+
+			request := httptest.NewRequest(http.MethodGet, "/sparql", nil)
+			request.Header.Set("Authorization", "Bearer "+signToken(t, tt.alg, tt.signWith, tt.claims))
+			principal, err := verifier.Authenticate(request)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Errorf("expected ErrInvalidCredentials, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected the token to verify, got %v", err)
+			}
+			if principal.Name != "alice" || principal.Role != RoleReadWrite {
+				t.Errorf("unexpected principal %+v", principal)
+			}
+		})
+	}
+}
+
+func TestJWTVerifierAllowMissingExpiry(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	token := signToken(t, "HS256", secret, map[string]any{"sub": "alice"})
+
+	for _, allow := range []bool{false, true} {
+		verifier, err := NewJWTVerifier(secret, JWTOptions{AllowMissingExpiry: allow})
+		if err != nil {
+			t.Fatalf("failed to create verifier: %v", err)
+		}
+		request := httptest.NewRequest(http.MethodGet, "/sparql", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		_, err = verifier.Authenticate(request)
+		if allow && err != nil {
+			t.Errorf("expected a token without exp to verify when allowed, got %v", err)
+		}
+		if !allow && !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("expected a token without exp to be rejected, got %v", err)
+		}
+	}
+}
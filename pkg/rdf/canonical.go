@@ -36,23 +36,30 @@ func SerializeQuadsCanonical(quads []*Quad) string {
 
 	var builder strings.Builder
 	for _, quad := range quads {
-		builder.WriteString(serializeTermCanonical(quad.Subject))
-		builder.WriteString(" ")
-		builder.WriteString(serializeTermCanonical(quad.Predicate))
-		builder.WriteString(" ")
-		builder.WriteString(serializeTermCanonical(quad.Object))
+		builder.WriteString(serializeQuadCanonical(quad))
+	}
 
-		// Add graph if not default graph
-		if quad.Graph != nil {
-			if _, isDefault := quad.Graph.(*DefaultGraph); !isDefault {
-				builder.WriteString(" ")
-				builder.WriteString(serializeTermCanonical(quad.Graph))
-			}
-		}
+	return builder.String()
+}
 
-		builder.WriteString(" .\n")
+// serializeQuadCanonical serializes a single quad as a canonical N-Quads line
+func serializeQuadCanonical(quad *Quad) string {
+	var builder strings.Builder
+	builder.WriteString(serializeTermCanonical(quad.Subject))
+	builder.WriteString(" ")
+	builder.WriteString(serializeTermCanonical(quad.Predicate))
+	builder.WriteString(" ")
+	builder.WriteString(serializeTermCanonical(quad.Object))
+
+	// Add graph if not default graph
+	if quad.Graph != nil {
+		if _, isDefault := quad.Graph.(*DefaultGraph); !isDefault {
+			builder.WriteString(" ")
+			builder.WriteString(serializeTermCanonical(quad.Graph))
+		}
 	}
 
+	builder.WriteString(" .\n")
 	return builder.String()
 }
 
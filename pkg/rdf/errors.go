@@ -0,0 +1,122 @@
+package rdf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError is a syntax error in an RDF document or SPARQL query, with the position it was
+// found at and, when the parser says, what it expected there
+type ParseError struct {
+	Line     int    // Line of the error, from 1
+	Column   int    // Column of the error in characters, from 1
+	Offset   int    // Byte offset of the error from the start of the input
+	Token    string // The text at the error, empty at the end of the input
+	Expected string // What the parser expected, empty when it doesn't say
+	Err      error  // The parser's error
+}
+
+// maxTokenLength is the number of bytes of the offending text a ParseError keeps
+const maxTokenLength = 32
+
+// NewParseError creates a ParseError for an error found at a byte offset of the input. What
+// was expected is taken from errors of the form "expected X at ...". An error that already
+// holds a ParseError is returned as it is.
+func NewParseError(input string, offset int, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return err
+	}
+	offset = max(0, min(offset, len(input)))
+	line, column := textPosition(input[:offset])
+	return &ParseError{
+		Line:     line,
+		Column:   column,
+		Offset:   offset,
+		Token:    tokenAt(input[offset:]),
+		Expected: expectedIn(err.Error()),
+		Err:      err,
+	}
+}
+
+func (e *ParseError) Error() string {
+	message := fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+	if e.Token != "" {
+		message += fmt.Sprintf(" (found %q)", e.Token)
+	}
+	return message
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// shift moves an error found in a piece of a document to its position in the document,
+// given the offset, line and column the piece starts at
+func (e *ParseError) shift(offset, line, column int) {
+	if e.Line == 1 {
+		e.Column += column - 1
+	}
+	e.Line += line - 1
+	e.Offset += offset
+}
+
+// shiftParseError shifts the ParseError in err, if there is one, to a piece of a document
+// starting at a byte offset of the input
+func shiftParseError(err error, input string, offset int) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		line, column := textPosition(input[:offset])
+		parseErr.shift(offset, line, column)
+	}
+	return err
+}
+
+// textPosition returns the line and column just after text
+func textPosition(text string) (int, int) {
+	line := strings.Count(text, "\n") + 1
+	lineStart := strings.LastIndexByte(text, '\n') + 1
+	return line, utf8.RuneCountInString(text[lineStart:]) + 1
+}
+
+// tokenAt returns the text up to the first whitespace, shortened to maxTokenLength bytes
+func tokenAt(text string) string {
+	if end := strings.IndexAny(text, " \t\r\n"); end != -1 {
+		text = text[:end]
+	}
+	if len(text) > maxTokenLength {
+		end := maxTokenLength
+		for end > 0 && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		text = text[:end]
+	}
+	return text
+}
+
+// expectedIn returns X from an error message containing "expected X at ...", "expected X after
+// ..." and the like, or "" if the message doesn't say what was expected
+func expectedIn(message string) string {
+	idx := 0
+	for {
+		next := strings.Index(message[idx:], "expected ")
+		if next == -1 {
+			return ""
+		}
+		idx += next
+		// Skip words such as "unexpected"
+		if idx == 0 || message[idx-1] == ' ' {
+			break
+		}
+		idx++
+	}
+	expected := message[idx+len("expected "):]
+	for _, separator := range []string{" at ", " after ", " before ", " in ", " to ", " for ", ", ", ": "} {
+		if end := strings.Index(expected, separator); end != -1 {
+			expected = expected[:end]
+		}
+	}
+	return expected
+}
@@ -0,0 +1,110 @@
+package rdf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// assertParseError checks that err holds a ParseError at a line and column
+func assertParseError(t *testing.T, err error, line, column int, token, expected string) *ParseError {
+	t.Helper()
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected a ParseError, got %v", err)
+	}
+	if parseErr.Line != line || parseErr.Column != column || parseErr.Token != token || parseErr.Expected != expected {
+		t.Errorf("Expected line %d, column %d, token %q, expected %q; got line %d, column %d, token %q, expected %q (%v)",
+			line, column, token, expected, parseErr.Line, parseErr.Column, parseErr.Token, parseErr.Expected, err)
+	}
+	return parseErr
+}
+
+func TestParseError_Turtle(t *testing.T) {
+	input := "@prefix ex: <http://example.org/> .\nex:a ex:b ex:c ;\n  ex:d \"é\" ex:e .\n"
+	_, err := NewTurtleParser(input).Parse()
+	parseErr := assertParseError(t, err, 3, 12, "ex:e", "'.'")
+	if input[parseErr.Offset:parseErr.Offset+4] != "ex:e" {
+		t.Errorf("Offset %d doesn't point at the token", parseErr.Offset)
+	}
+
+	// Errors in a graph block are reported at their position in the document
+	_, err = NewTriGParser("<http://example.org/g> {\n  <http://example.org/s> <http://example.org/p> .\n}").Parse()
+	assertParseError(t, err, 2, 49, ".", "")
+
+	// Streamed statements are located in the whole document
+	err = (&TurtleIOParser{}).ParseStream(strings.NewReader(input), func(*Quad) error { return nil })
+	assertParseError(t, err, 3, 12, "ex:e", "'.'")
+	err = (&TriGIOParser{}).ParseStream(strings.NewReader("<http://example.org/g> {\n  <http://example.org/s> <http://example.org/p> .\n}"),
+		func(*Quad) error { return nil })
+	assertParseError(t, err, 2, 49, ".", "")
+}
+
+func TestParseError_NQuads(t *testing.T) {
+	input := "<http://example.org/s> <http://example.org/p> <http://example.org/o> .\n<http://example.org/s> <http://example.org/p> \"x\" <http://example.org/g> <http://example.org/h> .\n"
+	_, err := NewNQuadsParser(input).Parse()
+	assertParseError(t, err, 2, 74, "<http://example.org/h>", "'.'")
+
+	err = (&NQuadsIOParser{}).ParseStream(strings.NewReader(input), func(*Quad) error { return nil })
+	assertParseError(t, err, 2, 74, "<http://example.org/h>", "'.'")
+}
+
+func TestParseError_RDFXML(t *testing.T) {
+	_, err := NewRDFXMLParser().Parse(strings.NewReader(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="http://example.org/s">
+    <rdf:Description/>
+  </rdf:Description>
+</rdf:RDF>`))
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 {
+		t.Errorf("Expected a ParseError on line 3, got %v", err)
+	}
+}
+
+func TestLenientParsing(t *testing.T) {
+	input := `<http://example.org/a> <http://example.org/p> "one" .
+<http://example.org/b> <http://example.org/p> "unterminated .
+<http://example.org/c> <http://example.org/p> <http://example.org/o> <http://example.org/g> .
+<http://example.org/d> <http://example.org/p> "four" .
+`
+	// N-Triples skips the bad string and the quad
+	ntriples := &NTriplesIOParser{}
+	ntriples.SetLenient(true)
+	for _, stream := range []bool{false, true} {
+		quads := parseWith(t, ntriples, input, stream)
+		if len(quads) != 2 || len(ntriples.Errors()) != 2 {
+			t.Fatalf("Expected 2 quads and 2 errors, got %d and %v", len(quads), ntriples.Errors())
+		}
+		if ntriples.Errors()[0].Line != 2 || ntriples.Errors()[1].Line != 3 {
+			t.Errorf("Errors are on the wrong lines: %v", ntriples.Errors())
+		}
+	}
+
+	// N-Quads only skips the bad string
+	nquads := &NQuadsIOParser{}
+	nquads.SetLenient(true)
+	for _, stream := range []bool{false, true} {
+		quads := parseWith(t, nquads, input, stream)
+		if len(quads) != 3 || len(nquads.Errors()) != 1 || nquads.Errors()[0].Line != 2 {
+			t.Fatalf("Expected 3 quads and an error on line 2, got %d and %v", len(quads), nquads.Errors())
+		}
+	}
+
+	// Without lenient mode the first error fails the parse
+	if _, err := (&NQuadsIOParser{}).Parse(strings.NewReader(input)); err == nil {
+		t.Error("Expected a parse error")
+	}
+}
+
+// parseWith parses input with Parse or ParseStream
+func parseWith(t *testing.T, parser StreamParser, input string, stream bool) []*Quad {
+	t.Helper()
+	if stream {
+		return streamQuads(t, parser, input)
+	}
+	quads, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return quads
+}
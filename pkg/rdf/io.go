@@ -1,9 +1,9 @@
 package rdf
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"strings"
 )
 
 // RDFParser is the interface for parsing RDF data in various formats
@@ -15,15 +15,21 @@ type RDFParser interface {
 	ContentType() string
 }
 
+// LenientParser is an RDFParser that can skip statements with syntax errors instead of
+// failing, for loading line-based dumps where a bad line shouldn't abort the load
+type LenientParser interface {
+	RDFParser
+
+	// SetLenient turns skipping bad statements on or off
+	SetLenient(lenient bool)
+
+	// Errors returns the syntax errors of the statements skipped by the last parse
+	Errors() []*ParseError
+}
+
 // NewParser creates an RDF parser based on the content type
 func NewParser(contentType string) (RDFParser, error) {
-	// Normalize content type (remove parameters like charset)
-	ct := strings.ToLower(strings.TrimSpace(contentType))
-	if idx := strings.Index(ct, ";"); idx != -1 {
-		ct = strings.TrimSpace(ct[:idx])
-	}
-
-	switch ct {
+	switch normalizeContentType(contentType) {
 	case "application/n-triples", "text/plain":
 		return &NTriplesIOParser{}, nil
 	case "application/n-quads":
@@ -42,7 +48,20 @@ func NewParser(contentType string) (RDFParser, error) {
 }
 
 // NTriplesIOParser parses N-Triples format (triples only, default graph)
-type NTriplesIOParser struct{}
+type NTriplesIOParser struct {
+	lenient bool
+	errors  []*ParseError
+}
+
+// SetLenient makes the parser skip lines with syntax errors
+func (p *NTriplesIOParser) SetLenient(lenient bool) {
+	p.lenient = lenient
+}
+
+// Errors returns the syntax errors of the lines skipped by the last parse
+func (p *NTriplesIOParser) Errors() []*ParseError {
+	return p.errors
+}
 
 func (p *NTriplesIOParser) ContentType() string {
 	return "application/n-triples"
@@ -57,7 +76,9 @@ func (p *NTriplesIOParser) Parse(reader io.Reader) ([]*Quad, error) {
 
 	// Use turtle parser (which handles N-Triples as a subset)
 	turtleParser := NewTurtleParser(string(data))
+	turtleParser.SetLenient(p.lenient)
 	triples, err := turtleParser.Parse()
+	p.errors = turtleParser.Errors()
 	if err != nil {
 		return nil, fmt.Errorf("error parsing N-Triples: %w", err)
 	}
@@ -76,8 +97,30 @@ func (p *NTriplesIOParser) Parse(reader io.Reader) ([]*Quad, error) {
 	return quads, nil
 }
 
+// ParseStream parses N-Triples one statement at a time
+func (p *NTriplesIOParser) ParseStream(reader io.Reader, handler QuadHandler) error {
+	parser := NewTurtleParser("")
+	parser.SetLenient(p.lenient)
+	err := parseTurtleStream(parser, reader, handler, "N-Triples")
+	p.errors = parser.Errors()
+	return err
+}
+
 // NQuadsIOParser parses N-Quads format (quads with optional graph)
-type NQuadsIOParser struct{}
+type NQuadsIOParser struct {
+	lenient bool
+	errors  []*ParseError
+}
+
+// SetLenient makes the parser skip lines with syntax errors
+func (p *NQuadsIOParser) SetLenient(lenient bool) {
+	p.lenient = lenient
+}
+
+// Errors returns the syntax errors of the lines skipped by the last parse
+func (p *NQuadsIOParser) Errors() []*ParseError {
+	return p.errors
+}
 
 func (p *NQuadsIOParser) ContentType() string {
 	return "application/n-quads"
@@ -92,7 +135,9 @@ func (p *NQuadsIOParser) Parse(reader io.Reader) ([]*Quad, error) {
 
 	// Use N-Quads parser
 	nquadsParser := NewNQuadsParser(string(data))
+	nquadsParser.SetLenient(p.lenient)
 	quads, err := nquadsParser.Parse()
+	p.errors = nquadsParser.Errors()
 	if err != nil {
 		return nil, fmt.Errorf("error parsing N-Quads: %w", err)
 	}
@@ -100,6 +145,15 @@ func (p *NQuadsIOParser) Parse(reader io.Reader) ([]*Quad, error) {
 	return quads, nil
 }
 
+// ParseStream parses N-Quads one line at a time
+func (p *NQuadsIOParser) ParseStream(reader io.Reader, handler QuadHandler) error {
+	parser := NewNQuadsParser("")
+	parser.SetLenient(p.lenient)
+	err := parseNQuadsStream(parser, reader, handler)
+	p.errors = parser.Errors()
+	return err
+}
+
 // TurtleIOParser parses Turtle format (triples with prefixes, default graph)
 type TurtleIOParser struct{}
 
@@ -135,6 +189,11 @@ func (p *TurtleIOParser) Parse(reader io.Reader) ([]*Quad, error) {
 	return quads, nil
 }
 
+// ParseStream parses Turtle one statement at a time
+func (p *TurtleIOParser) ParseStream(reader io.Reader, handler QuadHandler) error {
+	return parseTurtleStream(NewTurtleParser(""), reader, handler, "Turtle")
+}
+
 // TriGIOParser parses TriG format (Turtle + named graphs, quads)
 type TriGIOParser struct{}
 
@@ -159,6 +218,11 @@ func (p *TriGIOParser) Parse(reader io.Reader) ([]*Quad, error) {
 	return quads, nil
 }
 
+// ParseStream parses TriG one statement at a time, including the statements of graph blocks
+func (p *TriGIOParser) ParseStream(reader io.Reader, handler QuadHandler) error {
+	return parseTriGStream(NewTriGParser(""), reader, handler)
+}
+
 // RDFXMLIOParser parses RDF/XML format (triples, default graph)
 type RDFXMLIOParser struct{}
 
@@ -177,6 +241,17 @@ func (p *RDFXMLIOParser) Parse(reader io.Reader) ([]*Quad, error) {
 	return quads, nil
 }
 
+// ParseStream parses RDF/XML as its elements are read, handing over the quads of each
+// element when it ends
+func (p *RDFXMLIOParser) ParseStream(reader io.Reader, handler QuadHandler) error {
+	err := NewRDFXMLParser().ParseStream(reader, handler)
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("error parsing RDF/XML: %w", err)
+	}
+	return err
+}
+
 // JSONLDIOParser parses JSON-LD format (triples, default graph)
 type JSONLDIOParser struct{}
 
@@ -2,7 +2,11 @@ package rdf
 
 import (
 	"fmt"
+	"hash/fnv"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 // AreGraphsIsomorphic checks if two sets of triples are isomorphic,
@@ -10,285 +14,499 @@ import (
 // Two graphs are isomorphic if there exists a bijection between their
 // blank nodes such that when applied, the graphs are identical.
 func AreGraphsIsomorphic(expected, actual []*Triple) bool {
-	// Quick check: same number of triples
-	if len(expected) != len(actual) {
-		return false
-	}
+	_, isomorphic := FindGraphsIsomorphism(expected, actual)
+	return isomorphic
+}
 
-	// Extract blank nodes from both graphs
-	expectedBlanks := extractBlankNodeLabels(expected)
-	actualBlanks := extractBlankNodeLabels(actual)
+// FindGraphsIsomorphism returns a bijection from the blank node labels of expected to those
+// of actual that makes the two graphs identical, or false if there is none
+func FindGraphsIsomorphism(expected, actual []*Triple) (map[string]string, bool) {
+	return FindQuadsIsomorphism(triplesToQuads(expected), triplesToQuads(actual))
+}
 
-	// Quick check: same number of blank nodes
-	if len(expectedBlanks) != len(actualBlanks) {
-		return false
-	}
+// AreQuadsIsomorphic checks if two sets of quads are isomorphic,
+// accounting for blank node label differences in both triples and graph names.
+func AreQuadsIsomorphic(expected, actual []*Quad) bool {
+	_, isomorphic := FindQuadsIsomorphism(expected, actual)
+	return isomorphic
+}
 
-	// If no blank nodes, use simple comparison
-	if len(expectedBlanks) == 0 {
-		return simpleCompare(expected, actual)
+// FindQuadsIsomorphism returns a bijection from the blank node labels of expected to those
+// of actual, including blank graph names, that makes the two datasets identical, or false if
+// there is none.
+//
+// Blank nodes are first partitioned by colour refinement, so that only nodes of the same
+// colour are tried against each other. The datasets are then split into the connected
+// components of their blank nodes, and each component of expected is paired with a component
+// of actual with the same colours. A pair is matched by individualisation-refinement: a node
+// of the smallest colour class that isn't a single node is tried against each node of that
+// class in the other component, giving both a new colour and refining again, until every
+// node has a colour of its own.
+func FindQuadsIsomorphism(expected, actual []*Quad) (map[string]string, bool) {
+	// Quick check: same number of quads
+	if len(expected) != len(actual) {
+		return nil, false
 	}
 
-	// Sort blank nodes by degree (optimization: match high-degree nodes first)
-	expectedBlanks = sortByDegree(expectedBlanks, expected)
-	actualBlanks = sortByDegree(actualBlanks, actual)
+	source := newIsomorphismGraph(expected)
+	target := newIsomorphismGraph(actual)
 
-	// Find isomorphic mapping via backtracking
-	mapping := make(map[string]string)
-	usedTargets := make(map[string]bool)
-	return backtrack(expected, actual, expectedBlanks, actualBlanks, mapping, usedTargets, 0)
-}
+	// Quick check: same number of distinct quads and blank nodes
+	if len(source.quads) != len(target.quads) || len(source.blanks) != len(target.blanks) {
+		return nil, false
+	}
 
-// extractBlankNodeLabels extracts all unique blank node labels from a set of triples
-func extractBlankNodeLabels(triples []*Triple) []string {
-	blanks := make(map[string]bool)
-	for _, triple := range triples {
-		// Extract from subject
-		extractBlanksFromTerm(triple.Subject, blanks)
-		// Extract from object
-		extractBlanksFromTerm(triple.Object, blanks)
+	// Quads without blank nodes must be in both
+	for i, quad := range source.quads {
+		if len(source.quadBlanks[i]) == 0 && !target.keys[quadKey(quad, nil)] {
+			return nil, false
+		}
+	}
+	if len(source.blanks) == 0 {
+		return map[string]string{}, true
 	}
 
-	// Convert to sorted slice for deterministic ordering
-	result := make([]string, 0, len(blanks))
-	for label := range blanks {
-		result = append(result, label)
+	if !refineColours(source, target) {
+		return nil, false
 	}
-	sort.Strings(result)
-	return result
-}
 
-// extractBlanksFromTerm recursively extracts blank nodes from a term,
-// including those inside TripleTerms, QuotedTriples, and ReifiedTriples
-func extractBlanksFromTerm(term Term, blanks map[string]bool) {
-	switch t := term.(type) {
-	case *BlankNode:
-		blanks[t.ID] = true
-	case *TripleTerm:
-		// Recursively extract from triple term components
-		extractBlanksFromTerm(t.Subject, blanks)
-		extractBlanksFromTerm(t.Predicate, blanks)
-		extractBlanksFromTerm(t.Object, blanks)
-	case *QuotedTriple:
-		// Recursively extract from quoted triple components
-		extractBlanksFromTerm(t.Subject, blanks)
-		extractBlanksFromTerm(t.Predicate, blanks)
-		extractBlanksFromTerm(t.Object, blanks)
-	case *ReifiedTriple:
-		// Extract from identifier and the underlying triple
-		extractBlanksFromTerm(t.Identifier, blanks)
-		if t.Triple != nil {
-			extractBlanksFromTerm(t.Triple.Subject, blanks)
-			extractBlanksFromTerm(t.Triple.Predicate, blanks)
-			extractBlanksFromTerm(t.Triple.Object, blanks)
-		}
+	// Pair the components, trying those of actual with the same colours in turn; any
+	// isomorphic partner will do, as isomorphism is an equivalence
+	sourceComponents, targetComponents := source.components(), target.components()
+	if len(sourceComponents) != len(targetComponents) {
+		return nil, false
+	}
+	candidates := make(map[string][]*isomorphismGraph)
+	for _, component := range targetComponents {
+		candidates[component.invariant] = append(candidates[component.invariant], component.graph)
 	}
-}
 
-// countBlanksInTerm recursively counts occurrences of blank nodes in a term,
-// including those inside TripleTerms, QuotedTriples, and ReifiedTriples
-func countBlanksInTerm(term Term, degrees map[string]int) {
-	switch t := term.(type) {
-	case *BlankNode:
-		degrees[t.ID]++
-	case *TripleTerm:
-		// Recursively count in triple term components
-		countBlanksInTerm(t.Subject, degrees)
-		countBlanksInTerm(t.Predicate, degrees)
-		countBlanksInTerm(t.Object, degrees)
-	case *QuotedTriple:
-		// Recursively count in quoted triple components
-		countBlanksInTerm(t.Subject, degrees)
-		countBlanksInTerm(t.Predicate, degrees)
-		countBlanksInTerm(t.Object, degrees)
-	case *ReifiedTriple:
-		// Count in identifier and the underlying triple
-		countBlanksInTerm(t.Identifier, degrees)
-		if t.Triple != nil {
-			countBlanksInTerm(t.Triple.Subject, degrees)
-			countBlanksInTerm(t.Triple.Predicate, degrees)
-			countBlanksInTerm(t.Triple.Object, degrees)
+	mapping := make(map[string]string, len(source.blanks))
+	for _, component := range sourceComponents {
+		partners := candidates[component.invariant]
+		matched := false
+		for i, partner := range partners {
+			if partner == nil {
+				continue
+			}
+			if componentMapping, ok := matchComponents(component.graph, partner); ok {
+				for from, to := range componentMapping {
+					mapping[from] = to
+				}
+				partners[i] = nil
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, false
 		}
 	}
+	return mapping, true
 }
 
-// sortByDegree sorts blank nodes by their degree (number of triples they appear in)
-// This optimization helps backtracking by trying to match highly-connected nodes first
-func sortByDegree(blanks []string, triples []*Triple) []string {
-	degrees := make(map[string]int)
-	for _, blank := range blanks {
-		degrees[blank] = 0
-	}
-
-	for _, triple := range triples {
-		// Recursively count blank nodes in subject and object
-		countBlanksInTerm(triple.Subject, degrees)
-		countBlanksInTerm(triple.Object, degrees)
+// triplesToQuads puts triples in the default graph
+func triplesToQuads(triples []*Triple) []*Quad {
+	quads := make([]*Quad, len(triples))
+	for i, triple := range triples {
+		quads[i] = NewQuad(triple.Subject, triple.Predicate, triple.Object, NewDefaultGraph())
 	}
+	return quads
+}
 
-	// Sort by degree (descending)
-	sort.Slice(blanks, func(i, j int) bool {
-		return degrees[blanks[i]] > degrees[blanks[j]]
-	})
+// isomorphismGraph is a set of quads indexed by the blank nodes in them, which are numbered
+// by their position in blanks
+type isomorphismGraph struct {
+	quads      []*Quad         // The quads without duplicates
+	quadBlanks [][]int         // Blank nodes in each quad, including those in triple terms
+	quadTerms  [][4]quadTerm   // Each term of each quad, prepared for hashing
+	keys       map[string]bool // Key of each quad
+	blanks     []string        // All blank nodes, sorted
+	index      map[string]int  // Number of each blank node
+	quadsOf    [][]int         // Quads each blank node appears in
+	neighbours [][]int         // Blank nodes sharing a quad with each blank node
+	colours    []int           // Colour of each blank node
+}
 
-	return blanks
+// quadTerm is a term of a quad prepared for hashing in colour refinement
+type quadTerm struct {
+	hash  uint64 // Hash of a term without blank nodes, 0 otherwise
+	blank int    // Number of a blank node term, -1 otherwise
+	term  Term   // A term that contains blank nodes but isn't one
 }
 
-// simpleCompare compares two triple sets without considering blank node isomorphism
-func simpleCompare(expected, actual []*Triple) bool {
-	expectedMap := make(map[string]bool)
-	for _, triple := range expected {
-		key := tripleKey(triple, nil)
-		expectedMap[key] = true
+func newIsomorphismGraph(quads []*Quad) *isomorphismGraph {
+	g := &isomorphismGraph{keys: make(map[string]bool, len(quads))}
+	var quadBlankLabels [][]string
+	seenBlanks := make(map[string]bool)
+	for _, quad := range quads {
+		if quad.Graph == nil {
+			quad = NewQuad(quad.Subject, quad.Predicate, quad.Object, NewDefaultGraph())
+		}
+		key := quadKey(quad, nil)
+		if g.keys[key] {
+			continue
+		}
+		g.keys[key] = true
+
+		blanks := make(map[string]bool)
+		extractBlanksFromTerm(quad.Subject, blanks)
+		extractBlanksFromTerm(quad.Predicate, blanks)
+		extractBlanksFromTerm(quad.Object, blanks)
+		extractBlanksFromTerm(quad.Graph, blanks)
+		labels := sortedKeys(blanks)
+		for _, label := range labels {
+			seenBlanks[label] = true
+		}
+		g.quads = append(g.quads, quad)
+		quadBlankLabels = append(quadBlankLabels, labels)
+	}
+
+	g.blanks = sortedKeys(seenBlanks)
+	g.index = make(map[string]int, len(g.blanks))
+	for i, blank := range g.blanks {
+		g.index[blank] = i
+	}
+	g.quadTerms = make([][4]quadTerm, len(g.quads))
+	for i, quad := range g.quads {
+		for position, term := range []Term{quad.Subject, quad.Predicate, quad.Object, quad.Graph} {
+			prepared := quadTerm{blank: -1}
+			if blank, ok := term.(*BlankNode); ok {
+				prepared.blank = g.index[blank.ID]
+			} else if hasBlankNode(term) {
+				prepared.term = term
+			} else {
+				prepared.hash = hashString(term.String())
+			}
+			g.quadTerms[i][position] = prepared
+		}
+	}
+	g.quadsOf = make([][]int, len(g.blanks))
+	g.quadBlanks = make([][]int, len(g.quads))
+	for i, labels := range quadBlankLabels {
+		for _, label := range labels {
+			blank := g.index[label]
+			g.quadBlanks[i] = append(g.quadBlanks[i], blank)
+			g.quadsOf[blank] = append(g.quadsOf[blank], i)
+		}
 	}
 
-	for _, triple := range actual {
-		key := tripleKey(triple, nil)
-		if !expectedMap[key] {
-			return false
+	g.neighbours = make([][]int, len(g.blanks))
+	for blank := range g.blanks {
+		seen := map[int]bool{blank: true}
+		for _, i := range g.quadsOf[blank] {
+			for _, other := range g.quadBlanks[i] {
+				if !seen[other] {
+					seen[other] = true
+					g.neighbours[blank] = append(g.neighbours[blank], other)
+				}
+			}
 		}
 	}
+	g.colours = make([]int, len(g.blanks))
+	return g
+}
 
-	return true
+// hasBlankNode reports whether a term is or contains a blank node
+func hasBlankNode(term Term) bool {
+	blanks := make(map[string]bool)
+	extractBlanksFromTerm(term, blanks)
+	return len(blanks) > 0
 }
 
-// backtrack recursively tries to find a valid mapping between blank nodes
-func backtrack(expected, actual []*Triple, expectedBlanks, actualBlanks []string,
-	mapping map[string]string, usedTargets map[string]bool, index int) bool {
+// isomorphismComponent is a connected component of the blank nodes of a graph, with the quads
+// they appear in
+type isomorphismComponent struct {
+	graph     *isomorphismGraph
+	invariant string // The number of quads and the sorted colours of the blank nodes
+}
 
-	// Base case: all blank nodes have been mapped
-	if index == len(expectedBlanks) {
-		return verifyMapping(expected, actual, mapping)
-	}
+// components splits the graph into the connected components of its blank nodes, each with the
+// colours its nodes have in the whole graph
+func (g *isomorphismGraph) components() []isomorphismComponent {
+	var components []isomorphismComponent
+	visited := make([]bool, len(g.blanks))
+	for start := range g.blanks {
+		if visited[start] {
+			continue
+		}
+		visited[start] = true
+		members := []int{start}
+		for next := 0; next < len(members); next++ {
+			for _, neighbour := range g.neighbours[members[next]] {
+				if !visited[neighbour] {
+					visited[neighbour] = true
+					members = append(members, neighbour)
+				}
+			}
+		}
 
-	currentBlank := expectedBlanks[index]
+		// Each quad with blank nodes is in the component of its blank nodes
+		var quads []*Quad
+		seenQuads := make(map[int]bool)
+		for _, blank := range members {
+			for _, i := range g.quadsOf[blank] {
+				if !seenQuads[i] {
+					seenQuads[i] = true
+					quads = append(quads, g.quads[i])
+				}
+			}
+		}
 
-	// Try mapping current blank node to each candidate
-	for _, candidateBlank := range actualBlanks {
-		// Skip if this target blank node is already mapped
-		if usedTargets[candidateBlank] {
-			continue
+		component := newIsomorphismGraph(quads)
+		colours := make([]int, len(members))
+		for i, blank := range component.blanks {
+			component.colours[i] = g.colours[g.index[blank]]
+			colours[i] = component.colours[i]
 		}
+		sort.Ints(colours)
+		var invariant strings.Builder
+		invariant.WriteString(strconv.Itoa(len(quads)))
+		for _, colour := range colours {
+			invariant.WriteByte(' ')
+			invariant.WriteString(strconv.Itoa(colour))
+		}
+		components = append(components, isomorphismComponent{graph: component, invariant: invariant.String()})
+	}
+	return components
+}
 
-		// Try this mapping
-		mapping[currentBlank] = candidateBlank
-		usedTargets[candidateBlank] = true
+// refineColours refines the colours of the blank nodes of two graphs together by iterative
+// hash refinement. In each round a node gets a new colour for its old colour and the quads it
+// appears in, with the other blank nodes in them written as their colours, until a round no
+// longer splits any colour. Nodes that an isomorphism maps to each other always have the same
+// colour, so refinement returns false as soon as the graphs have different numbers of nodes
+// of some colour.
+func refineColours(source, target *isomorphismGraph) bool {
+	distinct := make(map[int]bool)
+	for _, colour := range source.colours {
+		distinct[colour] = true
+	}
+	count := len(distinct)
+
+	var signatures []uint64
+	for {
+		// The palette is shared, so equal colours in the two graphs mean equal signatures
+		palette := make(map[uint64]int, count)
+		source.colours = source.refine(palette, &signatures)
+		target.colours = target.refine(palette, &signatures)
+
+		counts := source.colourCounts(len(palette))
+		for _, colour := range target.colours {
+			counts[colour]--
+		}
+		for _, n := range counts {
+			if n != 0 {
+				return false
+			}
+		}
+		// Colours only ever split, so the same number of them means the same partition
+		if len(palette) == count {
+			return true
+		}
+		count = len(palette)
+	}
+}
 
-		// Early pruning: check if mapping is still consistent
-		if isConsistentSoFar(expected, actual, mapping) {
-			if backtrack(expected, actual, expectedBlanks, actualBlanks, mapping, usedTargets, index+1) {
-				return true
+// refine returns the colours of the blank nodes after a round of refinement, adding new
+// signatures to the palette
+func (g *isomorphismGraph) refine(palette map[uint64]int, signatures *[]uint64) []int {
+	colours := make([]int, len(g.blanks))
+	for blank := range g.blanks {
+		*signatures = (*signatures)[:0]
+		for _, i := range g.quadsOf[blank] {
+			var hash uint64
+			for _, term := range g.quadTerms[i] {
+				switch {
+				case term.blank == blank:
+					hash = mixHash(hash, mixHash(hashSelf, 0))
+				case term.blank >= 0:
+					hash = mixHash(hash, mixHash(hashBlank, uint64(g.colours[term.blank])))
+				case term.term != nil:
+					hash = mixHash(hash, g.colouredHash(term.term, blank))
+				default:
+					hash = mixHash(hash, term.hash)
+				}
 			}
+			*signatures = append(*signatures, hash)
 		}
+		slices.Sort(*signatures)
 
-		// Backtrack
-		delete(mapping, currentBlank)
-		delete(usedTargets, candidateBlank)
+		signature := mixHash(0, uint64(g.colours[blank]))
+		for _, hash := range *signatures {
+			signature = mixHash(signature, hash)
+		}
+		colour, exists := palette[signature]
+		if !exists {
+			colour = len(palette)
+			palette[signature] = colour
+		}
+		colours[blank] = colour
 	}
-
-	return false
+	return colours
 }
 
-// isTermFullyMapped recursively checks if all blank nodes in a term are mapped
-func isTermFullyMapped(term Term, mapping map[string]string) bool {
+// Seeds for hashing the kinds of terms that contain blank nodes
+const (
+	hashSelf uint64 = iota + 1
+	hashBlank
+	hashTripleTerm
+	hashQuotedTriple
+	hashReifiedTriple
+)
+
+// colouredHash hashes a term with the blank node being coloured hashed as itself and other
+// blank nodes as their colours
+func (g *isomorphismGraph) colouredHash(term Term, self int) uint64 {
 	switch t := term.(type) {
 	case *BlankNode:
-		_, exists := mapping[t.ID]
-		return exists
+		blank := g.index[t.ID]
+		if blank == self {
+			return mixHash(hashSelf, 0)
+		}
+		return mixHash(hashBlank, uint64(g.colours[blank]))
 	case *TripleTerm:
-		// Check if all blank nodes in triple term components are mapped
-		return isTermFullyMapped(t.Subject, mapping) &&
-			isTermFullyMapped(t.Predicate, mapping) &&
-			isTermFullyMapped(t.Object, mapping)
+		hash := mixHash(hashTripleTerm, g.colouredHash(t.Subject, self))
+		hash = mixHash(hash, g.colouredHash(t.Predicate, self))
+		return mixHash(hash, g.colouredHash(t.Object, self))
 	case *QuotedTriple:
-		// Check if all blank nodes in quoted triple components are mapped
-		return isTermFullyMapped(t.Subject, mapping) &&
-			isTermFullyMapped(t.Predicate, mapping) &&
-			isTermFullyMapped(t.Object, mapping)
+		hash := mixHash(hashQuotedTriple, g.colouredHash(t.Subject, self))
+		hash = mixHash(hash, g.colouredHash(t.Predicate, self))
+		return mixHash(hash, g.colouredHash(t.Object, self))
 	case *ReifiedTriple:
-		// Check identifier and the underlying triple
-		if !isTermFullyMapped(t.Identifier, mapping) {
-			return false
-		}
+		hash := mixHash(hashReifiedTriple, g.colouredHash(t.Identifier, self))
 		if t.Triple != nil {
-			return isTermFullyMapped(t.Triple.Subject, mapping) &&
-				isTermFullyMapped(t.Triple.Predicate, mapping) &&
-				isTermFullyMapped(t.Triple.Object, mapping)
+			hash = mixHash(hash, g.colouredHash(t.Triple, self))
 		}
-		return true
+		return hash
 	default:
-		// Non-blank node terms are always "fully mapped"
-		return true
+		return hashString(term.String())
 	}
 }
 
-// isConsistentSoFar checks if the current partial mapping is consistent
-// This is an optimization to prune the search space early
-func isConsistentSoFar(expected, actual []*Triple, mapping map[string]string) bool {
-	// For each triple in expected that only contains mapped blank nodes,
-	// check if there's a corresponding triple in actual
-	for _, triple := range expected {
-		// Check if all blank nodes in this triple (including nested ones) are mapped
-		subjectMapped := isTermFullyMapped(triple.Subject, mapping)
-		objectMapped := isTermFullyMapped(triple.Object, mapping)
-
-		// If all blank nodes in this triple are mapped, verify it exists in actual
-		if subjectMapped && objectMapped {
-			found := false
-			mappedKey := tripleKey(triple, mapping)
-
-			for _, actualTriple := range actual {
-				if tripleKey(actualTriple, nil) == mappedKey {
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				return false
-			}
-		}
-	}
+// hashString hashes a string with FNV-1a, never returning 0
+func hashString(s string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(s))
+	return hash.Sum64() | 1
+}
 
-	return true
+// mixHash combines a hash with a value. Colliding hashes merge colours, which only makes
+// refinement coarser: matches are still checked against the quads.
+func mixHash(hash, value uint64) uint64 {
+	hash ^= value + 0x9e3779b97f4a7c15 + (hash << 6) + (hash >> 2)
+	hash ^= hash >> 31
+	hash *= 0xbf58476d1ce4e5b9
+	hash ^= hash >> 29
+	return hash
 }
 
-// verifyMapping checks if the given mapping makes the graphs identical
-func verifyMapping(expected, actual []*Triple, mapping map[string]string) bool {
-	// Create a set of mapped expected triples
-	expectedMapped := make(map[string]bool)
-	for _, triple := range expected {
-		key := tripleKey(triple, mapping)
-		expectedMapped[key] = true
+// colourCounts returns the number of blank nodes of each colour, for colours numbered below
+// colours as they are after refinement
+func (g *isomorphismGraph) colourCounts(colours int) []int {
+	counts := make([]int, colours)
+	for _, colour := range g.colours {
+		counts[colour]++
 	}
+	return counts
+}
+
+// individualColour is the colour given to the nodes tried against each other; refinement
+// renumbers colours from 0, so it is never in use
+const individualColour = -1
 
-	// Create a set of actual triples
-	actualSet := make(map[string]bool)
-	for _, triple := range actual {
-		key := tripleKey(triple, nil)
-		actualSet[key] = true
+// matchComponents finds an isomorphism between two connected components whose colours are
+// those they have in their whole graphs
+func matchComponents(source, target *isomorphismGraph) (map[string]string, bool) {
+	if len(source.quads) != len(target.quads) || len(source.blanks) != len(target.blanks) {
+		return nil, false
+	}
+	if !refineColours(source, target) {
+		return nil, false
 	}
+	return individualise(source, target)
+}
 
-	// Check if they're identical
-	if len(expectedMapped) != len(actualSet) {
-		return false
+// individualise matches two graphs with stable colours: once every colour is a single node
+// the mapping is fixed and checked against the quads, otherwise a node of the smallest class
+// is tried against each node of the same colour in the target
+func individualise(source, target *isomorphismGraph) (map[string]string, bool) {
+	counts := source.colourCounts(slices.Max(source.colours) + 1)
+	class := -1
+	for colour, n := range counts {
+		if n > 1 && (class == -1 || n < counts[class]) {
+			class = colour
+		}
 	}
 
-	for key := range expectedMapped {
-		if !actualSet[key] {
-			return false
+	if class == -1 {
+		byColour := make(map[int]int, len(target.blanks))
+		for blank, colour := range target.colours {
+			byColour[colour] = blank
+		}
+		mapping := make(map[string]string, len(source.blanks))
+		for blank, colour := range source.colours {
+			mapping[source.blanks[blank]] = target.blanks[byColour[colour]]
+		}
+		for _, quad := range source.quads {
+			if !target.keys[quadKey(quad, mapping)] {
+				return nil, false
+			}
 		}
+		return mapping, true
 	}
 
-	return true
+	node := -1
+	for blank, colour := range source.colours {
+		if colour == class {
+			node = blank
+			break
+		}
+	}
+	sourceColours := append([]int(nil), source.colours...)
+	targetColours := append([]int(nil), target.colours...)
+	for candidate, colour := range targetColours {
+		if colour != class {
+			continue
+		}
+		source.colours[node] = individualColour
+		target.colours[candidate] = individualColour
+		if refineColours(source, target) {
+			if mapping, ok := individualise(source, target); ok {
+				return mapping, true
+			}
+		}
+		source.colours = append(source.colours[:0], sourceColours...)
+		target.colours = append(target.colours[:0], targetColours...)
+	}
+	return nil, false
 }
 
-// tripleKey creates a string key for a triple, applying blank node mapping if provided
-func tripleKey(triple *Triple, mapping map[string]string) string {
-	subject := termString(triple.Subject, mapping)
-	predicate := termString(triple.Predicate, mapping)
-	object := termString(triple.Object, mapping)
-	return fmt.Sprintf("%s|%s|%s", subject, predicate, object)
+// extractBlanksFromTerm recursively extracts blank nodes from a term,
+// including those inside TripleTerms, QuotedTriples, and ReifiedTriples
+func extractBlanksFromTerm(term Term, blanks map[string]bool) {
+	switch t := term.(type) {
+	case *BlankNode:
+		blanks[t.ID] = true
+	case *TripleTerm:
+		// Recursively extract from triple term components
+		extractBlanksFromTerm(t.Subject, blanks)
+		extractBlanksFromTerm(t.Predicate, blanks)
+		extractBlanksFromTerm(t.Object, blanks)
+	case *QuotedTriple:
+		// Recursively extract from quoted triple components
+		extractBlanksFromTerm(t.Subject, blanks)
+		extractBlanksFromTerm(t.Predicate, blanks)
+		extractBlanksFromTerm(t.Object, blanks)
+	case *ReifiedTriple:
+		// Extract from identifier and the underlying triple
+		extractBlanksFromTerm(t.Identifier, blanks)
+		if t.Triple != nil {
+			extractBlanksFromTerm(t.Triple.Subject, blanks)
+			extractBlanksFromTerm(t.Triple.Predicate, blanks)
+			extractBlanksFromTerm(t.Triple.Object, blanks)
+		}
+	}
 }
 
 // termString converts a term to string, applying blank node mapping if applicable
@@ -330,181 +548,6 @@ func termString(term Term, mapping map[string]string) string {
 	}
 }
 
-// AreQuadsIsomorphic checks if two sets of quads are isomorphic,
-// accounting for blank node label differences in both triples and graph names.
-func AreQuadsIsomorphic(expected, actual []*Quad) bool {
-	// Quick check: same number of quads
-	if len(expected) != len(actual) {
-		return false
-	}
-
-	// Extract blank nodes from both graphs (including graph names)
-	expectedBlanks := extractBlankNodeLabelsFromQuads(expected)
-	actualBlanks := extractBlankNodeLabelsFromQuads(actual)
-
-	// Quick check: same number of blank nodes
-	if len(expectedBlanks) != len(actualBlanks) {
-		return false
-	}
-
-	// If no blank nodes, use simple comparison
-	if len(expectedBlanks) == 0 {
-		return simpleCompareQuads(expected, actual)
-	}
-
-	// Sort blank nodes by degree
-	expectedBlanks = sortByDegreeQuads(expectedBlanks, expected)
-	actualBlanks = sortByDegreeQuads(actualBlanks, actual)
-
-	// Find isomorphic mapping via backtracking
-	mapping := make(map[string]string)
-	usedTargets := make(map[string]bool)
-	return backtrackQuads(expected, actual, expectedBlanks, actualBlanks, mapping, usedTargets, 0)
-}
-
-// extractBlankNodeLabelsFromQuads extracts all unique blank node labels from quads
-func extractBlankNodeLabelsFromQuads(quads []*Quad) []string {
-	blanks := make(map[string]bool)
-	for _, quad := range quads {
-		// Recursively extract from subject, object, and graph
-		extractBlanksFromTerm(quad.Subject, blanks)
-		extractBlanksFromTerm(quad.Object, blanks)
-		extractBlanksFromTerm(quad.Graph, blanks)
-	}
-
-	result := make([]string, 0, len(blanks))
-	for label := range blanks {
-		result = append(result, label)
-	}
-	sort.Strings(result)
-	return result
-}
-
-// sortByDegreeQuads sorts blank nodes by their degree in quads
-func sortByDegreeQuads(blanks []string, quads []*Quad) []string {
-	degrees := make(map[string]int)
-	for _, blank := range blanks {
-		degrees[blank] = 0
-	}
-
-	for _, quad := range quads {
-		// Recursively count blank nodes in subject, object, and graph
-		countBlanksInTerm(quad.Subject, degrees)
-		countBlanksInTerm(quad.Object, degrees)
-		countBlanksInTerm(quad.Graph, degrees)
-	}
-
-	sort.Slice(blanks, func(i, j int) bool {
-		return degrees[blanks[i]] > degrees[blanks[j]]
-	})
-
-	return blanks
-}
-
-// simpleCompareQuads compares two quad sets without considering blank node isomorphism
-func simpleCompareQuads(expected, actual []*Quad) bool {
-	expectedMap := make(map[string]bool)
-	for _, quad := range expected {
-		key := quadKey(quad, nil)
-		expectedMap[key] = true
-	}
-
-	for _, quad := range actual {
-		key := quadKey(quad, nil)
-		if !expectedMap[key] {
-			return false
-		}
-	}
-
-	return true
-}
-
-// backtrackQuads recursively tries to find a valid mapping between blank nodes in quads
-func backtrackQuads(expected, actual []*Quad, expectedBlanks, actualBlanks []string,
-	mapping map[string]string, usedTargets map[string]bool, index int) bool {
-
-	if index == len(expectedBlanks) {
-		return verifyMappingQuads(expected, actual, mapping)
-	}
-
-	currentBlank := expectedBlanks[index]
-
-	for _, candidateBlank := range actualBlanks {
-		if usedTargets[candidateBlank] {
-			continue
-		}
-
-		mapping[currentBlank] = candidateBlank
-		usedTargets[candidateBlank] = true
-
-		if isConsistentSoFarQuads(expected, actual, mapping) {
-			if backtrackQuads(expected, actual, expectedBlanks, actualBlanks, mapping, usedTargets, index+1) {
-				return true
-			}
-		}
-
-		delete(mapping, currentBlank)
-		delete(usedTargets, candidateBlank)
-	}
-
-	return false
-}
-
-// isConsistentSoFarQuads checks if the current partial mapping is consistent for quads
-func isConsistentSoFarQuads(expected, actual []*Quad, mapping map[string]string) bool {
-	for _, quad := range expected {
-		// Check if all blank nodes in this quad (including nested ones) are mapped
-		subjectMapped := isTermFullyMapped(quad.Subject, mapping)
-		objectMapped := isTermFullyMapped(quad.Object, mapping)
-		graphMapped := isTermFullyMapped(quad.Graph, mapping)
-
-		if subjectMapped && objectMapped && graphMapped {
-			found := false
-			mappedKey := quadKey(quad, mapping)
-
-			for _, actualQuad := range actual {
-				if quadKey(actualQuad, nil) == mappedKey {
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				return false
-			}
-		}
-	}
-
-	return true
-}
-
-// verifyMappingQuads checks if the given mapping makes the quad graphs identical
-func verifyMappingQuads(expected, actual []*Quad, mapping map[string]string) bool {
-	expectedMapped := make(map[string]bool)
-	for _, quad := range expected {
-		key := quadKey(quad, mapping)
-		expectedMapped[key] = true
-	}
-
-	actualSet := make(map[string]bool)
-	for _, quad := range actual {
-		key := quadKey(quad, nil)
-		actualSet[key] = true
-	}
-
-	if len(expectedMapped) != len(actualSet) {
-		return false
-	}
-
-	for key := range expectedMapped {
-		if !actualSet[key] {
-			return false
-		}
-	}
-
-	return true
-}
-
 // quadKey creates a string key for a quad, applying blank node mapping if provided
 func quadKey(quad *Quad, mapping map[string]string) string {
 	subject := termString(quad.Subject, mapping)
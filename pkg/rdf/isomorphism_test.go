@@ -1,7 +1,10 @@
 package rdf
 
 import (
+	"fmt"
+	"math/rand"
 	"testing"
+	"time"
 )
 
 func TestAreGraphsIsomorphic_EmptyGraphs(t *testing.T) {
@@ -517,3 +520,130 @@ func TestAreQuadsIsomorphic_ComplexQuadGraph(t *testing.T) {
 		t.Error("Complex quad graphs with shared blank nodes should be isomorphic")
 	}
 }
+
+func TestFindQuadsIsomorphism_Mapping(t *testing.T) {
+	// _:a and _:b only differ by the graph they are in
+	expected := []*Quad{
+		NewQuad(&BlankNode{ID: "a"}, &NamedNode{IRI: "http://example.org/p"}, &BlankNode{ID: "b"}, &BlankNode{ID: "g"}),
+		NewQuad(&BlankNode{ID: "b"}, &NamedNode{IRI: "http://example.org/p"}, &BlankNode{ID: "a"}, NewDefaultGraph()),
+	}
+	actual := []*Quad{
+		NewQuad(&BlankNode{ID: "y"}, &NamedNode{IRI: "http://example.org/p"}, &BlankNode{ID: "x"}, NewDefaultGraph()),
+		NewQuad(&BlankNode{ID: "x"}, &NamedNode{IRI: "http://example.org/p"}, &BlankNode{ID: "y"}, &BlankNode{ID: "h"}),
+	}
+
+	mapping, ok := FindQuadsIsomorphism(expected, actual)
+	if !ok {
+		t.Fatal("Datasets should be isomorphic")
+	}
+	if mapping["a"] != "x" || mapping["b"] != "y" || mapping["g"] != "h" || len(mapping) != 3 {
+		t.Errorf("Unexpected mapping: %v", mapping)
+	}
+
+	if _, ok := FindQuadsIsomorphism(expected, actual[:1]); ok {
+		t.Error("Datasets of different sizes should not be isomorphic")
+	}
+}
+
+// blankRings returns the triples of rings of blank nodes of the given sizes, labelled through a
+// permutation and in shuffled order
+func blankRings(sizes []int, prefix string, random *rand.Rand) []*Triple {
+	total := 0
+	for _, size := range sizes {
+		total += size
+	}
+	labels := random.Perm(total)
+	var triples []*Triple
+	start := 0
+	for _, size := range sizes {
+		for i := 0; i < size; i++ {
+			node := &BlankNode{ID: fmt.Sprintf("%s%d", prefix, labels[start+i])}
+			next := &BlankNode{ID: fmt.Sprintf("%s%d", prefix, labels[start+(i+1)%size])}
+			triples = append(triples,
+				NewTriple(node, &NamedNode{IRI: "http://example.org/next"}, next),
+				NewTriple(node, &NamedNode{IRI: "http://example.org/value"}, &Literal{Value: "v", Datatype: XSDString}))
+		}
+		start += size
+	}
+	random.Shuffle(len(triples), func(i, j int) { triples[i], triples[j] = triples[j], triples[i] })
+	return triples
+}
+
+func TestAreGraphsIsomorphic_ManySimilarBlankNodes(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	sizes := make([]int, 0, 1001)
+	for i := 0; i < 1000; i++ {
+		sizes = append(sizes, 3)
+	}
+	sizes = append(sizes, 2000)
+
+	start := time.Now()
+	mapping, ok := FindGraphsIsomorphism(blankRings(sizes, "a", random), blankRings(sizes, "b", random))
+	if !ok || len(mapping) != 5000 {
+		t.Fatalf("Graphs with 5000 blank nodes should be isomorphic, got %d mapped", len(mapping))
+	}
+
+	// Every node has the same colour in rings of any size, so refinement alone can't tell these apart
+	if AreGraphsIsomorphic(blankRings([]int{3000}, "a", random), blankRings(sizes[:1000], "b", random)) {
+		t.Error("A ring of 3000 blank nodes should not be isomorphic to 1000 rings of 3")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Comparing took %v", elapsed)
+	}
+}
+
+// circulant returns the triples of a graph of blank nodes where each node links to the next
+// one and, through a second predicate, to the one skip places further, labelled through a
+// permutation and in shuffled order
+func circulant(size, skip int, prefix string, random *rand.Rand) []*Triple {
+	labels := random.Perm(size)
+	node := func(i int) *BlankNode { return &BlankNode{ID: fmt.Sprintf("%s%d", prefix, labels[i%size])} }
+	var triples []*Triple
+	for i := 0; i < size; i++ {
+		triples = append(triples,
+			NewTriple(node(i), &NamedNode{IRI: "http://example.org/next"}, node(i+1)),
+			NewTriple(node(i), &NamedNode{IRI: "http://example.org/skip"}, node(i+skip)))
+	}
+	random.Shuffle(len(triples), func(i, j int) { triples[i], triples[j] = triples[j], triples[i] })
+	return triples
+}
+
+func TestAreGraphsIsomorphic_RegularNonIsomorphic(t *testing.T) {
+	// In all of these every node has the same colour after refinement
+	random := rand.New(rand.NewSource(2))
+	repeat := func(size, times int) []int {
+		sizes := make([]int, times)
+		for i := range sizes {
+			sizes[i] = size
+		}
+		return sizes
+	}
+
+	for name, test := range map[string]struct {
+		expected, actual []*Triple
+		isomorphic       bool
+	}{
+		"rings of 4 and one of 8": {
+			blankRings(append(repeat(4, 250), 8), "a", random), blankRings(repeat(4, 252), "b", random), false,
+		},
+		"one ring or two": {
+			blankRings([]int{2000}, "a", random), blankRings([]int{1000, 1000}, "b", random), false,
+		},
+		"connected with different skips": {
+			circulant(1000, 2, "a", random), circulant(1000, 3, "b", random), false,
+		},
+		"connected with the same skip": {
+			circulant(1000, 3, "a", random), circulant(1000, 3, "b", random), true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			if AreGraphsIsomorphic(test.expected, test.actual) != test.isomorphic {
+				t.Errorf("Expected isomorphic to be %v", test.isomorphic)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Comparing took %v", elapsed)
+			}
+		})
+	}
+}
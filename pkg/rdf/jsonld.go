@@ -1,249 +1,102 @@
 package rdf
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
-	"strings"
 )
 
-// JSONLDParser parses JSON-LD format
-// Note: This is a simplified parser that handles common JSON-LD patterns.
-// It supports:
-// - Simple @id and @type
-// - Properties with string values
-// - Properties with @value objects
-// - Properties with @id references
-// - @language and @type in value objects
-// - Basic @context expansion (prefix mapping)
-//
-// Not yet supported:
-// - Full @context processing (remote contexts, nested contexts)
-// - @graph
-// - @list and @set
-// - @reverse
-// - Framing
-// - Compaction/Expansion algorithms
-type JSONLDParser struct{}
+// JSONLDParser parses JSON-LD 1.1 by running the Expansion and Deserialize JSON-LD to RDF
+// algorithms of the JSON-LD 1.1 Processing Algorithms and API specification. Contexts may be
+// inline, arrays of contexts, or references to remote contexts; remote contexts are retrieved
+// through the parser's DocumentLoader. Without one, documents that reference remote contexts
+// are rejected, so parsing never reaches out to the network unless asked to.
+type JSONLDParser struct {
+	baseURI        string
+	loader         DocumentLoader
+	expandContext  any
+	processingMode string
+	rdfDirection   string
+}
 
-// NewJSONLDParser creates a new JSON-LD parser
-func NewJSONLDParser() *JSONLDParser {
-	return &JSONLDParser{}
+// JSONLDError is an error raised by the JSON-LD algorithms, with the error code the
+// specification gives it, such as "invalid term definition"
+type JSONLDError struct {
+	Code    string
+	Message string
+	Err     error // The underlying error, such as the ParseError of a JSON syntax error
 }
 
-// Parse parses JSON-LD and returns quads (all in default graph)
-func (p *JSONLDParser) Parse(reader io.Reader) ([]*Quad, error) {
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("error reading JSON-LD: %w", err)
-	}
+func (e *JSONLDError) Error() string {
+	return fmt.Sprintf("JSON-LD error (%s): %s", e.Code, e.Message)
+}
 
-	var doc interface{}
-	if err := json.Unmarshal(data, &doc); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %w", err)
-	}
+func (e *JSONLDError) Unwrap() error {
+	return e.Err
+}
 
-	var context map[string]interface{}
-	var quads []*Quad
-	blankNodeCounter := 0
-
-	// Handle both single object and array of objects
-	switch v := doc.(type) {
-	case map[string]interface{}:
-		// Extract @context if present
-		if ctx, ok := v["@context"].(map[string]interface{}); ok {
-			context = ctx
-		}
-		// Parse single object
-		objQuads, err := p.parseObject(v, context, &blankNodeCounter)
-		if err != nil {
-			return nil, err
-		}
-		quads = append(quads, objQuads...)
-
-	case []interface{}:
-		// Parse array of objects
-		for _, item := range v {
-			if obj, ok := item.(map[string]interface{}); ok {
-				// Extract @context from each object if present
-				if ctx, ok := obj["@context"].(map[string]interface{}); ok {
-					context = ctx
-				}
-				objQuads, err := p.parseObject(obj, context, &blankNodeCounter)
-				if err != nil {
-					return nil, err
-				}
-				quads = append(quads, objQuads...)
-			}
-		}
-
-	default:
-		return nil, fmt.Errorf("unexpected JSON-LD structure: %T", doc)
-	}
+func jsonldErrorf(code, format string, args ...any) error {
+	return &JSONLDError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
 
-	return quads, nil
+// NewJSONLDParser creates a new JSON-LD parser
+func NewJSONLDParser() *JSONLDParser {
+	return &JSONLDParser{}
 }
 
-// parseObjectWithSubject parses a JSON-LD object and returns quads and the subject
-func (p *JSONLDParser) parseObjectWithSubject(obj map[string]interface{}, context map[string]interface{}, blankNodeCounter *int) ([]*Quad, Term, error) {
-	var quads []*Quad
+// SetBaseURI sets the base IRI relative IRIs in the document are resolved against
+func (p *JSONLDParser) SetBaseURI(baseURI string) {
+	p.baseURI = baseURI
+}
 
-	// Get subject
-	var subject Term
-	if idVal, ok := obj["@id"]; ok {
-		if idStr, ok := idVal.(string); ok {
-			subject = NewNamedNode(p.expandIRI(idStr, context))
-		}
-	}
-	if subject == nil {
-		// Generate blank node
-		*blankNodeCounter++
-		subject = NewBlankNode(fmt.Sprintf("b%d", *blankNodeCounter))
-	}
+// SetDocumentLoader sets the loader remote contexts are retrieved with
+func (p *JSONLDParser) SetDocumentLoader(loader DocumentLoader) {
+	p.loader = loader
+}
 
-	// Process properties
-	for key, value := range obj {
-		// Skip JSON-LD keywords
-		if strings.HasPrefix(key, "@") {
-			continue
-		}
-
-		// Expand property IRI
-		predicate := NewNamedNode(p.expandIRI(key, context))
-
-		// Parse value(s)
-		switch v := value.(type) {
-		case string:
-			// Simple string literal
-			object := NewLiteral(v)
-			quad := NewQuad(subject, predicate, object, NewDefaultGraph())
-			quads = append(quads, quad)
-
-		case float64, bool:
-			// Number or boolean literal
-			object := NewLiteral(fmt.Sprintf("%v", v))
-			quad := NewQuad(subject, predicate, object, NewDefaultGraph())
-			quads = append(quads, quad)
-
-		case map[string]interface{}:
-			// Value object or nested object
-			objQuads, err := p.parseValue(subject, predicate, v, context, blankNodeCounter)
-			if err != nil {
-				return nil, nil, err
-			}
-			quads = append(quads, objQuads...)
-
-		case []interface{}:
-			// Array of values
-			for _, item := range v {
-				switch itemVal := item.(type) {
-				case string:
-					object := NewLiteral(itemVal)
-					quad := NewQuad(subject, predicate, object, NewDefaultGraph())
-					quads = append(quads, quad)
-
-				case map[string]interface{}:
-					objQuads, err := p.parseValue(subject, predicate, itemVal, context, blankNodeCounter)
-					if err != nil {
-						return nil, nil, err
-					}
-					quads = append(quads, objQuads...)
-				}
-			}
-		}
+// SetExpandContext sets a context applied before the document's own, given as a JSON context
+// or a JSON object with a @context entry
+func (p *JSONLDParser) SetExpandContext(context []byte) error {
+	value, err := decodeJSON(context)
+	if err != nil {
+		return fmt.Errorf("error parsing expand context: %w", err)
 	}
+	p.expandContext = value
+	return nil
+}
 
-	return quads, subject, nil
+// SetProcessingMode sets the processing mode, "json-ld-1.1" (the default) or "json-ld-1.0"
+func (p *JSONLDParser) SetProcessingMode(mode string) {
+	p.processingMode = mode
 }
 
-// parseObject parses a JSON-LD object and returns quads
-func (p *JSONLDParser) parseObject(obj map[string]interface{}, context map[string]interface{}, blankNodeCounter *int) ([]*Quad, error) {
-	quads, _, err := p.parseObjectWithSubject(obj, context, blankNodeCounter)
-	return quads, err
+// SetRDFDirection sets how the base direction of strings is represented in RDF:
+// "i18n-datatype", "compound-literal", or "" (the default) to drop it
+func (p *JSONLDParser) SetRDFDirection(mode string) {
+	p.rdfDirection = mode
 }
 
-// parseValue parses a value object (with @value, @id, @type, @language, etc.)
-func (p *JSONLDParser) parseValue(subject, predicate Term, value map[string]interface{}, context map[string]interface{}, blankNodeCounter *int) ([]*Quad, error) {
-	var quads []*Quad
-
-	// Check for @id (reference to another resource)
-	if idVal, ok := value["@id"]; ok {
-		if idStr, ok := idVal.(string); ok {
-			object := NewNamedNode(p.expandIRI(idStr, context))
-			quad := NewQuad(subject, predicate, object, NewDefaultGraph())
-			quads = append(quads, quad)
-			return quads, nil
-		}
+// Parse parses JSON-LD and returns its quads, with blank nodes labelled b0, b1, ...
+func (p *JSONLDParser) Parse(reader io.Reader) ([]*Quad, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JSON-LD: %w", err)
 	}
 
-	// Check for @value (literal value)
-	if val, ok := value["@value"]; ok {
-		var object Term
-
-		// Check for @language
-		if lang, ok := value["@language"].(string); ok {
-			object = &Literal{
-				Value:    fmt.Sprintf("%v", val),
-				Language: lang,
-			}
-		} else if typeVal, ok := value["@type"].(string); ok {
-			// Check for @type (datatype)
-			object = &Literal{
-				Value:    fmt.Sprintf("%v", val),
-				Datatype: NewNamedNode(p.expandIRI(typeVal, context)),
-			}
-		} else {
-			// Plain literal
-			object = NewLiteral(fmt.Sprintf("%v", val))
-		}
-
-		quad := NewQuad(subject, predicate, object, NewDefaultGraph())
-		quads = append(quads, quad)
-		return quads, nil
+	document, err := decodeJSON(data)
+	if err != nil {
+		return nil, &JSONLDError{Code: "loading document failed", Message: fmt.Sprintf("error parsing JSON: %v", err), Err: err}
 	}
 
-	// Otherwise, treat as nested object (blank node)
-	// Parse nested object first to get its subject (the blank node)
-	nestedQuads, nestedSubject, err := p.parseObjectWithSubject(value, context, blankNodeCounter)
+	processor := p.newProcessor()
+	expanded, err := processor.expandDocument(document, p.baseURI, p.expandContext, expandOptions{})
 	if err != nil {
 		return nil, err
 	}
-
-	// Create triple linking to the nested object
-	quad := NewQuad(subject, predicate, nestedSubject, NewDefaultGraph())
-	quads = append(quads, quad)
-	quads = append(quads, nestedQuads...)
-
-	return quads, nil
+	return processor.toRDF(expanded)
 }
 
-// expandIRI expands a compact IRI using the context
-func (p *JSONLDParser) expandIRI(iri string, context map[string]interface{}) string {
-	// Already a full IRI
-	if strings.Contains(iri, "://") {
-		return iri
-	}
-
-	// Check if it's a term defined in context first
-	if context != nil {
-		if expanded, ok := context[iri].(string); ok {
-			// Recursively expand the result
-			return p.expandIRI(expanded, context)
-		}
-	}
-
-	// Check for prefix:localName pattern
-	parts := strings.SplitN(iri, ":", 2)
-	if len(parts) == 2 && context != nil {
-		prefix := parts[0]
-		localName := parts[1]
-
-		// Look up prefix in context
-		if ns, ok := context[prefix].(string); ok {
-			return ns + localName
-		}
-	}
-
-	// Return as-is (might be relative IRI)
-	return iri
+func (p *JSONLDParser) newProcessor() *jsonldProcessor {
+	processor := newJSONLDProcessor(p.loader, p.processingMode)
+	processor.rdfDirection = p.rdfDirection
+	return processor
 }
@@ -0,0 +1,983 @@
+package rdf
+
+import (
+	"sort"
+	"strings"
+)
+
+// inverseContext maps an IRI to the terms that expand to it, by container and by the type or
+// language of the values they are selected for
+type inverseContext map[string]map[string]*inverseEntry
+
+// inverseEntry holds the terms for one IRI and container: by type mapping, by language mapping,
+// and under @none any term at all
+type inverseEntry struct {
+	language map[string]string
+	typ      map[string]string
+	any      map[string]string
+}
+
+func (e *inverseEntry) values(typeLanguage string) map[string]string {
+	switch typeLanguage {
+	case "@type":
+		return e.typ
+	case "@language":
+		return e.language
+	}
+	return e.any
+}
+
+// inverseContext runs the Inverse Context Creation algorithm, once per active context
+func (c *jsonldContext) inverseContext() inverseContext {
+	if c.inverse != nil {
+		return c.inverse
+	}
+	defaultLanguage := "@none"
+	if c.language != "" {
+		defaultLanguage = strings.ToLower(c.language)
+	}
+
+	// Shorter terms are preferred, then lexicographically lesser ones
+	terms := make([]string, 0, len(c.terms))
+	for term := range c.terms {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if len(terms[i]) != len(terms[j]) {
+			return len(terms[i]) < len(terms[j])
+		}
+		return terms[i] < terms[j]
+	})
+
+	result := make(inverseContext)
+	for _, term := range terms {
+		def := c.terms[term]
+		if def == nil || !def.hasID {
+			continue
+		}
+		container := "@none"
+		if len(def.container) > 0 {
+			containers := append([]string(nil), def.container...)
+			sort.Strings(containers)
+			container = strings.Join(containers, "")
+		}
+		containerMap, ok := result[def.id]
+		if !ok {
+			containerMap = make(map[string]*inverseEntry)
+			result[def.id] = containerMap
+		}
+		entry, ok := containerMap[container]
+		if !ok {
+			entry = &inverseEntry{
+				language: make(map[string]string),
+				typ:      make(map[string]string),
+				any:      map[string]string{"@none": term},
+			}
+			containerMap[container] = entry
+		}
+		add := func(values map[string]string, key string) {
+			if _, ok := values[key]; !ok {
+				values[key] = term
+			}
+		}
+
+		switch {
+		case def.reverse:
+			add(entry.typ, "@reverse")
+		case def.typeMapping == "@none":
+			add(entry.language, "@any")
+			add(entry.typ, "@any")
+		case def.typeMapping != "":
+			add(entry.typ, def.typeMapping)
+		case def.hasLanguage && def.hasDirection:
+			langDir := "@null"
+			switch {
+			case def.language != "" && def.direction != "":
+				langDir = strings.ToLower(def.language + "_" + def.direction)
+			case def.language != "":
+				langDir = strings.ToLower(def.language)
+			case def.direction != "":
+				langDir = "_" + def.direction
+			}
+			add(entry.language, langDir)
+		case def.hasLanguage:
+			language := "@null"
+			if def.language != "" {
+				language = strings.ToLower(def.language)
+			}
+			add(entry.language, language)
+		case def.hasDirection:
+			direction := "@none"
+			if def.direction != "" {
+				direction = "_" + def.direction
+			}
+			add(entry.language, direction)
+		case c.direction != "":
+			add(entry.language, strings.ToLower(c.language+"_"+c.direction))
+			add(entry.language, "@none")
+			add(entry.typ, "@none")
+		default:
+			add(entry.language, defaultLanguage)
+			add(entry.language, "@none")
+			add(entry.typ, "@none")
+		}
+	}
+	c.inverse = result
+	return result
+}
+
+// selectTerm runs the Term Selection algorithm, returning "" when no term fits
+func (c *jsonldContext) selectTerm(iri string, containers []string, typeLanguage string, preferredValues []string) string {
+	containerMap := c.inverseContext()[iri]
+	for _, container := range containers {
+		entry, ok := containerMap[container]
+		if !ok {
+			continue
+		}
+		values := entry.values(typeLanguage)
+		for _, preferred := range preferredValues {
+			if term, ok := values[preferred]; ok {
+				return term
+			}
+		}
+	}
+	return ""
+}
+
+// compactIRI runs the IRI Compaction algorithm. value is the value the IRI is the property of,
+// used to select a term whose type, language and container fit it; vocab compacts against
+// the vocabulary mapping and terms, otherwise the IRI is made relative to the base IRI.
+func (p *jsonldProcessor) compactIRI(active *jsonldContext, iri string, value any, vocab, reverse bool) (string, error) {
+	if vocab {
+		if _, ok := active.inverseContext()[iri]; ok {
+			if term := p.selectTermForValue(active, iri, value, reverse); term != "" {
+				return term, nil
+			}
+		}
+	}
+
+	if vocab && active.hasVocab && strings.HasPrefix(iri, active.vocab) && len(iri) > len(active.vocab) {
+		if suffix := iri[len(active.vocab):]; active.terms[suffix] == nil {
+			return suffix, nil
+		}
+	}
+
+	// A compact IRI with the shortest, then lexicographically least, prefix
+	compactIRI := ""
+	for term, def := range active.terms {
+		if def == nil || !def.hasID || def.id == iri || !strings.HasPrefix(iri, def.id) || !def.prefix {
+			continue
+		}
+		candidate := term + ":" + iri[len(def.id):]
+		shorter := compactIRI == "" || len(candidate) < len(compactIRI) ||
+			len(candidate) == len(compactIRI) && candidate < compactIRI
+		candidateDef := active.terms[candidate]
+		if shorter && (candidateDef == nil || candidateDef.hasID && candidateDef.id == iri && value == nil) {
+			compactIRI = candidate
+		}
+	}
+	if compactIRI != "" {
+		return compactIRI, nil
+	}
+
+	if scheme, rest, ok := strings.Cut(iri, ":"); ok && !strings.HasPrefix(rest, "//") {
+		if def := active.terms[scheme]; def != nil && def.prefix {
+			return "", jsonldErrorf("IRI confused with prefix", "%s can be mistaken for a compact IRI with prefix %s", iri, scheme)
+		}
+	}
+	if !vocab && p.compactToRelative && active.hasBase && !isKeyword(iri) {
+		return relativeIRI(active.base, iri), nil
+	}
+	return iri, nil
+}
+
+// selectTermForValue works out the containers and type or language a term for the IRI should
+// have to hold the value, following step 4 of the IRI Compaction algorithm, and selects one
+func (p *jsonldProcessor) selectTermForValue(active *jsonldContext, iri string, value any, reverse bool) string {
+	defaultLanguage := "@none"
+	if active.direction != "" {
+		defaultLanguage = strings.ToLower(active.language + "_" + active.direction)
+	} else if active.language != "" {
+		defaultLanguage = strings.ToLower(active.language)
+	}
+
+	object, _ := value.(*jsonObject)
+	if object != nil {
+		if preserve, ok := object.get("@preserve"); ok {
+			if values := asArray(preserve); len(values) > 0 {
+				object, _ = values[0].(*jsonObject)
+			}
+		}
+	}
+
+	var containers []string
+	typeLanguage, typeLanguageValue := "@language", "@null"
+	if object != nil && object.has("@index") && !isGraphObject(object) {
+		containers = append(containers, "@index", "@index@set")
+	}
+
+	switch {
+	case reverse:
+		typeLanguage, typeLanguageValue = "@type", "@reverse"
+		containers = append(containers, "@set")
+
+	case isListObject(object):
+		if !object.has("@index") {
+			containers = append(containers, "@list")
+		}
+		list := asArray(object.value("@list"))
+		commonType, commonLanguage := "", ""
+		if len(list) == 0 {
+			commonLanguage = defaultLanguage
+		}
+		for _, item := range list {
+			itemLanguage, itemType := "@none", "@none"
+			if itemObject, ok := item.(*jsonObject); ok && itemObject.has("@value") {
+				language, hasLanguage := itemObject.value("@language").(string)
+				if direction, ok := itemObject.value("@direction").(string); ok {
+					itemLanguage = strings.ToLower(language + "_" + direction)
+				} else if hasLanguage {
+					itemLanguage = strings.ToLower(language)
+				} else if typ, ok := itemObject.value("@type").(string); ok {
+					itemType = typ
+				} else {
+					itemLanguage = "@null"
+				}
+			} else {
+				itemType = "@id"
+			}
+			if commonLanguage == "" {
+				commonLanguage = itemLanguage
+			} else if itemLanguage != commonLanguage && isValueObject(item) {
+				commonLanguage = "@none"
+			}
+			if commonType == "" {
+				commonType = itemType
+			} else if itemType != commonType {
+				commonType = "@none"
+			}
+			if commonLanguage == "@none" && commonType == "@none" {
+				break
+			}
+		}
+		if commonLanguage == "" {
+			commonLanguage = "@none"
+		}
+		if commonType == "" {
+			commonType = "@none"
+		}
+		if commonType != "@none" {
+			typeLanguage, typeLanguageValue = "@type", commonType
+		} else {
+			typeLanguageValue = commonLanguage
+		}
+
+	case isGraphObject(object):
+		if object.has("@index") {
+			containers = append(containers, "@graph@index", "@graph@index@set")
+		}
+		if object.has("@id") {
+			containers = append(containers, "@graph@id", "@graph@id@set")
+		}
+		containers = append(containers, "@graph", "@graph@set", "@set")
+		if !object.has("@index") {
+			containers = append(containers, "@graph@index", "@graph@index@set")
+		}
+		if !object.has("@id") {
+			containers = append(containers, "@graph@id", "@graph@id@set")
+		}
+		containers = append(containers, "@index", "@index@set")
+		typeLanguage, typeLanguageValue = "@type", "@id"
+
+	default:
+		if isValueObject(object) {
+			language, hasLanguage := object.value("@language").(string)
+			direction, hasDirection := object.value("@direction").(string)
+			switch {
+			case hasDirection && !object.has("@index"):
+				typeLanguageValue = strings.ToLower(language + "_" + direction)
+				containers = append(containers, "@language", "@language@set")
+			case hasLanguage && !object.has("@index"):
+				typeLanguageValue = strings.ToLower(language)
+				containers = append(containers, "@language", "@language@set")
+			default:
+				if typ, ok := object.value("@type").(string); ok {
+					typeLanguage, typeLanguageValue = "@type", typ
+				}
+			}
+		} else {
+			typeLanguage, typeLanguageValue = "@type", "@id"
+			containers = append(containers, "@id", "@id@set", "@type", "@set@type")
+		}
+		containers = append(containers, "@set")
+	}
+
+	containers = append(containers, "@none")
+	if !p.is10() {
+		if object == nil || !object.has("@index") {
+			containers = append(containers, "@index", "@index@set")
+		}
+		if object != nil && object.len() == 1 && object.has("@value") {
+			containers = append(containers, "@language", "@language@set")
+		}
+	}
+
+	var preferredValues []string
+	if typeLanguageValue == "@reverse" {
+		preferredValues = append(preferredValues, "@reverse")
+	}
+	id, hasID := "", false
+	if object != nil {
+		id, hasID = object.value("@id").(string)
+	}
+	if (typeLanguageValue == "@id" || typeLanguageValue == "@reverse") && hasID {
+		compacted, err := p.compactIRI(active, id, nil, true, false)
+		if def := active.terms[compacted]; err == nil && def != nil && def.hasID && def.id == id {
+			preferredValues = append(preferredValues, "@vocab", "@id", "@none")
+		} else {
+			preferredValues = append(preferredValues, "@id", "@vocab", "@none")
+		}
+	} else {
+		preferredValues = append(preferredValues, typeLanguageValue, "@none")
+		if isListObject(object) && len(asArray(object.value("@list"))) == 0 {
+			typeLanguage = "@any"
+		}
+	}
+	preferredValues = append(preferredValues, "@any")
+	for _, preferred := range preferredValues {
+		if i := strings.Index(preferred, "_"); i >= 0 {
+			preferredValues = append(preferredValues, preferred[i:])
+			break
+		}
+	}
+	return active.selectTerm(iri, containers, typeLanguage, preferredValues)
+}
+
+// compactValue runs the Value Compaction algorithm on a value object or node reference
+func (p *jsonldProcessor) compactValue(active *jsonldContext, activeProperty string, value *jsonObject) (any, error) {
+	def := active.terms[activeProperty]
+	language, direction := active.language, active.direction
+	typeMapping := ""
+	var containers []string
+	if def != nil {
+		if def.hasLanguage {
+			language = def.language
+		}
+		if def.hasDirection {
+			direction = def.direction
+		}
+		typeMapping = def.typeMapping
+		containers = def.container
+	}
+	indexable := !value.has("@index") || containsString(containers, "@index")
+
+	var result any = value
+	typ, hasType := value.value("@type").(string)
+	switch {
+	case value.has("@id") && value.onlyKeys("@id", "@index"):
+		id, _ := value.value("@id").(string)
+		switch typeMapping {
+		case "@id":
+			return p.compactIRI(active, id, nil, false, false)
+		case "@vocab":
+			return p.compactIRI(active, id, nil, true, false)
+		}
+
+	case hasType && typ == typeMapping:
+		result = value.value("@value")
+
+	case typeMapping == "@none" || value.has("@type"):
+		if hasType {
+			compactedType, err := p.compactIRI(active, typ, nil, true, false)
+			if err != nil {
+				return nil, err
+			}
+			copied := value.clone()
+			copied.set("@type", compactedType)
+			result = copied
+		}
+
+	default:
+		if _, isString := value.value("@value").(string); !isString {
+			if indexable {
+				result = value.value("@value")
+			}
+			break
+		}
+		valueLanguage, hasLanguage := value.value("@language").(string)
+		valueDirection, hasDirection := value.value("@direction").(string)
+		languageMatches := language == "" && !hasLanguage || language != "" && hasLanguage && strings.EqualFold(valueLanguage, language)
+		directionMatches := direction == "" && !hasDirection || direction != "" && valueDirection == direction
+		if languageMatches && directionMatches && indexable {
+			result = value.value("@value")
+		}
+	}
+
+	object, ok := result.(*jsonObject)
+	if !ok {
+		return result, nil
+	}
+	compacted := newJSONObject()
+	for _, key := range object.keys {
+		alias, err := p.compactIRI(active, key, nil, true, false)
+		if err != nil {
+			return nil, err
+		}
+		compacted.set(alias, object.value(key))
+	}
+	return compacted, nil
+}
+
+// compact runs the Compaction algorithm on an expanded element; activeProperty is "" for null
+func (p *jsonldProcessor) compact(active *jsonldContext, activeProperty string, element any) (any, error) {
+	typeScoped := active
+
+	switch e := element.(type) {
+	case []any:
+		result := []any{}
+		for _, item := range e {
+			compacted, err := p.compact(active, activeProperty, item)
+			if err != nil {
+				return nil, err
+			}
+			if compacted != nil {
+				result = append(result, compacted)
+			}
+		}
+		def := active.terms[activeProperty]
+		if len(result) != 1 || !p.compactArrays || activeProperty == "@graph" || activeProperty == "@set" ||
+			def != nil && (def.hasContainer("@list") || def.hasContainer("@set")) {
+			return result, nil
+		}
+		return result[0], nil
+
+	case *jsonObject:
+		return p.compactObject(active, typeScoped, activeProperty, e)
+
+	default:
+		return element, nil
+	}
+}
+
+// compactObject compacts a map, following steps 5 to 12 of the Compaction algorithm
+func (p *jsonldProcessor) compactObject(active, typeScoped *jsonldContext, activeProperty string, element *jsonObject) (any, error) {
+	// A context that doesn't propagate doesn't apply to new node objects
+	if active.previous != nil && !element.has("@value") && !(element.len() == 1 && element.has("@id")) {
+		active = active.previous
+	}
+	var err error
+	if def := typeScoped.terms[activeProperty]; def != nil && def.hasContext {
+		if active, err = p.processContext(active, def.context, def.baseURL, nil, true, true, true); err != nil {
+			return nil, err
+		}
+	}
+	def := active.terms[activeProperty]
+
+	if element.has("@value") || element.has("@id") {
+		result, err := p.compactValue(active, activeProperty, element)
+		if err != nil {
+			return nil, err
+		}
+		if isScalar(result) || def != nil && def.typeMapping == "@json" {
+			return result, nil
+		}
+	}
+
+	if isListObject(element) && def != nil && def.hasContainer("@list") {
+		return p.compact(active, activeProperty, element.value("@list"))
+	}
+
+	insideReverse := activeProperty == "@reverse"
+	result := newJSONObject()
+
+	// Contexts scoped to the types of the node apply to its properties
+	if types, ok := element.get("@type"); ok {
+		var compactedTypes []string
+		for _, t := range asArray(types) {
+			s, _ := t.(string)
+			compacted, err := p.compactIRI(typeScoped, s, nil, true, false)
+			if err != nil {
+				return nil, err
+			}
+			compactedTypes = append(compactedTypes, compacted)
+		}
+		sort.Strings(compactedTypes)
+		for _, term := range compactedTypes {
+			if typeDef := typeScoped.terms[term]; typeDef != nil && typeDef.hasContext {
+				if active, err = p.processContext(active, typeDef.context, typeDef.baseURL, nil, false, false, true); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	keys := element.keys
+	if p.ordered {
+		keys = element.sortedKeys()
+	}
+	for _, expandedProperty := range keys {
+		expandedValue := element.value(expandedProperty)
+		switch expandedProperty {
+		case "@id":
+			compactedValue := expandedValue
+			if s, ok := expandedValue.(string); ok {
+				if compactedValue, err = p.compactIRI(active, s, nil, false, false); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.setCompacted(active, result, "@id", compactedValue); err != nil {
+				return nil, err
+			}
+			continue
+
+		case "@type":
+			var compactedValue any
+			if s, ok := expandedValue.(string); ok {
+				if compactedValue, err = p.compactIRI(typeScoped, s, nil, true, false); err != nil {
+					return nil, err
+				}
+			} else {
+				compactedTypes := []any{}
+				for _, t := range asArray(expandedValue) {
+					s, _ := t.(string)
+					compacted, err := p.compactIRI(typeScoped, s, nil, true, false)
+					if err != nil {
+						return nil, err
+					}
+					compactedTypes = append(compactedTypes, compacted)
+				}
+				compactedValue = compactedTypes
+			}
+			alias, err := p.compactIRI(active, "@type", nil, true, false)
+			if err != nil {
+				return nil, err
+			}
+			aliasDef := active.terms[alias]
+			forceArray := !p.is10() && aliasDef != nil && aliasDef.hasContainer("@set") || !p.compactArrays
+			addValue(result, alias, compactedValue, forceArray)
+			continue
+
+		case "@reverse":
+			compacted, err := p.compact(active, "@reverse", expandedValue)
+			if err != nil {
+				return nil, err
+			}
+			compactedValue, ok := compacted.(*jsonObject)
+			if !ok {
+				continue
+			}
+			for _, property := range compactedValue.keyList() {
+				if propertyDef := active.terms[property]; propertyDef != nil && propertyDef.reverse {
+					forceArray := propertyDef.hasContainer("@set") || !p.compactArrays
+					addValue(result, property, compactedValue.value(property), forceArray)
+					compactedValue.remove(property)
+				}
+			}
+			if compactedValue.len() > 0 {
+				if err := p.setCompacted(active, result, "@reverse", compactedValue); err != nil {
+					return nil, err
+				}
+			}
+			continue
+
+		case "@preserve":
+			compactedValue, err := p.compact(active, activeProperty, expandedValue)
+			if err != nil {
+				return nil, err
+			}
+			if array, ok := expandedValue.([]any); !ok || len(array) > 0 {
+				result.set("@preserve", compactedValue)
+			}
+			continue
+
+		case "@index":
+			if def != nil && def.hasContainer("@index") {
+				continue
+			}
+			if err := p.setCompacted(active, result, expandedProperty, expandedValue); err != nil {
+				return nil, err
+			}
+			continue
+
+		case "@direction", "@language", "@value":
+			if err := p.setCompacted(active, result, expandedProperty, expandedValue); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if array, ok := expandedValue.([]any); ok && len(array) == 0 {
+			itemActiveProperty, err := p.compactIRI(active, expandedProperty, expandedValue, true, insideReverse)
+			if err != nil {
+				return nil, err
+			}
+			nestResult, err := p.nestResult(active, result, itemActiveProperty)
+			if err != nil {
+				return nil, err
+			}
+			addValue(nestResult, itemActiveProperty, []any{}, true)
+		}
+
+		for _, expandedItem := range asArray(expandedValue) {
+			if err := p.compactItem(active, result, expandedProperty, expandedItem, insideReverse); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// setCompacted sets a keyword entry of a compacted map under the keyword's alias
+func (p *jsonldProcessor) setCompacted(active *jsonldContext, result *jsonObject, keyword string, value any) error {
+	alias, err := p.compactIRI(active, keyword, nil, true, false)
+	if err != nil {
+		return err
+	}
+	result.set(alias, value)
+	return nil
+}
+
+// nestResult returns the map the values of a term go in: the result itself, or the map under
+// the term's nest term
+func (p *jsonldProcessor) nestResult(active *jsonldContext, result *jsonObject, term string) (*jsonObject, error) {
+	def := active.terms[term]
+	if def == nil || def.nest == "" {
+		return result, nil
+	}
+	if def.nest != "@nest" {
+		if expanded, _ := p.expandIRI(active, def.nest, false, true); expanded != "@nest" {
+			return nil, jsonldErrorf("invalid @nest value", "%s does not expand to @nest", def.nest)
+		}
+	}
+	nested, ok := result.value(def.nest).(*jsonObject)
+	if !ok {
+		nested = newJSONObject()
+		result.set(def.nest, nested)
+	}
+	return nested, nil
+}
+
+// mapObject returns the map under a term that index, id, type, language and graph containers
+// keep their values in
+func mapObject(result *jsonObject, term string) *jsonObject {
+	object, ok := result.value(term).(*jsonObject)
+	if !ok {
+		object = newJSONObject()
+		result.set(term, object)
+	}
+	return object
+}
+
+// compactItem compacts one value of an expanded property into result, following step 12.8 of
+// the Compaction algorithm
+func (p *jsonldProcessor) compactItem(active *jsonldContext, result *jsonObject, expandedProperty string, expandedItem any, insideReverse bool) error {
+	itemActiveProperty, err := p.compactIRI(active, expandedProperty, expandedItem, true, insideReverse)
+	if err != nil {
+		return err
+	}
+	nestResult, err := p.nestResult(active, result, itemActiveProperty)
+	if err != nil {
+		return err
+	}
+	def := active.terms[itemActiveProperty]
+	container := func(c string) bool { return def != nil && def.hasContainer(c) }
+	forceArray := container("@set") || itemActiveProperty == "@graph" || itemActiveProperty == "@list" || !p.compactArrays
+	compactIRI := func(iri string, vocab bool) (string, error) {
+		return p.compactIRI(active, iri, nil, vocab, false)
+	}
+
+	item, _ := expandedItem.(*jsonObject)
+	element := expandedItem
+	switch {
+	case isListObject(item):
+		element = item.value("@list")
+	case isGraphObject(item):
+		element = item.value("@graph")
+	}
+	compactedItem, err := p.compact(active, itemActiveProperty, element)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case isListObject(item):
+		compactedItem = asArray(compactedItem)
+		if container("@list") {
+			nestResult.set(itemActiveProperty, compactedItem)
+			return nil
+		}
+		listAlias, err := compactIRI("@list", true)
+		if err != nil {
+			return err
+		}
+		list := newJSONObject()
+		list.set(listAlias, compactedItem)
+		if index, ok := item.get("@index"); ok {
+			indexAlias, err := compactIRI("@index", true)
+			if err != nil {
+				return err
+			}
+			list.set(indexAlias, index)
+		}
+		addValue(nestResult, itemActiveProperty, list, forceArray)
+
+	case isGraphObject(item):
+		id, hasID := item.value("@id").(string)
+		index, hasIndex := item.get("@index")
+		switch {
+		case container("@graph") && container("@id"):
+			mapKey := ""
+			if hasID {
+				mapKey, err = compactIRI(id, false)
+			} else {
+				mapKey, err = compactIRI("@none", true)
+			}
+			if err != nil {
+				return err
+			}
+			addValue(mapObject(nestResult, itemActiveProperty), mapKey, compactedItem, forceArray)
+
+		case container("@graph") && container("@index") && !hasID:
+			mapKey, _ := index.(string)
+			if !hasIndex {
+				mapKey = "@none"
+			}
+			addValue(mapObject(nestResult, itemActiveProperty), mapKey, compactedItem, forceArray)
+
+		case container("@graph") && !hasID:
+			// Several nodes would read as several graphs, so they are kept together with @included
+			if array, ok := compactedItem.([]any); ok && len(array) > 1 {
+				includedAlias, err := compactIRI("@included", true)
+				if err != nil {
+					return err
+				}
+				included := newJSONObject()
+				included.set(includedAlias, compactedItem)
+				compactedItem = included
+			}
+			addValue(nestResult, itemActiveProperty, compactedItem, forceArray)
+
+		default:
+			graphAlias, err := compactIRI("@graph", true)
+			if err != nil {
+				return err
+			}
+			graph := newJSONObject()
+			graph.set(graphAlias, compactedItem)
+			if hasID {
+				idAlias, err := compactIRI("@id", true)
+				if err != nil {
+					return err
+				}
+				compactedID, err := compactIRI(id, false)
+				if err != nil {
+					return err
+				}
+				graph.set(idAlias, compactedID)
+			}
+			if hasIndex {
+				indexAlias, err := compactIRI("@index", true)
+				if err != nil {
+					return err
+				}
+				graph.set(indexAlias, index)
+			}
+			addValue(nestResult, itemActiveProperty, graph, forceArray)
+		}
+
+	case (container("@language") || container("@index") || container("@id") || container("@type")) && !container("@graph"):
+		mapObject := mapObject(nestResult, itemActiveProperty)
+		var containerKeyword string
+		for _, c := range []string{"@language", "@index", "@id", "@type"} {
+			if container(c) {
+				containerKeyword = c
+				break
+			}
+		}
+		containerKey, err := compactIRI(containerKeyword, true)
+		if err != nil {
+			return err
+		}
+		indexKey := "@index"
+		if def.index != "" {
+			indexKey = def.index
+		}
+
+		var mapKey any
+		compactedObject, _ := compactedItem.(*jsonObject)
+		switch {
+		case containerKeyword == "@language":
+			if isValueObject(item) {
+				compactedItem = item.value("@value")
+			}
+			mapKey = item.value("@language")
+
+		case containerKeyword == "@index" && indexKey == "@index":
+			mapKey = item.value("@index")
+
+		case containerKeyword == "@index":
+			expandedIndexKey, _ := p.expandIRI(active, indexKey, false, true)
+			if containerKey, err = compactIRI(expandedIndexKey, true); err != nil {
+				return err
+			}
+			if compactedObject != nil {
+				mapKey = takeFirstValue(compactedObject, containerKey)
+			}
+
+		case containerKeyword == "@id":
+			if compactedObject != nil {
+				mapKey = compactedObject.value(containerKey)
+				compactedObject.remove(containerKey)
+			}
+
+		default: // @type
+			if compactedObject == nil {
+				break
+			}
+			mapKey = takeFirstValue(compactedObject, containerKey)
+			// A node left with only its identifier compacts to a node reference
+			if compactedObject.len() == 1 {
+				if expanded, _ := p.expandIRI(active, compactedObject.keys[0], false, true); expanded == "@id" {
+					reference := newJSONObject()
+					reference.set("@id", item.value("@id"))
+					if compactedItem, err = p.compact(active, itemActiveProperty, reference); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		key, ok := mapKey.(string)
+		if !ok {
+			if key, err = compactIRI("@none", true); err != nil {
+				return err
+			}
+		}
+		addValue(mapObject, key, compactedItem, forceArray)
+
+	default:
+		addValue(nestResult, itemActiveProperty, compactedItem, forceArray)
+	}
+	return nil
+}
+
+// takeFirstValue removes the first string value of an entry and returns it, leaving any other
+// values in place; nil is returned when the first value isn't a string
+func takeFirstValue(object *jsonObject, key string) any {
+	values := asArray(object.value(key))
+	if !object.has(key) || len(values) == 0 {
+		return nil
+	}
+	first, ok := values[0].(string)
+	if !ok {
+		return nil
+	}
+	switch rest := values[1:]; len(rest) {
+	case 0:
+		object.remove(key)
+	case 1:
+		object.set(key, rest[0])
+	default:
+		object.set(key, rest)
+	}
+	return first
+}
+
+// compactDocument runs the compact method of the JSON-LD API on an expanded document. When
+// graph is set, the result is always a map with @graph, even for a single node.
+func (p *jsonldProcessor) compactDocument(expanded []any, context any, base string, graph bool) (*jsonObject, error) {
+	if object, ok := context.(*jsonObject); ok && object.has("@context") {
+		context = object.value("@context")
+	}
+	active, err := p.processContext(newJSONLDContext(base), context, base, nil, false, true, true)
+	if err != nil {
+		return nil, err
+	}
+	compacted, err := p.compact(active, "", expanded)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *jsonObject
+	switch c := compacted.(type) {
+	case *jsonObject:
+		if graph {
+			compacted = []any{c}
+		} else {
+			result = c
+		}
+	case []any:
+		if len(c) == 0 && !graph {
+			result = newJSONObject()
+		}
+	}
+	if result == nil {
+		graphAlias, err := p.compactIRI(active, "@graph", nil, true, false)
+		if err != nil {
+			return nil, err
+		}
+		result = newJSONObject()
+		result.set(graphAlias, asArray(compacted))
+	}
+
+	// The context is written first, without the empty contexts it may include
+	var contexts []any
+	for _, c := range asArray(context) {
+		if object, ok := c.(*jsonObject); c != nil && (!ok || object.len() > 0) {
+			contexts = append(contexts, c)
+		}
+	}
+	if len(contexts) == 0 {
+		return result, nil
+	}
+	withContext := newJSONObject()
+	if len(contexts) == 1 {
+		withContext.set("@context", contexts[0])
+	} else {
+		withContext.set("@context", contexts)
+	}
+	for _, key := range result.keys {
+		withContext.set(key, result.value(key))
+	}
+	return withContext, nil
+}
+
+// relativeIRI writes an IRI relative to a base IRI, keeping it absolute when the two don't
+// share a scheme and authority or the relative form wouldn't resolve back to it
+func relativeIRI(base, iri string) string {
+	b, r := parseIRIReference(base), parseIRIReference(iri)
+	if !b.hasScheme || !r.hasScheme || b.scheme != r.scheme || b.hasAuthority != r.hasAuthority || b.authority != r.authority {
+		return iri
+	}
+
+	var relative string
+	if r.path == b.path && r.hasQuery == b.hasQuery && r.query == b.query && r.hasFragment {
+		relative = "#" + r.fragment
+	} else {
+		// Walk up from the directory of the base path to the longest common one
+		baseSegments := strings.Split(b.path, "/")
+		baseSegments = baseSegments[:len(baseSegments)-1]
+		segments := strings.Split(r.path, "/")
+		common := 0
+		for common < len(baseSegments) && common < len(segments)-1 && baseSegments[common] == segments[common] {
+			common++
+		}
+		relative = strings.Repeat("../", len(baseSegments)-common) + strings.Join(segments[common:], "/")
+		if relative == "" {
+			relative = "./"
+		} else if first, _, _ := strings.Cut(relative, "/"); strings.Contains(first, ":") {
+			relative = "./" + relative
+		}
+		if r.hasQuery {
+			relative += "?" + r.query
+		}
+		if r.hasFragment {
+			relative += "#" + r.fragment
+		}
+	}
+
+	if resolveIRI(base, relative) != iri {
+		return iri
+	}
+	return relative
+}
@@ -0,0 +1,973 @@
+package rdf
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxRemoteContexts bounds how deeply remote contexts may include each other
+const maxRemoteContexts = 32
+
+const (
+	jsonldProcessingMode10 = "json-ld-1.0"
+	jsonldProcessingMode11 = "json-ld-1.1"
+)
+
+// jsonldKeywords are the keywords of JSON-LD 1.1, including those only used in framing
+var jsonldKeywords = map[string]bool{
+	"@base": true, "@container": true, "@context": true, "@default": true, "@direction": true,
+	"@embed": true, "@explicit": true, "@graph": true, "@id": true, "@import": true,
+	"@included": true, "@index": true, "@json": true, "@language": true, "@list": true,
+	"@nest": true, "@none": true, "@omitDefault": true, "@prefix": true, "@preserve": true,
+	"@propagate": true, "@protected": true, "@requireAll": true, "@reverse": true, "@set": true,
+	"@type": true, "@value": true, "@version": true, "@vocab": true,
+}
+
+// keywordForm matches strings that look like keywords; they are ignored when not keywords
+var keywordForm = regexp.MustCompile(`^@[a-zA-Z]+$`)
+
+// absoluteIRIScheme matches the scheme at the start of an absolute IRI
+var absoluteIRIScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+\-.]*:`)
+
+func isKeyword(s string) bool {
+	return jsonldKeywords[s]
+}
+
+func isAbsoluteIRI(s string) bool {
+	return absoluteIRIScheme.MatchString(s)
+}
+
+func isBlankNodeIdentifier(s string) bool {
+	return strings.HasPrefix(s, "_:")
+}
+
+// termDefinition is the definition of a term in an active context
+type termDefinition struct {
+	id           string
+	hasID        bool // false when the term is explicitly mapped to null
+	reverse      bool
+	typeMapping  string
+	language     string
+	hasLanguage  bool // a language mapping to null has hasLanguage set and an empty language
+	direction    string
+	hasDirection bool
+	context      any
+	hasContext   bool
+	baseURL      string
+	nest         string
+	prefix       bool
+	protected    bool
+	index        string
+	container    []string
+}
+
+func (d *termDefinition) hasContainer(container string) bool {
+	for _, c := range d.container {
+		if c == container {
+			return true
+		}
+	}
+	return false
+}
+
+// equalIgnoringProtected compares two definitions apart from their protected flag
+func (d *termDefinition) equalIgnoringProtected(other *termDefinition) bool {
+	if d.id != other.id || d.hasID != other.hasID || d.reverse != other.reverse ||
+		d.typeMapping != other.typeMapping || d.language != other.language ||
+		d.hasLanguage != other.hasLanguage || d.direction != other.direction ||
+		d.hasDirection != other.hasDirection || d.hasContext != other.hasContext ||
+		d.nest != other.nest || d.prefix != other.prefix || d.index != other.index ||
+		len(d.container) != len(other.container) {
+		return false
+	}
+	if d.hasContext && !jsonEqual(d.context, other.context) {
+		return false
+	}
+	for i := range d.container {
+		if d.container[i] != other.container[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonldContext is an active context
+type jsonldContext struct {
+	terms        map[string]*termDefinition
+	base         string
+	hasBase      bool // false when the base IRI is null
+	originalBase string
+	vocab        string
+	hasVocab     bool
+	language     string // default language, "" for none
+	direction    string // default base direction, "" for none
+	previous     *jsonldContext
+	inverse      inverseContext // created when the context is first used for compaction
+}
+
+func newJSONLDContext(base string) *jsonldContext {
+	return &jsonldContext{
+		terms:        make(map[string]*termDefinition),
+		base:         base,
+		hasBase:      base != "",
+		originalBase: base,
+	}
+}
+
+// clone copies the context; term definitions are never changed once created, so they are shared
+func (c *jsonldContext) clone() *jsonldContext {
+	clone := *c
+	clone.inverse = nil
+	clone.terms = make(map[string]*termDefinition, len(c.terms))
+	for term, def := range c.terms {
+		clone.terms[term] = def
+	}
+	return &clone
+}
+
+func (c *jsonldContext) hasProtectedTerms() bool {
+	for _, def := range c.terms {
+		if def.protected {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonldProcessor holds the options and state of one run of the JSON-LD algorithms
+type jsonldProcessor struct {
+	loader         DocumentLoader
+	processingMode string
+	rdfDirection   string
+	contexts       map[string]*remoteContext // dereferenced remote contexts by URL
+	blankNodes     *blankNodeIssuer
+
+	// Compaction options
+	compactArrays     bool // replace arrays of one value with the value
+	compactToRelative bool // write IRIs relative to the base IRI
+	ordered           bool // process the entries of maps in key order
+}
+
+// remoteContext is the @context entry of a dereferenced context document
+type remoteContext struct {
+	documentURL string
+	context     any
+}
+
+func newJSONLDProcessor(loader DocumentLoader, processingMode string) *jsonldProcessor {
+	if processingMode == "" {
+		processingMode = jsonldProcessingMode11
+	}
+	return &jsonldProcessor{
+		loader:            loader,
+		processingMode:    processingMode,
+		contexts:          make(map[string]*remoteContext),
+		blankNodes:        newBlankNodeIssuer("_:b"),
+		compactArrays:     true,
+		compactToRelative: true,
+	}
+}
+
+func (p *jsonldProcessor) is10() bool {
+	return p.processingMode == jsonldProcessingMode10
+}
+
+// processContext runs the Context Processing algorithm, returning a new active context
+func (p *jsonldProcessor) processContext(active *jsonldContext, local any, baseURL string, remote []string, overrideProtected, propagate, validateScoped bool) (*jsonldContext, error) {
+	result := active.clone()
+	if object, ok := local.(*jsonObject); ok {
+		if value, ok := object.get("@propagate"); ok {
+			b, ok := value.(bool)
+			if !ok {
+				return nil, jsonldErrorf("invalid @propagate value", "@propagate must be true or false")
+			}
+			propagate = b
+		}
+	}
+	if !propagate && result.previous == nil {
+		result.previous = active
+	}
+
+	for _, context := range asArray(local) {
+		switch c := context.(type) {
+		case nil:
+			if !overrideProtected && result.hasProtectedTerms() {
+				return nil, jsonldErrorf("invalid context nullification", "a context with protected terms cannot be set to null")
+			}
+			previous := result
+			result = newJSONLDContext(active.originalBase)
+			if !propagate {
+				result.previous = previous
+			}
+			continue
+
+		case string:
+			var err error
+			result, err = p.processRemoteContext(result, c, baseURL, remote, validateScoped)
+			if err != nil {
+				return nil, err
+			}
+			continue
+
+		case *jsonObject:
+			if err := p.processLocalContext(result, c, baseURL, remote, overrideProtected, validateScoped); err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, jsonldErrorf("invalid local context", "a context must be null, a string or an object")
+		}
+	}
+	return result, nil
+}
+
+// processRemoteContext dereferences a context IRI and processes the context it holds
+func (p *jsonldProcessor) processRemoteContext(result *jsonldContext, reference, baseURL string, remote []string, validateScoped bool) (*jsonldContext, error) {
+	url := resolveIRI(baseURL, reference)
+	if !isAbsoluteIRI(url) {
+		return nil, jsonldErrorf("loading document failed", "cannot resolve context %q without a base IRI", reference)
+	}
+	if !validateScoped && containsString(remote, url) {
+		return result, nil
+	}
+	if len(remote) >= maxRemoteContexts {
+		return nil, jsonldErrorf("context overflow", "too many remote contexts from %s", url)
+	}
+	remote = append(append([]string(nil), remote...), url)
+
+	loaded, err := p.loadRemoteContext(url)
+	if err != nil {
+		return nil, err
+	}
+	return p.processContext(result, loaded.context, loaded.documentURL, remote, false, true, validateScoped)
+}
+
+// loadRemoteContext retrieves a context document through the document loader, once per URL
+func (p *jsonldProcessor) loadRemoteContext(url string) (*remoteContext, error) {
+	if loaded, ok := p.contexts[url]; ok {
+		return loaded, nil
+	}
+	if p.loader == nil {
+		return nil, jsonldErrorf("loading remote context failed", "no document loader to load %s", url)
+	}
+	doc, err := p.loader.LoadDocument(url)
+	if err != nil {
+		return nil, jsonldErrorf("loading remote context failed", "%s: %v", url, err)
+	}
+	document, err := decodeJSON(doc.Document)
+	if err != nil {
+		return nil, jsonldErrorf("loading remote context failed", "%s: %v", url, err)
+	}
+	object, ok := document.(*jsonObject)
+	if !ok || !object.has("@context") {
+		return nil, jsonldErrorf("invalid remote context", "%s has no @context entry", url)
+	}
+	documentURL := doc.DocumentURL
+	if documentURL == "" {
+		documentURL = url
+	}
+	loaded := &remoteContext{documentURL: documentURL, context: object.value("@context")}
+	p.contexts[url] = loaded
+	return loaded, nil
+}
+
+// processLocalContext applies a context definition to result
+func (p *jsonldProcessor) processLocalContext(result *jsonldContext, context *jsonObject, baseURL string, remote []string, overrideProtected, validateScoped bool) error {
+	if value, ok := context.get("@version"); ok {
+		if version, ok := value.(float64); !ok || version != 1.1 {
+			return jsonldErrorf("invalid @version value", "@version must be 1.1")
+		}
+		if p.is10() {
+			return jsonldErrorf("processing mode conflict", "@version 1.1 in json-ld-1.0 mode")
+		}
+	}
+
+	if value, ok := context.get("@import"); ok {
+		if p.is10() {
+			return jsonldErrorf("invalid context entry", "@import is not supported in json-ld-1.0 mode")
+		}
+		reference, ok := value.(string)
+		if !ok {
+			return jsonldErrorf("invalid @import value", "@import must be a string")
+		}
+		imported, err := p.loadRemoteContext(resolveIRI(baseURL, reference))
+		if err != nil {
+			return err
+		}
+		importContext, ok := imported.context.(*jsonObject)
+		if !ok {
+			return jsonldErrorf("invalid remote context", "the imported context must be an object")
+		}
+		if importContext.has("@import") {
+			return jsonldErrorf("invalid context entry", "an imported context cannot itself have @import")
+		}
+		merged := importContext.clone()
+		for _, key := range context.keys {
+			merged.set(key, context.value(key))
+		}
+		context = merged
+	}
+
+	if value, ok := context.get("@base"); ok && len(remote) == 0 {
+		switch base := value.(type) {
+		case nil:
+			result.base, result.hasBase = "", false
+		case string:
+			switch {
+			case isAbsoluteIRI(base):
+				result.base, result.hasBase = base, true
+			case result.hasBase:
+				result.base = resolveIRI(result.base, base)
+			default:
+				return jsonldErrorf("invalid base IRI", "relative @base %q without a base IRI", base)
+			}
+		default:
+			return jsonldErrorf("invalid base IRI", "@base must be a string or null")
+		}
+	}
+
+	if value, ok := context.get("@vocab"); ok {
+		switch vocab := value.(type) {
+		case nil:
+			result.vocab, result.hasVocab = "", false
+		case string:
+			expanded, _ := p.expandIRI(result, vocab, true, true)
+			if !isAbsoluteIRI(expanded) && !isBlankNodeIdentifier(expanded) || p.is10() && !isAbsoluteIRI(vocab) && !isBlankNodeIdentifier(vocab) {
+				return jsonldErrorf("invalid vocab mapping", "@vocab %q is not an IRI", vocab)
+			}
+			result.vocab, result.hasVocab = expanded, true
+		default:
+			return jsonldErrorf("invalid vocab mapping", "@vocab must be a string or null")
+		}
+	}
+
+	if value, ok := context.get("@language"); ok {
+		switch language := value.(type) {
+		case nil:
+			result.language = ""
+		case string:
+			result.language = language
+		default:
+			return jsonldErrorf("invalid default language", "@language must be a string or null")
+		}
+	}
+
+	if value, ok := context.get("@direction"); ok {
+		if p.is10() {
+			return jsonldErrorf("invalid context entry", "@direction is not supported in json-ld-1.0 mode")
+		}
+		switch direction := value.(type) {
+		case nil:
+			result.direction = ""
+		case string:
+			if direction != "ltr" && direction != "rtl" {
+				return jsonldErrorf("invalid base direction", "@direction must be \"ltr\" or \"rtl\"")
+			}
+			result.direction = direction
+		default:
+			return jsonldErrorf("invalid base direction", "@direction must be a string or null")
+		}
+	}
+
+	if context.has("@propagate") && p.is10() {
+		return jsonldErrorf("invalid context entry", "@propagate is not supported in json-ld-1.0 mode")
+	}
+
+	protected := false
+	if value, ok := context.get("@protected"); ok {
+		b, ok := value.(bool)
+		if !ok {
+			return jsonldErrorf("invalid @protected value", "@protected must be true or false")
+		}
+		protected = b
+	}
+
+	defined := make(map[string]bool)
+	for _, key := range context.keys {
+		switch key {
+		case "@base", "@direction", "@import", "@language", "@propagate", "@protected", "@version", "@vocab":
+			continue
+		}
+		if err := p.createTermDefinition(result, context, key, defined, baseURL, protected, overrideProtected, remote, validateScoped); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTermDefinition runs the Create Term Definition algorithm for a term of a local context
+func (p *jsonldProcessor) createTermDefinition(active *jsonldContext, local *jsonObject, term string, defined map[string]bool, baseURL string, protected, overrideProtected bool, remote []string, validateScoped bool) error {
+	if done, ok := defined[term]; ok {
+		if done {
+			return nil
+		}
+		return jsonldErrorf("cyclic IRI mapping", "term %q is defined through itself", term)
+	}
+	if term == "" {
+		return jsonldErrorf("invalid term definition", "the empty string cannot be a term")
+	}
+	defined[term] = false
+	value := local.value(term)
+	// Terms this one depends on are defined with the same settings
+	define := func(dependency string) error {
+		if !local.has(dependency) || defined[dependency] {
+			return nil
+		}
+		return p.createTermDefinition(active, local, dependency, defined, baseURL, protected, overrideProtected, remote, validateScoped)
+	}
+
+	if term == "@type" {
+		if p.is10() {
+			return jsonldErrorf("keyword redefinition", "@type cannot be redefined in json-ld-1.0 mode")
+		}
+		object, ok := value.(*jsonObject)
+		if !ok || object.len() == 0 || !object.onlyKeys("@container", "@protected") {
+			return jsonldErrorf("keyword redefinition", "@type can only be given a @set container")
+		}
+		if container, ok := object.get("@container"); ok && container != "@set" {
+			return jsonldErrorf("keyword redefinition", "@type can only be given a @set container")
+		}
+	} else if isKeyword(term) {
+		return jsonldErrorf("keyword redefinition", "keyword %s cannot be redefined", term)
+	} else if keywordForm.MatchString(term) {
+		// Terms that look like keywords are reserved for future use and ignored
+		defined[term] = true
+		return nil
+	}
+
+	previous := active.terms[term]
+	delete(active.terms, term)
+
+	var object *jsonObject
+	simpleTerm := false
+	switch v := value.(type) {
+	case nil:
+		object = newJSONObject()
+		object.set("@id", nil)
+	case string:
+		object = newJSONObject()
+		object.set("@id", v)
+		simpleTerm = true
+	case *jsonObject:
+		object = v
+	default:
+		return jsonldErrorf("invalid term definition", "the definition of %q must be a string or an object", term)
+	}
+
+	def := &termDefinition{protected: protected}
+	if value, ok := object.get("@protected"); ok {
+		if p.is10() {
+			return jsonldErrorf("invalid term definition", "@protected is not supported in json-ld-1.0 mode")
+		}
+		b, ok := value.(bool)
+		if !ok {
+			return jsonldErrorf("invalid @protected value", "@protected must be true or false")
+		}
+		def.protected = b
+	}
+
+	if value, ok := object.get("@type"); ok {
+		typ, ok := value.(string)
+		if !ok {
+			return jsonldErrorf("invalid type mapping", "the @type of %q must be a string", term)
+		}
+		expanded, err := p.expandIRIWithLocal(active, typ, false, true, define)
+		if err != nil {
+			return err
+		}
+		if (expanded == "@json" || expanded == "@none") && p.is10() {
+			return jsonldErrorf("invalid type mapping", "%s is not supported in json-ld-1.0 mode", expanded)
+		}
+		if expanded != "@id" && expanded != "@json" && expanded != "@none" && expanded != "@vocab" && !isAbsoluteIRI(expanded) {
+			return jsonldErrorf("invalid type mapping", "%q is not a valid type mapping", typ)
+		}
+		def.typeMapping = expanded
+	}
+
+	if value, ok := object.get("@reverse"); ok {
+		if object.has("@id") || object.has("@nest") {
+			return jsonldErrorf("invalid reverse property", "a reverse property cannot have @id or @nest")
+		}
+		reverse, ok := value.(string)
+		if !ok {
+			return jsonldErrorf("invalid IRI mapping", "@reverse must be a string")
+		}
+		if keywordForm.MatchString(reverse) && !isKeyword(reverse) {
+			defined[term] = true
+			return nil
+		}
+		expanded, err := p.expandIRIWithLocal(active, reverse, false, true, define)
+		if err != nil {
+			return err
+		}
+		if !isAbsoluteIRI(expanded) && !isBlankNodeIdentifier(expanded) {
+			return jsonldErrorf("invalid IRI mapping", "@reverse %q is not an IRI", reverse)
+		}
+		def.id, def.hasID = expanded, true
+		if value, ok := object.get("@container"); ok {
+			if value != nil && value != "@set" && value != "@index" {
+				return jsonldErrorf("invalid reverse property", "a reverse property can only have a @set or @index container")
+			}
+			if s, ok := value.(string); ok {
+				def.container = []string{s}
+			}
+		}
+		def.reverse = true
+		active.terms[term] = def
+		defined[term] = true
+		return nil
+	}
+
+	idValue, hasIDEntry := object.get("@id")
+	if hasIDEntry && idValue != term {
+		switch id := idValue.(type) {
+		case nil:
+			// The term is explicitly unmapped
+		case string:
+			if !isKeyword(id) && keywordForm.MatchString(id) {
+				defined[term] = true
+				return nil
+			}
+			expanded, err := p.expandIRIWithLocal(active, id, false, true, define)
+			if err != nil {
+				return err
+			}
+			if !isKeyword(expanded) && !isAbsoluteIRI(expanded) && !isBlankNodeIdentifier(expanded) {
+				return jsonldErrorf("invalid IRI mapping", "%q does not expand to an IRI", id)
+			}
+			if expanded == "@context" {
+				return jsonldErrorf("invalid keyword alias", "@context cannot be aliased")
+			}
+			def.id, def.hasID = expanded, true
+
+			// A term that looks like a compact IRI or an IRI must expand to itself
+			if len(term) > 2 && strings.Contains(term[1:len(term)-1], ":") || strings.Contains(term, "/") {
+				defined[term] = true
+				termExpanded, err := p.expandIRIWithLocal(active, term, false, true, define)
+				if err != nil {
+					return err
+				}
+				if termExpanded != expanded {
+					return jsonldErrorf("invalid IRI mapping", "term %q does not expand to %s", term, expanded)
+				}
+			}
+			if !strings.Contains(term, ":") && !strings.Contains(term, "/") && simpleTerm &&
+				(isGenDelim(expanded[len(expanded)-1]) || isBlankNodeIdentifier(expanded)) {
+				def.prefix = true
+			}
+		default:
+			return jsonldErrorf("invalid IRI mapping", "@id must be a string or null")
+		}
+	} else if i := strings.Index(term[1:], ":"); i >= 0 {
+		// A compact IRI or an IRI
+		prefix, suffix := term[:i+1], term[i+2:]
+		if err := define(prefix); err != nil {
+			return err
+		}
+		if prefixDef, ok := active.terms[prefix]; ok && prefixDef.hasID {
+			def.id, def.hasID = prefixDef.id+suffix, true
+		} else {
+			def.id, def.hasID = term, true
+		}
+	} else if strings.Contains(term, "/") {
+		expanded, _ := p.expandIRI(active, term, false, true)
+		if !isAbsoluteIRI(expanded) {
+			return jsonldErrorf("invalid IRI mapping", "term %q does not expand to an IRI", term)
+		}
+		def.id, def.hasID = expanded, true
+	} else if term == "@type" {
+		def.id, def.hasID = "@type", true
+	} else if active.hasVocab {
+		def.id, def.hasID = active.vocab+term, true
+	} else {
+		return jsonldErrorf("invalid IRI mapping", "term %q has no IRI and there is no vocabulary mapping", term)
+	}
+
+	if value, ok := object.get("@container"); ok {
+		containers, err := p.validateContainer(value)
+		if err != nil {
+			return err
+		}
+		def.container = containers
+		if def.hasContainer("@type") {
+			if def.typeMapping == "" {
+				def.typeMapping = "@id"
+			}
+			if def.typeMapping != "@id" && def.typeMapping != "@vocab" {
+				return jsonldErrorf("invalid type mapping", "a @type container requires an @id or @vocab type mapping")
+			}
+		}
+	}
+
+	if value, ok := object.get("@index"); ok {
+		index, ok := value.(string)
+		if p.is10() || !def.hasContainer("@index") {
+			return jsonldErrorf("invalid term definition", "@index requires an @index container")
+		}
+		if !ok || isKeyword(index) {
+			return jsonldErrorf("invalid term definition", "@index must be a string that isn't a keyword")
+		}
+		if expanded, _ := p.expandIRI(active, index, false, true); !isAbsoluteIRI(expanded) {
+			return jsonldErrorf("invalid term definition", "@index %q does not expand to an IRI", index)
+		}
+		def.index = index
+	}
+
+	if value, ok := object.get("@context"); ok {
+		if p.is10() {
+			return jsonldErrorf("invalid term definition", "scoped contexts are not supported in json-ld-1.0 mode")
+		}
+		if _, err := p.processContext(active, value, baseURL, remote, true, true, false); err != nil {
+			return jsonldErrorf("invalid scoped context", "the context of %q: %v", term, err)
+		}
+		def.context, def.hasContext = value, true
+		def.baseURL = baseURL
+	}
+
+	if value, ok := object.get("@language"); ok && !object.has("@type") {
+		switch language := value.(type) {
+		case nil:
+			def.language = ""
+		case string:
+			def.language = language
+		default:
+			return jsonldErrorf("invalid language mapping", "@language must be a string or null")
+		}
+		def.hasLanguage = true
+	}
+
+	if value, ok := object.get("@direction"); ok && !object.has("@type") {
+		switch direction := value.(type) {
+		case nil:
+		case string:
+			if direction != "ltr" && direction != "rtl" {
+				return jsonldErrorf("invalid base direction", "@direction must be \"ltr\" or \"rtl\"")
+			}
+			def.direction = direction
+		default:
+			return jsonldErrorf("invalid base direction", "@direction must be a string or null")
+		}
+		def.hasDirection = true
+	}
+
+	if value, ok := object.get("@nest"); ok {
+		if p.is10() {
+			return jsonldErrorf("invalid term definition", "@nest is not supported in json-ld-1.0 mode")
+		}
+		nest, ok := value.(string)
+		if !ok || isKeyword(nest) && nest != "@nest" {
+			return jsonldErrorf("invalid @nest value", "@nest must be a term or @nest")
+		}
+		def.nest = nest
+	}
+
+	if value, ok := object.get("@prefix"); ok {
+		if p.is10() || strings.Contains(term, ":") || strings.Contains(term, "/") {
+			return jsonldErrorf("invalid term definition", "@prefix can only be set on a simple term")
+		}
+		b, ok := value.(bool)
+		if !ok {
+			return jsonldErrorf("invalid @prefix value", "@prefix must be true or false")
+		}
+		def.prefix = b
+		if b && isKeyword(def.id) {
+			return jsonldErrorf("invalid term definition", "a keyword alias cannot be a prefix")
+		}
+	}
+
+	for _, key := range object.keys {
+		switch key {
+		case "@id", "@reverse", "@container", "@context", "@direction", "@index", "@language",
+			"@nest", "@prefix", "@protected", "@type":
+		default:
+			return jsonldErrorf("invalid term definition", "unexpected %s in the definition of %q", key, term)
+		}
+	}
+
+	if previous != nil && previous.protected && !overrideProtected {
+		if !def.equalIgnoringProtected(previous) {
+			return jsonldErrorf("protected term redefinition", "term %q is protected", term)
+		}
+		def = previous
+	}
+
+	active.terms[term] = def
+	defined[term] = true
+	return nil
+}
+
+// validateContainer checks a @container value and returns it as a list of container keywords
+func (p *jsonldProcessor) validateContainer(value any) ([]string, error) {
+	invalid := jsonldErrorf("invalid container mapping", "invalid @container %v", value)
+	if p.is10() {
+		s, ok := value.(string)
+		if !ok || s == "@graph" || s == "@id" || s == "@type" {
+			return nil, invalid
+		}
+	}
+
+	var containers []string
+	for _, item := range asArray(value) {
+		s, ok := item.(string)
+		if !ok {
+			return nil, invalid
+		}
+		containers = append(containers, s)
+	}
+	if len(containers) == 0 {
+		return nil, invalid
+	}
+	has := func(c string) bool { return containsString(containers, c) }
+
+	if len(containers) == 1 {
+		switch containers[0] {
+		case "@graph", "@id", "@index", "@language", "@list", "@set", "@type":
+			return containers, nil
+		}
+		return nil, invalid
+	}
+	if _, isArray := value.([]any); !isArray {
+		return nil, invalid
+	}
+	if has("@list") {
+		return nil, invalid
+	}
+	// @graph with @id or @index, optionally with @set; or @set with a single other container
+	if has("@graph") {
+		for _, c := range containers {
+			if c != "@graph" && c != "@id" && c != "@index" && c != "@set" {
+				return nil, invalid
+			}
+		}
+		if has("@id") && has("@index") {
+			return nil, invalid
+		}
+		return containers, nil
+	}
+	if len(containers) == 2 && has("@set") {
+		for _, c := range containers {
+			switch c {
+			case "@set", "@index", "@id", "@type", "@language":
+			default:
+				return nil, invalid
+			}
+		}
+		return containers, nil
+	}
+	return nil, invalid
+}
+
+// expandIRI runs IRI Expansion against an active context
+func (p *jsonldProcessor) expandIRI(active *jsonldContext, value string, documentRelative, vocab bool) (string, bool) {
+	expanded, _ := p.expandIRIWithLocal(active, value, documentRelative, vocab, nil)
+	if expanded == "" && value != "" {
+		return "", false
+	}
+	return expanded, true
+}
+
+// expandIRIWithLocal runs IRI Expansion while a local context is processed: define is called
+// for the value and its prefix so that terms of the local context they use are defined first.
+// A value that expands to null is returned as "".
+func (p *jsonldProcessor) expandIRIWithLocal(active *jsonldContext, value string, documentRelative, vocab bool, define func(term string) error) (string, error) {
+	if isKeyword(value) {
+		return value, nil
+	}
+	if keywordForm.MatchString(value) {
+		return "", nil
+	}
+
+	if define != nil {
+		if err := define(value); err != nil {
+			return "", err
+		}
+	}
+
+	if def, ok := active.terms[value]; ok {
+		if def.hasID && isKeyword(def.id) {
+			return def.id, nil
+		}
+		if vocab {
+			if !def.hasID {
+				return "", nil
+			}
+			return def.id, nil
+		}
+	}
+
+	if i := strings.Index(value[min(1, len(value)):], ":"); i >= 0 {
+		prefix, suffix := value[:i+1], value[i+2:]
+		if prefix == "_" || strings.HasPrefix(suffix, "//") {
+			return value, nil
+		}
+		if define != nil {
+			if err := define(prefix); err != nil {
+				return "", err
+			}
+		}
+		if def, ok := active.terms[prefix]; ok && def.hasID && def.prefix {
+			return def.id + suffix, nil
+		}
+		if isAbsoluteIRI(value) {
+			return value, nil
+		}
+	}
+
+	if vocab && active.hasVocab {
+		return active.vocab + value, nil
+	}
+	if documentRelative {
+		if !active.hasBase {
+			return value, nil
+		}
+		return resolveIRI(active.base, value), nil
+	}
+	return value, nil
+}
+
+func isGenDelim(c byte) bool {
+	return strings.IndexByte(":/?#[]@", c) >= 0
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// iriReference is an IRI reference split into its RFC 3986 components
+type iriReference struct {
+	scheme, authority, path, query, fragment       string
+	hasScheme, hasAuthority, hasQuery, hasFragment bool
+}
+
+var iriReferencePattern = regexp.MustCompile(`^(?:([^:/?#]+):)?(?://([^/?#]*))?([^?#]*)(?:\?([^#]*))?(?:#(.*))?$`)
+
+func parseIRIReference(s string) iriReference {
+	m := iriReferencePattern.FindStringSubmatchIndex(s)
+	group := func(n int) (string, bool) {
+		if m == nil || m[2*n] < 0 {
+			return "", false
+		}
+		return s[m[2*n]:m[2*n+1]], true
+	}
+	var ref iriReference
+	ref.scheme, ref.hasScheme = group(1)
+	ref.authority, ref.hasAuthority = group(2)
+	ref.path, _ = group(3)
+	ref.query, ref.hasQuery = group(4)
+	ref.fragment, ref.hasFragment = group(5)
+	return ref
+}
+
+func (r iriReference) String() string {
+	var sb strings.Builder
+	if r.hasScheme {
+		sb.WriteString(r.scheme + ":")
+	}
+	if r.hasAuthority {
+		sb.WriteString("//" + r.authority)
+	}
+	sb.WriteString(r.path)
+	if r.hasQuery {
+		sb.WriteString("?" + r.query)
+	}
+	if r.hasFragment {
+		sb.WriteString("#" + r.fragment)
+	}
+	return sb.String()
+}
+
+// resolveIRI resolves an IRI reference against a base IRI as RFC 3986 section 5.2 describes.
+// The reference is returned unchanged when there is no base.
+func resolveIRI(base, reference string) string {
+	if base == "" {
+		return reference
+	}
+	r := parseIRIReference(reference)
+	if r.hasScheme {
+		r.path = removeDotSegments(r.path)
+		return r.String()
+	}
+	b := parseIRIReference(base)
+	t := iriReference{scheme: b.scheme, hasScheme: b.hasScheme, fragment: r.fragment, hasFragment: r.hasFragment}
+	switch {
+	case r.hasAuthority:
+		t.authority, t.hasAuthority = r.authority, true
+		t.path = removeDotSegments(r.path)
+		t.query, t.hasQuery = r.query, r.hasQuery
+	case r.path == "":
+		t.authority, t.hasAuthority = b.authority, b.hasAuthority
+		t.path = b.path
+		if r.hasQuery {
+			t.query, t.hasQuery = r.query, true
+		} else {
+			t.query, t.hasQuery = b.query, b.hasQuery
+		}
+	default:
+		t.authority, t.hasAuthority = b.authority, b.hasAuthority
+		if strings.HasPrefix(r.path, "/") {
+			t.path = removeDotSegments(r.path)
+		} else {
+			// Merge the reference path with the base path
+			var merged string
+			if b.hasAuthority && b.path == "" {
+				merged = "/" + r.path
+			} else if i := strings.LastIndex(b.path, "/"); i >= 0 {
+				merged = b.path[:i+1] + r.path
+			} else {
+				merged = r.path
+			}
+			t.path = removeDotSegments(merged)
+		}
+		t.query, t.hasQuery = r.query, r.hasQuery
+	}
+	return t.String()
+}
+
+// removeDotSegments removes "." and ".." segments from a path as RFC 3986 section 5.2.4 describes
+func removeDotSegments(path string) string {
+	var output []string
+	for path != "" {
+		switch {
+		case strings.HasPrefix(path, "../"):
+			path = path[3:]
+		case strings.HasPrefix(path, "./"):
+			path = path[2:]
+		case strings.HasPrefix(path, "/./"):
+			path = path[2:]
+		case path == "/.":
+			path = "/"
+		case strings.HasPrefix(path, "/../"):
+			path = path[3:]
+			if len(output) > 0 {
+				output = output[:len(output)-1]
+			}
+		case path == "/..":
+			path = "/"
+			if len(output) > 0 {
+				output = output[:len(output)-1]
+			}
+		case path == "." || path == "..":
+			path = ""
+		default:
+			// Move the first segment, with its leading slash, to the output
+			start := 0
+			if path[0] == '/' {
+				start = 1
+			}
+			end := strings.IndexByte(path[start:], '/')
+			if end < 0 {
+				end = len(path)
+			} else {
+				end += start
+			}
+			output = append(output, path[:end])
+			path = path[end:]
+		}
+	}
+	return strings.Join(output, "")
+}
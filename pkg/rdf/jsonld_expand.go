@@ -0,0 +1,808 @@
+package rdf
+
+import (
+	"sort"
+	"strings"
+)
+
+// expandOptions are the flags of the Expansion algorithm that stay the same while it recurses
+type expandOptions struct {
+	frameExpansion bool
+	ordered        bool
+}
+
+// expandDocument expands a JSON-LD document, returning an array of node objects
+func (p *jsonldProcessor) expandDocument(document any, base string, expandContext any, opts expandOptions) ([]any, error) {
+	active := newJSONLDContext(base)
+	if expandContext != nil {
+		if object, ok := expandContext.(*jsonObject); ok && object.has("@context") {
+			expandContext = object.value("@context")
+		}
+		var err error
+		if active, err = p.processContext(active, expandContext, base, nil, false, true, true); err != nil {
+			return nil, err
+		}
+	}
+
+	expanded, err := p.expand(active, "", document, base, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	// A top-level object with only @graph stands for its contents
+	if object, ok := expanded.(*jsonObject); ok && object.len() == 1 && object.has("@graph") {
+		expanded = object.value("@graph")
+	}
+	if expanded == nil {
+		return []any{}, nil
+	}
+	return asArray(expanded), nil
+}
+
+// expand runs the Expansion algorithm on an element; activeProperty is "" for null
+func (p *jsonldProcessor) expand(active *jsonldContext, activeProperty string, element any, baseURL string, opts expandOptions, fromMap bool) (any, error) {
+	if element == nil {
+		return nil, nil
+	}
+	if activeProperty == "@default" {
+		opts.frameExpansion = false
+	}
+
+	// The context scoped to the active property, if any, is applied to its value
+	propertyDef := active.terms[activeProperty]
+	if propertyDef != nil && !propertyDef.hasContext {
+		propertyDef = nil
+	}
+
+	switch e := element.(type) {
+	case []any:
+		result := []any{}
+		for _, item := range e {
+			expandedItem, err := p.expand(active, activeProperty, item, baseURL, opts, fromMap)
+			if err != nil {
+				return nil, err
+			}
+			if array, ok := expandedItem.([]any); ok && active.terms[activeProperty] != nil && active.terms[activeProperty].hasContainer("@list") {
+				list := newJSONObject()
+				list.set("@list", array)
+				expandedItem = list
+			}
+			if array, ok := expandedItem.([]any); ok {
+				result = append(result, array...)
+			} else if expandedItem != nil {
+				result = append(result, expandedItem)
+			}
+		}
+		return result, nil
+
+	case *jsonObject:
+		return p.expandObject(active, activeProperty, e, baseURL, opts, fromMap, propertyDef)
+
+	default:
+		// A scalar outside of a property is dropped
+		if activeProperty == "" || activeProperty == "@graph" {
+			return nil, nil
+		}
+		if propertyDef != nil {
+			var err error
+			if active, err = p.processContext(active, propertyDef.context, propertyDef.baseURL, nil, false, true, true); err != nil {
+				return nil, err
+			}
+		}
+		return p.expandValue(active, activeProperty, e), nil
+	}
+}
+
+// expandObject expands a map, following steps 7 to 20 of the Expansion algorithm
+func (p *jsonldProcessor) expandObject(active *jsonldContext, activeProperty string, element *jsonObject, baseURL string, opts expandOptions, fromMap bool, propertyDef *termDefinition) (any, error) {
+	// A context that doesn't propagate only applies until a new node object is entered
+	if active.previous != nil && !fromMap {
+		revert := true
+		for _, key := range element.keys {
+			if expanded, _ := p.expandIRI(active, key, false, true); expanded == "@value" {
+				revert = false
+			} else if expanded == "@id" && element.len() == 1 {
+				revert = false
+			}
+		}
+		if revert {
+			active = active.previous
+		}
+	}
+
+	var err error
+	if propertyDef != nil {
+		if active, err = p.processContext(active, propertyDef.context, propertyDef.baseURL, nil, true, true, true); err != nil {
+			return nil, err
+		}
+	}
+	if context, ok := element.get("@context"); ok {
+		if active, err = p.processContext(active, context, baseURL, nil, false, true, true); err != nil {
+			return nil, err
+		}
+	}
+
+	// Contexts scoped to the types of the node apply to its properties but not to the types
+	typeScoped := active
+	var typeKeys []string
+	for _, key := range element.sortedKeys() {
+		if expanded, _ := p.expandIRI(typeScoped, key, false, true); expanded == "@type" {
+			typeKeys = append(typeKeys, key)
+		}
+	}
+	for _, key := range typeKeys {
+		var types []string
+		for _, value := range asArray(element.value(key)) {
+			if s, ok := value.(string); ok {
+				types = append(types, s)
+			}
+		}
+		sort.Strings(types)
+		for _, term := range types {
+			if def, ok := typeScoped.terms[term]; ok && def.hasContext {
+				if active, err = p.processContext(active, def.context, def.baseURL, nil, false, false, true); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	inputType := ""
+	if len(typeKeys) > 0 {
+		if types := asArray(element.value(typeKeys[0])); len(types) > 0 {
+			if last, ok := types[len(types)-1].(string); ok {
+				inputType, _ = p.expandIRI(active, last, false, true)
+			}
+		}
+	}
+
+	result := newJSONObject()
+	if err := p.expandEntries(active, typeScoped, activeProperty, element, result, inputType, baseURL, opts); err != nil {
+		return nil, err
+	}
+
+	if value, ok := result.get("@value"); ok {
+		if !result.onlyKeys("@direction", "@index", "@language", "@type", "@value") ||
+			result.has("@type") && (result.has("@language") || result.has("@direction")) {
+			return nil, jsonldErrorf("invalid value object", "a value object has unexpected entries")
+		}
+		typ := result.value("@type")
+		if typ == "@json" {
+			return result, nil
+		}
+		if array, isArray := value.([]any); value == nil || isArray && len(array) == 0 {
+			return nil, nil
+		}
+		if _, isString := value.(string); !isString && result.has("@language") && !opts.frameExpansion {
+			return nil, jsonldErrorf("invalid language-tagged value", "a language-tagged value must be a string")
+		}
+		if typ != nil && !opts.frameExpansion {
+			if s, ok := typ.(string); !ok || !isAbsoluteIRI(s) {
+				return nil, jsonldErrorf("invalid typed value", "the type of a value must be an IRI")
+			}
+		}
+	} else if typ, ok := result.get("@type"); ok {
+		if _, isArray := typ.([]any); !isArray {
+			result.set("@type", []any{typ})
+		}
+	} else if result.has("@set") || result.has("@list") {
+		if result.len() > 2 || result.len() == 2 && !result.has("@index") {
+			return nil, jsonldErrorf("invalid set or list object", "a set or list object can only also have @index")
+		}
+		if set, ok := result.get("@set"); ok {
+			return p.dropFreeFloating(activeProperty, set, opts), nil
+		}
+	}
+
+	if result.len() == 1 && result.has("@language") {
+		return nil, nil
+	}
+	return p.dropFreeFloating(activeProperty, result, opts), nil
+}
+
+// dropFreeFloating removes values, lists and bare node references that aren't the value of a property
+// outside of frames, where an empty map matches any node
+func (p *jsonldProcessor) dropFreeFloating(activeProperty string, result any, opts expandOptions) any {
+	if activeProperty != "" && activeProperty != "@graph" || opts.frameExpansion {
+		return result
+	}
+	object, ok := result.(*jsonObject)
+	if !ok {
+		return result
+	}
+	if object.len() == 0 || object.has("@value") || object.has("@list") {
+		return nil
+	}
+	if object.len() == 1 && object.has("@id") {
+		return nil
+	}
+	return result
+}
+
+// expandEntries expands the entries of a map into result, following steps 13 and 14 of the
+// Expansion algorithm; nested properties are expanded into the same result
+func (p *jsonldProcessor) expandEntries(active, typeScoped *jsonldContext, activeProperty string, element, result *jsonObject, inputType, baseURL string, opts expandOptions) error {
+	keys := element.keys
+	if opts.ordered {
+		keys = element.sortedKeys()
+	}
+	var nests []string
+
+	for _, key := range keys {
+		value := element.value(key)
+		if key == "@context" {
+			continue
+		}
+		expandedProperty, ok := p.expandIRI(active, key, false, true)
+		if !ok || !isKeyword(expandedProperty) && !strings.Contains(expandedProperty, ":") {
+			continue
+		}
+
+		if isKeyword(expandedProperty) {
+			if activeProperty == "@reverse" {
+				return jsonldErrorf("invalid reverse property map", "a reverse property map cannot contain %s", expandedProperty)
+			}
+			if result.has(expandedProperty) && expandedProperty != "@included" && !(expandedProperty == "@type" && !p.is10()) {
+				return jsonldErrorf("colliding keywords", "%s is used more than once", expandedProperty)
+			}
+			if expandedProperty == "@nest" {
+				nests = append(nests, key)
+				continue
+			}
+			if err := p.expandKeyword(active, typeScoped, activeProperty, expandedProperty, value, result, inputType, baseURL, opts); err != nil {
+				return err
+			}
+			continue
+		}
+
+		def := active.terms[key]
+		var expandedValue any
+		switch object, isObject := value.(*jsonObject); {
+		case def != nil && def.typeMapping == "@json":
+			v := newJSONObject()
+			v.set("@value", value)
+			v.set("@type", "@json")
+			expandedValue = v
+
+		case isObject && def != nil && def.hasContainer("@language"):
+			var err error
+			if expandedValue, err = p.expandLanguageMap(active, def, object, opts); err != nil {
+				return err
+			}
+
+		case isObject && def != nil && (def.hasContainer("@index") || def.hasContainer("@type") || def.hasContainer("@id")):
+			var err error
+			if expandedValue, err = p.expandIndexMap(active, key, def, object, baseURL, opts); err != nil {
+				return err
+			}
+
+		default:
+			var err error
+			if expandedValue, err = p.expand(active, key, value, baseURL, opts, false); err != nil {
+				return err
+			}
+		}
+		if expandedValue == nil {
+			continue
+		}
+
+		if def != nil && def.hasContainer("@list") && !isListObject(expandedValue) {
+			list := newJSONObject()
+			list.set("@list", asArray(expandedValue))
+			expandedValue = list
+		}
+		if def != nil && def.hasContainer("@graph") && !def.hasContainer("@id") && !def.hasContainer("@index") {
+			var graphs []any
+			for _, item := range asArray(expandedValue) {
+				graph := newJSONObject()
+				graph.set("@graph", asArray(item))
+				graphs = append(graphs, graph)
+			}
+			expandedValue = graphs
+		}
+
+		if def != nil && def.reverse {
+			reverseMap, ok := result.value("@reverse").(*jsonObject)
+			if !ok {
+				reverseMap = newJSONObject()
+				result.set("@reverse", reverseMap)
+			}
+			for _, item := range asArray(expandedValue) {
+				if isValueObject(item) || isListObject(item) {
+					return jsonldErrorf("invalid reverse property value", "the value of a reverse property must be a node")
+				}
+				addValue(reverseMap, expandedProperty, item, true)
+			}
+			continue
+		}
+		addValue(result, expandedProperty, expandedValue, true)
+	}
+
+	if opts.ordered {
+		sort.Strings(nests)
+	}
+	for _, nestingKey := range nests {
+		for _, nestedValue := range asArray(element.value(nestingKey)) {
+			nested, ok := nestedValue.(*jsonObject)
+			if !ok {
+				return jsonldErrorf("invalid @nest value", "the value of @nest must be a map")
+			}
+			for _, key := range nested.keys {
+				if expanded, _ := p.expandIRI(active, key, false, true); expanded == "@value" {
+					return jsonldErrorf("invalid @nest value", "a nested map cannot contain @value")
+				}
+			}
+			if err := p.expandEntries(active, typeScoped, activeProperty, nested, result, inputType, baseURL, opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expandKeyword expands the value of a keyword entry into result
+func (p *jsonldProcessor) expandKeyword(active, typeScoped *jsonldContext, activeProperty, keyword string, value any, result *jsonObject, inputType, baseURL string, opts expandOptions) error {
+	var expandedValue any
+	switch keyword {
+	case "@id":
+		switch v := value.(type) {
+		case string:
+			if expanded, ok := p.expandIRI(active, v, true, false); ok {
+				expandedValue = expanded
+			}
+		case *jsonObject:
+			if !opts.frameExpansion || v.len() != 0 {
+				return jsonldErrorf("invalid @id value", "@id must be a string")
+			}
+			expandedValue = []any{v}
+		case []any:
+			if !opts.frameExpansion {
+				return jsonldErrorf("invalid @id value", "@id must be a string")
+			}
+			ids := []any{}
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return jsonldErrorf("invalid @id value", "@id must be a string")
+				}
+				expanded, _ := p.expandIRI(active, s, true, false)
+				ids = append(ids, expanded)
+			}
+			expandedValue = ids
+		default:
+			return jsonldErrorf("invalid @id value", "@id must be a string")
+		}
+		if opts.frameExpansion {
+			expandedValue = asArray(expandedValue)
+		}
+
+	case "@type":
+		switch v := value.(type) {
+		case string:
+			if expanded, ok := p.expandIRI(typeScoped, v, true, true); ok {
+				expandedValue = expanded
+			}
+		case []any:
+			types := []any{}
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return jsonldErrorf("invalid type value", "@type must be a string or an array of strings")
+				}
+				if expanded, ok := p.expandIRI(typeScoped, s, true, true); ok {
+					types = append(types, expanded)
+				}
+			}
+			expandedValue = types
+		case *jsonObject:
+			if !opts.frameExpansion {
+				return jsonldErrorf("invalid type value", "@type must be a string or an array of strings")
+			}
+			if def, ok := v.get("@default"); ok && v.len() == 1 {
+				s, ok := def.(string)
+				if !ok {
+					return jsonldErrorf("invalid type value", "@default must be a string")
+				}
+				expanded, _ := p.expandIRI(typeScoped, s, true, true)
+				object := newJSONObject()
+				object.set("@default", expanded)
+				expandedValue = object
+			} else if v.len() == 0 {
+				expandedValue = v
+			} else {
+				return jsonldErrorf("invalid type value", "@type must be a string or an array of strings")
+			}
+		default:
+			return jsonldErrorf("invalid type value", "@type must be a string or an array of strings")
+		}
+		if existing, ok := result.get("@type"); ok {
+			expandedValue = append(append([]any{}, asArray(existing)...), asArray(expandedValue)...)
+		}
+
+	case "@graph":
+		expanded, err := p.expand(active, "@graph", value, baseURL, opts, false)
+		if err != nil {
+			return err
+		}
+		if expanded == nil {
+			expanded = []any{}
+		}
+		expandedValue = asArray(expanded)
+
+	case "@included":
+		if p.is10() {
+			return nil
+		}
+		expanded, err := p.expand(active, "", value, baseURL, opts, false)
+		if err != nil {
+			return err
+		}
+		if expanded == nil {
+			expanded = []any{}
+		}
+		included := asArray(expanded)
+		for _, item := range included {
+			if !isNodeObject(item) {
+				return jsonldErrorf("invalid @included value", "@included must contain node objects")
+			}
+		}
+		if existing, ok := result.get("@included"); ok {
+			included = append(append([]any{}, asArray(existing)...), included...)
+		}
+		expandedValue = included
+
+	case "@value":
+		if inputType == "@json" {
+			if p.is10() {
+				return jsonldErrorf("invalid value object value", "JSON literals are not supported in json-ld-1.0 mode")
+			}
+			expandedValue = value
+			break
+		}
+		if value != nil && !isScalar(value) && !(opts.frameExpansion && isScalarArrayOrEmptyMap(value)) {
+			return jsonldErrorf("invalid value object value", "@value must be a scalar or null")
+		}
+		expandedValue = value
+		if opts.frameExpansion {
+			expandedValue = asArray(value)
+		}
+		result.set("@value", expandedValue)
+		return nil
+
+	case "@language":
+		s, ok := value.(string)
+		if !ok && !(opts.frameExpansion && isScalarArrayOrEmptyMap(value)) {
+			return jsonldErrorf("invalid language-tagged string", "@language must be a string")
+		}
+		expandedValue = value
+		if ok && opts.frameExpansion {
+			expandedValue = []any{s}
+		}
+
+	case "@direction":
+		if p.is10() {
+			return nil
+		}
+		if value != "ltr" && value != "rtl" && !(opts.frameExpansion && isScalarArrayOrEmptyMap(value)) {
+			return jsonldErrorf("invalid base direction", "@direction must be \"ltr\" or \"rtl\"")
+		}
+		expandedValue = value
+
+	case "@index":
+		if _, ok := value.(string); !ok {
+			return jsonldErrorf("invalid @index value", "@index must be a string")
+		}
+		expandedValue = value
+
+	case "@list":
+		if activeProperty == "" || activeProperty == "@graph" {
+			return nil
+		}
+		items, err := p.expandListItems(active, activeProperty, value, baseURL, opts)
+		if err != nil {
+			return err
+		}
+		expandedValue = items
+
+	case "@set":
+		expanded, err := p.expand(active, activeProperty, value, baseURL, opts, false)
+		if err != nil {
+			return err
+		}
+		expandedValue = expanded
+
+	case "@reverse":
+		if _, ok := value.(*jsonObject); !ok {
+			return jsonldErrorf("invalid @reverse value", "@reverse must be a map")
+		}
+		expanded, err := p.expand(active, "@reverse", value, baseURL, opts, false)
+		if err != nil {
+			return err
+		}
+		reverse, ok := expanded.(*jsonObject)
+		if !ok {
+			return nil
+		}
+		// A reverse of a reverse property is a forward property
+		if nested, ok := reverse.value("@reverse").(*jsonObject); ok {
+			for _, property := range nested.keys {
+				addValue(result, property, nested.value(property), true)
+			}
+		}
+		for _, property := range reverse.keys {
+			if property == "@reverse" {
+				continue
+			}
+			reverseMap, ok := result.value("@reverse").(*jsonObject)
+			if !ok {
+				reverseMap = newJSONObject()
+				result.set("@reverse", reverseMap)
+			}
+			for _, item := range asArray(reverse.value(property)) {
+				if isValueObject(item) || isListObject(item) {
+					return jsonldErrorf("invalid reverse property value", "the value of a reverse property must be a node")
+				}
+				addValue(reverseMap, property, item, true)
+			}
+		}
+		return nil
+
+	case "@default", "@embed", "@explicit", "@omitDefault", "@requireAll":
+		if !opts.frameExpansion {
+			return nil
+		}
+		if keyword != "@default" {
+			// Framing flags are kept as they are
+			expandedValue = asArray(value)
+			break
+		}
+		expanded, err := p.expand(active, keyword, value, baseURL, opts, false)
+		if err != nil {
+			return err
+		}
+		expandedValue = expanded
+
+	default:
+		// Other keywords, such as @context inside a value, are ignored
+		return nil
+	}
+
+	// A null @set is kept so that the set object expands to null
+	if expandedValue != nil || keyword == "@set" {
+		result.set(keyword, expandedValue)
+	}
+	return nil
+}
+
+// expandListItems expands the value of @list; an array among the items is a list of its own
+func (p *jsonldProcessor) expandListItems(active *jsonldContext, activeProperty string, value any, baseURL string, opts expandOptions) ([]any, error) {
+	items := []any{}
+	for _, item := range asArray(value) {
+		if array, ok := item.([]any); ok {
+			nested, err := p.expandListItems(active, activeProperty, array, baseURL, opts)
+			if err != nil {
+				return nil, err
+			}
+			list := newJSONObject()
+			list.set("@list", nested)
+			items = append(items, list)
+			continue
+		}
+		expanded, err := p.expand(active, activeProperty, item, baseURL, opts, false)
+		if err != nil {
+			return nil, err
+		}
+		if array, ok := expanded.([]any); ok {
+			items = append(items, array...)
+		} else if expanded != nil {
+			items = append(items, expanded)
+		}
+	}
+	return items, nil
+}
+
+// expandLanguageMap expands the value of a property with a @language container
+func (p *jsonldProcessor) expandLanguageMap(active *jsonldContext, def *termDefinition, languageMap *jsonObject, opts expandOptions) ([]any, error) {
+	expanded := []any{}
+	direction := active.direction
+	if def.hasDirection {
+		direction = def.direction
+	}
+	keys := languageMap.keys
+	if opts.ordered {
+		keys = languageMap.sortedKeys()
+	}
+	for _, language := range keys {
+		for _, item := range asArray(languageMap.value(language)) {
+			if item == nil {
+				continue
+			}
+			s, ok := item.(string)
+			if !ok {
+				return nil, jsonldErrorf("invalid language map value", "the values of a language map must be strings")
+			}
+			v := newJSONObject()
+			v.set("@value", s)
+			if expanded, _ := p.expandIRI(active, language, false, true); language != "@none" && expanded != "@none" {
+				v.set("@language", language)
+			}
+			if direction != "" {
+				v.set("@direction", direction)
+			}
+			expanded = append(expanded, v)
+		}
+	}
+	return expanded, nil
+}
+
+// expandIndexMap expands the value of a property with an @index, @id or @type container
+func (p *jsonldProcessor) expandIndexMap(active *jsonldContext, key string, def *termDefinition, indexMap *jsonObject, baseURL string, opts expandOptions) ([]any, error) {
+	expanded := []any{}
+	indexKey := "@index"
+	if def.index != "" {
+		indexKey = def.index
+	}
+	byID, byType := def.hasContainer("@id"), def.hasContainer("@type")
+
+	keys := indexMap.keys
+	if opts.ordered {
+		keys = indexMap.sortedKeys()
+	}
+	for _, index := range keys {
+		// Node identifiers and types are in the scope of the context outside the property
+		mapContext := active
+		if (byID || byType) && active.previous != nil {
+			mapContext = active.previous
+		}
+		if indexDef, ok := mapContext.terms[index]; ok && byType && indexDef.hasContext {
+			var err error
+			if mapContext, err = p.processContext(mapContext, indexDef.context, indexDef.baseURL, nil, false, true, true); err != nil {
+				return nil, err
+			}
+		}
+
+		expandedIndex, _ := p.expandIRI(active, index, false, true)
+		items, err := p.expand(mapContext, key, asArray(indexMap.value(index)), baseURL, opts, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range asArray(items) {
+			if item == nil {
+				continue
+			}
+			if def.hasContainer("@graph") && !isGraphObject(item) {
+				graph := newJSONObject()
+				graph.set("@graph", asArray(item))
+				item = graph
+			}
+			object, ok := item.(*jsonObject)
+			if !ok {
+				continue
+			}
+			switch {
+			case def.hasContainer("@index") && indexKey != "@index" && expandedIndex != "@none":
+				reExpanded := p.expandValue(active, indexKey, index)
+				expandedIndexKey, _ := p.expandIRI(active, indexKey, false, true)
+				values := []any{reExpanded}
+				if existing, ok := object.get(expandedIndexKey); ok {
+					values = append(values, asArray(existing)...)
+				}
+				object.set(expandedIndexKey, values)
+				if object.has("@value") {
+					return nil, jsonldErrorf("invalid value object", "a value object cannot have property %s", expandedIndexKey)
+				}
+			case def.hasContainer("@index") && !object.has("@index") && expandedIndex != "@none":
+				object.set("@index", index)
+			case byID && !object.has("@id") && expandedIndex != "@none":
+				id, _ := p.expandIRI(active, index, true, false)
+				object.set("@id", id)
+			case byType && expandedIndex != "@none":
+				types := []any{expandedIndex}
+				if existing, ok := object.get("@type"); ok {
+					types = append(types, asArray(existing)...)
+				}
+				object.set("@type", types)
+			}
+			expanded = append(expanded, object)
+		}
+	}
+	return expanded, nil
+}
+
+// expandValue runs the Value Expansion algorithm for a scalar value of a property
+func (p *jsonldProcessor) expandValue(active *jsonldContext, activeProperty string, value any) *jsonObject {
+	def := active.terms[activeProperty]
+	result := newJSONObject()
+	if s, ok := value.(string); ok && def != nil && (def.typeMapping == "@id" || def.typeMapping == "@vocab") {
+		if expanded, ok := p.expandIRI(active, s, true, def.typeMapping == "@vocab"); ok {
+			result.set("@id", expanded)
+		} else {
+			result.set("@id", nil)
+		}
+		return result
+	}
+
+	result.set("@value", value)
+	if def != nil && def.typeMapping != "" && def.typeMapping != "@id" && def.typeMapping != "@vocab" && def.typeMapping != "@none" {
+		result.set("@type", def.typeMapping)
+	} else if _, ok := value.(string); ok {
+		language, direction := active.language, active.direction
+		if def != nil && def.hasLanguage {
+			language = def.language
+		}
+		if def != nil && def.hasDirection {
+			direction = def.direction
+		}
+		if language != "" {
+			result.set("@language", language)
+		}
+		if direction != "" {
+			result.set("@direction", direction)
+		}
+	}
+	return result
+}
+
+// addValue adds a value to an entry of an object, keeping the entry an array when asArray is set
+func addValue(object *jsonObject, key string, value any, asArray bool) {
+	if array, ok := value.([]any); ok {
+		if asArray && !object.has(key) {
+			object.set(key, []any{})
+		}
+		for _, item := range array {
+			addValue(object, key, item, asArray)
+		}
+		return
+	}
+	existing, ok := object.get(key)
+	if !ok {
+		if asArray {
+			object.set(key, []any{value})
+		} else {
+			object.set(key, value)
+		}
+		return
+	}
+	if array, ok := existing.([]any); ok {
+		object.set(key, append(array, value))
+	} else {
+		object.set(key, []any{existing, value})
+	}
+}
+
+func isValueObject(value any) bool {
+	object, ok := value.(*jsonObject)
+	return ok && object != nil && object.has("@value")
+}
+
+func isListObject(value any) bool {
+	object, ok := value.(*jsonObject)
+	return ok && object != nil && object.has("@list")
+}
+
+// isGraphObject reports whether a value is a map with @graph and at most @id and @index beside it
+func isGraphObject(value any) bool {
+	object, ok := value.(*jsonObject)
+	return ok && object != nil && object.has("@graph") && object.onlyKeys("@graph", "@id", "@index")
+}
+
+func isNodeObject(value any) bool {
+	object, ok := value.(*jsonObject)
+	return ok && !object.has("@value") && !object.has("@list") && !object.has("@set")
+}
+
+// isScalarArrayOrEmptyMap reports whether a value is an empty map or an array of scalars,
+// the other forms frame expansion allows for value object entries
+func isScalarArrayOrEmptyMap(value any) bool {
+	switch v := value.(type) {
+	case *jsonObject:
+		return v.len() == 0
+	case []any:
+		for _, item := range v {
+			if !isScalar(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
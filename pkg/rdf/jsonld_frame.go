@@ -0,0 +1,666 @@
+package rdf
+
+import (
+	"sort"
+	"strings"
+)
+
+// framingOptions are the framing flags used where a frame doesn't set its own
+type framingOptions struct {
+	embed       string // "@once" (the default), "@always" or "@never"
+	explicit    bool
+	requireAll  bool
+	omitDefault bool
+	omitGraph   *bool // nil for the default of the processing mode
+}
+
+// framingState is the state shared by the recursive calls of the Framing algorithm
+type framingState struct {
+	opts         framingOptions
+	graphMap     *jsonObject                // graph name to its nodes by @id
+	subjects     *jsonObject                // nodes of the graph being framed
+	uniqueEmbeds map[string]map[string]bool // graph name to the nodes embedded so far
+	subjectStack []framedSubject            // nodes being embedded, to detect cycles
+	bnodeCount   map[string]int             // times each blank node identifier is written
+}
+
+// framedSubject is a node on the subject stack of the Framing algorithm
+type framedSubject struct {
+	id    string
+	graph string
+}
+
+// frameFlags are the flags in effect for one frame
+type frameFlags struct {
+	embed      string
+	explicit   bool
+	requireAll bool
+}
+
+// frameDocument runs the frame method of the JSON-LD API: the expanded input is matched
+// against the frame and the framed result compacted with the frame's context
+func (p *jsonldProcessor) frameDocument(input []any, frame any, base string, opts framingOptions) (*jsonObject, error) {
+	expandedFrame, err := p.expandDocument(frame, base, nil, expandOptions{frameExpansion: true, ordered: p.ordered})
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a @graph entry in the frame, the nodes of all graphs are merged and framed
+	// together; with one, the default graph is framed
+	var context any
+	merged := true
+	if object, ok := frame.(*jsonObject); ok {
+		context = object.value("@context")
+		active, err := p.processContext(newJSONLDContext(base), context, base, nil, false, true, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range object.keys {
+			if expanded, _ := p.expandIRI(active, key, false, true); expanded == "@graph" {
+				merged = false
+			}
+		}
+	}
+
+	nodes := newNodeMap()
+	if err := p.generateNodeMap(input, nodes, "@default", nil, "", nil); err != nil {
+		return nil, err
+	}
+	graph := "@default"
+	if merged {
+		nodes.graphs.set("@merged", mergeNodeMaps(nodes.graphs))
+		graph = "@merged"
+	}
+	state := &framingState{
+		opts:       opts,
+		graphMap:   nodes.graphs,
+		subjects:   nodes.graph(graph),
+		bnodeCount: make(map[string]int),
+	}
+	if state.opts.embed == "" {
+		state.opts.embed = "@once"
+	}
+
+	holder := newJSONObject()
+	if err := p.frame(state, state.subjects.sortedKeys(), expandedFrame, holder, "", graph, false); err != nil {
+		return nil, err
+	}
+
+	// Blank node identifiers written only once are dropped, as nothing refers to them
+	clear := make(map[string]bool)
+	if !p.is10() {
+		for id, count := range state.bnodeCount {
+			if count == 1 {
+				clear[id] = true
+			}
+		}
+	}
+	framed := []any{}
+	if values, ok := holder.get(""); ok {
+		framed = asArray(cleanupPreserve(values, clear))
+	}
+
+	omitGraph := !p.is10()
+	if opts.omitGraph != nil {
+		omitGraph = *opts.omitGraph
+	}
+	compacted, err := p.compactDocument(framed, context, base, !omitGraph)
+	if err != nil {
+		return nil, err
+	}
+	cleanupNull(compacted)
+	return compacted, nil
+}
+
+// frame runs the Framing algorithm, adding the nodes of subjects matching the frame to the
+// property of parent; embedded is set when the nodes are the values of another node
+func (p *jsonldProcessor) frame(state *framingState, subjects []string, frame []any, parent *jsonObject, property, graph string, embedded bool) error {
+	frameObject, err := validateFrame(frame)
+	if err != nil {
+		return err
+	}
+	flags := frameFlags{
+		explicit:   boolFrameFlag(frameObject, "@explicit", state.opts.explicit),
+		requireAll: boolFrameFlag(frameObject, "@requireAll", state.opts.requireAll),
+	}
+	if flags.embed, err = embedFrameFlag(frameObject, state.opts.embed); err != nil {
+		return err
+	}
+
+	nodes, _ := state.graphMap.value(graph).(*jsonObject)
+	var matches []string
+	for _, id := range subjects {
+		subject, ok := nodes.value(id).(*jsonObject)
+		if !ok {
+			continue
+		}
+		match, err := p.filterSubject(state, subject, frameObject, flags)
+		if err != nil {
+			return err
+		}
+		if match {
+			matches = append(matches, id)
+		}
+	}
+	sort.Strings(matches)
+
+	for _, id := range matches {
+		subject := nodes.value(id).(*jsonObject)
+		if property == "" {
+			state.uniqueEmbeds = map[string]map[string]bool{graph: {}}
+		} else if state.uniqueEmbeds[graph] == nil {
+			state.uniqueEmbeds[graph] = make(map[string]bool)
+		}
+
+		output := newJSONObject()
+		output.set("@id", id)
+		if isBlankNodeIdentifier(id) {
+			state.bnodeCount[id]++
+		}
+
+		// A node already embedded elsewhere isn't repeated at the top of a graph, and a node
+		// that can't be embedded here is written as a reference
+		if !embedded && state.uniqueEmbeds[graph][id] {
+			continue
+		}
+		if embedded && (flags.embed == "@never" || state.createsCircularReference(id, graph)) ||
+			embedded && flags.embed == "@once" && state.uniqueEmbeds[graph][id] {
+			addValue(parent, property, output, true)
+			continue
+		}
+		state.uniqueEmbeds[graph][id] = true
+		state.subjectStack = append(state.subjectStack, framedSubject{id: id, graph: graph})
+
+		// The node also names a graph, which is framed into its @graph
+		if graphNodes, ok := state.graphMap.value(id).(*jsonObject); ok {
+			recurse := graph != "@merged"
+			var subframe any = newJSONObject()
+			if graphFrame, ok := frameObject.get("@graph"); ok {
+				recurse = id != "@merged" && id != "@default"
+				if values := asArray(graphFrame); len(values) > 0 {
+					if object, ok := values[0].(*jsonObject); ok {
+						subframe = object
+					}
+				}
+			}
+			if recurse {
+				if err := p.frame(state, graphNodes.sortedKeys(), []any{subframe}, output, "@graph", id, false); err != nil {
+					return err
+				}
+			}
+		}
+
+		if included, ok := frameObject.get("@included"); ok {
+			if err := p.frame(state, subjects, asArray(included), output, "@included", graph, false); err != nil {
+				return err
+			}
+		}
+
+		for _, prop := range subject.sortedKeys() {
+			if isKeyword(prop) {
+				output.set(prop, cloneJSON(subject.value(prop)))
+				if prop == "@type" {
+					for _, t := range asArray(subject.value(prop)) {
+						if s, ok := t.(string); ok && isBlankNodeIdentifier(s) {
+							state.bnodeCount[s]++
+						}
+					}
+				}
+				continue
+			}
+			if flags.explicit && !frameObject.has(prop) {
+				continue
+			}
+
+			subframe := implicitFrame(flags)
+			if value, ok := frameObject.get(prop); ok {
+				subframe = asArray(value)
+			}
+			for _, o := range asArray(subject.value(prop)) {
+				switch {
+				case isListObject(o):
+					listFrame := implicitFrame(flags)
+					if len(subframe) > 0 {
+						if object, ok := subframe[0].(*jsonObject); ok && object.has("@list") {
+							listFrame = asArray(object.value("@list"))
+						}
+					}
+					list := newJSONObject()
+					list.set("@list", []any{})
+					addValue(output, prop, list, true)
+					for _, item := range asArray(o.(*jsonObject).value("@list")) {
+						if reference, ok := nodeReference(item); ok {
+							if err := p.frame(state, []string{reference}, listFrame, list, "@list", graph, true); err != nil {
+								return err
+							}
+						} else {
+							addValue(list, "@list", cloneJSON(item), true)
+						}
+					}
+
+				default:
+					if reference, ok := nodeReference(o); ok {
+						if err := p.frame(state, []string{reference}, subframe, output, prop, graph, true); err != nil {
+							return err
+						}
+					} else if len(subframe) == 0 || valueMatch(subframe[0], o) {
+						addValue(output, prop, cloneJSON(o), true)
+					}
+				}
+			}
+		}
+
+		// Properties of the frame the node doesn't have get their default value, null
+		// unless the frame gives one
+		for _, prop := range frameObject.sortedKeys() {
+			values := asArray(frameObject.value(prop))
+			next, _ := firstJSONObject(values)
+			if prop == "@type" {
+				if next == nil || !next.has("@default") {
+					continue
+				}
+			} else if isKeyword(prop) {
+				continue
+			}
+			if next == nil {
+				next = newJSONObject()
+			}
+			if boolFrameFlag(next, "@omitDefault", state.opts.omitDefault) || output.has(prop) {
+				continue
+			}
+			var preserve any = "@null"
+			if value, ok := next.get("@default"); ok {
+				preserve = cloneJSON(value)
+			}
+			object := newJSONObject()
+			object.set("@preserve", asArray(preserve))
+			output.set(prop, []any{object})
+		}
+
+		// Nodes referring to this one through a reverse property of the frame are embedded
+		// under @reverse
+		if reverseFrame, ok := frameObject.value("@reverse").(*jsonObject); ok {
+			for _, reverseProp := range reverseFrame.sortedKeys() {
+				subframe := asArray(reverseFrame.value(reverseProp))
+				for _, subjectID := range state.subjects.keyList() {
+					node := state.subjects.value(subjectID).(*jsonObject)
+					refers := false
+					for _, value := range asArray(node.value(reverseProp)) {
+						if object, ok := value.(*jsonObject); ok && object.value("@id") == id {
+							refers = true
+							break
+						}
+					}
+					if !refers {
+						continue
+					}
+					reverse, ok := output.value("@reverse").(*jsonObject)
+					if !ok {
+						reverse = newJSONObject()
+						output.set("@reverse", reverse)
+					}
+					if !reverse.has(reverseProp) {
+						reverse.set(reverseProp, []any{})
+					}
+					if err := p.frame(state, []string{subjectID}, subframe, reverse, reverseProp, graph, true); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		addValue(parent, property, output, true)
+		state.subjectStack = state.subjectStack[:len(state.subjectStack)-1]
+	}
+	return nil
+}
+
+// createsCircularReference reports whether a node is already being embedded in the same graph
+func (s *framingState) createsCircularReference(id, graph string) bool {
+	for i := len(s.subjectStack) - 1; i >= 0; i-- {
+		if s.subjectStack[i].graph == graph && s.subjectStack[i].id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// filterSubject reports whether a node matches a frame, by @id, by @type, or by the
+// properties the frame lists; a frame without any of them matches every node
+func (p *jsonldProcessor) filterSubject(state *framingState, subject, frame *jsonObject, flags frameFlags) (bool, error) {
+	wildcard, matchesSome := true, false
+	for _, key := range frame.keys {
+		matchThis := false
+		frameValues := asArray(frame.value(key))
+		nodeValues := []any{}
+		if value, ok := subject.get(key); ok {
+			nodeValues = asArray(value)
+		}
+
+		switch {
+		case key == "@id":
+			if object, ok := firstJSONObject(frameValues); ok && object.len() == 0 || len(frameValues) == 0 {
+				matchThis = true
+			} else {
+				matchThis = len(nodeValues) > 0 && containsJSON(frameValues, nodeValues[0])
+			}
+			if !flags.requireAll {
+				return matchThis, nil
+			}
+
+		case key == "@type":
+			wildcard = false
+			if len(frameValues) == 0 {
+				if len(nodeValues) > 0 {
+					return false, nil
+				}
+				matchThis = true
+			} else if object, ok := frameValues[0].(*jsonObject); len(frameValues) == 1 && ok && object.len() == 0 {
+				matchThis = len(nodeValues) > 0
+			} else {
+				for _, t := range frameValues {
+					if object, ok := t.(*jsonObject); ok && object.has("@default") {
+						matchThis = true
+					} else if containsJSON(nodeValues, t) {
+						matchThis = true
+					}
+				}
+				if !flags.requireAll {
+					return matchThis, nil
+				}
+			}
+
+		case isKeyword(key):
+			continue
+
+		default:
+			wildcard = false
+			propertyFrame, hasFrame := firstJSONObject(frameValues)
+			if len(frameValues) > 0 && !hasFrame {
+				return false, jsonldErrorf("invalid frame", "the frame of %s must be an object", key)
+			}
+			if hasFrame {
+				if _, err := validateFrame([]any{propertyFrame}); err != nil {
+					return false, err
+				}
+			}
+			if len(nodeValues) == 0 && hasFrame && propertyFrame.has("@default") {
+				continue
+			}
+			if len(nodeValues) > 0 && len(frameValues) == 0 {
+				return false, nil
+			}
+
+			switch {
+			case !hasFrame:
+				matchThis = true
+			case isListObject(propertyFrame):
+				list, _ := firstJSONObject(asArray(propertyFrame.value("@list")))
+				if len(nodeValues) > 0 && isListObject(nodeValues[0]) && list != nil {
+					for _, item := range asArray(nodeValues[0].(*jsonObject).value("@list")) {
+						if isValueObject(list) && valueMatch(list, item) ||
+							!isValueObject(list) && p.nodeMatch(state, list, item, flags) {
+							matchThis = true
+							break
+						}
+					}
+				}
+			case isValueObject(propertyFrame):
+				for _, value := range nodeValues {
+					if valueMatch(propertyFrame, value) {
+						matchThis = true
+						break
+					}
+				}
+			case propertyFrame.len() == 1 && propertyFrame.has("@id"):
+				for _, value := range nodeValues {
+					if p.nodeMatch(state, propertyFrame, value, flags) {
+						matchThis = true
+						break
+					}
+				}
+			default:
+				matchThis = len(nodeValues) > 0
+			}
+		}
+
+		if !matchThis && flags.requireAll {
+			return false, nil
+		}
+		matchesSome = matchesSome || matchThis
+	}
+	return wildcard || matchesSome, nil
+}
+
+// nodeMatch reports whether a value refers to a node that matches a frame
+func (p *jsonldProcessor) nodeMatch(state *framingState, frame *jsonObject, value any, flags frameFlags) bool {
+	id, ok := nodeReferenceID(value)
+	if !ok {
+		return false
+	}
+	node, ok := state.subjects.value(id).(*jsonObject)
+	if !ok {
+		return false
+	}
+	match, err := p.filterSubject(state, node, frame, flags)
+	return err == nil && match
+}
+
+// valueMatch reports whether a value object matches a value pattern, where an empty map
+// stands for any @value, @type or @language
+func valueMatch(pattern, value any) bool {
+	patternObject, ok := pattern.(*jsonObject)
+	valueObject, isObject := value.(*jsonObject)
+	if !ok || !isObject {
+		return ok && patternObject.len() == 0
+	}
+	patterns := func(key string) []any {
+		if v, ok := patternObject.get(key); ok {
+			return asArray(v)
+		}
+		return nil
+	}
+	v2, t2, l2 := patterns("@value"), patterns("@type"), patterns("@language")
+	if len(v2) == 0 && len(t2) == 0 && len(l2) == 0 {
+		return true
+	}
+	matches := func(candidates []any, key string) bool {
+		actual, ok := valueObject.get(key)
+		if !ok {
+			return len(candidates) == 0
+		}
+		if wildcard, ok := firstJSONObject(candidates); ok && wildcard.len() == 0 {
+			return true
+		}
+		if key == "@language" {
+			language, _ := actual.(string)
+			for _, candidate := range candidates {
+				if s, ok := candidate.(string); ok && strings.EqualFold(s, language) {
+					return true
+				}
+			}
+			return false
+		}
+		return containsJSON(candidates, actual)
+	}
+	if wildcard, ok := firstJSONObject(v2); !(ok && wildcard.len() == 0) && !containsJSON(v2, valueObject.value("@value")) {
+		return false
+	}
+	return matches(t2, "@type") && matches(l2, "@language")
+}
+
+// validateFrame checks that a frame is a single map whose @id and @type entries are IRIs or
+// wildcards, returning the map
+func validateFrame(frame []any) (*jsonObject, error) {
+	if len(frame) != 1 {
+		return nil, jsonldErrorf("invalid frame", "a frame must be a single object")
+	}
+	object, ok := frame[0].(*jsonObject)
+	if !ok {
+		return nil, jsonldErrorf("invalid frame", "a frame must be a single object")
+	}
+	for _, id := range asArray(object.value("@id")) {
+		if s, ok := id.(string); ok && (!isAbsoluteIRI(s) || isBlankNodeIdentifier(s)) {
+			return nil, jsonldErrorf("invalid frame", "invalid @id %q in frame", s)
+		}
+	}
+	for _, t := range asArray(object.value("@type")) {
+		if s, ok := t.(string); ok && s != "@json" && (!isAbsoluteIRI(s) || isBlankNodeIdentifier(s)) {
+			return nil, jsonldErrorf("invalid frame", "invalid @type %q in frame", s)
+		}
+	}
+	return object, nil
+}
+
+// frameFlag returns the value of a framing flag, or fallback when the frame doesn't set it
+func frameFlag(frame *jsonObject, name string, fallback any) any {
+	values := asArray(frame.value(name))
+	if !frame.has(name) || len(values) == 0 {
+		return fallback
+	}
+	if object, ok := values[0].(*jsonObject); ok && object.has("@value") {
+		return object.value("@value")
+	}
+	return values[0]
+}
+
+func boolFrameFlag(frame *jsonObject, name string, fallback bool) bool {
+	value, _ := frameFlag(frame, name, fallback).(bool)
+	return value
+}
+
+// embedFrameFlag returns the @embed flag of a frame; true stands for @once and false for @never
+func embedFrameFlag(frame *jsonObject, fallback string) (string, error) {
+	switch v := frameFlag(frame, "@embed", fallback).(type) {
+	case bool:
+		if v {
+			return "@once", nil
+		}
+		return "@never", nil
+	case string:
+		if v == "@always" || v == "@once" || v == "@never" {
+			return v, nil
+		}
+	}
+	return "", jsonldErrorf("invalid @embed value", "@embed must be @always, @once or @never")
+}
+
+// implicitFrame returns the frame used for properties the frame doesn't list, which keeps the
+// flags of the enclosing frame
+func implicitFrame(flags frameFlags) []any {
+	frame := newJSONObject()
+	frame.set("@embed", []any{flags.embed})
+	frame.set("@explicit", []any{flags.explicit})
+	frame.set("@requireAll", []any{flags.requireAll})
+	return []any{frame}
+}
+
+// nodeReference returns the @id of a node reference, a map with nothing but @id
+func nodeReference(value any) (string, bool) {
+	object, ok := value.(*jsonObject)
+	if !ok || object.len() != 1 {
+		return "", false
+	}
+	id, ok := object.value("@id").(string)
+	return id, ok
+}
+
+// nodeReferenceID returns the @id of a node object or reference
+func nodeReferenceID(value any) (string, bool) {
+	object, ok := value.(*jsonObject)
+	if !ok {
+		return "", false
+	}
+	id, ok := object.value("@id").(string)
+	return id, ok
+}
+
+// firstJSONObject returns the first value of an array when it is a map
+func firstJSONObject(values []any) (*jsonObject, bool) {
+	if len(values) == 0 {
+		return nil, false
+	}
+	object, ok := values[0].(*jsonObject)
+	return object, ok
+}
+
+// mergeNodeMaps runs the Merge Node Maps algorithm, combining the nodes of all graphs
+func mergeNodeMaps(graphs *jsonObject) *jsonObject {
+	merged := newJSONObject()
+	for _, name := range graphs.sortedKeys() {
+		nodes := graphs.value(name).(*jsonObject)
+		for _, id := range nodes.sortedKeys() {
+			node := nodes.value(id).(*jsonObject)
+			mergedNode, ok := merged.value(id).(*jsonObject)
+			if !ok {
+				mergedNode = newJSONObject()
+				mergedNode.set("@id", id)
+				merged.set(id, mergedNode)
+			}
+			for _, property := range node.sortedKeys() {
+				if isKeyword(property) && property != "@type" {
+					mergedNode.set(property, cloneJSON(node.value(property)))
+					continue
+				}
+				for _, value := range asArray(node.value(property)) {
+					if !containsJSON(mergedNode.value(property), value) {
+						addValue(mergedNode, property, cloneJSON(value), true)
+					}
+				}
+			}
+		}
+	}
+	return merged
+}
+
+// cleanupPreserve replaces the @preserve maps holding default values with the values and
+// removes the identifiers of blank nodes in clear
+func cleanupPreserve(value any, clear map[string]bool) any {
+	switch v := value.(type) {
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			result[i] = cleanupPreserve(item, clear)
+		}
+		return result
+	case *jsonObject:
+		if preserve, ok := v.get("@preserve"); ok {
+			return asArray(preserve)[0]
+		}
+		if isValueObject(v) {
+			return v
+		}
+		for _, key := range v.keyList() {
+			if id, ok := v.value(key).(string); key == "@id" && ok && clear[id] {
+				v.remove(key)
+				continue
+			}
+			v.set(key, cleanupPreserve(v.value(key), clear))
+		}
+	}
+	return value
+}
+
+// cleanupNull replaces the "@null" placeholders of default values with null, dropping them
+// from arrays
+func cleanupNull(value any) any {
+	switch v := value.(type) {
+	case string:
+		if v == "@null" {
+			return nil
+		}
+	case []any:
+		result := make([]any, 0, len(v))
+		for _, item := range v {
+			if item = cleanupNull(item); item != nil {
+				result = append(result, item)
+			}
+		}
+		return result
+	case *jsonObject:
+		for _, key := range v.keys {
+			v.values[key] = cleanupNull(v.values[key])
+		}
+	}
+	return value
+}
@@ -0,0 +1,349 @@
+package rdf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fromRDFOptions are the options of the Serialize RDF as JSON-LD algorithm
+type fromRDFOptions struct {
+	useNativeTypes bool
+	useRDFType     bool
+}
+
+// nodeUsage records where a node is referenced: the node and property whose value refers to it
+type nodeUsage struct {
+	node     *jsonObject
+	property string
+	value    *jsonObject
+}
+
+// Lexical forms of xsd:integer and xsd:double that useNativeTypes converts to numbers; INF and
+// NaN have no JSON number and stay typed literals
+var (
+	xsdIntegerLexical = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	xsdDoubleLexical  = regexp.MustCompile(`^[+-]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)(?:[eE][+-]?[0-9]+)?$`)
+)
+
+// fromRDF runs the Serialize RDF as JSON-LD algorithm, returning the dataset in expanded form.
+// Lists whose nodes are blank nodes referenced once become @list values, and named graphs
+// become the @graph of a node in the default graph.
+func (p *jsonldProcessor) fromRDF(quads []*Quad, opts fromRDFOptions) ([]any, error) {
+	defaultGraph := newJSONObject()
+	graphMap := newJSONObject()
+	graphMap.set("@default", defaultGraph)
+	nilUsages := make(map[string][]*nodeUsage)    // graph name to the usages of rdf:nil
+	referencedOnce := make(map[string]*nodeUsage) // blank node to its only usage, nil when used again
+	compoundLiterals := make(map[string]map[string]bool)
+
+	for _, quad := range quads {
+		name := "@default"
+		if quad.Graph != nil {
+			if _, isDefault := quad.Graph.(*DefaultGraph); !isDefault {
+				var err error
+				if name, err = jsonldNodeID(quad.Graph); err != nil {
+					return nil, err
+				}
+			}
+		}
+		nodes, ok := graphMap.value(name).(*jsonObject)
+		if !ok {
+			nodes = newJSONObject()
+			graphMap.set(name, nodes)
+		}
+		if compoundLiterals[name] == nil {
+			compoundLiterals[name] = make(map[string]bool)
+		}
+		if name != "@default" && !defaultGraph.has(name) {
+			reference := newJSONObject()
+			reference.set("@id", name)
+			defaultGraph.set(name, reference)
+		}
+
+		subject, err := jsonldNodeID(quad.Subject)
+		if err != nil {
+			return nil, err
+		}
+		predicate, ok := quad.Predicate.(*NamedNode)
+		if !ok {
+			return nil, fmt.Errorf("JSON-LD cannot represent predicate %s", quad.Predicate)
+		}
+		node, ok := nodes.value(subject).(*jsonObject)
+		if !ok {
+			node = newJSONObject()
+			node.set("@id", subject)
+			nodes.set(subject, node)
+		}
+		if p.rdfDirection == "compound-literal" && predicate.IRI == rdfNamespace+"direction" {
+			compoundLiterals[name][subject] = true
+		}
+
+		objectID := ""
+		switch quad.Object.(type) {
+		case *NamedNode, *BlankNode:
+			objectID, _ = jsonldNodeID(quad.Object)
+			if !nodes.has(objectID) {
+				reference := newJSONObject()
+				reference.set("@id", objectID)
+				nodes.set(objectID, reference)
+			}
+		}
+
+		if predicate.IRI == rdfNamespace+"type" && !opts.useRDFType && objectID != "" {
+			if !containsJSON(node.value("@type"), objectID) {
+				addValue(node, "@type", objectID, true)
+			}
+			continue
+		}
+
+		value, err := p.rdfToObject(quad.Object, opts)
+		if err != nil {
+			return nil, err
+		}
+		if existing := node.value(predicate.IRI); existing != nil {
+			// An equal value already there is the one the usage refers to
+			found := false
+			for _, item := range asArray(existing) {
+				if jsonEqual(item, value) {
+					value, found = item.(*jsonObject), true
+					break
+				}
+			}
+			if !found {
+				addValue(node, predicate.IRI, value, true)
+			}
+		} else {
+			addValue(node, predicate.IRI, value, true)
+		}
+
+		usage := &nodeUsage{node: node, property: predicate.IRI, value: value}
+		switch {
+		case objectID == rdfNamespace+"nil":
+			nilUsages[name] = append(nilUsages[name], usage)
+		case !isBlankNodeIdentifier(objectID):
+		case hasUsage(referencedOnce, objectID):
+			referencedOnce[objectID] = nil
+		default:
+			referencedOnce[objectID] = usage
+		}
+	}
+
+	for _, name := range graphMap.keys {
+		nodes := graphMap.value(name).(*jsonObject)
+		if err := p.mergeCompoundLiterals(nodes, compoundLiterals[name], referencedOnce); err != nil {
+			return nil, err
+		}
+		for _, usage := range nilUsages[name] {
+			convertList(nodes, usage, referencedOnce)
+		}
+	}
+
+	result := []any{}
+	for _, subject := range defaultGraph.keys {
+		node := defaultGraph.value(subject).(*jsonObject)
+		if graph, ok := graphMap.value(subject).(*jsonObject); ok && subject != "@default" {
+			members := []any{}
+			for _, id := range graph.keys {
+				if member := graph.value(id).(*jsonObject); !member.onlyKeys("@id") {
+					members = append(members, member)
+				}
+			}
+			node.set("@graph", members)
+		}
+		if !node.onlyKeys("@id") {
+			result = append(result, node)
+		}
+	}
+	return result, nil
+}
+
+// hasUsage reports whether a blank node has been referenced before
+func hasUsage(referencedOnce map[string]*nodeUsage, id string) bool {
+	_, ok := referencedOnce[id]
+	return ok
+}
+
+// convertList turns the chain of list nodes ending in a usage of rdf:nil into a @list value.
+// The chain is followed back while its nodes are blank nodes referenced once that have nothing
+// but rdf:first and rdf:rest, and optionally an rdf:List type.
+func convertList(nodes *jsonObject, usage *nodeUsage, referencedOnce map[string]*nodeUsage) {
+	node, property, head := usage.node, usage.property, usage.value
+	var list []any
+	var listNodes []string
+	for property == rdfNamespace+"rest" && isWellFormedListNode(node, referencedOnce) {
+		list = append(list, asArray(node.value(rdfNamespace + "first"))[0])
+		id := node.value("@id").(string)
+		listNodes = append(listNodes, id)
+
+		nodeUsage := referencedOnce[id]
+		node, property, head = nodeUsage.node, nodeUsage.property, nodeUsage.value
+		if id, _ := node.value("@id").(string); !isBlankNodeIdentifier(id) {
+			break
+		}
+	}
+
+	head.remove("@id")
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	if list == nil {
+		list = []any{}
+	}
+	head.set("@list", list)
+	for _, id := range listNodes {
+		nodes.remove(id)
+	}
+}
+
+// isWellFormedListNode reports whether a node can be folded into a @list
+func isWellFormedListNode(node *jsonObject, referencedOnce map[string]*nodeUsage) bool {
+	id, _ := node.value("@id").(string)
+	if !isBlankNodeIdentifier(id) || referencedOnce[id] == nil {
+		return false
+	}
+	first, _ := node.value(rdfNamespace + "first").([]any)
+	rest, _ := node.value(rdfNamespace + "rest").([]any)
+	if len(first) != 1 || len(rest) != 1 {
+		return false
+	}
+	if types, ok := node.get("@type"); ok {
+		array, _ := types.([]any)
+		if len(array) != 1 || array[0] != rdfNamespace+"List" {
+			return false
+		}
+		return node.len() == 4
+	}
+	return node.len() == 3
+}
+
+// mergeCompoundLiterals folds the rdf:value, rdf:language and rdf:direction of compound
+// literal nodes back into the value objects that refer to them
+func (p *jsonldProcessor) mergeCompoundLiterals(nodes *jsonObject, subjects map[string]bool, referencedOnce map[string]*nodeUsage) error {
+	for _, id := range nodes.keyList() {
+		if !subjects[id] {
+			continue
+		}
+		usage := referencedOnce[id]
+		if usage == nil {
+			continue
+		}
+		compound := nodes.value(id).(*jsonObject)
+		nodes.remove(id)
+		for _, item := range asArray(usage.node.value(usage.property)) {
+			reference, ok := item.(*jsonObject)
+			if !ok || reference.value("@id") != id {
+				continue
+			}
+			reference.remove("@id")
+			reference.set("@value", firstValue(compound, rdfNamespace+"value"))
+			if language, ok := firstValue(compound, rdfNamespace+"language").(string); ok {
+				if !wellFormedLanguageTag.MatchString(language) {
+					return jsonldErrorf("invalid language-tagged string", "invalid language %q", language)
+				}
+				reference.set("@language", language)
+			}
+			if direction, ok := firstValue(compound, rdfNamespace+"direction").(string); ok {
+				if direction != "ltr" && direction != "rtl" {
+					return jsonldErrorf("invalid base direction", "invalid direction %q", direction)
+				}
+				reference.set("@direction", direction)
+			}
+		}
+	}
+	return nil
+}
+
+// firstValue returns the @value of the first value of a property, nil when there is none
+func firstValue(node *jsonObject, property string) any {
+	values := asArray(node.value(property))
+	if len(values) == 0 {
+		return nil
+	}
+	if value, ok := values[0].(*jsonObject); ok {
+		return value.value("@value")
+	}
+	return nil
+}
+
+// jsonldNodeID returns the node identifier of an IRI or a blank node
+func jsonldNodeID(term Term) (string, error) {
+	switch t := term.(type) {
+	case *NamedNode:
+		return t.IRI, nil
+	case *BlankNode:
+		return "_:" + t.ID, nil
+	}
+	return "", fmt.Errorf("JSON-LD cannot represent %s as a node", term)
+}
+
+// rdfToObject runs the RDF to Object Conversion algorithm
+func (p *jsonldProcessor) rdfToObject(term Term, opts fromRDFOptions) (*jsonObject, error) {
+	result := newJSONObject()
+	literal, ok := term.(*Literal)
+	if !ok {
+		id, err := jsonldNodeID(term)
+		if err != nil {
+			return nil, err
+		}
+		result.set("@id", id)
+		return result, nil
+	}
+
+	var value any = literal.Value
+	datatype := ""
+	if literal.Datatype != nil {
+		datatype = literal.Datatype.IRI
+	}
+	switch {
+	case literal.Language != "":
+		result.set("@value", value)
+		result.set("@language", literal.Language)
+		if literal.Direction != "" {
+			result.set("@direction", literal.Direction)
+		}
+		return result, nil
+
+	case datatype == "" || datatype == xsdNamespace+"string":
+		datatype = ""
+
+	case opts.useNativeTypes && datatype == xsdNamespace+"boolean":
+		switch literal.Value {
+		case "true":
+			value, datatype = true, ""
+		case "false":
+			value, datatype = false, ""
+		}
+
+	case opts.useNativeTypes && datatype == xsdNamespace+"integer" && xsdIntegerLexical.MatchString(literal.Value),
+		opts.useNativeTypes && datatype == xsdNamespace+"double" && xsdDoubleLexical.MatchString(literal.Value):
+		if number, err := strconv.ParseFloat(literal.Value, 64); err == nil {
+			value, datatype = number, ""
+		}
+
+	case datatype == rdfNamespace+"JSON" && !p.is10():
+		parsed, err := decodeJSON([]byte(literal.Value))
+		if err != nil {
+			return nil, jsonldErrorf("invalid JSON literal", "%v", err)
+		}
+		value, datatype = parsed, "@json"
+
+	case strings.HasPrefix(datatype, i18nNamespace) && p.rdfDirection == "i18n-datatype":
+		language, direction, _ := strings.Cut(datatype[len(i18nNamespace):], "_")
+		result.set("@value", value)
+		if language != "" {
+			result.set("@language", language)
+		}
+		if direction != "" {
+			result.set("@direction", direction)
+		}
+		return result, nil
+	}
+
+	result.set("@value", value)
+	if datatype != "" {
+		result.set("@type", datatype)
+	}
+	return result, nil
+}
@@ -0,0 +1,379 @@
+package rdf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON values in the JSON-LD algorithms are nil, bool, float64, string, []any and *jsonObject.
+// Objects keep the order of their keys, so output follows the order of the document.
+
+// jsonObject is a JSON object that keeps its keys in insertion order
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: make(map[string]any)}
+}
+
+// get returns the value of a key and whether the object has it
+func (o *jsonObject) get(key string) (any, bool) {
+	value, ok := o.values[key]
+	return value, ok
+}
+
+// value returns the value of a key, nil when the object doesn't have it
+func (o *jsonObject) value(key string) any {
+	return o.values[key]
+}
+
+func (o *jsonObject) has(key string) bool {
+	_, ok := o.values[key]
+	return ok
+}
+
+// set sets the value of a key, adding the key at the end when it is new
+func (o *jsonObject) set(key string, value any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsonObject) remove(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func (o *jsonObject) len() int {
+	return len(o.keys)
+}
+
+// keyList returns a copy of the keys in order, safe to iterate while the object changes
+func (o *jsonObject) keyList() []string {
+	return append([]string(nil), o.keys...)
+}
+
+// sortedKeys returns the keys in lexicographic order
+func (o *jsonObject) sortedKeys() []string {
+	keys := o.keyList()
+	sort.Strings(keys)
+	return keys
+}
+
+// clone returns a shallow copy of the object
+func (o *jsonObject) clone() *jsonObject {
+	c := &jsonObject{keys: o.keyList(), values: make(map[string]any, len(o.values))}
+	for k, v := range o.values {
+		c.values[k] = v
+	}
+	return c
+}
+
+// cloneJSON returns a deep copy of a JSON value
+func cloneJSON(value any) any {
+	switch v := value.(type) {
+	case []any:
+		array := make([]any, len(v))
+		for i, item := range v {
+			array[i] = cloneJSON(item)
+		}
+		return array
+	case *jsonObject:
+		c := &jsonObject{keys: v.keyList(), values: make(map[string]any, len(v.values))}
+		for k, item := range v.values {
+			c.values[k] = cloneJSON(item)
+		}
+		return c
+	}
+	return value
+}
+
+// onlyKeys reports whether the object has no keys other than the given ones
+func (o *jsonObject) onlyKeys(keys ...string) bool {
+	for _, key := range o.keys {
+		found := false
+		for _, k := range keys {
+			if key == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalJSON writes the object with its keys in order, leaving <, > and & unescaped
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := encoder.Encode(key); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1) // Encode ends each value with a newline
+		buf.WriteByte(':')
+		if err := encoder.Encode(o.values[key]); err != nil {
+			return nil, err
+		}
+		buf.Truncate(buf.Len() - 1)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	value, err := decodeJSONValue(decoder)
+	if err != nil {
+		offset := int(decoder.InputOffset())
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			offset = int(syntaxErr.Offset)
+		}
+		return nil, NewParseError(string(data), offset, err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, NewParseError(string(data), int(decoder.InputOffset()), fmt.Errorf("unexpected data after the JSON value"))
+	}
+	return value, nil
+}
+
+// decodeJSONValue reads the next value from a decoder
+func decodeJSONValue(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			object := newJSONObject()
+			for decoder.More() {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := keyToken.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid object key %v", keyToken)
+				}
+				value, err := decodeJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				object.set(key, value)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			return object, nil
+		case '[':
+			array := []any{}
+			for decoder.More() {
+				value, err := decodeJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, err
+			}
+			return array, nil
+		}
+		return nil, fmt.Errorf("unexpected delimiter %v", t)
+	default:
+		return t, nil
+	}
+}
+
+// asArray returns a value as an array, wrapping a value that isn't one
+func asArray(value any) []any {
+	if array, ok := value.([]any); ok {
+		return array
+	}
+	return []any{value}
+}
+
+// isScalar reports whether a value is a string, number or boolean
+func isScalar(value any) bool {
+	switch value.(type) {
+	case string, float64, bool:
+		return true
+	}
+	return false
+}
+
+// jsonEqual reports whether two values are equal; objects are equal when they have the same entries
+func jsonEqual(a, b any) bool {
+	switch x := a.(type) {
+	case *jsonObject:
+		y, ok := b.(*jsonObject)
+		if !ok || x.len() != y.len() {
+			return false
+		}
+		for _, key := range x.keys {
+			value, ok := y.get(key)
+			if !ok || !jsonEqual(x.values[key], value) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// canonicalJSON writes a value in the JSON Canonicalization Scheme (RFC 8785): object keys
+// sorted, no whitespace, and numbers and strings written as ECMAScript does
+func canonicalJSON(value any) string {
+	var sb strings.Builder
+	writeCanonicalJSON(&sb, value)
+	return sb.String()
+}
+
+func writeCanonicalJSON(sb *strings.Builder, value any) {
+	switch v := value.(type) {
+	case nil:
+		sb.WriteString("null")
+	case bool:
+		sb.WriteString(strconv.FormatBool(v))
+	case float64:
+		sb.WriteString(formatJSNumber(v))
+	case string:
+		writeCanonicalJSONString(sb, v)
+	case []any:
+		sb.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			writeCanonicalJSON(sb, item)
+		}
+		sb.WriteByte(']')
+	case *jsonObject:
+		// RFC 8785 sorts keys by their UTF-16 code units
+		keys := v.keyList()
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+		sb.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			writeCanonicalJSONString(sb, key)
+			sb.WriteByte(':')
+			writeCanonicalJSON(sb, v.values[key])
+		}
+		sb.WriteByte('}')
+	}
+}
+
+// writeCanonicalJSONString writes a string escaping only what JSON requires
+func writeCanonicalJSONString(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+}
+
+// lessUTF16 compares two strings by their UTF-16 code units
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16Units(a), utf16Units(b)
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+func utf16Units(s string) []uint16 {
+	var units []uint16
+	for _, r := range s {
+		if r >= 0x10000 {
+			r -= 0x10000
+			units = append(units, uint16(0xD800+(r>>10)), uint16(0xDC00+(r&0x3FF)))
+		} else {
+			units = append(units, uint16(r))
+		}
+	}
+	return units
+}
+
+// formatJSNumber writes a number the way ECMAScript's Number.prototype.toString does
+func formatJSNumber(v float64) string {
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return "0"
+	}
+	abs := math.Abs(v)
+	if abs >= 1e21 || abs < 1e-6 {
+		s := strconv.FormatFloat(v, 'e', -1, 64)
+		mantissa, exponent, _ := strings.Cut(s, "e")
+		sign := exponent[:1]
+		exponent = strings.TrimLeft(exponent[1:], "0")
+		return mantissa + "e" + sign + exponent
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
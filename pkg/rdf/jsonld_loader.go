@@ -0,0 +1,175 @@
+package rdf
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RemoteDocument is a document retrieved by a DocumentLoader
+type RemoteDocument struct {
+	DocumentURL string // URL of the document after any redirects
+	Document    []byte
+	ContextURL  string // context linked from an HTTP Link header, if any
+	ContentType string
+}
+
+// DocumentLoader retrieves remote contexts and documents for the JSON-LD processor
+type DocumentLoader interface {
+	LoadDocument(url string) (*RemoteDocument, error)
+}
+
+// LocalDocumentLoader serves documents from memory and the local file system, for
+// processing JSON-LD offline. Documents are registered under the URL they stand for.
+type LocalDocumentLoader struct {
+	mu          sync.RWMutex
+	documents   map[string]*RemoteDocument
+	directories map[string]string // local directory by URL prefix
+}
+
+// NewLocalDocumentLoader creates an empty local document loader
+func NewLocalDocumentLoader() *LocalDocumentLoader {
+	return &LocalDocumentLoader{
+		documents:   make(map[string]*RemoteDocument),
+		directories: make(map[string]string),
+	}
+}
+
+// AddDocument registers a document under a URL
+func (l *LocalDocumentLoader) AddDocument(url string, data []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.documents[url] = &RemoteDocument{
+		DocumentURL: url,
+		Document:    data,
+		ContentType: contentTypeForPath(url),
+	}
+}
+
+// AddDirectory serves the files in a directory for URLs starting with a prefix: the rest of
+// the URL is taken as the path of the file relative to the directory
+func (l *LocalDocumentLoader) AddDirectory(urlPrefix, dir string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.directories[urlPrefix] = dir
+}
+
+func (l *LocalDocumentLoader) LoadDocument(url string) (*RemoteDocument, error) {
+	url, _, _ = strings.Cut(url, "#")
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if doc, ok := l.documents[url]; ok {
+		return doc, nil
+	}
+
+	// The longest matching prefix wins, so a subdirectory can be mapped separately
+	bestPrefix := ""
+	for prefix := range l.directories {
+		if strings.HasPrefix(url, prefix) && len(prefix) >= len(bestPrefix) {
+			bestPrefix = prefix
+		}
+	}
+	dir, ok := l.directories[bestPrefix]
+	if !ok {
+		return nil, fmt.Errorf("no local document for %s", url)
+	}
+	relative := strings.TrimPrefix(url, bestPrefix)
+	if i := strings.IndexByte(relative, '?'); i >= 0 {
+		relative = relative[:i]
+	}
+	path := filepath.Join(dir, filepath.FromSlash(relative))
+	if rel, err := filepath.Rel(dir, path); err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("no local document for %s", url)
+	}
+	data, err := os.ReadFile(path) // #nosec G304 - path is confined to a registered directory
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", url, err)
+	}
+	return &RemoteDocument{
+		DocumentURL: url,
+		Document:    data,
+		ContentType: contentTypeForPath(path),
+	}, nil
+}
+
+// contentTypeForPath guesses the media type of a document from its file extension
+func contentTypeForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonld":
+		return "application/ld+json"
+	case ".json":
+		return "application/json"
+	case ".html", ".htm":
+		return "text/html"
+	case ".nq":
+		return "application/n-quads"
+	}
+	return "application/octet-stream"
+}
+
+// HTTPDocumentLoader retrieves documents over HTTP. It is opt-in: a parser loads nothing from
+// the network unless it has been given a loader that does.
+type HTTPDocumentLoader struct {
+	client  *http.Client
+	maxSize int64
+}
+
+// NewHTTPDocumentLoader creates a loader using the given client, http.DefaultClient when nil
+func NewHTTPDocumentLoader(client *http.Client) *HTTPDocumentLoader {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPDocumentLoader{client: client, maxSize: 10 << 20}
+}
+
+// jsonldContextLink matches a Link header pointing to a JSON-LD context
+var jsonldContextLink = regexp.MustCompile(`<([^>]*)>[^,]*rel="http://www\.w3\.org/ns/json-ld#context"`)
+
+func (l *HTTPDocumentLoader) LoadDocument(url string) (*RemoteDocument, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document URL %s: %w", url, err)
+	}
+	req.Header.Set("Accept", "application/ld+json, application/json;q=0.9")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error loading %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, l.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", url, err)
+	}
+	if int64(len(data)) > l.maxSize {
+		return nil, fmt.Errorf("document %s is larger than %d bytes", url, l.maxSize)
+	}
+
+	doc := &RemoteDocument{
+		DocumentURL: resp.Request.URL.String(),
+		Document:    data,
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		doc.ContentType = mediaType
+	}
+	if doc.ContentType != "application/ld+json" {
+		for _, link := range resp.Header.Values("Link") {
+			if m := jsonldContextLink.FindStringSubmatch(link); m != nil {
+				doc.ContextURL = resolveIRI(doc.DocumentURL, m[1])
+				break
+			}
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,419 @@
+package rdf
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const i18nNamespace = "https://www.w3.org/ns/i18n#"
+
+// blankNodeIssuer issues blank node identifiers with a prefix and a counter, remembering the
+// identifier issued for each existing one
+type blankNodeIssuer struct {
+	prefix  string
+	counter int
+	issued  map[string]string
+}
+
+func newBlankNodeIssuer(prefix string) *blankNodeIssuer {
+	return &blankNodeIssuer{prefix: prefix, issued: make(map[string]string)}
+}
+
+// issue returns the identifier for an existing one, issuing a new identifier the first time;
+// an empty existing identifier always gets a new one
+func (i *blankNodeIssuer) issue(existing string) string {
+	if id, ok := i.issued[existing]; ok && existing != "" {
+		return id
+	}
+	id := i.prefix + strconv.Itoa(i.counter)
+	i.counter++
+	if existing != "" {
+		i.issued[existing] = id
+	}
+	return id
+}
+
+// nodeMap holds the nodes of each graph by identifier, keeping the order in which they were added
+type nodeMap struct {
+	graphs *jsonObject // graph name, "@default" for the default graph, to a map of nodes by @id
+}
+
+func newNodeMap() *nodeMap {
+	graphs := newJSONObject()
+	graphs.set("@default", newJSONObject())
+	return &nodeMap{graphs: graphs}
+}
+
+func (m *nodeMap) graph(name string) *jsonObject {
+	graph, ok := m.graphs.value(name).(*jsonObject)
+	if !ok {
+		graph = newJSONObject()
+		m.graphs.set(name, graph)
+	}
+	return graph
+}
+
+// generateNodeMap runs the Node Map Generation algorithm. activeSubject is a node identifier or,
+// for the values of a reverse property, a node reference; list collects the items of a list.
+func (p *jsonldProcessor) generateNodeMap(element any, nodes *nodeMap, activeGraph string, activeSubject any, activeProperty string, list *jsonObject) error {
+	if array, ok := element.([]any); ok {
+		for _, item := range array {
+			if err := p.generateNodeMap(item, nodes, activeGraph, activeSubject, activeProperty, list); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	object, ok := element.(*jsonObject)
+	if !ok {
+		return nil
+	}
+	element = object.clone()
+	object = element.(*jsonObject)
+
+	graph := nodes.graph(activeGraph)
+	var subjectNode *jsonObject
+	if id, ok := activeSubject.(string); ok {
+		subjectNode, _ = graph.value(id).(*jsonObject)
+	}
+
+	if types, ok := object.get("@type"); ok && !object.has("@value") {
+		var issued []any
+		for _, t := range asArray(types) {
+			if s, ok := t.(string); ok && isBlankNodeIdentifier(s) {
+				t = p.blankNodes.issue(s)
+			}
+			issued = append(issued, t)
+		}
+		object.set("@type", issued)
+	}
+
+	switch {
+	case object.has("@value"):
+		if list != nil {
+			list.set("@list", append(asArray(list.value("@list")), object))
+		} else if subjectNode != nil && !containsJSON(subjectNode.value(activeProperty), object) {
+			addValue(subjectNode, activeProperty, object, true)
+		}
+
+	case object.has("@list"):
+		result := newJSONObject()
+		result.set("@list", []any{})
+		if err := p.generateNodeMap(object.value("@list"), nodes, activeGraph, activeSubject, activeProperty, result); err != nil {
+			return err
+		}
+		if list != nil {
+			list.set("@list", append(asArray(list.value("@list")), result))
+		} else if subjectNode != nil {
+			addValue(subjectNode, activeProperty, result, true)
+		}
+
+	default:
+		var id string
+		if value, ok := object.get("@id"); ok {
+			id, _ = value.(string)
+			object.remove("@id")
+		}
+		if id == "" || isBlankNodeIdentifier(id) {
+			id = p.blankNodes.issue(id)
+		}
+		node, ok := graph.value(id).(*jsonObject)
+		if !ok {
+			node = newJSONObject()
+			node.set("@id", id)
+			graph.set(id, node)
+		}
+
+		reference := newJSONObject()
+		reference.set("@id", id)
+		if reverseSubject, ok := activeSubject.(*jsonObject); ok {
+			// The node is the subject of the reverse property, pointing back to the active subject
+			if !containsJSON(node.value(activeProperty), reverseSubject) {
+				addValue(node, activeProperty, reverseSubject, true)
+			}
+		} else if activeProperty != "" {
+			if list != nil {
+				list.set("@list", append(asArray(list.value("@list")), reference))
+			} else if subjectNode != nil && !containsJSON(subjectNode.value(activeProperty), reference) {
+				addValue(subjectNode, activeProperty, reference, true)
+			}
+		}
+
+		if types, ok := object.get("@type"); ok {
+			for _, t := range asArray(types) {
+				if !containsJSON(node.value("@type"), t) {
+					addValue(node, "@type", t, true)
+				}
+			}
+			object.remove("@type")
+		}
+
+		if index, ok := object.get("@index"); ok {
+			if existing, ok := node.get("@index"); ok && !jsonEqual(existing, index) {
+				return jsonldErrorf("conflicting indexes", "node %s has two different indexes", id)
+			}
+			node.set("@index", index)
+			object.remove("@index")
+		}
+
+		if reverseMap, ok := object.value("@reverse").(*jsonObject); ok {
+			for _, property := range reverseMap.keys {
+				for _, value := range asArray(reverseMap.value(property)) {
+					if err := p.generateNodeMap(value, nodes, activeGraph, reference, property, nil); err != nil {
+						return err
+					}
+				}
+			}
+			object.remove("@reverse")
+		}
+
+		if value, ok := object.get("@graph"); ok {
+			if err := p.generateNodeMap(value, nodes, id, nil, "", nil); err != nil {
+				return err
+			}
+			object.remove("@graph")
+		}
+
+		if value, ok := object.get("@included"); ok {
+			if err := p.generateNodeMap(value, nodes, activeGraph, nil, "", nil); err != nil {
+				return err
+			}
+			object.remove("@included")
+		}
+
+		for _, property := range object.keyList() {
+			value := object.value(property)
+			if isBlankNodeIdentifier(property) {
+				property = p.blankNodes.issue(property)
+			}
+			if !node.has(property) {
+				node.set(property, []any{})
+			}
+			if err := p.generateNodeMap(value, nodes, activeGraph, id, property, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// containsJSON reports whether an array value contains an item equal to value
+func containsJSON(array any, value any) bool {
+	if array == nil {
+		return false
+	}
+	for _, item := range asArray(array) {
+		if jsonEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// toRDF runs the Deserialize JSON-LD to RDF algorithm on an expanded document
+func (p *jsonldProcessor) toRDF(expanded []any) ([]*Quad, error) {
+	nodes := newNodeMap()
+	if err := p.generateNodeMap(expanded, nodes, "@default", nil, "", nil); err != nil {
+		return nil, err
+	}
+
+	var quads []*Quad
+	rdfType := NewNamedNode(rdfNamespace + "type")
+	for _, graphName := range nodes.graphs.keys {
+		var graphTerm Term = NewDefaultGraph()
+		if graphName != "@default" {
+			if graphTerm = jsonldResource(graphName); graphTerm == nil {
+				continue
+			}
+		}
+		graph := nodes.graphs.value(graphName).(*jsonObject)
+		for _, subject := range graph.keys {
+			subjectTerm := jsonldResource(subject)
+			if subjectTerm == nil {
+				continue
+			}
+			node := graph.value(subject).(*jsonObject)
+			for _, property := range node.keys {
+				values := node.value(property)
+				if property == "@type" {
+					for _, t := range asArray(values) {
+						s, _ := t.(string)
+						if object := jsonldResource(s); object != nil {
+							quads = append(quads, NewQuad(subjectTerm, rdfType, object, graphTerm))
+						}
+					}
+					continue
+				}
+				if isKeyword(property) || isBlankNodeIdentifier(property) || !isWellFormedIRI(property) {
+					continue
+				}
+				predicate := NewNamedNode(property)
+				for _, item := range asArray(values) {
+					var triples []*Triple
+					object := p.objectToRDF(item, &triples)
+					if object == nil {
+						continue
+					}
+					quads = append(quads, NewQuad(subjectTerm, predicate, object, graphTerm))
+					for _, triple := range triples {
+						quads = append(quads, NewQuad(triple.Subject, triple.Predicate, triple.Object, graphTerm))
+					}
+				}
+			}
+		}
+	}
+	return quads, nil
+}
+
+// objectToRDF converts a node, value or list object to an RDF term, adding the triples a list
+// or a compound literal needs; it returns nil for a value that has no RDF representation
+func (p *jsonldProcessor) objectToRDF(item any, triples *[]*Triple) Term {
+	object, ok := item.(*jsonObject)
+	if !ok {
+		return nil
+	}
+	if !object.has("@value") {
+		if list, ok := object.get("@list"); ok {
+			return p.listToRDF(asArray(list), triples)
+		}
+		id, _ := object.value("@id").(string)
+		return jsonldResource(id)
+	}
+
+	value := object.value("@value")
+	datatype, _ := object.value("@type").(string)
+	if datatype != "" && datatype != "@json" && !isWellFormedIRI(datatype) {
+		return nil
+	}
+	language, hasLanguage := object.value("@language").(string)
+	if hasLanguage && !wellFormedLanguageTag.MatchString(language) {
+		return nil
+	}
+
+	var lexical string
+	switch v := value.(type) {
+	case string:
+		lexical = v
+	case bool:
+		lexical = strconv.FormatBool(v)
+		if datatype == "" {
+			datatype = xsdNamespace + "boolean"
+		}
+	case float64:
+		if datatype == "@json" {
+			break
+		}
+		if v != math.Trunc(v) || math.Abs(v) >= 1e21 || datatype == xsdNamespace+"double" {
+			lexical = canonicalDouble(v)
+			if datatype == "" {
+				datatype = xsdNamespace + "double"
+			}
+		} else {
+			lexical = strconv.FormatFloat(v, 'f', -1, 64)
+			if datatype == "" {
+				datatype = xsdNamespace + "integer"
+			}
+		}
+	}
+	if datatype == "@json" {
+		lexical = canonicalJSON(value)
+		datatype = rdfNamespace + "JSON"
+	}
+
+	if direction, ok := object.value("@direction").(string); ok && p.rdfDirection != "" {
+		switch p.rdfDirection {
+		case "i18n-datatype":
+			return NewLiteralWithDatatype(lexical, NewNamedNode(i18nNamespace+strings.ToLower(language)+"_"+direction))
+		case "compound-literal":
+			node := NewBlankNode(strings.TrimPrefix(p.blankNodes.issue(""), "_:"))
+			*triples = append(*triples, NewTriple(node, NewNamedNode(rdfNamespace+"value"), NewLiteral(lexical)))
+			if hasLanguage {
+				*triples = append(*triples, NewTriple(node, NewNamedNode(rdfNamespace+"language"), NewLiteral(strings.ToLower(language))))
+			}
+			*triples = append(*triples, NewTriple(node, NewNamedNode(rdfNamespace+"direction"), NewLiteral(direction)))
+			return node
+		}
+	}
+
+	switch {
+	case hasLanguage:
+		return NewLiteralWithLanguage(lexical, language)
+	case datatype == "" || datatype == xsdNamespace+"string":
+		return NewLiteral(lexical)
+	default:
+		return NewLiteralWithDatatype(lexical, NewNamedNode(datatype))
+	}
+}
+
+// listToRDF converts the items of a list to an RDF collection, returning its head
+func (p *jsonldProcessor) listToRDF(items []any, triples *[]*Triple) Term {
+	if len(items) == 0 {
+		return NewNamedNode(rdfNamespace + "nil")
+	}
+	nodes := make([]*BlankNode, len(items))
+	for i := range items {
+		nodes[i] = NewBlankNode(strings.TrimPrefix(p.blankNodes.issue(""), "_:"))
+	}
+	first, rest := NewNamedNode(rdfNamespace+"first"), NewNamedNode(rdfNamespace+"rest")
+	for i, item := range items {
+		var itemTriples []*Triple
+		if object := p.objectToRDF(item, &itemTriples); object != nil {
+			*triples = append(*triples, NewTriple(nodes[i], first, object))
+		}
+		*triples = append(*triples, itemTriples...)
+		var next Term = NewNamedNode(rdfNamespace + "nil")
+		if i+1 < len(nodes) {
+			next = nodes[i+1]
+		}
+		*triples = append(*triples, NewTriple(nodes[i], rest, next))
+	}
+	return nodes[0]
+}
+
+// jsonldResource returns the term for a node identifier, nil when it isn't a blank node
+// identifier or a well-formed IRI
+func jsonldResource(id string) Term {
+	if isBlankNodeIdentifier(id) {
+		return NewBlankNode(id[2:])
+	}
+	if isWellFormedIRI(id) {
+		return NewNamedNode(id)
+	}
+	return nil
+}
+
+// wellFormedLanguageTag matches language tags in the general form BCP 47 gives them
+var wellFormedLanguageTag = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+
+// isWellFormedIRI reports whether a string is an absolute IRI without characters IRIs exclude
+func isWellFormedIRI(s string) bool {
+	if !isAbsoluteIRI(s) {
+		return false
+	}
+	for _, r := range s {
+		if r <= 0x20 || strings.ContainsRune("<>\"{}|\\^`", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalDouble writes a number in the canonical form of xsd:double, such as 1.1E0
+func canonicalDouble(v float64) string {
+	s := strconv.FormatFloat(v, 'E', -1, 64)
+	mantissa, exponent, _ := strings.Cut(s, "E")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	sign := ""
+	if exponent[0] == '-' {
+		sign = "-"
+	}
+	exponent = strings.TrimLeft(exponent[1:], "0")
+	if exponent == "" {
+		exponent = "0"
+	}
+	return mantissa + "E" + sign + exponent
+}
@@ -1,6 +1,7 @@
 package rdf
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
@@ -468,3 +469,183 @@ func TestJSONLDParser_ContextInArray(t *testing.T) {
 		t.Errorf("Expected subject http://example.org/alice, got %s", getIRI(quads[0].Subject))
 	}
 }
+
+// assertJSONLDQuads parses JSON-LD and compares the result with the expected N-Quads
+func assertJSONLDQuads(t *testing.T, parser *JSONLDParser, input, expected string) {
+	t.Helper()
+	quads, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	expectedQuads, err := NewNQuadsParser(expected).Parse()
+	if err != nil {
+		t.Fatalf("Invalid expected N-Quads: %v", err)
+	}
+	if !AreQuadsIsomorphic(expectedQuads, quads) {
+		t.Errorf("Unexpected quads:\n%s\nExpected:\n%s", SerializeQuadsCanonical(quads), expected)
+	}
+}
+
+func TestJSONLDParser_GraphListAndReverse(t *testing.T) {
+	input := `{
+  "@context": {
+    "@vocab": "http://example.org/",
+    "@language": "en",
+    "knows": {"@type": "@id"},
+    "steps": {"@container": "@list"},
+    "parent": {"@reverse": "http://example.org/child"}
+  },
+  "@id": "http://example.org/g",
+  "@graph": [{
+    "@id": "http://example.org/alice",
+    "@type": "Person",
+    "name": "Alice",
+    "knows": "http://example.org/bob",
+    "steps": [1, 2.5, true],
+    "parent": {"@id": "http://example.org/carol"}
+  }]
+}`
+	expected := `<http://example.org/alice> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Person> <http://example.org/g> .
+<http://example.org/alice> <http://example.org/name> "Alice"@en <http://example.org/g> .
+<http://example.org/alice> <http://example.org/knows> <http://example.org/bob> <http://example.org/g> .
+<http://example.org/alice> <http://example.org/steps> _:l0 <http://example.org/g> .
+_:l0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "1"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/g> .
+_:l0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:l1 <http://example.org/g> .
+_:l1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "2.5E0"^^<http://www.w3.org/2001/XMLSchema#double> <http://example.org/g> .
+_:l1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:l2 <http://example.org/g> .
+_:l2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> <http://example.org/g> .
+_:l2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> <http://example.org/g> .
+<http://example.org/carol> <http://example.org/child> <http://example.org/alice> <http://example.org/g> .
+`
+	assertJSONLDQuads(t, NewJSONLDParser(), input, expected)
+}
+
+func TestJSONLDParser_ScopedContextsAndContainers(t *testing.T) {
+	input := `{
+  "@context": [
+    {"@vocab": "http://example.org/", "@base": "http://example.org/books/"},
+    {
+      "Book": {"@context": {"title": "http://purl.org/dc/terms/title"}},
+      "labels": {"@container": "@language"},
+      "byID": {"@container": "@id"},
+      "meta": {"@id": "http://example.org/meta", "@context": {"@vocab": "http://other.example/"}},
+      "data": {"@type": "@json"},
+      "details": "@nest"
+    }
+  ],
+  "@id": "moby",
+  "@type": "Book",
+  "title": "Moby Dick",
+  "labels": {"en": "Whale", "de": ["Wal"]},
+  "byID": {"../people/herman": {"name": "Herman"}},
+  "meta": {"source": "archive"},
+  "data": {"b": [1, 2.5], "a": null},
+  "details": {"pages": 635},
+  "related": {"title": "not a book title"},
+  "@included": [{"@id": "http://example.org/library", "name": "Library"}]
+}`
+	expected := `<http://example.org/books/moby> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Book> .
+<http://example.org/books/moby> <http://purl.org/dc/terms/title> "Moby Dick" .
+<http://example.org/books/moby> <http://example.org/labels> "Whale"@en .
+<http://example.org/books/moby> <http://example.org/labels> "Wal"@de .
+<http://example.org/books/moby> <http://example.org/byID> <http://example.org/people/herman> .
+<http://example.org/people/herman> <http://example.org/name> "Herman" .
+<http://example.org/books/moby> <http://example.org/meta> _:m .
+_:m <http://other.example/source> "archive" .
+<http://example.org/books/moby> <http://example.org/data> "{\"a\":null,\"b\":[1,2.5]}"^^<http://www.w3.org/1999/02/22-rdf-syntax-ns#JSON> .
+<http://example.org/books/moby> <http://example.org/pages> "635"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/books/moby> <http://example.org/related> _:r .
+_:r <http://example.org/title> "not a book title" .
+<http://example.org/library> <http://example.org/name> "Library" .
+`
+	assertJSONLDQuads(t, NewJSONLDParser(), input, expected)
+}
+
+func TestJSONLDParser_RemoteContext(t *testing.T) {
+	input := `{
+  "@context": "https://example.org/contexts/person.jsonld",
+  "@id": "alice",
+  "name": "Alice"
+}`
+	loader := NewLocalDocumentLoader()
+	loader.AddDocument("https://example.org/contexts/person.jsonld",
+		[]byte(`{"@context": {"name": "http://xmlns.com/foaf/0.1/name"}}`))
+
+	parser := NewJSONLDParser()
+	parser.SetBaseURI("https://example.org/people/")
+	parser.SetDocumentLoader(loader)
+	assertJSONLDQuads(t, parser, input,
+		`<https://example.org/people/alice> <http://xmlns.com/foaf/0.1/name> "Alice" .`+"\n")
+
+	// Without a document loader, remote contexts are not retrieved
+	_, err := NewJSONLDParser().Parse(strings.NewReader(input))
+	var jsonldErr *JSONLDError
+	if !errors.As(err, &jsonldErr) || jsonldErr.Code != "loading remote context failed" {
+		t.Errorf("Expected a loading remote context failed error, got %v", err)
+	}
+}
+
+func TestJSONLDParser_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+	}{
+		{`{"@context": {"a": "b:x", "b": "a:y"}, "a": "v"}`, "cyclic IRI mapping"},
+		{`{"@context": {"@id": "http://example.org/"}}`, "keyword redefinition"},
+		{`{"@context": {"id": "@id"}, "id": 5}`, "invalid @id value"},
+		{`{"@context": {"id": "@id"}, "id": "http://example.org/a", "@id": "http://example.org/b"}`, "colliding keywords"},
+		{`{"http://example.org/p": {"@value": "x", "@type": "http://example.org/t", "@language": "en"}}`, "invalid value object"},
+		{`{"@context": {"@protected": true, "p": "http://example.org/p"}, "http://example.org/q": {"@context": {"p": "http://example.org/other"}, "p": 1}}`, "protected term redefinition"},
+		{`[1, 2`, "loading document failed"},
+	}
+	for _, tt := range tests {
+		_, err := NewJSONLDParser().Parse(strings.NewReader(tt.input))
+		var jsonldErr *JSONLDError
+		if !errors.As(err, &jsonldErr) || jsonldErr.Code != tt.code {
+			t.Errorf("%s: expected error %q, got %v", tt.input, tt.code, err)
+		}
+	}
+}
+
+func TestJSONLDParser_RDFDirection(t *testing.T) {
+	input := `{
+  "@id": "http://example.org/s",
+  "http://example.org/p": {"@value": "مرحبا", "@language": "ar-EG", "@direction": "rtl"}
+}`
+	parser := NewJSONLDParser()
+	parser.SetRDFDirection("i18n-datatype")
+	assertJSONLDQuads(t, parser, input,
+		`<http://example.org/s> <http://example.org/p> "مرحبا"^^<https://www.w3.org/ns/i18n#ar-eg_rtl> .`+"\n")
+
+	parser = NewJSONLDParser()
+	parser.SetRDFDirection("compound-literal")
+	assertJSONLDQuads(t, parser, input, `<http://example.org/s> <http://example.org/p> _:c .
+_:c <http://www.w3.org/1999/02/22-rdf-syntax-ns#value> "مرحبا" .
+_:c <http://www.w3.org/1999/02/22-rdf-syntax-ns#language> "ar-eg" .
+_:c <http://www.w3.org/1999/02/22-rdf-syntax-ns#direction> "rtl" .
+`)
+}
+
+func TestResolveIRI(t *testing.T) {
+	base := "http://a/b/c/d;p?q"
+	tests := map[string]string{
+		"g:h":           "g:h",
+		"g":             "http://a/b/c/g",
+		"./g":           "http://a/b/c/g",
+		"g/":            "http://a/b/c/g/",
+		"/g":            "http://a/g",
+		"//g":           "http://g",
+		"?y":            "http://a/b/c/d;p?y",
+		"#s":            "http://a/b/c/d;p?q#s",
+		"":              "http://a/b/c/d;p?q",
+		"../..":         "http://a/",
+		"../../g":       "http://a/g",
+		"../../../../g": "http://a/g",
+		"g;x=1/../y":    "http://a/b/c/y",
+	}
+	for reference, expected := range tests {
+		if got := resolveIRI(base, reference); got != expected {
+			t.Errorf("resolveIRI(%q): expected %s, got %s", reference, expected, got)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package rdf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// JSONLDSerializer writes quads as JSON-LD 1.1 by running the Serialize RDF as JSON-LD
+// algorithm. The result is in expanded form unless a context is set, in which case it is
+// compacted against it, or a frame is set, in which case it is framed and compacted against
+// the frame's context. Named graphs become the @graph of a node.
+type JSONLDSerializer struct {
+	baseURI        string
+	loader         DocumentLoader
+	processingMode string
+	rdfDirection   string
+	context        any
+	frame          any
+
+	useNativeTypes    bool
+	useRDFType        bool
+	compactArrays     bool
+	compactToRelative bool
+	framing           framingOptions
+}
+
+// NewJSONLDSerializer creates a JSON-LD serializer writing expanded JSON-LD
+func NewJSONLDSerializer() *JSONLDSerializer {
+	return &JSONLDSerializer{compactArrays: true, compactToRelative: true}
+}
+
+// SetBaseURI sets the base IRI compacted IRIs are written relative to
+func (s *JSONLDSerializer) SetBaseURI(baseURI string) {
+	s.baseURI = baseURI
+}
+
+// SetDocumentLoader sets the loader remote contexts are retrieved with
+func (s *JSONLDSerializer) SetDocumentLoader(loader DocumentLoader) {
+	s.loader = loader
+}
+
+// SetProcessingMode sets the processing mode, "json-ld-1.1" (the default) or "json-ld-1.0"
+func (s *JSONLDSerializer) SetProcessingMode(mode string) {
+	s.processingMode = mode
+}
+
+// SetRDFDirection sets how base directions are read from RDF: "i18n-datatype",
+// "compound-literal", or "" (the default) to leave such literals as they are
+func (s *JSONLDSerializer) SetRDFDirection(mode string) {
+	s.rdfDirection = mode
+}
+
+// SetContext sets the context the output is compacted with, given as a JSON context or a
+// JSON object with a @context entry; nil goes back to expanded output
+func (s *JSONLDSerializer) SetContext(context []byte) error {
+	if context == nil {
+		s.context = nil
+		return nil
+	}
+	value, err := decodeJSON(context)
+	if err != nil {
+		return fmt.Errorf("error parsing JSON-LD context: %w", err)
+	}
+	s.context = value
+	return nil
+}
+
+// SetFrame sets the JSON-LD frame the output is shaped with; nil turns framing off
+func (s *JSONLDSerializer) SetFrame(frame []byte) error {
+	if frame == nil {
+		s.frame = nil
+		return nil
+	}
+	value, err := decodeJSON(frame)
+	if err != nil {
+		return fmt.Errorf("error parsing JSON-LD frame: %w", err)
+	}
+	if _, ok := value.(*jsonObject); !ok {
+		return jsonldErrorf("invalid frame", "a frame must be a JSON object")
+	}
+	s.frame = value
+	return nil
+}
+
+// SetUseNativeTypes writes xsd:boolean, xsd:integer and xsd:double literals as JSON values
+func (s *JSONLDSerializer) SetUseNativeTypes(useNativeTypes bool) {
+	s.useNativeTypes = useNativeTypes
+}
+
+// SetUseRDFType writes rdf:type as a property instead of @type
+func (s *JSONLDSerializer) SetUseRDFType(useRDFType bool) {
+	s.useRDFType = useRDFType
+}
+
+// SetCompactArrays sets whether arrays of one value are replaced with the value (the default)
+func (s *JSONLDSerializer) SetCompactArrays(compactArrays bool) {
+	s.compactArrays = compactArrays
+}
+
+// SetCompactToRelative sets whether IRIs are written relative to the base IRI (the default)
+func (s *JSONLDSerializer) SetCompactToRelative(compactToRelative bool) {
+	s.compactToRelative = compactToRelative
+}
+
+// SetEmbed sets how framing embeds nodes referenced more than once: "@once" (the default)
+// embeds the first reference, "@always" every one and "@never" none
+func (s *JSONLDSerializer) SetEmbed(embed string) {
+	s.framing.embed = embed
+}
+
+// SetExplicit makes framing leave out properties the frame doesn't list
+func (s *JSONLDSerializer) SetExplicit(explicit bool) {
+	s.framing.explicit = explicit
+}
+
+// SetRequireAll makes a node match a frame only when all its properties match
+func (s *JSONLDSerializer) SetRequireAll(requireAll bool) {
+	s.framing.requireAll = requireAll
+}
+
+// SetOmitDefault makes framing leave out properties the node doesn't have instead of writing
+// their default value
+func (s *JSONLDSerializer) SetOmitDefault(omitDefault bool) {
+	s.framing.omitDefault = omitDefault
+}
+
+// SetOmitGraph sets whether a framed result with a single node is written without @graph,
+// which by default it is in JSON-LD 1.1 and not in 1.0
+func (s *JSONLDSerializer) SetOmitGraph(omitGraph bool) {
+	s.framing.omitGraph = &omitGraph
+}
+
+func (s *JSONLDSerializer) ContentType() string {
+	return "application/ld+json"
+}
+
+func (s *JSONLDSerializer) Serialize(writer io.Writer, quads []*Quad) error {
+	processor := s.newProcessor()
+	expanded, err := processor.fromRDF(quads, fromRDFOptions{useNativeTypes: s.useNativeTypes, useRDFType: s.useRDFType})
+	if err != nil {
+		return err
+	}
+	return s.write(writer, processor, expanded)
+}
+
+// SerializeDocument reads a JSON-LD document and writes it the way Serialize writes quads:
+// expanded, compacted or framed
+func (s *JSONLDSerializer) SerializeDocument(writer io.Writer, reader io.Reader) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("error reading JSON-LD: %w", err)
+	}
+	document, err := decodeJSON(data)
+	if err != nil {
+		return jsonldErrorf("loading document failed", "error parsing JSON: %v", err)
+	}
+
+	processor := s.newProcessor()
+	expanded, err := processor.expandDocument(document, s.baseURI, nil, expandOptions{ordered: processor.ordered})
+	if err != nil {
+		return err
+	}
+	return s.write(writer, processor, expanded)
+}
+
+func (s *JSONLDSerializer) newProcessor() *jsonldProcessor {
+	processor := newJSONLDProcessor(s.loader, s.processingMode)
+	processor.rdfDirection = s.rdfDirection
+	processor.compactArrays = s.compactArrays
+	processor.compactToRelative = s.compactToRelative
+	return processor
+}
+
+// write shapes an expanded document with the frame or context and writes it as indented JSON
+func (s *JSONLDSerializer) write(writer io.Writer, processor *jsonldProcessor, expanded []any) error {
+	var result any = expanded
+	var err error
+	switch {
+	case s.frame != nil:
+		result, err = processor.frameDocument(expanded, s.frame, s.baseURI, s.framing)
+	case s.context != nil:
+		result, err = processor.compactDocument(expanded, s.context, s.baseURI, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return fmt.Errorf("error writing JSON-LD: %w", err)
+	}
+	return nil
+}
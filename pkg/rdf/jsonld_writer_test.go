@@ -0,0 +1,203 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+// turtleQuads parses Turtle input into quads in the default graph
+func turtleQuads(t *testing.T, input string) []*Quad {
+	t.Helper()
+	triples, err := NewTurtleParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	quads := make([]*Quad, len(triples))
+	for i, triple := range triples {
+		quads[i] = NewQuad(triple.Subject, triple.Predicate, triple.Object, NewDefaultGraph())
+	}
+	return quads
+}
+
+// assertJSONLD compares JSON output with the expected JSON, ignoring whitespace
+func assertJSONLD(t *testing.T, output, expected string) {
+	t.Helper()
+	actualValue, err := decodeJSON([]byte(output))
+	if err != nil {
+		t.Fatalf("Output is not JSON: %v\n%s", err, output)
+	}
+	expectedValue, err := decodeJSON([]byte(expected))
+	if err != nil {
+		t.Fatalf("Expected value is not JSON: %v", err)
+	}
+	if canonicalJSON(actualValue) != canonicalJSON(expectedValue) {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", output, expected)
+	}
+}
+
+func TestJSONLDSerializer_FromRDF(t *testing.T) {
+	quads := turtleQuads(t, `@prefix ex: <http://example.org/> .
+ex:alice a ex:Person ;
+    ex:name "Alice"@en ;
+    ex:age 30 ;
+    ex:tags ( "a" "b" ) .`)
+	quads = append(quads, NewQuad(NewNamedNode("http://example.org/alice"), NewNamedNode("http://example.org/knows"),
+		NewNamedNode("http://example.org/bob"), NewNamedNode("http://example.org/g")))
+
+	serializer := NewJSONLDSerializer()
+	serializer.SetUseNativeTypes(true)
+	var out strings.Builder
+	if err := serializer.Serialize(&out, quads); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	assertJSONLD(t, out.String(), `[
+  {
+    "@id": "http://example.org/alice",
+    "@type": ["http://example.org/Person"],
+    "http://example.org/name": [{"@value": "Alice", "@language": "en"}],
+    "http://example.org/age": [{"@value": 30}],
+    "http://example.org/tags": [{"@list": [{"@value": "a"}, {"@value": "b"}]}]
+  },
+  {
+    "@id": "http://example.org/g",
+    "@graph": [
+      {"@id": "http://example.org/alice", "http://example.org/knows": [{"@id": "http://example.org/bob"}]}
+    ]
+  }
+]`)
+
+	// The expanded output reads back as the same quads
+	reparsed, err := NewJSONLDParser().Parse(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatalf("Output does not parse: %v", err)
+	}
+	if !AreQuadsIsomorphic(quads, reparsed) {
+		t.Errorf("Round trip changed the quads:\n%s", out.String())
+	}
+}
+
+func TestJSONLDSerializer_Compact(t *testing.T) {
+	quads := turtleQuads(t, `@prefix ex: <http://example.org/> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+<http://example.com/people/alice> ex:name "Alice"@en, "Alicia"@es ;
+    ex:born "1990-01-01"^^xsd:date ;
+    ex:knows <http://example.com/people/bob> ;
+    ex:seeAlso <http://other.org/alice> .`)
+
+	serializer := NewJSONLDSerializer()
+	serializer.SetBaseURI("http://example.com/people/")
+	if err := serializer.SetContext([]byte(`{"@context": {
+  "ex": "http://example.org/",
+  "xsd": "http://www.w3.org/2001/XMLSchema#",
+  "name": {"@id": "ex:name", "@container": "@language"},
+  "born": {"@id": "ex:born", "@type": "xsd:date"},
+  "knows": {"@id": "ex:knows", "@type": "@id"}
+}}`)); err != nil {
+		t.Fatalf("SetContext failed: %v", err)
+	}
+	var out strings.Builder
+	if err := serializer.Serialize(&out, quads); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	assertJSONLD(t, out.String(), `{
+  "@context": {
+    "ex": "http://example.org/",
+    "xsd": "http://www.w3.org/2001/XMLSchema#",
+    "name": {"@id": "ex:name", "@container": "@language"},
+    "born": {"@id": "ex:born", "@type": "xsd:date"},
+    "knows": {"@id": "ex:knows", "@type": "@id"}
+  },
+  "@id": "alice",
+  "name": {"en": "Alice", "es": "Alicia"},
+  "born": "1990-01-01",
+  "knows": "bob",
+  "ex:seeAlso": {"@id": "http://other.org/alice"}
+}`)
+}
+
+func TestJSONLDSerializer_Frame(t *testing.T) {
+	input := `{
+  "@context": {"@vocab": "http://example.org/", "contains": {"@type": "@id"}},
+  "@graph": [
+    {"@id": "http://example.org/library", "@type": "Library", "contains": "http://example.org/library/the-republic"},
+    {"@id": "http://example.org/library/the-republic", "@type": "Book", "creator": "Plato", "title": "The Republic",
+     "contains": "http://example.org/library/the-republic#introduction"},
+    {"@id": "http://example.org/library/the-republic#introduction", "@type": "Chapter",
+     "description": "An introductory chapter on The Republic.", "title": "The Introduction"}
+  ]
+}`
+	quads, err := NewJSONLDParser().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	serializer := NewJSONLDSerializer()
+	if err := serializer.SetFrame([]byte(`{
+  "@context": {"@vocab": "http://example.org/"},
+  "@type": "Library",
+  "contains": {"@type": "Book", "contains": {"@type": "Chapter"}, "publisher": {"@default": "unknown"}}
+}`)); err != nil {
+		t.Fatalf("SetFrame failed: %v", err)
+	}
+	var out strings.Builder
+	if err := serializer.Serialize(&out, quads); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	assertJSONLD(t, out.String(), `{
+  "@context": {"@vocab": "http://example.org/"},
+  "@id": "http://example.org/library",
+  "@type": "Library",
+  "contains": {
+    "@id": "http://example.org/library/the-republic",
+    "@type": "Book",
+    "contains": {
+      "@id": "http://example.org/library/the-republic#introduction",
+      "@type": "Chapter",
+      "description": "An introductory chapter on The Republic.",
+      "title": "The Introduction"
+    },
+    "creator": "Plato",
+    "title": "The Republic",
+    "publisher": "unknown"
+  }
+}`)
+
+	// With @embed @never the chapter stays a reference
+	if err := serializer.SetFrame([]byte(`{
+  "@context": {"@vocab": "http://example.org/"},
+  "@type": "Book",
+  "contains": {"@embed": "@never"}
+}`)); err != nil {
+		t.Fatalf("SetFrame failed: %v", err)
+	}
+	out.Reset()
+	if err := serializer.Serialize(&out, quads); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	assertJSONLD(t, out.String(), `{
+  "@context": {"@vocab": "http://example.org/"},
+  "@id": "http://example.org/library/the-republic",
+  "@type": "Book",
+  "contains": {"@id": "http://example.org/library/the-republic#introduction"},
+  "creator": "Plato",
+  "title": "The Republic"
+}`)
+}
+
+func TestJSONLDSerializer_Errors(t *testing.T) {
+	serializer := NewJSONLDSerializer()
+	if err := serializer.SetFrame([]byte(`[{}]`)); err == nil {
+		t.Error("Expected an error for a frame that isn't an object")
+	}
+	if err := serializer.SetFrame([]byte(`{"@id": "_:b0"}`)); err != nil {
+		t.Fatalf("SetFrame failed: %v", err)
+	}
+	err := serializer.Serialize(&strings.Builder{}, turtleQuads(t, `<http://example.org/a> <http://example.org/p> "x" .`))
+	if jsonldErr, ok := err.(*JSONLDError); !ok || jsonldErr.Code != "invalid frame" {
+		t.Errorf("Expected an invalid frame error, got %v", err)
+	}
+
+	if _, err := NewSerializer("application/ld+json"); err != nil {
+		t.Errorf("NewSerializer failed for JSON-LD: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package rdf
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -15,6 +16,8 @@ type NQuadsParser struct {
 	prefixes   map[string]string
 	baseIRI    string
 	strictMode bool // When true, enforce strict N-Quads/N-Triples syntax
+	lenient    bool // When true, skip lines with syntax errors
+	errors     []*ParseError
 }
 
 // NewNQuadsParser creates a new N-Quads parser with strict validation
@@ -28,43 +31,40 @@ func NewNQuadsParser(input string) *NQuadsParser {
 	}
 }
 
+// reset points the parser at new input so a document can be parsed a line at a time
+func (p *NQuadsParser) reset(input string) {
+	p.input = input
+	p.pos = 0
+	p.length = len(input)
+}
+
+// SetLenient makes Parse skip lines with syntax errors and carry on with the next line,
+// collecting the errors instead of failing
+func (p *NQuadsParser) SetLenient(lenient bool) {
+	p.lenient = lenient
+}
+
+// Errors returns the syntax errors skipped in lenient mode
+func (p *NQuadsParser) Errors() []*ParseError {
+	return p.errors
+}
+
 // Parse parses the N-Quads document and returns quads
 func (p *NQuadsParser) Parse() ([]*Quad, error) {
 	var quads []*Quad
 
 	for p.pos < p.length {
 		p.skipWhitespaceAndComments()
-		if p.pos >= p.length {
-			break
-		}
-
-		// Check for PREFIX directive (optional Turtle extension)
-		if p.matchKeyword("@prefix") || p.matchKeyword("PREFIX") {
-			if p.strictMode {
-				return nil, fmt.Errorf("PREFIX directive not allowed in N-Quads")
-			}
-			if err := p.parsePrefix(); err != nil {
-				return nil, err
-			}
-			continue
-		}
-
-		// Check for BASE directive (optional Turtle extension)
-		if p.matchKeyword("@base") || p.matchKeyword("BASE") {
-			if p.strictMode {
-				return nil, fmt.Errorf("BASE directive not allowed in N-Quads")
-			}
-			if err := p.parseBase(); err != nil {
+		start := p.pos
+		quad, err := p.parseStatement()
+		if err != nil {
+			err = NewParseError(p.input, p.pos, err)
+			if !p.lenient {
 				return nil, err
 			}
+			p.recover(start, err)
 			continue
 		}
-
-		// Parse quad (or triple as quad in default graph)
-		quad, err := p.parseQuad()
-		if err != nil {
-			return nil, err
-		}
 		if quad != nil {
 			quads = append(quads, quad)
 		}
@@ -73,6 +73,46 @@ func (p *NQuadsParser) Parse() ([]*Quad, error) {
 	return quads, nil
 }
 
+// parseStatement parses a directive or a quad, returning nil for a directive
+func (p *NQuadsParser) parseStatement() (*Quad, error) {
+	if p.pos >= p.length {
+		return nil, nil
+	}
+
+	// Check for PREFIX directive (optional Turtle extension)
+	if p.matchKeyword("@prefix") || p.matchKeyword("PREFIX") {
+		if p.strictMode {
+			return nil, fmt.Errorf("PREFIX directive not allowed in N-Quads")
+		}
+		return nil, p.parsePrefix()
+	}
+
+	// Check for BASE directive (optional Turtle extension)
+	if p.matchKeyword("@base") || p.matchKeyword("BASE") {
+		if p.strictMode {
+			return nil, fmt.Errorf("BASE directive not allowed in N-Quads")
+		}
+		return nil, p.parseBase()
+	}
+
+	// Parse quad (or triple as quad in default graph)
+	return p.parseQuad()
+}
+
+// recover records a syntax error in lenient mode and skips to the line after the one the
+// failed statement starts on
+func (p *NQuadsParser) recover(start int, err error) {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		p.errors = append(p.errors, parseErr)
+	}
+	if end := strings.IndexByte(p.input[start:], '\n'); end != -1 {
+		p.pos = start + end + 1
+	} else {
+		p.pos = p.length
+	}
+}
+
 // skipWhitespaceAndComments skips whitespace and comments
 func (p *NQuadsParser) skipWhitespaceAndComments() {
 	for p.pos < p.length {
@@ -216,8 +256,8 @@ func (p *NQuadsParser) parseQuad() (*Quad, error) {
 
 	// Parse optional graph (4th position)
 	var graph Term
-	if p.pos < p.length && p.input[p.pos] == '<' {
-		// Graph IRI
+	if p.pos < p.length && (p.input[p.pos] == '<' || !p.strictMode && p.input[p.pos] != '.') {
+		// Graph IRI, or a prefixed name outside strict mode
 		graph, err = p.parseTerm()
 		if err != nil {
 			return nil, fmt.Errorf("error parsing graph: %w", err)
@@ -252,6 +292,9 @@ func (p *NQuadsParser) parseQuad() (*Quad, error) {
 
 // parseTerm parses an RDF term (IRI, blank node, literal, or quoted triple)
 func (p *NQuadsParser) parseTerm() (Term, error) {
+	if p.pos >= p.length {
+		return nil, fmt.Errorf("unexpected end of input, expected a term")
+	}
 	ch := p.input[p.pos]
 
 	switch ch {
@@ -388,6 +431,10 @@ func (p *NQuadsParser) parseLiteral() (Term, error) {
 		if ch == '"' {
 			break
 		}
+		if ch == '\n' || ch == '\r' {
+			// Line breaks must be escaped in single-line strings
+			return nil, fmt.Errorf("unclosed string literal")
+		}
 		if ch == '\\' {
 			// Handle escape sequences
 			p.pos++
@@ -642,9 +689,13 @@ func (p *NQuadsParser) processUnicodeEscape() (string, error) {
 	return string(rune(codePoint)), nil
 }
 
-// parseNumber parses a numeric literal
+// parseNumber parses a numeric literal outside strict mode, typed as in Turtle:
+// xsd:integer, xsd:decimal with a fraction, xsd:double with an exponent.
+// A '.' not followed by a digit or an exponent ends the statement rather than the number.
 func (p *NQuadsParser) parseNumber() (Term, error) {
 	start := p.pos
+	isDecimal := false
+	isDouble := false
 
 	// Optional sign
 	if p.pos < p.length && (p.input[p.pos] == '-' || p.input[p.pos] == '+') {
@@ -657,41 +708,50 @@ func (p *NQuadsParser) parseNumber() (Term, error) {
 		p.pos++
 		hasDigits = true
 	}
+	if !hasDigits {
+		return nil, fmt.Errorf("invalid number at position %d", start)
+	}
 
 	// Check for decimal point
-	isDecimal := false
-	if p.pos < p.length && p.input[p.pos] == '.' {
-		isDecimal = true
-		p.pos++
-		for p.pos < p.length && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+	if p.pos+1 < p.length && p.input[p.pos] == '.' {
+		next := p.input[p.pos+1]
+		if next >= '0' && next <= '9' {
+			isDecimal = true
+			p.pos++
+			for p.pos < p.length && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+				p.pos++
+			}
+		} else if next == 'e' || next == 'E' {
+			// "1.e5" is a double
 			p.pos++
-			hasDigits = true
 		}
 	}
 
 	// Check for exponent
 	if p.pos < p.length && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
-		isDecimal = true
+		isDouble = true
 		p.pos++
 		if p.pos < p.length && (p.input[p.pos] == '-' || p.input[p.pos] == '+') {
 			p.pos++
 		}
+		expHasDigits := false
 		for p.pos < p.length && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
 			p.pos++
+			expHasDigits = true
+		}
+		if !expHasDigits {
+			return nil, fmt.Errorf("expected digits in exponent at position %d", start)
 		}
-	}
-
-	if !hasDigits {
-		return nil, fmt.Errorf("invalid number at position %d", start)
 	}
 
 	numStr := p.input[start:p.pos]
 
-	if isDecimal {
-		// Parse as double
+	switch {
+	case isDouble:
 		return NewLiteralWithDatatype(numStr, XSDDouble), nil
-	} else {
-		// Parse as integer
+	case isDecimal:
+		return NewLiteralWithDatatype(numStr, XSDDecimal), nil
+	default:
 		return NewLiteralWithDatatype(numStr, XSDInteger), nil
 	}
 }
@@ -0,0 +1,274 @@
+package rdf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PatchContentType is the media type of RDF Patch documents
+const PatchContentType = "application/rdf-patch"
+
+// PatchOperation is the kind of a row of an RDF Patch
+type PatchOperation string
+
+const (
+	PatchHeader       PatchOperation = "H"  // Header: a name and a value, such as the patch id
+	PatchBegin        PatchOperation = "TX" // Start of a transaction
+	PatchCommit       PatchOperation = "TC" // Commit of the transaction
+	PatchAbort        PatchOperation = "TA" // Abort of the transaction, discarding its rows
+	PatchAddPrefix    PatchOperation = "PA" // Add a prefix
+	PatchDeletePrefix PatchOperation = "PD" // Delete a prefix
+	PatchAdd          PatchOperation = "A"  // Add a quad
+	PatchDelete       PatchOperation = "D"  // Delete a quad
+)
+
+// PatchRow is a row of an RDF Patch
+type PatchRow struct {
+	Operation PatchOperation
+	Quad      *Quad  // The quad of A and D rows
+	Prefix    string // The prefix of PA and PD rows, without the ':'
+	IRI       string // The namespace IRI of PA rows
+	Header    string // The name of H rows
+	Value     Term   // The value of H rows
+}
+
+// Patch is an RDF Patch: a log of changes to a dataset, made of rows adding and deleting
+// quads and prefixes, optionally grouped into transactions
+type Patch struct {
+	Rows []*PatchRow
+}
+
+// Changes returns the rows of a patch that change a dataset, without the rows of aborted
+// transactions, after checking that transaction rows are properly paired
+func (p *Patch) Changes() ([]*PatchRow, error) {
+	var changes []*PatchRow
+	inTransaction := false
+	start := 0 // Where the rows of the current transaction start in changes
+	for _, row := range p.Rows {
+		switch row.Operation {
+		case PatchHeader:
+		case PatchBegin:
+			if inTransaction {
+				return nil, fmt.Errorf("TX inside a transaction")
+			}
+			inTransaction = true
+			start = len(changes)
+		case PatchCommit, PatchAbort:
+			if !inTransaction {
+				return nil, fmt.Errorf("%s outside a transaction", row.Operation)
+			}
+			inTransaction = false
+			if row.Operation == PatchAbort {
+				changes = changes[:start]
+			}
+		case PatchAdd, PatchDelete, PatchAddPrefix, PatchDeletePrefix:
+			changes = append(changes, row)
+		default:
+			return nil, fmt.Errorf("unknown patch row %q", row.Operation)
+		}
+	}
+	if inTransaction {
+		return nil, fmt.Errorf("transaction not committed or aborted at the end of the patch")
+	}
+	return changes, nil
+}
+
+// Serialize writes a patch in the RDF Patch text format, one row per line
+func (p *Patch) Serialize(writer io.Writer) error {
+	buffered := bufio.NewWriter(writer)
+	for _, row := range p.Rows {
+		switch row.Operation {
+		case PatchAdd, PatchDelete:
+			buffered.WriteString(string(row.Operation) + " " + serializeQuadCanonical(row.Quad))
+		case PatchHeader:
+			fmt.Fprintf(buffered, "H %s %s .\n", row.Header, serializeTermCanonical(row.Value))
+		case PatchAddPrefix:
+			fmt.Fprintf(buffered, "PA \"%s\" \"%s\" .\n", escapeStringCanonical(row.Prefix), escapeStringCanonical(row.IRI))
+		case PatchDeletePrefix:
+			fmt.Fprintf(buffered, "PD \"%s\" .\n", escapeStringCanonical(row.Prefix))
+		case PatchBegin, PatchCommit, PatchAbort:
+			buffered.WriteString(string(row.Operation) + " .\n")
+		default:
+			return fmt.Errorf("unknown patch row %q", row.Operation)
+		}
+	}
+	return buffered.Flush()
+}
+
+// DiffQuads returns the patch that turns one set of quads into another: a transaction
+// deleting the quads only in from, then adding the quads only in to, each in canonical
+// N-Quads order. Blank nodes are compared by label. Two equal sets give an empty patch.
+func DiffQuads(from, to []*Quad) *Patch {
+	fromLines := quadLines(from)
+	toLines := quadLines(to)
+
+	patch := &Patch{}
+	for _, line := range sortedKeys(fromLines) {
+		if _, ok := toLines[line]; !ok {
+			patch.Rows = append(patch.Rows, &PatchRow{Operation: PatchDelete, Quad: fromLines[line]})
+		}
+	}
+	for _, line := range sortedKeys(toLines) {
+		if _, ok := fromLines[line]; !ok {
+			patch.Rows = append(patch.Rows, &PatchRow{Operation: PatchAdd, Quad: toLines[line]})
+		}
+	}
+	if len(patch.Rows) > 0 {
+		patch.Rows = append([]*PatchRow{{Operation: PatchBegin}}, patch.Rows...)
+		patch.Rows = append(patch.Rows, &PatchRow{Operation: PatchCommit})
+	}
+	return patch
+}
+
+// quadLines maps the canonical N-Quads line of each quad to the quad
+func quadLines(quads []*Quad) map[string]*Quad {
+	lines := make(map[string]*Quad, len(quads))
+	for _, quad := range quads {
+		if quad.Graph == nil {
+			quad = NewQuad(quad.Subject, quad.Predicate, quad.Object, NewDefaultGraph())
+		}
+		lines[serializeQuadCanonical(quad)] = quad
+	}
+	return lines
+}
+
+// PatchParser parses the RDF Patch text format. Terms are written as in N-Quads; blank nodes
+// may also be written <_:label>, and IRIs as prefixed names using the prefixes of earlier PA
+// rows.
+type PatchParser struct {
+	terms *NQuadsParser // Parses the terms of rows, allowing prefixed names
+}
+
+// NewPatchParser creates a new RDF Patch parser
+func NewPatchParser(input string) *PatchParser {
+	terms := NewNQuadsParser(input)
+	terms.strictMode = false
+	return &PatchParser{terms: terms}
+}
+
+// Parse parses the patch
+func (p *PatchParser) Parse() (*Patch, error) {
+	patch := &Patch{}
+	terms := p.terms
+	for {
+		terms.skipWhitespaceAndComments()
+		if terms.pos >= terms.length {
+			return patch, nil
+		}
+		row, err := p.parseRow()
+		if err != nil {
+			return nil, NewParseError(terms.input, terms.pos, err)
+		}
+		patch.Rows = append(patch.Rows, row)
+	}
+}
+
+// parseRow parses a row, which ends with a '.' that may be left out on rows without a quad
+func (p *PatchParser) parseRow() (*PatchRow, error) {
+	terms := p.terms
+	start := terms.pos
+	for terms.pos < terms.length && terms.input[terms.pos] >= 'A' && terms.input[terms.pos] <= 'Z' {
+		terms.pos++
+	}
+	row := &PatchRow{Operation: PatchOperation(terms.input[start:terms.pos])}
+	if start == terms.pos {
+		return nil, fmt.Errorf("expected a row type")
+	}
+	terms.skipWhitespaceAndComments()
+
+	switch row.Operation {
+	case PatchAdd, PatchDelete:
+		quad, err := terms.parseQuad()
+		if err != nil {
+			return nil, err
+		}
+		row.Quad = NewQuad(patchBlankNode(quad.Subject), quad.Predicate, patchBlankNode(quad.Object),
+			patchBlankNode(quad.Graph))
+		return row, nil
+
+	case PatchHeader:
+		row.Header = p.parseWord()
+		if row.Header == "" {
+			return nil, fmt.Errorf("expected a header name")
+		}
+		terms.skipWhitespaceAndComments()
+		value, err := terms.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		row.Value = patchBlankNode(value)
+
+	case PatchAddPrefix, PatchDeletePrefix:
+		prefix, err := p.parsePrefixName()
+		if err != nil {
+			return nil, err
+		}
+		row.Prefix = prefix
+		if row.Operation == PatchAddPrefix {
+			terms.skipWhitespaceAndComments()
+			iri, err := terms.parseTerm()
+			if err != nil {
+				return nil, err
+			}
+			switch t := iri.(type) {
+			case *NamedNode:
+				row.IRI = t.IRI
+			case *Literal:
+				row.IRI = t.Value
+			default:
+				return nil, fmt.Errorf("expected a namespace IRI")
+			}
+			terms.prefixes[row.Prefix] = row.IRI
+		} else {
+			delete(terms.prefixes, row.Prefix)
+		}
+
+	case PatchBegin, PatchCommit, PatchAbort:
+
+	default:
+		return nil, fmt.Errorf("unknown row type %q", row.Operation)
+	}
+
+	terms.skipWhitespaceAndComments()
+	if terms.pos < terms.length && terms.input[terms.pos] == '.' {
+		terms.pos++
+	}
+	return row, nil
+}
+
+// parsePrefixName parses the prefix of a PA or PD row, written as a string or as "name:"
+func (p *PatchParser) parsePrefixName() (string, error) {
+	terms := p.terms
+	if terms.pos < terms.length && terms.input[terms.pos] == '"' {
+		literal, err := terms.parseLiteral()
+		if err != nil {
+			return "", err
+		}
+		return literal.(*Literal).Value, nil
+	}
+	word := p.parseWord()
+	if !strings.HasSuffix(word, ":") {
+		return "", fmt.Errorf("expected a prefix")
+	}
+	return strings.TrimSuffix(word, ":"), nil
+}
+
+// parseWord parses text up to the next whitespace
+func (p *PatchParser) parseWord() string {
+	terms := p.terms
+	start := terms.pos
+	for terms.pos < terms.length && !strings.ContainsRune(" \t\r\n", rune(terms.input[terms.pos])) {
+		terms.pos++
+	}
+	return terms.input[start:terms.pos]
+}
+
+// patchBlankNode turns a blank node written as an IRI, <_:label>, into a blank node
+func patchBlankNode(term Term) Term {
+	if named, ok := term.(*NamedNode); ok && strings.HasPrefix(named.IRI, "_:") {
+		return NewBlankNode(named.IRI[2:])
+	}
+	return term
+}
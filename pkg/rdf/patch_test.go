@@ -0,0 +1,207 @@
+package rdf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPatchParser_Rows(t *testing.T) {
+	input := `H id <uuid:0123> .
+TX .
+PA "ex" "http://example.org/" .
+PA foaf: <http://xmlns.com/foaf/0.1/> .
+A ex:s foaf:name "Alice" .
+A <_:b1> <http://example.org/p> _:b2 <http://example.org/g> .
+# A comment
+D <http://example.org/s> <http://example.org/p> "old"@en .
+PD "foaf" .
+TC .
+`
+	patch, err := NewPatchParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	operations := []PatchOperation{PatchHeader, PatchBegin, PatchAddPrefix, PatchAddPrefix, PatchAdd, PatchAdd,
+		PatchDelete, PatchDeletePrefix, PatchCommit}
+	if len(patch.Rows) != len(operations) {
+		t.Fatalf("Expected %d rows, got %d", len(operations), len(patch.Rows))
+	}
+	for i, operation := range operations {
+		if patch.Rows[i].Operation != operation {
+			t.Errorf("Row %d: expected %s, got %s", i, operation, patch.Rows[i].Operation)
+		}
+	}
+
+	if patch.Rows[0].Header != "id" || !patch.Rows[0].Value.Equals(NewNamedNode("uuid:0123")) {
+		t.Errorf("Unexpected header row: %+v", patch.Rows[0])
+	}
+	if patch.Rows[3].Prefix != "foaf" || patch.Rows[3].IRI != "http://xmlns.com/foaf/0.1/" {
+		t.Errorf("Unexpected prefix row: %+v", patch.Rows[3])
+	}
+	added := patch.Rows[4].Quad
+	if !added.Subject.Equals(NewNamedNode("http://example.org/s")) ||
+		!added.Predicate.Equals(NewNamedNode("http://xmlns.com/foaf/0.1/name")) {
+		t.Errorf("Expected prefixed names to be expanded, got %s", added)
+	}
+	blank := patch.Rows[5].Quad
+	if !blank.Subject.Equals(NewBlankNode("b1")) || !blank.Object.Equals(NewBlankNode("b2")) ||
+		!blank.Graph.Equals(NewNamedNode("http://example.org/g")) {
+		t.Errorf("Expected blank nodes in a named graph, got %s", blank)
+	}
+	if !patch.Rows[6].Quad.Object.Equals(NewLiteralWithLanguage("old", "en")) {
+		t.Errorf("Unexpected deleted quad: %s", patch.Rows[6].Quad)
+	}
+}
+
+func TestPatchParser_Errors(t *testing.T) {
+	tests := []string{
+		"X <http://example.org/s> <http://example.org/p> <http://example.org/o> .",
+		"A <http://example.org/s> <http://example.org/p> ",
+		"A <http://example.org/s> <http://example.org/p> <http://example.org/o>",
+		"PA ex <http://example.org/> .",
+		"A ex:s <http://example.org/p> <http://example.org/o> .",
+		"A <http://example.org/s> <http://example.org/p> 1e .",
+	}
+	for _, input := range tests {
+		_, err := NewPatchParser(input).Parse()
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Expected a ParseError for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestPatchParser_Numbers(t *testing.T) {
+	tests := []struct {
+		number   string
+		expected *Literal
+	}{
+		{"42", NewLiteralWithDatatype("42", XSDInteger)},
+		{"-7", NewLiteralWithDatatype("-7", XSDInteger)},
+		{"1.5", NewLiteralWithDatatype("1.5", XSDDecimal)},
+		{"+0.25", NewLiteralWithDatatype("+0.25", XSDDecimal)},
+		{"1.5e3", NewLiteralWithDatatype("1.5e3", XSDDouble)},
+		{"1E-2", NewLiteralWithDatatype("1E-2", XSDDouble)},
+		{"1.e5", NewLiteralWithDatatype("1.e5", XSDDouble)},
+	}
+	for _, tt := range tests {
+		// With and without a space before the final '.'
+		for _, input := range []string{
+			"A <http://example.org/s> <http://example.org/p> " + tt.number + " .",
+			"A <http://example.org/s> <http://example.org/p> " + tt.number + ".",
+		} {
+			patch, err := NewPatchParser(input).Parse()
+			if err != nil {
+				t.Errorf("Parse failed for %q: %v", input, err)
+				continue
+			}
+			if len(patch.Rows) != 1 {
+				t.Errorf("Expected 1 row for %q, got %d", input, len(patch.Rows))
+				continue
+			}
+			if object := patch.Rows[0].Quad.Object; !object.Equals(tt.expected) {
+				t.Errorf("Expected %s for %q, got %s", tt.expected, input, object)
+			}
+		}
+	}
+
+	// A number may be followed by a graph
+	patch, err := NewPatchParser("A <http://example.org/s> <http://example.org/p> 1.5 <http://example.org/g> .").Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if quad := patch.Rows[0].Quad; !quad.Object.Equals(NewLiteralWithDatatype("1.5", XSDDecimal)) ||
+		!quad.Graph.Equals(NewNamedNode("http://example.org/g")) {
+		t.Errorf("Unexpected quad: %s", quad)
+	}
+}
+
+func TestPatch_SerializeRoundTrip(t *testing.T) {
+	input := `H id <uuid:0123> .
+TX .
+PA "ex" "http://example.org/" .
+A <http://example.org/s> <http://example.org/p> "line\nbreak" .
+A _:b1 <http://example.org/p> "1"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/g> .
+D <http://example.org/s> <http://example.org/p> "old"@en .
+PD "ex" .
+TC .
+`
+	patch, err := NewPatchParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var builder strings.Builder
+	if err := patch.Serialize(&builder); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if builder.String() != input {
+		t.Errorf("Expected:\n%s\ngot:\n%s", input, builder.String())
+	}
+}
+
+func TestPatch_Changes(t *testing.T) {
+	patch, err := NewPatchParser(`TX .
+A <http://example.org/s> <http://example.org/p> "kept" .
+TC .
+TX .
+A <http://example.org/s> <http://example.org/p> "aborted" .
+TA .
+A <http://example.org/s> <http://example.org/p> "outside" .
+`).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	changes, err := patch.Changes()
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	if len(changes) != 2 || !changes[0].Quad.Object.Equals(NewLiteral("kept")) ||
+		!changes[1].Quad.Object.Equals(NewLiteral("outside")) {
+		t.Errorf("Expected the rows outside the aborted transaction, got %v", changes)
+	}
+
+	for _, input := range []string{"TX .\nTX .\nTC .\nTC .", "TC .", "TA .", "TX .\nA <a:s> <a:p> <a:o> ."} {
+		patch, err := NewPatchParser(input).Parse()
+		if err != nil {
+			t.Fatalf("Parse failed for %q: %v", input, err)
+		}
+		if _, err := patch.Changes(); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
+
+func TestDiffQuads(t *testing.T) {
+	from, err := NewNQuadsParser(`<http://example.org/s> <http://example.org/p> "same" .
+<http://example.org/s> <http://example.org/p> "removed" .
+_:b <http://example.org/p> "removed" <http://example.org/g> .
+`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := NewNQuadsParser(`<http://example.org/s> <http://example.org/p> "added" <http://example.org/g> .
+<http://example.org/s> <http://example.org/p> "same" .
+`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var builder strings.Builder
+	if err := DiffQuads(from, to).Serialize(&builder); err != nil {
+		t.Fatal(err)
+	}
+	expected := `TX .
+D <http://example.org/s> <http://example.org/p> "removed" .
+D _:b <http://example.org/p> "removed" <http://example.org/g> .
+A <http://example.org/s> <http://example.org/p> "added" <http://example.org/g> .
+TC .
+`
+	if builder.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, builder.String())
+	}
+
+	if patch := DiffQuads(from, from); len(patch.Rows) != 0 {
+		t.Errorf("Expected an empty patch for equal sets, got %d rows", len(patch.Rows))
+	}
+}
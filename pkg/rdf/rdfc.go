@@ -0,0 +1,453 @@
+package rdf
+
+import (
+	"crypto"
+	_ "crypto/sha256" // Registers SHA-256, the default hash algorithm
+	_ "crypto/sha512" // Registers SHA-384
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrCanonicalizationLimit is returned when canonicalizing a dataset takes more N-degree
+// hashing than allowed, as it does for datasets crafted to make canonicalization take
+// exponential time
+var ErrCanonicalizationLimit = errors.New("canonicalization exceeded the maximum number of deep iterations")
+
+// Canonicalizer implements the W3C RDF Dataset Canonicalization algorithm (RDFC-1.0), which
+// labels the blank nodes of a dataset so that isomorphic datasets serialize to the same
+// canonical N-Quads document
+type Canonicalizer struct {
+	hash              crypto.Hash
+	maxDeepIterations int
+}
+
+// CanonicalDataset is the result of canonicalizing a dataset
+type CanonicalDataset struct {
+	Quads       []*Quad           // Relabelled quads without duplicates, in canonical N-Quads order
+	Identifiers map[string]string // Canonical label of each input blank node label
+}
+
+// NewCanonicalizer creates a canonicalizer hashing with SHA-256
+func NewCanonicalizer() *Canonicalizer {
+	return &Canonicalizer{hash: crypto.SHA256}
+}
+
+// SetHashAlgorithm sets the hash algorithm used for blank node labelling, SHA-256 (the default)
+// or another available algorithm such as SHA-384
+func (c *Canonicalizer) SetHashAlgorithm(hash crypto.Hash) {
+	c.hash = hash
+}
+
+// SetMaxDeepIterations sets how many times N-degree hashing may be run for a blank node
+// before canonicalization fails with ErrCanonicalizationLimit; 0 (the default) allows the cube
+// of the number of blank nodes that need it, and a negative value removes the limit
+func (c *Canonicalizer) SetMaxDeepIterations(iterations int) {
+	c.maxDeepIterations = iterations
+}
+
+// NQuads returns the canonical N-Quads serialization of the dataset
+func (d *CanonicalDataset) NQuads() string {
+	return SerializeQuadsCanonical(d.Quads)
+}
+
+// CanonicalizeQuads canonicalizes a dataset with RDFC-1.0 and SHA-256, returning its quads
+// with canonical blank node labels in canonical N-Quads order
+func CanonicalizeQuads(quads []*Quad) ([]*Quad, error) {
+	dataset, err := NewCanonicalizer().Canonicalize(quads)
+	if err != nil {
+		return nil, err
+	}
+	return dataset.Quads, nil
+}
+
+// CanonicalHash returns the hex SHA-256 hash of the canonical N-Quads form of a dataset, which
+// is the same for any two isomorphic datasets
+func CanonicalHash(quads []*Quad) (string, error) {
+	return NewCanonicalizer().Hash(quads)
+}
+
+// Hash returns the hex hash of the canonical N-Quads form of a dataset, using the
+// canonicalizer's hash algorithm
+func (c *Canonicalizer) Hash(quads []*Quad) (string, error) {
+	dataset, err := c.Canonicalize(quads)
+	if err != nil {
+		return "", err
+	}
+	return c.hashString(dataset.NQuads()), nil
+}
+
+// Canonicalize labels the blank nodes of a dataset with RDFC-1.0
+func (c *Canonicalizer) Canonicalize(quads []*Quad) (*CanonicalDataset, error) {
+	if !c.hash.Available() {
+		return nil, fmt.Errorf("hash algorithm %v is not available", c.hash)
+	}
+
+	state := &canonicalizationState{
+		canonicalizer:     c,
+		blankNodeQuads:    make(map[string][]*Quad),
+		firstDegreeHashes: make(map[string]string),
+		deepIterations:    make(map[string]int),
+		canonicalIssuer:   newIdentifierIssuer("c14n"),
+	}
+
+	// Map each blank node to the quads it appears in, ignoring duplicate quads
+	seen := make(map[string]bool, len(quads))
+	for _, quad := range quads {
+		line := serializeQuadCanonical(quad)
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		for _, id := range quadBlankNodes(quad) {
+			state.blankNodeQuads[id] = append(state.blankNodeQuads[id], quad)
+		}
+	}
+
+	// Group blank nodes by their first degree hash
+	hashToBlankNodes := make(map[string][]string)
+	for _, id := range sortedKeys(state.blankNodeQuads) {
+		hash := state.hashFirstDegreeQuads(id)
+		hashToBlankNodes[hash] = append(hashToBlankNodes[hash], id)
+	}
+
+	// Blank nodes with a unique first degree hash are labelled in hash order
+	hashes := sortedKeys(hashToBlankNodes)
+	for _, hash := range hashes {
+		if ids := hashToBlankNodes[hash]; len(ids) == 1 {
+			state.canonicalIssuer.issue(ids[0])
+			delete(hashToBlankNodes, hash)
+		}
+	}
+
+	state.maxDeepIterations = c.maxDeepIterations
+	if state.maxDeepIterations == 0 {
+		shared := 0
+		for _, ids := range hashToBlankNodes {
+			shared += len(ids)
+		}
+		state.maxDeepIterations = shared * shared * shared
+	}
+
+	// The rest are told apart by the hashes of the paths to their neighbours
+	for _, hash := range hashes {
+		ids, shared := hashToBlankNodes[hash]
+		if !shared {
+			continue
+		}
+		var results []nDegreeResult
+		for _, id := range ids {
+			if state.canonicalIssuer.has(id) {
+				continue
+			}
+			issuer := newIdentifierIssuer("b")
+			issuer.issue(id)
+			result, err := state.hashNDegreeQuads(id, issuer)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+		sort.SliceStable(results, func(i, j int) bool { return results[i].hash < results[j].hash })
+		for _, result := range results {
+			for _, id := range result.issuer.order {
+				state.canonicalIssuer.issue(id)
+			}
+		}
+	}
+
+	// Relabel the quads and sort them by their canonical N-Quads lines
+	lines := make(map[string]*Quad, len(quads))
+	for _, quad := range quads {
+		relabelled := relabelQuad(quad, func(id string) string { return state.canonicalIssuer.issued[id] })
+		lines[serializeQuadCanonical(relabelled)] = relabelled
+	}
+	dataset := &CanonicalDataset{
+		Quads:       make([]*Quad, 0, len(lines)),
+		Identifiers: state.canonicalIssuer.issued,
+	}
+	for _, line := range sortedKeys(lines) {
+		dataset.Quads = append(dataset.Quads, lines[line])
+	}
+	return dataset, nil
+}
+
+// canonicalizationState holds what is known about the blank nodes of a dataset while it is
+// being canonicalized
+type canonicalizationState struct {
+	canonicalizer     *Canonicalizer
+	blankNodeQuads    map[string][]*Quad // Quads each blank node appears in
+	firstDegreeHashes map[string]string  // First degree hash of each blank node, once computed
+	deepIterations    map[string]int     // Times N-degree hashing was run for each blank node
+	maxDeepIterations int                // Negative for no limit
+	canonicalIssuer   *identifierIssuer
+}
+
+// nDegreeResult is the hash of a blank node's paths and the temporary labels issued while
+// finding them
+type nDegreeResult struct {
+	hash   string
+	issuer *identifierIssuer
+}
+
+// hashFirstDegreeQuads hashes the quads a blank node appears in, with the node itself labelled
+// "a" and every other blank node "z"
+func (s *canonicalizationState) hashFirstDegreeQuads(id string) string {
+	if hash, ok := s.firstDegreeHashes[id]; ok {
+		return hash
+	}
+	label := func(other string) string {
+		if other == id {
+			return "a"
+		}
+		return "z"
+	}
+	quads := s.blankNodeQuads[id]
+	lines := make([]string, len(quads))
+	for i, quad := range quads {
+		lines[i] = serializeQuadCanonical(relabelQuad(quad, label))
+	}
+	sort.Strings(lines)
+	hash := s.canonicalizer.hashString(strings.Join(lines, ""))
+	s.firstDegreeHashes[id] = hash
+	return hash
+}
+
+// hashRelatedBlankNode hashes a blank node found in a quad of another, by its position in the
+// quad, the quad's predicate and its label or first degree hash
+func (s *canonicalizationState) hashRelatedBlankNode(related string, quad *Quad, issuer *identifierIssuer, position string) string {
+	var identifier string
+	if label, ok := s.canonicalIssuer.issued[related]; ok {
+		identifier = "_:" + label
+	} else if label, ok := issuer.issued[related]; ok {
+		identifier = "_:" + label
+	} else {
+		identifier = s.hashFirstDegreeQuads(related)
+	}
+	input := position
+	if position != "g" {
+		input += serializeTermCanonical(quad.Predicate)
+	}
+	return s.canonicalizer.hashString(input + identifier)
+}
+
+// hashNDegreeQuads hashes a blank node by the shortest path, in code point order, through the
+// blank nodes related to it, trying every order of the related nodes that share a hash
+func (s *canonicalizationState) hashNDegreeQuads(id string, issuer *identifierIssuer) (nDegreeResult, error) {
+	if s.maxDeepIterations >= 0 && s.deepIterations[id] > s.maxDeepIterations {
+		return nDegreeResult{}, fmt.Errorf("%w (%d)", ErrCanonicalizationLimit, s.maxDeepIterations)
+	}
+	s.deepIterations[id]++
+
+	// Group the related blank nodes by their hash
+	hashToRelated := make(map[string][]string)
+	for _, quad := range s.blankNodeQuads[id] {
+		for _, component := range []struct {
+			term     Term
+			position string
+		}{{quad.Subject, "s"}, {quad.Object, "o"}, {quad.Graph, "g"}} {
+			for _, related := range termBlankNodes(component.term, nil) {
+				if related == id {
+					continue
+				}
+				hash := s.hashRelatedBlankNode(related, quad, issuer, component.position)
+				hashToRelated[hash] = append(hashToRelated[hash], related)
+			}
+		}
+	}
+
+	hasher := s.canonicalizer.hash.New()
+	for _, relatedHash := range sortedKeys(hashToRelated) {
+		hasher.Write([]byte(relatedHash))
+
+		chosenPath := ""
+		var chosenIssuer *identifierIssuer
+		related := hashToRelated[relatedHash]
+		permutation := make([]int, len(related))
+		for i := range permutation {
+			permutation[i] = i
+		}
+		for more := true; more; more = nextPermutation(permutation) {
+			issuerCopy := issuer.clone()
+			path := ""
+			var recursionList []string
+			longer := func() bool {
+				return chosenIssuer != nil && len(path) >= len(chosenPath) && path > chosenPath
+			}
+
+			skip := false
+			for _, i := range permutation {
+				if label, ok := s.canonicalIssuer.issued[related[i]]; ok {
+					path += "_:" + label
+				} else {
+					if !issuerCopy.has(related[i]) {
+						recursionList = append(recursionList, related[i])
+					}
+					path += "_:" + issuerCopy.issue(related[i])
+				}
+				if longer() {
+					skip = true
+					break
+				}
+			}
+			if skip {
+				continue
+			}
+
+			for _, next := range recursionList {
+				result, err := s.hashNDegreeQuads(next, issuerCopy)
+				if err != nil {
+					return nDegreeResult{}, err
+				}
+				path += "_:" + issuerCopy.issue(next) + "<" + result.hash + ">"
+				issuerCopy = result.issuer
+				if longer() {
+					skip = true
+					break
+				}
+			}
+			if skip {
+				continue
+			}
+
+			if chosenIssuer == nil || path < chosenPath {
+				chosenPath = path
+				chosenIssuer = issuerCopy
+			}
+		}
+
+		hasher.Write([]byte(chosenPath))
+		issuer = chosenIssuer
+	}
+
+	return nDegreeResult{hash: hex.EncodeToString(hasher.Sum(nil)), issuer: issuer}, nil
+}
+
+// hashString returns the hex hash of a string
+func (c *Canonicalizer) hashString(input string) string {
+	hasher := c.hash.New()
+	hasher.Write([]byte(input))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// identifierIssuer issues blank node labels made of a prefix and a counter, remembering the
+// order they were issued in
+type identifierIssuer struct {
+	prefix string
+	issued map[string]string
+	order  []string
+}
+
+func newIdentifierIssuer(prefix string) *identifierIssuer {
+	return &identifierIssuer{prefix: prefix, issued: make(map[string]string)}
+}
+
+// issue returns the label issued for an identifier, issuing a new one the first time
+func (i *identifierIssuer) issue(id string) string {
+	if label, ok := i.issued[id]; ok {
+		return label
+	}
+	label := fmt.Sprintf("%s%d", i.prefix, len(i.order))
+	i.issued[id] = label
+	i.order = append(i.order, id)
+	return label
+}
+
+func (i *identifierIssuer) has(id string) bool {
+	_, ok := i.issued[id]
+	return ok
+}
+
+func (i *identifierIssuer) clone() *identifierIssuer {
+	issued := make(map[string]string, len(i.issued))
+	for id, label := range i.issued {
+		issued[id] = label
+	}
+	return &identifierIssuer{prefix: i.prefix, issued: issued, order: append([]string(nil), i.order...)}
+}
+
+// nextPermutation rearranges indices into the next permutation in lexicographic order,
+// returning false once they are back in ascending order
+func nextPermutation(indices []int) bool {
+	i := len(indices) - 2
+	for i >= 0 && indices[i] >= indices[i+1] {
+		i--
+	}
+	if i < 0 {
+		return false
+	}
+	j := len(indices) - 1
+	for indices[j] <= indices[i] {
+		j--
+	}
+	indices[i], indices[j] = indices[j], indices[i]
+	for k, l := i+1, len(indices)-1; k < l; k, l = k+1, l-1 {
+		indices[k], indices[l] = indices[l], indices[k]
+	}
+	return true
+}
+
+// quadBlankNodes returns the labels of the blank nodes in a quad, including those inside triple
+// terms, without duplicates
+func quadBlankNodes(quad *Quad) []string {
+	var ids []string
+	for _, term := range []Term{quad.Subject, quad.Object, quad.Graph} {
+		for _, id := range termBlankNodes(term, nil) {
+			duplicate := false
+			for _, seen := range ids {
+				duplicate = duplicate || seen == id
+			}
+			if !duplicate {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// termBlankNodes appends the labels of the blank nodes in a term, including those inside
+// triple terms, to ids
+func termBlankNodes(term Term, ids []string) []string {
+	switch t := term.(type) {
+	case *BlankNode:
+		ids = append(ids, t.ID)
+	case *TripleTerm:
+		ids = termBlankNodes(t.Subject, ids)
+		ids = termBlankNodes(t.Object, ids)
+	}
+	return ids
+}
+
+// relabelQuad returns a copy of a quad with its blank nodes relabelled
+func relabelQuad(quad *Quad, label func(id string) string) *Quad {
+	return NewQuad(relabelTerm(quad.Subject, label), quad.Predicate, relabelTerm(quad.Object, label),
+		relabelTerm(quad.Graph, label))
+}
+
+// relabelTerm returns a term with its blank nodes relabelled, including those inside triple
+// terms
+func relabelTerm(term Term, label func(id string) string) Term {
+	switch t := term.(type) {
+	case *BlankNode:
+		return NewBlankNode(label(t.ID))
+	case *TripleTerm:
+		return &TripleTerm{
+			Subject:   relabelTerm(t.Subject, label),
+			Predicate: t.Predicate,
+			Object:    relabelTerm(t.Object, label),
+		}
+	}
+	return term
+}
+
+// sortedKeys returns the keys of a map in code point order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
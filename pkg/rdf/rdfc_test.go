@@ -0,0 +1,134 @@
+package rdf
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func canonicalizeNQuads(t *testing.T, canonicalizer *Canonicalizer, input string) *CanonicalDataset {
+	t.Helper()
+	quads, err := NewNQuadsParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	dataset, err := canonicalizer.Canonicalize(quads)
+	if err != nil {
+		t.Fatalf("Canonicalize failed: %v", err)
+	}
+	return dataset
+}
+
+func TestCanonicalize_UniqueHashes(t *testing.T) {
+	// Example from the RDFC-1.0 specification, labelled by first degree hashes alone
+	dataset := canonicalizeNQuads(t, NewCanonicalizer(), `<http://example.com/#p> <http://example.com/#q> _:e0 .
+<http://example.com/#p> <http://example.com/#r> _:e1 .
+_:e0 <http://example.com/#s> <http://example.com/#u> .
+_:e1 <http://example.com/#t> <http://example.com/#u> .
+`)
+	expected := `<http://example.com/#p> <http://example.com/#q> _:c14n0 .
+<http://example.com/#p> <http://example.com/#r> _:c14n1 .
+_:c14n0 <http://example.com/#s> <http://example.com/#u> .
+_:c14n1 <http://example.com/#t> <http://example.com/#u> .
+`
+	if dataset.NQuads() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, dataset.NQuads())
+	}
+}
+
+func TestCanonicalize_SharedHashes(t *testing.T) {
+	// Example from the RDFC-1.0 specification, which needs N-degree hashing
+	dataset := canonicalizeNQuads(t, NewCanonicalizer(), `<http://example.com/#p> <http://example.com/#q> _:e0 .
+<http://example.com/#p> <http://example.com/#q> _:e1 .
+_:e0 <http://example.com/#p> _:e2 .
+_:e1 <http://example.com/#p> _:e3 .
+_:e2 <http://example.com/#r> _:e3 .
+`)
+	expected := `<http://example.com/#p> <http://example.com/#q> _:c14n2 .
+<http://example.com/#p> <http://example.com/#q> _:c14n3 .
+_:c14n0 <http://example.com/#r> _:c14n1 .
+_:c14n2 <http://example.com/#p> _:c14n1 .
+_:c14n3 <http://example.com/#p> _:c14n0 .
+`
+	if dataset.NQuads() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, dataset.NQuads())
+	}
+	identifiers := map[string]string{"e0": "c14n3", "e1": "c14n2", "e2": "c14n0", "e3": "c14n1"}
+	for id, label := range identifiers {
+		if dataset.Identifiers[id] != label {
+			t.Errorf("Expected %s to be labelled %s, got %v", id, label, dataset.Identifiers)
+		}
+	}
+}
+
+func TestCanonicalHash_Isomorphic(t *testing.T) {
+	// The same dataset with other labels, quad order, a duplicate and a named graph
+	first, err := NewNQuadsParser(`_:a <http://example.org/p> _:b <http://example.org/g> .
+_:b <http://example.org/p> _:c <http://example.org/g> .
+_:c <http://example.org/p> _:a <http://example.org/g> .
+_:a <http://example.org/name> "x" _:g .
+<http://example.org/s> <http://example.org/p> <<( _:b <http://example.org/p> "y" )>> .
+`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewNQuadsParser(`<http://example.org/s> <http://example.org/p> <<( _:n2 <http://example.org/p> "y" )>> .
+_:n3 <http://example.org/p> _:n1 <http://example.org/g> .
+_:n1 <http://example.org/name> "x" _:graph .
+_:n2 <http://example.org/p> _:n3 <http://example.org/g> .
+_:n1 <http://example.org/p> _:n2 <http://example.org/g> .
+_:n1 <http://example.org/p> _:n2 <http://example.org/g> .
+`).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstHash, err := CanonicalHash(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondHash, err := CanonicalHash(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstHash != secondHash || len(firstHash) != 64 {
+		t.Errorf("Expected equal SHA-256 hashes, got %s and %s", firstHash, secondHash)
+	}
+	canonical, err := CanonicalizeQuads(second)
+	if err != nil || len(canonical) != 5 {
+		t.Errorf("Expected 5 canonical quads, got %d (%v)", len(canonical), err)
+	}
+
+	// A changed literal changes the hash
+	first[3].Object = NewLiteral("z")
+	if changed, _ := CanonicalHash(first); changed == firstHash {
+		t.Error("Expected a different hash for a different dataset")
+	}
+
+	canonicalizer := NewCanonicalizer()
+	canonicalizer.SetHashAlgorithm(crypto.SHA384)
+	if hash, err := canonicalizer.Hash(second); err != nil || len(hash) != 96 {
+		t.Errorf("Expected a SHA-384 hash, got %q (%v)", hash, err)
+	}
+}
+
+func TestCanonicalize_DeepIterationLimit(t *testing.T) {
+	// A clique of indistinguishable blank nodes takes exponential N-degree hashing
+	var builder strings.Builder
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			if i != j {
+				fmt.Fprintf(&builder, "_:e%d <http://example.org/p> _:e%d .\n", i, j)
+			}
+		}
+	}
+	quads, err := NewNQuadsParser(builder.String()).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCanonicalizer().Canonicalize(quads); !errors.Is(err, ErrCanonicalizationLimit) {
+		t.Errorf("Expected ErrCanonicalizationLimit, got %v", err)
+	}
+}
@@ -393,8 +393,53 @@ func validateAttributes(elem xml.StartElement) error {
 
 // Parse parses RDF/XML and returns quads (all in default graph)
 func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
+	var quads []*Quad
+	err := p.ParseStream(reader, func(quad *Quad) error {
+		quads = append(quads, quad)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quads, nil
+}
+
+// ParseStream parses RDF/XML from a reader and calls the handler with the quads read so far
+// each time an element ends, so only the quads of the element being read are held in memory
+func (p *RDFXMLParser) ParseStream(reader io.Reader, handler QuadHandler) error {
 	decoder := xml.NewDecoder(reader)
+	var handlerErr error
+	err := p.parseElements(decoder, func(quad *Quad) error {
+		handlerErr = handler(quad)
+		return handlerErr
+	})
+	if err == nil || handlerErr != nil {
+		return err
+	}
+
+	// The decoder is just past the token the error was found in
+	line, column := decoder.InputPos()
+	return &ParseError{
+		Line:     line,
+		Column:   column,
+		Offset:   int(decoder.InputOffset()),
+		Expected: expectedIn(err.Error()),
+		Err:      err,
+	}
+}
+
+// parseElements reads the elements of an RDF/XML document from a decoder
+func (p *RDFXMLParser) parseElements(decoder *xml.Decoder, handler QuadHandler) error {
 	var quads []*Quad
+	flush := func() error {
+		for _, quad := range quads {
+			if err := handler(quad); err != nil {
+				return err
+			}
+		}
+		quads = quads[:0]
+		return nil
+	}
 	var currentSubject Term
 	var blankNodeCounter int
 	var liCounter int    // Counter for rdf:li elements within current container
@@ -417,7 +462,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("XML parse error: %w", err)
+			return fmt.Errorf("XML parse error: %w", err)
 		}
 
 		switch elem := token.(type) {
@@ -486,10 +531,10 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 				}
 				// Nested rdf:RDF - this is forbidden, validate it to get the error
 				if err := validateNodeElement(elem); err != nil {
-					return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+					return fmt.Errorf("invalid RDF/XML: %w", err)
 				}
 				// Validation passed but nested rdf:RDF should always fail - this shouldn't happen
-				return nil, fmt.Errorf("invalid RDF/XML: nested rdf:RDF is forbidden")
+				return fmt.Errorf("invalid RDF/XML: nested rdf:RDF is forbidden")
 			}
 
 			// Check if this is an RDF container (rdf:Bag, rdf:Seq, rdf:Alt)
@@ -499,7 +544,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 			if isContainer(elem) && resourceAttr == "" && currentSubject == nil {
 				// Validate attributes
 				if err := validateAttributes(elem); err != nil {
-					return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+					return fmt.Errorf("invalid RDF/XML: %w", err)
 				}
 
 				// Check for rdf:about, rdf:ID, or rdf:nodeID, otherwise create blank node
@@ -513,13 +558,13 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 				} else if idAttr != "" {
 					resolvedID, err := p.resolveID(idAttr)
 					if err != nil {
-						return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+						return fmt.Errorf("invalid RDF/XML: %w", err)
 					}
 					containerNode = NewNamedNode(resolvedID)
 				} else if nodeIDAttr != "" {
 					node, err := p.getOrCreateNodeID(nodeIDAttr, &blankNodeCounter)
 					if err != nil {
-						return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+						return fmt.Errorf("invalid RDF/XML: %w", err)
 					}
 					containerNode = node
 				} else {
@@ -564,7 +609,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 				liCounter = 0 // Reset counter for this container
 				containerQuads, err := p.parseContainer(decoder, containerNode, &liCounter, &blankNodeCounter)
 				if err != nil {
-					return nil, err
+					return err
 				}
 				quads = append(quads, containerQuads...)
 				continue
@@ -574,7 +619,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 			if elem.Name.Local == "Description" && elem.Name.Space == rdfNS && currentSubject == nil {
 				// Validate attributes
 				if err := validateAttributes(elem); err != nil {
-					return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+					return fmt.Errorf("invalid RDF/XML: %w", err)
 				}
 
 				// Get rdf:about, rdf:ID, rdf:nodeID, or create blank node
@@ -584,7 +629,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 
 				// Validate that we don't have conflicting subject specifiers
 				if idAttr != "" && nodeIDAttr != "" {
-					return nil, fmt.Errorf("invalid RDF/XML: element cannot have both rdf:ID and rdf:nodeID")
+					return fmt.Errorf("invalid RDF/XML: element cannot have both rdf:ID and rdf:nodeID")
 				}
 
 				// Check if rdf:about attribute exists (even if empty)
@@ -605,13 +650,13 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 				} else if idAttr != "" {
 					resolvedID, err := p.resolveID(idAttr)
 					if err != nil {
-						return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+						return fmt.Errorf("invalid RDF/XML: %w", err)
 					}
 					currentSubject = NewNamedNode(resolvedID)
 				} else if nodeIDAttr != "" {
 					node, err := p.getOrCreateNodeID(nodeIDAttr, &blankNodeCounter)
 					if err != nil {
-						return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+						return fmt.Errorf("invalid RDF/XML: %w", err)
 					}
 					currentSubject = node
 				} else {
@@ -708,12 +753,12 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 			if hasAboutAttr || currentSubject == nil {
 				// Validate this is not a forbidden node element
 				if err := validateNodeElement(elem); err != nil {
-					return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+					return fmt.Errorf("invalid RDF/XML: %w", err)
 				}
 
 				// Validate attributes
 				if err := validateAttributes(elem); err != nil {
-					return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+					return fmt.Errorf("invalid RDF/XML: %w", err)
 				}
 
 				// This is a typed node (implicit rdf:type)
@@ -728,13 +773,13 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 				} else if idAttr != "" {
 					resolvedID, err := p.resolveID(idAttr)
 					if err != nil {
-						return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+						return fmt.Errorf("invalid RDF/XML: %w", err)
 					}
 					subject = NewNamedNode(resolvedID)
 				} else if nodeIDAttr != "" {
 					node, err := p.getOrCreateNodeID(nodeIDAttr, &blankNodeCounter)
 					if err != nil {
-						return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+						return fmt.Errorf("invalid RDF/XML: %w", err)
 					}
 					subject = node
 				} else {
@@ -751,7 +796,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 				liCounter = 0 // Reset counter
 				typedNodeQuads, err := p.parseTypedNode(decoder, subject, &liCounter, &blankNodeCounter)
 				if err != nil {
-					return nil, err
+					return err
 				}
 				quads = append(quads, typedNodeQuads...)
 				continue
@@ -761,12 +806,12 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 			if currentSubject != nil {
 				// Validate property element
 				if err := validatePropertyElement(elem); err != nil {
-					return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+					return fmt.Errorf("invalid RDF/XML: %w", err)
 				}
 
 				// Validate attributes (for illegal combinations)
 				if err := validateAttributes(elem); err != nil {
-					return nil, err
+					return err
 				}
 
 				// Handle rdf:li specially - it auto-numbers to rdf:_N
@@ -791,7 +836,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 							for depth > 0 {
 								token, err := decoder.Token()
 								if err != nil {
-									return nil, err
+									return err
 								}
 								switch token.(type) {
 								case xml.StartElement:
@@ -807,7 +852,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					// Use parsePropertyContent to handle parseType
 					object, nestedQuads, err := p.parsePropertyContent(decoder, elem, &blankNodeCounter)
 					if err != nil {
-						return nil, err
+						return err
 					}
 
 					quad := NewQuad(currentSubject, NewNamedNode(predicate), object, NewDefaultGraph())
@@ -818,7 +863,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					if idAttr := getAttr(elem.Attr, rdfNS, "ID"); idAttr != "" {
 						statementID, err := p.resolveID(idAttr)
 						if err != nil {
-							return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+							return fmt.Errorf("invalid RDF/XML: %w", err)
 						}
 						reificationQuads := generateReificationQuads(statementID, currentSubject, NewNamedNode(predicate), object)
 						quads = append(quads, reificationQuads...)
@@ -835,7 +880,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					if annotationNodeIDAttr := getAttr(elem.Attr, rdfNS, "annotationNodeID"); annotationNodeIDAttr != "" {
 						reifier, err := p.getOrCreateNodeID(annotationNodeIDAttr, &blankNodeCounter)
 						if err != nil {
-							return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+							return fmt.Errorf("invalid RDF/XML: %w", err)
 						}
 						annotationQuad := generateAnnotationQuad(reifier, currentSubject, NewNamedNode(predicate), object)
 						quads = append(quads, annotationQuad)
@@ -846,7 +891,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 
 				// Validate that rdf:resource and rdf:nodeID aren't both present
 				if getAttr(elem.Attr, rdfNS, "resource") != "" && getAttr(elem.Attr, rdfNS, "nodeID") != "" {
-					return nil, fmt.Errorf("invalid RDF/XML: element cannot have both rdf:resource and rdf:nodeID")
+					return fmt.Errorf("invalid RDF/XML: element cannot have both rdf:resource and rdf:nodeID")
 				}
 
 				// Check for rdf:resource attribute (second priority)
@@ -912,7 +957,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					if idAttr := getAttr(elem.Attr, rdfNS, "ID"); idAttr != "" {
 						statementID, err := p.resolveID(idAttr)
 						if err != nil {
-							return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+							return fmt.Errorf("invalid RDF/XML: %w", err)
 						}
 						reificationQuads := generateReificationQuads(statementID, currentSubject, NewNamedNode(predicate), object)
 						quads = append(quads, reificationQuads...)
@@ -929,7 +974,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					if annotationNodeIDAttr := getAttr(elem.Attr, rdfNS, "annotationNodeID"); annotationNodeIDAttr != "" {
 						reifier, err := p.getOrCreateNodeID(annotationNodeIDAttr, &blankNodeCounter)
 						if err != nil {
-							return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+							return fmt.Errorf("invalid RDF/XML: %w", err)
 						}
 						annotationQuad := generateAnnotationQuad(reifier, currentSubject, NewNamedNode(predicate), object)
 						quads = append(quads, annotationQuad)
@@ -939,7 +984,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					for {
 						token, err := decoder.Token()
 						if err != nil {
-							return nil, fmt.Errorf("error reading property content: %w", err)
+							return fmt.Errorf("error reading property content: %w", err)
 						}
 						if _, ok := token.(xml.EndElement); ok {
 							break
@@ -954,7 +999,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 				if nodeIDAttr != "" {
 					object, err := p.getOrCreateNodeID(nodeIDAttr, &blankNodeCounter)
 					if err != nil {
-						return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+						return fmt.Errorf("invalid RDF/XML: %w", err)
 					}
 
 					// Create main triple
@@ -965,7 +1010,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					if idAttr := getAttr(elem.Attr, rdfNS, "ID"); idAttr != "" {
 						statementID, err := p.resolveID(idAttr)
 						if err != nil {
-							return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+							return fmt.Errorf("invalid RDF/XML: %w", err)
 						}
 						reificationQuads := generateReificationQuads(statementID, currentSubject, NewNamedNode(predicate), object)
 						quads = append(quads, reificationQuads...)
@@ -982,7 +1027,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					if annotationNodeIDAttr := getAttr(elem.Attr, rdfNS, "annotationNodeID"); annotationNodeIDAttr != "" {
 						reifier, err := p.getOrCreateNodeID(annotationNodeIDAttr, &blankNodeCounter)
 						if err != nil {
-							return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+							return fmt.Errorf("invalid RDF/XML: %w", err)
 						}
 						annotationQuad := generateAnnotationQuad(reifier, currentSubject, NewNamedNode(predicate), object)
 						quads = append(quads, annotationQuad)
@@ -992,7 +1037,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					for {
 						token, err := decoder.Token()
 						if err != nil {
-							return nil, fmt.Errorf("error reading property content: %w", err)
+							return fmt.Errorf("error reading property content: %w", err)
 						}
 						if _, ok := token.(xml.EndElement); ok {
 							break
@@ -1038,7 +1083,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					if idAttr := getAttr(elem.Attr, rdfNS, "ID"); idAttr != "" {
 						statementID, err := p.resolveID(idAttr)
 						if err != nil {
-							return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+							return fmt.Errorf("invalid RDF/XML: %w", err)
 						}
 						reificationQuads := generateReificationQuads(statementID, currentSubject, NewNamedNode(predicate), blankNode)
 						quads = append(quads, reificationQuads...)
@@ -1055,7 +1100,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					if annotationNodeIDAttr := getAttr(elem.Attr, rdfNS, "annotationNodeID"); annotationNodeIDAttr != "" {
 						reifier, err := p.getOrCreateNodeID(annotationNodeIDAttr, &blankNodeCounter)
 						if err != nil {
-							return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+							return fmt.Errorf("invalid RDF/XML: %w", err)
 						}
 						annotationQuad := generateAnnotationQuad(reifier, currentSubject, NewNamedNode(predicate), blankNode)
 						quads = append(quads, annotationQuad)
@@ -1090,7 +1135,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 					for {
 						token, err := decoder.Token()
 						if err != nil {
-							return nil, fmt.Errorf("error reading property content: %w", err)
+							return fmt.Errorf("error reading property content: %w", err)
 						}
 						if _, ok := token.(xml.EndElement); ok {
 							break
@@ -1132,7 +1177,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 				for {
 					token, err := decoder.Token()
 					if err != nil {
-						return nil, fmt.Errorf("error reading property content: %w", err)
+						return fmt.Errorf("error reading property content: %w", err)
 					}
 
 					switch t := token.(type) {
@@ -1173,7 +1218,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 						if propertyIDAttr != "" {
 							statementID, err := p.resolveID(propertyIDAttr)
 							if err != nil {
-								return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+								return fmt.Errorf("invalid RDF/XML: %w", err)
 							}
 							reificationQuads := generateReificationQuads(statementID, currentSubject, NewNamedNode(predicate), object)
 							quads = append(quads, reificationQuads...)
@@ -1190,7 +1235,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 						if annotationNodeIDAttr != "" {
 							reifier, err := p.getOrCreateNodeID(annotationNodeIDAttr, &blankNodeCounter)
 							if err != nil {
-								return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+								return fmt.Errorf("invalid RDF/XML: %w", err)
 							}
 							annotationQuad := generateAnnotationQuad(reifier, currentSubject, NewNamedNode(predicate), object)
 							quads = append(quads, annotationQuad)
@@ -1211,13 +1256,13 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 							} else if idAttr != "" {
 								resolvedID, err := p.resolveID(idAttr)
 								if err != nil {
-									return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+									return fmt.Errorf("invalid RDF/XML: %w", err)
 								}
 								object = NewNamedNode(resolvedID)
 							} else if nodeIDAttr != "" {
 								node, err := p.getOrCreateNodeID(nodeIDAttr, &blankNodeCounter)
 								if err != nil {
-									return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+									return fmt.Errorf("invalid RDF/XML: %w", err)
 								}
 								object = node
 							} else {
@@ -1232,7 +1277,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 							// Parse nested Description
 							nestedQuads, err := p.parseNestedDescription(decoder, object, &blankNodeCounter)
 							if err != nil {
-								return nil, err
+								return err
 							}
 							quads = append(quads, nestedQuads...)
 
@@ -1240,7 +1285,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 							if propertyIDAttr != "" {
 								statementID, err := p.resolveID(propertyIDAttr)
 								if err != nil {
-									return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+									return fmt.Errorf("invalid RDF/XML: %w", err)
 								}
 								reificationQuads := generateReificationQuads(statementID, currentSubject, NewNamedNode(predicate), object)
 								quads = append(quads, reificationQuads...)
@@ -1257,7 +1302,7 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 							if annotationNodeIDAttr != "" {
 								reifier, err := p.getOrCreateNodeID(annotationNodeIDAttr, &blankNodeCounter)
 								if err != nil {
-									return nil, fmt.Errorf("invalid RDF/XML: %w", err)
+									return fmt.Errorf("invalid RDF/XML: %w", err)
 								}
 								annotationQuad := generateAnnotationQuad(reifier, currentSubject, NewNamedNode(predicate), object)
 								quads = append(quads, annotationQuad)
@@ -1285,10 +1330,14 @@ func (p *RDFXMLParser) Parse(reader io.Reader) ([]*Quad, error) {
 			if elem.Name.Local == "Description" && elem.Name.Space == rdfNS {
 				currentSubject = nil
 			}
+
+			if err := flush(); err != nil {
+				return err
+			}
 		}
 	}
 
-	return quads, nil
+	return flush()
 }
 
 // parseContainer parses the contents of an RDF container (Bag, Seq, Alt)
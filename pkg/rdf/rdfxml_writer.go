@@ -0,0 +1,419 @@
+package rdf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// RDFXMLSerializer writes triples as RDF/XML. Each subject becomes a node element, a typed
+// node when one of its types can be written as an element name. Blank nodes referenced once
+// are nested in place with rdf:parseType="Resource", and lists of IRIs and labelled blank
+// nodes are written with rdf:parseType="Collection". Predicates without a namespace in the
+// prefix map get a generated one (ns0, ns1, ...); a predicate IRI that can't be split into a
+// namespace and a local name at all can't be written and fails the serialization.
+type RDFXMLSerializer struct {
+	prefixes map[string]string // namespace IRI by prefix name
+}
+
+// NewRDFXMLSerializer creates an RDF/XML serializer with the rdf, rdfs and xsd prefixes
+func NewRDFXMLSerializer() *RDFXMLSerializer {
+	return &RDFXMLSerializer{prefixes: map[string]string{
+		"rdf":  rdfNamespace,
+		"rdfs": rdfsNamespace,
+		"xsd":  xsdNamespace,
+	}}
+}
+
+// SetPrefix adds a prefix for the namespace, replacing one with the same name
+func (s *RDFXMLSerializer) SetPrefix(name, namespace string) {
+	s.prefixes[name] = namespace
+}
+
+func (s *RDFXMLSerializer) ContentType() string {
+	return "application/rdf+xml"
+}
+
+func (s *RDFXMLSerializer) Serialize(writer io.Writer, quads []*Quad) error {
+	triples, err := defaultGraphTriples(quads, "RDF/XML")
+	if err != nil {
+		return err
+	}
+
+	w := &rdfxmlWriter{
+		turtleWriter: newTurtleDocument(s.prefixes, true).newWriter(1),
+		namespaces:   map[string]string{"rdf": rdfNamespace},
+	}
+	for _, triple := range triples {
+		w.add(triple)
+	}
+	var body strings.Builder
+	if err := w.eachSubject(func(subject *turtleSubject) error {
+		return w.writeNode(&body, subject, 1, w.anonymous(subject.term))
+	}); err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	out.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<rdf:RDF")
+	names := make([]string, 0, len(w.namespaces))
+	for name := range w.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if i > 0 {
+			out.WriteString("\n        ")
+		}
+		fmt.Fprintf(&out, " xmlns:%s=\"%s\"", name, escapeXMLAttribute(w.namespaces[name]))
+	}
+	if w.version12 {
+		out.WriteString("\n         rdf:version=\"1.2\"")
+	}
+	if _, ok := w.namespaces["its"]; ok {
+		out.WriteString("\n         its:version=\"2.0\"")
+	}
+	out.WriteString(">\n")
+	out.WriteString(body.String())
+	out.WriteString("</rdf:RDF>\n")
+
+	if _, err := io.WriteString(writer, out.String()); err != nil {
+		return fmt.Errorf("error writing RDF/XML: %w", err)
+	}
+	return nil
+}
+
+// rdfxmlWriter writes the node elements of a graph laid out by a turtleWriter
+type rdfxmlWriter struct {
+	*turtleWriter
+	namespaces map[string]string // namespaces used, by prefix name
+	version12  bool              // whether RDF 1.2 syntax was written
+}
+
+// writeNode writes a subject as a node element with its property elements. An anonymous
+// node is written without rdf:about or rdf:nodeID.
+func (w *rdfxmlWriter) writeNode(sb *strings.Builder, subject *turtleSubject, depth int, anonymous bool) error {
+	element, typeIndex := "rdf:Description", -1
+	for i, object := range subject.objects[rdfType] {
+		if class, ok := object.(*NamedNode); ok && !reservedRDFName(class.IRI, forbiddenNodeElements) {
+			if name, err := w.qname(class.IRI); err == nil {
+				element, typeIndex = name, i
+				break
+			}
+		}
+	}
+
+	writeIndent(sb, depth)
+	sb.WriteString("<" + element)
+	if !anonymous {
+		attribute, err := w.nodeAttribute(subject.term)
+		if err != nil {
+			return err
+		}
+		sb.WriteString(attribute)
+	}
+
+	var properties strings.Builder
+	for _, predicate := range subject.predicates {
+		for i, object := range subject.objects[predicate.IRI] {
+			if predicate.IRI == rdfType && i == typeIndex {
+				continue
+			}
+			if err := w.writeProperty(&properties, predicate, object, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	if properties.Len() == 0 {
+		sb.WriteString("/>\n")
+		return nil
+	}
+	sb.WriteString(">\n")
+	sb.WriteString(properties.String())
+	writeIndent(sb, depth)
+	sb.WriteString("</" + element + ">\n")
+	return nil
+}
+
+// nodeAttribute returns the rdf:about or rdf:nodeID attribute naming a subject
+func (w *rdfxmlWriter) nodeAttribute(term Term) (string, error) {
+	switch t := term.(type) {
+	case *NamedNode:
+		return ` rdf:about="` + escapeXMLAttribute(t.IRI) + `"`, nil
+	case *BlankNode:
+		return ` rdf:nodeID="` + w.labelFor(t, isXMLName) + `"`, nil
+	default:
+		return "", fmt.Errorf("RDF/XML cannot represent %s as a subject", term)
+	}
+}
+
+// writeProperty writes a property element for a predicate and one of its objects
+func (w *rdfxmlWriter) writeProperty(sb *strings.Builder, predicate *NamedNode, object Term, depth int) error {
+	if reservedRDFName(predicate.IRI, forbiddenPropertyElements) {
+		return fmt.Errorf("RDF/XML cannot represent <%s> as a predicate", predicate.IRI)
+	}
+	element, err := w.qname(predicate.IRI)
+	if err != nil {
+		return err
+	}
+	writeIndent(sb, depth)
+	sb.WriteString("<" + element)
+
+	switch o := object.(type) {
+	case *NamedNode:
+		sb.WriteString(` rdf:resource="` + escapeXMLAttribute(o.IRI) + "\"/>\n")
+		return nil
+
+	case *Literal:
+		if o.Language != "" {
+			sb.WriteString(` xml:lang="` + escapeXMLAttribute(o.Language) + `"`)
+			if o.Direction != "" {
+				w.namespaces["its"] = itsNS
+				w.version12 = true
+				sb.WriteString(` its:dir="` + escapeXMLAttribute(o.Direction) + `"`)
+			}
+		} else if o.Datatype != nil && o.Datatype.IRI != xsdNamespace+"string" {
+			sb.WriteString(` rdf:datatype="` + escapeXMLAttribute(o.Datatype.IRI) + `"`)
+		}
+		text, err := escapeXMLText(o.Value)
+		if err != nil {
+			return err
+		}
+		sb.WriteString(">" + text + "</" + element + ">\n")
+		return nil
+
+	case *TripleTerm:
+		return w.writeTripleTerm(sb, element, o.Subject, o.Predicate, o.Object, depth)
+
+	case *QuotedTriple:
+		return w.writeTripleTerm(sb, element, o.Subject, o.Predicate, o.Object, depth)
+
+	case *BlankNode:
+		if !w.inline(o) {
+			sb.WriteString(` rdf:nodeID="` + w.labelFor(o, isXMLName) + "\"/>\n")
+			return nil
+		}
+		key := serializeTermCanonical(o)
+		w.written[key] = true
+
+		if items, nodes, ok := w.listItems(o); ok && w.collectionItems(items) {
+			w.markWritten(nodes)
+			sb.WriteString(" rdf:parseType=\"Collection\">\n")
+			for _, item := range items {
+				if err := w.writeItem(sb, item, depth+1); err != nil {
+					return err
+				}
+			}
+			writeIndent(sb, depth)
+			sb.WriteString("</" + element + ">\n")
+			return nil
+		}
+
+		subject, ok := w.bySubject[key]
+		if !ok {
+			sb.WriteString(" rdf:parseType=\"Resource\"/>\n")
+			return nil
+		}
+		sb.WriteString(" rdf:parseType=\"Resource\">\n")
+		for _, predicate := range subject.predicates {
+			for _, nested := range subject.objects[predicate.IRI] {
+				if err := w.writeProperty(sb, predicate, nested, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		writeIndent(sb, depth)
+		sb.WriteString("</" + element + ">\n")
+		return nil
+
+	default:
+		return fmt.Errorf("RDF/XML cannot represent %s as an object", object)
+	}
+}
+
+// writeTripleTerm writes a triple term as the content of an rdf:parseType="Triple" property element
+func (w *rdfxmlWriter) writeTripleTerm(sb *strings.Builder, element string, subject, predicate, object Term, depth int) error {
+	iri, ok := predicate.(*NamedNode)
+	if !ok {
+		return fmt.Errorf("RDF/XML cannot represent %s as a predicate", predicate)
+	}
+	attribute, err := w.nodeAttribute(subject)
+	if err != nil {
+		return err
+	}
+	w.version12 = true
+
+	sb.WriteString(" rdf:parseType=\"Triple\">\n")
+	writeIndent(sb, depth+1)
+	sb.WriteString("<rdf:Description" + attribute + ">\n")
+	// Blank nodes in triple terms are always labelled, so the object is never written in place
+	if err := w.writeProperty(sb, iri, object, depth+2); err != nil {
+		return err
+	}
+	writeIndent(sb, depth+1)
+	sb.WriteString("</rdf:Description>\n")
+	writeIndent(sb, depth)
+	sb.WriteString("</" + element + ">\n")
+	return nil
+}
+
+// writeItem writes an item of an rdf:parseType="Collection" list as an empty node element
+func (w *rdfxmlWriter) writeItem(sb *strings.Builder, item Term, depth int) error {
+	attribute, err := w.nodeAttribute(item)
+	if err != nil {
+		return err
+	}
+	writeIndent(sb, depth)
+	sb.WriteString("<rdf:Description" + attribute + "/>\n")
+	return nil
+}
+
+// collectionItems reports whether list items can be written as a collection: IRIs and blank
+// nodes with a label. Blank nodes written in place go in the rdf:first properties instead.
+func (w *rdfxmlWriter) collectionItems(items []Term) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case *NamedNode:
+		case *BlankNode:
+			if w.inline(item) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// reservedRDFName reports whether an IRI is an RDF syntax name that can't be used as an element
+// name where the forbidden names apply. rdf:Description and rdf:li have a meaning of their own
+// in both places.
+func reservedRDFName(iri string, forbidden map[string]bool) bool {
+	local, ok := strings.CutPrefix(iri, rdfNamespace)
+	return ok && (forbidden[local] || local == "Description" || local == "li")
+}
+
+// qname returns the element name of an IRI, prefix:local. The longest namespace of the prefix
+// map that leaves a valid local name is used; otherwise the IRI is split before the longest
+// suffix that is a valid local name and the namespace gets a generated prefix.
+func (w *rdfxmlWriter) qname(iri string) (string, error) {
+	best, bestNamespace := "", ""
+	for name, namespace := range w.prefixes {
+		if !strings.HasPrefix(iri, namespace) || !isXMLName(iri[len(namespace):]) || !isXMLPrefix(name) {
+			continue
+		}
+		if len(namespace) > len(bestNamespace) || (len(namespace) == len(bestNamespace) && name < best) {
+			best, bestNamespace = name, namespace
+		}
+	}
+	if bestNamespace != "" {
+		w.namespaces[best] = bestNamespace
+		return best + ":" + iri[len(bestNamespace):], nil
+	}
+
+	split := len(iri)
+	for split > 0 {
+		r, size := utf8.DecodeLastRuneInString(iri[:split])
+		if !isPN_CHARS(r) && r != '.' {
+			break
+		}
+		split -= size
+	}
+	// The local name must start with a name start character
+	for split < len(iri) && !isXMLName(iri[split:]) {
+		_, size := utf8.DecodeRuneInString(iri[split:])
+		split += size
+	}
+	if split == 0 || split == len(iri) {
+		return "", fmt.Errorf("RDF/XML cannot represent <%s>: no namespace and local name split", iri)
+	}
+
+	namespace := iri[:split]
+	for name, declared := range w.namespaces {
+		if declared == namespace {
+			return name + ":" + iri[split:], nil
+		}
+	}
+	for i := 0; ; i++ {
+		name := fmt.Sprintf("ns%d", i)
+		if _, taken := w.namespaces[name]; taken {
+			continue
+		}
+		if _, taken := w.prefixes[name]; taken {
+			continue
+		}
+		w.namespaces[name] = namespace
+		return name + ":" + iri[split:], nil
+	}
+}
+
+// isXMLName reports whether a string is an XML name without colons (NCName). The Turtle
+// PN_CHARS classes follow the XML name characters, apart from the period.
+func isXMLName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case isPN_CHARS_U(r):
+		case i > 0 && (isPN_CHARS(r) || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isXMLPrefix reports whether a string can be declared as a namespace prefix
+func isXMLPrefix(s string) bool {
+	return isXMLName(s) && !strings.HasPrefix(strings.ToLower(s), "xml")
+}
+
+// escapeXMLText escapes the text content of an element. XML 1.0 can't contain most
+// control characters, so literals with them can't be written.
+func escapeXMLText(s string) (string, error) {
+	var builder strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '&':
+			builder.WriteString("&amp;")
+		case r == '<':
+			builder.WriteString("&lt;")
+		case r == '>':
+			builder.WriteString("&gt;")
+		case r == '\r':
+			builder.WriteString("&#xD;")
+		case r < 0x20 && r != '\t' && r != '\n', r == 0xFFFE, r == 0xFFFF:
+			return "", fmt.Errorf("RDF/XML cannot represent the character U+%04X", r)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String(), nil
+}
+
+// escapeXMLAttribute escapes an attribute value, keeping whitespace characters as they are
+func escapeXMLAttribute(s string) string {
+	var builder strings.Builder
+	for _, r := range s {
+		switch r {
+		case '&':
+			builder.WriteString("&amp;")
+		case '<':
+			builder.WriteString("&lt;")
+		case '"':
+			builder.WriteString("&quot;")
+		case '\t':
+			builder.WriteString("&#x9;")
+		case '\n':
+			builder.WriteString("&#xA;")
+		case '\r':
+			builder.WriteString("&#xD;")
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,148 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+// serializeRDFXML parses Turtle input and writes it as RDF/XML with the given prefixes
+func serializeRDFXML(t *testing.T, input string, prefixes map[string]string) (string, []*Triple) {
+	t.Helper()
+	triples, err := NewTurtleParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	quads := make([]*Quad, len(triples))
+	for i, triple := range triples {
+		quads[i] = NewQuad(triple.Subject, triple.Predicate, triple.Object, NewDefaultGraph())
+	}
+
+	serializer := NewRDFXMLSerializer()
+	for name, namespace := range prefixes {
+		serializer.SetPrefix(name, namespace)
+	}
+	var out strings.Builder
+	if err := serializer.Serialize(&out, quads); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	return out.String(), triples
+}
+
+// reparseRDFXML parses RDF/XML output back into triples
+func reparseRDFXML(t *testing.T, output string) []*Triple {
+	t.Helper()
+	quads, err := NewRDFXMLParser().Parse(strings.NewReader(output))
+	if err != nil {
+		t.Fatalf("Output does not parse: %v\n%s", err, output)
+	}
+	triples := make([]*Triple, len(quads))
+	for i, quad := range quads {
+		triples[i] = NewTriple(quad.Subject, quad.Predicate, quad.Object)
+	}
+	return triples
+}
+
+func TestRDFXMLSerializer_TypedNodesAndNesting(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+ex:alice a ex:Person ;
+    ex:name "Alice"@en ;
+    ex:age 30 ;
+    ex:knows ex:bob ;
+    ex:address [ ex:city "Paris" ] ;
+    ex:pet [ a ex:Cat ; ex:name "Tom" ] .`
+
+	output, triples := serializeRDFXML(t, input, map[string]string{"ex": "http://example.org/"})
+	expected := `<?xml version="1.0" encoding="utf-8"?>
+<rdf:RDF xmlns:ex="http://example.org/"
+         xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+    <ex:Person rdf:about="http://example.org/alice">
+        <ex:name xml:lang="en">Alice</ex:name>
+        <ex:age rdf:datatype="http://www.w3.org/2001/XMLSchema#integer">30</ex:age>
+        <ex:knows rdf:resource="http://example.org/bob"/>
+        <ex:address rdf:parseType="Resource">
+            <ex:city>Paris</ex:city>
+        </ex:address>
+        <ex:pet rdf:parseType="Resource">
+            <rdf:type rdf:resource="http://example.org/Cat"/>
+            <ex:name>Tom</ex:name>
+        </ex:pet>
+    </ex:Person>
+</rdf:RDF>
+`
+	if output != expected {
+		t.Errorf("Unexpected output:\n%s\nExpected:\n%s", output, expected)
+	}
+	if !AreGraphsIsomorphic(triples, reparseRDFXML(t, output)) {
+		t.Errorf("Round trip changed the graph:\n%s", output)
+	}
+}
+
+func TestRDFXMLSerializer_RoundTrip(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+ex:s ex:text "a < b & \"c\"\nline" ;
+    ex:list ( ex:a _:shared ex:b ) ;
+    ex:nested ( ex:a [ ex:p "x" ] ) ;
+    ex:literals ( 1 2 ) ;
+    <http://other.example/vocab#term> "generated" ;
+    <http://other.example/vocab#term2> ex:o ;
+    ex:dir "hello"@en--ltr ;
+    ex:triple <<( ex:s ex:p _:inner )>> .
+_:shared ex:v 1 .
+ex:a ex:ref _:shared .
+ex:b ex:ref _:shared .
+_:c1 ex:next _:c2 .
+_:c2 ex:next _:c1 .
+[] ex:p ex:o .
+ex:t a ex:A, ex:B .`
+
+	output, triples := serializeRDFXML(t, input, map[string]string{"ex": "http://example.org/"})
+	for _, want := range []string{
+		`xmlns:ns0="http://other.example/vocab#"`,
+		`<ns0:term>generated</ns0:term>`,
+		`<ns0:term2 rdf:resource="http://example.org/o"/>`,
+		`rdf:parseType="Collection"`,
+		`rdf:parseType="Triple"`,
+		`rdf:version="1.2"`,
+		`its:dir="ltr"`,
+		`a &lt; b &amp; "c"`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	reparsed := reparseRDFXML(t, output)
+	if len(reparsed) != len(triples) {
+		t.Fatalf("Expected %d triples, got %d:\n%s", len(triples), len(reparsed), output)
+	}
+	if !AreGraphsIsomorphic(triples, reparsed) {
+		t.Errorf("Round trip changed the graph:\n%s", output)
+	}
+}
+
+func TestRDFXMLSerializer_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		quads []*Quad
+	}{
+		{"no local name", []*Quad{NewQuad(
+			NewNamedNode("http://example.org/s"), NewNamedNode("http://example.org/123/"),
+			NewLiteral("x"), NewDefaultGraph())}},
+		{"reserved predicate", []*Quad{NewQuad(
+			NewNamedNode("http://example.org/s"), NewNamedNode(rdfNamespace+"about"),
+			NewLiteral("x"), NewDefaultGraph())}},
+		{"control character", []*Quad{NewQuad(
+			NewNamedNode("http://example.org/s"), NewNamedNode("http://example.org/p"),
+			NewLiteral("bell\x07"), NewDefaultGraph())}},
+		{"named graph", []*Quad{NewQuad(
+			NewNamedNode("http://example.org/s"), NewNamedNode("http://example.org/p"),
+			NewLiteral("x"), NewNamedNode("http://example.org/g"))}},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := NewRDFXMLSerializer().Serialize(&out, tt.quads); err == nil {
+			t.Errorf("%s: expected an error, got:\n%s", tt.name, out.String())
+		}
+	}
+}
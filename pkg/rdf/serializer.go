@@ -0,0 +1,204 @@
+package rdf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RDFSerializer is the interface for writing RDF data in various formats
+type RDFSerializer interface {
+	// Serialize writes quads to a writer
+	Serialize(writer io.Writer, quads []*Quad) error
+
+	// ContentType returns the MIME type this serializer writes
+	ContentType() string
+}
+
+// QuadIterator is a source of quads read one at a time, such as the quads of a store query
+type QuadIterator interface {
+	// Next advances to the next quad, returning false at the end
+	Next() bool
+
+	// Quad returns the current quad
+	Quad() (*Quad, error)
+}
+
+// StreamSerializer is an RDFSerializer that can also write quads as they are read from
+// an iterator, without holding them all in memory
+type StreamSerializer interface {
+	RDFSerializer
+
+	// SerializeStream writes the quads of an iterator to a writer
+	SerializeStream(writer io.Writer, quads QuadIterator) error
+}
+
+// sliceQuadIterator iterates over the quads of a slice
+type sliceQuadIterator struct {
+	quads []*Quad
+	pos   int
+}
+
+// NewSliceQuadIterator creates an iterator over the quads of a slice
+func NewSliceQuadIterator(quads []*Quad) QuadIterator {
+	return &sliceQuadIterator{quads: quads, pos: -1}
+}
+
+func (it *sliceQuadIterator) Next() bool {
+	it.pos++
+	return it.pos < len(it.quads)
+}
+
+func (it *sliceQuadIterator) Quad() (*Quad, error) {
+	return it.quads[it.pos], nil
+}
+
+// NewSerializer creates an RDF serializer based on the content type
+func NewSerializer(contentType string) (RDFSerializer, error) {
+	switch normalizeContentType(contentType) {
+	case "application/n-triples", "text/plain":
+		return &NTriplesSerializer{}, nil
+	case "application/n-quads":
+		return &NQuadsSerializer{}, nil
+	case "text/turtle", "application/x-turtle":
+		return NewTurtleSerializer(), nil
+	case "application/trig", "application/x-trig":
+		return NewTriGSerializer(), nil
+	case "application/rdf+xml", "application/xml", "text/xml":
+		return NewRDFXMLSerializer(), nil
+	case "application/ld+json":
+		return NewJSONLDSerializer(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content type: %s", contentType)
+	}
+}
+
+// GetSerializerContentTypes returns a list of all content types NewSerializer accepts
+func GetSerializerContentTypes() []string {
+	return []string{
+		"text/turtle",
+		"application/x-turtle",
+		"application/trig",
+		"application/x-trig",
+		"application/n-quads",
+		"application/n-triples",
+		"text/plain", // Alias for N-Triples
+		"application/rdf+xml",
+		"application/xml", // Alias for RDF/XML
+		"text/xml",        // Alias for RDF/XML
+		"application/ld+json",
+	}
+}
+
+// normalizeContentType lowercases a content type and removes parameters like charset
+func normalizeContentType(contentType string) string {
+	ct := strings.ToLower(strings.TrimSpace(contentType))
+	if idx := strings.Index(ct, ";"); idx != -1 {
+		ct = strings.TrimSpace(ct[:idx])
+	}
+	return ct
+}
+
+// NTriplesSerializer writes canonical N-Triples (triples only, default graph)
+type NTriplesSerializer struct{}
+
+func (s *NTriplesSerializer) ContentType() string {
+	return "application/n-triples"
+}
+
+func (s *NTriplesSerializer) Serialize(writer io.Writer, quads []*Quad) error {
+	triples, err := defaultGraphTriples(quads, "N-Triples")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(writer, SerializeTriplesCanonical(triples)); err != nil {
+		return fmt.Errorf("error writing N-Triples: %w", err)
+	}
+	return nil
+}
+
+// NQuadsSerializer writes N-Quads in canonical syntax, one line per quad as it is read
+type NQuadsSerializer struct{}
+
+func (s *NQuadsSerializer) ContentType() string {
+	return "application/n-quads"
+}
+
+func (s *NQuadsSerializer) Serialize(writer io.Writer, quads []*Quad) error {
+	return s.SerializeStream(writer, NewSliceQuadIterator(quads))
+}
+
+// SerializeStream writes each quad in the canonical syntax of RDF 1.2 N-Quads: literals escaped
+// with \t \b \n \r \f \" \\ and other control characters as uppercase \uXXXX, no xsd:string
+// datatype, and triple terms as <<( s p o )>>. Unlike SerializeQuadsCanonical it keeps language
+// tags as they are, so a dump reads back as the same data.
+func (s *NQuadsSerializer) SerializeStream(writer io.Writer, quads QuadIterator) error {
+	buffered := bufio.NewWriter(writer)
+	for quads.Next() {
+		quad, err := quads.Quad()
+		if err != nil {
+			return fmt.Errorf("error reading quad: %w", err)
+		}
+		if _, err := buffered.WriteString(nquadsLine(quad)); err != nil {
+			return fmt.Errorf("error writing N-Quads: %w", err)
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("error writing N-Quads: %w", err)
+	}
+	return nil
+}
+
+// nquadsLine serializes a quad as an N-Quads line like serializeQuadCanonical, but with the
+// language tags of its literals as given
+func nquadsLine(quad *Quad) string {
+	var builder strings.Builder
+	builder.WriteString(nquadsTerm(quad.Subject))
+	builder.WriteString(" ")
+	builder.WriteString(nquadsTerm(quad.Predicate))
+	builder.WriteString(" ")
+	builder.WriteString(nquadsTerm(quad.Object))
+	if quad.Graph != nil {
+		if _, isDefault := quad.Graph.(*DefaultGraph); !isDefault {
+			builder.WriteString(" ")
+			builder.WriteString(nquadsTerm(quad.Graph))
+		}
+	}
+	builder.WriteString(" .\n")
+	return builder.String()
+}
+
+// nquadsTerm serializes a term like serializeTermCanonical, keeping language tags as given
+func nquadsTerm(term Term) string {
+	switch t := term.(type) {
+	case *Literal:
+		if t.Language == "" {
+			return serializeLiteralCanonical(t)
+		}
+		quoted := `"` + escapeStringCanonical(t.Value) + `"@` + t.Language
+		if t.Direction != "" {
+			return quoted + "--" + t.Direction
+		}
+		return quoted
+	case *TripleTerm:
+		return "<<( " + nquadsTerm(t.Subject) + " " + nquadsTerm(t.Predicate) + " " + nquadsTerm(t.Object) + " )>>"
+	default:
+		return serializeTermCanonical(term)
+	}
+}
+
+// defaultGraphTriples returns the triples of quads in the default graph,
+// failing for quads in a named graph, which a triple format can't represent
+func defaultGraphTriples(quads []*Quad, format string) ([]*Triple, error) {
+	triples := make([]*Triple, len(quads))
+	for i, quad := range quads {
+		if quad.Graph != nil {
+			if _, isDefault := quad.Graph.(*DefaultGraph); !isDefault {
+				return nil, fmt.Errorf("%s cannot represent quads in named graph %s", format, quad.Graph)
+			}
+		}
+		triples[i] = NewTriple(quad.Subject, quad.Predicate, quad.Object)
+	}
+	return triples, nil
+}
@@ -0,0 +1,414 @@
+package rdf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// QuadHandler receives the quads of a streaming parse one at a time; an error it returns
+// stops the parse and is returned by ParseStream as it is
+type QuadHandler func(quad *Quad) error
+
+// StreamParser is an RDFParser that can also hand quads to a handler as they are read,
+// holding only the statement being parsed in memory instead of the whole document
+type StreamParser interface {
+	RDFParser
+
+	// ParseStream parses RDF data from a reader and calls the handler for each quad.
+	// Unlike Parse it doesn't remove duplicate quads across statements.
+	ParseStream(reader io.Reader, handler QuadHandler) error
+}
+
+// segmentKind is the kind of a piece of Turtle or TriG text read by a statementScanner
+type segmentKind int
+
+const (
+	segmentStatement  segmentKind = iota // Directives and triples up to a terminating '.'
+	segmentGraphOpen                     // The text before the '{' of a TriG graph block
+	segmentGraphClose                    // The '}' closing a TriG graph block
+)
+
+// statementScanner splits Turtle or TriG read from a reader into statements, so a document
+// can be parsed one statement at a time. It tracks just enough of the syntax to find the
+// '.' ending a statement: IRIs, strings, comments and bracket nesting. In TriG mode the
+// statements of a graph block are returned one by one between its open and close segments.
+type statementScanner struct {
+	reader       *bufio.Reader
+	trig         bool
+	inGraph      bool
+	pendingClose bool
+
+	buf        []byte
+	hasContent bool // Whether buf holds anything but whitespace and comments
+	kind       segmentKind
+	text       string
+	err        error
+
+	// Position of the next byte, and of the start of the current segment
+	offset, line, column                int
+	startOffset, startLine, startColumn int
+}
+
+func newStatementScanner(reader io.Reader, trig bool) *statementScanner {
+	return &statementScanner{reader: bufio.NewReaderSize(reader, 64*1024), trig: trig, line: 1, column: 1}
+}
+
+// read reads a byte, keeping track of its position
+func (s *statementScanner) read() (byte, error) {
+	ch, err := s.reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	s.offset++
+	if ch == '\n' {
+		s.line++
+		s.column = 1
+	} else if utf8.RuneStart(ch) {
+		s.column++
+	}
+	return ch, nil
+}
+
+// locate moves a ParseError found in the current segment to its position in the document
+func (s *statementScanner) locate(err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		parseErr.shift(s.startOffset, s.startLine, s.startColumn)
+	}
+	return err
+}
+
+// locateAll moves the ParseErrors found in the current segment to their position in the
+// document
+func (s *statementScanner) locateAll(parseErrs []*ParseError) {
+	for _, parseErr := range parseErrs {
+		parseErr.shift(s.startOffset, s.startLine, s.startColumn)
+	}
+}
+
+// next reads the next segment, returning false at the end of the input or on a read error
+func (s *statementScanner) next() bool {
+	if s.pendingClose {
+		s.pendingClose = false
+		s.kind, s.text = segmentGraphClose, ""
+		return true
+	}
+
+	s.buf = s.buf[:0]
+	s.hasContent = false
+	s.startOffset, s.startLine, s.startColumn = s.offset, s.line, s.column
+	depth := 0
+	for {
+		ch, err := s.read()
+		if err == io.EOF {
+			if !s.hasContent {
+				return false
+			}
+			s.kind, s.text = segmentStatement, string(s.buf)
+			return true
+		}
+		if err != nil {
+			s.err = fmt.Errorf("error reading input: %w", err)
+			return false
+		}
+
+		switch ch {
+		case ' ', '\t', '\r', '\n':
+			s.buf = append(s.buf, ch)
+			continue
+		case '#':
+			s.buf = append(s.buf, ch)
+			if !s.readUntil('\n') {
+				return s.finish()
+			}
+			continue
+		}
+		hadContent := s.hasContent
+		s.hasContent = true
+
+		switch ch {
+		case '"', '\'':
+			if !s.readString(ch) {
+				return s.finish()
+			}
+		case '<':
+			s.buf = append(s.buf, ch)
+			if s.peek() == '<' {
+				// A triple term or reified triple, not an IRI
+				s.buf = append(s.buf, s.readByte())
+			} else if !s.readUntil('>') {
+				return s.finish()
+			}
+		case '[', '(':
+			s.buf = append(s.buf, ch)
+			depth++
+		case ']', ')':
+			s.buf = append(s.buf, ch)
+			depth--
+		case '{':
+			if s.peek() == '|' {
+				// Start of an annotation block
+				s.buf = append(s.buf, ch, s.readByte())
+				depth++
+			} else if s.trig && depth == 0 && !s.inGraph {
+				s.inGraph = true
+				s.kind, s.text = segmentGraphOpen, string(s.buf)
+				return true
+			} else {
+				s.buf = append(s.buf, ch)
+				depth++
+			}
+		case '|':
+			s.buf = append(s.buf, ch)
+			if s.peek() == '}' {
+				s.buf = append(s.buf, s.readByte())
+				depth--
+			}
+		case '}':
+			if s.trig && depth == 0 && s.inGraph {
+				s.inGraph = false
+				if hadContent {
+					// The last statement of a block needn't end with '.'
+					s.pendingClose = true
+					s.kind, s.text = segmentStatement, string(s.buf)
+				} else {
+					s.kind, s.text = segmentGraphClose, ""
+				}
+				return true
+			}
+			s.buf = append(s.buf, ch)
+			depth--
+		case '.':
+			s.buf = append(s.buf, ch)
+			if depth == 0 && s.endsStatement() {
+				s.kind, s.text = segmentStatement, string(s.buf)
+				return true
+			}
+		default:
+			s.buf = append(s.buf, ch)
+		}
+	}
+}
+
+// finish returns what is left in the buffer when the input ends inside a string, IRI or
+// comment, leaving it to the parser to report the unterminated token
+func (s *statementScanner) finish() bool {
+	if s.err != nil || !s.hasContent {
+		return false
+	}
+	s.kind, s.text = segmentStatement, string(s.buf)
+	return true
+}
+
+// endsStatement reports whether the '.' just read ends a statement rather than being part of
+// a number, prefixed name or blank node label
+func (s *statementScanner) endsStatement() bool {
+	next := s.peek()
+	if next >= '0' && next <= '9' {
+		// A statement can't start with a digit, so this is a decimal like .5
+		return false
+	}
+	if len(s.buf) >= 2 {
+		switch s.buf[len(s.buf)-2] {
+		case '>', '"', '\'', ']', ')', ' ', '\t', '\r', '\n':
+			return true
+		}
+	}
+	return next == 0 || !isNameByte(next)
+}
+
+// isNameByte reports whether a byte can continue a prefixed name, blank node label or number
+func isNameByte(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' ||
+		ch == '_' || ch == '-' || ch == ':' || ch == '.' || ch == '%' || ch == '\\' || ch >= 0x80
+}
+
+// readString reads a short or long string literal whose opening quote was just read
+func (s *statementScanner) readString(quote byte) bool {
+	s.buf = append(s.buf, quote)
+	long := false
+	if next, err := s.reader.Peek(2); err == nil && next[0] == quote && next[1] == quote {
+		s.buf = append(s.buf, s.readByte(), s.readByte())
+		long = true
+	}
+
+	quotes := 0
+	for {
+		ch, err := s.read()
+		if err != nil {
+			if err != io.EOF {
+				s.err = fmt.Errorf("error reading input: %w", err)
+			}
+			return false
+		}
+		s.buf = append(s.buf, ch)
+		switch {
+		case ch == '\\':
+			escaped, err := s.read()
+			if err != nil {
+				if err != io.EOF {
+					s.err = fmt.Errorf("error reading input: %w", err)
+				}
+				return false
+			}
+			s.buf = append(s.buf, escaped)
+			quotes = 0
+		case ch == quote:
+			quotes++
+			if !long || quotes == 3 {
+				return true
+			}
+		case ch == '\n' && !long:
+			// Let the parser report the unterminated string
+			return true
+		default:
+			quotes = 0
+		}
+	}
+}
+
+// readUntil reads up to and including the end byte
+func (s *statementScanner) readUntil(end byte) bool {
+	for {
+		ch, err := s.read()
+		if err != nil {
+			if err != io.EOF {
+				s.err = fmt.Errorf("error reading input: %w", err)
+			}
+			return false
+		}
+		s.buf = append(s.buf, ch)
+		if ch == end {
+			return true
+		}
+	}
+}
+
+// peek returns the next byte without reading it, or 0 at the end of the input
+func (s *statementScanner) peek() byte {
+	next, err := s.reader.Peek(1)
+	if err != nil {
+		return 0
+	}
+	return next[0]
+}
+
+// readByte reads a byte that peek has shown is there
+func (s *statementScanner) readByte() byte {
+	ch, _ := s.read()
+	return ch
+}
+
+// parseTurtleStream parses Turtle one statement at a time with a parser whose prefixes, base
+// and blank node counter carry over from statement to statement
+func parseTurtleStream(parser *TurtleParser, reader io.Reader, handler QuadHandler, format string) error {
+	scanner := newStatementScanner(reader, false)
+	for scanner.next() {
+		parser.reset(scanner.text)
+		skipped := len(parser.errors)
+		triples, err := parser.Parse()
+		scanner.locateAll(parser.errors[skipped:])
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", format, scanner.locate(err))
+		}
+		for _, triple := range triples {
+			if err := handler(NewQuad(triple.Subject, triple.Predicate, triple.Object, NewDefaultGraph())); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.err
+}
+
+// parseTriGStream parses TriG one statement at a time, keeping track of the graph block the
+// statements are in
+func parseTriGStream(parser *TriGParser, reader io.Reader, handler QuadHandler) error {
+	scanner := newStatementScanner(reader, true)
+	var graph Term // nil outside graph blocks
+	for scanner.next() {
+		switch scanner.kind {
+		case segmentGraphOpen:
+			parser.reset(scanner.text)
+			name, err := parser.parseGraphLabel()
+			if err != nil {
+				err = NewParseError(parser.input, parser.pos, err)
+				return fmt.Errorf("error parsing TriG: %w", scanner.locate(err))
+			}
+			graph = name
+
+		case segmentGraphClose:
+			graph = nil
+
+		case segmentStatement:
+			if graph == nil {
+				parser.reset(scanner.text)
+				quads, err := parser.Parse()
+				if err != nil {
+					return fmt.Errorf("error parsing TriG: %w", scanner.locate(err))
+				}
+				for _, quad := range quads {
+					if err := handler(quad); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			triples, err := parser.parseGraphStatements(scanner.text)
+			if err != nil {
+				return fmt.Errorf("error parsing TriG: %w", scanner.locate(err))
+			}
+			for _, triple := range triples {
+				if err := handler(NewQuad(triple.Subject, triple.Predicate, triple.Object, graph)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if scanner.err != nil {
+		return scanner.err
+	}
+	if graph != nil {
+		return fmt.Errorf("error parsing TriG: unmatched braces in graph block")
+	}
+	return nil
+}
+
+// parseNQuadsStream parses N-Quads one line at a time
+func parseNQuadsStream(parser *NQuadsParser, reader io.Reader, handler QuadHandler) error {
+	buffered := bufio.NewReaderSize(reader, 64*1024)
+	offset, lineNumber := 0, 1
+	for {
+		line, readErr := buffered.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("error reading input: %w", readErr)
+		}
+		if line != "" {
+			parser.reset(line)
+			skipped := len(parser.errors)
+			quads, err := parser.Parse()
+			for _, parseErr := range parser.errors[skipped:] {
+				parseErr.shift(offset, lineNumber, 1)
+			}
+			if err != nil {
+				var parseErr *ParseError
+				if errors.As(err, &parseErr) {
+					parseErr.shift(offset, lineNumber, 1)
+				}
+				return fmt.Errorf("error parsing N-Quads: %w", err)
+			}
+			for _, quad := range quads {
+				if err := handler(quad); err != nil {
+					return err
+				}
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		offset += len(line)
+		lineNumber++
+	}
+}
@@ -0,0 +1,148 @@
+package rdf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// streamQuads parses input with a parser's ParseStream, reading it a byte at a time
+func streamQuads(t *testing.T, parser StreamParser, input string) []*Quad {
+	t.Helper()
+	var quads []*Quad
+	err := parser.ParseStream(iotest.OneByteReader(strings.NewReader(input)), func(quad *Quad) error {
+		quads = append(quads, quad)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseStream failed: %v", err)
+	}
+	return quads
+}
+
+// assertStreamMatchesParse checks that ParseStream reads the same quads as Parse
+func assertStreamMatchesParse(t *testing.T, parser StreamParser, input string) {
+	t.Helper()
+	expected, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	actual := streamQuads(t, parser, input)
+	if !AreQuadsIsomorphic(expected, actual) {
+		t.Errorf("ParseStream read %d quads, Parse read %d:\n%s\nexpected:\n%s",
+			len(actual), len(expected), SerializeQuadsCanonical(actual), SerializeQuadsCanonical(expected))
+	}
+}
+
+func TestParseStream_Turtle(t *testing.T) {
+	assertStreamMatchesParse(t, &TurtleIOParser{}, `@prefix ex: <http://example.org/> .
+PREFIX foaf: <http://xmlns.com/foaf/0.1/>
+@base <http://example.org/base/> .
+
+# A comment with a dot. And "quotes
+ex:alice a foaf:Person ;
+    foaf:name "Alice. Smith", 'single . quoted' ;
+    ex:bio """Multi-line
+text with "quotes" and a dot.
+""" ;
+    ex:age 30 ; ex:height 1.75 ; ex:weight 6.5e1 ;
+    ex:knows [ foaf:name "Bob" ; ex:tags ( "a" "b" ) ] .
+ex:a.b ex:c.d <relative> .
+_:b1.x ex:p _:b1.x.
+<s> <p> <o>.<s> <p> "v"@en-US.
+ex:s ex:p ex:o {| ex:source <http://example.org/src#frag> |} .
+<< ex:s ex:p ex:o >> ex:certainty 0.9 .
+ex:escaped ex:p "a \" . \\" .`)
+}
+
+func TestParseStream_NTriples(t *testing.T) {
+	assertStreamMatchesParse(t, &NTriplesIOParser{}, `<http://example.org/s> <http://example.org/p> "a.b" .
+_:x <http://example.org/p> <http://example.org/o> .
+# comment
+<http://example.org/s> <http://example.org/p> "1"^^<http://www.w3.org/2001/XMLSchema#integer> .`)
+}
+
+func TestParseStream_NQuads(t *testing.T) {
+	assertStreamMatchesParse(t, &NQuadsIOParser{}, `<http://example.org/s> <http://example.org/p> "a" <http://example.org/g> .
+_:x <http://example.org/p> <http://example.org/o> _:g .
+
+<http://example.org/s> <http://example.org/p> "no newline at the end"`+" .")
+}
+
+func TestParseStream_TriG(t *testing.T) {
+	assertStreamMatchesParse(t, &TriGIOParser{}, `@prefix ex: <http://example.org/> .
+PREFIX foaf: <http://xmlns.com/foaf/0.1/>
+ex:s ex:p ex:o .
+ex:g1 {
+    ex:alice foaf:name "Alice {not a block}" ;
+        ex:knows [ foaf:name "Bob" ] .
+    ex:alice ex:age 30 .
+}
+GRAPH ex:g2 { ex:a ex:b 1.5 . ex:d ex:e ex:f . }
+_:g { ex:x ex:y ex:z }
+[] { ex:x ex:y "anonymous graph" . }
+{ ex:x ex:y "default graph block" }
+ex:t ex:u ex:v .`)
+}
+
+func TestParseStream_EdgeCases(t *testing.T) {
+	for name, input := range map[string]string{
+		"bare decimal":           `<a:s> <a:p> .5 .`,
+		"decimal after comma":    `<a:s> <a:p> 1.5 , .25 .`,
+		"decimal after newline":  "<a:s> <a:p>\n.5 .",
+		"signed decimals":        `<a:s> <a:p> -.5, +.5, -1.5 .`,
+		"exponent":               `<a:s> <a:p> .5e3, 1.E2, 4. <a:s> <a:q> 7 .`,
+		"integer then statement": `<a:s> <a:p> 1.<a:s> <a:q> 2 .`,
+		"decimal in list":        `<a:s> <a:p> ( .5 1.5 ) .`,
+		"decimal in annotation":  `<a:s> <a:p> <a:o> {| <a:q> .5 |} .`,
+		"prefixed name dots":     "@prefix ex: <http://example.org/> .\nex:a.b ex:p .5 .",
+	} {
+		t.Run(name, func(t *testing.T) {
+			assertStreamMatchesParse(t, &TurtleIOParser{}, input)
+		})
+	}
+}
+
+func TestParseStream_RDFXML(t *testing.T) {
+	assertStreamMatchesParse(t, &RDFXMLIOParser{}, `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://example.org/">
+  <rdf:Description rdf:about="http://example.org/alice">
+    <ex:name xml:lang="en">Alice</ex:name>
+    <ex:knows>
+      <rdf:Description rdf:about="http://example.org/bob"><ex:name>Bob</ex:name></rdf:Description>
+    </ex:knows>
+  </rdf:Description>
+  <rdf:Seq rdf:about="http://example.org/list"><rdf:li>one</rdf:li><rdf:li>two</rdf:li></rdf:Seq>
+  <ex:Thing rdf:nodeID="n1" ex:label="thing"/>
+</rdf:RDF>`)
+}
+
+func TestParseStream_Errors(t *testing.T) {
+	// Errors from the handler stop the parse and are returned as they are
+	stop := errors.New("stop")
+	calls := 0
+	err := (&TurtleIOParser{}).ParseStream(strings.NewReader(`<a:s> <a:p> <a:o1> . <a:s> <a:p> <a:o2> .`), func(*Quad) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Expected the handler's error after one quad, got %v after %d", err, calls)
+	}
+
+	// Syntax errors are reported for the statement they're in
+	for name, test := range map[string]struct {
+		parser StreamParser
+		input  string
+	}{
+		"turtle": {&TurtleIOParser{}, `<a:s> <a:p> <a:o> . <a:s> <a:p> .`},
+		"string": {&TurtleIOParser{}, `<a:s> <a:p> "unterminated .`},
+		"trig":   {&TriGIOParser{}, `<a:g> { <a:s> <a:p> <a:o> .`},
+		"nquads": {&NQuadsIOParser{}, "<a:s> <a:p> <a:o> <a:g> <a:x> .\n"},
+		"rdfxml": {&RDFXMLIOParser{}, `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description>`},
+	} {
+		if err := test.parser.ParseStream(strings.NewReader(test.input), func(*Quad) error { return nil }); err == nil {
+			t.Errorf("%s: expected a parse error", name)
+		}
+	}
+}
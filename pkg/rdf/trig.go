@@ -31,8 +31,25 @@ func (p *TriGParser) SetBaseURI(baseURI string) {
 	p.base = baseURI
 }
 
+// reset points the parser at new input, keeping its prefixes, base and blank node counter
+// so a document can be parsed a statement at a time
+func (p *TriGParser) reset(input string) {
+	p.input = input
+	p.pos = 0
+	p.length = len(input)
+}
+
 // Parse parses the TriG document and returns quads
 func (p *TriGParser) Parse() ([]*Quad, error) {
+	quads, err := p.parseStatements()
+	if err != nil {
+		return nil, NewParseError(p.input, p.pos, err)
+	}
+	return quads, nil
+}
+
+// parseStatements parses the directives, triple blocks and graph blocks of the input
+func (p *TriGParser) parseStatements() ([]*Quad, error) {
 	var quads []*Quad
 
 	for p.pos < p.length {
@@ -143,28 +160,9 @@ func (p *TriGParser) Parse() ([]*Quad, error) {
 func (p *TriGParser) parseGraphBlock() ([]*Quad, error) {
 	p.skipWhitespaceAndComments()
 
-	// Check for GRAPH [] (anonymous blank node) syntax
-	var graphTerm Term
-	if p.pos+1 < p.length && p.input[p.pos] == '[' && p.input[p.pos+1] == ']' {
-		// Anonymous blank node
-		p.pos += 2 // skip '[]'
-		p.blankNodeCounter++
-		graphTerm = NewBlankNode(fmt.Sprintf("anon%d", p.blankNodeCounter))
-	} else {
-		// Parse graph IRI or blank node
-		var err error
-		graphTerm, err = p.parseTerm()
-		if err != nil {
-			return nil, fmt.Errorf("expected graph IRI or blank node after GRAPH: %w", err)
-		}
-
-		// Graph must be a named node or blank node
-		switch graphTerm.(type) {
-		case *NamedNode, *BlankNode:
-			// Valid graph name
-		default:
-			return nil, fmt.Errorf("graph name must be an IRI or blank node, got: %T", graphTerm)
-		}
+	graphTerm, err := p.parseGraphName()
+	if err != nil {
+		return nil, err
 	}
 
 	p.skipWhitespaceAndComments()
@@ -192,6 +190,69 @@ func (p *TriGParser) parseGraphBlock() ([]*Quad, error) {
 	return quads, nil
 }
 
+// parseGraphName parses the name after GRAPH: an IRI, a blank node, or [] for a new blank node
+func (p *TriGParser) parseGraphName() (Term, error) {
+	// Check for GRAPH [] (anonymous blank node) syntax
+	if p.pos+1 < p.length && p.input[p.pos] == '[' && p.input[p.pos+1] == ']' {
+		p.pos += 2 // skip '[]'
+		p.blankNodeCounter++
+		return NewBlankNode(fmt.Sprintf("anon%d", p.blankNodeCounter)), nil
+	}
+
+	// Parse graph IRI or blank node
+	graphTerm, err := p.parseTerm()
+	if err != nil {
+		return nil, fmt.Errorf("expected graph IRI or blank node after GRAPH: %w", err)
+	}
+
+	// Graph must be a named node or blank node
+	switch graphTerm.(type) {
+	case *NamedNode, *BlankNode:
+		return graphTerm, nil
+	default:
+		return nil, fmt.Errorf("graph name must be an IRI or blank node, got: %T", graphTerm)
+	}
+}
+
+// parseGraphLabel parses the text a stream read before the '{' of a graph block: directives
+// without a terminating '.', then GRAPH and a graph name, a graph name alone, or nothing for
+// a block in the default graph
+func (p *TriGParser) parseGraphLabel() (Term, error) {
+	for {
+		p.skipWhitespaceAndComments()
+		if p.matchExactKeyword("@prefix") || p.matchKeyword("PREFIX") {
+			if err := p.parsePrefix(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		turtleStyle := p.matchExactKeyword("@base")
+		if turtleStyle || p.matchKeyword("BASE") {
+			if err := p.parseBase(turtleStyle); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		break
+	}
+
+	if p.pos >= p.length {
+		return NewDefaultGraph(), nil
+	}
+	if p.matchKeyword("GRAPH") {
+		p.skipWhitespaceAndComments()
+	}
+	graphTerm, err := p.parseGraphName()
+	if err != nil {
+		return nil, err
+	}
+	p.skipWhitespaceAndComments()
+	if p.pos < p.length {
+		return nil, fmt.Errorf("expected '{' after graph name")
+	}
+	return graphTerm, nil
+}
+
 // parseAnonymousGraphBlock parses an anonymous graph block: { triples }
 func (p *TriGParser) parseAnonymousGraphBlock() ([]*Quad, error) {
 	// Expect '{'
@@ -268,15 +329,26 @@ func (p *TriGParser) parseTriplesBlock(startPos int) ([]*Triple, int, error) {
 		return nil, pos, fmt.Errorf("unmatched braces in graph block")
 	}
 
-	// Extract the content between braces
-	content := p.input[startPos:pos]
+	// Parse the content between braces
+	triples, err := p.parseGraphStatements(p.input[startPos:pos])
+	if err != nil {
+		return nil, pos, shiftParseError(err, p.input, startPos)
+	}
+
+	// Skip the closing '}'
+	pos++
 
+	return triples, pos, nil
+}
+
+// parseGraphStatements parses the triples content of a graph block using TurtleParser
+func (p *TriGParser) parseGraphStatements(content string) ([]*Triple, error) {
 	// Validate that directives are not used inside graph blocks
 	// Per TriG spec, @prefix, @base, PREFIX, and BASE are only allowed at document level
 	trimmedContent := strings.TrimSpace(content)
 	if strings.HasPrefix(trimmedContent, "@prefix") || strings.HasPrefix(trimmedContent, "@base") ||
 		strings.HasPrefix(strings.ToUpper(trimmedContent), "PREFIX") || strings.HasPrefix(strings.ToUpper(trimmedContent), "BASE") {
-		return nil, pos, fmt.Errorf("directives (@prefix, @base, PREFIX, BASE) not allowed inside graph blocks")
+		return nil, fmt.Errorf("directives (@prefix, @base, PREFIX, BASE) not allowed inside graph blocks")
 	}
 
 	// TriG allows optional trailing '.' in graph blocks, but Turtle parser expects it
@@ -297,20 +369,19 @@ func (p *TriGParser) parseTriplesBlock(startPos int) ([]*Triple, int, error) {
 	// Parse the triples
 	triples, err := turtleParser.Parse()
 	if err != nil {
-		return nil, pos, fmt.Errorf("failed to parse triples in graph block: %w", err)
+		return nil, fmt.Errorf("failed to parse triples in graph block: %w", err)
 	}
 
 	// Update TriG parser's blank node counter to maintain uniqueness across graph blocks
 	p.blankNodeCounter = turtleParser.blankNodeCounter
 
-	// Skip the closing '}'
-	pos++
-
-	return triples, pos, nil
+	return triples, nil
 }
 
 // ensureProperTermination ensures content ends with '.' if it contains triples
-// This is needed because TriG allows optional '.' but Turtle parser requires it
+// This is needed because TriG allows optional '.' after the last triple of a block but
+// Turtle parser requires it. Only a '.' that is the last token counts: one inside the
+// content, like the point of a decimal or the end of an earlier triple, doesn't.
 func (p *TriGParser) ensureProperTermination(content string) string {
 	// Trim whitespace
 	trimmed := strings.TrimSpace(content)
@@ -318,9 +389,9 @@ func (p *TriGParser) ensureProperTermination(content string) string {
 		return content
 	}
 
-	// Find the last '.' that's not in a comment, string, or IRI
-	// Scan forward and track the last '.' we see outside these contexts
-	lastDotPos := -1
+	// Scan forward, tracking whether the last character outside comments is a '.' that is
+	// not in a string or IRI
+	endsWithDot := false
 	inComment := false
 	inString := false
 	inLongString := false
@@ -330,6 +401,12 @@ func (p *TriGParser) ensureProperTermination(content string) string {
 	for i := 0; i < len(trimmed); i++ {
 		ch := trimmed[i]
 
+		// Any other character outside comments means the content doesn't end with a '.'
+		startsComment := ch == '#' && !inString && !inIRI
+		if !inComment && !startsComment && ch != ' ' && ch != '\t' && ch != '\r' && ch != '\n' {
+			endsWithDot = false
+		}
+
 		// Handle escape sequences in strings (both regular and long)
 		if inString && ch == '\\' && i+1 < len(trimmed) {
 			i++ // Skip next character
@@ -391,14 +468,14 @@ func (p *TriGParser) ensureProperTermination(content string) string {
 			continue
 		}
 
-		// Record '.' positions that are not in comments, strings, or IRIs
+		// Record a '.' that is not in a comment, string, or IRI
 		if !inComment && !inString && !inLongString && !inIRI && ch == '.' {
-			lastDotPos = i
+			endsWithDot = true
 		}
 	}
 
-	// If we found a '.', we're good
-	if lastDotPos >= 0 {
+	// If the content ends with a '.', we're good
+	if endsWithDot {
 		return content
 	}
 
@@ -436,7 +513,7 @@ func (p *TriGParser) parseDefaultGraphTripleBlock() ([]*Triple, error) {
 	// Parse the triples
 	triples, err := turtleParser.Parse()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse triple block: %w", err)
+		return nil, shiftParseError(fmt.Errorf("failed to parse triple block: %w", err), p.input, startPos)
 	}
 
 	// Update TriG parser's blank node counter to maintain uniqueness
@@ -487,7 +564,7 @@ func (p *TriGParser) findTripleBlockEnd(start int) int {
 
 		// Check for triple block terminators when not in string or IRI
 		if !inString && !inIRI {
-			if ch == '.' {
+			if ch == '.' && p.dotEndsStatement(pos) {
 				// Found the end, return position after the '.'
 				return pos + 1
 			}
@@ -516,6 +593,20 @@ func (p *TriGParser) findTripleBlockEnd(start int) int {
 	return -1
 }
 
+// dotEndsStatement reports whether the '.' at pos ends a statement rather than being the
+// point of a number like 1.5 or .5, or part of a prefixed name or blank node label
+func (p *TriGParser) dotEndsStatement(pos int) bool {
+	if pos+1 >= p.length {
+		return true
+	}
+	next := p.input[pos+1]
+	if next >= '0' && next <= '9' {
+		// A statement can't start with a digit
+		return false
+	}
+	return pos == 0 || !isNameByte(p.input[pos-1]) || !isNameByte(next)
+}
+
 // parseTerm parses an RDF term (IRI, blank node, or literal)
 func (p *TriGParser) parseTerm() (Term, error) {
 	p.skipWhitespaceAndComments()
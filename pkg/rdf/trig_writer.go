@@ -0,0 +1,162 @@
+package rdf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// trigBlockQuads is the number of quads the TriG writer lays out at once
+const trigBlockQuads = 10000
+
+// TriGSerializer writes quads as TriG, with the triples of each graph written as readable
+// Turtle inside a graph block. A dataset of up to trigBlockQuads quads is laid out as a whole,
+// like the Turtle writer does. Larger ones are streamed in blocks of that many quads: every
+// blank node is labelled, only the prefixes used by the first block are declared, and a
+// graph whose quads are spread over several blocks gets a graph block in each of them.
+type TriGSerializer struct {
+	prefixes map[string]string // namespace IRI by prefix name
+}
+
+// NewTriGSerializer creates a TriG serializer with the rdf, rdfs and xsd prefixes
+func NewTriGSerializer() *TriGSerializer {
+	return &TriGSerializer{prefixes: map[string]string{
+		"rdf":  rdfNamespace,
+		"rdfs": rdfsNamespace,
+		"xsd":  xsdNamespace,
+	}}
+}
+
+// SetPrefix adds a prefix for abbreviating IRIs in the namespace, replacing one with the same name
+func (s *TriGSerializer) SetPrefix(name, namespace string) {
+	s.prefixes[name] = namespace
+}
+
+func (s *TriGSerializer) ContentType() string {
+	return "application/trig"
+}
+
+func (s *TriGSerializer) Serialize(writer io.Writer, quads []*Quad) error {
+	return s.SerializeStream(writer, NewSliceQuadIterator(quads))
+}
+
+func (s *TriGSerializer) SerializeStream(writer io.Writer, quads QuadIterator) error {
+	block, err := readQuadBlock(quads, trigBlockQuads)
+	if err != nil {
+		return err
+	}
+	streaming := len(block) == trigBlockQuads
+	doc := newTurtleDocument(s.prefixes, !streaming)
+
+	var body strings.Builder
+	writeTriGBlock(doc, block, &body)
+	var header strings.Builder
+	doc.writePrefixes(&header)
+	if header.Len() > 0 && body.Len() > 0 {
+		header.WriteString("\n")
+	}
+
+	buffered := bufio.NewWriter(writer)
+	if _, err := buffered.WriteString(header.String() + body.String()); err != nil {
+		return fmt.Errorf("error writing TriG: %w", err)
+	}
+
+	if streaming {
+		// The header is written, so later blocks may only use the prefixes it declares
+		declared := make(map[string]string, len(doc.used))
+		for name := range doc.used {
+			declared[name] = doc.prefixes[name]
+		}
+		doc.prefixes = declared
+
+		for len(block) == trigBlockQuads {
+			if block, err = readQuadBlock(quads, trigBlockQuads); err != nil {
+				return err
+			}
+			body.Reset()
+			writeTriGBlock(doc, block, &body)
+			if body.Len() == 0 {
+				continue
+			}
+			if _, err := buffered.WriteString("\n" + body.String()); err != nil {
+				return fmt.Errorf("error writing TriG: %w", err)
+			}
+		}
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("error writing TriG: %w", err)
+	}
+	return nil
+}
+
+// readQuadBlock reads up to n quads from an iterator
+func readQuadBlock(quads QuadIterator, n int) ([]*Quad, error) {
+	block := make([]*Quad, 0, min(n, 1024))
+	for len(block) < n && quads.Next() {
+		quad, err := quads.Quad()
+		if err != nil {
+			return nil, fmt.Errorf("error reading quad: %w", err)
+		}
+		block = append(block, quad)
+	}
+	return block, nil
+}
+
+// writeTriGBlock writes quads grouped by graph, in order of first appearance: the triples of
+// the default graph at the top level and those of a named graph inside a graph block
+func writeTriGBlock(doc *turtleDocument, quads []*Quad, sb *strings.Builder) {
+	var names []Term
+	writers := make(map[string]*turtleWriter)
+	for _, quad := range quads {
+		key := trigGraphKey(quad.Graph)
+		w, ok := writers[key]
+		if !ok {
+			indent := 0
+			if key != "" {
+				indent = 1
+			}
+			if _, ok := quad.Graph.(*BlankNode); ok {
+				// A blank graph name is referenced by its label
+				doc.pinned[key] = true
+			}
+			w = doc.newWriter(indent)
+			w.graph = key
+			writers[key] = w
+			names = append(names, quad.Graph)
+		}
+		w.add(NewTriple(quad.Subject, quad.Predicate, quad.Object))
+	}
+
+	// Every graph is added before any is written, so blank nodes shared between them are known
+	for _, name := range names {
+		key := trigGraphKey(name)
+		w := writers[key]
+		if key == "" {
+			w.writeSubjects(sb)
+			continue
+		}
+
+		var graph strings.Builder
+		w.writeSubjects(&graph)
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(doc.term(name))
+		sb.WriteString(" {\n")
+		sb.WriteString(graph.String())
+		sb.WriteString("}\n")
+	}
+}
+
+// trigGraphKey returns the key of a graph name, "" for the default graph
+func trigGraphKey(graph Term) string {
+	if graph == nil {
+		return ""
+	}
+	if _, isDefault := graph.(*DefaultGraph); isDefault {
+		return ""
+	}
+	return serializeTermCanonical(graph)
+}
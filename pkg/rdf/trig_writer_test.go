@@ -0,0 +1,210 @@
+package rdf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTriGSerializer_GraphBlocks(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+ex:s ex:p "default" .
+ex:g1 {
+    ex:s ex:p [ ex:q "inner" ] ;
+        ex:list ( 1 2 ) .
+}
+ex:g2 { ex:a ex:ref _:shared . }
+ex:g3 { _:shared ex:v 1 . }
+_:bg { ex:x ex:y ex:z . }`
+
+	quads, err := NewTriGParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	serializer := NewTriGSerializer()
+	serializer.SetPrefix("ex", "http://example.org/")
+	var out strings.Builder
+	if err := serializer.Serialize(&out, quads); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	output := out.String()
+
+	for _, want := range []string{
+		"@prefix ex: <http://example.org/> .\n\nex:s ex:p \"default\" .\n",
+		"ex:g1 {\n    ex:s ex:p [ ex:q \"inner\" ] ;\n        ex:list ( 1 2 ) .\n}\n",
+		"ex:g2 {\n    ex:a ex:ref _:",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "@prefix rdf:") {
+		t.Errorf("Only the prefixes used should be declared:\n%s", output)
+	}
+
+	reparsed, err := NewTriGParser(output).Parse()
+	if err != nil {
+		t.Fatalf("Output does not parse: %v\n%s", err, output)
+	}
+	if !AreQuadsIsomorphic(quads, reparsed) {
+		t.Errorf("Round trip changed the dataset:\n%s", output)
+	}
+}
+
+func TestTriGSerializer_Streaming(t *testing.T) {
+	// More quads than fit in a block, spread over two graphs and sharing blank nodes
+	var quads []*Quad
+	ex := "http://example.org/"
+	for i := 0; i < trigBlockQuads*2+10; i++ {
+		graph := NewNamedNode(ex + fmt.Sprintf("g%d", i%2))
+		subject := NewNamedNode(ex + fmt.Sprintf("s%d", i%50))
+		quads = append(quads,
+			NewQuad(subject, NewNamedNode(ex+"p"), NewIntegerLiteral(int64(i)), graph),
+			NewQuad(NewNamedNode(ex+fmt.Sprintf("t%d", i)), NewNamedNode(ex+"node"), NewBlankNode(fmt.Sprintf("n%d", i%7)), NewDefaultGraph()),
+		)
+	}
+
+	serializer := NewTriGSerializer()
+	serializer.SetPrefix("ex", ex)
+	var out strings.Builder
+	if err := serializer.SerializeStream(&out, NewSliceQuadIterator(quads)); err != nil {
+		t.Fatalf("SerializeStream failed: %v", err)
+	}
+	output := out.String()
+
+	if strings.Count(output, "@prefix ex:") != 1 {
+		t.Errorf("Expected the prefixes to be declared once")
+	}
+	if strings.Count(output, "ex:g0 {") < 2 {
+		t.Errorf("Expected a graph block per block of quads")
+	}
+	reparsed, err := NewTriGParser(output).Parse()
+	if err != nil {
+		t.Fatalf("Output does not parse: %v", err)
+	}
+	if len(reparsed) != len(quads) {
+		t.Fatalf("Expected %d quads, got %d", len(quads), len(reparsed))
+	}
+	// Blank nodes keep their labels, so the quads can be compared directly
+	// instead of through the isomorphism check, which is slow for this many
+	sortedLines := func(quads []*Quad) string {
+		lines := strings.Split(SerializeQuadsCanonical(quads), "\n")
+		sort.Strings(lines)
+		return strings.Join(lines, "\n")
+	}
+	if sortedLines(quads) != sortedLines(reparsed) {
+		t.Error("Round trip changed the dataset")
+	}
+}
+
+func TestTriGSerializer_Numbers(t *testing.T) {
+	// Decimals and doubles are written bare, in the default graph and in graph blocks,
+	// including as the last triple of a block
+	ex := "http://example.org/"
+	var quads []*Quad
+	for i, value := range []*Literal{
+		NewLiteralWithDatatype("1.5", XSDDecimal),
+		NewLiteralWithDatatype("-0.25", XSDDecimal),
+		NewLiteralWithDatatype(".5", XSDDecimal),
+		NewLiteralWithDatatype("1.5E-2", XSDDouble),
+		NewLiteralWithDatatype("4", XSDInteger),
+	} {
+		predicate := NewNamedNode(ex + fmt.Sprintf("p%d", i))
+		quads = append(quads,
+			NewQuad(NewNamedNode(ex+"s"), predicate, value, NewDefaultGraph()),
+			NewQuad(NewNamedNode(ex+"s"), predicate, value, NewNamedNode(ex+"g")),
+			NewQuad(NewBlankNode(fmt.Sprintf("b%d", i)), predicate, value, NewNamedNode(ex+fmt.Sprintf("g%d", i))),
+		)
+	}
+
+	var out strings.Builder
+	if err := NewTriGSerializer().Serialize(&out, quads); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	output := out.String()
+	for _, want := range []string{" 1.5", " -0.25", " .5", " 1.5E-2"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	reparsed, err := NewTriGParser(output).Parse()
+	if err != nil {
+		t.Fatalf("Output does not parse: %v\n%s", err, output)
+	}
+	if !AreQuadsIsomorphic(quads, reparsed) {
+		t.Errorf("Round trip changed the dataset:\n%s", output)
+	}
+	var streamed []*Quad
+	err = (&TriGIOParser{}).ParseStream(strings.NewReader(output), func(quad *Quad) error {
+		streamed = append(streamed, quad)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Output does not stream: %v\n%s", err, output)
+	}
+	if !AreQuadsIsomorphic(quads, streamed) {
+		t.Errorf("Streamed round trip changed the dataset:\n%s", output)
+	}
+
+	// Without a final '.', a number still ends the last triple of a block
+	for _, input := range []string{
+		`<a:g> { <a:s> <a:p> 1.5 }`,
+		`<a:g> { <a:s> <a:p> <a:o> . <a:s> <a:p> .5 }`,
+		`<a:s> <a:p> 1.5E-2 . <a:g> { <a:s> <a:p> -0.25 }`,
+	} {
+		if _, err := NewTriGParser(input).Parse(); err != nil {
+			t.Errorf("Failed to parse %q: %v", input, err)
+		}
+	}
+}
+
+func TestNQuadsSerializer_Escaping(t *testing.T) {
+	ex := "http://example.org/"
+	quads := []*Quad{
+		NewQuad(NewNamedNode(ex+"s"), NewNamedNode(ex+"p"),
+			NewLiteral("tab\tline\nquote\" back\\ bell\x07 del\x7f é"), NewNamedNode(ex+"g")),
+		NewQuad(NewBlankNode("b0"), NewNamedNode(ex+"p"),
+			NewLiteralWithLanguage("Hallo", "DE"), NewDefaultGraph()),
+		NewQuad(NewNamedNode(ex+"s"), NewNamedNode(ex+"says"),
+			&TripleTerm{Subject: NewNamedNode(ex + "a"), Predicate: NewNamedNode(ex + "b"), Object: NewLiteral("c")}, NewBlankNode("g")),
+		NewQuad(NewNamedNode(ex+"s"), NewNamedNode(ex+"p"),
+			NewLiteralWithDatatype("x", NewNamedNode(xsdNamespace+"string")), NewDefaultGraph()),
+	}
+
+	var out strings.Builder
+	if err := (&NQuadsSerializer{}).Serialize(&out, quads); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	expected := `<http://example.org/s> <http://example.org/p> "tab\tline\nquote\" back\\ bell\u0007 del\u007F é" <http://example.org/g> .
+_:b0 <http://example.org/p> "Hallo"@DE .
+<http://example.org/s> <http://example.org/says> <<( <http://example.org/a> <http://example.org/b> "c" )>> _:g .
+<http://example.org/s> <http://example.org/p> "x" .
+`
+	if out.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nExpected:\n%s", out.String(), expected)
+	}
+
+	reparsed, err := NewNQuadsParser(out.String()).Parse()
+	if err != nil {
+		t.Fatalf("Output does not parse: %v", err)
+	}
+	if len(reparsed) != len(quads) {
+		t.Errorf("Expected %d quads, got %d", len(quads), len(reparsed))
+	}
+
+	// A dump keeps language tags as given, so it reads back as the same data
+	tagged := []*Quad{NewQuad(NewNamedNode(ex+"s"), NewNamedNode(ex+"p"), NewLiteralWithLanguage("color", "en-US"), NewDefaultGraph())}
+	out.Reset()
+	if err := (&NQuadsSerializer{}).Serialize(&out, tagged); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	reparsed, err = NewNQuadsParser(out.String()).Parse()
+	if err != nil {
+		t.Fatalf("Output does not parse: %v", err)
+	}
+	if !AreQuadsIsomorphic(tagged, reparsed) {
+		t.Errorf("Round trip changed the language tag: %s", out.String())
+	}
+}
@@ -1,6 +1,7 @@
 package rdf
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -18,6 +19,8 @@ type TurtleParser struct {
 	strictNTriples          bool      // When true, enforce strict N-Triples syntax
 	extraTriples            []*Triple // Triples generated during term parsing (collections, blank node property lists)
 	lastTermWasPropertyList bool      // True if the last parsed term was a blank node property list
+	lenient                 bool      // When true, skip statements with syntax errors
+	errors                  []*ParseError
 }
 
 // NewTurtleParser creates a new Turtle parser
@@ -47,58 +50,42 @@ func (p *TurtleParser) SetBaseURI(baseURI string) {
 	p.base = baseURI
 }
 
+// reset points the parser at new input, keeping its prefixes, base and blank node counter
+// so a document can be parsed a statement at a time
+func (p *TurtleParser) reset(input string) {
+	p.input = input
+	p.pos = 0
+	p.length = len(input)
+}
+
+// SetLenient makes Parse skip the rest of the line a syntax error is on and carry on with the
+// next statement, collecting the errors instead of failing. It is meant for N-Triples, where
+// every statement is on a line of its own.
+func (p *TurtleParser) SetLenient(lenient bool) {
+	p.lenient = lenient
+}
+
+// Errors returns the syntax errors skipped in lenient mode
+func (p *TurtleParser) Errors() []*ParseError {
+	return p.errors
+}
+
 // Parse parses the Turtle document and returns triples
 func (p *TurtleParser) Parse() ([]*Triple, error) {
 	var triples []*Triple
 
 	for p.pos < p.length {
 		p.skipWhitespaceAndComments()
-		if p.pos >= p.length {
-			break
-		}
-
-		// Check for VERSION directive (RDF 1.2 Turtle)
-		// @version must be lowercase (case-sensitive), VERSION can be any case (case-insensitive)
-		if p.matchExactKeyword("@version") || p.matchKeyword("VERSION") {
-			if p.strictNTriples {
-				return nil, fmt.Errorf("VERSION directive not allowed in N-Triples")
-			}
-			if err := p.parseVersion(); err != nil {
-				return nil, err
-			}
-			continue
-		}
-
-		// Check for PREFIX directive
-		// @prefix must be lowercase (case-sensitive), PREFIX can be any case (case-insensitive)
-		if p.matchExactKeyword("@prefix") || p.matchKeyword("PREFIX") {
-			if p.strictNTriples {
-				return nil, fmt.Errorf("PREFIX directive not allowed in N-Triples")
-			}
-			if err := p.parsePrefix(); err != nil {
-				return nil, err
-			}
-			continue
-		}
-
-		// Check for BASE directive
-		// @base must be lowercase (case-sensitive), BASE can be any case (case-insensitive)
-		isTurtleBase := p.matchExactKeyword("@base")
-		if isTurtleBase || p.matchKeyword("BASE") {
-			if p.strictNTriples {
-				return nil, fmt.Errorf("BASE directive not allowed in N-Triples")
-			}
-			if err := p.parseBase(isTurtleBase); err != nil {
+		start := p.pos
+		blockTriples, err := p.parseStatement()
+		if err != nil {
+			err = NewParseError(p.input, p.pos, err)
+			if !p.lenient {
 				return nil, err
 			}
+			p.recover(start, err)
 			continue
 		}
-
-		// Parse triple block (may return multiple triples due to property list syntax)
-		blockTriples, err := p.parseTripleBlock()
-		if err != nil {
-			return nil, err
-		}
 		triples = append(triples, blockTriples...)
 	}
 
@@ -117,6 +104,61 @@ func (p *TurtleParser) Parse() ([]*Triple, error) {
 	return uniqueTriples, nil
 }
 
+// parseStatement parses a directive or a triple block, returning the triples it produced
+func (p *TurtleParser) parseStatement() ([]*Triple, error) {
+	p.skipWhitespaceAndComments()
+	if p.pos >= p.length {
+		return nil, nil
+	}
+
+	// Check for VERSION directive (RDF 1.2 Turtle)
+	// @version must be lowercase (case-sensitive), VERSION can be any case (case-insensitive)
+	if p.matchExactKeyword("@version") || p.matchKeyword("VERSION") {
+		if p.strictNTriples {
+			return nil, fmt.Errorf("VERSION directive not allowed in N-Triples")
+		}
+		return nil, p.parseVersion()
+	}
+
+	// Check for PREFIX directive
+	// @prefix must be lowercase (case-sensitive), PREFIX can be any case (case-insensitive)
+	if p.matchExactKeyword("@prefix") || p.matchKeyword("PREFIX") {
+		if p.strictNTriples {
+			return nil, fmt.Errorf("PREFIX directive not allowed in N-Triples")
+		}
+		return nil, p.parsePrefix()
+	}
+
+	// Check for BASE directive
+	// @base must be lowercase (case-sensitive), BASE can be any case (case-insensitive)
+	isTurtleBase := p.matchExactKeyword("@base")
+	if isTurtleBase || p.matchKeyword("BASE") {
+		if p.strictNTriples {
+			return nil, fmt.Errorf("BASE directive not allowed in N-Triples")
+		}
+		return nil, p.parseBase(isTurtleBase)
+	}
+
+	// Parse triple block (may return multiple triples due to property list syntax)
+	return p.parseTripleBlock()
+}
+
+// recover records a syntax error in lenient mode and skips to the line after the one the
+// failed statement starts on
+func (p *TurtleParser) recover(start int, err error) {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		p.errors = append(p.errors, parseErr)
+	}
+	p.extraTriples = nil
+	p.lastTermWasPropertyList = false
+	if end := strings.IndexByte(p.input[start:], '\n'); end != -1 {
+		p.pos = start + end + 1
+	} else {
+		p.pos = p.length
+	}
+}
+
 // skipWhitespaceAndComments skips whitespace and comments
 func (p *TurtleParser) skipWhitespaceAndComments() {
 	for p.pos < p.length {
@@ -1540,6 +1582,10 @@ func (p *TurtleParser) parseLiteral() (Term, error) {
 		if ch == quoteChar {
 			break
 		}
+		if ch == '\n' || ch == '\r' {
+			// Line breaks must be escaped in single-line strings
+			return nil, fmt.Errorf("unclosed string literal")
+		}
 		if ch == '\\' && p.pos+1 < p.length {
 			// Handle escape sequences
 			nextCh := p.input[p.pos+1]
@@ -0,0 +1,567 @@
+package rdf
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	rdfNamespace  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	rdfsNamespace = "http://www.w3.org/2000/01/rdf-schema#"
+	xsdNamespace  = "http://www.w3.org/2001/XMLSchema#"
+
+	rdfType  = rdfNamespace + "type"
+	rdfFirst = rdfNamespace + "first"
+	rdfRest  = rdfNamespace + "rest"
+	rdfNil   = rdfNamespace + "nil"
+)
+
+// Lexical forms written without quotes and datatype in Turtle
+var (
+	turtleInteger = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	turtleDecimal = regexp.MustCompile(`^[+-]?[0-9]*\.[0-9]+$`)
+	turtleDouble  = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)[eE][+-]?[0-9]+$`)
+)
+
+// TurtleSerializer writes triples as readable Turtle: triples are grouped by subject
+// and predicate, IRIs are abbreviated with the prefix map, blank nodes referenced once
+// are written in place as [ ... ] and well-formed rdf:List chains as ( ... ).
+// Only the prefixes used are declared.
+type TurtleSerializer struct {
+	prefixes map[string]string // namespace IRI by prefix name
+}
+
+// NewTurtleSerializer creates a Turtle serializer with the rdf, rdfs and xsd prefixes
+func NewTurtleSerializer() *TurtleSerializer {
+	return &TurtleSerializer{prefixes: map[string]string{
+		"rdf":  rdfNamespace,
+		"rdfs": rdfsNamespace,
+		"xsd":  xsdNamespace,
+	}}
+}
+
+// SetPrefix adds a prefix for abbreviating IRIs in the namespace, replacing one with the same name
+func (s *TurtleSerializer) SetPrefix(name, namespace string) {
+	s.prefixes[name] = namespace
+}
+
+func (s *TurtleSerializer) ContentType() string {
+	return "text/turtle"
+}
+
+func (s *TurtleSerializer) Serialize(writer io.Writer, quads []*Quad) error {
+	triples, err := defaultGraphTriples(quads, "Turtle")
+	if err != nil {
+		return err
+	}
+
+	doc := newTurtleDocument(s.prefixes, true)
+	w := doc.newWriter(0)
+	for _, triple := range triples {
+		w.add(triple)
+	}
+	var body strings.Builder
+	w.writeSubjects(&body)
+
+	var out strings.Builder
+	doc.writePrefixes(&out)
+	if out.Len() > 0 && body.Len() > 0 {
+		out.WriteString("\n")
+	}
+	out.WriteString(body.String())
+
+	if _, err := io.WriteString(writer, out.String()); err != nil {
+		return fmt.Errorf("error writing Turtle: %w", err)
+	}
+	return nil
+}
+
+// turtleSubject holds the predicates and objects of a subject, in order of appearance
+type turtleSubject struct {
+	term       Term
+	predicates []*NamedNode
+	objects    map[string][]Term // by predicate IRI
+}
+
+// turtleDocument holds the state shared by the graphs of a Turtle or TriG document:
+// the prefixes and the blank nodes, whose labels are scoped to the whole document
+type turtleDocument struct {
+	prefixes map[string]string
+	used     map[string]bool // prefixes written
+
+	// inlining allows blank nodes to be written in place, which needs every
+	// occurrence of them to be known; streamed documents label them all
+	inlining bool
+
+	refs      map[string]int    // object occurrences of each blank node
+	referrers map[string]string // key of a subject referencing each blank node
+	graphs    map[string]string // graph of each blank node
+	pinned    map[string]bool   // blank nodes in triple terms or several graphs, which need a label
+	labels    map[string]string // generated blank node labels, by term key
+}
+
+func newTurtleDocument(prefixes map[string]string, inlining bool) *turtleDocument {
+	return &turtleDocument{
+		prefixes:  prefixes,
+		used:      make(map[string]bool),
+		inlining:  inlining,
+		refs:      make(map[string]int),
+		referrers: make(map[string]string),
+		graphs:    make(map[string]string),
+		pinned:    make(map[string]bool),
+		labels:    make(map[string]string),
+	}
+}
+
+// writePrefixes writes a declaration of each prefix used, sorted by name
+func (d *turtleDocument) writePrefixes(sb *strings.Builder) {
+	names := make([]string, 0, len(d.used))
+	for name := range d.used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(sb, "@prefix %s: <%s> .\n", name, escapeTurtleIRI(d.prefixes[name]))
+	}
+}
+
+// turtleWriter lays out the triples of one graph of a document
+type turtleWriter struct {
+	*turtleDocument
+	graph  string // key of the graph, "" for the default graph
+	indent int    // depth of the subjects
+
+	subjects  []*turtleSubject          // in order of first appearance
+	bySubject map[string]*turtleSubject // by term key
+	seen      map[string]bool           // triples added
+	written   map[string]bool           // subjects and blank nodes written, in place or not
+}
+
+// newWriter creates the writer of a graph whose subjects are written at an indentation depth
+func (d *turtleDocument) newWriter(indent int) *turtleWriter {
+	return &turtleWriter{
+		turtleDocument: d,
+		indent:         indent,
+		bySubject:      make(map[string]*turtleSubject),
+		seen:           make(map[string]bool),
+		written:        make(map[string]bool),
+	}
+}
+
+// add adds a triple to the graph, ignoring duplicates
+func (w *turtleWriter) add(triple *Triple) {
+	predicate, ok := triple.Predicate.(*NamedNode)
+	if !ok {
+		return
+	}
+	key := serializeTermCanonical(triple.Subject) + " " + predicate.IRI + " " + serializeTermCanonical(triple.Object)
+	if w.seen[key] {
+		return
+	}
+	w.seen[key] = true
+
+	subject := w.subject(triple.Subject)
+	if _, ok := subject.objects[predicate.IRI]; !ok {
+		subject.predicates = append(subject.predicates, predicate)
+	}
+	subject.objects[predicate.IRI] = append(subject.objects[predicate.IRI], triple.Object)
+
+	if !w.inlining {
+		return
+	}
+	if _, ok := triple.Object.(*BlankNode); ok {
+		object := serializeTermCanonical(triple.Object)
+		w.refs[object]++
+		w.referrers[object] = serializeTermCanonical(triple.Subject)
+		w.inGraph(object)
+	}
+	if _, ok := triple.Subject.(*BlankNode); ok {
+		w.inGraph(serializeTermCanonical(triple.Subject))
+	}
+	w.pinTripleTerms(triple.Subject, false)
+	w.pinTripleTerms(triple.Object, false)
+}
+
+// inGraph records that a blank node occurs in the writer's graph, pinning it
+// when it occurs in another graph too
+func (w *turtleWriter) inGraph(key string) {
+	if graph, ok := w.graphs[key]; ok && graph != w.graph {
+		w.pinned[key] = true
+	}
+	w.graphs[key] = w.graph
+}
+
+// subject returns the entry of a subject, creating it on first use
+func (w *turtleWriter) subject(term Term) *turtleSubject {
+	key := serializeTermCanonical(term)
+	subject, ok := w.bySubject[key]
+	if !ok {
+		subject = &turtleSubject{term: term, objects: make(map[string][]Term)}
+		w.bySubject[key] = subject
+		w.subjects = append(w.subjects, subject)
+	}
+	return subject
+}
+
+// pinTripleTerms marks the blank nodes inside triple terms, which are always labelled
+func (d *turtleDocument) pinTripleTerms(term Term, inside bool) {
+	switch t := term.(type) {
+	case *BlankNode:
+		if inside {
+			d.pinned[serializeTermCanonical(t)] = true
+		}
+	case *TripleTerm:
+		d.pinTripleTerms(t.Subject, true)
+		d.pinTripleTerms(t.Object, true)
+	case *QuotedTriple:
+		d.pinTripleTerms(t.Subject, true)
+		d.pinTripleTerms(t.Object, true)
+	}
+}
+
+// inline reports whether a blank node can be written in place, where it is referenced
+func (w *turtleWriter) inline(term Term) bool {
+	if _, ok := term.(*BlankNode); !ok || !w.inlining {
+		return false
+	}
+	key := serializeTermCanonical(term)
+	return w.refs[key] == 1 && !w.pinned[key] && !w.written[key]
+}
+
+// writeSubjects writes a block per subject not written in place
+func (w *turtleWriter) writeSubjects(sb *strings.Builder) {
+	// rdf:type comes first, written as "a"
+	for _, subject := range w.subjects {
+		sort.SliceStable(subject.predicates, func(i, j int) bool {
+			return subject.predicates[i].IRI == rdfType && subject.predicates[j].IRI != rdfType
+		})
+	}
+	_ = w.eachSubject(func(subject *turtleSubject) error {
+		w.writeSubject(sb, subject)
+		return nil
+	})
+}
+
+// eachSubject calls fn for each subject to write at the top level, in order of first appearance,
+// leaving out the blank nodes written in place by the subjects before. The subject is marked
+// written before the call. It stops at the first error fn returns.
+func (w *turtleWriter) eachSubject(fn func(subject *turtleSubject) error) error {
+	var pending []*turtleSubject
+	for _, subject := range w.subjects {
+		key := serializeTermCanonical(subject.term)
+		if w.written[key] {
+			continue
+		}
+		if w.inline(subject.term) {
+			pending = append(pending, subject)
+			continue
+		}
+		w.written[key] = true
+		if err := fn(subject); err != nil {
+			return err
+		}
+	}
+
+	// Blank nodes referenced once from a cycle of such nodes are never reached
+	// from a subject above, so the cycle is entered with a label
+	for _, subject := range pending {
+		if w.written[serializeTermCanonical(subject.term)] {
+			continue
+		}
+		// Walk up to the cycle, so the nodes hanging off it are still written in place
+		visited := make(map[string]bool)
+		for {
+			key := serializeTermCanonical(subject.term)
+			visited[key] = true
+			referrer, ok := w.bySubject[w.referrers[key]]
+			if !ok || visited[w.referrers[key]] || !w.inline(referrer.term) {
+				break
+			}
+			subject = referrer
+		}
+		w.written[serializeTermCanonical(subject.term)] = true
+		if err := fn(subject); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// anonymous reports whether a subject can be written without a label: a blank node never referenced
+func (w *turtleWriter) anonymous(term Term) bool {
+	if _, ok := term.(*BlankNode); !ok || !w.inlining {
+		return false
+	}
+	key := serializeTermCanonical(term)
+	return w.refs[key] == 0 && !w.pinned[key]
+}
+
+// writeSubject writes a subject with its predicates and objects, ending with a period
+func (w *turtleWriter) writeSubject(sb *strings.Builder, subject *turtleSubject) {
+	if sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+	writeIndent(sb, w.indent)
+	if w.anonymous(subject.term) {
+		sb.WriteString("[]")
+	} else {
+		sb.WriteString(w.term(subject.term))
+	}
+	sb.WriteString(" ")
+	w.writePredicates(sb, subject, w.indent)
+	sb.WriteString(" .\n")
+}
+
+// writePredicates writes the predicate-object list of a subject at an indentation depth
+func (w *turtleWriter) writePredicates(sb *strings.Builder, subject *turtleSubject, depth int) {
+	for i, predicate := range subject.predicates {
+		if i > 0 {
+			sb.WriteString(" ;\n")
+			writeIndent(sb, depth+1)
+		}
+		if predicate.IRI == rdfType {
+			sb.WriteString("a")
+		} else {
+			sb.WriteString(w.iri(predicate.IRI))
+		}
+		sb.WriteString(" ")
+		for j, object := range subject.objects[predicate.IRI] {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			w.writeObject(sb, object, depth+1)
+		}
+	}
+}
+
+// writeObject writes an object, in place as [ ... ] or ( ... ) when it is a blank node referenced once
+func (w *turtleWriter) writeObject(sb *strings.Builder, object Term, depth int) {
+	if !w.inline(object) {
+		sb.WriteString(w.term(object))
+		return
+	}
+	key := serializeTermCanonical(object)
+	w.written[key] = true
+
+	if items, nodes, ok := w.listItems(object); ok {
+		w.markWritten(nodes)
+		sb.WriteString("(")
+		for _, item := range items {
+			sb.WriteString(" ")
+			w.writeObject(sb, item, depth)
+		}
+		sb.WriteString(" )")
+		return
+	}
+
+	subject, ok := w.bySubject[key]
+	if !ok {
+		sb.WriteString("[]")
+		return
+	}
+	if len(subject.predicates) == 1 {
+		// A single property fits on one line, unless its object is written in place too
+		if objects := subject.objects[subject.predicates[0].IRI]; len(objects) == 1 && !w.inline(objects[0]) {
+			sb.WriteString("[ ")
+			w.writePredicates(sb, subject, depth)
+			sb.WriteString(" ]")
+			return
+		}
+	}
+	sb.WriteString("[\n")
+	writeIndent(sb, depth+1)
+	w.writePredicates(sb, subject, depth)
+	sb.WriteString("\n")
+	writeIndent(sb, depth)
+	sb.WriteString("]")
+}
+
+// listItems returns the items of a well-formed rdf:List starting at a blank node:
+// every node of the chain is a blank node with exactly one rdf:first and one rdf:rest
+// and nothing else, referenced only by the node before it, ending with rdf:nil.
+// The keys of the nodes are returned too, to be marked written when the list is.
+func (w *turtleWriter) listItems(head Term) ([]Term, []string, bool) {
+	var items []Term
+	var nodes []string
+	node := head
+	for {
+		key := serializeTermCanonical(node)
+		subject, ok := w.bySubject[key]
+		if !ok || len(subject.predicates) != 2 {
+			return nil, nil, false
+		}
+		first, rest := subject.objects[rdfFirst], subject.objects[rdfRest]
+		if len(first) != 1 || len(rest) != 1 {
+			return nil, nil, false
+		}
+		items = append(items, first[0])
+		nodes = append(nodes, key)
+
+		if iri, ok := rest[0].(*NamedNode); ok && iri.IRI == rdfNil {
+			break
+		}
+		if !w.inline(rest[0]) {
+			return nil, nil, false
+		}
+		node = rest[0]
+	}
+	return items, nodes, true
+}
+
+// markWritten marks blank nodes written
+func (w *turtleWriter) markWritten(keys []string) {
+	for _, key := range keys {
+		w.written[key] = true
+	}
+}
+
+// term writes a term that is not written in place
+func (d *turtleDocument) term(term Term) string {
+	switch t := term.(type) {
+	case *NamedNode:
+		if t.IRI == rdfNil {
+			return "()"
+		}
+		return d.iri(t.IRI)
+	case *BlankNode:
+		return "_:" + d.label(t)
+	case *Literal:
+		return d.literal(t)
+	case *TripleTerm:
+		return fmt.Sprintf("<<( %s %s %s )>>", d.term(t.Subject), d.term(t.Predicate), d.term(t.Object))
+	case *QuotedTriple:
+		return fmt.Sprintf("<<( %s %s %s )>>", d.term(t.Subject), d.term(t.Predicate), d.term(t.Object))
+	default:
+		return serializeTermCanonical(term)
+	}
+}
+
+// iri writes an IRI as a prefixed name when a prefix covers it, <...> otherwise.
+// The longest namespace that leaves a valid local name is used.
+func (d *turtleDocument) iri(iri string) string {
+	best, bestNamespace := "", ""
+	for name, namespace := range d.prefixes {
+		if !strings.HasPrefix(iri, namespace) || !isTurtleLocalName(iri[len(namespace):]) {
+			continue
+		}
+		if len(namespace) > len(bestNamespace) || (len(namespace) == len(bestNamespace) && name < best) {
+			best, bestNamespace = name, namespace
+		}
+	}
+	if bestNamespace == "" {
+		return "<" + escapeTurtleIRI(iri) + ">"
+	}
+	d.used[best] = true
+	return best + ":" + iri[len(bestNamespace):]
+}
+
+// literal writes a literal, without quotes for numbers and booleans in their canonical syntax
+func (d *turtleDocument) literal(lit *Literal) string {
+	quoted := `"` + escapeStringCanonical(lit.Value) + `"`
+	if lit.Language != "" {
+		if lit.Direction != "" {
+			return quoted + "@" + lit.Language + "--" + lit.Direction
+		}
+		return quoted + "@" + lit.Language
+	}
+	if lit.Datatype == nil {
+		return quoted
+	}
+
+	switch lit.Datatype.IRI {
+	case xsdNamespace + "string":
+		return quoted
+	case xsdNamespace + "integer":
+		if turtleInteger.MatchString(lit.Value) {
+			return lit.Value
+		}
+	case xsdNamespace + "decimal":
+		if turtleDecimal.MatchString(lit.Value) {
+			return lit.Value
+		}
+	case xsdNamespace + "double":
+		if turtleDouble.MatchString(lit.Value) {
+			return lit.Value
+		}
+	case xsdNamespace + "boolean":
+		if lit.Value == "true" || lit.Value == "false" {
+			return lit.Value
+		}
+	}
+	return quoted + "^^" + d.iri(lit.Datatype.IRI)
+}
+
+// generatedLabel matches the labels given to blank nodes whose IDs can't be written
+var generatedLabel = regexp.MustCompile(`^b[0-9]+$`)
+
+// label returns the label of a blank node: its own ID when that is a valid Turtle label,
+// otherwise a generated one. IDs that look like generated labels are replaced too,
+// so the labels stay distinct without knowing every blank node in advance.
+func (d *turtleDocument) label(b *BlankNode) string {
+	return d.labelFor(b, isTurtleBlankNodeLabel)
+}
+
+// labelFor returns the label of a blank node in a syntax whose labels valid accepts
+func (d *turtleDocument) labelFor(b *BlankNode, valid func(string) bool) string {
+	if valid(b.ID) && !generatedLabel.MatchString(b.ID) {
+		return b.ID
+	}
+	key := serializeTermCanonical(b)
+	label, ok := d.labels[key]
+	if !ok {
+		label = fmt.Sprintf("b%d", len(d.labels))
+		d.labels[key] = label
+	}
+	return label
+}
+
+// isTurtleLocalName reports whether a string can be written as the local part of a prefixed name
+// (PN_LOCAL without escapes or percent encoding)
+func isTurtleLocalName(s string) bool {
+	for i, r := range s {
+		switch {
+		case isPN_CHARS_U(r), r >= '0' && r <= '9', r == ':':
+		case i > 0 && (isPN_CHARS(r) || r == '.'):
+		default:
+			return false
+		}
+	}
+	return !strings.HasSuffix(s, ".")
+}
+
+// isTurtleBlankNodeLabel reports whether a string is a valid blank node label after "_:"
+func isTurtleBlankNodeLabel(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case isPN_CHARS_U(r), r >= '0' && r <= '9':
+		case i > 0 && (isPN_CHARS(r) || r == '.'):
+		default:
+			return false
+		}
+	}
+	return !strings.HasSuffix(s, ".")
+}
+
+// escapeTurtleIRI escapes the characters an IRIREF can't contain
+func escapeTurtleIRI(iri string) string {
+	var builder strings.Builder
+	for _, r := range iri {
+		if r <= 0x20 || strings.ContainsRune("<>\"{}|^`\\", r) {
+			fmt.Fprintf(&builder, `\u%04X`, r)
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// writeIndent writes the indentation of a depth, four spaces per level
+func writeIndent(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("    ", depth))
+}
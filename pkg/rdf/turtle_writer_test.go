@@ -0,0 +1,165 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+// serializeTurtle parses Turtle input and writes it back with the given prefixes
+func serializeTurtle(t *testing.T, input string, prefixes map[string]string) (string, []*Triple) {
+	t.Helper()
+	triples, err := NewTurtleParser(input).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	quads := make([]*Quad, len(triples))
+	for i, triple := range triples {
+		quads[i] = NewQuad(triple.Subject, triple.Predicate, triple.Object, NewDefaultGraph())
+	}
+
+	serializer := NewTurtleSerializer()
+	for name, namespace := range prefixes {
+		serializer.SetPrefix(name, namespace)
+	}
+	var out strings.Builder
+	if err := serializer.Serialize(&out, quads); err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	return out.String(), triples
+}
+
+func TestTurtleSerializer_GroupsAndAbbreviates(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+ex:alice ex:name "Alice" .
+ex:alice a ex:Person .
+ex:alice ex:knows ex:bob .
+ex:alice ex:knows ex:carol .
+ex:alice ex:age 30 .`
+
+	output, _ := serializeTurtle(t, input, map[string]string{"ex": "http://example.org/"})
+	expected := `@prefix ex: <http://example.org/> .
+
+ex:alice a ex:Person ;
+    ex:name "Alice" ;
+    ex:knows ex:bob, ex:carol ;
+    ex:age 30 .
+`
+	if output != expected {
+		t.Errorf("Unexpected output:\n%s\nExpected:\n%s", output, expected)
+	}
+}
+
+func TestTurtleSerializer_BlankNodesAndLists(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+ex:s ex:p [ ex:q "x" ; ex:r [ ex:t "y" ] ] .
+ex:s ex:list ( 1 ex:two ( "three" ) ) .
+ex:s ex:empty () .
+_:shared ex:v 1 .
+ex:a ex:ref _:shared .
+ex:b ex:ref _:shared .`
+
+	output, triples := serializeTurtle(t, input, map[string]string{"ex": "http://example.org/"})
+
+	for _, want := range []string{
+		`ex:p [
+        ex:q "x" ;
+        ex:r [ ex:t "y" ]
+    ]`,
+		`ex:list ( 1 ex:two ( "three" ) )`,
+		`ex:empty ()`,
+		`ex:a ex:ref _:shared .`,
+		`ex:b ex:ref _:shared .`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "rdf:first") {
+		t.Errorf("Lists should be written as collections, got:\n%s", output)
+	}
+
+	reparsed, err := NewTurtleParser(output).Parse()
+	if err != nil {
+		t.Fatalf("Output does not parse: %v\n%s", err, output)
+	}
+	if !AreGraphsIsomorphic(triples, reparsed) {
+		t.Errorf("Round trip changed the graph:\n%s", output)
+	}
+}
+
+func TestTurtleSerializer_RoundTrip(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+ex:s ex:string "line\nbreak \"quoted\"" ;
+    ex:lang "bonjour"@fr ;
+    ex:decimal 1.5 ;
+    ex:double 1.0e3 ;
+    ex:boolean false ;
+    ex:date "2024-01-01"^^xsd:date ;
+    ex:odd "01"^^xsd:integer ;
+    <http://example.org/a/b> ex:o .
+[] ex:p ex:o .
+_:c1 ex:next _:c2 .
+_:c2 ex:next _:c1 .
+_:l ex:p ( ex:a ) .
+_:l ex:q _:l .
+ex:t ex:triple <<( ex:s ex:p _:inner )>> .
+_:inner ex:p "in" .`
+
+	output, triples := serializeTurtle(t, input, map[string]string{"ex": "http://example.org/"})
+	reparsed, err := NewTurtleParser(output).Parse()
+	if err != nil {
+		t.Fatalf("Output does not parse: %v\n%s", err, output)
+	}
+	if len(reparsed) != len(triples) {
+		t.Fatalf("Expected %d triples, got %d:\n%s", len(triples), len(reparsed), output)
+	}
+	if !AreGraphsIsomorphic(triples, reparsed) {
+		t.Errorf("Round trip changed the graph:\n%s", output)
+	}
+	if !strings.Contains(output, "<http://example.org/a/b>") {
+		t.Errorf("IRIs without a valid local name should not be abbreviated:\n%s", output)
+	}
+}
+
+func TestTurtleSerializer_NamedGraph(t *testing.T) {
+	quads := []*Quad{NewQuad(
+		NewNamedNode("http://example.org/s"),
+		NewNamedNode("http://example.org/p"),
+		NewNamedNode("http://example.org/o"),
+		NewNamedNode("http://example.org/g"),
+	)}
+	var out strings.Builder
+	if err := NewTurtleSerializer().Serialize(&out, quads); err == nil {
+		t.Error("Expected an error for a quad in a named graph")
+	}
+}
+
+func TestNewSerializer(t *testing.T) {
+	tests := []struct {
+		contentType string
+		expected    string
+	}{
+		{"text/turtle", "text/turtle"},
+		{"text/turtle; charset=utf-8", "text/turtle"},
+		{"application/n-triples", "application/n-triples"},
+		{"application/n-quads", "application/n-quads"},
+		{"application/trig", "application/trig"},
+		{"application/x-trig", "application/trig"},
+		{"application/rdf+xml", "application/rdf+xml"},
+	}
+	for _, tt := range tests {
+		serializer, err := NewSerializer(tt.contentType)
+		if err != nil {
+			t.Errorf("NewSerializer(%q) failed: %v", tt.contentType, err)
+			continue
+		}
+		if serializer.ContentType() != tt.expected {
+			t.Errorf("NewSerializer(%q): expected %s, got %s", tt.contentType, tt.expected, serializer.ContentType())
+		}
+	}
+
+	if _, err := NewSerializer("application/x-unknown"); err == nil {
+		t.Error("Expected an error for an unsupported content type")
+	}
+}
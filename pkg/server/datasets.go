@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// datasetInfo describes a dataset in admin responses
+type datasetInfo struct {
+	Name    string `json:"name"`
+	Triples int64  `json:"triples"`
+	SPARQL  string `json:"sparql"`
+	Data    string `json:"data"`
+}
+
+// handleListDatasets lists all named datasets
+func (s *Server) handleListDatasets(w http.ResponseWriter, r *http.Request) {
+	datasets := []datasetInfo{}
+	for _, name := range s.datasets.Names() {
+		// Skip datasets deleted since listing the names
+		ts, release, err := s.datasets.Acquire(name)
+		if err != nil {
+			continue
+		}
+		datasets = append(datasets, newDatasetInfo(name, s.authorizedStore(r, ts)))
+		release()
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{"datasets": datasets})
+}
+
+// handleGetDataset describes a single dataset
+func (s *Server) handleGetDataset(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	ts, release, err := s.datasets.Acquire(name)
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	defer release()
+
+	s.writeJSON(w, http.StatusOK, newDatasetInfo(name, s.authorizedStore(r, ts)))
+}
+
+// handleCreateDataset creates a new empty dataset
+// The name is taken from a JSON body {"name": "..."} or the "name" parameter
+func (s *Server) handleCreateDataset(w http.ResponseWriter, r *http.Request) {
+	if !s.canAdministerDatasets(r) {
+		s.writeError(w, http.StatusForbidden, "Creating datasets requires write access to all graphs")
+		return
+	}
+
+	var name string
+	if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		var request struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+		name = request.Name
+	} else {
+		if err := r.ParseForm(); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Failed to parse form")
+			return
+		}
+		name = r.FormValue("name")
+	}
+
+	if name == "" {
+		s.writeError(w, http.StatusBadRequest, "Missing dataset name")
+		return
+	}
+
+	ts, err := s.datasets.Create(name)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidDatasetName):
+			s.writeError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, store.ErrDatasetExists):
+			s.writeError(w, http.StatusConflict, err.Error())
+		default:
+			s.writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	s.writeJSON(w, http.StatusCreated, newDatasetInfo(name, s.authorizedStore(r, ts)))
+}
+
+// handleDeleteDataset closes a dataset and removes its data, once the requests using it are done
+func (s *Server) handleDeleteDataset(w http.ResponseWriter, r *http.Request) {
+	if !s.canAdministerDatasets(r) {
+		s.writeError(w, http.StatusForbidden, "Deleting datasets requires write access to all graphs")
+		return
+	}
+	name := r.PathValue("name")
+
+	if err := s.datasets.Delete(name); err != nil {
+		if errors.Is(err, store.ErrDatasetNotFound) {
+			s.writeError(w, http.StatusNotFound, err.Error())
+		} else {
+			s.writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	s.forgetEngine(name)
+
+	s.writeJSON(w, http.StatusOK, map[string]any{"success": true, "deleted": name})
+}
+
+// canAdministerDatasets reports whether the request's principal may create and delete datasets.
+// With an access policy that takes write access to all graphs, as a dataset holds graphs of
+// principals who can write only their own.
+func (s *Server) canAdministerDatasets(r *http.Request) bool {
+	return s.policy == nil || s.policy.CanWriteAll(principalName(r))
+}
+
+// newDatasetInfo describes a dataset, counting the triples of the caller's view of its store
+func newDatasetInfo(name string, ts *store.TripleStore) datasetInfo {
+	count, _ := ts.Count()
+	return datasetInfo{
+		Name:    name,
+		Triples: count,
+		SPARQL:  "/datasets/" + name + "/sparql",
+		Data:    "/datasets/" + name + "/data",
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aleksaelezovic/trigo/pkg/auth"
+)
+
+func TestDatasetAdministrationRequiresWriteAccessToAllGraphs(t *testing.T) {
+	handler := withTestPolicy(t, newTestServer(t, testData)).Handler()
+	create := func(key, name string) int {
+		return request(t, handler, http.MethodPost, "/datasets", `{"name": "`+name+`"}`,
+			"Content-Type", "application/json", auth.APIKeyHeader, key).Code
+	}
+	remove := func(key, name string) int {
+		return request(t, handler, http.MethodDelete, "/datasets/"+name, "", auth.APIKeyHeader, key).Code
+	}
+
+	// Alice can write a graph of every dataset, but may not create or delete datasets holding others' graphs
+	if code := create("alice-key", "shared"); code != http.StatusForbidden {
+		t.Errorf("expected a restricted writer to be forbidden to create a dataset, got %d", code)
+	}
+	if code := create("admin-key", "shared"); code != http.StatusCreated {
+		t.Fatalf("expected the dataset to be created, got %d", code)
+	}
+	if code := remove("alice-key", "shared"); code != http.StatusForbidden {
+		t.Errorf("expected a restricted writer to be forbidden to delete a dataset, got %d", code)
+	}
+	if code := remove("reader-key", "shared"); code != http.StatusForbidden {
+		t.Errorf("expected a reader to be forbidden to delete a dataset, got %d", code)
+	}
+	if code := remove("admin-key", "shared"); code != http.StatusOK {
+		t.Errorf("expected the dataset to be deleted, got %d", code)
+	}
+}
+
+func TestDatasetRoutes(t *testing.T) {
+	handler := newTestServer(t, testData).Handler()
+	create := func(name string) *httptest.ResponseRecorder {
+		return request(t, handler, http.MethodPost, "/datasets", `{"name": "`+name+`"}`, "Content-Type", "application/json")
+	}
+
+	assertStatus(t, create("books"), http.StatusCreated)
+	assertStatus(t, create("books"), http.StatusConflict)
+	assertStatus(t, create("../books"), http.StatusBadRequest)
+	assertStatus(t, request(t, handler, http.MethodPost, "/datasets", `{}`, "Content-Type", "application/json"), http.StatusBadRequest)
+
+	// Each dataset only holds its own data
+	w := request(t, handler, http.MethodPost, "/datasets/books/data", `<http://example.org/b> <http://example.org/title> "T" .`,
+		"Content-Type", "text/turtle")
+	assertStatus(t, w, http.StatusOK)
+	w = request(t, handler, http.MethodGet, "/datasets/books", "")
+	assertStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"triples":1`) {
+		t.Errorf("expected the dataset to hold 1 triple, got %s", w.Body.String())
+	}
+	w = request(t, handler, http.MethodGet, "/datasets/books/sparql?query=ASK+%7B+%3Fs+%3Chttp%3A%2F%2Fexample.org%2Fname%3E+%3Fo+%7D", "",
+		"Accept", "application/sparql-results+json")
+	assertStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"boolean": false`) {
+		t.Errorf("expected the default store's data not to be visible, got %s", w.Body.String())
+	}
+
+	assertStatus(t, request(t, handler, http.MethodDelete, "/datasets/books", ""), http.StatusOK)
+	assertStatus(t, request(t, handler, http.MethodDelete, "/datasets/books", ""), http.StatusNotFound)
+	assertStatus(t, request(t, handler, http.MethodGet, "/datasets/books", ""), http.StatusNotFound)
+	assertStatus(t, request(t, handler, http.MethodGet, "/datasets/books/sparql?query=ASK+%7B%7D", ""), http.StatusNotFound)
+	assertStatus(t, request(t, handler, http.MethodGet, "/datasets/missing/sparql?query=ASK+%7B%7D", ""), http.StatusNotFound)
+
+	// The name can be used again once the dataset is gone
+	assertStatus(t, create("books"), http.StatusCreated)
+}
+
+// blockingWriter is a response writer whose first write waits until it is released
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(data []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.writing)
+		<-w.release
+	})
+	return w.ResponseRecorder.Write(data)
+}
+
+func TestDeleteDatasetWaitsForRequests(t *testing.T) {
+	handler := newTestServer(t, testData).Handler()
+	assertStatus(t, request(t, handler, http.MethodPost, "/datasets?name=books", ""), http.StatusCreated)
+	w := request(t, handler, http.MethodPost, "/datasets/books/data", `<http://example.org/b> <http://example.org/title> "T" .`,
+		"Content-Type", "text/turtle")
+	assertStatus(t, w, http.StatusOK)
+
+	// A query is stuck writing its results
+	query := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), writing: make(chan struct{}), release: make(chan struct{})}
+	queried := make(chan struct{})
+	go func() {
+		defer close(queried)
+		handler.ServeHTTP(query, httptest.NewRequest(http.MethodGet, "/datasets/books/sparql?query=SELECT+*+WHERE+%7B+%3Fs+%3Fp+%3Fo+%7D", nil))
+	}()
+	<-query.writing
+
+	deleted := make(chan *httptest.ResponseRecorder)
+	go func() {
+		deleted <- request(t, handler, http.MethodDelete, "/datasets/books", "")
+	}()
+
+	// The dataset is gone for new requests straight away, but isn't closed under the query
+	for request(t, handler, http.MethodGet, "/datasets/books", "").Code != http.StatusNotFound {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-deleted:
+		t.Fatal("expected the delete to wait for the query")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(query.release)
+	<-queried
+	assertStatus(t, <-deleted, http.StatusOK)
+	assertStatus(t, query.ResponseRecorder, http.StatusOK)
+	if !strings.Contains(query.Body.String(), "http://example.org/title") {
+		t.Errorf("expected the query to finish with its results, got %s", query.Body.String())
+	}
+}
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,7 +11,10 @@ import (
 	"time"
 
 	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/executor"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
 	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+	"github.com/aleksaelezovic/trigo/pkg/store"
 )
 
 // handleRoot provides information about the endpoint
@@ -28,6 +33,13 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
 	}
 	endpointURL := fmt.Sprintf("%s://%s/sparql", scheme, r.Host)
 
+	// Only count triples the caller may read
+	var totalTriples int64
+	if eng, release, err := s.engineFor(r); err == nil {
+		totalTriples, _ = eng.store.Count()
+		release()
+	}
+
 	html := `<!DOCTYPE html>
 <html>
 <head>
@@ -78,7 +90,7 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
         <h1>🎯 Trigo SPARQL Endpoint</h1>
         <div class="info">
             Endpoint: <code>` + endpointURL + `</code> |
-            Total triples: <strong>` + fmt.Sprintf("%d", s.Stats().TotalTriples) + `</strong>
+            Total triples: <strong>` + fmt.Sprintf("%d", totalTriples) + `</strong>
         </div>
     </div>
     <div id="yasgui"></div>
@@ -110,16 +122,6 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
 // handleSPARQL handles SPARQL query requests according to SPARQL 1.1 Protocol
 // https://www.w3.org/TR/sparql11-protocol/
 func (s *Server) handleSPARQL(w http.ResponseWriter, r *http.Request) {
-	// Enable CORS
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept")
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	// Extract query string
 	var queryString string
 	var err error
@@ -178,25 +180,61 @@ func (s *Server) handleSPARQL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	eng, release, err := s.engineFor(r)
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	defer release()
+
 	// Parse query
 	p := parser.NewParser(queryString)
 	query, err := p.Parse()
 	if err != nil {
-		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Parse error: %v", err))
+		s.writeParseError(w, "Parse error", err)
 		return
 	}
 
-	// Optimize query
-	optimizedQuery, err := s.optimizer.Optimize(query)
+	// The request context is cancelled when the client disconnects
+	timeout, err := s.requestTimeout(r)
 	if err != nil {
-		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Optimization error: %v", err))
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'timeout' parameter: %v", err))
 		return
 	}
+	ctx := r.Context()
 
-	// Execute query
-	result, err := s.executor.Execute(optimizedQuery)
+	parallelism, err := requestParallelism(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'parallelism' parameter: %v", err))
+		return
+	}
+	if parallelism > 0 {
+		ctx = executor.WithParallelism(ctx, parallelism)
+	}
+
+	// Streamed SELECT results apply the timeout themselves, see writeSelect
+	streamCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	explain, err := requestExplainMode(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'explain' parameter: %v", err))
+		return
+	}
+
+	// Optimize query
+	optimizedQuery, err := eng.optimizer.Optimize(ctx, query)
 	if err != nil {
-		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Execution error: %v", err))
+		s.writeQueryError(w, "Optimization error", err, timeout)
+		return
+	}
+
+	if explain != explainNone {
+		s.writeExplain(ctx, w, eng, optimizedQuery, explain, timeout)
 		return
 	}
 
@@ -204,27 +242,75 @@ func (s *Server) handleSPARQL(w http.ResponseWriter, r *http.Request) {
 	acceptHeader := r.Header.Get("Accept")
 	format := s.negotiateFormat(acceptHeader)
 
+	// SELECT results are streamed to the client as they are produced
+	if query.QueryType == parser.QueryTypeSelect {
+		s.writeSelect(streamCtx, w, eng, optimizedQuery, format, timeout)
+		return
+	}
+
+	// Execute query
+	result, err := eng.executor.Execute(ctx, optimizedQuery)
+	if err != nil {
+		s.writeQueryError(w, "Execution error", err, timeout)
+		return
+	}
+
+	// CONSTRUCT and DESCRIBE return RDF rather than SPARQL results
+	if constructResult, ok := result.(*executor.ConstructResult); ok {
+		s.writeGraph(w, r, constructResult)
+		return
+	}
+
 	// Format and send response
 	s.writeResult(w, result, format)
 }
 
-// handleDataUpload handles bulk data uploads in various RDF formats
-func (s *Server) handleDataUpload(w http.ResponseWriter, r *http.Request) {
-	// Enable CORS
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept")
+// writeExplain describes the plan of a query instead of returning its results.
+// In analyze mode the query is executed and each operator is annotated with its runtime statistics.
+func (s *Server) writeExplain(ctx context.Context, w http.ResponseWriter, eng *engine, query *optimizer.OptimizedQuery, mode explainMode, timeout time.Duration) {
+	plan := eng.optimizer.Explain(query.Plan)
+	// Estimates come from statistics over all graphs, hidden from callers who can't read them all
+	if readsAll, err := eng.store.CanReadAll(); err != nil || !readsAll {
+		plan.HideEstimates()
+	}
+	response := map[string]any{
+		"analyze": mode == explainAnalyze,
+		"plan":    plan,
+	}
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
+	if mode == explainAnalyze {
+		result, profile, err := eng.executor.Analyze(ctx, query)
+		if err != nil {
+			s.writeQueryError(w, "Execution error", err, timeout)
+			return
+		}
+		profile.Annotate(plan)
+		response["executionTimeMs"] = float64(profile.Elapsed.Microseconds()) / 1000
+		response["termLookups"] = profile.TermLookups
+		response["resultRows"] = resultRows(result)
 	}
 
+	response["text"] = plan.String()
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// maxReportedParseErrors is the number of skipped statements a lenient upload lists in its response
+const maxReportedParseErrors = 100
+
+// handleDataUpload handles bulk data uploads in various RDF formats
+func (s *Server) handleDataUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		s.writeError(w, http.StatusMethodNotAllowed, "Method not allowed. Use POST")
 		return
 	}
 
+	eng, release, err := s.engineFor(r)
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	defer release()
+
 	// Get Content-Type header
 	contentType := r.Header.Get("Content-Type")
 	if contentType == "" {
@@ -241,33 +327,161 @@ func (s *Server) handleDataUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse RDF data from request body
-	startTime := time.Now()
-	quads, err := parser.Parse(r.Body)
-	if err != nil {
-		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Parse error: %v", err))
-		return
+	// With lenient=true, line-based formats skip bad statements instead of failing
+	lenientParser, lenient := parser.(rdf.LenientParser)
+	if lenient {
+		lenient = r.URL.Query().Get("lenient") == "true"
+		lenientParser.SetLenient(lenient)
 	}
 
-	// Bulk insert quads
-	if err := s.store.InsertQuadsBatch(quads); err != nil {
-		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Insert error: %v", err))
+	// Parse RDF data from request body, streaming it into the store in batches when the
+	// format allows so the upload never has to fit in memory
+	startTime := time.Now()
+	var inserted int
+	if streamParser, ok := parser.(rdf.StreamParser); ok {
+		inserted, err = eng.store.LoadQuads(r.Body, streamParser)
+	} else {
+		var quads []*rdf.Quad
+		quads, err = parser.Parse(r.Body)
+		if err != nil {
+			err = fmt.Errorf("%w: %w", store.ErrInvalidData, err)
+		} else if err = eng.store.InsertQuadsBatch(quads); err == nil {
+			inserted = len(quads)
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrAccessDenied):
+			s.writeError(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, store.ErrInvalidData):
+			s.writeParseError(w, fmt.Sprintf("Parse error after inserting %d quads", inserted), err)
+		default:
+			s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Insert error after inserting %d quads: %v", inserted, err))
+		}
 		return
 	}
 
 	duration := time.Since(startTime)
 
 	// Return success response with statistics
+	statistics := map[string]any{
+		"quadsInserted":  inserted,
+		"durationMs":     duration.Milliseconds(),
+		"quadsPerSecond": float64(inserted) / duration.Seconds(),
+	}
 	response := map[string]any{
-		"success": true,
-		"statistics": map[string]any{
-			"quadsInserted":  len(quads),
-			"durationMs":     duration.Milliseconds(),
-			"quadsPerSecond": float64(len(quads)) / duration.Seconds(),
-		},
+		"success":    true,
+		"statistics": statistics,
+	}
+	if lenient {
+		skipped := lenientParser.Errors()
+		statistics["statementsSkipped"] = len(skipped)
+		reported := make([]string, 0, min(len(skipped), maxReportedParseErrors))
+		for _, parseErr := range skipped[:min(len(skipped), maxReportedParseErrors)] {
+			reported = append(reported, parseErr.Error())
+		}
+		response["errors"] = reported
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(response) // #nosec G104 - error writing response is logged elsewhere if needed
 }
+
+// handlePatch applies an RDF Patch to a dataset, making all of its changes or none
+func (s *Server) handlePatch(w http.ResponseWriter, r *http.Request) {
+	eng, release, err := s.engineFor(r)
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	defer release()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	patch, err := rdf.NewPatchParser(string(body)).Parse()
+	if err != nil {
+		s.writeParseError(w, "Invalid patch", err)
+		return
+	}
+
+	startTime := time.Now()
+	if err := eng.store.ApplyPatch(patch); err != nil {
+		switch {
+		case errors.Is(err, store.ErrAccessDenied):
+			s.writeError(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, store.ErrInvalidData):
+			s.writeParseError(w, "Invalid patch", err)
+		case errors.Is(err, store.ErrPatchTooLarge):
+			s.writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		default:
+			s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Patch error: %v", err))
+		}
+		return
+	}
+
+	// The patch was applied, so its transactions are well formed
+	changes, _ := patch.Changes()
+	var added, deleted int
+	for _, row := range changes {
+		switch row.Operation {
+		case rdf.PatchAdd:
+			added++
+		case rdf.PatchDelete:
+			deleted++
+		}
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"statistics": map[string]any{
+			"quadsAdded":   added,
+			"quadsDeleted": deleted,
+			"durationMs":   time.Since(startTime).Milliseconds(),
+		},
+	})
+}
+
+// handleAnalyze collects and persists statistics of a dataset and hands them to its optimizer
+// The statistics cover all graphs, so only callers who can read them all may collect them.
+func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	eng, release, err := s.datasetEngine(r.PathValue("name"))
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	defer release()
+
+	readsAll, err := s.authorizedStore(r, eng.store).CanReadAll()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Analyze error: %v", err))
+		return
+	}
+	if !readsAll {
+		s.writeError(w, http.StatusForbidden, "Analyze requires read access to all graphs")
+		return
+	}
+
+	startTime := time.Now()
+	stats, err := eng.store.Analyze(r.Context())
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Analyze error: %v", err))
+		return
+	}
+	eng.optimizer.SetStatistics(&optimizer.Statistics{TotalTriples: stats.Quads, Dataset: stats})
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"statistics": map[string]any{
+			"quads":              stats.Quads,
+			"subjects":           stats.Subjects,
+			"objects":            stats.Objects,
+			"predicates":         len(stats.Predicates),
+			"graphs":             len(stats.Graphs),
+			"characteristicSets": len(stats.CharacteristicSets),
+			"durationMs":         time.Since(startTime).Milliseconds(),
+		},
+	})
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/auth"
+)
+
+func TestAnalyzeRequiresReadAccessToAllGraphs(t *testing.T) {
+	s := withTestPolicy(t, newTestServer(t, testData))
+	handler := s.Handler()
+	before := s.optimizer.Statistics()
+
+	// Alice can't read Bob's graph, so she may neither see nor replace statistics covering it
+	w := request(t, handler, http.MethodPost, "/analyze", "", auth.APIKeyHeader, "alice-key")
+	assertStatus(t, w, http.StatusForbidden)
+	if s.optimizer.Statistics() != before {
+		t.Error("expected a forbidden analyze to leave the statistics alone")
+	}
+
+	w = request(t, handler, http.MethodPost, "/analyze", "", auth.APIKeyHeader, "admin-key")
+	assertStatus(t, w, http.StatusOK)
+	if stats := s.optimizer.Statistics(); stats == before || stats.TotalTriples != 3 {
+		t.Errorf("expected statistics of 3 triples, got %+v", stats)
+	}
+}
+
+func TestExplainParameter(t *testing.T) {
+	handler := newTestServer(t, testData).Handler()
+	query := "/sparql?query=" + url.QueryEscape("SELECT * WHERE { ?s ?p ?o }")
+
+	for _, tt := range []struct {
+		explain string
+		status  int
+		analyze bool
+	}{
+		{"true", http.StatusOK, false},
+		{"plan", http.StatusOK, false},
+		{"ANALYZE", http.StatusOK, true},
+		{"verbose", http.StatusBadRequest, false},
+		{"1", http.StatusBadRequest, false},
+	} {
+		w := request(t, handler, http.MethodGet, query+"&explain="+tt.explain, "")
+		if w.Code != tt.status {
+			t.Errorf("explain=%s: expected status %d, got %d: %s", tt.explain, tt.status, w.Code, w.Body.String())
+			continue
+		}
+		if tt.status != http.StatusOK {
+			if !strings.Contains(w.Body.String(), "Invalid 'explain' parameter") {
+				t.Errorf("explain=%s: expected the parameter to be named in %s", tt.explain, w.Body.String())
+			}
+			continue
+		}
+
+		var response struct {
+			Analyze    bool   `json:"analyze"`
+			Text       string `json:"text"`
+			ResultRows *int   `json:"resultRows"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("explain=%s: failed to decode response: %v", tt.explain, err)
+		}
+		if response.Analyze != tt.analyze || response.Text == "" {
+			t.Errorf("explain=%s: unexpected response %s", tt.explain, w.Body.String())
+		}
+		// Only an analysis runs the query, over the one triple of the default graph
+		if tt.analyze != (response.ResultRows != nil) || (tt.analyze && *response.ResultRows != 1) {
+			t.Errorf("explain=%s: unexpected result rows in %s", tt.explain, w.Body.String())
+		}
+	}
+}
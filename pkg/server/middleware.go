@@ -0,0 +1,108 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/aleksaelezovic/trigo/pkg/auth"
+)
+
+// WithAuth requires callers to authenticate; anonymous callers may only query if allowAnonymousRead is set
+func (s *Server) WithAuth(authenticator auth.Authenticator, allowAnonymousRead bool) *Server {
+	s.authenticator = authenticator
+	s.allowAnonymousRead = allowAnonymousRead
+	return s
+}
+
+// WithCORSOrigins restricts the origins allowed to call the endpoint from browsers ("*" allows any)
+func (s *Server) WithCORSOrigins(origins []string) *Server {
+	s.corsOrigins = origins
+	return s
+}
+
+// cors sets CORS headers for allowed origins and answers preflight requests
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		switch {
+		case len(s.corsOrigins) == 0 || slices.Contains(s.corsOrigins, "*"):
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && slices.Contains(s.corsOrigins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept, Authorization, "+auth.APIKeyHeader)
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate identifies the caller and stores the principal in the request context
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.authenticator == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := s.authenticator.Authenticate(r)
+		if errors.Is(err, auth.ErrNoCredentials) {
+			// Anonymous; requireRole decides what it may do
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			s.writeUnauthorized(w, "Authentication failed")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// requireRole only lets callers with at least the given role through
+func (s *Server) requireRole(role auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.authenticator == nil {
+			next(w, r)
+			return
+		}
+
+		principal := auth.PrincipalFromContext(r.Context())
+		if principal == nil {
+			if role == auth.RoleReadOnly && s.allowAnonymousRead {
+				next(w, r)
+				return
+			}
+			s.writeUnauthorized(w, "Authentication required")
+			return
+		}
+
+		if !principal.Role.Allows(role) {
+			s.writeError(w, http.StatusForbidden, "Insufficient role: "+role.String()+" required")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// principalName returns the name of the request's principal, "" for anonymous callers
+func principalName(r *http.Request) string {
+	if principal := auth.PrincipalFromContext(r.Context()); principal != nil {
+		return principal.Name
+	}
+	return ""
+}
+
+// writeUnauthorized writes a 401 response with a challenge for the configured schemes
+func (s *Server) writeUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="Trigo", charset="UTF-8"`)
+	s.writeError(w, http.StatusUnauthorized, message)
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/aleksaelezovic/trigo/pkg/auth"
+)
+
+// signJWT signs claims as an RS256 token with an RSA key, or an HS256 token with a secret
+func signJWT(t *testing.T, key any, claims map[string]any) string {
+	t.Helper()
+	alg := "HS256"
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		alg = "RS256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authenticatedServer returns a server accepting the API keys "read-key" and "write-key",
+// HTTP Basic for "basic-user" with password "secret", and RS256 JWTs signed by the returned key
+func authenticatedServer(t *testing.T, allowAnonymousRead bool) (*Server, *rsa.PrivateKey) {
+	t.Helper()
+	keys, err := auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Key: "read-key", Name: "reader", Role: "read"},
+		{Key: "write-key", Name: "writer", Role: "write"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htpasswd := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(htpasswd, []byte("basic-user:"+string(hash)+":write\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	basic, err := auth.LoadBasicCredentials(htpasswd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwt, err := auth.NewJWTVerifier(&rsaKey.PublicKey, auth.JWTOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t, testData).WithAuth(auth.Chain{keys, basic, jwt}, allowAnonymousRead)
+	return s, rsaKey
+}
+
+func TestRequireRole(t *testing.T) {
+	s, _ := authenticatedServer(t, false)
+	handler := s.Handler()
+
+	query := "/sparql?query=" + "SELECT+*+WHERE+%7B+%3Fs+%3Fp+%3Fo+%7D"
+	upload := []string{"Content-Type", "text/turtle"}
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		headers  []string
+		expected map[string]int // status by API key, "" for anonymous
+	}{
+		{"query", http.MethodGet, query, "", nil,
+			map[string]int{"": http.StatusUnauthorized, "read-key": http.StatusOK, "write-key": http.StatusOK}},
+		{"upload", http.MethodPost, "/data", `<http://example.org/s> <http://example.org/p> "o" .`, upload,
+			map[string]int{"": http.StatusUnauthorized, "read-key": http.StatusForbidden, "write-key": http.StatusOK}},
+		{"patch", http.MethodPost, "/patch", `A <http://example.org/s> <http://example.org/p> "p" .`, nil,
+			map[string]int{"": http.StatusUnauthorized, "read-key": http.StatusForbidden, "write-key": http.StatusOK}},
+		{"list datasets", http.MethodGet, "/datasets", "", nil,
+			map[string]int{"": http.StatusUnauthorized, "read-key": http.StatusOK, "write-key": http.StatusOK}},
+		{"create dataset", http.MethodPost, "/datasets?name=created", "", nil,
+			map[string]int{"": http.StatusUnauthorized, "read-key": http.StatusForbidden, "write-key": http.StatusCreated}},
+	}
+	for _, tt := range tests {
+		for _, key := range []string{"", "read-key", "write-key"} {
+			headers := tt.headers
+			if key != "" {
+				headers = append(headers[:len(headers):len(headers)], auth.APIKeyHeader, key)
+			}
+			w := request(t, handler, tt.method, tt.target, tt.body, headers...)
+			if w.Code != tt.expected[key] {
+				t.Errorf("%s with key %q: expected status %d, got %d: %s", tt.name, key, tt.expected[key], w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("%s with key %q: expected a challenge with the 401", tt.name, key)
+			}
+		}
+	}
+
+	// Anonymous callers may query, but nothing more, when anonymous reads are allowed
+	s, _ = authenticatedServer(t, true)
+	handler = s.Handler()
+	assertStatus(t, request(t, handler, http.MethodGet, query, ""), http.StatusOK)
+	assertStatus(t, request(t, handler, http.MethodGet, "/datasets", ""), http.StatusOK)
+	assertStatus(t, request(t, handler, http.MethodPost, "/data", tests[1].body, upload...), http.StatusUnauthorized)
+	assertStatus(t, request(t, handler, http.MethodPost, "/datasets?name=anonymous", ""), http.StatusUnauthorized)
+}
+
+func TestAuthenticationFailures(t *testing.T) {
+	s, rsaKey := authenticatedServer(t, true)
+	handler := s.Handler()
+	query := "/sparql?query=" + "ASK+%7B+%3Fs+%3Fp+%3Fo+%7D"
+
+	basicRequest := func(password string) int {
+		credentials := base64.StdEncoding.EncodeToString([]byte("basic-user:" + password))
+		return request(t, handler, http.MethodGet, query, "", "Authorization", "Basic "+credentials).Code
+	}
+	bearerRequest := func(token string) int {
+		return request(t, handler, http.MethodGet, query, "", "Authorization", "Bearer "+token).Code
+	}
+
+	if code := basicRequest("secret"); code != http.StatusOK {
+		t.Errorf("expected the Basic password to be accepted, got %d", code)
+	}
+	// Rejected credentials are refused even though anonymous callers may query
+	if code := basicRequest("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong Basic password to be rejected, got %d", code)
+	}
+
+	now := time.Now()
+	if code := bearerRequest(signJWT(t, rsaKey, map[string]any{"sub": "alice", "exp": now.Add(time.Hour).Unix()})); code != http.StatusOK {
+		t.Errorf("expected a valid JWT to be accepted, got %d", code)
+	}
+	if code := bearerRequest(signJWT(t, rsaKey, map[string]any{"sub": "alice", "exp": now.Add(-time.Hour).Unix()})); code != http.StatusUnauthorized {
+		t.Errorf("expected an expired JWT to be rejected, got %d", code)
+	}
+
+	// An HS256 token "signed" with the RSA public key must not verify against that key
+	publicKey, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+	if code := bearerRequest(signJWT(t, publicPEM, map[string]any{"sub": "alice", "exp": now.Add(time.Hour).Unix()})); code != http.StatusUnauthorized {
+		t.Errorf("expected a JWT with a mismatched algorithm to be rejected, got %d", code)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	s, _ := authenticatedServer(t, false)
+	handler := s.WithCORSOrigins([]string{"https://app.example.org"}).Handler()
+	query := "/sparql?query=" + "ASK+%7B+%3Fs+%3Fp+%3Fo+%7D"
+
+	w := request(t, handler, http.MethodGet, query, "", "Origin", "https://app.example.org", auth.APIKeyHeader, "read-key")
+	assertStatus(t, w, http.StatusOK)
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://app.example.org" {
+		t.Errorf("expected the allowed origin to be echoed, got %q", origin)
+	}
+	if vary := w.Header().Get("Vary"); vary != "Origin" {
+		t.Errorf("expected Vary: Origin, got %q", vary)
+	}
+
+	w = request(t, handler, http.MethodGet, query, "", "Origin", "https://evil.example.org", auth.APIKeyHeader, "read-key")
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("expected no CORS header for another origin, got %q", origin)
+	}
+
+	// Preflight requests carry no credentials and are answered before authentication
+	w = request(t, handler, http.MethodOptions, "/data", "", "Origin", "https://app.example.org",
+		"Access-Control-Request-Method", "POST")
+	assertStatus(t, w, http.StatusOK)
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "https://app.example.org" {
+		t.Errorf("expected the preflight to allow the origin, got %q", origin)
+	}
+	if headers := w.Header().Get("Access-Control-Allow-Headers"); headers == "" {
+		t.Error("expected the preflight to list the allowed headers")
+	}
+
+	// Without a configured list any origin is allowed
+	w = request(t, newTestServer(t, testData).Handler(), http.MethodGet, query, "", "Origin", "https://evil.example.org")
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("expected any origin to be allowed by default, got %q", origin)
+	}
+}
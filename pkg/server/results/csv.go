@@ -3,11 +3,12 @@ package results
 import (
 	"encoding/csv"
 	"fmt"
-	"sort"
+	"io"
 	"strings"
 
 	"github.com/aleksaelezovic/trigo/pkg/rdf"
 	"github.com/aleksaelezovic/trigo/pkg/sparql/executor"
+	"github.com/aleksaelezovic/trigo/pkg/store"
 )
 
 // SPARQL CSV Results Format
@@ -15,59 +16,75 @@ import (
 
 // FormatSelectResultsCSV converts a SELECT result to SPARQL CSV format
 func FormatSelectResultsCSV(result *executor.SelectResult) ([]byte, error) {
-	var builder strings.Builder
-	w := csv.NewWriter(&builder)
+	return formatSelect(result, NewCSVSelectWriter)
+}
 
-	// Create blank node canonicalization mapping
-	bnodeMap := createBlankNodeMapping(result)
-
-	// Extract variable names
-	var varNames []string
-	if result.Variables == nil {
-		// SELECT * without variables list (shouldn't happen with new executor)
-		// Fallback: collect all variables from bindings (alphabetically sorted for consistency)
-		varSet := make(map[string]bool)
-		for _, binding := range result.Bindings {
-			for varName := range binding.Vars {
-				if !varSet[varName] {
-					varSet[varName] = true
-					varNames = append(varNames, varName)
-				}
-			}
-		}
-		sort.Strings(varNames)
-	} else {
-		// Use variables from query (preserves query order)
-		for _, v := range result.Variables {
-			varNames = append(varNames, v.Name)
-		}
+// CSVSelectWriter streams SELECT results in SPARQL CSV format
+type CSVSelectWriter struct {
+	streamWriter
+	csv  *csv.Writer
+	vars []string
+
+	// Blank node canonicalization mapping, built in order of first appearance
+	bnodeMap map[string]string
+}
+
+// NewCSVSelectWriter creates a streaming SPARQL CSV writer
+func NewCSVSelectWriter(w io.Writer) SelectWriter {
+	sw := &CSVSelectWriter{
+		streamWriter: newStreamWriter(w),
+		bnodeMap:     make(map[string]string),
 	}
+	sw.csv = csv.NewWriter(sw.buf)
+	return sw
+}
 
-	// Write header row
-	if err := w.Write(varNames); err != nil {
-		return nil, err
+// WriteHeader writes the header row
+func (w *CSVSelectWriter) WriteHeader(vars []string) error {
+	w.vars = vars
+	return w.csv.Write(vars)
+}
+
+// WriteBinding writes one data row
+func (w *CSVSelectWriter) WriteBinding(binding *store.Binding) error {
+	row := make([]string, len(w.vars))
+	for i, varName := range w.vars {
+		if term, ok := binding.Vars[varName]; ok {
+			row[i] = termToCSVValue(term, w.blankNodeMapping(term))
+		}
+		// If variable is not bound, leave empty string
 	}
+	return w.csv.Write(row)
+}
 
-	// Write data rows
-	for _, binding := range result.Bindings {
-		row := make([]string, len(varNames))
-		for i, varName := range varNames {
-			if term, ok := binding.Vars[varName]; ok {
-				row[i] = termToCSVValue(term, bnodeMap)
+// blankNodeMapping assigns a canonical label to a blank node seen for the first time
+// Uses single letters a-z, then falls back to b0, b1, b2...
+func (w *CSVSelectWriter) blankNodeMapping(term rdf.Term) map[string]string {
+	if bn, ok := term.(*rdf.BlankNode); ok {
+		if _, exists := w.bnodeMap[bn.ID]; !exists {
+			counter := len(w.bnodeMap)
+			if counter < 26 {
+				w.bnodeMap[bn.ID] = string(rune('a' + counter))
+			} else {
+				w.bnodeMap[bn.ID] = fmt.Sprintf("b%d", counter-26)
 			}
-			// If variable is not bound, leave empty string
-		}
-		if err := w.Write(row); err != nil {
-			return nil, err
 		}
 	}
+	return w.bnodeMap
+}
 
-	w.Flush()
-	if err := w.Error(); err != nil {
-		return nil, err
+// Flush writes the buffered rows to the underlying writer
+func (w *CSVSelectWriter) Flush() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
 	}
+	return w.streamWriter.Flush()
+}
 
-	return []byte(builder.String()), nil
+// Close flushes the remaining rows
+func (w *CSVSelectWriter) Close() error {
+	return w.Flush()
 }
 
 // FormatAskResultCSV converts an ASK result to SPARQL CSV format
@@ -97,34 +114,6 @@ func FormatAskResultCSV(result *executor.AskResult) ([]byte, error) {
 	return []byte(builder.String()), nil
 }
 
-// createBlankNodeMapping creates a canonical mapping for blank nodes
-// Maps internal blank node IDs to canonical labels (a, b, c, ... or b0, b1, b2 after z)
-func createBlankNodeMapping(result *executor.SelectResult) map[string]string {
-	bnodeMap := make(map[string]string)
-	counter := 0
-
-	// Collect all blank nodes in order of first appearance
-	for _, binding := range result.Bindings {
-		for _, term := range binding.Vars {
-			if bn, ok := term.(*rdf.BlankNode); ok {
-				if _, exists := bnodeMap[bn.ID]; !exists {
-					// Use single letters a-z, then fall back to b0, b1, b2...
-					var label string
-					if counter < 26 {
-						label = string(rune('a' + counter))
-					} else {
-						label = fmt.Sprintf("b%d", counter-26)
-					}
-					bnodeMap[bn.ID] = label
-					counter++
-				}
-			}
-		}
-	}
-
-	return bnodeMap
-}
-
 // termToCSVValue converts an RDF term to a CSV value string
 // According to SPARQL spec:
 // - IRIs are written without angle brackets
@@ -74,6 +74,9 @@ func formatNTriplesTerm(builder *strings.Builder, term executor.Term) error {
 			builder.WriteString(escapeNTriplesString(value))
 			builder.WriteString("\"")
 		}
+	case "triple":
+		// Value is already in N-Triples 1.2 form: <<( s p o )>>
+		builder.WriteString(term.Value)
 	default:
 		return fmt.Errorf("unknown term type: %s", term.Type)
 	}
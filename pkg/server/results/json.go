@@ -2,10 +2,11 @@ package results
 
 import (
 	"encoding/json"
-	"sort"
+	"io"
 
 	"github.com/aleksaelezovic/trigo/pkg/rdf"
 	"github.com/aleksaelezovic/trigo/pkg/sparql/executor"
+	"github.com/aleksaelezovic/trigo/pkg/store"
 )
 
 // SPARQL JSON Results Format
@@ -31,55 +32,78 @@ type ResultBindings struct {
 // BindingValue represents a single bound value
 type BindingValue struct {
 	Type     string  `json:"type"`
-	Value    string  `json:"value"`
+	Value    any     `json:"value"` // string, or TripleValue for triple terms
 	Datatype *string `json:"datatype,omitempty"`
 	XMLLang  *string `json:"xml:lang,omitempty"`
 }
 
+// TripleValue is the value of an RDF 1.2 triple term binding (SPARQL 1.2 JSON results)
+type TripleValue struct {
+	Subject   BindingValue `json:"subject"`
+	Predicate BindingValue `json:"predicate"`
+	Object    BindingValue `json:"object"`
+}
+
 // FormatSelectResultsJSON converts a SELECT result to SPARQL JSON format
 func FormatSelectResultsJSON(result *executor.SelectResult) ([]byte, error) {
-	// Extract variable names
-	var varNames []string
-	if result.Variables == nil {
-		// SELECT * without variables list (shouldn't happen with new executor)
-		// Fallback: collect all variables from bindings (alphabetically sorted for consistency)
-		varSet := make(map[string]bool)
-		for _, binding := range result.Bindings {
-			for varName := range binding.Vars {
-				if !varSet[varName] {
-					varSet[varName] = true
-					varNames = append(varNames, varName)
-				}
-			}
-		}
-		sort.Strings(varNames)
-	} else {
-		// Use variables from query (preserves query order)
-		for _, v := range result.Variables {
-			varNames = append(varNames, v.Name)
-		}
-	}
+	return formatSelect(result, NewJSONSelectWriter)
+}
 
-	// Convert bindings
-	jsonBindings := make([]map[string]BindingValue, 0, len(result.Bindings))
-	for _, binding := range result.Bindings {
-		jsonBinding := make(map[string]BindingValue)
-		for varName, term := range binding.Vars {
-			jsonBinding[varName] = termToBindingValue(term)
-		}
-		jsonBindings = append(jsonBindings, jsonBinding)
+// JSONSelectWriter streams SELECT results in SPARQL JSON format,
+// indented like FormatSelectResultsJSON
+type JSONSelectWriter struct {
+	streamWriter
+	rows int
+}
+
+// NewJSONSelectWriter creates a streaming SPARQL JSON writer
+func NewJSONSelectWriter(w io.Writer) SelectWriter {
+	return &JSONSelectWriter{streamWriter: newStreamWriter(w)}
+}
+
+// WriteHeader writes the head and opens the bindings array
+func (w *JSONSelectWriter) WriteHeader(vars []string) error {
+	if vars == nil {
+		vars = []string{}
+	}
+	data, err := json.MarshalIndent(vars, "    ", "  ")
+	if err != nil {
+		return err
 	}
+	w.buf.WriteString("{\n  \"head\": {\n    \"vars\": ")
+	w.buf.Write(data)
+	_, err = w.buf.WriteString("\n  },\n  \"results\": {\n    \"bindings\": [")
+	return err
+}
 
-	sparqlResult := SPARQLResultsJSON{
-		Head: ResultHead{
-			Vars: varNames,
-		},
-		Results: &ResultBindings{
-			Bindings: jsonBindings,
-		},
+// WriteBinding writes one solution
+func (w *JSONSelectWriter) WriteBinding(binding *store.Binding) error {
+	jsonBinding := make(map[string]BindingValue, len(binding.Vars))
+	for varName, term := range binding.Vars {
+		jsonBinding[varName] = termToBindingValue(term)
 	}
+	data, err := json.MarshalIndent(jsonBinding, "      ", "  ")
+	if err != nil {
+		return err
+	}
+	if w.rows > 0 {
+		w.buf.WriteString(",")
+	}
+	w.rows++
+	w.buf.WriteString("\n      ")
+	_, err = w.buf.Write(data)
+	return err
+}
 
-	return json.MarshalIndent(sparqlResult, "", "  ")
+// Close closes the bindings array and the document
+func (w *JSONSelectWriter) Close() error {
+	if w.rows > 0 {
+		w.buf.WriteString("\n    ")
+	}
+	if _, err := w.buf.WriteString("]\n  }\n}"); err != nil {
+		return err
+	}
+	return w.Flush()
 }
 
 // FormatAskResultJSON converts an ASK result to SPARQL JSON format
@@ -124,6 +148,16 @@ func termToBindingValue(term rdf.Term) BindingValue {
 
 		return bv
 
+	case *rdf.TripleTerm:
+		return BindingValue{
+			Type: "triple",
+			Value: TripleValue{
+				Subject:   termToBindingValue(t.Subject),
+				Predicate: termToBindingValue(t.Predicate),
+				Object:    termToBindingValue(t.Object),
+			},
+		}
+
 	default:
 		return BindingValue{
 			Type:  "literal",
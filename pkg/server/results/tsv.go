@@ -2,11 +2,12 @@ package results
 
 import (
 	"fmt"
-	"sort"
+	"io"
 	"strings"
 
 	"github.com/aleksaelezovic/trigo/pkg/rdf"
 	"github.com/aleksaelezovic/trigo/pkg/sparql/executor"
+	"github.com/aleksaelezovic/trigo/pkg/store"
 )
 
 // SPARQL TSV Results Format
@@ -14,58 +15,64 @@ import (
 
 // FormatSelectResultsTSV converts a SELECT result to SPARQL TSV format
 func FormatSelectResultsTSV(result *executor.SelectResult) ([]byte, error) {
-	var builder strings.Builder
+	return formatSelect(result, NewTSVSelectWriter)
+}
 
-	// Create blank node canonicalization mapping
-	bnodeMap := createBlankNodeMappingTSV(result)
-
-	// Extract variable names
-	var varNames []string
-	if result.Variables == nil {
-		// SELECT * without variables list (shouldn't happen with new executor)
-		// Fallback: collect all variables from bindings (alphabetically sorted for consistency)
-		varSet := make(map[string]bool)
-		for _, binding := range result.Bindings {
-			for varName := range binding.Vars {
-				if !varSet[varName] {
-					varSet[varName] = true
-					varNames = append(varNames, varName)
-				}
-			}
-		}
-		sort.Strings(varNames)
-	} else {
-		// Use variables from query (preserves query order)
-		for _, v := range result.Variables {
-			varNames = append(varNames, v.Name)
-		}
+// TSVSelectWriter streams SELECT results in SPARQL TSV format
+type TSVSelectWriter struct {
+	streamWriter
+	vars []string
+
+	// Blank node canonicalization mapping, built in order of first appearance
+	bnodeMap map[string]string
+}
+
+// NewTSVSelectWriter creates a streaming SPARQL TSV writer
+func NewTSVSelectWriter(w io.Writer) SelectWriter {
+	return &TSVSelectWriter{
+		streamWriter: newStreamWriter(w),
+		bnodeMap:     make(map[string]string),
 	}
+}
 
-	// Write header row with ? prefix
-	for i, varName := range varNames {
+// WriteHeader writes the header row with ? prefix
+func (w *TSVSelectWriter) WriteHeader(vars []string) error {
+	w.vars = vars
+	for i, varName := range vars {
 		if i > 0 {
-			builder.WriteString("\t")
+			w.buf.WriteString("\t")
 		}
-		builder.WriteString("?")
-		builder.WriteString(varName)
+		w.buf.WriteString("?")
+		w.buf.WriteString(varName)
 	}
-	builder.WriteString("\n")
+	_, err := w.buf.WriteString("\n")
+	return err
+}
 
-	// Write data rows
-	for _, binding := range result.Bindings {
-		for i, varName := range varNames {
-			if i > 0 {
-				builder.WriteString("\t")
-			}
-			if term, ok := binding.Vars[varName]; ok {
-				builder.WriteString(termToTSVValue(term, bnodeMap))
+// WriteBinding writes one data row
+func (w *TSVSelectWriter) WriteBinding(binding *store.Binding) error {
+	for i, varName := range w.vars {
+		if i > 0 {
+			w.buf.WriteString("\t")
+		}
+		if term, ok := binding.Vars[varName]; ok {
+			if bn, ok := term.(*rdf.BlankNode); ok {
+				if _, exists := w.bnodeMap[bn.ID]; !exists {
+					// Use b0, b1, b2... for TSV (as per W3C test expectations)
+					w.bnodeMap[bn.ID] = fmt.Sprintf("b%d", len(w.bnodeMap))
+				}
 			}
-			// If variable is not bound, leave empty
+			w.buf.WriteString(termToTSVValue(term, w.bnodeMap))
 		}
-		builder.WriteString("\n")
+		// If variable is not bound, leave empty
 	}
+	_, err := w.buf.WriteString("\n")
+	return err
+}
 
-	return []byte(builder.String()), nil
+// Close flushes the remaining rows
+func (w *TSVSelectWriter) Close() error {
+	return w.Flush()
 }
 
 // FormatAskResultTSV converts an ASK result to SPARQL TSV format
@@ -86,28 +93,6 @@ func FormatAskResultTSV(result *executor.AskResult) ([]byte, error) {
 	return []byte(builder.String()), nil
 }
 
-// createBlankNodeMappingTSV creates a canonical mapping for blank nodes
-// Maps internal blank node IDs to canonical labels (b0, b1, b2, ...)
-func createBlankNodeMappingTSV(result *executor.SelectResult) map[string]string {
-	bnodeMap := make(map[string]string)
-	counter := 0
-
-	// Collect all blank nodes in order of first appearance
-	for _, binding := range result.Bindings {
-		for _, term := range binding.Vars {
-			if bn, ok := term.(*rdf.BlankNode); ok {
-				if _, exists := bnodeMap[bn.ID]; !exists {
-					// Use b0, b1, b2... for TSV (as per W3C test expectations)
-					bnodeMap[bn.ID] = fmt.Sprintf("b%d", counter)
-					counter++
-				}
-			}
-		}
-	}
-
-	return bnodeMap
-}
-
 // termToTSVValue converts an RDF term to a TSV value string
 // According to SPARQL TSV spec:
 // - IRIs are enclosed in angle brackets: <iri>
@@ -0,0 +1,114 @@
+package results
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aleksaelezovic/trigo/pkg/sparql/executor"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// SelectWriter writes the solutions of a SELECT query as they are produced,
+// so a result never has to fit in memory
+type SelectWriter interface {
+	// WriteHeader writes the variable names, before any solution
+	WriteHeader(vars []string) error
+	// WriteBinding writes one solution
+	WriteBinding(binding *store.Binding) error
+	// Flush sends the buffered output on to the underlying writer
+	Flush() error
+	// Close ends the document and flushes it
+	Close() error
+}
+
+// NewSelectWriter returns the streaming writer for a result format: json, xml, csv or tsv
+func NewSelectWriter(w io.Writer, format string) (SelectWriter, error) {
+	switch format {
+	case "json":
+		return NewJSONSelectWriter(w), nil
+	case "xml":
+		return NewXMLSelectWriter(w), nil
+	case "csv":
+		return NewCSVSelectWriter(w), nil
+	case "tsv":
+		return NewTSVSelectWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported result format: %s", format)
+	}
+}
+
+// flusher is implemented by writers that can send buffered data to the client, such as http.ResponseWriter
+type flusher interface {
+	Flush()
+}
+
+// streamWriter buffers the output of a SelectWriter
+type streamWriter struct {
+	buf *bufio.Writer
+	out io.Writer
+}
+
+func newStreamWriter(w io.Writer) streamWriter {
+	return streamWriter{
+		buf: bufio.NewWriterSize(w, 32*1024),
+		out: w,
+	}
+}
+
+// Flush writes the buffer to the underlying writer and flushes that too if it can
+func (s *streamWriter) Flush() error {
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if f, ok := s.out.(flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// formatSelect writes a complete SELECT result with a streaming writer
+func formatSelect(result *executor.SelectResult, newWriter func(io.Writer) SelectWriter) ([]byte, error) {
+	var buf bytes.Buffer
+	sw := newWriter(&buf)
+
+	if err := sw.WriteHeader(selectVariableNames(result)); err != nil {
+		return nil, err
+	}
+	for _, binding := range result.Bindings {
+		if err := sw.WriteBinding(binding); err != nil {
+			return nil, err
+		}
+	}
+	if err := sw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// selectVariableNames returns the variable names of a SELECT result
+func selectVariableNames(result *executor.SelectResult) []string {
+	var varNames []string
+	if result.Variables == nil {
+		// SELECT * without variables list (shouldn't happen with new executor)
+		// Fallback: collect all variables from bindings (alphabetically sorted for consistency)
+		varSet := make(map[string]bool)
+		for _, binding := range result.Bindings {
+			for varName := range binding.Vars {
+				if !varSet[varName] {
+					varSet[varName] = true
+					varNames = append(varNames, varName)
+				}
+			}
+		}
+		sort.Strings(varNames)
+	} else {
+		// Use variables from query (preserves query order)
+		for _, v := range result.Variables {
+			varNames = append(varNames, v.Name)
+		}
+	}
+	return varNames
+}
@@ -0,0 +1,240 @@
+package results
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/internal/encoding"
+	"github.com/aleksaelezovic/trigo/internal/storage"
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/executor"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+var formatters = map[string]func(*executor.SelectResult) ([]byte, error){
+	"json": FormatSelectResultsJSON,
+	"xml":  FormatSelectResultsXML,
+	"csv":  FormatSelectResultsCSV,
+	"tsv":  FormatSelectResultsTSV,
+}
+
+func row(pairs ...any) *store.Binding {
+	binding := store.NewBinding()
+	for i := 0; i < len(pairs); i += 2 {
+		binding.Set(pairs[i].(string), pairs[i+1].(rdf.Term))
+	}
+	return binding
+}
+
+func typed(value, datatype string) rdf.Term {
+	return rdf.NewLiteralWithDatatype(value, rdf.NewNamedNode("http://www.w3.org/2001/XMLSchema#"+datatype))
+}
+
+func variables(names ...string) []*parser.Variable {
+	vars := make([]*parser.Variable, len(names))
+	for i, name := range names {
+		vars[i] = &parser.Variable{Name: name}
+	}
+	return vars
+}
+
+// selectFixture is the result the golden files in testdata were written from, by the
+// formatters that built the whole document in memory before results were streamed.
+// Those wrote the bindings of a solution in map order; the golden XML has them in header order.
+func selectFixture() *executor.SelectResult {
+	iri := func(s string) rdf.Term { return rdf.NewNamedNode("http://example.org/" + s) }
+	return &executor.SelectResult{
+		Variables: variables("s", "label", "value", "extra"),
+		Bindings: []*store.Binding{
+			row("s", iri("a"), "label", rdf.NewLiteral("plain"), "value", typed("42", "integer")),
+			row("s", rdf.NewBlankNode("x1"), "label", rdf.NewLiteralWithLanguage("Ünïcödé, \"quoted\"\nline\ttab <&>", "en"), "value", typed("1.5", "decimal")),
+			row("s", iri("b?x=1&y=<2>"), "label", typed("true", "boolean"), "value", typed("2e3", "double")),
+			row("s", rdf.NewBlankNode("x1"), "value", typed("2024-01-01", "date"), "extra", iri("c")),
+			row("extra", &rdf.TripleTerm{Subject: iri("a"), Predicate: iri("p"), Object: rdf.NewLiteral("o")}),
+			row("s", rdf.NewBlankNode("x2"), "label", rdf.NewLiteral("")),
+			row(),
+		},
+	}
+}
+
+// stream writes a result through the streaming writer of a format
+func stream(t *testing.T, format string, result *executor.SelectResult) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	sw, err := NewSelectWriter(&buf, format)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	if err := sw.WriteHeader(selectVariableNames(result)); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+	for i, binding := range result.Bindings {
+		if err := sw.WriteBinding(binding); err != nil {
+			t.Fatalf("failed to write binding: %v", err)
+		}
+		// Flushing part way through doesn't change the output
+		if i%2 == 0 {
+			if err := sw.Flush(); err != nil {
+				t.Fatalf("failed to flush: %v", err)
+			}
+		}
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("failed to close writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func assertSameOutput(t *testing.T, expected, actual []byte) {
+	t.Helper()
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestSelectFormatsMatchGolden(t *testing.T) {
+	results := map[string]*executor.SelectResult{
+		"select": selectFixture(),
+		"empty":  {Variables: variables("s", "o")},
+	}
+	for name, result := range results {
+		for format, formatter := range formatters {
+			t.Run(name+"."+format, func(t *testing.T) {
+				golden, err := os.ReadFile(filepath.Join("testdata", name+"."+format))
+				if err != nil {
+					t.Fatalf("failed to read golden file: %v", err)
+				}
+				data, err := formatter(result)
+				if err != nil {
+					t.Fatalf("failed to format: %v", err)
+				}
+				assertSameOutput(t, golden, data)
+				assertSameOutput(t, golden, stream(t, format, result))
+			})
+		}
+	}
+}
+
+func TestSelectWriterWithoutVariables(t *testing.T) {
+	// SELECT * results without a variable list are written with the variables sorted
+	result := selectFixture()
+	result.Variables = nil
+	sortedResult := selectFixture()
+	sortedResult.Variables = variables("extra", "label", "s", "value")
+
+	for format, formatter := range formatters {
+		t.Run(format, func(t *testing.T) {
+			expected, err := formatter(sortedResult)
+			if err != nil {
+				t.Fatalf("failed to format: %v", err)
+			}
+			data, err := formatter(result)
+			if err != nil {
+				t.Fatalf("failed to format: %v", err)
+			}
+			assertSameOutput(t, expected, data)
+			assertSameOutput(t, expected, stream(t, format, result))
+		})
+	}
+}
+
+const cursorData = `@prefix ex: <http://example.org/> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+ex:a ex:label "A", "a"@en ; ex:value 1, "2.5"^^xsd:decimal, "3"^^xsd:string .
+ex:b ex:label "line\nbreak, \"quoted\"" ; ex:next [ ex:label "anonymous" ] .
+ex:g { ex:c ex:label "in a graph" ; ex:value true . }
+`
+
+// newTestStore returns a store holding a TriG document
+func newTestStore(t *testing.T, trig string) *store.TripleStore {
+	t.Helper()
+	badgerStorage, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { badgerStorage.Close() })
+
+	ts := store.NewTripleStore(badgerStorage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+	quads, err := rdf.NewTriGParser(trig).Parse()
+	if err != nil {
+		t.Fatalf("failed to parse data: %v", err)
+	}
+	if err := ts.InsertQuadsBatch(quads); err != nil {
+		t.Fatalf("failed to insert data: %v", err)
+	}
+	return ts
+}
+
+func TestCursorStreamMatchesSelectResult(t *testing.T) {
+	ts := newTestStore(t, cursorData)
+	stats, err := optimizer.LoadStatistics(ts)
+	if err != nil {
+		t.Fatalf("failed to load statistics: %v", err)
+	}
+	exec := executor.NewExecutor(ts)
+
+	for _, query := range []string{
+		`SELECT ?s ?p ?o WHERE { ?s ?p ?o } ORDER BY ?s ?p ?o`,
+		`SELECT * WHERE { ?s ex:label ?l OPTIONAL { ?s ex:value ?v } } ORDER BY ?l`,
+		`SELECT ?g ?s WHERE { GRAPH ?g { ?s ?p ?o } }`,
+		`SELECT ?s WHERE { ?s ex:missing ?o }`,
+	} {
+		parsed, err := parser.NewParser("PREFIX ex: <http://example.org/> " + query).Parse()
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", query, err)
+		}
+		plan, err := optimizer.NewOptimizer(stats).Optimize(context.Background(), parsed)
+		if err != nil {
+			t.Fatalf("failed to optimize %q: %v", query, err)
+		}
+		result, err := exec.Execute(context.Background(), plan)
+		if err != nil {
+			t.Fatalf("failed to execute %q: %v", query, err)
+		}
+
+		for format, formatter := range formatters {
+			expected, err := formatter(result.(*executor.SelectResult))
+			if err != nil {
+				t.Fatalf("failed to format: %v", err)
+			}
+
+			cursor, err := exec.Select(context.Background(), plan)
+			if err != nil {
+				t.Fatalf("failed to select %q: %v", query, err)
+			}
+			var buf bytes.Buffer
+			sw, err := NewSelectWriter(&buf, format)
+			if err != nil {
+				t.Fatalf("failed to create writer: %v", err)
+			}
+			vars := make([]string, len(cursor.Variables))
+			for i, v := range cursor.Variables {
+				vars[i] = v.Name
+			}
+			if err := sw.WriteHeader(vars); err != nil {
+				t.Fatalf("failed to write header: %v", err)
+			}
+			for cursor.Next() {
+				if err := sw.WriteBinding(cursor.Binding()); err != nil {
+					t.Fatalf("failed to write binding: %v", err)
+				}
+			}
+			if err := cursor.Err(); err != nil {
+				t.Fatalf("%s: query failed: %v", query, err)
+			}
+			cursor.Close()
+			if err := sw.Close(); err != nil {
+				t.Fatalf("failed to close writer: %v", err)
+			}
+
+			if !bytes.Equal(expected, buf.Bytes()) {
+				t.Errorf("%s as %s: expected:\n%s\ngot:\n%s", query, format, expected, buf.Bytes())
+			}
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/aleksaelezovic/trigo/pkg/rdf"
 	"github.com/aleksaelezovic/trigo/pkg/sparql/executor"
+	"github.com/aleksaelezovic/trigo/pkg/store"
 )
 
 // Results represents SPARQL XML query results
@@ -288,52 +289,60 @@ func bindingToString(binding map[string]rdf.Term) string {
 
 // FormatSelectResultsXML converts a SELECT result to SPARQL XML format
 func FormatSelectResultsXML(result *executor.SelectResult) ([]byte, error) {
-	// Extract variable names
-	var varNames []string
-	if result.Variables == nil {
-		varSet := make(map[string]bool)
-		for _, binding := range result.Bindings {
-			for varName := range binding.Vars {
-				if !varSet[varName] {
-					varSet[varName] = true
-					varNames = append(varNames, varName)
-				}
-			}
-		}
-	} else {
-		for _, v := range result.Variables {
-			varNames = append(varNames, v.Name)
-		}
-	}
+	return formatSelect(result, NewXMLSelectWriter)
+}
 
-	xml := `<?xml version="1.0"?>
+// XMLSelectWriter streams SELECT results in SPARQL XML format
+type XMLSelectWriter struct {
+	streamWriter
+	vars []string
+}
+
+// NewXMLSelectWriter creates a streaming SPARQL XML writer
+func NewXMLSelectWriter(w io.Writer) SelectWriter {
+	return &XMLSelectWriter{streamWriter: newStreamWriter(w)}
+}
+
+// WriteHeader writes the head and opens the results element
+func (w *XMLSelectWriter) WriteHeader(vars []string) error {
+	w.vars = vars
+	w.buf.WriteString(`<?xml version="1.0"?>
 <sparql xmlns="http://www.w3.org/2005/sparql-results#">
   <head>
-`
-
-	for _, varName := range varNames {
-		xml += "    <variable name=\"" + varName + "\"/>\n"
+`)
+	for _, varName := range vars {
+		w.buf.WriteString("    <variable name=\"" + xmlEscape(varName) + "\"/>\n")
 	}
-
-	xml += `  </head>
+	_, err := w.buf.WriteString(`  </head>
   <results>
-`
+`)
+	return err
+}
 
-	for _, binding := range result.Bindings {
-		xml += "    <result>\n"
-		for varName, term := range binding.Vars {
-			xml += "      <binding name=\"" + varName + "\">\n"
-			xml += termToXML(term, "        ")
-			xml += "      </binding>\n"
+// WriteBinding writes one result element, with the bound variables in header order
+func (w *XMLSelectWriter) WriteBinding(binding *store.Binding) error {
+	w.buf.WriteString("    <result>\n")
+	for _, varName := range w.vars {
+		term, ok := binding.Vars[varName]
+		if !ok {
+			continue
 		}
-		xml += "    </result>\n"
+		w.buf.WriteString("      <binding name=\"" + xmlEscape(varName) + "\">\n")
+		w.buf.WriteString(termToXML(term, "        "))
+		w.buf.WriteString("      </binding>\n")
 	}
+	_, err := w.buf.WriteString("    </result>\n")
+	return err
+}
 
-	xml += `  </results>
+// Close closes the results and the document
+func (w *XMLSelectWriter) Close() error {
+	if _, err := w.buf.WriteString(`  </results>
 </sparql>
-`
-
-	return []byte(xml), nil
+`); err != nil {
+		return err
+	}
+	return w.Flush()
 }
 
 // FormatAskResultXML converts an ASK result to SPARQL XML format
@@ -369,6 +378,14 @@ func termToXML(term rdf.Term, indent string) string {
 		}
 		return indent + "<literal>" + xmlEscape(t.Value) + "</literal>\n"
 
+	case *rdf.TripleTerm:
+		inner := indent + "    "
+		return indent + "<triple>\n" +
+			indent + "  <subject>\n" + termToXML(t.Subject, inner) + indent + "  </subject>\n" +
+			indent + "  <predicate>\n" + termToXML(t.Predicate, inner) + indent + "  </predicate>\n" +
+			indent + "  <object>\n" + termToXML(t.Object, inner) + indent + "  </object>\n" +
+			indent + "</triple>\n"
+
 	default:
 		return indent + "<literal>" + xmlEscape(term.String()) + "</literal>\n"
 	}
@@ -3,8 +3,11 @@ package server
 import (
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/aleksaelezovic/trigo/pkg/access"
+	"github.com/aleksaelezovic/trigo/pkg/auth"
 	"github.com/aleksaelezovic/trigo/pkg/sparql/executor"
 	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
 	"github.com/aleksaelezovic/trigo/pkg/store"
@@ -16,6 +19,38 @@ type Server struct {
 	executor  *executor.Executor
 	optimizer *optimizer.Optimizer
 	addr      string
+
+	// Named datasets served under /datasets/{name}/...
+	datasets  *store.Registry
+	enginesMu sync.Mutex
+	engines   map[string]*engine
+
+	// Per-graph access control, nil means unrestricted
+	policy *access.Policy
+
+	// Authentication and CORS, see middleware.go
+	authenticator      auth.Authenticator // nil disables authentication
+	allowAnonymousRead bool
+	corsOrigins        []string // empty allows any origin
+
+	// Default and maximum query execution time, zero means unlimited
+	queryTimeout time.Duration
+
+	// Memory each sort, DISTINCT and GROUP BY may hold before spilling to disk, zero for the default
+	memoryBudget int64
+
+	// Goroutines a query may run on, zero for the executor default of one
+	parallelism int
+}
+
+// DefaultQueryTimeout is the query timeout of a new server, below the HTTP write timeout
+const DefaultQueryTimeout = 10 * time.Second
+
+// engine bundles the query machinery for one dataset
+type engine struct {
+	store     *store.TripleStore
+	executor  *executor.Executor
+	optimizer *optimizer.Optimizer
 }
 
 // NewServer creates a new SPARQL HTTP server
@@ -23,8 +58,7 @@ func NewServer(store *store.TripleStore, addr string) *Server {
 	exec := executor.NewExecutor(store)
 
 	// Get statistics for optimizer
-	count, _ := store.Count()
-	stats := &optimizer.Statistics{TotalTriples: count}
+	stats, _ := optimizer.LoadStatistics(store)
 	opt := optimizer.NewOptimizer(stats)
 
 	return &Server{
@@ -32,21 +66,62 @@ func NewServer(store *store.TripleStore, addr string) *Server {
 		executor:  exec,
 		optimizer: opt,
 		addr:      addr,
+		engines:   make(map[string]*engine),
+
+		queryTimeout: DefaultQueryTimeout,
 	}
 }
 
+// WithDatasets enables the named dataset routes and admin endpoints backed by a registry
+func (s *Server) WithDatasets(registry *store.Registry) *Server {
+	s.datasets = registry
+	return s
+}
+
+// WithAccessPolicy restricts queries and uploads to the graphs the calling principal may access
+func (s *Server) WithAccessPolicy(policy *access.Policy) *Server {
+	s.policy = policy
+	return s
+}
+
+// WithQueryTimeout sets the default and maximum execution time of a query (zero disables the limit)
+// Requests may ask for a shorter timeout with the "timeout" parameter
+func (s *Server) WithQueryTimeout(timeout time.Duration) *Server {
+	s.queryTimeout = timeout
+	return s
+}
+
+// WithMemoryBudget sets the memory, in bytes, each sort, DISTINCT and GROUP BY of a query may hold
+// before spilling to temporary files (zero keeps the default)
+func (s *Server) WithMemoryBudget(bytes int64) *Server {
+	s.memoryBudget = bytes
+	s.executor.SetMemoryBudget(bytes)
+	return s
+}
+
+// WithParallelism sets the number of goroutines a query may run on (zero keeps the default of one)
+// Requests may ask for fewer with the "parallelism" parameter
+func (s *Server) WithParallelism(n int) *Server {
+	s.parallelism = n
+	s.executor.SetParallelism(n)
+	return s
+}
+
 // Start starts the HTTP server
 func (s *Server) Start() error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/sparql", s.handleSPARQL)
-	mux.HandleFunc("/data", s.handleDataUpload)
-	mux.HandleFunc("/", s.handleRoot)
+	// Leave time to write the response of a query that runs up to its timeout
+	writeTimeout := 15 * time.Second
+	if s.queryTimeout == 0 {
+		writeTimeout = 0
+	} else if s.queryTimeout+5*time.Second > writeTimeout {
+		writeTimeout = s.queryTimeout + 5*time.Second
+	}
 
 	server := &http.Server{
 		Addr:         s.addr,
-		Handler:      mux,
+		Handler:      s.Handler(),
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: writeTimeout,
 		IdleTimeout:  60 * time.Second,
 	}
 
@@ -54,9 +129,102 @@ func (s *Server) Start() error {
 	return server.ListenAndServe()
 }
 
+// Handler returns the HTTP handler with all endpoint routes
+func (s *Server) Handler() http.Handler {
+	read := func(h http.HandlerFunc) http.HandlerFunc { return s.requireRole(auth.RoleReadOnly, h) }
+	write := func(h http.HandlerFunc) http.HandlerFunc { return s.requireRole(auth.RoleReadWrite, h) }
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sparql", read(s.handleSPARQL))
+	mux.HandleFunc("/data", write(s.handleDataUpload))
+	mux.HandleFunc("POST /patch", write(s.handlePatch))
+	mux.HandleFunc("POST /analyze", write(s.handleAnalyze))
+	mux.HandleFunc("/", read(s.handleRoot))
+
+	if s.datasets != nil {
+		mux.HandleFunc("GET /datasets", read(s.handleListDatasets))
+		mux.HandleFunc("POST /datasets", write(s.handleCreateDataset))
+		mux.HandleFunc("GET /datasets/{name}", read(s.handleGetDataset))
+		mux.HandleFunc("DELETE /datasets/{name}", write(s.handleDeleteDataset))
+		mux.HandleFunc("/datasets/{name}/sparql", read(s.handleSPARQL))
+		mux.HandleFunc("/datasets/{name}/data", write(s.handleDataUpload))
+		mux.HandleFunc("POST /datasets/{name}/patch", write(s.handlePatch))
+		mux.HandleFunc("POST /datasets/{name}/analyze", write(s.handleAnalyze))
+	}
+
+	return s.cors(s.authenticate(mux))
+}
+
 // Stats returns the optimizer statistics
 func (s *Server) Stats() *optimizer.Statistics {
 	// Update statistics
-	count, _ := s.store.Count()
-	return &optimizer.Statistics{TotalTriples: count}
+	stats, _ := optimizer.LoadStatistics(s.store)
+	return stats
+}
+
+// engineFor returns the engine of the dataset addressed by the request:
+// the default store for /sparql and /data, a named dataset for /datasets/{name}/...
+// With an access policy, the engine only sees graphs the request's principal may access.
+// The dataset stays open until release is called.
+func (s *Server) engineFor(r *http.Request) (eng *engine, release func(), err error) {
+	eng, release, err = s.datasetEngine(r.PathValue("name"))
+	if err != nil || s.policy == nil {
+		return eng, release, err
+	}
+
+	ts := s.authorizedStore(r, eng.store)
+	return &engine{store: ts, executor: s.newExecutor(ts), optimizer: eng.optimizer}, release, nil
+}
+
+// authorizedStore returns the view of a store the request's principal may access
+func (s *Server) authorizedStore(r *http.Request, ts *store.TripleStore) *store.TripleStore {
+	if s.policy == nil {
+		return ts
+	}
+	return ts.WithAuthorizer(s.policy.Authorizer(principalName(r)))
+}
+
+// newExecutor creates an executor for a store with the server's execution settings
+func (s *Server) newExecutor(ts *store.TripleStore) *executor.Executor {
+	exec := executor.NewExecutor(ts)
+	exec.SetMemoryBudget(s.memoryBudget)
+	exec.SetParallelism(s.parallelism)
+	return exec
+}
+
+// datasetEngine returns the unrestricted engine of a dataset ("" for the default store)
+// The dataset stays open until release is called, even if it is deleted meanwhile.
+func (s *Server) datasetEngine(name string) (eng *engine, release func(), err error) {
+	if name == "" {
+		return &engine{store: s.store, executor: s.executor, optimizer: s.optimizer}, func() {}, nil
+	}
+
+	ts, release, err := s.datasets.Acquire(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.enginesMu.Lock()
+	defer s.enginesMu.Unlock()
+
+	// A dataset deleted and recreated under the same name gets a fresh engine
+	if eng, ok := s.engines[name]; ok && eng.store == ts {
+		return eng, release, nil
+	}
+
+	stats, _ := optimizer.LoadStatistics(ts)
+	eng = &engine{
+		store:     ts,
+		executor:  s.newExecutor(ts),
+		optimizer: optimizer.NewOptimizer(stats),
+	}
+	s.engines[name] = eng
+	return eng, release, nil
+}
+
+// forgetEngine drops the cached engine of a deleted dataset
+func (s *Server) forgetEngine(name string) {
+	s.enginesMu.Lock()
+	defer s.enginesMu.Unlock()
+	delete(s.engines, name)
 }
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/internal/encoding"
+	"github.com/aleksaelezovic/trigo/internal/storage"
+	"github.com/aleksaelezovic/trigo/pkg/access"
+	"github.com/aleksaelezovic/trigo/pkg/auth"
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+const testData = `@prefix ex: <http://example.org/> .
+ex:a ex:name "A" .
+ex:alice { ex:b ex:name "B" . }
+ex:bob { ex:c ex:name "C" . }
+`
+
+// newTestServer returns a server over a store holding a TriG document, with datasets enabled
+func newTestServer(t *testing.T, trig string) *Server {
+	t.Helper()
+	badgerStorage, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { badgerStorage.Close() })
+
+	ts := store.NewTripleStore(badgerStorage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+	quads, err := rdf.NewTriGParser(trig).Parse()
+	if err != nil {
+		t.Fatalf("failed to parse data: %v", err)
+	}
+	if err := ts.InsertQuadsBatch(quads); err != nil {
+		t.Fatalf("failed to insert data: %v", err)
+	}
+
+	provider, err := storage.NewBadgerProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	registry, err := store.NewRegistry(provider, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	t.Cleanup(func() { registry.Close() })
+
+	return NewServer(ts, "").WithDatasets(registry)
+}
+
+// request sends a request to a handler; headers are given as name, value pairs
+func request(t *testing.T, handler http.Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, target, reader)
+	for i := 0; i < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// assertStatus fails unless a response has the expected status code
+func assertStatus(t *testing.T, w *httptest.ResponseRecorder, expected int) {
+	t.Helper()
+	if w.Code != expected {
+		t.Errorf("expected status %d, got %d: %s", expected, w.Code, w.Body.String())
+	}
+}
+
+// withTestPolicy authenticates the API keys "admin-key" (write access to all graphs),
+// "alice-key" (write access to her graph only) and "reader-key" (read-only, all graphs)
+func withTestPolicy(t *testing.T, s *Server) *Server {
+	t.Helper()
+	keys, err := auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Key: "admin-key", Name: "admin", Role: "write"},
+		{Key: "alice-key", Name: "alice", Role: "write"},
+		{Key: "reader-key", Name: "reader", Role: "read"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	policy := &access.Policy{Principals: map[string][]access.Rule{
+		"admin":  {{Graph: "*", Permissions: []string{"read", "write"}}},
+		"alice":  {{Graph: "http://example.org/alice", Permissions: []string{"read", "write"}}},
+		"reader": {{Graph: "*", Permissions: []string{"read"}}},
+	}}
+	return s.WithAuth(keys, false).WithAccessPolicy(policy)
+}
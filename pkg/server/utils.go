@@ -1,24 +1,164 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
 	"github.com/aleksaelezovic/trigo/pkg/server/results"
 	"github.com/aleksaelezovic/trigo/pkg/sparql/executor"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
 )
 
 // writeError writes an error response
 func (s *Server) writeError(w http.ResponseWriter, statusCode int, message string) {
 	log.Printf("Error: %s", message)
 
+	s.writeJSON(w, statusCode, map[string]any{"error": map[string]any{"code": statusCode, "message": message}})
+}
+
+// writeParseError writes a 400 response for a syntax error in a query or upload, with the
+// line, column and offending token when the parser reported them
+func (s *Server) writeParseError(w http.ResponseWriter, message string, err error) {
+	message = fmt.Sprintf("%s: %v", message, err)
+	log.Printf("Error: %s", message)
+
+	detail := map[string]any{"code": http.StatusBadRequest, "message": message}
+	var parseErr *rdf.ParseError
+	if errors.As(err, &parseErr) {
+		detail["line"] = parseErr.Line
+		detail["column"] = parseErr.Column
+		detail["offset"] = parseErr.Offset
+		detail["token"] = parseErr.Token
+		if parseErr.Expected != "" {
+			detail["expected"] = parseErr.Expected
+		}
+	}
+	s.writeJSON(w, http.StatusBadRequest, map[string]any{"error": detail})
+}
+
+// writeQueryError writes the response for a failed query, telling timeouts and disconnects apart from other errors
+func (s *Server) writeQueryError(w http.ResponseWriter, prefix string, err error, timeout time.Duration) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		s.writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("Query timed out after %s", timeout))
+	case errors.Is(err, context.Canceled):
+		// The client went away, nobody is left to read a response
+		log.Printf("Query cancelled: client disconnected")
+	default:
+		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("%s: %v", prefix, err))
+	}
+}
+
+// requestTimeout returns the timeout for a query request: the "timeout" parameter
+// (seconds, or a duration such as "500ms"), capped by the server's query timeout
+func (s *Server) requestTimeout(r *http.Request) (time.Duration, error) {
+	values := r.URL.Query()
+	if r.Form != nil {
+		values = r.Form
+	}
+
+	param := values.Get("timeout")
+	if param == "" {
+		return s.queryTimeout, nil
+	}
+
+	timeout, err := time.ParseDuration(param)
+	if err != nil {
+		seconds, convErr := strconv.ParseFloat(param, 64)
+		if convErr != nil {
+			return 0, fmt.Errorf("expected seconds or a duration, got %s", param)
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+
+	if s.queryTimeout > 0 && timeout > s.queryTimeout {
+		return s.queryTimeout, nil
+	}
+	return timeout, nil
+}
+
+// requestParallelism returns the number of goroutines asked for by the "parallelism" parameter,
+// zero when absent. The executor caps it by the server's setting.
+func requestParallelism(r *http.Request) (int, error) {
+	values := r.URL.Query()
+	if r.Form != nil {
+		values = r.Form
+	}
+
+	param := values.Get("parallelism")
+	if param == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, fmt.Errorf("expected an integer, got %s", param)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return n, nil
+}
+
+// explainMode selects what the "explain" parameter asks for instead of query results
+type explainMode int
+
+const (
+	explainNone    explainMode = iota
+	explainPlan                // the optimized plan with estimated cardinalities
+	explainAnalyze             // the plan annotated with runtime statistics of an execution
+)
+
+// requestExplainMode parses the "explain" parameter: "true" or "plan" explains, "analyze" also executes
+func requestExplainMode(r *http.Request) (explainMode, error) {
+	values := r.URL.Query()
+	if r.Form != nil {
+		values = r.Form
+	}
+
+	switch param := strings.ToLower(values.Get("explain")); param {
+	case "", "false":
+		return explainNone, nil
+	case "true", "plan":
+		return explainPlan, nil
+	case "analyze":
+		return explainAnalyze, nil
+	default:
+		return explainNone, fmt.Errorf("expected true, plan or analyze, got %s", param)
+	}
+}
+
+// resultRows counts the solutions or triples of a query result
+func resultRows(result executor.QueryResult) int {
+	switch r := result.(type) {
+	case *executor.SelectResult:
+		return len(r.Bindings)
+	case *executor.ConstructResult:
+		return len(r.Triples)
+	case *executor.AskResult:
+		if r.Result {
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeJSON writes a JSON response
+func (s *Server) writeJSON(w http.ResponseWriter, statusCode int, value any) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(statusCode)
-
-	// Simple JSON serialization
-	_, _ = w.Write([]byte(fmt.Sprintf(`{"error":{"code":%d,"message":"%s"}}`, statusCode, message))) // #nosec G104 - error writing response is logged elsewhere if needed
+	_ = json.NewEncoder(w).Encode(value) // #nosec G104 - error writing response is logged elsewhere if needed
 }
 
 // negotiateFormat determines the response format based on Accept header
@@ -49,32 +189,120 @@ func (s *Server) negotiateFormat(acceptHeader string) string {
 	return "json"
 }
 
-// writeResult writes the query result in the specified format
-func (s *Server) writeResult(w http.ResponseWriter, result executor.QueryResult, format string) {
-	var data []byte
-	var err error
-	var contentType string
+// selectFlushRows is the number of solutions written between flushes to the client
+const selectFlushRows = 1000
+
+// selectWriteTimeout is how long the client has to take each batch of streamed solutions.
+// It replaces the server's write timeout, which would otherwise cut off long result streams.
+const selectWriteTimeout = 30 * time.Second
+
+// writeSelect streams the solutions of a SELECT query to the client.
+// Errors before the first solution get an error response. Once solutions have been
+// sent the status can't change, so later errors abort the response instead.
+// The query timeout only covers the work up to the first solution, such as a sort:
+// after that the stream runs until the solutions are exhausted or the client goes away.
+func (s *Server) writeSelect(ctx context.Context, w http.ResponseWriter, eng *engine, query *optimizer.OptimizedQuery, format string, timeout time.Duration) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var deadline *time.Timer
+	if timeout > 0 {
+		deadline = time.AfterFunc(timeout, func() { cancel(context.DeadlineExceeded) })
+		defer deadline.Stop()
+	}
+	// timeoutError reports a query stopped by the deadline as timed out rather than cancelled
+	timeoutError := func(err error) error {
+		if cause := context.Cause(ctx); errors.Is(err, context.Canceled) && errors.Is(cause, context.DeadlineExceeded) {
+			return cause
+		}
+		return err
+	}
+
+	cursor, err := eng.executor.Select(ctx, query)
+	if err != nil {
+		s.writeQueryError(w, "Execution error", timeoutError(err), timeout)
+		return
+	}
+	defer cursor.Close()
 
-	// Handle CONSTRUCT results separately (they return RDF, not SPARQL results)
-	if constructResult, ok := result.(*executor.ConstructResult); ok {
-		// CONSTRUCT queries return RDF triples in N-Triples format
-		contentType = "application/n-triples; charset=utf-8"
-		data, err = results.FormatConstructResultNTriples(constructResult)
-		if err != nil {
-			s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Formatting error: %v", err))
+	// Wait for the first solution, which is when blocking operators such as ORDER BY finish
+	more := cursor.Next()
+	if !more {
+		if err := cursor.Err(); err != nil {
+			s.writeQueryError(w, "Execution error", timeoutError(err), timeout)
 			return
 		}
-		w.Header().Set("Content-Type", contentType)
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(data) // #nosec G104 - error writing response is logged elsewhere if needed
+	}
+	if deadline != nil && !deadline.Stop() {
+		// The deadline passed while the first solution was produced
+		s.writeQueryError(w, "Execution error", context.DeadlineExceeded, timeout)
 		return
 	}
 
-	// Handle SELECT and ASK results
+	sw, err := results.NewSelectWriter(w, format)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Formatting error: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", resultContentType(format))
+	w.WriteHeader(http.StatusOK)
+
+	// Not every ResponseWriter supports deadlines, and the server's write timeout applies to those that don't
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Now().Add(selectWriteTimeout))
+	// Don't leave the deadline on a kept-alive connection of a server without a write timeout
+	defer func() { _ = rc.SetWriteDeadline(time.Time{}) }()
+
+	vars := make([]string, len(cursor.Variables))
+	for i, v := range cursor.Variables {
+		vars[i] = v.Name
+	}
+	if err := sw.WriteHeader(vars); err != nil {
+		return
+	}
+	for ; more; more = cursor.Next() {
+		if err := sw.WriteBinding(cursor.Binding()); err != nil {
+			log.Printf("Error writing results: %v", err)
+			return
+		}
+		if cursor.Rows()%selectFlushRows == 0 {
+			if err := sw.Flush(); err != nil {
+				// The client went away
+				return
+			}
+			_ = rc.SetWriteDeadline(time.Now().Add(selectWriteTimeout))
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("Query failed after %d rows: %v", cursor.Rows(), err)
+		panic(http.ErrAbortHandler)
+	}
+	_ = sw.Close() // #nosec G104 - error writing response is logged elsewhere if needed
+}
+
+// resultContentType returns the media type of a SPARQL result format
+func resultContentType(format string) string {
 	switch format {
 	case "xml":
-		contentType = "application/sparql-results+xml; charset=utf-8"
+		return "application/sparql-results+xml; charset=utf-8"
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "tsv":
+		return "text/tab-separated-values; charset=utf-8"
+	default:
+		return "application/sparql-results+json; charset=utf-8"
+	}
+}
 
+// writeResult writes SELECT or ASK results in the specified format
+func (s *Server) writeResult(w http.ResponseWriter, result executor.QueryResult, format string) {
+	var data []byte
+	var err error
+	var contentType string
+
+	// Handle SELECT and ASK results
+	contentType = resultContentType(format)
+	switch format {
+	case "xml":
 		if selectResult, ok := result.(*executor.SelectResult); ok {
 			data, err = results.FormatSelectResultsXML(selectResult)
 		} else if askResult, ok := result.(*executor.AskResult); ok {
@@ -82,8 +310,6 @@ func (s *Server) writeResult(w http.ResponseWriter, result executor.QueryResult,
 		}
 
 	case "csv":
-		contentType = "text/csv; charset=utf-8"
-
 		if selectResult, ok := result.(*executor.SelectResult); ok {
 			data, err = results.FormatSelectResultsCSV(selectResult)
 		} else if askResult, ok := result.(*executor.AskResult); ok {
@@ -91,8 +317,6 @@ func (s *Server) writeResult(w http.ResponseWriter, result executor.QueryResult,
 		}
 
 	case "tsv":
-		contentType = "text/tab-separated-values; charset=utf-8"
-
 		if selectResult, ok := result.(*executor.SelectResult); ok {
 			data, err = results.FormatSelectResultsTSV(selectResult)
 		} else if askResult, ok := result.(*executor.AskResult); ok {
@@ -100,8 +324,6 @@ func (s *Server) writeResult(w http.ResponseWriter, result executor.QueryResult,
 		}
 
 	default: // json
-		contentType = "application/sparql-results+json; charset=utf-8"
-
 		if selectResult, ok := result.(*executor.SelectResult); ok {
 			data, err = results.FormatSelectResultsJSON(selectResult)
 		} else if askResult, ok := result.(*executor.AskResult); ok {
@@ -118,3 +340,55 @@ func (s *Server) writeResult(w http.ResponseWriter, result executor.QueryResult,
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(data) // #nosec G104 - error writing response is logged elsewhere if needed
 }
+
+// negotiateRDFContentType returns the first RDF serialization the Accept header names,
+// N-Triples when it names none
+func negotiateRDFContentType(acceptHeader string) string {
+	for _, part := range strings.Split(acceptHeader, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if _, err := rdf.NewSerializer(mediaType); err == nil {
+			return mediaType
+		}
+	}
+	return "application/n-triples"
+}
+
+// writeGraph writes the triples of a CONSTRUCT or DESCRIBE query in the RDF serialization
+// the Accept header asks for. JSON-LD is compacted with the JSON-LD context in the "context"
+// parameter, or framed with the frame in the "frame" parameter.
+func (s *Server) writeGraph(w http.ResponseWriter, r *http.Request, result *executor.ConstructResult) {
+	serializer, err := rdf.NewSerializer(negotiateRDFContentType(r.Header.Get("Accept")))
+	if err != nil {
+		s.writeError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+
+	if jsonld, ok := serializer.(*rdf.JSONLDSerializer); ok {
+		values := r.URL.Query()
+		if r.Form != nil {
+			values = r.Form
+		}
+		if context := values.Get("context"); context != "" {
+			if err := jsonld.SetContext([]byte(context)); err != nil {
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'context' parameter: %v", err))
+				return
+			}
+		}
+		if frame := values.Get("frame"); frame != "" {
+			if err := jsonld.SetFrame([]byte(frame)); err != nil {
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'frame' parameter: %v", err))
+				return
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := serializer.Serialize(&buf, result.Quads()); err != nil {
+		s.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Formatting error: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", serializer.ContentType()+"; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes()) // #nosec G104 - error writing response is logged elsewhere if needed
+}
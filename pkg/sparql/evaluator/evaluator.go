@@ -57,8 +57,8 @@ func (e *Evaluator) evaluateVariableExpression(expr *parser.VariableExpression,
 		return nil, fmt.Errorf("* is not a valid variable reference in expressions")
 	}
 
-	// Look up variable in binding
-	value, exists := binding.Vars[expr.Variable.Name]
+	// Look up variable in binding, decoding it if it is still encoded
+	value, exists := binding.Term(expr.Variable.Name)
 	if !exists {
 		return nil, fmt.Errorf("unbound variable: ?%s", expr.Variable.Name)
 	}
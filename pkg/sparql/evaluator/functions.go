@@ -70,6 +70,18 @@ func (e *Evaluator) evaluateFunctionCall(expr *parser.FunctionCallExpression, bi
 	case "ROUND":
 		return e.evaluateRound(expr.Arguments, binding)
 
+	// RDF 1.2 triple term functions
+	case "TRIPLE":
+		return e.evaluateTriple(expr.Arguments, binding)
+	case "SUBJECT":
+		return e.evaluateTripleComponent(expr.Arguments, binding, "SUBJECT")
+	case "PREDICATE":
+		return e.evaluateTripleComponent(expr.Arguments, binding, "PREDICATE")
+	case "OBJECT":
+		return e.evaluateTripleComponent(expr.Arguments, binding, "OBJECT")
+	case "ISTRIPLE":
+		return e.evaluateIsTriple(expr.Arguments, binding)
+
 	default:
 		// Check if it's a type casting function (IRI-based)
 		if strings.HasPrefix(funcName, "HTTP://WWW.W3.ORG/2001/XMLSCHEMA#") {
@@ -93,8 +105,7 @@ func (e *Evaluator) evaluateBound(args []parser.Expression, binding *store.Bindi
 		return nil, fmt.Errorf("BOUND requires a variable argument")
 	}
 
-	_, exists := binding.Vars[varExpr.Variable.Name]
-	return rdf.NewBooleanLiteral(exists), nil
+	return rdf.NewBooleanLiteral(binding.Bound(varExpr.Variable.Name)), nil
 }
 
 func (e *Evaluator) evaluateIsIRI(args []parser.Expression, binding *store.Binding) (rdf.Term, error) {
@@ -698,6 +709,73 @@ func (e *Evaluator) evaluateTypeCast(args []parser.Expression, binding *store.Bi
 	return rdf.NewLiteralWithDatatype(value, rdf.NewNamedNode(datatypeIRI)), nil
 }
 
+// RDF 1.2 triple term functions
+
+func (e *Evaluator) evaluateTriple(args []parser.Expression, binding *store.Binding) (rdf.Term, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("TRIPLE requires exactly 3 arguments")
+	}
+
+	var components [3]rdf.Term
+	for i, arg := range args {
+		term, err := e.Evaluate(arg, binding)
+		if err != nil {
+			return nil, err
+		}
+		components[i] = term
+	}
+
+	switch components[0].(type) {
+	case *rdf.NamedNode, *rdf.BlankNode:
+	default:
+		return nil, fmt.Errorf("TRIPLE subject must be an IRI or blank node")
+	}
+	if _, ok := components[1].(*rdf.NamedNode); !ok {
+		return nil, fmt.Errorf("TRIPLE predicate must be an IRI")
+	}
+
+	return &rdf.TripleTerm{Subject: components[0], Predicate: components[1], Object: components[2]}, nil
+}
+
+func (e *Evaluator) evaluateTripleComponent(args []parser.Expression, binding *store.Binding, component string) (rdf.Term, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("%s requires exactly 1 argument", component)
+	}
+
+	term, err := e.Evaluate(args[0], binding)
+	if err != nil {
+		return nil, err
+	}
+
+	tripleTerm, ok := term.(*rdf.TripleTerm)
+	if !ok {
+		return nil, fmt.Errorf("%s requires a triple term argument", component)
+	}
+
+	switch component {
+	case "SUBJECT":
+		return tripleTerm.Subject, nil
+	case "PREDICATE":
+		return tripleTerm.Predicate, nil
+	default:
+		return tripleTerm.Object, nil
+	}
+}
+
+func (e *Evaluator) evaluateIsTriple(args []parser.Expression, binding *store.Binding) (rdf.Term, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("isTRIPLE requires exactly 1 argument")
+	}
+
+	term, err := e.Evaluate(args[0], binding)
+	if err != nil {
+		return nil, err
+	}
+
+	_, isTriple := term.(*rdf.TripleTerm)
+	return rdf.NewBooleanLiteral(isTriple), nil
+}
+
 // termsEqual checks strict RDF term equality
 func (e *Evaluator) termsEqual(t1, t2 rdf.Term) bool {
 	// Compare types first
@@ -733,6 +811,12 @@ func (e *Evaluator) termsEqual(t1, t2 rdf.Term) bool {
 		}
 		return v1.Datatype.IRI == v2.Datatype.IRI
 
+	case *rdf.TripleTerm:
+		v2, ok := t2.(*rdf.TripleTerm)
+		return ok && e.termsEqual(v1.Subject, v2.Subject) &&
+			e.termsEqual(v1.Predicate, v2.Predicate) &&
+			e.termsEqual(v1.Object, v2.Object)
+
 	default:
 		return false
 	}
@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// Cursor streams the solutions of a SELECT query, one at a time.
+// Only the state of the plan's blocking operators (ORDER BY, hash join tables, DISTINCT)
+// is held, within the executor's memory budget where they can spill to disk, not the result.
+type Cursor struct {
+	// Variables are the projected variables, in query order
+	Variables []*parser.Variable
+
+	ctx     context.Context
+	iter    store.BindingIterator
+	done    func() // releases the query state
+	binding *store.Binding
+	rows    int64
+	closed  bool
+}
+
+// Select starts a SELECT query and returns a cursor over its solutions.
+// The caller must close the cursor.
+func (e *Executor) Select(ctx context.Context, query *optimizer.OptimizedQuery) (*Cursor, error) {
+	if query.Original.QueryType != parser.QueryTypeSelect {
+		return nil, fmt.Errorf("not a SELECT query")
+	}
+	ctx, done := e.startQuery(ctx)
+
+	iter, err := e.createIterator(ctx, query.Plan)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	// Determine variables list
+	variables := query.Original.Select.Variables
+	if variables == nil {
+		// SELECT * - extract variables from WHERE clause in order they appear
+		variables = extractVariablesFromGraphPattern(query.Original.Select.Where)
+	}
+
+	return &Cursor{
+		Variables: variables,
+		ctx:       ctx,
+		iter:      iter,
+		done:      done,
+	}, nil
+}
+
+// Next advances to the next solution
+// It returns false when the solutions are exhausted or the query was cancelled, see Err.
+func (c *Cursor) Next() bool {
+	if c.closed {
+		return false
+	}
+	if c.iter.Next() {
+		// Solutions leave the cursor decoded
+		binding := c.iter.Binding()
+		if err := binding.Materialize(); err != nil {
+			failQuery(c.ctx, fmt.Errorf("failed to decode solution: %w", err))
+			c.binding = nil
+			return false
+		}
+		c.binding = binding
+		c.rows++
+		return true
+	}
+	c.binding = nil
+	return false
+}
+
+// Binding returns the current solution, valid until the next call to Next
+func (c *Cursor) Binding() *store.Binding {
+	return c.binding
+}
+
+// Rows returns the number of solutions returned so far
+func (c *Cursor) Rows() int64 {
+	return c.rows
+}
+
+// Err returns the error that ended the iteration early, such as the query timing out
+// or a spill file failing
+func (c *Cursor) Err() error {
+	return queryError(c.ctx)
+}
+
+// Close releases the iterators of the query
+func (c *Cursor) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	err := c.iter.Close()
+	c.done()
+	return err
+}
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -15,26 +16,42 @@ import (
 // Executor executes SPARQL queries using the Volcano iterator model
 type Executor struct {
 	store *store.TripleStore
+
+	// graph constrains all scans inside a GRAPH pattern, nil for the default graph
+	graph *parser.GraphTerm
+
+	// Memory each sort, DISTINCT and GROUP BY may hold before spilling to files in tempDir
+	memoryBudget int64
+	tempDir      string
+
+	// Number of goroutines a query may run on, see SetParallelism
+	parallelism int
 }
 
 // NewExecutor creates a new query executor
 func NewExecutor(store *store.TripleStore) *Executor {
 	return &Executor{
-		store: store,
+		store:        store,
+		memoryBudget: DefaultMemoryBudget,
+		parallelism:  1,
 	}
 }
 
 // Execute executes an optimized query
-func (e *Executor) Execute(query *optimizer.OptimizedQuery) (QueryResult, error) {
+// Execution stops with ctx.Err() once ctx is cancelled or its deadline passes
+func (e *Executor) Execute(ctx context.Context, query *optimizer.OptimizedQuery) (QueryResult, error) {
+	ctx, done := e.startQuery(ctx)
+	defer done()
+
 	switch query.Original.QueryType {
 	case parser.QueryTypeSelect:
-		return e.executeSelect(query)
+		return e.executeSelect(ctx, query)
 	case parser.QueryTypeAsk:
-		return e.executeAsk(query)
+		return e.executeAsk(ctx, query)
 	case parser.QueryTypeConstruct:
-		return e.executeConstruct(query)
+		return e.executeConstruct(ctx, query)
 	case parser.QueryTypeDescribe:
-		return e.executeDescribe(query)
+		return e.executeDescribe(ctx, query)
 	default:
 		return nil, fmt.Errorf("unsupported query type")
 	}
@@ -76,89 +93,99 @@ type Triple struct {
 type Term struct {
 	Type  string // "iri", "blank", "literal"
 	Value string
+	RDF   rdf.Term // the term itself, with the datatype and language of literals
 }
 
 func (r *ConstructResult) resultType() {}
 
-// executeSelect executes a SELECT query
-func (e *Executor) executeSelect(query *optimizer.OptimizedQuery) (*SelectResult, error) {
-	// Create iterator from plan
-	iter, err := e.createIterator(query.Plan)
+// Quads returns the triples of the result as quads in the default graph, for the RDF serializers
+func (r *ConstructResult) Quads() []*rdf.Quad {
+	quads := make([]*rdf.Quad, len(r.Triples))
+	for i, t := range r.Triples {
+		quads[i] = rdf.NewQuad(t.Subject.RDF, t.Predicate.RDF, t.Object.RDF, rdf.NewDefaultGraph())
+	}
+	return quads
+}
+
+// executeSelect executes a SELECT query, collecting all solutions of its cursor
+func (e *Executor) executeSelect(ctx context.Context, query *optimizer.OptimizedQuery) (*SelectResult, error) {
+	cursor, err := e.Select(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	defer iter.Close()
+	defer cursor.Close()
 
 	// Collect all bindings
 	var bindings []*store.Binding
-	for iter.Next() {
-		binding := iter.Binding()
+	for cursor.Next() {
 		// Clone to avoid mutation
-		bindings = append(bindings, binding.Clone())
-	}
-
-	// Determine variables list
-	variables := query.Original.Select.Variables
-	if variables == nil {
-		// SELECT * - extract variables from WHERE clause in order they appear
-		variables = extractVariablesFromGraphPattern(query.Original.Select.Where)
-	}
-
-	// Apply DISTINCT if specified
-	if query.Original.Select.Distinct {
-		bindings = applyDistinct(bindings)
+		bindings = append(bindings, cursor.Binding().Clone())
 	}
-
-	// Apply REDUCED if specified (relaxed de-duplication)
-	if query.Original.Select.Reduced {
-		bindings = applyReduced(bindings)
+	if err := cursor.Err(); err != nil {
+		return nil, err
 	}
 
 	return &SelectResult{
-		Variables: variables,
+		Variables: cursor.Variables,
 		Bindings:  bindings,
 	}, nil
 }
 
-// applyDistinct removes duplicate bindings
-func applyDistinct(bindings []*store.Binding) []*store.Binding {
-	if len(bindings) == 0 {
-		return bindings
-	}
+// bindingKey identifies the variables and values of a binding, for DISTINCT and grouping.
+// Unlike join keys, which are checked with SameTerm, equal keys must mean the same terms.
+func bindingKey(ts *store.TripleStore, binding *store.Binding) string {
+	names := binding.Names()
+	sort.Strings(names)
 
-	seen := make(map[string]bool)
-	var unique []*store.Binding
-
-	for _, binding := range bindings {
-		// Create a signature for this binding
-		sig := bindingSignature(binding)
-		if !seen[sig] {
-			seen[sig] = true
-			unique = append(unique, binding)
-		}
+	var key []byte
+	for _, name := range names {
+		key = append(key, name...)
+		key = append(key, '=')
+		key = appendTermIdentity(key, ts, binding, name)
+		key = append(key, ';')
 	}
+	return string(key)
+}
 
-	return unique
+// appendTermIdentity appends a key for a variable's value that only equal terms share.
+// Encoded IDs are used for the term types the encoder keeps the lexical form of, so
+// those values are never decoded; numbers, booleans, dates and triple terms are encoded
+// by value ("01"^^xsd:integer and 1 share an ID) and are keyed by their signature.
+func appendTermIdentity(key []byte, ts *store.TripleStore, binding *store.Binding, name string) []byte {
+	if encoded, ok := binding.Encoded(name); ok && encodesLexicalForm(encoded) {
+		return append(key, encoded[:]...)
+	}
+	term, _ := binding.Term(name)
+	if encoded, err := ts.EncodeTerm(term); err == nil && encodesLexicalForm(encoded) {
+		return append(key, encoded[:]...)
+	}
+	key = append(key, 0)
+	return append(key, termSignature(term)...)
 }
 
-// applyReduced is a hint that duplicates MAY be removed but are not required to be removed.
-// Per SPARQL spec, REDUCED permits but does not require elimination of duplicates.
-// For simplicity and correctness, we keep all duplicates (no-op).
-func applyReduced(bindings []*store.Binding) []*store.Binding {
-	// REDUCED is a hint to the query engine that it MAY eliminate duplicates
-	// but is not required to. To ensure test compatibility, we don't remove any.
-	return bindings
+// encodesLexicalForm reports whether an encoded term identifies a single RDF term
+func encodesLexicalForm(encoded store.EncodedTerm) bool {
+	switch rdf.TermType(encoded[0]) {
+	case rdf.TermTypeNamedNode, rdf.TermTypeBlankNode, rdf.TermTypeStringLiteral,
+		rdf.TermTypeLangStringLiteral, rdf.TermTypeTypedLiteral, rdf.TermTypeDefaultGraph:
+		return true
+	}
+	return false
 }
 
-// bindingSignature creates a unique string representation of a binding
-func bindingSignature(binding *store.Binding) string {
-	var parts []string
-	for varName, term := range binding.Vars {
-		parts = append(parts, varName+"="+termSignature(term))
+// appendTermKey appends the encoded ID of a variable's value to a key, encoding decoded terms
+func appendTermKey(key []byte, ts *store.TripleStore, binding *store.Binding, name string) []byte {
+	if encoded, ok := binding.Encoded(name); ok {
+		return append(key, encoded[:]...)
+	}
+	term, _ := binding.Term(name)
+	encoded, err := ts.EncodeTerm(term)
+	if err != nil {
+		// Terms the store can't encode are keyed by their signature instead
+		key = append(key, 0)
+		return append(key, termSignature(term)...)
 	}
-	// Sort to ensure consistent ordering
-	sort.Strings(parts)
-	return strings.Join(parts, ";")
+	return append(key, encoded[:]...)
 }
 
 // termSignature creates a unique string representation of an RDF term
@@ -177,15 +204,17 @@ func termSignature(term rdf.Term) string {
 			sig += "^^" + t.Datatype.IRI
 		}
 		return sig
+	case *rdf.TripleTerm:
+		return "triple:" + t.String()
 	default:
 		return "unknown:" + fmt.Sprintf("%v", term)
 	}
 }
 
 // executeAsk executes an ASK query
-func (e *Executor) executeAsk(query *optimizer.OptimizedQuery) (*AskResult, error) {
+func (e *Executor) executeAsk(ctx context.Context, query *optimizer.OptimizedQuery) (*AskResult, error) {
 	// Create iterator from plan
-	iter, err := e.createIterator(query.Plan)
+	iter, err := e.createIterator(ctx, query.Plan)
 	if err != nil {
 		return nil, err
 	}
@@ -193,12 +222,15 @@ func (e *Executor) executeAsk(query *optimizer.OptimizedQuery) (*AskResult, erro
 
 	// Check if there's at least one result
 	result := iter.Next()
+	if err := queryError(ctx); err != nil {
+		return nil, err
+	}
 
 	return &AskResult{Result: result}, nil
 }
 
 // executeConstruct executes a CONSTRUCT query
-func (e *Executor) executeConstruct(query *optimizer.OptimizedQuery) (*ConstructResult, error) {
+func (e *Executor) executeConstruct(ctx context.Context, query *optimizer.OptimizedQuery) (*ConstructResult, error) {
 	// Get the template from the construct plan
 	constructPlan, ok := query.Plan.(*optimizer.ConstructPlan)
 	if !ok {
@@ -206,7 +238,7 @@ func (e *Executor) executeConstruct(query *optimizer.OptimizedQuery) (*Construct
 	}
 
 	// Create iterator from the input plan (WHERE clause)
-	iter, err := e.createIterator(constructPlan.Input)
+	iter, err := e.createIterator(ctx, constructPlan.Input)
 	if err != nil {
 		return nil, err
 	}
@@ -235,12 +267,15 @@ func (e *Executor) executeConstruct(query *optimizer.OptimizedQuery) (*Construct
 			}
 		}
 	}
+	if err := queryError(ctx); err != nil {
+		return nil, err
+	}
 
 	return &ConstructResult{Triples: triples}, nil
 }
 
 // executeDescribe executes a DESCRIBE query
-func (e *Executor) executeDescribe(query *optimizer.OptimizedQuery) (*ConstructResult, error) {
+func (e *Executor) executeDescribe(ctx context.Context, query *optimizer.OptimizedQuery) (*ConstructResult, error) {
 	// Get the describe plan
 	describePlan, ok := query.Plan.(*optimizer.DescribePlan)
 	if !ok {
@@ -252,7 +287,7 @@ func (e *Executor) executeDescribe(query *optimizer.OptimizedQuery) (*ConstructR
 
 	if describePlan.Input != nil {
 		// Execute WHERE clause to find resources dynamically
-		iter, err := e.createIterator(describePlan.Input)
+		iter, err := e.createIterator(ctx, describePlan.Input)
 		if err != nil {
 			return nil, err
 		}
@@ -262,8 +297,12 @@ func (e *Executor) executeDescribe(query *optimizer.OptimizedQuery) (*ConstructR
 		seen := make(map[string]bool)
 		for iter.Next() {
 			binding := iter.Binding()
-			// Add all bound IRIs (named nodes) to resources
-			for _, term := range binding.Vars {
+			// Add all bound IRIs (named nodes) to resources, decoding only those
+			for _, name := range binding.Names() {
+				if encoded, ok := binding.Encoded(name); ok && rdf.TermType(encoded[0]) != rdf.TermTypeNamedNode {
+					continue
+				}
+				term, _ := binding.Term(name)
 				if namedNode, ok := term.(*rdf.NamedNode); ok {
 					key := namedNode.IRI
 					if !seen[key] {
@@ -273,6 +312,9 @@ func (e *Executor) executeDescribe(query *optimizer.OptimizedQuery) (*ConstructR
 				}
 			}
 		}
+		if err := queryError(ctx); err != nil {
+			return nil, err
+		}
 	} else {
 		// Use resources directly from DESCRIBE clause
 		for _, resource := range describePlan.Resources {
@@ -290,10 +332,9 @@ func (e *Executor) executeDescribe(query *optimizer.OptimizedQuery) (*ConstructR
 			Subject:   resource,
 			Predicate: &store.Variable{Name: "p"},
 			Object:    &store.Variable{Name: "o"},
-			Graph:     &store.Variable{Name: "g"},
 		}
 
-		iter, err := e.store.Query(pattern)
+		iter, err := e.store.Query(ctx, pattern)
 		if err != nil {
 			return nil, fmt.Errorf("failed to query store for resource %s: %w", resource.String(), err)
 		}
@@ -309,8 +350,8 @@ func (e *Executor) executeDescribe(query *optimizer.OptimizedQuery) (*ConstructR
 
 			// Convert to executor.Triple
 			triple := &Triple{
-				Subject:   Term{Type: "iri", Value: quad.Subject.String()},
-				Predicate: Term{Type: "iri", Value: quad.Predicate.String()},
+				Subject:   e.rdfTermToExecutorTerm(quad.Subject),
+				Predicate: e.rdfTermToExecutorTerm(quad.Predicate),
 				Object:    e.rdfTermToExecutorTerm(quad.Object),
 			}
 
@@ -325,6 +366,9 @@ func (e *Executor) executeDescribe(query *optimizer.OptimizedQuery) (*ConstructR
 			return nil, fmt.Errorf("error closing iterator: %w", err)
 		}
 	}
+	if err := queryError(ctx); err != nil {
+		return nil, err
+	}
 
 	return &ConstructResult{Triples: triples}, nil
 }
@@ -333,13 +377,15 @@ func (e *Executor) executeDescribe(query *optimizer.OptimizedQuery) (*ConstructR
 func (e *Executor) rdfTermToExecutorTerm(term rdf.Term) Term {
 	switch t := term.(type) {
 	case *rdf.NamedNode:
-		return Term{Type: "iri", Value: t.IRI}
+		return Term{Type: "iri", Value: t.IRI, RDF: t}
 	case *rdf.BlankNode:
-		return Term{Type: "blank", Value: t.ID}
+		return Term{Type: "blank", Value: t.ID, RDF: t}
 	case *rdf.Literal:
-		return Term{Type: "literal", Value: t.Value}
+		return Term{Type: "literal", Value: t.Value, RDF: t}
+	case *rdf.TripleTerm:
+		return Term{Type: "triple", Value: t.String(), RDF: t}
 	default:
-		return Term{Type: "literal", Value: term.String()}
+		return Term{Type: "literal", Value: term.String(), RDF: term}
 	}
 }
 
@@ -369,99 +415,153 @@ func (e *Executor) instantiateTriplePattern(pattern *parser.TriplePattern, bindi
 
 // instantiateTerm converts a TermOrVariable to a concrete Term using bindings
 func (e *Executor) instantiateTerm(termOrVar parser.TermOrVariable, binding *store.Binding) (Term, error) {
+	term, err := instantiateRDFTerm(termOrVar, binding)
+	if err != nil {
+		return Term{}, err
+	}
+	return e.rdfTermToExecutorTerm(term), nil
+}
+
+// instantiateRDFTerm substitutes bound variables, including inside triple term patterns
+func instantiateRDFTerm(termOrVar parser.TermOrVariable, binding *store.Binding) (rdf.Term, error) {
 	if termOrVar.IsVariable() {
 		// Look up variable in binding
-		value, found := binding.Vars[termOrVar.Variable.Name]
+		value, found := binding.Term(termOrVar.Variable.Name)
 		if !found {
-			return Term{}, fmt.Errorf("unbound variable: %s", termOrVar.Variable.Name)
+			return nil, fmt.Errorf("unbound variable: %s", termOrVar.Variable.Name)
+		}
+		return value, nil
+	}
+
+	if termOrVar.IsTripleTerm() {
+		var components [3]rdf.Term
+		for i, component := range []parser.TermOrVariable{
+			termOrVar.TripleTerm.Subject, termOrVar.TripleTerm.Predicate, termOrVar.TripleTerm.Object,
+		} {
+			term, err := instantiateRDFTerm(component, binding)
+			if err != nil {
+				return nil, err
+			}
+			components[i] = term
 		}
-		return e.rdfTermToExecutorTerm(value), nil
+		return &rdf.TripleTerm{Subject: components[0], Predicate: components[1], Object: components[2]}, nil
 	}
 
 	// It's a constant term
-	return e.rdfTermToExecutorTerm(termOrVar.Term), nil
+	return termOrVar.Term, nil
 }
 
 // createIterator creates an iterator from a query plan
-func (e *Executor) createIterator(plan optimizer.QueryPlan) (store.BindingIterator, error) {
+func (e *Executor) createIterator(ctx context.Context, plan optimizer.QueryPlan) (store.BindingIterator, error) {
+	it, err := e.createPlanIterator(ctx, plan)
+	if err != nil {
+		return nil, err
+	}
+	return profileIterator(ctx, plan, it), nil
+}
+
+// createPlanIterator creates the iterator of a plan node's operator
+func (e *Executor) createPlanIterator(ctx context.Context, plan optimizer.QueryPlan) (store.BindingIterator, error) {
+	if scan, ok := partitionedScan(ctx, plan); ok {
+		it, ok, err := e.createPartitionedIterator(ctx, plan, scan)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return it, nil
+		}
+	}
+
 	switch p := plan.(type) {
 	case *optimizer.ScanPlan:
-		return e.createScanIterator(p)
+		return e.createScanIterator(ctx, p)
 	case *optimizer.JoinPlan:
-		return e.createJoinIterator(p)
+		return e.newJoinIterator(ctx, p)
+	case *optimizer.LeapfrogJoinPlan:
+		return e.createLeapfrogJoinIterator(ctx, p)
 	case *optimizer.FilterPlan:
-		return e.createFilterIterator(p)
+		return e.createFilterIterator(ctx, p)
 	case *optimizer.ProjectionPlan:
-		return e.createProjectionIterator(p)
+		return e.createProjectionIterator(ctx, p)
 	case *optimizer.LimitPlan:
-		return e.createLimitIterator(p)
+		return e.createLimitIterator(ctx, p)
 	case *optimizer.OffsetPlan:
-		return e.createOffsetIterator(p)
+		return e.createOffsetIterator(ctx, p)
 	case *optimizer.DistinctPlan:
-		return e.createDistinctIterator(p)
+		return e.createDistinctIterator(ctx, p)
 	case *optimizer.GraphPlan:
-		return e.createGraphIterator(p)
+		return e.createGraphIterator(ctx, p)
 	case *optimizer.BindPlan:
-		return e.createBindIterator(p)
+		return e.createBindIterator(ctx, p)
 	case *optimizer.OptionalPlan:
-		return e.createOptionalIterator(p)
+		return e.createOptionalIterator(ctx, p)
 	case *optimizer.UnionPlan:
-		return e.createUnionIterator(p)
+		return e.createUnionIterator(ctx, p)
 	case *optimizer.MinusPlan:
-		return e.createMinusIterator(p)
+		return e.createMinusIterator(ctx, p)
 	case *optimizer.OrderByPlan:
-		return e.createOrderByIterator(p)
+		return e.createOrderByIterator(ctx, p)
+	case *optimizer.GroupPlan:
+		return e.createGroupIterator(ctx, p)
 	default:
 		return nil, fmt.Errorf("unsupported plan type: %T", plan)
 	}
 }
 
 // createScanIterator creates an iterator for scanning a triple pattern
-func (e *Executor) createScanIterator(plan *optimizer.ScanPlan) (store.BindingIterator, error) {
-	// Convert parser triple pattern to store pattern
-	pattern := &store.Pattern{
-		Subject:   e.convertTermOrVariable(plan.Pattern.Subject),
-		Predicate: e.convertTermOrVariable(plan.Pattern.Predicate),
-		Object:    e.convertTermOrVariable(plan.Pattern.Object),
+func (e *Executor) createScanIterator(ctx context.Context, plan *optimizer.ScanPlan) (store.BindingIterator, error) {
+	pattern := e.storePattern(plan)
+	var graphVar *parser.Variable
+	if e.graph != nil {
+		graphVar = e.graph.Variable
+	}
+
+	// Execute pattern query, over one key range in a partition of a parallel scan
+	var quadIter store.QuadIterator
+	var err error
+	if part, ok := ctx.Value(scanRangeKey{}).(*scanRange); ok && part.scan == plan {
+		quadIter, err = e.store.QueryRange(ctx, pattern, part.keys)
+	} else {
+		quadIter, err = e.store.Query(ctx, pattern)
 	}
-
-	// Execute pattern query
-	quadIter, err := e.store.Query(pattern)
 	if err != nil {
 		return nil, err
 	}
 
-	return &scanIterator{
+	it := &scanIterator{
 		quadIter: quadIter,
 		pattern:  plan.Pattern,
+		graphVar: graphVar,
 		binding:  store.NewBinding(),
-	}, nil
-}
+	}
 
-// createJoinIterator creates an iterator for join operations
-func (e *Executor) createJoinIterator(plan *optimizer.JoinPlan) (store.BindingIterator, error) {
-	left, err := e.createIterator(plan.Left)
-	if err != nil {
-		return nil, err
+	// Bind the encoded terms of the index keys, decoded only when a value is needed.
+	// Triple term patterns match the components of decoded terms.
+	if encoded, ok := quadIter.(store.EncodedQuadIterator); ok && !hasTripleTermPattern(plan.Pattern) {
+		if terms := termCache(ctx); terms != nil {
+			it.encoded = encoded
+			it.terms = terms
+		}
 	}
+	return it, nil
+}
 
-	switch plan.Type {
-	case optimizer.JoinTypeNestedLoop:
-		return &nestedLoopJoinIterator{
-			left:         left,
-			rightPlan:    plan.Right,
-			executor:     e,
-			currentLeft:  nil,
-			currentRight: nil,
-		}, nil
-	default:
-		return nil, fmt.Errorf("unsupported join type: %v", plan.Type)
+// storePattern converts the triple pattern of a scan to a store pattern in the executor's graph
+func (e *Executor) storePattern(plan *optimizer.ScanPlan) *store.Pattern {
+	pattern := &store.Pattern{
+		Subject:   e.convertTermOrVariable(plan.Pattern.Subject),
+		Predicate: e.convertTermOrVariable(plan.Pattern.Predicate),
+		Object:    e.convertTermOrVariable(plan.Pattern.Object),
 	}
+	if e.graph != nil {
+		pattern.Graph = e.convertGraphTerm(e.graph)
+	}
+	return pattern
 }
 
 // createFilterIterator creates an iterator for filter operations
-func (e *Executor) createFilterIterator(plan *optimizer.FilterPlan) (store.BindingIterator, error) {
-	input, err := e.createIterator(plan.Input)
+func (e *Executor) createFilterIterator(ctx context.Context, plan *optimizer.FilterPlan) (store.BindingIterator, error) {
+	input, err := e.createIterator(ctx, plan.Input)
 	if err != nil {
 		return nil, err
 	}
@@ -474,8 +574,8 @@ func (e *Executor) createFilterIterator(plan *optimizer.FilterPlan) (store.Bindi
 }
 
 // createProjectionIterator creates an iterator for projection operations
-func (e *Executor) createProjectionIterator(plan *optimizer.ProjectionPlan) (store.BindingIterator, error) {
-	input, err := e.createIterator(plan.Input)
+func (e *Executor) createProjectionIterator(ctx context.Context, plan *optimizer.ProjectionPlan) (store.BindingIterator, error) {
+	input, err := e.createIterator(ctx, plan.Input)
 	if err != nil {
 		return nil, err
 	}
@@ -487,8 +587,8 @@ func (e *Executor) createProjectionIterator(plan *optimizer.ProjectionPlan) (sto
 }
 
 // createLimitIterator creates an iterator for LIMIT operations
-func (e *Executor) createLimitIterator(plan *optimizer.LimitPlan) (store.BindingIterator, error) {
-	input, err := e.createIterator(plan.Input)
+func (e *Executor) createLimitIterator(ctx context.Context, plan *optimizer.LimitPlan) (store.BindingIterator, error) {
+	input, err := e.createIterator(ctx, plan.Input)
 	if err != nil {
 		return nil, err
 	}
@@ -501,8 +601,8 @@ func (e *Executor) createLimitIterator(plan *optimizer.LimitPlan) (store.Binding
 }
 
 // createOffsetIterator creates an iterator for OFFSET operations
-func (e *Executor) createOffsetIterator(plan *optimizer.OffsetPlan) (store.BindingIterator, error) {
-	input, err := e.createIterator(plan.Input)
+func (e *Executor) createOffsetIterator(ctx context.Context, plan *optimizer.OffsetPlan) (store.BindingIterator, error) {
+	input, err := e.createIterator(ctx, plan.Input)
 	if err != nil {
 		return nil, err
 	}
@@ -515,15 +615,16 @@ func (e *Executor) createOffsetIterator(plan *optimizer.OffsetPlan) (store.Bindi
 }
 
 // createDistinctIterator creates an iterator for DISTINCT operations
-func (e *Executor) createDistinctIterator(plan *optimizer.DistinctPlan) (store.BindingIterator, error) {
-	input, err := e.createIterator(plan.Input)
+func (e *Executor) createDistinctIterator(ctx context.Context, plan *optimizer.DistinctPlan) (store.BindingIterator, error) {
+	input, err := e.createIterator(ctx, plan.Input)
 	if err != nil {
 		return nil, err
 	}
 
 	return &distinctIterator{
-		input: input,
-		seen:  make(map[string]bool),
+		ctx:      ctx,
+		input:    input,
+		distinct: e.newHashDistinct(ctx),
 	}, nil
 }
 
@@ -532,6 +633,13 @@ func (e *Executor) convertTermOrVariable(tov parser.TermOrVariable) any {
 	if tov.IsVariable() {
 		return store.NewVariable(tov.Variable.Name)
 	}
+	if tov.IsTripleTerm() {
+		return &store.TripleTermPattern{
+			Subject:   e.convertTermOrVariable(tov.TripleTerm.Subject),
+			Predicate: e.convertTermOrVariable(tov.TripleTerm.Predicate),
+			Object:    e.convertTermOrVariable(tov.TripleTerm.Object),
+		}
+	}
 	return tov.Term
 }
 
@@ -539,7 +647,12 @@ func (e *Executor) convertTermOrVariable(tov parser.TermOrVariable) any {
 type scanIterator struct {
 	quadIter store.QuadIterator
 	pattern  *parser.TriplePattern
+	graphVar *parser.Variable // bound to the quad's graph inside GRAPH ?g
 	binding  *store.Binding
+
+	// Set when variables are bound to encoded terms
+	encoded store.EncodedQuadIterator
+	terms   *store.TermCache
 }
 
 func (it *scanIterator) Next() bool {
@@ -548,6 +661,13 @@ func (it *scanIterator) Next() bool {
 			return false
 		}
 
+		if it.encoded != nil {
+			if it.bindEncoded() {
+				return true
+			}
+			continue
+		}
+
 		quad, err := it.quadIter.Quad()
 		if err != nil {
 			return false
@@ -555,38 +675,11 @@ func (it *scanIterator) Next() bool {
 
 		// Bind variables, checking for repeated variables
 		it.binding = store.NewBinding()
-		valid := true
-
-		// Bind subject
-		if it.pattern.Subject.IsVariable() {
-			varName := it.pattern.Subject.Variable.Name
-			it.binding.Vars[varName] = quad.Subject
-		}
-
-		// Bind predicate (check if variable already bound from subject)
-		if it.pattern.Predicate.IsVariable() {
-			varName := it.pattern.Predicate.Variable.Name
-			if existingValue, exists := it.binding.Vars[varName]; exists {
-				// Variable already bound - check if values match
-				if !existingValue.Equals(quad.Predicate) {
-					valid = false
-				}
-			} else {
-				it.binding.Vars[varName] = quad.Predicate
-			}
-		}
-
-		// Bind object (check if variable already bound from subject or predicate)
-		if valid && it.pattern.Object.IsVariable() {
-			varName := it.pattern.Object.Variable.Name
-			if existingValue, exists := it.binding.Vars[varName]; exists {
-				// Variable already bound - check if values match
-				if !existingValue.Equals(quad.Object) {
-					valid = false
-				}
-			} else {
-				it.binding.Vars[varName] = quad.Object
-			}
+		valid := bindTerm(it.binding, it.pattern.Subject, quad.Subject) &&
+			bindTerm(it.binding, it.pattern.Predicate, quad.Predicate) &&
+			bindTerm(it.binding, it.pattern.Object, quad.Object)
+		if valid && it.graphVar != nil {
+			valid = bindTerm(it.binding, parser.TermOrVariable{Variable: it.graphVar}, quad.Graph)
 		}
 
 		// If all variable constraints are satisfied, return this binding
@@ -597,87 +690,71 @@ func (it *scanIterator) Next() bool {
 	}
 }
 
-func (it *scanIterator) Binding() *store.Binding {
-	return it.binding
-}
-
-func (it *scanIterator) Close() error {
-	return it.quadIter.Close()
-}
-
-// nestedLoopJoinIterator implements nested loop join
-type nestedLoopJoinIterator struct {
-	left         store.BindingIterator
-	rightPlan    optimizer.QueryPlan
-	executor     *Executor
-	currentLeft  *store.Binding
-	currentRight store.BindingIterator
-	result       *store.Binding
-}
-
-func (it *nestedLoopJoinIterator) Next() bool {
-	for {
-		// If we have a right iterator, try to get next from it
-		if it.currentRight != nil {
-			if it.currentRight.Next() {
-				rightBinding := it.currentRight.Binding()
-
-				// Merge bindings
-				merged := it.mergeBindings(it.currentLeft, rightBinding)
-				if merged != nil {
-					it.result = merged
-					return true
-				}
-				continue
-			}
-			// Right exhausted, close it
-			_ = it.currentRight.Close() // #nosec G104 - close error doesn't affect iteration logic
-			it.currentRight = nil
+// bindEncoded binds the variables of the pattern to the encoded terms of the current quad
+// Returns false if a repeated variable doesn't match
+func (it *scanIterator) bindEncoded() bool {
+	quad, err := it.encoded.EncodedQuad()
+	if err != nil {
+		return false
+	}
+	it.binding = store.NewBinding()
+	positions := []parser.TermOrVariable{it.pattern.Subject, it.pattern.Predicate, it.pattern.Object}
+	if it.graphVar != nil {
+		positions = append(positions, parser.TermOrVariable{Variable: it.graphVar})
+	}
+	for i, position := range positions {
+		if !position.IsVariable() {
+			continue
 		}
-
-		// Get next from left
-		if !it.left.Next() {
-			return false
+		name := position.Variable.Name
+		if existing, ok := it.binding.Encoded(name); ok {
+			if existing != quad[i] {
+				return false
+			}
+			continue
 		}
+		it.binding.SetEncoded(name, quad[i], it.terms)
+	}
+	return true
+}
 
-		it.currentLeft = it.left.Binding()
+// hasTripleTermPattern reports whether a triple pattern has a triple term position
+func hasTripleTermPattern(pattern *parser.TriplePattern) bool {
+	return pattern.Subject.IsTripleTerm() || pattern.Predicate.IsTripleTerm() || pattern.Object.IsTripleTerm()
+}
 
-		// Create new right iterator (with current left binding applied)
-		rightIter, err := it.executor.createIterator(it.rightPlan)
-		if err != nil {
+// bindTerm binds the variables of a pattern position to a matched term
+// Returns false if a repeated variable or a triple term component doesn't match
+func bindTerm(binding *store.Binding, pattern parser.TermOrVariable, value rdf.Term) bool {
+	if pattern.IsTripleTerm() {
+		tripleTerm, ok := value.(*rdf.TripleTerm)
+		if !ok {
 			return false
 		}
-		it.currentRight = rightIter
+		return bindTerm(binding, pattern.TripleTerm.Subject, tripleTerm.Subject) &&
+			bindTerm(binding, pattern.TripleTerm.Predicate, tripleTerm.Predicate) &&
+			bindTerm(binding, pattern.TripleTerm.Object, tripleTerm.Object)
 	}
-}
 
-func (it *nestedLoopJoinIterator) Binding() *store.Binding {
-	return it.result
-}
+	if !pattern.IsVariable() {
+		return true
+	}
 
-func (it *nestedLoopJoinIterator) Close() error {
-	if it.currentRight != nil {
-		_ = it.currentRight.Close() // #nosec G104 - right close error less critical than left close error
+	varName := pattern.Variable.Name
+	if existingValue, exists := binding.Term(varName); exists {
+		// Variable already bound - check if values match
+		return existingValue.Equals(value)
 	}
-	return it.left.Close()
+	binding.Set(varName, value)
+	return true
 }
 
-// mergeBindings merges two bindings, returns nil if incompatible
-func (it *nestedLoopJoinIterator) mergeBindings(left, right *store.Binding) *store.Binding {
-	result := left.Clone()
-
-	for varName, term := range right.Vars {
-		if existingTerm, exists := result.Vars[varName]; exists {
-			// Check compatibility
-			if !existingTerm.Equals(term) {
-				return nil
-			}
-		} else {
-			result.Vars[varName] = term
-		}
-	}
+func (it *scanIterator) Binding() *store.Binding {
+	return it.binding
+}
 
-	return result
+func (it *scanIterator) Close() error {
+	return it.quadIter.Close()
 }
 
 // filterIterator implements filter operations
@@ -689,35 +766,37 @@ type filterIterator struct {
 
 func (it *filterIterator) Next() bool {
 	for it.input.Next() {
-		binding := it.input.Binding()
-
-		// If no expression, pass through (shouldn't happen)
-		if it.filter.Expression == nil {
+		if evaluateFilter(it.evaluator, it.filter, it.input.Binding()) {
 			return true
 		}
+	}
+	return false
+}
 
-		// Evaluate the filter expression
-		result, err := it.evaluator.Evaluate(it.filter.Expression, binding)
-		if err != nil {
-			// Expression evaluation error - filter out this binding
-			continue
-		}
+// evaluateFilter reports whether a filter accepts a binding
+func evaluateFilter(ev *evaluator.Evaluator, filter *parser.Filter, binding *store.Binding) bool {
+	// If no expression, pass through (shouldn't happen)
+	if filter.Expression == nil {
+		return true
+	}
 
-		// Check effective boolean value
-		lit, ok := result.(*rdf.Literal)
-		if !ok {
-			// Non-literal result - filter out
-			continue
-		}
+	// Evaluate the filter expression
+	result, err := ev.Evaluate(filter.Expression, binding)
+	if err != nil {
+		// Expression evaluation error - filter out this binding
+		return false
+	}
 
-		// Check if it's a boolean literal with value true
-		if lit.Datatype != nil && lit.Datatype.IRI == "http://www.w3.org/2001/XMLSchema#boolean" {
-			if lit.Value == "true" || lit.Value == "1" {
-				return true
-			}
-		}
+	// Check effective boolean value
+	lit, ok := result.(*rdf.Literal)
+	if !ok {
+		// Non-literal result - filter out
+		return false
+	}
 
-		// Not true - continue to next binding
+	// Check if it's a boolean literal with value true
+	if lit.Datatype != nil && lit.Datatype.IRI == "http://www.w3.org/2001/XMLSchema#boolean" {
+		return lit.Value == "true" || lit.Value == "1"
 	}
 	return false
 }
@@ -746,17 +825,12 @@ func (it *projectionIterator) Binding() *store.Binding {
 		return it.input.Binding()
 	}
 
-	// Project only selected variables
-	binding := store.NewBinding()
-	inputBinding := it.input.Binding()
-
-	for _, variable := range it.variables {
-		if term, exists := inputBinding.Vars[variable.Name]; exists {
-			binding.Vars[variable.Name] = term
-		}
+	// Project only selected variables, leaving them encoded
+	names := make([]string, len(it.variables))
+	for i, variable := range it.variables {
+		names[i] = variable.Name
 	}
-
-	return binding
+	return it.input.Binding().Project(names)
 }
 
 func (it *projectionIterator) Close() error {
@@ -819,194 +893,153 @@ func (it *offsetIterator) Close() error {
 }
 
 // createGraphIterator creates an iterator for a GRAPH pattern
-func (e *Executor) createGraphIterator(plan *optimizer.GraphPlan) (store.BindingIterator, error) {
-	// The GRAPH pattern wraps the inner plan and constrains all scans to a specific graph
-	// We need to wrap this by creating a modified executor that adds graph constraints
+func (e *Executor) createGraphIterator(ctx context.Context, plan *optimizer.GraphPlan) (store.BindingIterator, error) {
+	// The inner plan runs on an executor whose scans are all constrained to the graph,
+	// including those of nested operators (filters, OPTIONAL, UNION, ...)
+	graphExec := *e
+	graphExec.graph = plan.Graph
 
-	// Create a graph-aware executor wrapper
-	graphExec := &graphExecutor{
-		base:  e,
-		graph: plan.Graph,
-	}
-
-	// Execute the inner plan with the graph constraint
-	return graphExec.createIterator(plan.Input)
-}
-
-// graphExecutor wraps an executor and adds graph constraints to all scans
-type graphExecutor struct {
-	base  *Executor
-	graph *parser.GraphTerm
+	return graphExec.createIterator(ctx, plan.Input)
 }
 
-func (ge *graphExecutor) createIterator(plan optimizer.QueryPlan) (store.BindingIterator, error) {
-	switch p := plan.(type) {
-	case *optimizer.ScanPlan:
-		return ge.createGraphScanIterator(p)
-	case *optimizer.JoinPlan:
-		// For joins, create an iterator with graph-constrained left side
-		// The right side will be created on-demand during iteration
-		left, err := ge.createIterator(p.Left)
-		if err != nil {
-			return nil, err
-		}
-		// Create a join iterator that uses the graph executor for right side too
-		return &graphJoinIterator{
-			left:      left,
-			rightPlan: p.Right,
-			graphExec: ge,
-		}, nil
-	default:
-		// For other operators, delegate to base executor
-		return ge.base.createIterator(plan)
-	}
-}
-
-func (ge *graphExecutor) createGraphScanIterator(plan *optimizer.ScanPlan) (store.BindingIterator, error) {
-	// Convert parser triple pattern to store pattern with graph constraint
-	pattern := &store.Pattern{
-		Subject:   ge.base.convertTermOrVariable(plan.Pattern.Subject),
-		Predicate: ge.base.convertTermOrVariable(plan.Pattern.Predicate),
-		Object:    ge.base.convertTermOrVariable(plan.Pattern.Object),
-		Graph:     ge.convertGraphTerm(ge.graph),
-	}
-
-	// Execute pattern query
-	quadIter, err := ge.base.store.Query(pattern)
-	if err != nil {
-		return nil, err
-	}
-
-	return &scanIterator{
-		quadIter: quadIter,
-		pattern:  plan.Pattern,
-		binding:  store.NewBinding(),
-	}, nil
-}
-
-func (ge *graphExecutor) convertGraphTerm(graphTerm *parser.GraphTerm) any {
+// convertGraphTerm converts a GRAPH term to the graph of a store pattern
+func (e *Executor) convertGraphTerm(graphTerm *parser.GraphTerm) any {
 	if graphTerm.Variable != nil {
 		return &store.Variable{Name: graphTerm.Variable.Name}
 	}
 	return graphTerm.IRI
 }
 
-// graphJoinIterator implements nested loop join for GRAPH patterns
-type graphJoinIterator struct {
-	left         store.BindingIterator
-	rightPlan    optimizer.QueryPlan
-	graphExec    *graphExecutor
-	currentLeft  *store.Binding
-	currentRight store.BindingIterator
-	result       *store.Binding
+// distinctIterator implements DISTINCT operations.
+// New solutions stream through while the seen set fits the executor's memory budget,
+// past it they are deduplicated through spill files and returned after the input is exhausted,
+// still in input order.
+type distinctIterator struct {
+	ctx       context.Context
+	input     store.BindingIterator
+	distinct  *hashDistinct
+	spilled   sortedBindings
+	binding   *store.Binding
+	inputDone bool
 }
 
-func (it *graphJoinIterator) Next() bool {
-	for {
-		// If we have a right iterator, try to get next from it
-		if it.currentRight != nil {
-			if it.currentRight.Next() {
-				rightBinding := it.currentRight.Binding()
-
-				// Merge bindings
-				merged := it.mergeBindings(it.currentLeft, rightBinding)
-				if merged != nil {
-					it.result = merged
-					return true
-				}
-				continue
+func (it *distinctIterator) Next() bool {
+	if !it.inputDone {
+		for it.input.Next() {
+			binding := it.input.Binding()
+			emit, err := it.distinct.add(binding)
+			if err != nil {
+				failQuery(it.ctx, err)
+				return false
+			}
+			if emit {
+				it.binding = binding
+				return true
 			}
-			// Right exhausted, close it
-			_ = it.currentRight.Close() // #nosec G104 - close error doesn't affect iteration logic
-			it.currentRight = nil
 		}
-
-		// Get next from left
-		if !it.left.Next() {
+		it.inputDone = true
+		if it.ctx.Err() != nil {
 			return false
 		}
 
-		it.currentLeft = it.left.Binding()
-
-		// Create new right iterator using graph executor (with graph constraints)
-		rightIter, err := it.graphExec.createIterator(it.rightPlan)
+		spilled, err := it.distinct.finish()
 		if err != nil {
+			failQuery(it.ctx, err)
 			return false
 		}
-		it.currentRight = rightIter
+		it.spilled = spilled
+	}
+	if it.spilled == nil {
+		return false
 	}
+
+	binding, err := it.spilled.next()
+	if err != nil {
+		failQuery(it.ctx, err)
+		return false
+	}
+	if binding == nil {
+		return false
+	}
+	it.binding = binding
+	return true
 }
 
-func (it *graphJoinIterator) Binding() *store.Binding {
-	return it.result
+func (it *distinctIterator) Binding() *store.Binding {
+	return it.binding
 }
 
-func (it *graphJoinIterator) Close() error {
-	if it.currentRight != nil {
-		_ = it.currentRight.Close() // #nosec G104 - right close error less critical than left close error
+func (it *distinctIterator) Close() error {
+	it.distinct.close()
+	if it.spilled != nil {
+		it.spilled.close()
 	}
-	return it.left.Close()
+	return it.input.Close()
 }
 
-// mergeBindings merges two bindings, returns nil if incompatible
-func (it *graphJoinIterator) mergeBindings(left, right *store.Binding) *store.Binding {
-	result := left.Clone()
-
-	for varName, term := range right.Vars {
-		if existingTerm, exists := result.Vars[varName]; exists {
-			// Check compatibility
-			if !existingTerm.Equals(term) {
-				return nil
-			}
-		} else {
-			result.Vars[varName] = term
-		}
+// createGroupIterator creates an iterator for GROUP BY, returning one solution per group
+// with the grouping variables bound. Aggregates are not evaluated.
+func (e *Executor) createGroupIterator(ctx context.Context, plan *optimizer.GroupPlan) (store.BindingIterator, error) {
+	input, err := e.createIterator(ctx, plan.Input)
+	if err != nil {
+		return nil, err
 	}
 
-	return result
+	// The groups are the distinct keys, found with the same spillable hashing as DISTINCT
+	return &distinctIterator{
+		ctx: ctx,
+		input: &groupKeyIterator{
+			input:      input,
+			conditions: plan.GroupBy,
+			evaluator:  evaluator.NewEvaluator(),
+		},
+		distinct: e.newHashDistinct(ctx),
+	}, nil
 }
 
-// distinctIterator implements DISTINCT operations
-type distinctIterator struct {
-	input store.BindingIterator
-	seen  map[string]bool
+// groupKeyIterator reduces each solution to its GROUP BY key
+type groupKeyIterator struct {
+	input      store.BindingIterator
+	conditions []*parser.GroupCondition
+	evaluator  *evaluator.Evaluator
 }
 
-func (it *distinctIterator) Next() bool {
-	for it.input.Next() {
-		binding := it.input.Binding()
-		key := it.bindingKey(binding)
+func (it *groupKeyIterator) Next() bool {
+	return it.input.Next()
+}
 
-		if !it.seen[key] {
-			it.seen[key] = true
-			return true
+func (it *groupKeyIterator) Binding() *store.Binding {
+	input := it.input.Binding()
+	var names []string
+	for _, condition := range it.conditions {
+		if condition.Expression == nil && condition.Variable != nil {
+			names = append(names, condition.Variable.Name)
 		}
 	}
-	return false
-}
-
-func (it *distinctIterator) Binding() *store.Binding {
-	return it.input.Binding()
+	// Grouping variables keep their encoded IDs, so groups are found without decoding them
+	key := input.Project(names)
+	for _, condition := range it.conditions {
+		if condition.Expression == nil {
+			continue
+		}
+		// Only named expressions, (expr AS ?var), are part of the key
+		if condition.Variable == nil {
+			continue
+		}
+		if value, err := it.evaluator.Evaluate(condition.Expression, input); err == nil {
+			key.Set(condition.Variable.Name, value)
+		}
+	}
+	return key
 }
 
-func (it *distinctIterator) Close() error {
+func (it *groupKeyIterator) Close() error {
 	return it.input.Close()
 }
 
-// bindingKey creates a unique key for a binding
-func (it *distinctIterator) bindingKey(binding *store.Binding) string {
-	// Simple string concatenation for now
-	// TODO: Implement better hashing
-	key := ""
-	for varName, term := range binding.Vars {
-		key += varName + "=" + term.String() + ";"
-	}
-	return key
-}
-
 // createBindIterator creates an iterator for BIND operations
-func (e *Executor) createBindIterator(plan *optimizer.BindPlan) (store.BindingIterator, error) {
-	input, err := e.createIterator(plan.Input)
+func (e *Executor) createBindIterator(ctx context.Context, plan *optimizer.BindPlan) (store.BindingIterator, error) {
+	input, err := e.createIterator(ctx, plan.Input)
 	if err != nil {
 		return nil, err
 	}
@@ -1049,7 +1082,7 @@ func (it *bindIterator) Binding() *store.Binding {
 	extendedBinding := inputBinding.Clone()
 
 	// Add the result to the extended binding
-	extendedBinding.Vars[it.variable.Name] = result
+	extendedBinding.Set(it.variable.Name, result)
 
 	return extendedBinding
 }
@@ -1059,15 +1092,33 @@ func (it *bindIterator) Close() error {
 }
 
 // createOptionalIterator creates an iterator for OPTIONAL operations (left outer join)
-func (e *Executor) createOptionalIterator(plan *optimizer.OptionalPlan) (store.BindingIterator, error) {
-	left, err := e.createIterator(plan.Left)
+func (e *Executor) createOptionalIterator(ctx context.Context, plan *optimizer.OptionalPlan) (store.BindingIterator, error) {
+	left, err := e.createIterator(ctx, plan.Left)
 	if err != nil {
 		return nil, err
 	}
 
+	if plan.Type == optimizer.JoinTypeHashJoin {
+		right, err := e.createIterator(ctx, plan.Right)
+		if err != nil {
+			_ = left.Close() // #nosec G104 - cleanup on error
+			return nil, err
+		}
+		return &hashJoinIterator{
+			left:      left,
+			build:     e.newTableBuild(ctx, right, plan.Variables),
+			optional:  true,
+			filters:   plan.Filters,
+			evaluator: evaluator.NewEvaluator(),
+		}, nil
+	}
+
 	return &optionalIterator{
+		ctx:          ctx,
 		left:         left,
 		rightPlan:    plan.Right,
+		filters:      plan.Filters,
+		evaluator:    evaluator.NewEvaluator(),
 		executor:     e,
 		currentLeft:  nil,
 		currentRight: nil,
@@ -1075,10 +1126,14 @@ func (e *Executor) createOptionalIterator(plan *optimizer.OptionalPlan) (store.B
 	}, nil
 }
 
-// optionalIterator implements OPTIONAL patterns (left outer join)
+// optionalIterator implements OPTIONAL patterns (left outer join) as a nested loop
+// The right plan is evaluated again for every left binding
 type optionalIterator struct {
+	ctx          context.Context
 	left         store.BindingIterator
 	rightPlan    optimizer.QueryPlan
+	filters      []*parser.Filter // evaluated on the combined bindings
+	evaluator    *evaluator.Evaluator
 	executor     *Executor
 	currentLeft  *store.Binding
 	currentRight store.BindingIterator
@@ -1094,8 +1149,8 @@ func (it *optionalIterator) Next() bool {
 				rightBinding := it.currentRight.Binding()
 
 				// Try to merge bindings
-				merged := it.mergeBindings(it.currentLeft, rightBinding)
-				if merged != nil {
+				merged := mergeBindings(it.currentLeft, rightBinding)
+				if merged != nil && it.accepts(merged) {
 					it.hasMatch = true
 					it.result = merged
 					return true
@@ -1122,16 +1177,25 @@ func (it *optionalIterator) Next() bool {
 		it.hasMatch = false
 
 		// Create new right iterator
-		rightIter, err := it.executor.createIterator(it.rightPlan)
+		rightIter, err := it.executor.createIterator(it.ctx, it.rightPlan)
 		if err != nil {
-			// If right fails, still return left binding (OPTIONAL semantics)
-			it.result = it.currentLeft
-			return true
+			failQuery(it.ctx, err)
+			return false
 		}
 		it.currentRight = rightIter
 	}
 }
 
+// accepts evaluates the OPTIONAL filters on a combined binding
+func (it *optionalIterator) accepts(binding *store.Binding) bool {
+	for _, filter := range it.filters {
+		if !evaluateFilter(it.evaluator, filter, binding) {
+			return false
+		}
+	}
+	return true
+}
+
 func (it *optionalIterator) Binding() *store.Binding {
 	return it.result
 }
@@ -1143,126 +1207,75 @@ func (it *optionalIterator) Close() error {
 	return it.left.Close()
 }
 
-// mergeBindings merges two bindings, returns nil if incompatible
-func (it *optionalIterator) mergeBindings(left, right *store.Binding) *store.Binding {
-	result := left.Clone()
-
-	for varName, term := range right.Vars {
-		if existingTerm, exists := result.Vars[varName]; exists {
-			// Check compatibility
-			if !existingTerm.Equals(term) {
-				return nil
-			}
-		} else {
-			result.Vars[varName] = term
-		}
+// createUnionIterator creates an iterator for UNION operations (alternation)
+func (e *Executor) createUnionIterator(ctx context.Context, plan *optimizer.UnionPlan) (store.BindingIterator, error) {
+	var tasks []parallelTask
+	for _, branch := range []optimizer.QueryPlan{plan.Left, plan.Right} {
+		tasks = append(tasks, func(ctx context.Context) (store.BindingIterator, error) {
+			return e.createIterator(ctx, branch)
+		})
 	}
-
-	return result
+	if !plan.Parallel {
+		return newSerialIterator(ctx, tasks), nil
+	}
+	// The branches run concurrently when the query has workers free, one after the other otherwise
+	return newParallelIterator(ctx, tasks), nil
 }
 
-// createUnionIterator creates an iterator for UNION operations (alternation)
-func (e *Executor) createUnionIterator(plan *optimizer.UnionPlan) (store.BindingIterator, error) {
-	left, err := e.createIterator(plan.Left)
+// createMinusIterator creates an iterator for MINUS operations (set difference)
+func (e *Executor) createMinusIterator(ctx context.Context, plan *optimizer.MinusPlan) (store.BindingIterator, error) {
+	left, err := e.createIterator(ctx, plan.Left)
 	if err != nil {
 		return nil, err
 	}
 
-	right, err := e.createIterator(plan.Right)
+	right, err := e.createIterator(ctx, plan.Right)
 	if err != nil {
 		_ = left.Close() // #nosec G104 - cleanup on error
 		return nil, err
 	}
 
-	return &unionIterator{
-		left:     left,
-		right:    right,
-		leftDone: false,
-	}, nil
-}
-
-// unionIterator implements UNION patterns (alternation)
-type unionIterator struct {
-	left     store.BindingIterator
-	right    store.BindingIterator
-	leftDone bool
-}
-
-func (it *unionIterator) Next() bool {
-	// First exhaust the left side
-	if !it.leftDone {
-		if it.left.Next() {
-			return true
-		}
-		it.leftDone = true
-	}
-
-	// Then process the right side
-	return it.right.Next()
-}
-
-func (it *unionIterator) Binding() *store.Binding {
-	if !it.leftDone {
-		return it.left.Binding()
-	}
-	return it.right.Binding()
-}
-
-func (it *unionIterator) Close() error {
-	_ = it.left.Close() // #nosec G104 - left close error less critical than right close error
-	return it.right.Close()
-}
-
-// createMinusIterator creates an iterator for MINUS operations (set difference)
-func (e *Executor) createMinusIterator(plan *optimizer.MinusPlan) (store.BindingIterator, error) {
-	left, err := e.createIterator(plan.Left)
-	if err != nil {
-		return nil, err
-	}
-
 	return &minusIterator{
 		left:      left,
-		rightPlan: plan.Right,
-		executor:  e,
+		build:     e.newTableBuild(ctx, right, plan.Variables),
+		variables: plan.Variables,
+		store:     e.store,
 	}, nil
 }
 
 // minusIterator implements MINUS patterns (set difference)
+// The right side is read once into a hash table on the shared variables
 type minusIterator struct {
 	left      store.BindingIterator
-	rightPlan optimizer.QueryPlan
-	executor  *Executor
+	build     *tableBuild
+	variables []string
+	store     *store.TripleStore
+
+	table *hashTable
 }
 
 func (it *minusIterator) Next() bool {
-	for it.left.Next() {
-		leftBinding := it.left.Binding()
+	if it.table == nil {
+		it.table = it.build.wait()
+	}
 
-		// Check if this binding is compatible with any right binding
-		rightIter, err := it.executor.createIterator(it.rightPlan)
-		if err != nil {
-			// If right fails, return left binding (MINUS semantics)
+	for it.left.Next() {
+		if !it.removed(it.left.Binding()) {
 			return true
 		}
+	}
+	return false
+}
 
-		hasMatch := false
-		for rightIter.Next() {
-			rightBinding := rightIter.Binding()
-
-			// Check if bindings are compatible (share common variables with same values)
-			if it.isCompatible(leftBinding, rightBinding) {
-				hasMatch = true
-				break
+// removed reports whether a left binding is compatible with a right binding it shares a variable with
+func (it *minusIterator) removed(left *store.Binding) bool {
+	for _, rows := range it.table.candidates(left) {
+		for _, right := range rows {
+			if minusCompatible(left, right) {
+				return true
 			}
 		}
-		_ = rightIter.Close() // #nosec G104 - close error doesn't affect iteration logic
-
-		// Only return the binding if there was no match (MINUS semantics)
-		if !hasMatch {
-			return true
-		}
 	}
-
 	return false
 }
 
@@ -1271,40 +1284,55 @@ func (it *minusIterator) Binding() *store.Binding {
 }
 
 func (it *minusIterator) Close() error {
+	_ = it.build.close() // #nosec G104 - right close error less critical than left close error
 	return it.left.Close()
 }
 
-// isCompatible checks if two bindings are compatible (no conflicting variable values)
-func (it *minusIterator) isCompatible(left, right *store.Binding) bool {
-	for varName, leftTerm := range left.Vars {
-		if rightTerm, exists := right.Vars[varName]; exists {
-			if !leftTerm.Equals(rightTerm) {
+// minusCompatible checks if two bindings share at least one variable and agree on all shared variables
+func minusCompatible(left, right *store.Binding) bool {
+	shared := false
+	for _, name := range left.Names() {
+		if right.Bound(name) {
+			if !left.SameTerm(name, right) {
 				return false
 			}
+			shared = true
 		}
 	}
-	return true
+	return shared
 }
 
 // createOrderByIterator creates an iterator for ORDER BY operations
-func (e *Executor) createOrderByIterator(plan *optimizer.OrderByPlan) (store.BindingIterator, error) {
-	input, err := e.createIterator(plan.Input)
+func (e *Executor) createOrderByIterator(ctx context.Context, plan *optimizer.OrderByPlan) (store.BindingIterator, error) {
+	input, err := e.createIterator(ctx, plan.Input)
 	if err != nil {
 		return nil, err
 	}
 
 	return &orderByIterator{
+		ctx:     ctx,
 		input:   input,
 		orderBy: plan.OrderBy,
+		limit:   plan.Limit,
+		budget:  e.memoryBudget,
+		dir:     e.tempDir,
+		terms:   termCache(ctx),
 	}, nil
 }
 
-// orderByIterator implements ORDER BY operations
+// orderByIterator implements ORDER BY operations.
+// Solutions are sorted in memory up to the executor's memory budget and with an external
+// merge sort beyond it. With a limit only the first solutions are kept, in a bounded heap.
 type orderByIterator struct {
+	ctx         context.Context
 	input       store.BindingIterator
 	orderBy     []*parser.OrderCondition
-	bindings    []*store.Binding
-	position    int
+	limit       int // number of solutions needed, 0 for all
+	budget      int64
+	dir         string
+	terms       *store.TermCache
+	sorted      sortedBindings
+	binding     *store.Binding
 	initialized bool
 }
 
@@ -1312,55 +1340,96 @@ func (it *orderByIterator) Next() bool {
 	// Materialize and sort all bindings on first call
 	if !it.initialized {
 		it.initialized = true
-
-		// Collect all bindings
-		for it.input.Next() {
-			binding := it.input.Binding()
-			it.bindings = append(it.bindings, binding.Clone())
+		sorted, err := it.sort()
+		if err != nil {
+			failQuery(it.ctx, err)
+			return false
 		}
-
-		// Sort bindings according to ORDER BY conditions
-		it.sortBindings()
+		it.sorted = sorted
 	}
-
-	if it.position >= len(it.bindings) {
+	if it.sorted == nil {
 		return false
 	}
 
-	it.position++
+	binding, err := it.sorted.next()
+	if err != nil {
+		failQuery(it.ctx, err)
+		return false
+	}
+	if binding == nil {
+		return false
+	}
+	it.binding = binding
 	return true
 }
 
-func (it *orderByIterator) Binding() *store.Binding {
-	if it.position > 0 && it.position <= len(it.bindings) {
-		return it.bindings[it.position-1]
+// sort consumes the input and returns its solutions in order
+func (it *orderByIterator) sort() (sortedBindings, error) {
+	sorter := &externalSort{less: it.less, budget: it.budget, dir: it.dir, terms: it.terms}
+
+	var top *topK
+	if it.limit > 0 {
+		top = &topK{less: it.less, k: it.limit}
 	}
-	return store.NewBinding()
-}
 
-func (it *orderByIterator) Close() error {
-	return it.input.Close()
+	for it.input.Next() {
+		binding := it.input.Binding().Clone()
+		// Decode the sort keys once rather than on every comparison
+		for _, condition := range it.orderBy {
+			if varExpr, ok := condition.Expression.(*parser.VariableExpression); ok {
+				binding.Decode(varExpr.Variable.Name)
+			}
+		}
+		if top != nil {
+			top.add(binding)
+			if top.size <= it.budget {
+				continue
+			}
+			// The solutions kept for the limit no longer fit: sort everything externally instead
+			heads := top.heads
+			sort.Slice(heads, func(i, j int) bool { return heads[i].seq < heads[j].seq })
+			top = nil
+			for _, head := range heads {
+				if err := sorter.add(head.binding); err != nil {
+					sorter.close()
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := sorter.add(binding); err != nil {
+			sorter.close()
+			return nil, err
+		}
+	}
+	if top != nil {
+		return &sliceBindings{bindings: top.sorted()}, nil
+	}
+
+	sorted, err := sorter.finish()
+	if err != nil {
+		sorter.close()
+		return nil, err
+	}
+	return sorted, nil
 }
 
-// sortBindings sorts the bindings according to ORDER BY conditions
-func (it *orderByIterator) sortBindings() {
-	if len(it.orderBy) == 0 {
-		return
+func (it *orderByIterator) Binding() *store.Binding {
+	if it.binding == nil {
+		return store.NewBinding()
 	}
+	return it.binding
+}
 
-	// Use a simple bubble sort with comparison function
-	// For better performance, could use sort.Slice with a custom Less function
-	for i := 0; i < len(it.bindings); i++ {
-		for j := i + 1; j < len(it.bindings); j++ {
-			if it.shouldSwap(it.bindings[i], it.bindings[j]) {
-				it.bindings[i], it.bindings[j] = it.bindings[j], it.bindings[i]
-			}
-		}
+func (it *orderByIterator) Close() error {
+	if it.sorted != nil {
+		it.sorted.close()
 	}
+	return it.input.Close()
 }
 
-// shouldSwap returns true if binding a should come after binding b
-func (it *orderByIterator) shouldSwap(a, b *store.Binding) bool {
+// less reports whether binding a sorts before binding b according to the ORDER BY conditions
+func (it *orderByIterator) less(a, b *store.Binding) bool {
 	// Compare based on each ORDER BY condition in order
 	for _, condition := range it.orderBy {
 		cmp := it.compareByCondition(a, b, condition)
@@ -1370,7 +1439,7 @@ func (it *orderByIterator) shouldSwap(a, b *store.Binding) bool {
 			if !condition.Ascending {
 				cmp = -cmp
 			}
-			return cmp > 0
+			return cmp < 0
 		}
 		// If equal, continue to next condition
 	}
@@ -1391,8 +1460,8 @@ func (it *orderByIterator) compareByCondition(a, b *store.Binding, condition *pa
 
 	varName := varExpr.Variable.Name
 
-	aVal, aExists := a.Vars[varName]
-	bVal, bExists := b.Vars[varName]
+	aVal, aExists := a.Term(varName)
+	bVal, bExists := b.Term(varName)
 
 	// Handle missing values (unbound variables)
 	if !aExists && !bExists {
@@ -1439,15 +1508,22 @@ func extractVariablesFromGraphPattern(pattern *parser.GraphPattern) []*parser.Va
 
 	// Helper to add variable if not seen
 	addVar := func(v *parser.Variable) {
-		if v != nil && !seen[v.Name] {
+		// Anonymous reifiers ("_:r0") behave like blank nodes and are not projected
+		if v != nil && !seen[v.Name] && !strings.HasPrefix(v.Name, "_:") {
 			seen[v.Name] = true
 			variables = append(variables, v)
 		}
 	}
 
-	// Helper to extract variables from TermOrVariable
-	extractFromTerm := func(t parser.TermOrVariable) {
+	// Helper to extract variables from TermOrVariable (including inside triple terms)
+	var extractFromTerm func(t parser.TermOrVariable)
+	extractFromTerm = func(t parser.TermOrVariable) {
 		addVar(t.Variable)
+		if t.TripleTerm != nil {
+			extractFromTerm(t.TripleTerm.Subject)
+			extractFromTerm(t.TripleTerm.Predicate)
+			extractFromTerm(t.TripleTerm.Object)
+		}
 	}
 
 	// Process patterns in order
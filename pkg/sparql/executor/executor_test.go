@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/internal/encoding"
+	"github.com/aleksaelezovic/trigo/internal/storage"
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// newTestStore creates a store in a temporary directory holding a TriG document
+func newTestStore(t *testing.T, trig string) *store.TripleStore {
+	t.Helper()
+	badgerStorage, err := storage.NewBadgerStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { badgerStorage.Close() })
+
+	ts := store.NewTripleStore(badgerStorage, encoding.NewTermEncoder(), encoding.NewTermDecoder())
+	quads, err := rdf.NewTriGParser(trig).Parse()
+	if err != nil {
+		t.Fatalf("failed to parse data: %v", err)
+	}
+	if err := ts.InsertQuadsBatch(quads); err != nil {
+		t.Fatalf("failed to insert data: %v", err)
+	}
+	return ts
+}
+
+// planQuery parses and optimizes a query; nil statistics are loaded from the store
+func planQuery(t *testing.T, ts *store.TripleStore, query string, stats *optimizer.Statistics) *optimizer.OptimizedQuery {
+	t.Helper()
+	parsed, err := parser.NewParser(query).Parse()
+	if err != nil {
+		t.Fatalf("failed to parse query: %v", err)
+	}
+	if stats == nil {
+		if stats, err = optimizer.LoadStatistics(ts); err != nil {
+			t.Fatalf("failed to load statistics: %v", err)
+		}
+	}
+	optimized, err := optimizer.NewOptimizer(stats).Optimize(context.Background(), parsed)
+	if err != nil {
+		t.Fatalf("failed to optimize query: %v", err)
+	}
+	return optimized
+}
+
+// selectRows runs a SELECT query and returns its solutions, one line per solution with the
+// bound variables in name order
+func selectRows(t *testing.T, exec *Executor, query *optimizer.OptimizedQuery) []string {
+	t.Helper()
+	result, err := exec.Execute(context.Background(), query)
+	if err != nil {
+		t.Fatalf("failed to execute query: %v", err)
+	}
+	selectResult, ok := result.(*SelectResult)
+	if !ok {
+		t.Fatalf("expected a SELECT result, got %T", result)
+	}
+	rows := make([]string, len(selectResult.Bindings))
+	for i, binding := range selectResult.Bindings {
+		rows[i] = formatBinding(binding)
+	}
+	return rows
+}
+
+// formatBinding writes the variables of a solution in name order
+func formatBinding(binding *store.Binding) string {
+	names := make([]string, 0, len(binding.Vars))
+	for name := range binding.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + binding.Vars[name].String()
+	}
+	return strings.Join(parts, " ")
+}
+
+// sorted returns the rows in sorted order, for comparing results that have no defined order
+func sorted(rows []string) []string {
+	rows = append([]string(nil), rows...)
+	sort.Strings(rows)
+	return rows
+}
+
+// assertSameRows fails unless two results hold the same rows
+func assertSameRows(t *testing.T, expected, actual []string) {
+	t.Helper()
+	if strings.Join(expected, "\n") != strings.Join(actual, "\n") {
+		t.Errorf("expected %d rows:\n%s\ngot %d rows:\n%s",
+			len(expected), strings.Join(expected, "\n"), len(actual), strings.Join(actual, "\n"))
+	}
+}
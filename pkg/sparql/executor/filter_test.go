@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+)
+
+const filterData = `@prefix ex: <http://example.org/> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+ex:a ex:p ex:x, ex:y, 1, "1.0"^^xsd:decimal, "1", "x", "x"^^xsd:string, "a"@en, "a"@EN ; ex:r 1 .
+ex:b ex:p ex:x, 2 ; ex:q ex:x ; ex:r 2 .
+ex:c ex:q ex:y ; ex:r 3 .
+ex:x ex:q ex:z .
+`
+
+// TestFilterRewrites compares the solutions of queries whose filters are rewritten with those
+// of equivalent queries the rewrites leave alone: a disjunction with false is not folded or
+// split, and a filter on a variable bound by BIND is not pushed below it.
+func TestFilterRewrites(t *testing.T) {
+	ts := newTestStore(t, filterData)
+	tests := []struct {
+		name     string
+		query    string
+		baseline string
+		folded   bool // no filter is left in the plan
+	}{
+		{
+			name:     "= on an IRI",
+			query:    `SELECT * WHERE { ?s ex:p ?o FILTER(?o = ex:x) }`,
+			baseline: `SELECT * WHERE { ?s ex:p ?o FILTER(?o = ex:x || false) }`,
+			folded:   true,
+		},
+		{
+			name:     "= on a number",
+			query:    `SELECT * WHERE { ?s ex:p ?o FILTER(?o = 1) }`,
+			baseline: `SELECT * WHERE { ?s ex:p ?o FILTER(?o = 1 || false) }`,
+		},
+		{
+			name:     "= on a string",
+			query:    `SELECT * WHERE { ?s ex:p ?o FILTER(?o = "x") }`,
+			baseline: `SELECT * WHERE { ?s ex:p ?o FILTER(?o = "x" || false) }`,
+		},
+		{
+			name:     "sameTerm on a number",
+			query:    `SELECT * WHERE { ?s ex:p ?o FILTER(sameTerm(?o, 1)) }`,
+			baseline: `SELECT * WHERE { ?s ex:p ?o FILTER(sameTerm(?o, 1) || false) }`,
+			folded:   true,
+		},
+		{
+			name:     "sameTerm on a string",
+			query:    `SELECT * WHERE { ?s ex:p ?o FILTER(sameTerm("x", ?o)) }`,
+			baseline: `SELECT * WHERE { ?s ex:p ?o FILTER(sameTerm("x", ?o) || false) }`,
+			folded:   true,
+		},
+		{
+			name:     "sameTerm on a language tagged string",
+			query:    `SELECT * WHERE { ?s ex:p ?o FILTER(sameTerm(?o, "a"@en)) }`,
+			baseline: `SELECT * WHERE { ?s ex:p ?o FILTER(sameTerm(?o, "a"@en) || false) }`,
+			folded:   true,
+		},
+		{
+			name:     "constant in several patterns",
+			query:    `SELECT * WHERE { ?s ex:p ?o . ?o ex:q ?z FILTER(?o = ex:x) }`,
+			baseline: `SELECT * WHERE { ?s ex:p ?o . ?o ex:q ?z FILTER(?o = ex:x || false) }`,
+			folded:   true,
+		},
+		{
+			name:     "constant joined with an optional",
+			query:    `SELECT * WHERE { ?s ex:p ?o OPTIONAL { ?o ex:q ?z } FILTER(?o = ex:x) }`,
+			baseline: `SELECT * WHERE { ?s ex:p ?o OPTIONAL { ?o ex:q ?z } FILTER(?o = ex:x || false) }`,
+			folded:   true,
+		},
+		{
+			name:     "two constants for a variable",
+			query:    `SELECT * WHERE { ?s ex:p ?o FILTER(?o = ex:x) FILTER(?o = ex:y) }`,
+			baseline: `SELECT * WHERE { ?s ex:p ?o FILTER(?o = ex:x || false) FILTER(?o = ex:y || false) }`,
+		},
+		{
+			name:     "filter pushed into union branches",
+			query:    `SELECT ?s ?o WHERE { { ?s ex:p ?o } UNION { ?s ex:q ?o } FILTER(?o != ex:x) }`,
+			baseline: `SELECT ?s ?o WHERE { { ?s ex:p ?o } UNION { ?s ex:q ?o } BIND(?o AS ?b) FILTER(?b != ex:x) }`,
+		},
+		{
+			name:     "!bound pushed into union branches",
+			query:    `SELECT ?s ?o ?r WHERE { { ?s ex:p ?o } UNION { ?s ex:r ?r } FILTER(!bound(?o)) }`,
+			baseline: `SELECT ?s ?o ?r WHERE { { ?s ex:p ?o } UNION { ?s ex:r ?r } BIND(BOUND(?o) AS ?b) FILTER(!?b) }`,
+		},
+		{
+			name:     "!bound above an optional",
+			query:    `SELECT ?s ?o ?z WHERE { ?s ex:p ?o OPTIONAL { ?o ex:q ?z } FILTER(!bound(?z)) }`,
+			baseline: `SELECT ?s ?o ?z WHERE { ?s ex:p ?o OPTIONAL { ?o ex:q ?z } BIND(BOUND(?z) AS ?b) FILTER(!?b) }`,
+		},
+		{
+			name:     "filter on the left side of an optional",
+			query:    `SELECT ?s ?r ?z WHERE { ?s ex:r ?r OPTIONAL { ?s ex:q ?z } FILTER(?r > 1) }`,
+			baseline: `SELECT ?s ?r ?z WHERE { ?s ex:r ?r OPTIONAL { ?s ex:q ?z } BIND(?r AS ?b) FILTER(?b > 1) }`,
+		},
+		{
+			name:     "conjunction split across a join",
+			query:    `SELECT * WHERE { ?s ex:p ?o . ?s ex:r ?r FILTER(?o != ex:x && ?r > 1) }`,
+			baseline: `SELECT * WHERE { ?s ex:p ?o . ?s ex:r ?r FILTER((?o != ex:x && ?r > 1) || false) }`,
+		},
+		{
+			name:     "trivially true conjunct",
+			query:    `SELECT * WHERE { ?s ex:r ?r FILTER(true && 1 < 2 && ?r > 1) }`,
+			baseline: `SELECT * WHERE { ?s ex:r ?r FILTER(?r > 1 || false) }`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := NewExecutor(ts)
+			plan := planQuery(t, ts, "PREFIX ex: <http://example.org/> "+tt.query, nil)
+			if tt.folded {
+				for _, node := range planNodes(plan.Plan) {
+					if _, ok := node.(*optimizer.FilterPlan); ok {
+						t.Error("expected the filter to be folded into the patterns")
+					}
+				}
+			}
+			expected := sorted(selectRows(t, exec, planQuery(t, ts, "PREFIX ex: <http://example.org/> "+tt.baseline, nil)))
+			assertSameRows(t, expected, sorted(selectRows(t, exec, plan)))
+		})
+	}
+}
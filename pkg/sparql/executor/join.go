@@ -0,0 +1,366 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/aleksaelezovic/trigo/pkg/sparql/evaluator"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// newJoinIterator creates the iterator for the join type chosen by the optimizer
+func (e *Executor) newJoinIterator(ctx context.Context, plan *optimizer.JoinPlan) (store.BindingIterator, error) {
+	left, err := e.createIterator(ctx, plan.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	if plan.Type == optimizer.JoinTypeNestedLoop {
+		return &nestedLoopJoinIterator{
+			ctx:       ctx,
+			left:      left,
+			rightPlan: plan.Right,
+			executor:  e,
+		}, nil
+	}
+
+	right, err := e.createIterator(ctx, plan.Right)
+	if err != nil {
+		_ = left.Close() // #nosec G104 - cleanup on error
+		return nil, err
+	}
+
+	switch plan.Type {
+	case optimizer.JoinTypeHashJoin:
+		return &hashJoinIterator{
+			left:      left,
+			build:     e.newTableBuild(ctx, right, plan.Variables),
+			variables: plan.Variables,
+		}, nil
+	case optimizer.JoinTypeMergeJoin:
+		return &mergeJoinIterator{
+			left:     left,
+			right:    right,
+			variable: plan.Variables[0],
+			store:    e.store,
+		}, nil
+	default:
+		_ = left.Close()  // #nosec G104 - cleanup on error
+		_ = right.Close() // #nosec G104 - cleanup on error
+		return nil, fmt.Errorf("unsupported join type: %v", plan.Type)
+	}
+}
+
+// mergeBindings merges two bindings, returns nil if incompatible
+// Shared variables are compared by encoded ID when both sides came from the store.
+func mergeBindings(left, right *store.Binding) *store.Binding {
+	return left.Merge(right)
+}
+
+// nestedLoopJoinIterator implements nested loop join
+// The right plan is evaluated again for every left binding
+type nestedLoopJoinIterator struct {
+	ctx          context.Context
+	left         store.BindingIterator
+	rightPlan    optimizer.QueryPlan
+	executor     *Executor
+	currentLeft  *store.Binding
+	currentRight store.BindingIterator
+	result       *store.Binding
+}
+
+func (it *nestedLoopJoinIterator) Next() bool {
+	for {
+		// If we have a right iterator, try to get next from it
+		if it.currentRight != nil {
+			if it.currentRight.Next() {
+				rightBinding := it.currentRight.Binding()
+
+				// Merge bindings
+				merged := mergeBindings(it.currentLeft, rightBinding)
+				if merged != nil {
+					it.result = merged
+					return true
+				}
+				continue
+			}
+			// Right exhausted, close it
+			_ = it.currentRight.Close() // #nosec G104 - close error doesn't affect iteration logic
+			it.currentRight = nil
+		}
+
+		// Get next from left
+		if !it.left.Next() {
+			return false
+		}
+
+		it.currentLeft = it.left.Binding()
+
+		// Create new right iterator
+		rightIter, err := it.executor.createIterator(it.ctx, it.rightPlan)
+		if err != nil {
+			failQuery(it.ctx, err)
+			return false
+		}
+		it.currentRight = rightIter
+	}
+}
+
+func (it *nestedLoopJoinIterator) Binding() *store.Binding {
+	return it.result
+}
+
+func (it *nestedLoopJoinIterator) Close() error {
+	if it.currentRight != nil {
+		_ = it.currentRight.Close() // #nosec G104 - right close error less critical than left close error
+	}
+	return it.left.Close()
+}
+
+// hashJoinIterator implements hash join
+// The right side is read once into a table keyed by the encoded IDs of the join variables,
+// then left bindings are streamed through it, so the output keeps the left side's order.
+// The table may be built on another goroutine while the left side starts (see tableBuild).
+// As a left outer join (OPTIONAL) it also returns left bindings without a match.
+type hashJoinIterator struct {
+	left      store.BindingIterator
+	build     *tableBuild
+	variables []string
+
+	// Left outer join only: filters on the combined bindings
+	optional  bool
+	filters   []*parser.Filter
+	evaluator *evaluator.Evaluator
+
+	table *hashTable
+
+	currentLeft *store.Binding
+	matched     bool // currentLeft was returned, combined or alone
+	candidates  [][]*store.Binding
+	group       int
+	pos         int
+	result      *store.Binding
+}
+
+func (it *hashJoinIterator) Next() bool {
+	if it.table == nil {
+		it.table = it.build.wait()
+	}
+
+	for {
+		for it.group < len(it.candidates) {
+			rows := it.candidates[it.group]
+			if it.pos >= len(rows) {
+				it.group++
+				it.pos = 0
+				continue
+			}
+			right := rows[it.pos]
+			it.pos++
+			if merged := mergeBindings(it.currentLeft, right); merged != nil && it.accepts(merged) {
+				it.matched = true
+				it.result = merged
+				return true
+			}
+		}
+
+		if it.optional && it.currentLeft != nil && !it.matched {
+			// No match on the right, OPTIONAL keeps the left binding alone
+			it.matched = true
+			it.result = it.currentLeft
+			return true
+		}
+
+		// Probe the table with the next left binding
+		if !it.left.Next() {
+			return false
+		}
+		it.currentLeft = it.left.Binding()
+		it.matched = false
+		it.group, it.pos = 0, 0
+		it.candidates = it.table.candidates(it.currentLeft)
+	}
+}
+
+// accepts evaluates the join filters on a combined binding
+func (it *hashJoinIterator) accepts(binding *store.Binding) bool {
+	for _, filter := range it.filters {
+		if !evaluateFilter(it.evaluator, filter, binding) {
+			return false
+		}
+	}
+	return true
+}
+
+func (it *hashJoinIterator) Binding() *store.Binding {
+	return it.result
+}
+
+func (it *hashJoinIterator) Close() error {
+	_ = it.build.close() // #nosec G104 - right close error less critical than left close error
+	return it.left.Close()
+}
+
+// hashTable holds the bindings of a join input keyed by the encoded IDs of the join variables
+type hashTable struct {
+	store     *store.TripleStore
+	variables []string
+
+	rows    []*store.Binding            // all bindings
+	table   map[string][]*store.Binding // bindings by join key
+	unkeyed []*store.Binding            // bindings missing a join variable, tried against every probe
+}
+
+// buildHashTable reads an iterator into a hash table, until it ends or stop is set
+func buildHashTable(ts *store.TripleStore, it store.BindingIterator, variables []string, stop *atomic.Bool) *hashTable {
+	t := &hashTable{
+		store:     ts,
+		variables: variables,
+		table:     make(map[string][]*store.Binding),
+	}
+	for !stop.Load() && it.Next() {
+		binding := it.Binding().Clone()
+		t.rows = append(t.rows, binding)
+		if key, ok := joinKey(ts, binding, variables); ok {
+			t.table[key] = append(t.table[key], binding)
+		} else {
+			t.unkeyed = append(t.unkeyed, binding)
+		}
+	}
+	return t
+}
+
+// candidates returns the groups of bindings that may be compatible with a probe binding
+func (t *hashTable) candidates(binding *store.Binding) [][]*store.Binding {
+	if key, ok := joinKey(t.store, binding, t.variables); ok {
+		return [][]*store.Binding{t.table[key], t.unkeyed}
+	}
+	return [][]*store.Binding{t.rows}
+}
+
+// joinKey concatenates the encoded IDs of the join variables
+// Returns false if a join variable is unbound
+func joinKey(ts *store.TripleStore, binding *store.Binding, variables []string) (string, bool) {
+	key := make([]byte, 0, len(variables)*len(store.EncodedTerm{}))
+	for _, name := range variables {
+		if !binding.Bound(name) {
+			return "", false
+		}
+		key = appendTermKey(key, ts, binding, name)
+		key = append(key, ';')
+	}
+	return string(key), true
+}
+
+// mergeJoinIterator implements merge join over two inputs ordered by the join variable
+// in encoded term order, as they come out of the indexes. Neither side is materialised
+// beyond the right bindings sharing the current key.
+type mergeJoinIterator struct {
+	left     store.BindingIterator
+	right    store.BindingIterator
+	variable string
+	store    *store.TripleStore
+
+	started   bool
+	rightNext *store.Binding // lookahead on the right side
+	rightKey  store.EncodedTerm
+	rightDone bool
+
+	hasGroup bool
+	group    []*store.Binding // right bindings with groupKey
+	groupKey store.EncodedTerm
+
+	currentLeft *store.Binding
+	pos         int
+	result      *store.Binding
+}
+
+func (it *mergeJoinIterator) Next() bool {
+	if !it.started {
+		it.started = true
+		it.advanceRight()
+	}
+
+	for {
+		for it.pos < len(it.group) {
+			right := it.group[it.pos]
+			it.pos++
+			if merged := mergeBindings(it.currentLeft, right); merged != nil {
+				it.result = merged
+				return true
+			}
+		}
+
+		if !it.left.Next() {
+			return false
+		}
+		it.currentLeft = it.left.Binding()
+		it.pos = 0
+
+		key, ok := it.key(it.currentLeft)
+		if !ok {
+			// Inputs ordered by the variable always bind it; keep the group for the next key
+			it.pos = len(it.group)
+			continue
+		}
+		if it.hasGroup && key == it.groupKey {
+			// Same key as the previous left binding, replay the group
+			continue
+		}
+
+		// Skip right bindings ordered before the left key, then collect the matching group
+		for !it.rightDone && bytes.Compare(it.rightKey[:], key[:]) < 0 {
+			it.advanceRight()
+		}
+		it.group = nil
+		it.groupKey = key
+		it.hasGroup = true
+		for !it.rightDone && it.rightKey == key {
+			it.group = append(it.group, it.rightNext)
+			it.advanceRight()
+		}
+	}
+}
+
+// advanceRight moves the right lookahead to the next binding
+func (it *mergeJoinIterator) advanceRight() {
+	for it.right.Next() {
+		binding := it.right.Binding()
+		if key, ok := it.key(binding); ok {
+			it.rightNext = binding.Clone()
+			it.rightKey = key
+			return
+		}
+	}
+	it.rightNext = nil
+	it.rightDone = true
+}
+
+// key returns the encoded join variable of a binding
+func (it *mergeJoinIterator) key(binding *store.Binding) (store.EncodedTerm, bool) {
+	if encoded, ok := binding.Encoded(it.variable); ok {
+		return encoded, true
+	}
+	term, ok := binding.Term(it.variable)
+	if !ok {
+		return store.EncodedTerm{}, false
+	}
+	encoded, err := it.store.EncodeTerm(term)
+	if err != nil {
+		return store.EncodedTerm{}, false
+	}
+	return encoded, true
+}
+
+func (it *mergeJoinIterator) Binding() *store.Binding {
+	return it.result
+}
+
+func (it *mergeJoinIterator) Close() error {
+	_ = it.right.Close() // #nosec G104 - right close error less critical than left close error
+	return it.left.Close()
+}
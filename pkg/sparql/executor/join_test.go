@@ -0,0 +1,236 @@
+package executor
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// joinData has subjects with several values for a predicate, so joins see duplicate keys
+// on both sides, values of different term types, and subjects missing from one side
+const joinData = `@prefix ex: <http://example.org/> .
+ex:a ex:name "A1", "A2" ; ex:tag "x", "y", 1, ex:x, "x"@en ; ex:alias "y" ; ex:kind ex:Thing ; ex:rank 1 .
+ex:b ex:name "B" ; ex:tag "x" ; ex:alias "x", "z" ; ex:kind ex:Thing ; ex:rank 2 .
+ex:c ex:name "C" ; ex:kind ex:Thing .
+ex:d ex:tag "w", "x", 1 ; ex:rank 2 .
+ex:e ex:other "x", "w", 1, "y" .
+ex:f ex:other "x", ex:x .
+ex:g1 { ex:a ex:tag "x" ; ex:name "A" . ex:b ex:name "B" . }
+ex:g2 { ex:a ex:name "A" . ex:b ex:tag "z" . }
+`
+
+// joinPlans returns the joins of a plan
+func joinPlans(plan optimizer.QueryPlan) []*optimizer.JoinPlan {
+	switch p := plan.(type) {
+	case *optimizer.JoinPlan:
+		return append(append([]*optimizer.JoinPlan{p}, joinPlans(p.Left)...), joinPlans(p.Right)...)
+	case *optimizer.UnionPlan:
+		return append(joinPlans(p.Left), joinPlans(p.Right)...)
+	case *optimizer.OptionalPlan:
+		return append(joinPlans(p.Left), joinPlans(p.Right)...)
+	case *optimizer.ProjectionPlan:
+		return joinPlans(p.Input)
+	case *optimizer.FilterPlan:
+		return joinPlans(p.Input)
+	case *optimizer.GraphPlan:
+		return joinPlans(p.Input)
+	case *optimizer.BindPlan:
+		return joinPlans(p.Input)
+	default:
+		return nil
+	}
+}
+
+// mentionedVariables returns the variables a plan may bind, whether or not every solution does
+func mentionedVariables(plan optimizer.QueryPlan) map[string]bool {
+	vars := make(map[string]bool)
+	switch p := plan.(type) {
+	case *optimizer.ScanPlan:
+		if p.Pattern.Subject.IsVariable() {
+			vars[p.Pattern.Subject.Variable.Name] = true
+		}
+		if p.Pattern.Predicate.IsVariable() {
+			vars[p.Pattern.Predicate.Variable.Name] = true
+		}
+		if p.Pattern.Object.IsVariable() {
+			vars[p.Pattern.Object.Variable.Name] = true
+		}
+	case *optimizer.JoinPlan:
+		return unionOf(mentionedVariables(p.Left), mentionedVariables(p.Right))
+	case *optimizer.UnionPlan:
+		return unionOf(mentionedVariables(p.Left), mentionedVariables(p.Right))
+	case *optimizer.OptionalPlan:
+		return unionOf(mentionedVariables(p.Left), mentionedVariables(p.Right))
+	case *optimizer.FilterPlan:
+		return mentionedVariables(p.Input)
+	case *optimizer.BindPlan:
+		vars = mentionedVariables(p.Input)
+		vars[p.Variable.Name] = true
+	case *optimizer.GraphPlan:
+		vars = mentionedVariables(p.Input)
+		if p.Graph.Variable != nil {
+			vars[p.Graph.Variable.Name] = true
+		}
+	}
+	return vars
+}
+
+func unionOf(a, b map[string]bool) map[string]bool {
+	for name := range b {
+		a[name] = true
+	}
+	return a
+}
+
+func TestJoinsMatchNestedLoop(t *testing.T) {
+	ts := newTestStore(t, joinData)
+	tests := []struct {
+		name  string
+		query string
+		merge bool // the optimizer chooses a merge join
+	}{
+		{"shared subject", `SELECT * WHERE { ?s ex:name ?n . ?s ex:tag ?t }`, false},
+		{"duplicate keys", `SELECT * WHERE { ?s ex:tag ?t . ?o ex:other ?t }`, true},
+		{"merge on subject", `SELECT * WHERE { ?s ex:kind ex:Thing . ?s ex:tag "x" }`, true},
+		{"merge below a filter", `SELECT * WHERE { ?s ex:tag ?t . ?o ex:other ?t FILTER(?s != ?o) }`, true},
+		{"several keys", `SELECT * WHERE { ?s ex:tag ?t . ?s ex:alias ?t }`, true},
+		{"three patterns", `SELECT * WHERE { ?s ex:name ?n . ?s ex:tag ?t . ?s ex:rank ?r }`, false},
+		{"no shared variables", `SELECT * WHERE { ex:c ex:name ?n . ?o ex:other ?t }`, false},
+		{"key unbound in a union", `SELECT * WHERE { ?s ex:name ?n { ?s ex:tag ?t } UNION { ?x ex:other ?t } }`, false},
+		{"key unbound in an optional", `SELECT * WHERE { { ?s ex:tag ?t OPTIONAL { ?s ex:rank ?r } } ?x ex:rank ?r }`, false},
+		{"graphs", `SELECT * WHERE { GRAPH ?g { ?s ex:tag ?t } GRAPH ?g { ?s ex:name ?n } }`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := "PREFIX ex: <http://example.org/> " + tt.query
+			exec := NewExecutor(ts)
+
+			planned := planQuery(t, ts, query, nil)
+			joins := joinPlans(planned.Plan)
+			if len(joins) == 0 {
+				t.Fatal("expected a join")
+			}
+			merged := false
+			for _, join := range joins {
+				merged = merged || join.Type == optimizer.JoinTypeMergeJoin
+			}
+			if merged != tt.merge {
+				t.Errorf("expected merge join %v, got %v", tt.merge, merged)
+			}
+			actual := selectRows(t, exec, planned)
+
+			nested := planQuery(t, ts, query, nil)
+			for _, join := range joinPlans(nested.Plan) {
+				join.Type = optimizer.JoinTypeNestedLoop
+			}
+			expected := sorted(selectRows(t, exec, nested))
+			if len(expected) == 0 {
+				t.Fatal("expected solutions")
+			}
+			assertSameRows(t, expected, sorted(actual))
+
+			// Hash joins on every variable both sides mention, bound or not, and with
+			// the sides swapped so that either one has the unbound keys
+			for _, swap := range []bool{false, true} {
+				hashed := planQuery(t, ts, query, nil)
+				for _, join := range joinPlans(hashed.Plan) {
+					left, right := mentionedVariables(join.Left), mentionedVariables(join.Right)
+					join.Type = optimizer.JoinTypeHashJoin
+					join.Variables = nil
+					for name := range left {
+						if right[name] {
+							join.Variables = append(join.Variables, name)
+						}
+					}
+					sort.Strings(join.Variables)
+					if swap {
+						join.Left, join.Right = join.Right, join.Left
+					}
+				}
+				assertSameRows(t, expected, sorted(selectRows(t, exec, hashed)))
+			}
+		})
+	}
+}
+
+// sliceIterator returns a fixed list of bindings
+type sliceIterator struct {
+	bindings []*store.Binding
+	pos      int
+}
+
+func (it *sliceIterator) Next() bool {
+	it.pos++
+	return it.pos <= len(it.bindings)
+}
+
+func (it *sliceIterator) Binding() *store.Binding { return it.bindings[it.pos-1] }
+func (it *sliceIterator) Close() error            { return nil }
+
+// bindingOf creates a binding of variables to IRIs, skipping empty values
+func bindingOf(pairs ...string) *store.Binding {
+	binding := store.NewBinding()
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			binding.Set(pairs[i], rdf.NewNamedNode("http://example.org/"+pairs[i+1]))
+		}
+	}
+	return binding
+}
+
+func TestMergeJoinKeepsGroupAcrossUnboundKey(t *testing.T) {
+	ts := newTestStore(t, "")
+	it := &mergeJoinIterator{
+		left: &sliceIterator{bindings: []*store.Binding{
+			bindingOf("s", "a", "l", "1"),
+			bindingOf("s", "", "l", "2"),
+			bindingOf("s", "a", "l", "3"),
+		}},
+		right: &sliceIterator{bindings: []*store.Binding{
+			bindingOf("s", "a", "r", "1"),
+			bindingOf("s", "a", "r", "2"),
+		}},
+		variable: "s",
+		store:    ts,
+	}
+	defer it.Close()
+
+	var rows []string
+	for it.Next() {
+		rows = append(rows, formatBinding(it.Binding()))
+	}
+	// The right group for ex:a is replayed for the third left binding
+	assertSameRows(t, []string{
+		"l=<http://example.org/1> r=<http://example.org/1> s=<http://example.org/a>",
+		"l=<http://example.org/1> r=<http://example.org/2> s=<http://example.org/a>",
+		"l=<http://example.org/3> r=<http://example.org/1> s=<http://example.org/a>",
+		"l=<http://example.org/3> r=<http://example.org/2> s=<http://example.org/a>",
+	}, rows)
+}
+
+func TestNestedLoopJoinReportsErrors(t *testing.T) {
+	ts := newTestStore(t, joinData)
+	exec := NewExecutor(ts)
+	ctx, done := exec.startQuery(context.Background())
+	defer done()
+
+	// The right plan fails to start for the first left binding
+	left, err := exec.createIterator(ctx, planQuery(t, ts,
+		`PREFIX ex: <http://example.org/> SELECT ?s WHERE { ?s ex:name ?n }`, nil).Plan)
+	if err != nil {
+		t.Fatalf("failed to create iterator: %v", err)
+	}
+	it := &nestedLoopJoinIterator{ctx: ctx, left: left, rightPlan: &optimizer.OrderByPlan{}, executor: exec}
+	defer it.Close()
+	if it.Next() {
+		t.Fatal("expected no solutions")
+	}
+	if queryError(ctx) == nil {
+		t.Error("expected the error to be reported")
+	}
+}
@@ -0,0 +1,352 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// trieLevel is one key position of a pattern's trie: a constant to seek to, or a variable
+type trieLevel struct {
+	constant bool
+	value    store.EncodedTerm
+	variable int // index in the plan's variable order
+}
+
+// triePattern is a triple pattern read from a trie
+type triePattern struct {
+	trie   *store.Trie
+	levels [3]trieLevel // in key order
+	depth  int          // levels opened
+}
+
+// leapfrogIterator implements the Leapfrog Triejoin.
+// The variables are bound one at a time in the plan's order. For each, the tries of the
+// patterns using it are opened down to its level and intersected by leapfrogging:
+// the trie with the smallest key seeks to the largest key of the others, until all
+// agree on a value. Each value found is extended by the next variable, backtracking
+// when a variable has no more values.
+type leapfrogIterator struct {
+	ctx       context.Context
+	variables []string
+	patterns  []*triePattern
+
+	participants [][]int // per variable: the patterns using it, sorted by key while intersecting
+	opened       [][]int // per variable and participant: levels opened to reach the variable
+	position     []int   // per variable: the participant to move next
+	values       []store.EncodedTerm
+
+	depth   int // variable being bound, -1 before the first call to Next
+	done    bool
+	terms   *store.TermCache
+	owned   bool // terms is closed with the iterator
+	binding *store.Binding
+}
+
+// createLeapfrogJoinIterator opens a trie per pattern of a leapfrog join
+func (e *Executor) createLeapfrogJoinIterator(ctx context.Context, plan *optimizer.LeapfrogJoinPlan) (store.BindingIterator, error) {
+	var graph rdf.Term
+	if e.graph != nil {
+		if e.graph.Variable != nil {
+			return nil, fmt.Errorf("leapfrog join over a graph variable")
+		}
+		graph = e.graph.IRI
+	}
+
+	it := &leapfrogIterator{
+		ctx:          ctx,
+		variables:    plan.Variables,
+		participants: make([][]int, len(plan.Variables)),
+		opened:       make([][]int, len(plan.Variables)),
+		position:     make([]int, len(plan.Variables)),
+		values:       make([]store.EncodedTerm, len(plan.Variables)),
+		depth:        -1,
+		terms:        termCache(ctx),
+	}
+	if it.terms == nil {
+		it.terms = e.store.NewTermCache()
+		it.owned = true
+	}
+
+	for i, pattern := range plan.Patterns {
+		tp, err := e.newTriePattern(pattern, plan.Orders[i], plan.Variables)
+		if err == nil {
+			tp.trie, err = e.store.OpenTrie(ctx, plan.Orders[i], graph)
+		}
+		if err != nil {
+			_ = it.Close() // #nosec G104 - close error less important than original error
+			return nil, err
+		}
+		it.patterns = append(it.patterns, tp)
+		for _, level := range tp.levels {
+			if !level.constant {
+				it.participants[level.variable] = append(it.participants[level.variable], i)
+			}
+		}
+	}
+	for i := range it.opened {
+		it.opened[i] = make([]int, len(it.participants[i]))
+	}
+	return it, nil
+}
+
+// newTriePattern maps the positions of a pattern to the levels of its trie
+func (e *Executor) newTriePattern(pattern *parser.TriplePattern, order [3]int, variables []string) (*triePattern, error) {
+	positions := []parser.TermOrVariable{pattern.Subject, pattern.Predicate, pattern.Object}
+	tp := &triePattern{}
+	for i, position := range order {
+		t := positions[position]
+		if t.IsVariable() {
+			tp.levels[i].variable = -1
+			for j, name := range variables {
+				if name == t.Variable.Name {
+					tp.levels[i].variable = j
+				}
+			}
+			if tp.levels[i].variable < 0 {
+				return nil, fmt.Errorf("variable ?%s missing from the leapfrog join order", t.Variable.Name)
+			}
+			continue
+		}
+		encoded, err := e.store.EncodeTerm(t.Term)
+		if err != nil {
+			return nil, err
+		}
+		tp.levels[i] = trieLevel{constant: true, value: encoded}
+	}
+	return tp, nil
+}
+
+func (it *leapfrogIterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	var ok bool
+	if it.depth < 0 {
+		it.depth = 0
+		ok = it.open(0)
+	} else {
+		// Move past the solution returned last
+		ok = it.next(it.depth)
+	}
+
+	for {
+		if queryError(it.ctx) != nil {
+			it.done = true
+			return false
+		}
+		if !ok {
+			it.close(it.depth)
+			it.depth--
+			if it.depth < 0 {
+				it.done = true
+				return false
+			}
+			ok = it.next(it.depth)
+			continue
+		}
+		if it.depth == len(it.variables)-1 {
+			it.binding = store.NewBinding()
+			for i, name := range it.variables {
+				it.binding.SetEncoded(name, it.values[i], it.terms)
+			}
+			return true
+		}
+		it.depth++
+		ok = it.open(it.depth)
+	}
+}
+
+// open opens the tries of a variable's patterns down to its level and finds its first value
+func (it *leapfrogIterator) open(depth int) bool {
+	participants := it.participants[depth]
+	for i, p := range participants {
+		opened, ok := it.patterns[p].descend()
+		it.opened[depth][i] = opened
+		if !ok {
+			// Levels not reached are left unopened, for close
+			for j := i + 1; j < len(participants); j++ {
+				it.opened[depth][j] = 0
+			}
+			return false
+		}
+	}
+
+	// Sorting the participants by key sorts their opened level counts with them
+	sort.Sort(byTrieKey{it: it, depth: depth})
+	it.position[depth] = 0
+	return it.search(depth) && it.matchTrailing(depth)
+}
+
+// next finds the next value of a variable
+func (it *leapfrogIterator) next(depth int) bool {
+	return it.advance(depth) && it.matchTrailing(depth)
+}
+
+// advance moves the participant after the current value and leapfrogs to the next common key
+func (it *leapfrogIterator) advance(depth int) bool {
+	participants := it.participants[depth]
+	p := it.position[depth]
+	trie := it.patterns[participants[p]].trie
+	trie.Next()
+	if trie.AtEnd() {
+		return false
+	}
+	it.position[depth] = (p + 1) % len(participants)
+	return it.search(depth)
+}
+
+// search leapfrogs the participants of a variable until they agree on a key
+func (it *leapfrogIterator) search(depth int) bool {
+	participants := it.participants[depth]
+	k := len(participants)
+	p := it.position[depth]
+	maxKey := it.patterns[participants[(p+k-1)%k]].trie.Key()
+	for {
+		trie := it.patterns[participants[p]].trie
+		key := trie.Key()
+		if key == maxKey {
+			it.position[depth] = p
+			it.values[depth] = key
+			return true
+		}
+		trie.Seek(maxKey)
+		if trie.AtEnd() {
+			return false
+		}
+		maxKey = trie.Key()
+		p = (p + 1) % k
+	}
+}
+
+// matchTrailing checks the constants that follow the last variable of a pattern in
+// its key order, for the value just found, moving on to the next value until they match
+func (it *leapfrogIterator) matchTrailing(depth int) bool {
+	for {
+		matched := true
+		for _, p := range it.participants[depth] {
+			if !it.patterns[p].matchTrailing() {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+		if !it.advance(depth) {
+			return false
+		}
+	}
+}
+
+// close returns the tries of a variable's patterns to where they were before open
+func (it *leapfrogIterator) close(depth int) {
+	for i, p := range it.participants[depth] {
+		tp := it.patterns[p]
+		for ; it.opened[depth][i] > 0; it.opened[depth][i]-- {
+			tp.trie.Up()
+			tp.depth--
+		}
+	}
+}
+
+// descend opens a pattern's trie level by level, seeking to its constants,
+// until it reaches the level of its next variable
+func (tp *triePattern) descend() (int, bool) {
+	opened := 0
+	for tp.depth < len(tp.levels) {
+		tp.trie.Open()
+		tp.depth++
+		opened++
+		if tp.trie.AtEnd() {
+			return opened, false
+		}
+		level := tp.levels[tp.depth-1]
+		if !level.constant {
+			return opened, true
+		}
+		tp.trie.Seek(level.value)
+		if tp.trie.AtEnd() || tp.trie.Key() != level.value {
+			return opened, false
+		}
+	}
+	return opened, false
+}
+
+// matchTrailing reports whether the constants after a pattern's current level exist,
+// when no variable of the pattern follows
+func (tp *triePattern) matchTrailing() bool {
+	for _, level := range tp.levels[tp.depth:] {
+		if !level.constant {
+			return true
+		}
+	}
+
+	opened := 0
+	defer func() {
+		for ; opened > 0; opened-- {
+			tp.trie.Up()
+		}
+	}()
+	for _, level := range tp.levels[tp.depth:] {
+		tp.trie.Open()
+		opened++
+		tp.trie.Seek(level.value)
+		if tp.trie.AtEnd() || tp.trie.Key() != level.value {
+			return false
+		}
+	}
+	return true
+}
+
+func (it *leapfrogIterator) Binding() *store.Binding {
+	return it.binding
+}
+
+func (it *leapfrogIterator) Close() error {
+	var firstErr error
+	for _, tp := range it.patterns {
+		if err := tp.trie.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	it.patterns = nil
+	it.done = true
+	if it.owned {
+		if err := it.terms.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// byTrieKey sorts the participants of a variable by the keys of their tries
+type byTrieKey struct {
+	it    *leapfrogIterator
+	depth int
+}
+
+func (s byTrieKey) Len() int {
+	return len(s.it.participants[s.depth])
+}
+
+func (s byTrieKey) Less(i, j int) bool {
+	participants := s.it.participants[s.depth]
+	a := s.it.patterns[participants[i]].trie.Key()
+	b := s.it.patterns[participants[j]].trie.Key()
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+func (s byTrieKey) Swap(i, j int) {
+	participants := s.it.participants[s.depth]
+	opened := s.it.opened[s.depth]
+	participants[i], participants[j] = participants[j], participants[i]
+	opened[i], opened[j] = opened[j], opened[i]
+}
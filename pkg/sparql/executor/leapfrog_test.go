@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+)
+
+// leapfrogData has triangles and a 4-clique among ex:a to ex:d, cycles that aren't
+// triangles through ex:e and ex:f, and a named graph holding another set of edges
+const leapfrogData = `@prefix ex: <http://example.org/> .
+ex:a ex:knows ex:b, ex:c, ex:d . ex:b ex:knows ex:c, ex:d . ex:c ex:knows ex:d, ex:a .
+ex:d ex:knows ex:e . ex:e ex:knows ex:a, ex:f . ex:f ex:knows ex:d .
+ex:a ex:likes ex:b . ex:b ex:likes ex:c . ex:c ex:likes ex:a, ex:b .
+ex:a ex:type ex:Person . ex:b ex:type ex:Person . ex:d ex:type ex:Person .
+ex:g { ex:a ex:knows ex:b . ex:b ex:knows ex:c . ex:c ex:knows ex:a, ex:b . ex:b ex:type ex:Person . ex:c ex:type ex:Person . }
+`
+
+// leapfrogJoin returns the leapfrog join of a plan, nil if it has none
+func leapfrogJoin(plan optimizer.QueryPlan) *optimizer.LeapfrogJoinPlan {
+	for _, node := range planNodes(plan) {
+		if leapfrog, ok := node.(*optimizer.LeapfrogJoinPlan); ok {
+			return leapfrog
+		}
+	}
+	return nil
+}
+
+// pairwise replaces the leapfrog join of a plan by nested loop joins of its patterns
+func pairwise(plan optimizer.QueryPlan) optimizer.QueryPlan {
+	switch p := plan.(type) {
+	case *optimizer.LeapfrogJoinPlan:
+		var joined optimizer.QueryPlan
+		for _, pattern := range p.Patterns {
+			var scan optimizer.QueryPlan = &optimizer.ScanPlan{Pattern: pattern}
+			if joined != nil {
+				scan = &optimizer.JoinPlan{Left: joined, Right: scan, Type: optimizer.JoinTypeNestedLoop}
+			}
+			joined = scan
+		}
+		return joined
+	case *optimizer.ProjectionPlan:
+		p.Input = pairwise(p.Input)
+	case *optimizer.GraphPlan:
+		p.Input = pairwise(p.Input)
+	case *optimizer.BindPlan:
+		p.Input = pairwise(p.Input)
+	case *optimizer.FilterPlan:
+		p.Input = pairwise(p.Input)
+	case *optimizer.JoinPlan:
+		p.Left, p.Right = pairwise(p.Left), pairwise(p.Right)
+	}
+	return plan
+}
+
+// hasTrailingConstant reports whether a leapfrog join reads a pattern whose key
+// order has a constant after the pattern's last variable
+func hasTrailingConstant(plan *optimizer.LeapfrogJoinPlan) bool {
+	for i, pattern := range plan.Patterns {
+		positions := []parser.TermOrVariable{pattern.Subject, pattern.Predicate, pattern.Object}
+		variable := false
+		for _, position := range plan.Orders[i] {
+			if positions[position].IsVariable() {
+				variable = true
+			} else if variable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestLeapfrogMatchesPairwiseJoins(t *testing.T) {
+	ts := newTestStore(t, leapfrogData)
+	exec := NewExecutor(ts)
+
+	tests := []struct {
+		name  string
+		query string
+		rows  int
+	}{
+		{"triangle", `SELECT * WHERE { ?x ex:knows ?y . ?y ex:knows ?z . ?x ex:knows ?z }`, 5},
+		{"directed cycle", `SELECT * WHERE { ?x ex:knows ?y . ?y ex:knows ?z . ?z ex:knows ?x }`, 9},
+		{"4-clique", `SELECT * WHERE { ?a ex:knows ?b . ?a ex:knows ?c . ?a ex:knows ?d .
+			?b ex:knows ?c . ?b ex:knows ?d . ?c ex:knows ?d }`, 1},
+		{"triangle over two predicates", `SELECT * WHERE { ?x ex:knows ?y . ?y ex:likes ?z . ?z ex:knows ?x }`, 3},
+		{"two triangles sharing an edge", `SELECT * WHERE { ?x ex:knows ?y . ?y ex:knows ?z . ?x ex:knows ?z . ?z ex:likes ?x }`, 1},
+		{"graph with a constant pattern", `SELECT * WHERE { GRAPH <http://example.org/g> {
+			?x ex:knows ?y . ?y ex:knows ?z . ?z ex:knows ?x . ?y ex:type ex:Person } }`, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := "PREFIX ex: <http://example.org/> " + tt.query
+			planned := planQuery(t, ts, query, nil)
+			leapfrog := leapfrogJoin(planned.Plan)
+			if leapfrog == nil {
+				t.Fatal("expected a leapfrog join")
+			}
+			actual := sorted(selectRows(t, exec, planned))
+
+			expected := planQuery(t, ts, query, nil)
+			expected.Plan = pairwise(expected.Plan)
+			if leapfrogJoin(expected.Plan) != nil {
+				t.Fatal("expected the leapfrog join to be replaced")
+			}
+			expectedRows := sorted(selectRows(t, exec, expected))
+			if len(expectedRows) != tt.rows {
+				t.Errorf("expected %d solutions from pairwise joins, got %d", tt.rows, len(expectedRows))
+			}
+			assertSameRows(t, expectedRows, actual)
+
+			// The constants of patterns read by their subject first come after their variable,
+			// and are matched for each value of it instead of narrowing the trie up front
+			trailing := planQuery(t, ts, query, nil)
+			leapfrog = leapfrogJoin(trailing.Plan)
+			for i, pattern := range leapfrog.Patterns {
+				if pattern.Subject.IsVariable() && !pattern.Predicate.IsVariable() && !pattern.Object.IsVariable() {
+					leapfrog.Orders[i] = [3]int{0, 1, 2}
+				}
+			}
+			if strings.Contains(tt.name, "constant") && !hasTrailingConstant(leapfrog) {
+				t.Fatal("expected a pattern with a trailing constant")
+			}
+			assertSameRows(t, expectedRows, sorted(selectRows(t, exec, trailing)))
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+)
+
+const optionalData = `@prefix ex: <http://example.org/> .
+ex:a ex:name "A" ; ex:min 1 ; ex:rank 1, 5 .
+ex:b ex:name "B" ; ex:min 3 ; ex:rank 2 .
+ex:c ex:name "C" ; ex:min 0 .
+ex:d ex:label "D" .
+`
+
+// planNodes returns the nodes of a plan, parents before their children
+func planNodes(plan optimizer.QueryPlan) []optimizer.QueryPlan {
+	nodes := []optimizer.QueryPlan{plan}
+	switch p := plan.(type) {
+	case *optimizer.JoinPlan:
+		nodes = append(append(nodes, planNodes(p.Left)...), planNodes(p.Right)...)
+	case *optimizer.OptionalPlan:
+		nodes = append(append(nodes, planNodes(p.Left)...), planNodes(p.Right)...)
+	case *optimizer.MinusPlan:
+		nodes = append(append(nodes, planNodes(p.Left)...), planNodes(p.Right)...)
+	case *optimizer.UnionPlan:
+		nodes = append(append(nodes, planNodes(p.Left)...), planNodes(p.Right)...)
+	case *optimizer.ProjectionPlan:
+		nodes = append(nodes, planNodes(p.Input)...)
+	case *optimizer.FilterPlan:
+		nodes = append(nodes, planNodes(p.Input)...)
+	case *optimizer.GraphPlan:
+		nodes = append(nodes, planNodes(p.Input)...)
+	case *optimizer.BindPlan:
+		nodes = append(nodes, planNodes(p.Input)...)
+	}
+	return nodes
+}
+
+// optionalPlan returns the only OPTIONAL of a plan
+func optionalPlan(t *testing.T, plan optimizer.QueryPlan) *optimizer.OptionalPlan {
+	t.Helper()
+	var found *optimizer.OptionalPlan
+	for _, node := range planNodes(plan) {
+		if optional, ok := node.(*optimizer.OptionalPlan); ok {
+			if found != nil {
+				t.Fatal("expected a single OPTIONAL")
+			}
+			found = optional
+		}
+	}
+	if found == nil {
+		t.Fatal("expected an OPTIONAL")
+	}
+	return found
+}
+
+func TestOptional(t *testing.T) {
+	ts := newTestStore(t, optionalData)
+	tests := []struct {
+		name     string
+		query    string
+		joinType optimizer.JoinType
+		filters  int // filters moved into the join
+		expected []string
+	}{
+		{
+			name:     "hash left outer join",
+			query:    `SELECT ?s ?r WHERE { ?s ex:name ?n OPTIONAL { ?s ex:rank ?r } }`,
+			joinType: optimizer.JoinTypeHashJoin,
+			expected: []string{
+				"r=\"1\"^^<http://www.w3.org/2001/XMLSchema#integer> s=<http://example.org/a>",
+				"r=\"2\"^^<http://www.w3.org/2001/XMLSchema#integer> s=<http://example.org/b>",
+				"r=\"5\"^^<http://www.w3.org/2001/XMLSchema#integer> s=<http://example.org/a>",
+				"s=<http://example.org/c>",
+			},
+		},
+		{
+			name:     "filter on the left side's variables",
+			query:    `SELECT ?s ?r WHERE { ?s ex:min ?m OPTIONAL { ?s ex:rank ?r FILTER(?r > ?m) } }`,
+			joinType: optimizer.JoinTypeHashJoin,
+			filters:  1,
+			expected: []string{
+				"r=\"5\"^^<http://www.w3.org/2001/XMLSchema#integer> s=<http://example.org/a>",
+				"s=<http://example.org/b>",
+				"s=<http://example.org/c>",
+			},
+		},
+		{
+			name:     "filters on both sides' variables",
+			query:    `SELECT ?s ?r WHERE { ?s ex:min ?m OPTIONAL { ?s ex:rank ?r FILTER(?r > ?m) FILTER(?r < 5) } }`,
+			joinType: optimizer.JoinTypeHashJoin,
+			filters:  1,
+			expected: []string{
+				"s=<http://example.org/a>",
+				"s=<http://example.org/b>",
+				"s=<http://example.org/c>",
+			},
+		},
+		{
+			name:     "filter on the right side's variables",
+			query:    `SELECT ?s ?r WHERE { ?s ex:min ?m OPTIONAL { ?s ex:rank ?r FILTER(?r > 1) } }`,
+			joinType: optimizer.JoinTypeHashJoin,
+			expected: []string{
+				"r=\"2\"^^<http://www.w3.org/2001/XMLSchema#integer> s=<http://example.org/b>",
+				"r=\"5\"^^<http://www.w3.org/2001/XMLSchema#integer> s=<http://example.org/a>",
+				"s=<http://example.org/c>",
+			},
+		},
+		{
+			name:     "single left row",
+			query:    `SELECT ?r WHERE { ex:a ex:name "A" OPTIONAL { ex:a ex:rank ?r FILTER(?r > 1) } }`,
+			joinType: optimizer.JoinTypeNestedLoop,
+			expected: []string{"r=\"5\"^^<http://www.w3.org/2001/XMLSchema#integer>"},
+		},
+		{
+			name:     "single left row without a match",
+			query:    `SELECT ?r WHERE { ex:c ex:name "C" OPTIONAL { ex:c ex:rank ?r } }`,
+			joinType: optimizer.JoinTypeNestedLoop,
+			expected: []string{""},
+		},
+		{
+			name:     "no left row",
+			query:    `SELECT ?r WHERE { ex:d ex:name "D" OPTIONAL { ex:d ex:rank ?r } }`,
+			joinType: optimizer.JoinTypeNestedLoop,
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := "PREFIX ex: <http://example.org/> " + tt.query
+			exec := NewExecutor(ts)
+
+			plan := planQuery(t, ts, query, nil)
+			optional := optionalPlan(t, plan.Plan)
+			if optional.Type != tt.joinType {
+				t.Errorf("expected %v, got %v", tt.joinType, optional.Type)
+			}
+			if len(optional.Filters) != tt.filters {
+				t.Errorf("expected %d filters in the join, got %d", tt.filters, len(optional.Filters))
+			}
+			for _, filter := range optional.Filters {
+				// A filter moved into the join must not also stay on the right side
+				for _, node := range planNodes(optional.Right) {
+					if filterPlan, ok := node.(*optimizer.FilterPlan); ok && filterPlan.Filter == filter {
+						t.Error("filter both in the join and on the right side")
+					}
+				}
+			}
+			assertSameRows(t, tt.expected, sorted(selectRows(t, exec, plan)))
+
+			// The other join type gives the same solutions
+			other := planQuery(t, ts, query, nil)
+			optional = optionalPlan(t, other.Plan)
+			if optional.Type == optimizer.JoinTypeHashJoin {
+				optional.Type = optimizer.JoinTypeNestedLoop
+			} else {
+				optional.Type = optimizer.JoinTypeHashJoin
+			}
+			assertSameRows(t, tt.expected, sorted(selectRows(t, exec, other)))
+		})
+	}
+}
+
+func TestMinus(t *testing.T) {
+	ts := newTestStore(t, optionalData)
+	tests := []struct {
+		name     string
+		query    string
+		dropped  bool
+		expected []string
+	}{
+		{
+			name:     "shared variable",
+			query:    `SELECT ?s WHERE { ?s ex:name ?n MINUS { ?s ex:rank ?r } }`,
+			expected: []string{"s=<http://example.org/c>"},
+		},
+		{
+			name:    "no shared variables",
+			query:   `SELECT ?s WHERE { ?s ex:name ?n MINUS { ?x ex:rank ?r } }`,
+			dropped: true,
+			expected: []string{
+				"s=<http://example.org/a>",
+				"s=<http://example.org/b>",
+				"s=<http://example.org/c>",
+			},
+		},
+		{
+			name:    "no variables on the right",
+			query:   `SELECT ?s WHERE { ?s ex:name ?n MINUS { ex:a ex:rank 1 } }`,
+			dropped: true,
+			expected: []string{
+				"s=<http://example.org/a>",
+				"s=<http://example.org/b>",
+				"s=<http://example.org/c>",
+			},
+		},
+		{
+			name:     "variable only bound in some solutions",
+			query:    `SELECT ?s WHERE { ?s ex:name ?n OPTIONAL { ?s ex:rank ?r } MINUS { ?x ex:rank ?r FILTER(?r > 1) } }`,
+			expected: []string{"s=<http://example.org/a>", "s=<http://example.org/c>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := "PREFIX ex: <http://example.org/> " + tt.query
+			exec := NewExecutor(ts)
+
+			plan := planQuery(t, ts, query, nil)
+			var minus *optimizer.MinusPlan
+			for _, node := range planNodes(plan.Plan) {
+				if p, ok := node.(*optimizer.MinusPlan); ok {
+					minus = p
+				}
+			}
+			if dropped := minus == nil; dropped != tt.dropped {
+				t.Errorf("expected MINUS dropped %v, got %v", tt.dropped, dropped)
+			}
+			assertSameRows(t, tt.expected, sorted(selectRows(t, exec, plan)))
+			if !tt.dropped {
+				return
+			}
+
+			// A MINUS whose right side binds none of the left side's variables removes nothing
+			parsed := planQuery(t, ts, query, nil)
+			projection, ok := parsed.Plan.(*optimizer.ProjectionPlan)
+			if !ok {
+				t.Fatalf("expected a projection, got %T", parsed.Plan)
+			}
+			right := planQuery(t, ts, "PREFIX ex: <http://example.org/> SELECT * WHERE { ?x ex:rank ?r }", nil)
+			projection.Input = &optimizer.MinusPlan{Left: projection.Input, Right: right.Plan}
+			assertSameRows(t, tt.expected, sorted(selectRows(t, exec, parsed)))
+		})
+	}
+}
+
+func TestOptionalReportsErrors(t *testing.T) {
+	ts := newTestStore(t, optionalData)
+	exec := NewExecutor(ts)
+	ctx, done := exec.startQuery(context.Background())
+	defer done()
+
+	// The right plan fails to start for the first left binding
+	left, err := exec.createIterator(ctx, planQuery(t, ts,
+		`PREFIX ex: <http://example.org/> SELECT ?s WHERE { ?s ex:name ?n }`, nil).Plan)
+	if err != nil {
+		t.Fatalf("failed to create iterator: %v", err)
+	}
+	it := &optionalIterator{ctx: ctx, left: left, rightPlan: &optimizer.OrderByPlan{}, executor: exec}
+	defer it.Close()
+	if it.Next() {
+		t.Fatal("expected no solutions")
+	}
+	if queryError(ctx) == nil {
+		t.Error("expected the error to be reported")
+	}
+}
@@ -0,0 +1,354 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// parallelBatchSize is the number of solutions a worker hands over at once
+const parallelBatchSize = 256
+
+// partitionsPerWorker is the number of key ranges a partitioned scan is split into per
+// goroutine, so workers that finish early take over ranges from the others
+const partitionsPerWorker = 4
+
+// SetParallelism sets the number of goroutines a query may run on, its degree of parallelism.
+// With 1, the default, queries run on the calling goroutine only. Zero or less restores the default.
+func (e *Executor) SetParallelism(n int) {
+	if n <= 0 {
+		n = 1
+	}
+	e.parallelism = n
+}
+
+// parallelismKey is the context key of a query's degree of parallelism
+type parallelismKey struct{}
+
+// WithParallelism returns a context whose queries run on at most n goroutines,
+// lowering the executor's degree of parallelism for them
+func WithParallelism(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, parallelismKey{}, n)
+}
+
+// queryParallelism returns the degree of parallelism of a query started with ctx
+func (e *Executor) queryParallelism(ctx context.Context) int {
+	n := max(e.parallelism, 1)
+	if limit, ok := ctx.Value(parallelismKey{}).(int); ok && limit > 0 && limit < n {
+		n = limit
+	}
+	return n
+}
+
+// parallelTask creates an iterator to run on a worker goroutine
+type parallelTask func(ctx context.Context) (store.BindingIterator, error)
+
+// parallelIterator runs tasks on worker goroutines, up to the query's degree of parallelism,
+// and returns their solutions in the order they are handed over. Workers take the next task
+// when they finish one. Without a free worker, the tasks run one after the other on the
+// calling goroutine, in order.
+type parallelIterator struct {
+	parent context.Context
+	ctx    context.Context // cancelled when the iterator is closed
+	cancel context.CancelFunc
+	tasks  []parallelTask
+
+	mu   sync.Mutex
+	next int // next task to run
+
+	started bool
+	serial  bool
+	results chan []*store.Binding
+	wg      sync.WaitGroup
+
+	current store.BindingIterator // task run on the calling goroutine
+	batch   []*store.Binding
+	pos     int
+	binding *store.Binding
+	closed  bool
+}
+
+// newParallelIterator creates an iterator over the solutions of several tasks
+func newParallelIterator(ctx context.Context, tasks []parallelTask) *parallelIterator {
+	workerCtx, cancel := context.WithCancel(ctx)
+	return &parallelIterator{
+		parent: ctx,
+		ctx:    workerCtx,
+		cancel: cancel,
+		tasks:  tasks,
+	}
+}
+
+// newSerialIterator creates an iterator over the solutions of several tasks, run in order
+// on the calling goroutine
+func newSerialIterator(ctx context.Context, tasks []parallelTask) *parallelIterator {
+	it := newParallelIterator(ctx, tasks)
+	it.started, it.serial = true, true
+	return it
+}
+
+func (it *parallelIterator) Next() bool {
+	if it.closed {
+		return false
+	}
+	if !it.started {
+		it.start()
+	}
+	if it.serial {
+		return it.nextSerial()
+	}
+
+	for it.pos >= len(it.batch) {
+		batch, ok := <-it.results
+		if !ok {
+			it.binding = nil
+			return false
+		}
+		it.batch, it.pos = batch, 0
+	}
+	it.binding = it.batch[it.pos]
+	it.batch[it.pos] = nil
+	it.pos++
+	return true
+}
+
+// start launches a worker per task, as far as the query has workers left
+func (it *parallelIterator) start() {
+	it.started = true
+	workers := 0
+	for workers < len(it.tasks) && acquireWorker(it.parent) {
+		workers++
+	}
+	if workers == 0 {
+		it.serial = true
+		return
+	}
+
+	it.results = make(chan []*store.Binding, workers)
+	it.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go it.work()
+	}
+	go func() {
+		it.wg.Wait()
+		close(it.results)
+	}()
+}
+
+// work runs tasks until none are left
+func (it *parallelIterator) work() {
+	defer it.wg.Done()
+	defer releaseWorker(it.parent)
+	for {
+		task, ok := it.take()
+		if !ok || !it.run(task) {
+			return
+		}
+	}
+}
+
+// take returns the next task to run
+func (it *parallelIterator) take() (parallelTask, bool) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.next >= len(it.tasks) {
+		return nil, false
+	}
+	task := it.tasks[it.next]
+	it.next++
+	return task, true
+}
+
+// run hands the solutions of a task over in batches, returning false once the iterator was
+// closed. A task that fails, or finds the query failed, stops the other workers too.
+func (it *parallelIterator) run(task parallelTask) bool {
+	input, err := task(it.ctx)
+	if err != nil {
+		failQuery(it.parent, err)
+		it.cancel()
+		return false
+	}
+	defer input.Close() // #nosec G104 - close error doesn't affect the solutions handed over
+
+	batch := make([]*store.Binding, 0, parallelBatchSize)
+	for input.Next() {
+		// The input may reuse its binding once it moves on
+		batch = append(batch, input.Binding().Clone())
+		if len(batch) == parallelBatchSize {
+			if !it.send(batch) {
+				return false
+			}
+			batch = make([]*store.Binding, 0, parallelBatchSize)
+		}
+	}
+	if queryError(it.ctx) != nil {
+		it.cancel()
+		return false
+	}
+	if len(batch) > 0 {
+		return it.send(batch)
+	}
+	return true
+}
+
+// send hands a batch over to the calling goroutine
+func (it *parallelIterator) send(batch []*store.Binding) bool {
+	select {
+	case it.results <- batch:
+		return true
+	case <-it.ctx.Done():
+		return false
+	}
+}
+
+// nextSerial runs the tasks one after the other on the calling goroutine
+func (it *parallelIterator) nextSerial() bool {
+	for {
+		if it.current == nil {
+			task, ok := it.take()
+			if !ok {
+				it.binding = nil
+				return false
+			}
+			input, err := task(it.ctx)
+			if err != nil {
+				failQuery(it.parent, err)
+				return false
+			}
+			it.current = input
+		}
+		if it.current.Next() {
+			it.binding = it.current.Binding()
+			return true
+		}
+		_ = it.current.Close() // #nosec G104 - close error doesn't affect iteration logic
+		it.current = nil
+	}
+}
+
+func (it *parallelIterator) Binding() *store.Binding {
+	return it.binding
+}
+
+// Close stops the workers and waits for them to release their iterators
+func (it *parallelIterator) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	it.cancel()
+	if it.started && !it.serial {
+		it.wg.Wait()
+	}
+	if it.current != nil {
+		return it.current.Close()
+	}
+	return nil
+}
+
+// scanRangeKey is the context key of the key range a partitioned scan reads
+type scanRangeKey struct{}
+
+// scanRange is the part of a partitioned scan read by one task
+type scanRange struct {
+	scan *optimizer.ScanPlan
+	keys store.ScanRange
+}
+
+// partitionedScan returns the scan to partition when a plan is a pipeline of filters and
+// binds over a scan the optimizer marked as parallel, for queries running on several goroutines.
+// Each partition runs the whole pipeline, so its filters are evaluated in parallel too.
+func partitionedScan(ctx context.Context, plan optimizer.QueryPlan) (*optimizer.ScanPlan, bool) {
+	if parallelism(ctx) < 2 || ctx.Value(scanRangeKey{}) != nil {
+		return nil, false
+	}
+	for {
+		switch p := plan.(type) {
+		case *optimizer.ScanPlan:
+			return p, p.Parallel
+		case *optimizer.FilterPlan:
+			plan = p.Input
+		case *optimizer.BindPlan:
+			plan = p.Input
+		default:
+			return nil, false
+		}
+	}
+}
+
+// createPartitionedIterator splits the key range of a scan and runs the pipeline above it
+// once per range, on worker goroutines
+func (e *Executor) createPartitionedIterator(ctx context.Context, plan optimizer.QueryPlan, scan *optimizer.ScanPlan) (store.BindingIterator, bool, error) {
+	ranges, err := e.store.PartitionQuery(ctx, e.storePattern(scan), parallelism(ctx)*partitionsPerWorker)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(ranges) < 2 {
+		return nil, false, nil
+	}
+
+	tasks := make([]parallelTask, len(ranges))
+	for i, keys := range ranges {
+		part := &scanRange{scan: scan, keys: keys}
+		tasks[i] = func(ctx context.Context) (store.BindingIterator, error) {
+			return e.createPlanIterator(context.WithValue(ctx, scanRangeKey{}, part), plan)
+		}
+	}
+	return newParallelIterator(ctx, tasks), true, nil
+}
+
+// tableBuild builds the hash table of a join input. With a worker free, the table is built
+// in the background as soon as the join is created, so the build phases of the joins of
+// a plan run in parallel with each other and with the probe sides; otherwise it is built
+// when first needed.
+type tableBuild struct {
+	ctx       context.Context
+	store     *store.TripleStore
+	input     store.BindingIterator
+	variables []string
+
+	table *hashTable
+	done  chan struct{} // closed when a background build finishes, nil without one
+	stop  atomic.Bool   // asks a background build to stop early
+}
+
+// newTableBuild starts building the hash table of a join input
+func (e *Executor) newTableBuild(ctx context.Context, input store.BindingIterator, variables []string) *tableBuild {
+	b := &tableBuild{
+		ctx:       ctx,
+		store:     e.store,
+		input:     input,
+		variables: variables,
+	}
+	if acquireWorker(ctx) {
+		b.done = make(chan struct{})
+		go func() {
+			defer close(b.done)
+			defer releaseWorker(ctx)
+			b.table = buildHashTable(b.store, b.input, b.variables, &b.stop)
+		}()
+	}
+	return b
+}
+
+// wait returns the hash table, building it or waiting for its background build
+func (b *tableBuild) wait() *hashTable {
+	if b.done != nil {
+		<-b.done
+	} else if b.table == nil {
+		b.table = buildHashTable(b.store, b.input, b.variables, &b.stop)
+	}
+	return b.table
+}
+
+// close stops a background build and closes the input
+func (b *tableBuild) close() error {
+	b.stop.Store(true)
+	if b.done != nil {
+		<-b.done
+	}
+	return b.input.Close()
+}
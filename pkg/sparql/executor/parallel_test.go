@@ -0,0 +1,257 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// parallelData returns a TriG document of items spread over the default graph and three
+// named graphs, enough for scans to split into several key ranges
+func parallelData() string {
+	var doc strings.Builder
+	doc.WriteString("@prefix ex: <http://example.org/> .\n")
+	for i := 0; i < 600; i++ {
+		triples := fmt.Sprintf("ex:item%d ex:value %d ; ex:label \"label %d\" ; ex:group ex:group%d .\n", i, i, i%7, i%5)
+		if i%4 == 0 {
+			doc.WriteString(triples)
+		} else {
+			fmt.Fprintf(&doc, "ex:graph%d { %s }\n", i%4, triples)
+		}
+	}
+	return doc.String()
+}
+
+// largeStatistics makes the optimizer estimate every scan large enough to run in parallel
+var largeStatistics = &optimizer.Statistics{TotalTriples: 100_000_000}
+
+// graphReader is an authorizer that can read the default graph and the named graphs listed
+type graphReader map[string]bool
+
+func (r graphReader) CanRead(graph rdf.Term) bool {
+	if _, ok := graph.(*rdf.DefaultGraph); ok {
+		return true
+	}
+	return r[graph.String()]
+}
+
+func (r graphReader) CanWrite(rdf.Term) bool { return false }
+
+// waitForGoroutines waits for the number of goroutines to drop back to a baseline
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running, expected at most %d", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestParallelExecutionMatchesSerial(t *testing.T) {
+	ts := newTestStore(t, parallelData())
+	queries := map[string]string{
+		"scan":     `PREFIX ex: <http://example.org/> SELECT ?s ?v WHERE { ?s ex:value ?v }`,
+		"filter":   `PREFIX ex: <http://example.org/> SELECT ?s WHERE { GRAPH ?g { ?s ex:value ?v FILTER(?v > 250) } }`,
+		"bind":     `PREFIX ex: <http://example.org/> SELECT ?s ?w WHERE { GRAPH ?g { ?s ex:value ?v BIND(?v * 2 AS ?w) } }`,
+		"join":     `PREFIX ex: <http://example.org/> SELECT ?s ?v ?l WHERE { GRAPH ?g { ?s ex:value ?v . ?s ex:label ?l } }`,
+		"union":    `PREFIX ex: <http://example.org/> SELECT ?s ?x WHERE { { ?s ex:value ?x } UNION { GRAPH <http://example.org/graph1> { ?s ex:label ?x } } }`,
+		"optional": `PREFIX ex: <http://example.org/> SELECT ?s ?l WHERE { GRAPH ?g { ?s ex:group ex:group1 OPTIONAL { ?s ex:label ?l } } }`,
+		"distinct": `PREFIX ex: <http://example.org/> SELECT DISTINCT ?l WHERE { GRAPH ?g { ?s ex:label ?l } }`,
+		"order":    `PREFIX ex: <http://example.org/> SELECT ?s ?v WHERE { GRAPH ?g { ?s ex:value ?v } } ORDER BY DESC(?v)`,
+		"group":    `PREFIX ex: <http://example.org/> SELECT ?grp (COUNT(?s) AS ?n) WHERE { GRAPH ?g { ?s ex:group ?grp } } GROUP BY ?grp`,
+	}
+
+	for name, query := range queries {
+		t.Run(name, func(t *testing.T) {
+			plan := planQuery(t, ts, query, largeStatistics)
+
+			serial := NewExecutor(ts)
+			expected := selectRows(t, serial, plan)
+			if len(expected) == 0 {
+				t.Fatal("expected solutions")
+			}
+
+			parallel := NewExecutor(ts)
+			parallel.SetParallelism(8)
+			actual := selectRows(t, parallel, plan)
+			if name == "order" {
+				// ORDER BY fixes the order whatever the scans below it return
+				assertSameRows(t, expected, actual)
+				return
+			}
+			assertSameRows(t, sorted(expected), sorted(actual))
+		})
+	}
+}
+
+func TestPartitionedScan(t *testing.T) {
+	ts := newTestStore(t, parallelData())
+	plan := planQuery(t, ts, `PREFIX ex: <http://example.org/> SELECT ?s ?v WHERE { GRAPH ?g { ?s ex:value ?v } }`, largeStatistics)
+
+	exec := NewExecutor(ts)
+	exec.SetParallelism(8)
+	ctx, done := exec.startQuery(context.Background())
+	defer done()
+
+	// The scan is split, so the parallel results above come from partitioned scans
+	var scan *optimizer.ScanPlan
+	for node := plan.Plan; scan == nil; {
+		switch p := node.(type) {
+		case *optimizer.ProjectionPlan:
+			node = p.Input
+		case *optimizer.GraphPlan:
+			node = p.Input
+		case *optimizer.ScanPlan:
+			scan = p
+		default:
+			t.Fatalf("unexpected plan node %T", node)
+		}
+	}
+	if _, ok := partitionedScan(ctx, scan); !ok {
+		t.Fatal("expected the scan to be partitioned")
+	}
+	ranges, err := ts.PartitionQuery(ctx, exec.storePattern(scan), 8*partitionsPerWorker)
+	if err != nil {
+		t.Fatalf("failed to partition: %v", err)
+	}
+	if len(ranges) < 2 {
+		t.Fatalf("expected several key ranges, got %d", len(ranges))
+	}
+
+	// Partitioned scans only return quads from graphs the caller can read
+	reader := graphReader{"<http://example.org/graph1>": true}
+	restricted := ts.WithAuthorizer(reader)
+	serial := NewExecutor(restricted)
+	expected := selectRows(t, serial, plan)
+	parallel := NewExecutor(restricted)
+	parallel.SetParallelism(8)
+	actual := selectRows(t, parallel, plan)
+	assertSameRows(t, sorted(expected), sorted(actual))
+	if len(actual) != 150 {
+		t.Errorf("expected the 150 items of the readable graph, got %d", len(actual))
+	}
+	for _, row := range actual {
+		if strings.Contains(row, "graph2") || strings.Contains(row, "graph3") {
+			t.Errorf("solution from an unreadable graph: %s", row)
+		}
+	}
+}
+
+// endlessIterator returns empty solutions until its context is cancelled
+type endlessIterator struct {
+	ctx     context.Context
+	binding *store.Binding
+}
+
+func (it *endlessIterator) Next() bool              { return it.ctx.Err() == nil }
+func (it *endlessIterator) Binding() *store.Binding { return it.binding }
+func (it *endlessIterator) Close() error            { return nil }
+
+func endlessTask(ctx context.Context) (store.BindingIterator, error) {
+	return &endlessIterator{ctx: ctx, binding: store.NewBinding()}, nil
+}
+
+func TestParallelIteratorFailure(t *testing.T) {
+	ts := newTestStore(t, "")
+	baseline := runtime.NumGoroutine()
+
+	exec := NewExecutor(ts)
+	exec.SetParallelism(8)
+	ctx, done := exec.startQuery(context.Background())
+
+	// A failing task stops the workers running endless ones
+	failure := errors.New("task failed")
+	tasks := []parallelTask{endlessTask, endlessTask, endlessTask,
+		func(context.Context) (store.BindingIterator, error) { return nil, failure },
+		endlessTask}
+	it := newParallelIterator(ctx, tasks)
+	finished := make(chan int)
+	go func() {
+		rows := 0
+		for it.Next() {
+			rows++
+		}
+		finished <- rows
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the iterator kept running after a task failed")
+	}
+	if err := queryError(ctx); !errors.Is(err, failure) {
+		t.Errorf("expected the task's error, got %v", err)
+	}
+	it.Close()
+	done()
+	waitForGoroutines(t, baseline)
+}
+
+func TestParallelIteratorCancellation(t *testing.T) {
+	ts := newTestStore(t, "")
+	baseline := runtime.NumGoroutine()
+
+	exec := NewExecutor(ts)
+	exec.SetParallelism(8)
+	parent, cancel := context.WithCancel(context.Background())
+	ctx, done := exec.startQuery(parent)
+
+	it := newParallelIterator(ctx, []parallelTask{endlessTask, endlessTask, endlessTask, endlessTask})
+	for i := 0; i < 1000; i++ {
+		if !it.Next() {
+			t.Fatal("expected endless solutions")
+		}
+	}
+	cancel()
+	finished := make(chan struct{})
+	go func() {
+		for it.Next() {
+		}
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the iterator kept running after the query was cancelled")
+	}
+	if err := queryError(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	it.Close()
+	done()
+	waitForGoroutines(t, baseline)
+}
+
+func TestParallelQueryClosedEarly(t *testing.T) {
+	ts := newTestStore(t, parallelData())
+	baseline := runtime.NumGoroutine()
+
+	// Closing a cursor after one row stops partitioned scans and background hash table builds
+	exec := NewExecutor(ts)
+	exec.SetParallelism(8)
+	for _, query := range []string{
+		`PREFIX ex: <http://example.org/> SELECT ?s ?v WHERE { GRAPH ?g { ?s ex:value ?v } }`,
+		`PREFIX ex: <http://example.org/> SELECT ?s ?v ?l WHERE { GRAPH ?g { ?s ex:value ?v . ?s ex:label ?l } }`,
+	} {
+		cursor, err := exec.Select(context.Background(), planQuery(t, ts, query, largeStatistics))
+		if err != nil {
+			t.Fatalf("failed to start query: %v", err)
+		}
+		if !cursor.Next() {
+			t.Fatalf("expected a solution: %v", cursor.Err())
+		}
+		if err := cursor.Close(); err != nil {
+			t.Fatalf("failed to close cursor: %v", err)
+		}
+	}
+	waitForGoroutines(t, baseline)
+}
@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// Profile holds the runtime statistics of every plan node of an analyzed query
+type Profile struct {
+	mu    sync.Mutex
+	nodes map[optimizer.QueryPlan]*optimizer.NodeStats
+
+	// Elapsed is the total execution time of the query
+	Elapsed time.Duration
+
+	// TermLookups is the number of encoded terms the query decoded, not counting repeats served from its cache
+	TermLookups int64
+}
+
+// profileKey is the context key of the profile of a running analysis
+type profileKey struct{}
+
+// Analyze executes a query like Execute while recording, for every operator,
+// the rows it produced, the Next calls it served and the time spent in them
+func (e *Executor) Analyze(ctx context.Context, query *optimizer.OptimizedQuery) (QueryResult, *Profile, error) {
+	profile := &Profile{nodes: make(map[optimizer.QueryPlan]*optimizer.NodeStats)}
+
+	ctx, done := e.startQuery(context.WithValue(ctx, profileKey{}, profile))
+	defer done()
+
+	start := time.Now()
+	result, err := e.Execute(ctx, query)
+	profile.Elapsed = time.Since(start)
+	profile.TermLookups = termCache(ctx).Lookups()
+
+	return result, profile, err
+}
+
+// Stats returns the statistics of a plan node, nil if it never ran
+func (p *Profile) Stats(plan optimizer.QueryPlan) *optimizer.NodeStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats, ok := p.nodes[plan]
+	if !ok {
+		return nil
+	}
+	result := *stats
+	result.ElapsedMs = float64(result.Elapsed.Microseconds()) / 1000
+	return &result
+}
+
+// Annotate attaches the statistics to the nodes of an explained plan
+func (p *Profile) Annotate(root *optimizer.PlanNode) {
+	root.Walk(func(node *optimizer.PlanNode) {
+		if node.Plan != nil {
+			node.Actual = p.Stats(node.Plan)
+		}
+	})
+}
+
+// node returns the statistics of a plan node, creating them on first use
+func (p *Profile) node(plan optimizer.QueryPlan) *optimizer.NodeStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats, ok := p.nodes[plan]
+	if !ok {
+		stats = &optimizer.NodeStats{}
+		p.nodes[plan] = stats
+	}
+	return stats
+}
+
+// profileIterator wraps the iterator of a plan node when the query is being analyzed
+func profileIterator(ctx context.Context, plan optimizer.QueryPlan, it store.BindingIterator) store.BindingIterator {
+	profile, ok := ctx.Value(profileKey{}).(*Profile)
+	if !ok {
+		return it
+	}
+	stats := profile.node(plan)
+	profile.mu.Lock()
+	stats.Opens++
+	profile.mu.Unlock()
+	return &profiledIterator{input: it, profile: profile, stats: stats}
+}
+
+// profiledIterator counts the rows and Next calls of its input and times them.
+// The statistics are updated under the profile's lock, as the iterators of a node
+// may run on several goroutines in a parallel query.
+type profiledIterator struct {
+	input   store.BindingIterator
+	profile *Profile
+	stats   *optimizer.NodeStats
+}
+
+func (it *profiledIterator) Next() bool {
+	start := time.Now()
+	ok := it.input.Next()
+	elapsed := time.Since(start)
+
+	it.profile.mu.Lock()
+	defer it.profile.mu.Unlock()
+	it.stats.Elapsed += elapsed
+	it.stats.NextCalls++
+	if ok {
+		it.stats.Rows++
+	}
+	return ok
+}
+
+func (it *profiledIterator) Binding() *store.Binding {
+	return it.input.Binding()
+}
+
+func (it *profiledIterator) Close() error {
+	return it.input.Close()
+}
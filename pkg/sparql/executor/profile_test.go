@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+)
+
+const profileData = `@prefix ex: <http://example.org/> .
+ex:a ex:value 1 ; ex:name "A" .
+ex:b ex:value 2 ; ex:name "B" .
+ex:c ex:value 3 .
+ex:d ex:value 4 ; ex:name "D" .
+`
+
+func TestAnalyze(t *testing.T) {
+	ts := newTestStore(t, profileData)
+	// The query only gives the result form: its plan is replaced by one built by hand,
+	// so that the counts don't depend on the optimizer's choices
+	plan := planQuery(t, ts, `SELECT ?s ?n WHERE { ?s ?p ?n }`, nil)
+	iri := func(local string) parser.TermOrVariable {
+		return parser.TermOrVariable{Term: rdf.NewNamedNode("http://example.org/" + local)}
+	}
+	variable := func(name string) parser.TermOrVariable {
+		return parser.TermOrVariable{Variable: &parser.Variable{Name: name}}
+	}
+	filter := &optimizer.FilterPlan{
+		Input: &optimizer.ScanPlan{Pattern: &parser.TriplePattern{Subject: variable("s"), Predicate: iri("value"), Object: variable("v")}},
+		Filter: &parser.Filter{Expression: &parser.BinaryExpression{
+			Operator: parser.OpGreaterThan,
+			Left:     &parser.VariableExpression{Variable: &parser.Variable{Name: "v"}},
+			Right:    &parser.LiteralExpression{Literal: rdf.NewIntegerLiteral(1)},
+		}},
+	}
+	join := &optimizer.JoinPlan{
+		Left:  filter,
+		Right: &optimizer.ScanPlan{Pattern: &parser.TriplePattern{Subject: variable("s"), Predicate: iri("name"), Object: variable("n")}},
+		Type:  optimizer.JoinTypeNestedLoop,
+	}
+	plan.Plan = &optimizer.ProjectionPlan{Input: join, Variables: []*parser.Variable{{Name: "s"}, {Name: "n"}}}
+
+	result, profile, err := NewExecutor(ts).Analyze(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("failed to analyze query: %v", err)
+	}
+	if rows := len(result.(*SelectResult).Bindings); rows != 2 {
+		t.Fatalf("expected 2 solutions, got %d", rows)
+	}
+
+	// Every iterator is read to its end, so each one takes one more Next than it has rows
+	tests := []struct {
+		name string
+		plan optimizer.QueryPlan
+		want optimizer.NodeStats
+	}{
+		{"projection", plan.Plan, optimizer.NodeStats{Rows: 2, NextCalls: 3, Opens: 1}},
+		{"join", join, optimizer.NodeStats{Rows: 2, NextCalls: 3, Opens: 1}},
+		{"filter", filter, optimizer.NodeStats{Rows: 3, NextCalls: 4, Opens: 1}},
+		{"values", filter.Input, optimizer.NodeStats{Rows: 4, NextCalls: 5, Opens: 1}},
+		// The inner side of the nested loop is scanned again for each of the three outer rows
+		{"names", join.Right, optimizer.NodeStats{Rows: 9, NextCalls: 12, Opens: 3}},
+	}
+	for _, tt := range tests {
+		stats := profile.Stats(tt.plan)
+		if stats == nil {
+			t.Errorf("%s: expected statistics", tt.name)
+			continue
+		}
+		if stats.Rows != tt.want.Rows || stats.NextCalls != tt.want.NextCalls || stats.Opens != tt.want.Opens {
+			t.Errorf("%s: expected %d rows, %d Next calls and %d opens, got %d, %d and %d", tt.name,
+				tt.want.Rows, tt.want.NextCalls, tt.want.Opens, stats.Rows, stats.NextCalls, stats.Opens)
+		}
+	}
+
+	// The statistics are attached to the matching nodes of the explained plan
+	explained := optimizer.NewOptimizer(&optimizer.Statistics{}).Explain(plan.Plan)
+	profile.Annotate(explained)
+	explained.Walk(func(node *optimizer.PlanNode) {
+		if node.Actual == nil {
+			t.Errorf("expected statistics for %s %s", node.Operator, node.Details)
+		} else if *node.Actual != *profile.Stats(node.Plan) {
+			t.Errorf("expected the statistics of %s %s to be attached", node.Operator, node.Details)
+		}
+	})
+}
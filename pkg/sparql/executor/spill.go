@@ -0,0 +1,695 @@
+package executor
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// DefaultMemoryBudget is the memory a sort, DISTINCT or GROUP BY may use before spilling to disk
+const DefaultMemoryBudget = 64 << 20
+
+// spillPartitions is the number of files a DISTINCT or GROUP BY spills to once its budget is used up
+const spillPartitions = 64
+
+// SetMemoryBudget sets the memory, in bytes, each sort, DISTINCT and GROUP BY of a query may hold
+// before spilling to temporary files. Zero or less restores the default.
+func (e *Executor) SetMemoryBudget(bytes int64) {
+	if bytes <= 0 {
+		bytes = DefaultMemoryBudget
+	}
+	e.memoryBudget = bytes
+}
+
+// SetTempDir sets the directory of spill files, the system temporary directory if empty
+func (e *Executor) SetTempDir(dir string) {
+	e.tempDir = dir
+}
+
+// bindingSize estimates the memory held by a binding
+func bindingSize(binding *store.Binding) int64 {
+	size := int64(64)
+	for name, term := range binding.Vars {
+		size += int64(len(name)) + termSize(term) + 32
+	}
+	for _, name := range binding.Names() {
+		if _, ok := binding.Encoded(name); ok {
+			size += int64(len(name)) + int64(len(store.EncodedTerm{})) + 32
+		}
+	}
+	return size
+}
+
+// termSize estimates the memory held by an RDF term
+func termSize(term rdf.Term) int64 {
+	switch t := term.(type) {
+	case *rdf.NamedNode:
+		return int64(len(t.IRI)) + 16
+	case *rdf.BlankNode:
+		return int64(len(t.ID)) + 16
+	case *rdf.Literal:
+		size := int64(len(t.Value)+len(t.Language)+len(t.Direction)) + 64
+		if t.Datatype != nil {
+			size += int64(len(t.Datatype.IRI)) + 16
+		}
+		return size
+	case *rdf.TripleTerm:
+		return termSize(t.Subject) + termSize(t.Predicate) + termSize(t.Object) + 48
+	case *rdf.QuotedTriple:
+		return termSize(t.Subject) + termSize(t.Predicate) + termSize(t.Object) + 48
+	default:
+		return 16
+	}
+}
+
+// Term tags of the spill file encoding
+const (
+	spillNamedNode byte = iota + 1
+	spillBlankNode
+	spillLiteral
+	spillTripleTerm
+	spillQuotedTriple
+	spillDefaultGraph
+	spillEncoded // an encoded ID from the store, written as is
+)
+
+// spillFile is a temporary file of bindings, written once and then read back in order.
+// Each record is a sequence number followed by the variables of a binding.
+// Encoded values stay encoded, and are decoded by terms once read back.
+type spillFile struct {
+	file   *os.File
+	writer *bufio.Writer
+	reader *bufio.Reader
+	terms  *store.TermCache
+}
+
+// newSpillFile creates a spill file in dir
+func newSpillFile(dir string, terms *store.TermCache) (*spillFile, error) {
+	file, err := os.CreateTemp(dir, "trigo-spill-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill file: %w", err)
+	}
+	return &spillFile{file: file, writer: bufio.NewWriterSize(file, 64*1024), terms: terms}, nil
+}
+
+// write appends a binding and its sequence number
+func (f *spillFile) write(seq uint64, binding *store.Binding) error {
+	f.writeUvarint(seq)
+	names := binding.Names()
+	f.writeUvarint(uint64(len(names)))
+	for _, name := range names {
+		f.writeString(name)
+		if encoded, ok := binding.Encoded(name); ok {
+			_ = f.writer.WriteByte(spillEncoded) // #nosec G104 - checked once per record in write
+			_, _ = f.writer.Write(encoded[:])    // #nosec G104 - checked once per record in write
+			continue
+		}
+		term, _ := binding.Term(name)
+		if err := f.writeTerm(term); err != nil {
+			return err
+		}
+	}
+	// bufio.Writer keeps its first error and returns it from every later call
+	if _, err := f.writer.Write(nil); err != nil {
+		return fmt.Errorf("failed to write spill file: %w", err)
+	}
+	return nil
+}
+
+func (f *spillFile) writeUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	_, _ = f.writer.Write(buf[:n]) // #nosec G104 - checked once per record in write
+}
+
+func (f *spillFile) writeString(s string) {
+	f.writeUvarint(uint64(len(s)))
+	_, _ = f.writer.WriteString(s) // #nosec G104 - checked once per record in write
+}
+
+func (f *spillFile) writeTerm(term rdf.Term) error {
+	switch t := term.(type) {
+	case *rdf.NamedNode:
+		_ = f.writer.WriteByte(spillNamedNode) // #nosec G104 - checked once per record in write
+		f.writeString(t.IRI)
+	case *rdf.BlankNode:
+		_ = f.writer.WriteByte(spillBlankNode) // #nosec G104 - checked once per record in write
+		f.writeString(t.ID)
+	case *rdf.Literal:
+		_ = f.writer.WriteByte(spillLiteral) // #nosec G104 - checked once per record in write
+		f.writeString(t.Value)
+		f.writeString(t.Language)
+		f.writeString(t.Direction)
+		datatype := ""
+		if t.Datatype != nil {
+			datatype = t.Datatype.IRI
+		}
+		f.writeString(datatype)
+	case *rdf.TripleTerm:
+		_ = f.writer.WriteByte(spillTripleTerm) // #nosec G104 - checked once per record in write
+		return f.writeTriple(t.Subject, t.Predicate, t.Object)
+	case *rdf.QuotedTriple:
+		_ = f.writer.WriteByte(spillQuotedTriple) // #nosec G104 - checked once per record in write
+		return f.writeTriple(t.Subject, t.Predicate, t.Object)
+	case *rdf.DefaultGraph:
+		_ = f.writer.WriteByte(spillDefaultGraph) // #nosec G104 - checked once per record in write
+	default:
+		return fmt.Errorf("cannot spill term of type %T", term)
+	}
+	return nil
+}
+
+func (f *spillFile) writeTriple(subject, predicate, object rdf.Term) error {
+	for _, term := range []rdf.Term{subject, predicate, object} {
+		if err := f.writeTerm(term); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewind flushes the written records and prepares the file for reading from the start
+func (f *spillFile) rewind() error {
+	if err := f.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write spill file: %w", err)
+	}
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind spill file: %w", err)
+	}
+	f.reader = bufio.NewReaderSize(f.file, 64*1024)
+	return nil
+}
+
+// read returns the next binding and its sequence number, io.EOF after the last one
+func (f *spillFile) read() (uint64, *store.Binding, error) {
+	seq, err := binary.ReadUvarint(f.reader)
+	if err != nil {
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	count, err := binary.ReadUvarint(f.reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	binding := store.NewBinding()
+	for i := uint64(0); i < count; i++ {
+		name, err := f.readString()
+		if err != nil {
+			return 0, nil, err
+		}
+		if tag, err := f.reader.Peek(1); err == nil && tag[0] == spillEncoded {
+			var encoded store.EncodedTerm
+			_, _ = f.reader.Discard(1) // #nosec G104 - the byte was peeked
+			if _, err := io.ReadFull(f.reader, encoded[:]); err != nil {
+				return 0, nil, fmt.Errorf("failed to read spill file: %w", err)
+			}
+			binding.SetEncoded(name, encoded, f.terms)
+			continue
+		}
+		term, err := f.readTerm()
+		if err != nil {
+			return 0, nil, err
+		}
+		binding.Set(name, term)
+	}
+	return seq, binding, nil
+}
+
+func (f *spillFile) readString() (string, error) {
+	n, err := binary.ReadUvarint(f.reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read spill file: %w", err)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(f.reader, buf); err != nil {
+		return "", fmt.Errorf("failed to read spill file: %w", err)
+	}
+	return string(buf), nil
+}
+
+func (f *spillFile) readTerm() (rdf.Term, error) {
+	tag, err := f.reader.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spill file: %w", err)
+	}
+	switch tag {
+	case spillNamedNode:
+		iri, err := f.readString()
+		if err != nil {
+			return nil, err
+		}
+		return rdf.NewNamedNode(iri), nil
+	case spillBlankNode:
+		id, err := f.readString()
+		if err != nil {
+			return nil, err
+		}
+		return rdf.NewBlankNode(id), nil
+	case spillLiteral:
+		var parts [4]string
+		for i := range parts {
+			if parts[i], err = f.readString(); err != nil {
+				return nil, err
+			}
+		}
+		lit := &rdf.Literal{Value: parts[0], Language: parts[1], Direction: parts[2]}
+		if parts[3] != "" {
+			lit.Datatype = rdf.NewNamedNode(parts[3])
+		}
+		return lit, nil
+	case spillTripleTerm, spillQuotedTriple:
+		var terms [3]rdf.Term
+		for i := range terms {
+			if terms[i], err = f.readTerm(); err != nil {
+				return nil, err
+			}
+		}
+		if tag == spillTripleTerm {
+			return &rdf.TripleTerm{Subject: terms[0], Predicate: terms[1], Object: terms[2]}, nil
+		}
+		return &rdf.QuotedTriple{Subject: terms[0], Predicate: terms[1], Object: terms[2]}, nil
+	case spillDefaultGraph:
+		return rdf.NewDefaultGraph(), nil
+	default:
+		return nil, fmt.Errorf("corrupt spill file: unknown term tag %d", tag)
+	}
+}
+
+// remove closes and deletes the file
+func (f *spillFile) remove() {
+	_ = f.file.Close()           // #nosec G104 - the file is deleted anyway
+	_ = os.Remove(f.file.Name()) // #nosec G104 - best effort cleanup of a temporary file
+}
+
+// externalSort sorts bindings within a memory budget. Sorted runs are spilled to
+// temporary files whenever the buffered bindings exceed the budget, then k-way merged.
+// Bindings that compare equal keep their input order.
+type externalSort struct {
+	less   func(a, b *store.Binding) bool
+	budget int64
+	dir    string
+	terms  *store.TermCache
+
+	buffer []*store.Binding
+	size   int64
+	seq    uint64 // input position of the first buffered binding
+	runs   []*spillFile
+}
+
+// add buffers a binding, spilling a sorted run once the buffer is over budget
+func (s *externalSort) add(binding *store.Binding) error {
+	s.buffer = append(s.buffer, binding)
+	s.size += bindingSize(binding)
+	if s.size > s.budget {
+		return s.spill()
+	}
+	return nil
+}
+
+// spill writes the buffer as a sorted run
+func (s *externalSort) spill() error {
+	sort.SliceStable(s.buffer, func(i, j int) bool {
+		return s.less(s.buffer[i], s.buffer[j])
+	})
+	run, err := newSpillFile(s.dir, s.terms)
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, run)
+	for i, binding := range s.buffer {
+		if err := run.write(s.seq+uint64(i), binding); err != nil {
+			return err
+		}
+	}
+	s.seq += uint64(len(s.buffer))
+	s.buffer = nil
+	s.size = 0
+	return nil
+}
+
+// finish sorts what is left and returns the sorted bindings.
+// Without spilled runs they are simply the sorted buffer.
+func (s *externalSort) finish() (sortedBindings, error) {
+	if len(s.runs) == 0 {
+		sort.SliceStable(s.buffer, func(i, j int) bool {
+			return s.less(s.buffer[i], s.buffer[j])
+		})
+		return &sliceBindings{bindings: s.buffer}, nil
+	}
+	if len(s.buffer) > 0 {
+		if err := s.spill(); err != nil {
+			return nil, err
+		}
+	}
+	merge := &runMerge{less: s.less, runs: s.runs}
+	s.runs = nil
+	for i, run := range merge.runs {
+		if err := run.rewind(); err != nil {
+			merge.close()
+			return nil, err
+		}
+		if err := merge.advance(i); err != nil {
+			merge.close()
+			return nil, err
+		}
+	}
+	heap.Init(merge)
+	return merge, nil
+}
+
+// close deletes the spilled runs of an unfinished sort
+func (s *externalSort) close() {
+	for _, run := range s.runs {
+		run.remove()
+	}
+	s.runs = nil
+	s.buffer = nil
+}
+
+// sortedBindings is the output of a sort
+type sortedBindings interface {
+	// next returns the next binding, nil once exhausted
+	next() (*store.Binding, error)
+	close()
+}
+
+// sliceBindings returns the bindings of an in-memory sort
+type sliceBindings struct {
+	bindings []*store.Binding
+	position int
+}
+
+func (s *sliceBindings) next() (*store.Binding, error) {
+	if s.position >= len(s.bindings) {
+		return nil, nil
+	}
+	s.position++
+	return s.bindings[s.position-1], nil
+}
+
+func (s *sliceBindings) close() {
+	s.bindings = nil
+}
+
+// mergeHead is the current binding of a sorted run
+type mergeHead struct {
+	run     int
+	seq     uint64
+	binding *store.Binding
+}
+
+// runMerge k-way merges sorted runs with a heap of their current bindings.
+// Ties are broken by input position so the merge is stable.
+type runMerge struct {
+	less  func(a, b *store.Binding) bool
+	runs  []*spillFile
+	heads []mergeHead
+}
+
+func (m *runMerge) Len() int { return len(m.heads) }
+
+func (m *runMerge) Less(i, j int) bool {
+	a, b := m.heads[i], m.heads[j]
+	if m.less(a.binding, b.binding) {
+		return true
+	}
+	if m.less(b.binding, a.binding) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (m *runMerge) Swap(i, j int) { m.heads[i], m.heads[j] = m.heads[j], m.heads[i] }
+
+func (m *runMerge) Push(x any) { m.heads = append(m.heads, x.(mergeHead)) }
+
+func (m *runMerge) Pop() any {
+	last := m.heads[len(m.heads)-1]
+	m.heads = m.heads[:len(m.heads)-1]
+	return last
+}
+
+// advance reads the next binding of a run onto the heap, before it is initialized
+func (m *runMerge) advance(run int) error {
+	seq, binding, err := m.runs[run].read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m.heads = append(m.heads, mergeHead{run: run, seq: seq, binding: binding})
+	return nil
+}
+
+func (m *runMerge) next() (*store.Binding, error) {
+	if len(m.heads) == 0 {
+		return nil, nil
+	}
+	head := m.heads[0]
+	seq, binding, err := m.runs[head.run].read()
+	switch {
+	case err == io.EOF:
+		heap.Pop(m)
+	case err != nil:
+		return nil, err
+	default:
+		m.heads[0] = mergeHead{run: head.run, seq: seq, binding: binding}
+		heap.Fix(m, 0)
+	}
+	return head.binding, nil
+}
+
+func (m *runMerge) close() {
+	for _, run := range m.runs {
+		run.remove()
+	}
+	m.runs = nil
+	m.heads = nil
+}
+
+// topK keeps the k first bindings of an ordering in a bounded heap,
+// for an ORDER BY whose solutions are cut by LIMIT
+type topK struct {
+	less  func(a, b *store.Binding) bool
+	k     int
+	heads []mergeHead // max-heap: the last binding in order is on top
+	seq   uint64
+	size  int64
+}
+
+func (t *topK) Len() int { return len(t.heads) }
+
+func (t *topK) Less(i, j int) bool {
+	// Reversed, so the root is the binding that sorts last
+	a, b := t.heads[i], t.heads[j]
+	if t.less(b.binding, a.binding) {
+		return true
+	}
+	if t.less(a.binding, b.binding) {
+		return false
+	}
+	return a.seq > b.seq
+}
+
+func (t *topK) Swap(i, j int) { t.heads[i], t.heads[j] = t.heads[j], t.heads[i] }
+
+func (t *topK) Push(x any) { t.heads = append(t.heads, x.(mergeHead)) }
+
+func (t *topK) Pop() any {
+	last := t.heads[len(t.heads)-1]
+	t.heads = t.heads[:len(t.heads)-1]
+	return last
+}
+
+// add offers a binding, keeping it only while it is among the k first
+func (t *topK) add(binding *store.Binding) {
+	head := mergeHead{seq: t.seq, binding: binding}
+	t.seq++
+	if len(t.heads) < t.k {
+		heap.Push(t, head)
+		t.size += bindingSize(binding)
+		return
+	}
+	// The new binding comes after all equal ones, so it only replaces a binding that sorts after it
+	if !t.less(binding, t.heads[0].binding) {
+		return
+	}
+	t.size += bindingSize(binding) - bindingSize(t.heads[0].binding)
+	t.heads[0] = head
+	heap.Fix(t, 0)
+}
+
+// sorted returns the kept bindings in order
+func (t *topK) sorted() []*store.Binding {
+	sort.Slice(t.heads, func(i, j int) bool {
+		return t.Less(j, i)
+	})
+	bindings := make([]*store.Binding, len(t.heads))
+	for i, head := range t.heads {
+		bindings[i] = head.binding
+	}
+	return bindings
+}
+
+// hashDistinct removes duplicate bindings within a memory budget, keeping the first
+// occurrence of each in input order. Bindings are emitted as they arrive until the set
+// of seen signatures is over budget. Later bindings that were not seen yet are then
+// hash-partitioned to spill files, each deduplicated on its own once the input is
+// exhausted, and the survivors of all partitions merged back by input position.
+type hashDistinct struct {
+	store  *store.TripleStore
+	terms  *store.TermCache
+	budget int64
+	dir    string
+
+	seen       map[string]bool
+	size       int64
+	seq        uint64
+	partitions []*spillFile // nil until the budget is used up
+}
+
+// newHashDistinct creates the duplicate elimination of a DISTINCT or GROUP BY of a running query
+func (e *Executor) newHashDistinct(ctx context.Context) *hashDistinct {
+	return &hashDistinct{
+		store:  e.store,
+		terms:  termCache(ctx),
+		budget: e.memoryBudget,
+		dir:    e.tempDir,
+		seen:   make(map[string]bool),
+	}
+}
+
+// add offers a binding. It returns true when the binding is new and can be emitted straight away;
+// once spilling, new bindings are written to a partition and returned by finish instead.
+func (d *hashDistinct) add(binding *store.Binding) (bool, error) {
+	sig := bindingKey(d.store, binding)
+	seq := d.seq
+	d.seq++
+	if d.seen[sig] {
+		return false, nil
+	}
+	if d.partitions == nil {
+		d.seen[sig] = true
+		d.size += int64(len(sig)) + 48
+		if d.size > d.budget {
+			if err := d.createPartitions(); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return false, d.partitions[partitionOf(sig)].write(seq, binding)
+}
+
+func (d *hashDistinct) createPartitions() error {
+	partitions := make([]*spillFile, 0, spillPartitions)
+	for i := 0; i < spillPartitions; i++ {
+		partition, err := newSpillFile(d.dir, d.terms)
+		if err != nil {
+			for _, created := range partitions {
+				created.remove()
+			}
+			return err
+		}
+		partitions = append(partitions, partition)
+	}
+	d.partitions = partitions
+	return nil
+}
+
+// partitionOf returns the spill partition of a binding signature
+func partitionOf(sig string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(sig)) // #nosec G104 - hash writes never fail
+	return int(h.Sum32() % spillPartitions)
+}
+
+// finish returns the spilled bindings that were not emitted by add, in input order
+func (d *hashDistinct) finish() (sortedBindings, error) {
+	d.seen = nil
+	if d.partitions == nil {
+		return &sliceBindings{}, nil
+	}
+
+	// A binding's duplicates are all in its partition, and the survivors of a partition
+	// are written in input order, so merging them by position restores the input order
+	merge := &runMerge{less: func(a, b *store.Binding) bool { return false }}
+	partitions := d.partitions
+	d.partitions = nil
+	defer func() {
+		for _, partition := range partitions {
+			partition.remove()
+		}
+	}()
+	for _, partition := range partitions {
+		survivors, err := d.dedupePartition(partition)
+		if err != nil {
+			merge.close()
+			return nil, err
+		}
+		merge.runs = append(merge.runs, survivors)
+		if err := merge.advance(len(merge.runs) - 1); err != nil {
+			merge.close()
+			return nil, err
+		}
+	}
+	heap.Init(merge)
+	return merge, nil
+}
+
+// dedupePartition writes the first occurrence of each binding of a partition to a new spill file
+func (d *hashDistinct) dedupePartition(partition *spillFile) (*spillFile, error) {
+	if err := partition.rewind(); err != nil {
+		return nil, err
+	}
+	survivors, err := newSpillFile(d.dir, d.terms)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for {
+		seq, binding, err := partition.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			survivors.remove()
+			return nil, err
+		}
+		sig := bindingKey(d.store, binding)
+		if seen[sig] {
+			continue
+		}
+		seen[sig] = true
+		if err := survivors.write(seq, binding); err != nil {
+			survivors.remove()
+			return nil, err
+		}
+	}
+	if err := survivors.rewind(); err != nil {
+		survivors.remove()
+		return nil, err
+	}
+	return survivors, nil
+}
+
+// close deletes the partitions of an unfinished DISTINCT
+func (d *hashDistinct) close() {
+	for _, partition := range d.partitions {
+		partition.remove()
+	}
+	d.partitions = nil
+	d.seen = nil
+}
@@ -0,0 +1,222 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// smallBudget is a memory budget of a few KB, far below the size of parallelData's results
+const smallBudget = 4 << 10
+
+var spillQueries = map[string]string{
+	"order":          `PREFIX ex: <http://example.org/> SELECT ?s ?v WHERE { GRAPH ?g { ?s ex:value ?v } } ORDER BY DESC(?v)`,
+	"order labels":   `PREFIX ex: <http://example.org/> SELECT ?s ?l WHERE { GRAPH ?g { ?s ex:label ?l } } ORDER BY ?l DESC(?s)`,
+	"order limit":    `PREFIX ex: <http://example.org/> SELECT ?s ?v WHERE { GRAPH ?g { ?s ex:value ?v } } ORDER BY ASC(?v) LIMIT 400 OFFSET 10`,
+	"order computed": `PREFIX ex: <http://example.org/> SELECT ?s ?w WHERE { GRAPH ?g { ?s ex:value ?v BIND(?v * 2 AS ?w) } } ORDER BY DESC(?w)`,
+	"distinct":       `PREFIX ex: <http://example.org/> SELECT DISTINCT ?s ?grp WHERE { GRAPH ?g { ?s ex:group ?grp } }`,
+	"distinct dupes": `PREFIX ex: <http://example.org/> SELECT DISTINCT ?s ?grp WHERE { GRAPH ?g { ?s ex:group ?grp . ?s ?p ?o } }`,
+	"group":          `PREFIX ex: <http://example.org/> SELECT ?s WHERE { GRAPH ?g { ?s ex:label ?l } } GROUP BY ?s`,
+}
+
+// assertEmptyDir fails if any spill file is left in dir
+func assertEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read temp dir: %v", err)
+	}
+	for _, entry := range entries {
+		t.Errorf("spill file left behind: %s", entry.Name())
+	}
+}
+
+func TestSpillMatchesInMemory(t *testing.T) {
+	ts := newTestStore(t, parallelData())
+
+	for name, query := range spillQueries {
+		t.Run(name, func(t *testing.T) {
+			plan := planQuery(t, ts, query, nil)
+			expected := selectRows(t, NewExecutor(ts), plan)
+			if len(expected) == 0 {
+				t.Fatal("expected solutions")
+			}
+
+			// Without a writable temp dir the query only succeeds if nothing is spilled
+			missing := NewExecutor(ts)
+			missing.SetMemoryBudget(smallBudget)
+			missing.SetTempDir(filepath.Join(t.TempDir(), "missing"))
+			if _, err := missing.Execute(context.Background(), plan); err == nil {
+				t.Fatal("expected the query to spill")
+			}
+
+			dir := t.TempDir()
+			spilling := NewExecutor(ts)
+			spilling.SetMemoryBudget(smallBudget)
+			spilling.SetTempDir(dir)
+			actual := selectRows(t, spilling, plan)
+			// ORDER BY and DISTINCT keep a defined order, GROUP BY does not
+			if name == "group" {
+				assertSameRows(t, sorted(expected), sorted(actual))
+			} else {
+				assertSameRows(t, expected, actual)
+			}
+			assertEmptyDir(t, dir)
+		})
+	}
+}
+
+func TestSpillFilesRemovedOnClose(t *testing.T) {
+	ts := newTestStore(t, parallelData())
+
+	for name, query := range spillQueries {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			exec := NewExecutor(ts)
+			exec.SetMemoryBudget(smallBudget)
+			exec.SetTempDir(dir)
+
+			cursor, err := exec.Select(context.Background(), planQuery(t, ts, query, nil))
+			if err != nil {
+				t.Fatalf("failed to start query: %v", err)
+			}
+			// Read until the spilled solutions are being returned, then stop early
+			for cursor.Next() {
+				entries, err := os.ReadDir(dir)
+				if err != nil {
+					t.Fatalf("failed to read temp dir: %v", err)
+				}
+				if len(entries) > 0 {
+					break
+				}
+			}
+			if err := cursor.Err(); err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			if err := cursor.Close(); err != nil {
+				t.Fatalf("failed to close cursor: %v", err)
+			}
+			assertEmptyDir(t, dir)
+		})
+	}
+}
+
+// unspillableTerm is a term the spill file format has no encoding for
+type unspillableTerm struct{}
+
+func (unspillableTerm) Type() rdf.TermType         { return rdf.TermTypeNamedNode }
+func (unspillableTerm) String() string             { return "<unspillable>" }
+func (unspillableTerm) Equals(other rdf.Term) bool { return false }
+
+func TestSpillFilesRemovedOnError(t *testing.T) {
+	ts := newTestStore(t, parallelData())
+
+	t.Run("sort", func(t *testing.T) {
+		dir := t.TempDir()
+		terms := ts.NewTermCache()
+		defer terms.Close()
+		sorter := &externalSort{
+			less:   func(a, b *store.Binding) bool { return false },
+			budget: 256,
+			dir:    dir,
+			terms:  terms,
+		}
+		for i := 0; len(sorter.runs) < 3; i++ {
+			binding := store.NewBinding()
+			binding.Set("x", rdf.NewLiteral("a value long enough to fill the budget quickly"))
+			if err := sorter.add(binding); err != nil {
+				t.Fatalf("failed to spill: %v", err)
+			}
+		}
+		binding := store.NewBinding()
+		binding.Set("x", unspillableTerm{})
+		binding.Set("y", rdf.NewLiteral("a value long enough to fill the budget at once, whatever is buffered already"))
+		binding.Set("z", rdf.NewLiteral("a value long enough to fill the budget at once, whatever is buffered already"))
+		if err := sorter.add(binding); err == nil {
+			t.Fatal("expected the spill to fail")
+		}
+		sorter.close()
+		assertEmptyDir(t, dir)
+	})
+
+	t.Run("distinct", func(t *testing.T) {
+		dir := t.TempDir()
+		exec := NewExecutor(ts)
+		exec.SetMemoryBudget(256)
+		exec.SetTempDir(dir)
+		ctx, done := exec.startQuery(context.Background())
+		defer done()
+
+		distinct := exec.newHashDistinct(ctx)
+		for i := 0; distinct.partitions == nil; i++ {
+			binding := store.NewBinding()
+			binding.Set("x", rdf.NewLiteral(string(rune('a'+i%26))+string(rune('a'+i/26))))
+			if _, err := distinct.add(binding); err != nil {
+				t.Fatalf("failed to spill: %v", err)
+			}
+		}
+		binding := store.NewBinding()
+		binding.Set("x", unspillableTerm{})
+		if _, err := distinct.add(binding); err == nil {
+			t.Fatal("expected the spill to fail")
+		}
+		distinct.close()
+		assertEmptyDir(t, dir)
+	})
+
+	t.Run("temp dir removed", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "spill")
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			t.Fatalf("failed to create temp dir: %v", err)
+		}
+		exec := NewExecutor(ts)
+		exec.SetMemoryBudget(256)
+		exec.SetTempDir(dir)
+		ctx, done := exec.startQuery(context.Background())
+		defer done()
+
+		// Partitions that were created before the failure are removed with it
+		distinct := exec.newHashDistinct(ctx)
+		if err := os.Remove(dir); err != nil {
+			t.Fatalf("failed to remove temp dir: %v", err)
+		}
+		var err error
+		for i := 0; err == nil && i < 1000; i++ {
+			binding := store.NewBinding()
+			binding.Set("x", rdf.NewLiteral(string(rune('a'+i%26))+string(rune('a'+i/26))))
+			_, err = distinct.add(binding)
+		}
+		if err == nil {
+			t.Fatal("expected the spill to fail")
+		}
+		if distinct.partitions != nil {
+			t.Error("expected no partitions after the failure")
+		}
+		distinct.close()
+	})
+
+	t.Run("query", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "missing")
+		exec := NewExecutor(ts)
+		exec.SetMemoryBudget(smallBudget)
+		exec.SetTempDir(dir)
+		for name, query := range spillQueries {
+			cursor, err := exec.Select(context.Background(), planQuery(t, ts, query, nil))
+			if err != nil {
+				t.Fatalf("%s: failed to start query: %v", name, err)
+			}
+			for cursor.Next() {
+			}
+			if cursor.Err() == nil {
+				t.Errorf("%s: expected the query to fail", name)
+			}
+			if err := cursor.Close(); err != nil {
+				t.Errorf("%s: failed to close cursor: %v", name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// queryState is shared by the iterators of a running query
+type queryState struct {
+	// terms decodes the encoded values of the query's bindings
+	terms *store.TermCache
+
+	// workers holds a slot for every goroutine the query runs besides the caller's,
+	// so the query never runs on more goroutines than its degree of parallelism
+	workers chan struct{}
+
+	mu  sync.Mutex
+	err error // first error an iterator could not return itself
+}
+
+// queryStateKey is the context key of the state of a running query
+type queryStateKey struct{}
+
+// startQuery returns a context carrying the state of a new query, and the function
+// that releases it. A query started within another shares the outer query's state.
+func (e *Executor) startQuery(ctx context.Context) (context.Context, func()) {
+	if _, ok := ctx.Value(queryStateKey{}).(*queryState); ok {
+		return ctx, func() {}
+	}
+	state := &queryState{
+		terms:   e.store.NewTermCache(),
+		workers: make(chan struct{}, e.queryParallelism(ctx)-1),
+	}
+	return context.WithValue(ctx, queryStateKey{}, state), func() {
+		_ = state.terms.Close() // #nosec G104 - closing a read-only transaction
+	}
+}
+
+// termCache returns the term cache of the running query, nil outside a query
+func termCache(ctx context.Context) *store.TermCache {
+	if state, ok := ctx.Value(queryStateKey{}).(*queryState); ok {
+		return state.terms
+	}
+	return nil
+}
+
+// failQuery records an error that ends an iterator early, such as a failed spill file write
+func failQuery(ctx context.Context, err error) {
+	state, ok := ctx.Value(queryStateKey{}).(*queryState)
+	if !ok {
+		return
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.err == nil {
+		state.err = err
+	}
+}
+
+// queryError returns the error that ended a query early: one recorded by an iterator, or ctx.Err()
+func queryError(ctx context.Context) error {
+	if state, ok := ctx.Value(queryStateKey{}).(*queryState); ok {
+		state.mu.Lock()
+		err := state.err
+		state.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// acquireWorker takes a worker slot of the running query for a new goroutine,
+// returning false when the query already runs on all the goroutines it may use
+func acquireWorker(ctx context.Context) bool {
+	state, ok := ctx.Value(queryStateKey{}).(*queryState)
+	if !ok {
+		return false
+	}
+	select {
+	case state.workers <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseWorker returns a worker slot taken by acquireWorker
+func releaseWorker(ctx context.Context) {
+	if state, ok := ctx.Value(queryStateKey{}).(*queryState); ok {
+		<-state.workers
+	}
+}
+
+// parallelism returns the degree of parallelism of the running query, 1 outside a query
+func parallelism(ctx context.Context) int {
+	if state, ok := ctx.Value(queryStateKey{}).(*queryState); ok {
+		return cap(state.workers) + 1
+	}
+	return 1
+}
@@ -0,0 +1,292 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/optimizer"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// termData returns a document of n subjects with long IRIs and literals, stored in the
+// id2str table rather than inline in their encoded IDs
+func termData(n int) (string, []rdf.Term) {
+	var doc strings.Builder
+	var terms []rdf.Term
+	for i := 0; i < n; i++ {
+		subject := rdf.NewNamedNode(fmt.Sprintf("http://example.org/a/rather/long/subject/iri/%d", i))
+		object := rdf.NewLiteral(fmt.Sprintf("a literal much longer than an encoded ID, number %d", i))
+		fmt.Fprintf(&doc, "%s <http://example.org/value> %s .\n", subject, object)
+		terms = append(terms, subject, object)
+	}
+	return doc.String(), terms
+}
+
+// encodeTerms returns the encoded IDs of terms
+func encodeTerms(t *testing.T, ts *store.TripleStore, terms []rdf.Term) []store.EncodedTerm {
+	t.Helper()
+	encoded := make([]store.EncodedTerm, len(terms))
+	for i, term := range terms {
+		var err error
+		if encoded[i], err = ts.EncodeTerm(term); err != nil {
+			t.Fatalf("failed to encode %s: %v", term, err)
+		}
+	}
+	return encoded
+}
+
+func TestTermCache(t *testing.T) {
+	data, terms := termData(10)
+	ts := newTestStore(t, data)
+	encoded := encodeTerms(t, ts, terms)
+
+	cache := ts.NewTermCache()
+	for round := 0; round < 3; round++ {
+		for i, id := range encoded {
+			term, err := cache.Decode(id)
+			if err != nil {
+				t.Fatalf("failed to decode %s: %v", terms[i], err)
+			}
+			if !term.Equals(terms[i]) {
+				t.Errorf("expected %s, got %s", terms[i], term)
+			}
+		}
+	}
+	// Every term is read from the store once, then from the cache
+	if lookups := cache.Lookups(); lookups != int64(len(terms)) {
+		t.Errorf("expected %d lookups, got %d", len(terms), lookups)
+	}
+
+	// Terms can still be decoded after the query's transaction is closed
+	if err := cache.Close(); err != nil {
+		t.Fatalf("failed to close cache: %v", err)
+	}
+	fresh := ts.NewTermCache()
+	if err := fresh.Close(); err != nil {
+		t.Fatalf("failed to close cache: %v", err)
+	}
+	term, err := fresh.Decode(encoded[0])
+	if err != nil {
+		t.Fatalf("failed to decode after close: %v", err)
+	}
+	if !term.Equals(terms[0]) {
+		t.Errorf("expected %s, got %s", terms[0], term)
+	}
+}
+
+func TestTermCacheConcurrentDecode(t *testing.T) {
+	data, terms := termData(200)
+	ts := newTestStore(t, data)
+	encoded := encodeTerms(t, ts, terms)
+
+	cache := ts.NewTermCache()
+	defer cache.Close()
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			for i := range encoded {
+				n := (i + offset*13) % len(encoded)
+				term, err := cache.Decode(encoded[n])
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !term.Equals(terms[n]) {
+					errs <- fmt.Errorf("expected %s, got %s", terms[n], term)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	// Concurrent misses for the same term don't read it twice
+	if lookups := cache.Lookups(); lookups != int64(len(terms)) {
+		t.Errorf("expected %d lookups, got %d", len(terms), lookups)
+	}
+}
+
+func TestTermCacheSharedByParallelOperators(t *testing.T) {
+	ts := newTestStore(t, parallelData())
+
+	// Partitioned scans, background hash table builds, a parallel union and a sort
+	// all decode through the query's one cache
+	for _, query := range []string{
+		`PREFIX ex: <http://example.org/> SELECT ?s ?l ?grp WHERE { GRAPH ?g { ?s ex:label ?l . ?s ex:group ?grp } } ORDER BY ?l ?s`,
+		`PREFIX ex: <http://example.org/> SELECT ?s ?x WHERE { { ?s ex:label ?x } UNION { GRAPH ?g { ?s ex:label ?x } } FILTER(STRLEN(?x) > 6) }`,
+		`PREFIX ex: <http://example.org/> SELECT DISTINCT ?l WHERE { GRAPH ?g { ?s ex:label ?l . ?s ex:value ?v } FILTER(?v > 10) }`,
+	} {
+		plan := planQuery(t, ts, query, largeStatistics)
+		expected := selectRows(t, NewExecutor(ts), plan)
+
+		parallel := NewExecutor(ts)
+		parallel.SetParallelism(8)
+		var wg sync.WaitGroup
+		results := make([][]string, 4)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = selectRows(t, parallel, plan)
+			}(i)
+		}
+		wg.Wait()
+		for _, actual := range results {
+			assertSameRows(t, sorted(expected), sorted(actual))
+		}
+	}
+}
+
+// encodedBinding binds variables to the encoded IDs of terms
+func encodedBinding(t *testing.T, ts *store.TripleStore, cache *store.TermCache, pairs ...any) *store.Binding {
+	t.Helper()
+	binding := store.NewBinding()
+	for i := 0; i < len(pairs); i += 2 {
+		encoded := encodeTerms(t, ts, []rdf.Term{pairs[i+1].(rdf.Term)})
+		binding.SetEncoded(pairs[i].(string), encoded[0], cache)
+	}
+	return binding
+}
+
+func TestBindingMergeEncoded(t *testing.T) {
+	data, terms := termData(3)
+	ts := newTestStore(t, data)
+	cache := ts.NewTermCache()
+	defer cache.Close()
+	a, b, c := terms[0], terms[2], terms[4]
+
+	// Compatible bindings merge without decoding anything, and stay encoded
+	left := encodedBinding(t, ts, cache, "x", a, "y", b)
+	right := encodedBinding(t, ts, cache, "x", a, "z", c)
+	merged := left.Merge(right)
+	if merged == nil {
+		t.Fatal("expected compatible bindings")
+	}
+	for name, term := range map[string]rdf.Term{"x": a, "y": b, "z": c} {
+		if _, ok := merged.Encoded(name); !ok {
+			t.Errorf("expected ?%s to stay encoded", name)
+		}
+		if value, ok := merged.Term(name); !ok || !value.Equals(term) {
+			t.Errorf("expected ?%s = %s, got %v", name, term, value)
+		}
+	}
+	if !left.SameTerm("x", right) {
+		t.Error("expected ?x to be the same term")
+	}
+	if left.Merge(encodedBinding(t, ts, cache, "x", b)) != nil {
+		t.Error("expected bindings of ?x to different terms not to merge")
+	}
+	if left.SameTerm("y", right) {
+		t.Error("expected ?y unbound on one side not to be the same term")
+	}
+	// Only reading the merged values above decoded terms
+	if lookups := cache.Lookups(); lookups != 3 {
+		t.Errorf("expected 3 lookups, got %d", lookups)
+	}
+
+	// A decoded term is compared with an encoded one by value
+	decoded := store.NewBinding()
+	decoded.Set("x", a)
+	decoded.Set("w", c)
+	if !left.SameTerm("x", decoded) || !decoded.SameTerm("x", left) {
+		t.Error("expected the decoded and encoded ?x to be the same term")
+	}
+	merged = decoded.Merge(left)
+	if merged == nil {
+		t.Fatal("expected compatible bindings")
+	}
+	// The bindings of the decoded side take the other side's cache for its encoded values
+	if _, ok := merged.Encoded("y"); !ok {
+		t.Error("expected ?y to stay encoded")
+	}
+	decoded.Set("x", b)
+	if decoded.Merge(left) != nil || left.Merge(decoded) != nil {
+		t.Error("expected bindings of ?x to different terms not to merge")
+	}
+
+	// Values encoded for another query are compared as terms
+	other := ts.NewTermCache()
+	defer other.Close()
+	foreign := encodedBinding(t, ts, other, "x", a, "v", c)
+	merged = left.Merge(foreign)
+	if merged == nil {
+		t.Fatal("expected compatible bindings across caches")
+	}
+	if value, ok := merged.Term("v"); !ok || !value.Equals(c) {
+		t.Errorf("expected ?v = %s, got %v", c, value)
+	}
+	if left.Merge(encodedBinding(t, ts, other, "x", b)) != nil {
+		t.Error("expected bindings of ?x to different terms not to merge across caches")
+	}
+}
+
+func TestJoinComparesEncodedValues(t *testing.T) {
+	data, _ := termData(50)
+	ts := newTestStore(t, data+`<http://example.org/a/rather/long/subject/iri/7> <http://example.org/other> "x" .`)
+	exec := NewExecutor(ts)
+
+	for _, join := range []string{"planned", "hash", "nested loop"} {
+		plan := planQuery(t, ts, `SELECT ?s ?v ?o WHERE { ?s <http://example.org/value> ?v . ?s <http://example.org/other> ?o }`, nil)
+		for _, p := range joinPlans(plan.Plan) {
+			switch join {
+			case "hash":
+				p.Type = optimizer.JoinTypeHashJoin
+			case "nested loop":
+				p.Type = optimizer.JoinTypeNestedLoop
+			}
+		}
+
+		ctx, done := exec.startQuery(context.Background())
+		it, err := exec.createIterator(ctx, plan.Plan)
+		if err != nil {
+			t.Fatalf("%s: failed to create iterator: %v", join, err)
+		}
+		rows := 0
+		for it.Next() {
+			rows++
+		}
+		it.Close()
+		// The join variable ?s is matched on its encoded IDs: no term is decoded
+		if lookups := termCache(ctx).Lookups(); lookups != 0 {
+			t.Errorf("%s: expected no lookups, got %d", join, lookups)
+		}
+		done()
+		if rows != 1 {
+			t.Errorf("%s: expected 1 solution, got %d", join, rows)
+		}
+	}
+}
+
+func TestDistinctKeepsTermsWithEqualValues(t *testing.T) {
+	ts := newTestStore(t, `<http://example.org/s> <http://example.org/value> 1 .`)
+	exec := NewExecutor(ts)
+
+	// The encoder gives these the same IDs as the stored 1 and as each other, but they are different terms
+	values := `{ ?s ?p ?o BIND("01"^^xsd:integer AS ?v) } UNION { ?s ?p ?o BIND(1 AS ?v) } UNION
+		{ ?s ?p ?o BIND("2024-01-01T12:00:00Z"^^xsd:dateTime AS ?v) } UNION
+		{ ?s ?p ?o BIND("2024-01-01T13:00:00+01:00"^^xsd:dateTime AS ?v) } UNION
+		{ ?s ?p ?v }`
+	expected := []string{
+		`v="01"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+		`v="1"^^<http://www.w3.org/2001/XMLSchema#integer>`,
+		`v="2024-01-01T12:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime>`,
+		`v="2024-01-01T13:00:00+01:00"^^<http://www.w3.org/2001/XMLSchema#dateTime>`,
+	}
+	for _, query := range []string{
+		`SELECT DISTINCT ?v WHERE { ` + values + ` }`,
+		`SELECT ?v WHERE { ` + values + ` } GROUP BY ?v`,
+	} {
+		plan := planQuery(t, ts, `PREFIX xsd: <http://www.w3.org/2001/XMLSchema#> `+query, nil)
+		assertSameRows(t, expected, sorted(selectRows(t, exec, plan)))
+	}
+}
@@ -0,0 +1,420 @@
+package optimizer
+
+import (
+	"math"
+
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// LoadStatistics builds optimizer statistics for a store, using the statistics
+// persisted by store.Analyze when the store has been analyzed
+func LoadStatistics(ts *store.TripleStore) (*Statistics, error) {
+	count, err := ts.Count()
+	if err != nil {
+		return nil, err
+	}
+	dataset, err := ts.LoadStatistics()
+	if err != nil {
+		return nil, err
+	}
+	return &Statistics{TotalTriples: count, Dataset: dataset}, nil
+}
+
+// estimate is the estimated result of a plan: its number of solutions and
+// the number of distinct values of each variable
+type estimate struct {
+	rows     float64
+	distinct map[string]float64
+}
+
+// total returns the number of quads the estimates are based on
+func (o *Optimizer) total() float64 {
+	stats := o.Statistics()
+	switch {
+	case stats == nil:
+		return 1
+	case stats.Dataset != nil && stats.Dataset.Quads > 0:
+		return float64(stats.Dataset.Quads)
+	case stats.TotalTriples > 0:
+		return float64(stats.TotalTriples)
+	default:
+		return 1
+	}
+}
+
+// dataset returns the analyzed statistics, nil if there are none
+func (o *Optimizer) dataset() *store.Statistics {
+	if stats := o.Statistics(); stats != nil {
+		return stats.Dataset
+	}
+	return nil
+}
+
+// graphFraction estimates the share of all quads a scan in graph can see
+func (o *Optimizer) graphFraction(graph *parser.GraphTerm) float64 {
+	dataset := o.dataset()
+	if dataset == nil || dataset.Quads == 0 {
+		return 1
+	}
+	quads := float64(dataset.Quads)
+	switch {
+	case graph == nil:
+		return float64(dataset.Graphs[""]) / quads
+	case graph.IRI != nil:
+		return float64(dataset.Graphs[graph.IRI.String()]) / quads
+	default:
+		return float64(dataset.Quads-dataset.Graphs[""]) / quads
+	}
+}
+
+// estimatePattern estimates the solutions of a single triple pattern.
+// With analyzed statistics, a bound predicate contributes its quad count, and bound
+// subjects and objects divide it by the predicate's distinct subjects and objects.
+// Otherwise the selectivity heuristic is applied to the total count.
+func (o *Optimizer) estimatePattern(pattern *parser.TriplePattern, graph *parser.GraphTerm) estimate {
+	est := estimate{distinct: make(map[string]float64)}
+	dataset := o.dataset()
+
+	if dataset == nil || pattern.Subject.IsTripleTerm() || pattern.Object.IsTripleTerm() {
+		est.rows = o.total() * o.estimateSelectivity(pattern)
+		for _, name := range patternVariables(pattern) {
+			est.distinct[name] = est.rows
+		}
+		return est
+	}
+
+	subjects, objects := float64(dataset.Subjects), float64(dataset.Objects)
+	if pattern.Predicate.IsVariable() {
+		est.rows = float64(dataset.Quads)
+		est.distinct[pattern.Predicate.Variable.Name] = float64(len(dataset.Predicates))
+	} else if ps, ok := dataset.Predicates[pattern.Predicate.Term.String()]; ok {
+		est.rows = float64(ps.Quads)
+		subjects, objects = float64(ps.Subjects), float64(ps.Objects)
+	} else {
+		// The predicate doesn't occur in the data
+		return est
+	}
+
+	if pattern.Subject.IsVariable() {
+		est.distinct[pattern.Subject.Variable.Name] = subjects
+	} else if subjects > 0 {
+		est.rows /= subjects
+	}
+	if pattern.Object.IsVariable() {
+		est.distinct[pattern.Object.Variable.Name] = objects
+	} else if objects > 0 {
+		est.rows /= objects
+	}
+
+	est.rows *= o.graphFraction(graph)
+	est.capDistinct()
+	return est
+}
+
+// estimateStar estimates a star of patterns sharing a subject variable with bound predicates
+// from the characteristic sets: every set containing all the predicates contributes
+// its subjects times the average occurrences of each predicate per subject.
+// Returns false if the statistics have no matching characteristic set.
+func (o *Optimizer) estimateStar(patterns []*parser.TriplePattern, graph *parser.GraphTerm) (estimate, bool) {
+	dataset := o.dataset()
+	if dataset == nil || len(patterns) < 2 {
+		return estimate{}, false
+	}
+
+	rows, subjects := 0.0, 0.0
+	for _, set := range dataset.CharacteristicSets {
+		occurrences := make(map[string]int64, len(set.Predicates))
+		for i, p := range set.Predicates {
+			occurrences[p] = set.Occurrences[i]
+		}
+
+		setRows := float64(set.Subjects)
+		for _, pattern := range patterns {
+			occ, ok := occurrences[pattern.Predicate.Term.String()]
+			if !ok {
+				setRows = 0
+				break
+			}
+			setRows *= float64(occ) / float64(set.Subjects)
+		}
+		if setRows > 0 {
+			rows += setRows
+			subjects += float64(set.Subjects)
+		}
+	}
+	if rows == 0 {
+		return estimate{}, false
+	}
+
+	est := estimate{distinct: make(map[string]float64)}
+	est.rows = rows
+	for _, pattern := range patterns {
+		single := o.estimatePattern(pattern, graph)
+		for name, n := range single.distinct {
+			if current, ok := est.distinct[name]; !ok || n < current {
+				est.distinct[name] = n
+			}
+		}
+		if !pattern.Object.IsVariable() {
+			if ps := dataset.Predicates[pattern.Predicate.Term.String()]; ps != nil && ps.Objects > 0 {
+				est.rows /= float64(ps.Objects)
+			}
+		}
+	}
+	est.distinct[patterns[0].Subject.Variable.Name] = subjects
+	est.rows *= o.graphFraction(graph)
+	est.capDistinct()
+	return est, true
+}
+
+// estimatePatterns estimates the join of triple patterns, using characteristic
+// sets for stars around a subject variable and joinEstimates for the rest
+func (o *Optimizer) estimatePatterns(patterns []*parser.TriplePattern, graph *parser.GraphTerm) estimate {
+	stars := make(map[string][]*parser.TriplePattern)
+	var order []string
+	var rest []*parser.TriplePattern
+	for _, pattern := range patterns {
+		if pattern.Subject.IsVariable() && !pattern.Predicate.IsVariable() && !pattern.Predicate.IsTripleTerm() &&
+			!pattern.Object.IsTripleTerm() {
+			name := pattern.Subject.Variable.Name
+			if _, ok := stars[name]; !ok {
+				order = append(order, name)
+			}
+			stars[name] = append(stars[name], pattern)
+			continue
+		}
+		rest = append(rest, pattern)
+	}
+
+	var result *estimate
+	join := func(est estimate) {
+		if result == nil {
+			result = &est
+			return
+		}
+		joined := joinEstimates(*result, est)
+		result = &joined
+	}
+
+	for _, name := range order {
+		if est, ok := o.estimateStar(stars[name], graph); ok {
+			join(est)
+			continue
+		}
+		for _, pattern := range stars[name] {
+			join(o.estimatePattern(pattern, graph))
+		}
+	}
+	for _, pattern := range rest {
+		join(o.estimatePattern(pattern, graph))
+	}
+
+	if result == nil {
+		return estimate{rows: 1, distinct: map[string]float64{}}
+	}
+	return *result
+}
+
+// joinEstimates estimates an equi-join: the product of both sides divided,
+// for every shared variable, by the larger of its distinct value counts
+func joinEstimates(a, b estimate) estimate {
+	result := estimate{rows: a.rows * b.rows, distinct: make(map[string]float64)}
+	for name, n := range a.distinct {
+		if m, ok := b.distinct[name]; ok {
+			if d := math.Max(n, m); d > 0 {
+				result.rows /= d
+			}
+			result.distinct[name] = math.Min(n, m)
+			continue
+		}
+		result.distinct[name] = n
+	}
+	for name, m := range b.distinct {
+		if _, ok := a.distinct[name]; !ok {
+			result.distinct[name] = m
+		}
+	}
+	result.capDistinct()
+	return result
+}
+
+// capDistinct limits the distinct value counts to the number of rows
+func (e *estimate) capDistinct() {
+	for name, n := range e.distinct {
+		if n > e.rows {
+			e.distinct[name] = e.rows
+		}
+	}
+}
+
+// orderPatterns orders triple patterns for joining, greedily: it starts with the
+// pattern with the fewest estimated solutions, then repeatedly adds the pattern
+// sharing a variable with the ones chosen so far that keeps the estimated
+// intermediate result smallest, so cartesian products are only formed when
+// nothing connected is left
+func (o *Optimizer) orderPatterns(patterns []*parser.TriplePattern, graph *parser.GraphTerm) []*parser.TriplePattern {
+	if len(patterns) < 2 {
+		return patterns
+	}
+
+	remaining := make([]*parser.TriplePattern, len(patterns))
+	copy(remaining, patterns)
+	ordered := make([]*parser.TriplePattern, 0, len(patterns))
+	bound := make(map[string]bool)
+
+	take := func(i int) {
+		for _, name := range patternVariables(remaining[i]) {
+			bound[name] = true
+		}
+		ordered = append(ordered, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+
+	best := 0
+	for i := 1; i < len(remaining); i++ {
+		if o.estimatePattern(remaining[i], graph).rows < o.estimatePattern(remaining[best], graph).rows {
+			best = i
+		}
+	}
+	take(best)
+
+	for len(remaining) > 0 {
+		best, bestRows, bestConnected := -1, 0.0, false
+		for i, pattern := range remaining {
+			connected := false
+			for _, name := range patternVariables(pattern) {
+				if bound[name] {
+					connected = true
+					break
+				}
+			}
+			if bestConnected && !connected {
+				continue
+			}
+
+			rows := o.estimatePatterns(append(ordered[:len(ordered):len(ordered)], pattern), graph).rows
+			if best < 0 || (connected && !bestConnected) || rows < bestRows {
+				best, bestRows, bestConnected = i, rows, connected
+			}
+		}
+		take(best)
+	}
+
+	return ordered
+}
+
+// estimatePlan estimates the solutions of a plan
+// graph is the enclosing GRAPH term, nil for the default graph
+func (o *Optimizer) estimatePlan(plan QueryPlan, graph *parser.GraphTerm) estimate {
+	switch p := plan.(type) {
+	case *ScanPlan:
+		return o.estimatePattern(p.Pattern, graph)
+	case *LeapfrogJoinPlan:
+		return o.estimatePatterns(p.Patterns, graph)
+	case *JoinPlan:
+		if patterns, ok := scanLeaves(p); ok {
+			return o.estimatePatterns(patterns, graph)
+		}
+		return joinEstimates(o.estimatePlan(p.Left, graph), o.estimatePlan(p.Right, graph))
+	case *UnionPlan:
+		left, right := o.estimatePlan(p.Left, graph), o.estimatePlan(p.Right, graph)
+		result := estimate{rows: left.rows + right.rows, distinct: make(map[string]float64)}
+		for name, n := range left.distinct {
+			result.distinct[name] = n + right.distinct[name]
+		}
+		for name, n := range right.distinct {
+			if _, ok := left.distinct[name]; !ok {
+				result.distinct[name] = n
+			}
+		}
+		return result
+	case *OptionalPlan:
+		// Every left solution is kept, extended by its matches on the right
+		left := o.estimatePlan(p.Left, graph)
+		joined := joinEstimates(left, o.estimatePlan(p.Right, graph))
+		if joined.rows < left.rows {
+			return left
+		}
+		return joined
+	case *MinusPlan:
+		return o.estimatePlan(p.Left, graph)
+	case *LimitPlan:
+		est := o.estimatePlan(p.Input, graph)
+		est.rows = math.Min(float64(p.Limit), est.rows)
+		est.capDistinct()
+		return est
+	case *OffsetPlan:
+		est := o.estimatePlan(p.Input, graph)
+		est.rows = math.Max(0, est.rows-float64(p.Offset))
+		est.capDistinct()
+		return est
+	case *FilterPlan:
+		return o.estimatePlan(p.Input, graph)
+	case *BindPlan:
+		return o.estimatePlan(p.Input, graph)
+	case *ProjectionPlan:
+		return o.estimatePlan(p.Input, graph)
+	case *OrderByPlan:
+		return o.estimatePlan(p.Input, graph)
+	case *DistinctPlan:
+		return o.estimatePlan(p.Input, graph)
+	case *GroupPlan:
+		// One row per combination of the grouping variables' values
+		input := o.estimatePlan(p.Input, graph)
+		est := estimate{rows: 1, distinct: map[string]float64{}}
+		for _, condition := range p.GroupBy {
+			if condition.Variable == nil {
+				continue
+			}
+			if d, ok := input.distinct[condition.Variable.Name]; ok {
+				est.rows *= d
+				est.distinct[condition.Variable.Name] = d
+			}
+		}
+		est.rows = math.Min(est.rows, input.rows)
+		est.capDistinct()
+		return est
+	case *ConstructPlan:
+		if p.Input == nil {
+			break
+		}
+		return o.estimatePlan(p.Input, graph)
+	case *DescribePlan:
+		if p.Input == nil {
+			break
+		}
+		return o.estimatePlan(p.Input, graph)
+	case *GraphPlan:
+		return o.estimatePlan(p.Input, p.Graph)
+	}
+	return estimate{rows: o.total(), distinct: map[string]float64{}}
+}
+
+// scanLeaves returns the triple patterns of a join tree made only of scans
+func scanLeaves(plan QueryPlan) ([]*parser.TriplePattern, bool) {
+	switch p := plan.(type) {
+	case *ScanPlan:
+		return []*parser.TriplePattern{p.Pattern}, true
+	case *LeapfrogJoinPlan:
+		return p.Patterns, true
+	case *JoinPlan:
+		left, ok := scanLeaves(p.Left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := scanLeaves(p.Right)
+		if !ok {
+			return nil, false
+		}
+		return append(left, right...), true
+	case *BindPlan:
+		// Constants propagated from filters are bound above the scans they were folded into
+		if _, ok := p.Expression.(*parser.LiteralExpression); ok {
+			return scanLeaves(p.Input)
+		}
+		return nil, false
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,375 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+)
+
+// PlanNode describes one operator of a query plan, as shown by EXPLAIN
+type PlanNode struct {
+	Operator      string      `json:"operator"`
+	Details       string      `json:"details,omitempty"`
+	EstimatedRows *float64    `json:"estimatedRows,omitempty"` // nil once hidden, see HideEstimates
+	Actual        *NodeStats  `json:"actual,omitempty"`        // set by EXPLAIN ANALYZE
+	Children      []*PlanNode `json:"children,omitempty"`
+
+	// Plan is the described plan node
+	Plan QueryPlan `json:"-"`
+}
+
+// NodeStats are the runtime statistics of a plan node collected by EXPLAIN ANALYZE.
+// A node evaluated several times (e.g. the inner side of a nested loop join) sums all runs.
+type NodeStats struct {
+	Rows      int64         `json:"rows"`      // solutions produced
+	NextCalls int64         `json:"nextCalls"` // calls to Next, including the final one returning false
+	Opens     int64         `json:"opens"`     // iterators created
+	Elapsed   time.Duration `json:"-"`         // time spent in Next, including children
+
+	ElapsedMs float64 `json:"timeMs"`
+}
+
+// Explain describes a plan as a tree of operators with estimated cardinalities
+func (o *Optimizer) Explain(plan QueryPlan) *PlanNode {
+	return o.explain(plan, nil)
+}
+
+func (o *Optimizer) explain(plan QueryPlan, graph *parser.GraphTerm) *PlanNode {
+	if plan == nil {
+		return &PlanNode{Operator: "Empty"}
+	}
+
+	node := &PlanNode{Plan: plan}
+	child := func(p QueryPlan) {
+		if p != nil {
+			node.Children = append(node.Children, o.explain(p, graph))
+		}
+	}
+
+	switch p := plan.(type) {
+	case *ScanPlan:
+		node.Operator = "Scan"
+		node.Details = formatTriplePattern(p.Pattern)
+		if graph != nil {
+			node.Details += " in " + formatGraphTerm(graph)
+		}
+	case *LeapfrogJoinPlan:
+		node.Operator = "LeapfrogJoin"
+		node.Details = "on " + formatVariableNames(p.Variables)
+		for i, pattern := range p.Patterns {
+			details := formatTriplePattern(pattern) + " by " + trieIndexName(p.Orders[i], graph)
+			if graph != nil {
+				details += " in " + formatGraphTerm(graph)
+			}
+			node.Children = append(node.Children, &PlanNode{
+				Operator:      "Trie",
+				Details:       details,
+				EstimatedRows: roundedRows(o.estimatePattern(pattern, graph).rows),
+			})
+		}
+	case *JoinPlan:
+		node.Operator = p.Type.String()
+		if len(p.Variables) > 0 {
+			node.Details = "on " + formatVariableNames(p.Variables)
+		}
+		child(p.Left)
+		child(p.Right)
+	case *FilterPlan:
+		node.Operator = "Filter"
+		node.Details = FormatExpression(p.Filter.Expression)
+		child(p.Input)
+	case *ProjectionPlan:
+		node.Operator = "Projection"
+		names := make([]string, len(p.Variables))
+		for i, v := range p.Variables {
+			names[i] = v.Name
+		}
+		node.Details = formatVariableNames(names)
+		child(p.Input)
+	case *OrderByPlan:
+		node.Operator = "OrderBy"
+		conditions := make([]string, len(p.OrderBy))
+		for i, c := range p.OrderBy {
+			direction := "ASC"
+			if !c.Ascending {
+				direction = "DESC"
+			}
+			conditions[i] = fmt.Sprintf("%s(%s)", direction, FormatExpression(c.Expression))
+		}
+		node.Details = strings.Join(conditions, " ")
+		if p.Limit > 0 {
+			node.Operator = "TopK"
+			node.Details += fmt.Sprintf(" first %d", p.Limit)
+		}
+		child(p.Input)
+	case *LimitPlan:
+		node.Operator = "Limit"
+		node.Details = fmt.Sprint(p.Limit)
+		child(p.Input)
+	case *OffsetPlan:
+		node.Operator = "Offset"
+		node.Details = fmt.Sprint(p.Offset)
+		child(p.Input)
+	case *DistinctPlan:
+		node.Operator = "Distinct"
+		child(p.Input)
+	case *GroupPlan:
+		node.Operator = "Group"
+		var keys []string
+		for _, condition := range p.GroupBy {
+			switch {
+			case condition.Expression != nil && condition.Variable != nil:
+				keys = append(keys, fmt.Sprintf("(%s AS ?%s)", FormatExpression(condition.Expression), condition.Variable.Name))
+			case condition.Expression != nil:
+				keys = append(keys, FormatExpression(condition.Expression))
+			case condition.Variable != nil:
+				keys = append(keys, "?"+condition.Variable.Name)
+			}
+		}
+		node.Details = strings.Join(keys, " ")
+		child(p.Input)
+	case *ConstructPlan:
+		node.Operator = "Construct"
+		patterns := make([]string, len(p.Template))
+		for i, pattern := range p.Template {
+			patterns[i] = formatTriplePattern(pattern)
+		}
+		node.Details = strings.Join(patterns, " . ")
+		child(p.Input)
+	case *DescribePlan:
+		node.Operator = "Describe"
+		resources := make([]string, len(p.Resources))
+		for i, resource := range p.Resources {
+			resources[i] = resource.String()
+		}
+		node.Details = strings.Join(resources, " ")
+		child(p.Input)
+	case *GraphPlan:
+		node.Operator = "Graph"
+		node.Details = formatGraphTerm(p.Graph)
+		node.Children = append(node.Children, o.explain(p.Input, p.Graph))
+	case *BindPlan:
+		node.Operator = "Bind"
+		node.Details = fmt.Sprintf("%s AS ?%s", FormatExpression(p.Expression), p.Variable.Name)
+		child(p.Input)
+	case *OptionalPlan:
+		node.Operator = "Optional"
+		if p.Type == JoinTypeHashJoin {
+			node.Operator = "HashOptional"
+		}
+		var details []string
+		if len(p.Variables) > 0 {
+			details = append(details, "on "+formatVariableNames(p.Variables))
+		}
+		for _, filter := range p.Filters {
+			details = append(details, "filter "+FormatExpression(filter.Expression))
+		}
+		node.Details = strings.Join(details, " ")
+		child(p.Left)
+		child(p.Right)
+	case *UnionPlan:
+		node.Operator = "Union"
+		child(p.Left)
+		child(p.Right)
+	case *MinusPlan:
+		node.Operator = "Minus"
+		if len(p.Variables) > 0 {
+			node.Details = "on " + formatVariableNames(p.Variables)
+		}
+		child(p.Left)
+		child(p.Right)
+	default:
+		node.Operator = fmt.Sprintf("%T", plan)
+	}
+
+	node.EstimatedRows = roundedRows(o.estimateCardinality(plan, graph))
+	return node
+}
+
+// roundedRows rounds an estimated cardinality for display
+func roundedRows(rows float64) *float64 {
+	rounded := math.Round(rows*100) / 100
+	return &rounded
+}
+
+// HideEstimates removes the estimated cardinalities from the plan tree.
+// Estimates come from statistics over all graphs, so they reveal the sizes of graphs
+// a caller who can't read every graph shouldn't see.
+func (n *PlanNode) HideEstimates() {
+	n.Walk(func(node *PlanNode) { node.EstimatedRows = nil })
+}
+
+// String formats the plan tree with one operator per line, children indented
+func (n *PlanNode) String() string {
+	var sb strings.Builder
+	n.format(&sb, 0)
+	return sb.String()
+}
+
+func (n *PlanNode) format(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(n.Operator)
+	if n.Details != "" {
+		sb.WriteString(" ")
+		sb.WriteString(n.Details)
+	}
+	if n.EstimatedRows != nil && n.Plan != nil {
+		fmt.Fprintf(sb, "  (estimated rows=%.0f)", *n.EstimatedRows)
+	}
+	if n.Actual != nil {
+		fmt.Fprintf(sb, "  (actual rows=%d next=%d opens=%d time=%.3fms)",
+			n.Actual.Rows, n.Actual.NextCalls, n.Actual.Opens, n.Actual.ElapsedMs)
+	}
+	sb.WriteString("\n")
+	for _, child := range n.Children {
+		child.format(sb, depth+1)
+	}
+}
+
+// Walk calls fn for the node and all its descendants, parents first
+func (n *PlanNode) Walk(fn func(*PlanNode)) {
+	fn(n)
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// String returns the operator name of a join type
+func (t JoinType) String() string {
+	switch t {
+	case JoinTypeNestedLoop:
+		return "NestedLoopJoin"
+	case JoinTypeHashJoin:
+		return "HashJoin"
+	case JoinTypeMergeJoin:
+		return "MergeJoin"
+	default:
+		return fmt.Sprintf("Join(%d)", int(t))
+	}
+}
+
+// formatTriplePattern formats a triple pattern in SPARQL syntax
+func formatTriplePattern(pattern *parser.TriplePattern) string {
+	return fmt.Sprintf("%s %s %s",
+		formatTermOrVariable(pattern.Subject),
+		formatTermOrVariable(pattern.Predicate),
+		formatTermOrVariable(pattern.Object))
+}
+
+func formatTermOrVariable(t parser.TermOrVariable) string {
+	switch {
+	case t.IsVariable():
+		return "?" + t.Variable.Name
+	case t.IsTripleTerm():
+		return "<<( " + formatTriplePattern(t.TripleTerm) + " )>>"
+	case t.Term != nil:
+		return t.Term.String()
+	default:
+		return "[]"
+	}
+}
+
+func formatGraphTerm(graph *parser.GraphTerm) string {
+	if graph.Variable != nil {
+		return "?" + graph.Variable.Name
+	}
+	return graph.IRI.String()
+}
+
+func formatVariableNames(names []string) string {
+	vars := make([]string, len(names))
+	for i, name := range names {
+		vars[i] = "?" + name
+	}
+	return strings.Join(vars, " ")
+}
+
+// operatorSymbols are the SPARQL spellings of infix and prefix operators
+var operatorSymbols = map[parser.Operator]string{
+	parser.OpAnd:                "&&",
+	parser.OpOr:                 "||",
+	parser.OpNot:                "!",
+	parser.OpEqual:              "=",
+	parser.OpNotEqual:           "!=",
+	parser.OpLessThan:           "<",
+	parser.OpLessThanOrEqual:    "<=",
+	parser.OpGreaterThan:        ">",
+	parser.OpGreaterThanOrEqual: ">=",
+	parser.OpAdd:                "+",
+	parser.OpSubtract:           "-",
+	parser.OpMultiply:           "*",
+	parser.OpDivide:             "/",
+}
+
+// operatorFunctions are the SPARQL function names of operators written as calls
+var operatorFunctions = map[parser.Operator]string{
+	parser.OpRegex:     "REGEX",
+	parser.OpStr:       "STR",
+	parser.OpLang:      "LANG",
+	parser.OpDatatype:  "DATATYPE",
+	parser.OpIsNumeric: "isNumeric",
+	parser.OpAbs:       "ABS",
+	parser.OpCeil:      "CEIL",
+	parser.OpFloor:     "FLOOR",
+	parser.OpRound:     "ROUND",
+}
+
+// FormatExpression formats an expression in SPARQL syntax
+func FormatExpression(expr parser.Expression) string {
+	switch e := expr.(type) {
+	case nil:
+		return ""
+	case *parser.VariableExpression:
+		return "?" + e.Variable.Name
+	case *parser.LiteralExpression:
+		if e.Literal == nil {
+			return "\"\""
+		}
+		return e.Literal.String()
+	case *parser.BinaryExpression:
+		if name, ok := operatorFunctions[e.Operator]; ok {
+			return fmt.Sprintf("%s(%s, %s)", name, FormatExpression(e.Left), FormatExpression(e.Right))
+		}
+		return fmt.Sprintf("(%s %s %s)", FormatExpression(e.Left), operatorSymbols[e.Operator], FormatExpression(e.Right))
+	case *parser.UnaryExpression:
+		if name, ok := operatorFunctions[e.Operator]; ok {
+			return fmt.Sprintf("%s(%s)", name, FormatExpression(e.Operand))
+		}
+		return operatorSymbols[e.Operator] + FormatExpression(e.Operand)
+	case *parser.FunctionCallExpression:
+		args := make([]string, len(e.Arguments))
+		for i, arg := range e.Arguments {
+			args[i] = FormatExpression(arg)
+		}
+		return fmt.Sprintf("%s(%s)", formatFunctionName(e.Function), strings.Join(args, ", "))
+	case *parser.ExistsExpression:
+		if e.Not {
+			return "NOT EXISTS { ... }"
+		}
+		return "EXISTS { ... }"
+	case *parser.InExpression:
+		values := make([]string, len(e.Values))
+		for i, value := range e.Values {
+			values[i] = FormatExpression(value)
+		}
+		op := "IN"
+		if e.Not {
+			op = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s (%s)", FormatExpression(e.Expression), op, strings.Join(values, ", "))
+	default:
+		return fmt.Sprintf("%T", expr)
+	}
+}
+
+// formatFunctionName writes IRI function names (casts, extension functions) as IRIs
+func formatFunctionName(name string) string {
+	if strings.Contains(name, ":") {
+		return rdf.NewNamedNode(name).String()
+	}
+	return name
+}
@@ -0,0 +1,151 @@
+package optimizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+)
+
+func variable(name string) parser.Expression {
+	return &parser.VariableExpression{Variable: &parser.Variable{Name: name}}
+}
+
+func TestExplainGolden(t *testing.T) {
+	named := &parser.GraphTerm{IRI: rdf.NewNamedNode("http://example.org/g")}
+	filter := &parser.Filter{Expression: &parser.BinaryExpression{
+		Operator: parser.OpGreaterThan, Left: variable("o"), Right: &parser.LiteralExpression{Literal: rdf.NewIntegerLiteral(1)}}}
+	triangle := &LeapfrogJoinPlan{
+		Patterns:  []*parser.TriplePattern{scan("?x", "p", "?y").Pattern, scan("?y", "p", "?z").Pattern, scan("?z", "p", "?x").Pattern},
+		Variables: []string{"x", "y", "z"},
+		Orders:    [][3]int{{0, 1, 2}, {0, 1, 2}, {1, 2, 0}},
+	}
+	orderBy := []*parser.OrderCondition{{Expression: variable("o"), Ascending: false}, {Expression: variable("s"), Ascending: true}}
+
+	tests := []struct {
+		name string
+		plan QueryPlan
+		want string // one line per operator, children indented
+	}{
+		{"empty", nil, `
+Empty
+`},
+		{"scan", scan("?s", "p", "?o"), `
+Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"leapfrog join", triangle, `
+LeapfrogJoin on ?x ?y ?z  (estimated rows=1)
+  Trie ?x <http://example.org/p> ?y by SPO
+  Trie ?y <http://example.org/p> ?z by SPO
+  Trie ?z <http://example.org/p> ?x by POS
+`},
+		{"nested loop join", &JoinPlan{Left: scan("s", "p", "?o"), Right: scan("?o", "q", "?x"), Type: JoinTypeNestedLoop}, `
+NestedLoopJoin  (estimated rows=1)
+  Scan <http://example.org/s> <http://example.org/p> ?o  (estimated rows=1)
+  Scan ?o <http://example.org/q> ?x  (estimated rows=100)
+`},
+		{"hash join", &JoinPlan{Left: scan("?s", "p", "?o"), Right: scan("?s", "q", "?x"), Type: JoinTypeHashJoin, Variables: []string{"s"}}, `
+HashJoin on ?s  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+  Scan ?s <http://example.org/q> ?x  (estimated rows=100)
+`},
+		{"merge join", &JoinPlan{Left: scan("?s", "p", "o"), Right: scan("?s", "q", "o"), Type: JoinTypeMergeJoin, Variables: []string{"s"}}, `
+MergeJoin on ?s  (estimated rows=10)
+  Scan ?s <http://example.org/p> <http://example.org/o>  (estimated rows=10)
+  Scan ?s <http://example.org/q> <http://example.org/o>  (estimated rows=10)
+`},
+		{"filter", &FilterPlan{Input: scan("?s", "p", "?o"), Filter: filter}, `
+Filter (?o > "1"^^<http://www.w3.org/2001/XMLSchema#integer>)  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"projection", &ProjectionPlan{Input: scan("?s", "p", "?o"), Variables: []*parser.Variable{{Name: "s"}, {Name: "o"}}}, `
+Projection ?s ?o  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"order by", &OrderByPlan{Input: scan("?s", "p", "?o"), OrderBy: orderBy}, `
+OrderBy DESC(?o) ASC(?s)  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"top k", &OrderByPlan{Input: scan("?s", "p", "?o"), OrderBy: orderBy, Limit: 5}, `
+TopK DESC(?o) ASC(?s) first 5  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"limit", &LimitPlan{Input: scan("?s", "p", "?o"), Limit: 10}, `
+Limit 10  (estimated rows=10)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"offset", &OffsetPlan{Input: scan("?s", "p", "?o"), Offset: 20}, `
+Offset 20  (estimated rows=80)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"distinct", &DistinctPlan{Input: scan("?s", "p", "?o")}, `
+Distinct  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"group", &GroupPlan{Input: scan("?s", "p", "?o"), GroupBy: []*parser.GroupCondition{
+			{Variable: &parser.Variable{Name: "s"}},
+			{Expression: &parser.UnaryExpression{Operator: parser.OpStr, Operand: variable("o")}},
+			{Expression: &parser.UnaryExpression{Operator: parser.OpLang, Operand: variable("o")}, Variable: &parser.Variable{Name: "lang"}},
+		}}, `
+Group ?s STR(?o) (LANG(?o) AS ?lang)  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"construct", &ConstructPlan{Input: scan("?s", "p", "?o"), Template: []*parser.TriplePattern{scan("?o", "q", "?s").Pattern, scan("?s", "r", "x").Pattern}}, `
+Construct ?o <http://example.org/q> ?s . ?s <http://example.org/r> <http://example.org/x>  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"describe", &DescribePlan{Resources: []*rdf.NamedNode{rdf.NewNamedNode("http://example.org/a"), rdf.NewNamedNode("http://example.org/b")}}, `
+Describe <http://example.org/a> <http://example.org/b>  (estimated rows=1000)
+`},
+		{"graph", &GraphPlan{Input: scan("?s", "p", "?o"), Graph: graphVariable("g")}, `
+Graph ?g  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o in ?g  (estimated rows=100)
+`},
+		{"named graph", &GraphPlan{Input: &JoinPlan{Left: scan("?s", "p", "?o"), Right: triangle, Type: JoinTypeHashJoin}, Graph: named}, `
+Graph <http://example.org/g>  (estimated rows=100)
+  HashJoin  (estimated rows=100)
+    Scan ?s <http://example.org/p> ?o in <http://example.org/g>  (estimated rows=100)
+    LeapfrogJoin on ?x ?y ?z  (estimated rows=1)
+      Trie ?x <http://example.org/p> ?y by GSPO in <http://example.org/g>
+      Trie ?y <http://example.org/p> ?z by GSPO in <http://example.org/g>
+      Trie ?z <http://example.org/p> ?x by GPOS in <http://example.org/g>
+`},
+		{"bind", &BindPlan{Input: scan("?s", "p", "?o"), Expression: &parser.LiteralExpression{Literal: rdf.NewLiteral("x")}, Variable: &parser.Variable{Name: "c"}}, `
+Bind "x" AS ?c  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+`},
+		{"optional", &OptionalPlan{Left: scan("s", "p", "?o"), Right: scan("?o", "q", "?x"), Type: JoinTypeNestedLoop}, `
+Optional  (estimated rows=1)
+  Scan <http://example.org/s> <http://example.org/p> ?o  (estimated rows=1)
+  Scan ?o <http://example.org/q> ?x  (estimated rows=100)
+`},
+		{"hash optional", &OptionalPlan{Left: scan("?s", "p", "?o"), Right: scan("?s", "q", "?x"), Type: JoinTypeHashJoin,
+			Variables: []string{"s"}, Filters: []*parser.Filter{filter}}, `
+HashOptional on ?s filter (?o > "1"^^<http://www.w3.org/2001/XMLSchema#integer>)  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+  Scan ?s <http://example.org/q> ?x  (estimated rows=100)
+`},
+		{"union", &UnionPlan{Left: scan("?s", "p", "?o"), Right: scan("?s", "q", "?o")}, `
+Union  (estimated rows=200)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+  Scan ?s <http://example.org/q> ?o  (estimated rows=100)
+`},
+		{"minus", &MinusPlan{Left: scan("?s", "p", "?o"), Right: scan("?s", "q", "x"), Variables: []string{"s"}}, `
+Minus on ?s  (estimated rows=100)
+  Scan ?s <http://example.org/p> ?o  (estimated rows=100)
+  Scan ?s <http://example.org/q> <http://example.org/x>  (estimated rows=10)
+`},
+	}
+
+	o := NewOptimizer(&Statistics{TotalTriples: 1000})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The expected plans start on the line after the opening backquote
+			want := strings.TrimPrefix(tt.want, "\n")
+			if got := o.Explain(tt.plan).String(); got != want {
+				t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package optimizer
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// LeapfrogJoinPlan joins triple patterns all at once with the Leapfrog Triejoin:
+// the variables are bound one at a time, in order, each to the values found in
+// every pattern using it, by seeking through the permutation indexes.
+// Its work is bounded by the largest possible result (it is worst-case optimal),
+// where pairwise joins of cyclic patterns such as triangles can build far larger
+// intermediate results.
+type LeapfrogJoinPlan struct {
+	Patterns []*parser.TriplePattern
+
+	// Variables is the order the variables are bound in
+	Variables []string
+
+	// Orders are the key orders of the indexes the patterns are read from,
+	// as quad positions (see store.TrieOrders)
+	Orders [][3]int
+}
+
+func (p *LeapfrogJoinPlan) planNode() {}
+
+// maxLeapfrogVariables bounds the variable orders tried for a Leapfrog Triejoin
+const maxLeapfrogVariables = 8
+
+// leapfrogJoin plans the triple patterns of a group with a Leapfrog Triejoin
+// when they are cyclic, returning the patterns it can't read from a trie
+// (triple term patterns, repeated variables, no variables) to be joined pairwise
+func (o *Optimizer) leapfrogJoin(patterns []*parser.TriplePattern, graph *parser.GraphTerm) (*LeapfrogJoinPlan, []*parser.TriplePattern, bool) {
+	if graph != nil && graph.Variable != nil {
+		return nil, nil, false
+	}
+
+	var eligible, rest []*parser.TriplePattern
+	for _, pattern := range patterns {
+		if trieEligible(pattern) {
+			eligible = append(eligible, pattern)
+		} else {
+			rest = append(rest, pattern)
+		}
+	}
+	if len(eligible) < 3 || !cyclicPatterns(eligible) {
+		return nil, nil, false
+	}
+
+	variables := o.leapfrogVariables(eligible, graph)
+	if variables == nil {
+		return nil, nil, false
+	}
+	plan := &LeapfrogJoinPlan{Patterns: eligible, Variables: variables}
+	for _, pattern := range eligible {
+		order, _ := trieOrder(pattern, variables)
+		plan.Orders = append(plan.Orders, order)
+	}
+	return plan, rest, true
+}
+
+// trieEligible reports whether a triple pattern can be read from a trie:
+// its positions are plain terms or distinct variables, at least one a variable
+func trieEligible(pattern *parser.TriplePattern) bool {
+	seen := make(map[string]bool)
+	for _, t := range []parser.TermOrVariable{pattern.Subject, pattern.Predicate, pattern.Object} {
+		switch {
+		case t.IsTripleTerm():
+			return false
+		case t.IsVariable():
+			if seen[t.Variable.Name] {
+				return false
+			}
+			seen[t.Variable.Name] = true
+		case t.Term == nil:
+			return false
+		}
+	}
+	return len(seen) > 0
+}
+
+// cyclicPatterns reports whether the hypergraph with a vertex per variable and an
+// edge per pattern is cyclic, by GYO reduction: variables in only one pattern and
+// patterns whose variables all appear in another pattern are removed until nothing
+// changes. Acyclic patterns reduce to nothing and are joined well pairwise.
+func cyclicPatterns(patterns []*parser.TriplePattern) bool {
+	var edges [][]string
+	for _, pattern := range patterns {
+		edges = append(edges, patternVariables(pattern))
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		uses := make(map[string]int)
+		for _, edge := range edges {
+			for _, name := range edge {
+				uses[name]++
+			}
+		}
+		for i, edge := range edges {
+			kept := edge[:0:0]
+			for _, name := range edge {
+				if uses[name] > 1 {
+					kept = append(kept, name)
+				}
+			}
+			if len(kept) < len(edge) {
+				edges[i] = kept
+				changed = true
+			}
+		}
+
+		for i := 0; i < len(edges); i++ {
+			if len(edges[i]) == 0 || containedInOther(edges, i) {
+				edges = append(edges[:i], edges[i+1:]...)
+				changed = true
+				i--
+			}
+		}
+	}
+	return len(edges) > 0
+}
+
+// containedInOther reports whether the variables of edge i all appear in another edge
+func containedInOther(edges [][]string, i int) bool {
+	for j, other := range edges {
+		if j == i {
+			continue
+		}
+		contained := true
+		for _, name := range edges[i] {
+			if !slices.Contains(other, name) {
+				contained = false
+				break
+			}
+		}
+		if contained {
+			return true
+		}
+	}
+	return false
+}
+
+// leapfrogVariables chooses the order to bind the variables in: each pattern's
+// variables must follow the key order of one of the permutation indexes.
+// Variables shared by the most patterns, then with the fewest values, are tried first.
+// Returns nil if no order fits every pattern.
+func (o *Optimizer) leapfrogVariables(patterns []*parser.TriplePattern, graph *parser.GraphTerm) []string {
+	uses := make(map[string]int)
+	var names []string
+	for _, pattern := range patterns {
+		for _, name := range patternVariables(pattern) {
+			if uses[name] == 0 {
+				names = append(names, name)
+			}
+			uses[name]++
+		}
+	}
+	if len(names) > maxLeapfrogVariables {
+		return nil
+	}
+
+	distinct := o.estimatePatterns(patterns, graph).distinct
+	sort.SliceStable(names, func(i, j int) bool {
+		if uses[names[i]] != uses[names[j]] {
+			return uses[names[i]] > uses[names[j]]
+		}
+		return distinct[names[i]] < distinct[names[j]]
+	})
+
+	// Permutations in lexicographic order of the preferred order
+	perm := make([]int, len(names))
+	for i := range perm {
+		perm[i] = i
+	}
+	order := make([]string, len(names))
+	for {
+		for i, p := range perm {
+			order[i] = names[p]
+		}
+		fits := true
+		for _, pattern := range patterns {
+			if _, ok := trieOrder(pattern, order); !ok {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			return order
+		}
+		if !nextPermutation(perm) {
+			return nil
+		}
+	}
+}
+
+// nextPermutation advances perm to the next permutation in lexicographic order,
+// returning false after the last one
+func nextPermutation(perm []int) bool {
+	i := len(perm) - 2
+	for i >= 0 && perm[i] >= perm[i+1] {
+		i--
+	}
+	if i < 0 {
+		return false
+	}
+	j := len(perm) - 1
+	for perm[j] <= perm[i] {
+		j--
+	}
+	perm[i], perm[j] = perm[j], perm[i]
+	slices.Reverse(perm[i+1:])
+	return true
+}
+
+// trieOrder chooses the index a pattern is read from when its variables are bound
+// in the given order: its variables must come in that order in the index keys.
+// Of the indexes that fit, the one with the most constants first is used, so the
+// trie is narrowed to them before any variable is bound.
+func trieOrder(pattern *parser.TriplePattern, variables []string) ([3]int, bool) {
+	positions := []parser.TermOrVariable{pattern.Subject, pattern.Predicate, pattern.Object}
+	best, bestLeading := [3]int{}, -1
+	for _, order := range store.TrieOrders {
+		rank, leading, fits := -1, 0, true
+		for i, position := range order {
+			t := positions[position]
+			if !t.IsVariable() {
+				if leading == i {
+					leading++
+				}
+				continue
+			}
+			r := slices.Index(variables, t.Variable.Name)
+			if r <= rank {
+				fits = false
+				break
+			}
+			rank = r
+		}
+		if fits && leading > bestLeading {
+			best, bestLeading = order, leading
+		}
+	}
+	return best, bestLeading >= 0
+}
+
+// trieIndexName names the index a trie is read from, such as POS or GPOS
+func trieIndexName(order [3]int, graph *parser.GraphTerm) string {
+	name := ""
+	if graph != nil {
+		name = "G"
+	}
+	for _, position := range order {
+		name += string("SPO"[position])
+	}
+	return name
+}
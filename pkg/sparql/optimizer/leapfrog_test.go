@@ -0,0 +1,93 @@
+package optimizer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+)
+
+// patterns makes triple patterns from subject, predicate, object triples, see term
+func patterns(positions ...string) []*parser.TriplePattern {
+	var result []*parser.TriplePattern
+	for i := 0; i+2 < len(positions); i += 3 {
+		result = append(result, scan(positions[i], positions[i+1], positions[i+2]).Pattern)
+	}
+	return result
+}
+
+func TestLeapfrogJoinOnlyForCyclicPatterns(t *testing.T) {
+	quoted := patterns("?x", "p", "?y", "?y", "p", "?z", "?z", "p", "?x", "?x", "q", "?w")
+	quoted[3].Object = parser.TermOrVariable{TripleTerm: scan("?a", "p", "?b").Pattern}
+	fixedGraph := &parser.GraphTerm{IRI: rdf.NewNamedNode("http://example.org/g")}
+
+	tests := []struct {
+		name     string
+		patterns []*parser.TriplePattern
+		graph    *parser.GraphTerm
+		leapfrog bool
+		rest     int // patterns left to pairwise joins
+	}{
+		{"triangle", patterns("?x", "p", "?y", "?y", "p", "?z", "?x", "p", "?z"), nil, true, 0},
+		{"4-cycle", patterns("?a", "p", "?b", "?b", "p", "?c", "?c", "p", "?d", "?d", "p", "?a"), nil, true, 0},
+		{"4-clique", patterns("?a", "p", "?b", "?a", "p", "?c", "?a", "p", "?d",
+			"?b", "p", "?c", "?b", "p", "?d", "?c", "p", "?d"), nil, true, 0},
+		{"triangle with a dangling pattern", patterns("?x", "p", "?y", "?y", "p", "?z", "?z", "p", "?x", "?x", "q", "?w"), nil, true, 0},
+		{"triangle in a named graph", patterns("?x", "p", "?y", "?y", "p", "?z", "?z", "p", "?x"), fixedGraph, true, 0},
+		{"triangle with a triple term pattern", quoted, nil, true, 1},
+		{"triangle with a repeated variable", patterns("?x", "p", "?y", "?y", "p", "?z", "?z", "p", "?x", "?x", "q", "?x"), nil, true, 1},
+		{"path", patterns("?x", "p", "?y", "?y", "p", "?z", "?z", "p", "?w"), nil, false, 0},
+		{"star", patterns("?s", "p", "?a", "?s", "q", "?b", "?s", "r", "?c"), nil, false, 0},
+		{"tree", patterns("?x", "p", "?y", "?y", "p", "?z", "?y", "q", "?w", "?w", "r", "?v"), nil, false, 0},
+		{"two patterns in a cycle", patterns("?x", "p", "?y", "?y", "p", "?x"), nil, false, 0},
+		{"patterns sharing all their variables", patterns("?x", "p", "?y", "?x", "q", "?y", "?y", "r", "?x"), nil, false, 0},
+		{"cycle through a constant", patterns("?x", "p", "?y", "?y", "p", "c", "c", "p", "?x"), nil, false, 0},
+		{"triangle in a graph variable", patterns("?x", "p", "?y", "?y", "p", "?z", "?z", "p", "?x"), graphVariable("g"), false, 0},
+	}
+
+	o := NewOptimizer(&Statistics{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, rest, ok := o.leapfrogJoin(tt.patterns, tt.graph)
+			if ok != tt.leapfrog {
+				t.Fatalf("expected leapfrog join %v, got %v", tt.leapfrog, ok)
+			}
+			if !ok {
+				return
+			}
+			if len(rest) != tt.rest || len(plan.Patterns) != len(tt.patterns)-tt.rest {
+				t.Errorf("expected %d patterns left to pairwise joins, got %d", tt.rest, len(rest))
+			}
+			// Every pattern is read from an index whose key order follows the variable order
+			for i, pattern := range plan.Patterns {
+				if order, ok := trieOrder(pattern, plan.Variables); !ok || order != plan.Orders[i] {
+					t.Errorf("pattern %d: expected key order %v to fit %v", i, plan.Orders[i], plan.Variables)
+				}
+			}
+		})
+	}
+
+	// The optimizer plans acyclic patterns and graph variables with pairwise joins
+	for query, leapfrog := range map[string]bool{
+		`SELECT * WHERE { ?x <http://example.org/p> ?y . ?y <http://example.org/p> ?z . ?z <http://example.org/p> ?x }`:              true,
+		`SELECT * WHERE { ?x <http://example.org/p> ?y . ?y <http://example.org/p> ?z . ?z <http://example.org/p> ?w }`:              false,
+		`SELECT * WHERE { GRAPH ?g { ?x <http://example.org/p> ?y . ?y <http://example.org/p> ?z . ?z <http://example.org/p> ?x } }`: false,
+	} {
+		parsed, err := parser.NewParser(query).Parse()
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", query, err)
+		}
+		optimized, err := o.Optimize(context.Background(), parsed)
+		if err != nil {
+			t.Fatalf("failed to optimize %q: %v", query, err)
+		}
+		planned := false
+		o.Explain(optimized.Plan).Walk(func(node *PlanNode) {
+			planned = planned || node.Operator == "LeapfrogJoin"
+		})
+		if planned != leapfrog {
+			t.Errorf("%s: expected leapfrog join %v, got %v", query, leapfrog, planned)
+		}
+	}
+}
@@ -1,31 +1,53 @@
 package optimizer
 
 import (
+	"context"
+	"maps"
+	"sort"
+	"sync/atomic"
+
 	"github.com/aleksaelezovic/trigo/pkg/rdf"
 	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+	"github.com/aleksaelezovic/trigo/pkg/store"
 )
 
 // Optimizer optimizes SPARQL queries
 type Optimizer struct {
-	// Statistics about the data (for selectivity estimation)
-	stats *Statistics
+	// Statistics about the data (for cardinality estimation), replaced after an analysis
+	stats atomic.Pointer[Statistics]
 }
 
 // Statistics holds statistics about the stored data
 type Statistics struct {
 	TotalTriples int64
-	// Could be extended with per-predicate counts, etc.
+
+	// Dataset holds the statistics collected by store.Analyze, nil if the store was never analyzed
+	Dataset *store.Statistics
 }
 
 // NewOptimizer creates a new query optimizer
 func NewOptimizer(stats *Statistics) *Optimizer {
-	return &Optimizer{
-		stats: stats,
-	}
+	o := &Optimizer{}
+	o.stats.Store(stats)
+	return o
+}
+
+// Statistics returns the statistics the optimizer currently uses
+func (o *Optimizer) Statistics() *Statistics {
+	return o.stats.Load()
+}
+
+// SetStatistics replaces the statistics, e.g. after the store was analyzed again
+func (o *Optimizer) SetStatistics(stats *Statistics) {
+	o.stats.Store(stats)
 }
 
 // Optimize optimizes a parsed query
-func (o *Optimizer) Optimize(query *parser.Query) (*OptimizedQuery, error) {
+func (o *Optimizer) Optimize(ctx context.Context, query *parser.Query) (*OptimizedQuery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	optimized := &OptimizedQuery{
 		Original: query,
 	}
@@ -56,6 +78,7 @@ func (o *Optimizer) Optimize(query *parser.Query) (*OptimizedQuery, error) {
 		}
 		optimized.Plan = plan
 	}
+	o.markParallelScans(optimized.Plan, nil, false)
 
 	return optimized, nil
 }
@@ -74,6 +97,10 @@ type QueryPlan interface {
 // ScanPlan represents a scan operation
 type ScanPlan struct {
 	Pattern *parser.TriplePattern
+
+	// Parallel marks large scans whose order nothing above relies on: their key range
+	// may be split and read by several goroutines (see markParallelScans)
+	Parallel bool
 }
 
 func (p *ScanPlan) planNode() {}
@@ -83,6 +110,10 @@ type JoinPlan struct {
 	Left  QueryPlan
 	Right QueryPlan
 	Type  JoinType
+
+	// Variables bound on both sides, used as hash join keys.
+	// A merge join has the one variable both inputs are ordered by.
+	Variables []string
 }
 
 func (p *JoinPlan) planNode() {}
@@ -116,6 +147,10 @@ func (p *ProjectionPlan) planNode() {}
 type OrderByPlan struct {
 	Input   QueryPlan
 	OrderBy []*parser.OrderCondition
+
+	// Limit is the number of leading solutions a LIMIT above needs (with its OFFSET),
+	// zero when all are returned
+	Limit int
 }
 
 func (p *OrderByPlan) planNode() {}
@@ -143,6 +178,14 @@ type DistinctPlan struct {
 
 func (p *DistinctPlan) planNode() {}
 
+// GroupPlan represents a GROUP BY operation, one solution per group
+type GroupPlan struct {
+	Input   QueryPlan
+	GroupBy []*parser.GroupCondition
+}
+
+func (p *GroupPlan) planNode() {}
+
 // ConstructPlan represents a CONSTRUCT operation
 type ConstructPlan struct {
 	Input    QueryPlan
@@ -180,6 +223,14 @@ func (p *BindPlan) planNode() {}
 type OptionalPlan struct {
 	Left  QueryPlan
 	Right QueryPlan
+	Type  JoinType // hash join, or nested loop when the left side has at most one row
+
+	// Variables bound on both sides, used as hash join keys
+	Variables []string
+
+	// Filters of the OPTIONAL group that need variables of the left side.
+	// They are evaluated on the combined solution, which is left alone when none passes.
+	Filters []*parser.Filter
 }
 
 func (p *OptionalPlan) planNode() {}
@@ -188,6 +239,10 @@ func (p *OptionalPlan) planNode() {}
 type UnionPlan struct {
 	Left  QueryPlan
 	Right QueryPlan
+
+	// Parallel marks unions whose branches may run concurrently, their solutions
+	// interleaved, when nothing above relies on their order (see markParallelScans)
+	Parallel bool
 }
 
 func (p *UnionPlan) planNode() {}
@@ -196,6 +251,9 @@ func (p *UnionPlan) planNode() {}
 type MinusPlan struct {
 	Left  QueryPlan
 	Right QueryPlan
+
+	// Variables bound on both sides, used as hash keys for the right side
+	Variables []string
 }
 
 func (p *MinusPlan) planNode() {}
@@ -203,24 +261,33 @@ func (p *MinusPlan) planNode() {}
 // optimizeSelect optimizes a SELECT query
 func (o *Optimizer) optimizeSelect(query *parser.SelectQuery) (QueryPlan, error) {
 	// Start with the WHERE clause
-	plan, err := o.optimizeGraphPattern(query.Where)
+	plan, err := o.optimizeGraphPattern(query.Where, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	// Apply GROUP BY if present
+	if len(query.GroupBy) > 0 {
+		plan = &GroupPlan{
+			Input:   plan,
+			GroupBy: query.GroupBy,
+		}
+	}
+
 	// Apply ORDER BY if present
 	if len(query.OrderBy) > 0 {
-		plan = &OrderByPlan{
+		orderBy := &OrderByPlan{
 			Input:   plan,
 			OrderBy: query.OrderBy,
 		}
-	}
-
-	// Apply DISTINCT if present
-	if query.Distinct {
-		plan = &DistinctPlan{
-			Input: plan,
+		// Without DISTINCT, LIMIT and OFFSET only need the first solutions of the order
+		if query.Limit != nil && !query.Distinct {
+			orderBy.Limit = *query.Limit
+			if query.Offset != nil {
+				orderBy.Limit += *query.Offset
+			}
 		}
+		plan = orderBy
 	}
 
 	// Apply projection (if not SELECT *)
@@ -231,6 +298,14 @@ func (o *Optimizer) optimizeSelect(query *parser.SelectQuery) (QueryPlan, error)
 		}
 	}
 
+	// Apply DISTINCT to the projected solutions.
+	// REDUCED permits but does not require eliminating duplicates, so they are kept.
+	if query.Distinct {
+		plan = &DistinctPlan{
+			Input: plan,
+		}
+	}
+
 	// Apply OFFSET if present
 	if query.Offset != nil {
 		plan = &OffsetPlan{
@@ -253,7 +328,7 @@ func (o *Optimizer) optimizeSelect(query *parser.SelectQuery) (QueryPlan, error)
 // optimizeAsk optimizes an ASK query
 func (o *Optimizer) optimizeAsk(query *parser.AskQuery) (QueryPlan, error) {
 	// ASK queries just need to check existence
-	plan, err := o.optimizeGraphPattern(query.Where)
+	plan, err := o.optimizeGraphPattern(query.Where, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -268,7 +343,7 @@ func (o *Optimizer) optimizeAsk(query *parser.AskQuery) (QueryPlan, error) {
 // optimizeConstruct optimizes a CONSTRUCT query
 func (o *Optimizer) optimizeConstruct(query *parser.ConstructQuery) (QueryPlan, error) {
 	// Optimize the WHERE clause to get bindings
-	plan, err := o.optimizeGraphPattern(query.Where)
+	plan, err := o.optimizeGraphPattern(query.Where, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -288,7 +363,7 @@ func (o *Optimizer) optimizeDescribe(query *parser.DescribeQuery) (QueryPlan, er
 
 	// If there's a WHERE clause, optimize it to find resources dynamically
 	if query.Where != nil {
-		plan, err := o.optimizeGraphPattern(query.Where)
+		plan, err := o.optimizeGraphPattern(query.Where, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -299,22 +374,48 @@ func (o *Optimizer) optimizeDescribe(query *parser.DescribeQuery) (QueryPlan, er
 }
 
 // optimizeGraphPattern optimizes a graph pattern
-func (o *Optimizer) optimizeGraphPattern(pattern *parser.GraphPattern) (QueryPlan, error) {
+// graph is the enclosing GRAPH term, nil for the default graph
+func (o *Optimizer) optimizeGraphPattern(pattern *parser.GraphPattern, graph *parser.GraphTerm) (QueryPlan, error) {
 	switch pattern.Type {
 	case parser.GraphPatternTypeBasic:
-		return o.optimizeBasicGraphPattern(pattern)
+		return o.optimizeBasicGraphPattern(pattern, graph)
 	case parser.GraphPatternTypeGraph:
 		return o.optimizeGraphGraphPattern(pattern)
+	case parser.GraphPatternTypeUnion:
+		return o.optimizeUnionPattern(pattern, graph)
 	default:
-		// TODO: Handle other pattern types (UNION, OPTIONAL, etc.)
-		return o.optimizeBasicGraphPattern(pattern)
+		// OPTIONAL and MINUS groups are planned like any group, their parent combines them
+		return o.optimizeBasicGraphPattern(pattern, graph)
+	}
+}
+
+// optimizeUnionPattern optimizes a UNION pattern, each branch on its own
+func (o *Optimizer) optimizeUnionPattern(pattern *parser.GraphPattern, graph *parser.GraphTerm) (QueryPlan, error) {
+	var plan QueryPlan
+	for _, child := range pattern.Children {
+		childPlan, err := o.optimizeGraphPattern(child, graph)
+		if err != nil {
+			return nil, err
+		}
+		if childPlan == nil {
+			continue
+		}
+		if plan == nil {
+			plan = childPlan
+		} else {
+			plan = &UnionPlan{
+				Left:  plan,
+				Right: childPlan,
+			}
+		}
 	}
+	return plan, nil
 }
 
 // optimizeGraphGraphPattern optimizes a GRAPH pattern
 func (o *Optimizer) optimizeGraphGraphPattern(pattern *parser.GraphPattern) (QueryPlan, error) {
 	// Optimize the nested patterns within the graph
-	innerPlan, err := o.optimizeBasicGraphPattern(pattern)
+	innerPlan, err := o.optimizeBasicGraphPattern(pattern, pattern.Graph)
 	if err != nil {
 		return nil, err
 	}
@@ -328,13 +429,14 @@ func (o *Optimizer) optimizeGraphGraphPattern(pattern *parser.GraphPattern) (Que
 
 // optimizeBasicGraphPattern optimizes a basic graph pattern.
 // This function handles two execution paths:
-//  1. Order-preserving (when Elements is populated): Processes patterns, BINDs, and
-//     FILTERs in their textual order to ensure BIND variables are available to
-//     subsequent patterns. This is the correct SPARQL semantics.
-//  2. Legacy selectivity-based (fallback): Reorders patterns by selectivity for
-//     optimization, but may produce incorrect results when BIND variables are
-//     used in subsequent patterns.
-func (o *Optimizer) optimizeBasicGraphPattern(pattern *parser.GraphPattern) (QueryPlan, error) {
+//  1. Order-preserving (when Elements is populated): Processes BINDs and nested groups
+//     (OPTIONAL, MINUS, UNION, GRAPH) in their textual order to ensure BIND variables are
+//     available to subsequent patterns and OPTIONAL/MINUS apply to what precedes them,
+//     reordering only the triple patterns between them. This is the correct SPARQL semantics.
+//  2. Legacy cost-based (fallback): Reorders all patterns by estimated cost,
+//     but may produce incorrect results when BIND variables are used in
+//     subsequent patterns.
+func (o *Optimizer) optimizeBasicGraphPattern(pattern *parser.GraphPattern, graph *parser.GraphTerm) (QueryPlan, error) {
 	var plan QueryPlan
 
 	// Use Elements if available (preserves order of triples, BINDs, FILTERs)
@@ -343,20 +445,71 @@ func (o *Optimizer) optimizeBasicGraphPattern(pattern *parser.GraphPattern) (Que
 		// IMPORTANT: This preserves the semantics where:
 		//   ?s ?p ?o . BIND(?o+1 AS ?z) ?s1 ?p1 ?z
 		// makes ?z available to the second triple pattern.
-		for _, elem := range pattern.Elements {
-			if elem.Triple != nil {
-				// Add triple pattern as scan or join
-				scanPlan := &ScanPlan{Pattern: elem.Triple}
+		// Runs of triple patterns between BINDs and nested groups are joined in the order
+		// chosen by orderPatterns. FILTERs apply to the whole group wherever they are written:
+		// each is pushed down to where its variables are first bound in every solution,
+		// and equalities with constants are folded into the triple patterns.
+		filters := o.groupFilters(pattern)
+		var constants map[string]rdf.Term
+		constants, filters = propagateConstants(pattern, filters, graph)
+		substituted := make(map[*parser.TriplePattern][]string) // constants folded into each pattern
+		bound := make(map[string]bool)                          // constants already bound by a BIND
+
+		var triples []*parser.TriplePattern
+		applyFilters := func(final bool) {
+			var deferred []*parser.Filter
+			for _, filter := range filters {
+				switch {
+				case plan == nil:
+					deferred = append(deferred, filter)
+				case filterBound(filter, plan):
+					plan = pushFilter(plan, filter)
+				case final:
+					// Kept on top, where an enclosing OPTIONAL can take it over
+					plan = &FilterPlan{
+						Input:  plan,
+						Filter: filter,
+					}
+				default:
+					deferred = append(deferred, filter)
+				}
+			}
+			filters = deferred
+		}
+		flush := func() {
+			// Cyclic patterns are joined all at once, the rest pairwise after them
+			if leapfrog, rest, ok := o.leapfrogJoin(triples, graph); ok {
+				var names []string
+				for _, triple := range leapfrog.Patterns {
+					names = append(names, substituted[triple]...)
+				}
+				leapfrogPlan := bindConstants(leapfrog, constants, names, bound)
+				if plan == nil {
+					plan = leapfrogPlan
+				} else {
+					plan = o.newJoinPlan(plan, leapfrogPlan, graph)
+				}
+				triples = rest
+			}
+			for _, triple := range o.orderPatterns(triples, graph) {
+				scanPlan := bindConstants(&ScanPlan{Pattern: triple}, constants, substituted[triple], bound)
 				if plan == nil {
 					plan = scanPlan
 				} else {
-					plan = &JoinPlan{
-						Left:  plan,
-						Right: scanPlan,
-						Type:  JoinTypeNestedLoop,
-					}
+					plan = o.newJoinPlan(plan, scanPlan, graph)
 				}
+			}
+			triples = nil
+			applyFilters(false)
+		}
+
+		for _, elem := range pattern.Elements {
+			if elem.Triple != nil {
+				triple, names := substituteConstants(elem.Triple, constants)
+				substituted[triple] = names
+				triples = append(triples, triple)
 			} else if elem.Bind != nil {
+				flush()
 				// Apply BIND immediately (makes variable available to subsequent patterns)
 				if plan != nil {
 					plan = &BindPlan{
@@ -365,22 +518,25 @@ func (o *Optimizer) optimizeBasicGraphPattern(pattern *parser.GraphPattern) (Que
 						Variable:   elem.Bind.Variable,
 					}
 				}
-			} else if elem.Filter != nil {
-				// Apply FILTER immediately
-				if plan != nil {
-					plan = &FilterPlan{
-						Input:  plan,
-						Filter: elem.Filter,
-					}
+			} else if elem.Group != nil {
+				// OPTIONAL and MINUS apply to everything before them, so groups keep their position
+				flush()
+				groupPlan, err := o.optimizeGraphPattern(elem.Group, graph)
+				if err != nil {
+					return nil, err
 				}
+				plan = o.combineGroup(plan, elem.Group.Type, groupPlan, graph)
+				applyFilters(false)
 			}
 		}
+		flush()
+		applyFilters(true)
 	} else {
 		// Fallback to old behavior if Elements not populated (for backwards compatibility)
 		// Handle triple patterns if present
 		if len(pattern.Patterns) > 0 {
-			// Reorder triple patterns by selectivity (greedy approach)
-			orderedPatterns := o.reorderBySelectivity(pattern.Patterns)
+			// Order triple patterns by estimated cost (greedy approach)
+			orderedPatterns := o.orderPatterns(pattern.Patterns, graph)
 
 			// Build join plan from ordered patterns
 			plan = &ScanPlan{Pattern: orderedPatterns[0]}
@@ -389,23 +545,14 @@ func (o *Optimizer) optimizeBasicGraphPattern(pattern *parser.GraphPattern) (Que
 				rightPlan := &ScanPlan{Pattern: orderedPatterns[i]}
 
 				// Decide join type based on estimated cost
-				joinType := o.selectJoinType(plan, rightPlan)
-
-				plan = &JoinPlan{
-					Left:  plan,
-					Right: rightPlan,
-					Type:  joinType,
-				}
+				plan = o.newJoinPlan(plan, rightPlan, graph)
 			}
 		}
 
 		// Apply filters (filter push-down)
-		for _, filter := range pattern.Filters {
+		for _, filter := range o.groupFilters(pattern) {
 			if plan != nil {
-				plan = &FilterPlan{
-					Input:  plan,
-					Filter: filter,
-				}
+				plan = pushFilter(plan, filter)
 			}
 		}
 
@@ -419,70 +566,94 @@ func (o *Optimizer) optimizeBasicGraphPattern(pattern *parser.GraphPattern) (Que
 				}
 			}
 		}
+
+		// Handle child patterns (e.g., GRAPH, OPTIONAL, UNION, MINUS patterns)
+		for _, child := range pattern.Children {
+			childPlan, err := o.optimizeGraphPattern(child, graph)
+			if err != nil {
+				return nil, err
+			}
+			plan = o.combineGroup(plan, child.Type, childPlan, graph)
+		}
 	}
 
-	// Handle child patterns (e.g., GRAPH, OPTIONAL, UNION, MINUS patterns)
-	for _, child := range pattern.Children {
-		childPlan, err := o.optimizeGraphPattern(child)
-		if err != nil {
-			return nil, err
+	return plan, nil
+}
+
+// combineGroup combines the plan of the preceding patterns with the plan of a nested group
+func (o *Optimizer) combineGroup(plan QueryPlan, groupType parser.GraphPatternType, groupPlan QueryPlan, graph *parser.GraphTerm) QueryPlan {
+	if groupPlan == nil {
+		// An empty group matches the empty solution, which changes nothing
+		return plan
+	}
+	if plan == nil {
+		if groupType == parser.GraphPatternTypeMinus {
+			return nil
 		}
+		return groupPlan
+	}
 
-		if childPlan != nil {
-			if plan == nil {
-				plan = childPlan
-			} else {
-				// Create appropriate plan based on child pattern type
-				switch child.Type {
-				case parser.GraphPatternTypeOptional:
-					plan = &OptionalPlan{
-						Left:  plan,
-						Right: childPlan,
-					}
-				case parser.GraphPatternTypeUnion:
-					plan = &UnionPlan{
-						Left:  plan,
-						Right: childPlan,
-					}
-				case parser.GraphPatternTypeMinus:
-					plan = &MinusPlan{
-						Left:  plan,
-						Right: childPlan,
-					}
-				default:
-					// Regular join for other pattern types
-					plan = &JoinPlan{
-						Left:  plan,
-						Right: childPlan,
-						Type:  JoinTypeNestedLoop,
-					}
-				}
-			}
+	switch groupType {
+	case parser.GraphPatternTypeOptional:
+		return o.newOptionalPlan(plan, groupPlan)
+	case parser.GraphPatternTypeMinus:
+		return newMinusPlan(plan, groupPlan)
+	default:
+		// GRAPH, UNION and plain nested groups are joined
+		return o.newJoinPlan(plan, groupPlan, graph)
+	}
+}
+
+// newOptionalPlan plans OPTIONAL as a left outer join.
+// Top-level filters of the right side that use variables the right side does not bind
+// are moved into the join, where they see the left side's bindings too.
+func (o *Optimizer) newOptionalPlan(left, right QueryPlan) *OptionalPlan {
+	var filters []*parser.Filter
+	for {
+		filterPlan, ok := right.(*FilterPlan)
+		if !ok || filterBound(filterPlan.Filter, filterPlan.Input) {
+			break
 		}
+		filters = append([]*parser.Filter{filterPlan.Filter}, filters...)
+		right = filterPlan.Input
 	}
 
-	return plan, nil
+	// The right side is read once into a hash table unless the left side has at most
+	// one row, in which case evaluating it once per left row costs the same
+	joinType := JoinTypeHashJoin
+	if atMostOneRow(left) {
+		joinType = JoinTypeNestedLoop
+	}
+
+	return &OptionalPlan{
+		Left:      left,
+		Right:     right,
+		Type:      joinType,
+		Variables: sharedVariables(left, right),
+		Filters:   filters,
+	}
 }
 
-// reorderBySelectivity reorders triple patterns by estimated selectivity
-// More selective patterns (fewer results) should be executed first
-func (o *Optimizer) reorderBySelectivity(patterns []*parser.TriplePattern) []*parser.TriplePattern {
-	// Create a copy to avoid modifying the original
-	ordered := make([]*parser.TriplePattern, len(patterns))
-	copy(ordered, patterns)
-
-	// Simple heuristic-based ordering:
-	// 1. Patterns with more bound terms are more selective
-	// 2. Patterns with bound subjects/predicates are preferred
-	for i := 0; i < len(ordered); i++ {
-		for j := i + 1; j < len(ordered); j++ {
-			if o.estimateSelectivity(ordered[j]) < o.estimateSelectivity(ordered[i]) {
-				ordered[i], ordered[j] = ordered[j], ordered[i]
-			}
+// newMinusPlan plans MINUS, which can be dropped when the two sides can't share a variable
+func newMinusPlan(left, right QueryPlan) QueryPlan {
+	leftVars := planVariables(left)
+	disjoint := true
+	for name := range planVariables(right) {
+		if leftVars[name] {
+			disjoint = false
+			break
 		}
 	}
+	if disjoint {
+		// Solutions with no variable in common are never removed by MINUS
+		return left
+	}
 
-	return ordered
+	return &MinusPlan{
+		Left:      left,
+		Right:     right,
+		Variables: sharedVariables(left, right),
+	}
 }
 
 // estimateSelectivity estimates the selectivity of a triple pattern
@@ -508,11 +679,269 @@ func (o *Optimizer) estimateSelectivity(pattern *parser.TriplePattern) float64 {
 	return selectivity
 }
 
-// selectJoinType selects the appropriate join type based on the plans
-func (o *Optimizer) selectJoinType(left, right QueryPlan) JoinType {
-	// Simple heuristic: use hash join for larger inputs, nested loop for smaller
-	// In a real implementation, this would consider statistics and cardinality estimates
+// newJoinPlan joins two plans using the join type chosen by selectJoinType
+func (o *Optimizer) newJoinPlan(left, right QueryPlan, graph *parser.GraphTerm) *JoinPlan {
+	joinType, variables := o.selectJoinType(left, right, graph)
+
+	// A hash join builds its table from the right side, so make that the smaller one
+	if joinType == JoinTypeHashJoin && o.estimateCardinality(right, graph) > o.estimateCardinality(left, graph) {
+		left, right = right, left
+	}
+
+	return &JoinPlan{
+		Left:      left,
+		Right:     right,
+		Type:      joinType,
+		Variables: variables,
+	}
+}
+
+// selectJoinType selects the join type for two plans and returns the join variables:
+//   - merge join when both inputs come out of the indexes ordered by a shared variable
+//   - nested loop when the left side has at most one row, so the right side runs once anyway
+//   - hash join otherwise, evaluating the right side once instead of once per left row
+func (o *Optimizer) selectJoinType(left, right QueryPlan, graph *parser.GraphTerm) (JoinType, []string) {
+	shared := sharedVariables(left, right)
+
+	if order := orderedBy(left, graph); order != "" && order == orderedBy(right, graph) {
+		return JoinTypeMergeJoin, []string{order}
+	}
+
+	if atMostOneRow(left) {
+		return JoinTypeNestedLoop, shared
+	}
+
+	return JoinTypeHashJoin, shared
+}
+
+// estimateCardinality estimates the number of solutions a plan produces
+func (o *Optimizer) estimateCardinality(plan QueryPlan, graph *parser.GraphTerm) float64 {
+	return o.estimatePlan(plan, graph).rows
+}
+
+// atMostOneRow reports whether a plan can produce at most one solution
+func atMostOneRow(plan QueryPlan) bool {
+	switch p := plan.(type) {
+	case *ScanPlan:
+		return len(patternVariables(p.Pattern)) == 0
+	case *JoinPlan:
+		return atMostOneRow(p.Left) && atMostOneRow(p.Right)
+	case *LimitPlan:
+		return p.Limit <= 1 || atMostOneRow(p.Input)
+	case *FilterPlan:
+		return atMostOneRow(p.Input)
+	case *BindPlan:
+		return atMostOneRow(p.Input)
+	default:
+		return false
+	}
+}
+
+// orderedBy returns the variable a plan's solutions are ordered by (in encoded term order), or ""
+func orderedBy(plan QueryPlan, graph *parser.GraphTerm) string {
+	switch p := plan.(type) {
+	case *ScanPlan:
+		return store.OrderedBy(scanPattern(p.Pattern, graph))
+	case *LeapfrogJoinPlan:
+		// The first variable is bound in order, by seeking through the tries
+		return p.Variables[0]
+	case *JoinPlan:
+		if p.Type == JoinTypeMergeJoin {
+			return p.Variables[0]
+		}
+		// Nested loop and hash joins stream the left side in order
+		return orderedBy(p.Left, graph)
+	case *FilterPlan:
+		return orderedBy(p.Input, graph)
+	case *BindPlan:
+		// BIND replaces the value of a variable that is already bound
+		if order := orderedBy(p.Input, graph); order != p.Variable.Name {
+			return order
+		}
+		return ""
+	case *GraphPlan:
+		return orderedBy(p.Input, p.Graph)
+	default:
+		return ""
+	}
+}
+
+// boundVariables returns the variables bound in every solution of a plan
+func boundVariables(plan QueryPlan) map[string]bool {
+	vars := make(map[string]bool)
+	switch p := plan.(type) {
+	case *ScanPlan:
+		for _, name := range patternVariables(p.Pattern) {
+			vars[name] = true
+		}
+	case *LeapfrogJoinPlan:
+		for _, name := range p.Variables {
+			vars[name] = true
+		}
+	case *JoinPlan:
+		maps.Copy(vars, boundVariables(p.Left))
+		maps.Copy(vars, boundVariables(p.Right))
+	case *UnionPlan:
+		right := boundVariables(p.Right)
+		for name := range boundVariables(p.Left) {
+			if right[name] {
+				vars[name] = true
+			}
+		}
+	case *OptionalPlan:
+		return boundVariables(p.Left)
+	case *MinusPlan:
+		return boundVariables(p.Left)
+	case *GraphPlan:
+		vars = boundVariables(p.Input)
+		if p.Graph != nil && p.Graph.Variable != nil {
+			vars[p.Graph.Variable.Name] = true
+		}
+	case *FilterPlan:
+		return boundVariables(p.Input)
+	case *BindPlan:
+		// The BIND variable stays unbound when its expression fails, which a constant never does
+		vars = boundVariables(p.Input)
+		if _, ok := p.Expression.(*parser.LiteralExpression); ok {
+			vars[p.Variable.Name] = true
+		}
+	case *OrderByPlan:
+		return boundVariables(p.Input)
+	case *DistinctPlan:
+		return boundVariables(p.Input)
+	case *GroupPlan:
+		input := boundVariables(p.Input)
+		for _, condition := range p.GroupBy {
+			if condition.Expression == nil && condition.Variable != nil && input[condition.Variable.Name] {
+				vars[condition.Variable.Name] = true
+			}
+		}
+	case *LimitPlan:
+		return boundVariables(p.Input)
+	case *OffsetPlan:
+		return boundVariables(p.Input)
+	}
+	return vars
+}
+
+// sharedVariables returns the sorted variables bound in every solution of both plans
+func sharedVariables(left, right QueryPlan) []string {
+	leftVars := boundVariables(left)
+	rightVars := boundVariables(right)
+
+	var shared []string
+	for name := range leftVars {
+		if rightVars[name] {
+			shared = append(shared, name)
+		}
+	}
+	sort.Strings(shared)
+	return shared
+}
+
+// planVariables returns the variables that may be bound in some solution of a plan
+func planVariables(plan QueryPlan) map[string]bool {
+	vars := make(map[string]bool)
+	switch p := plan.(type) {
+	case *ScanPlan:
+		for _, name := range patternVariables(p.Pattern) {
+			vars[name] = true
+		}
+	case *LeapfrogJoinPlan:
+		for _, name := range p.Variables {
+			vars[name] = true
+		}
+	case *JoinPlan:
+		maps.Copy(vars, planVariables(p.Left))
+		maps.Copy(vars, planVariables(p.Right))
+	case *UnionPlan:
+		maps.Copy(vars, planVariables(p.Left))
+		maps.Copy(vars, planVariables(p.Right))
+	case *OptionalPlan:
+		maps.Copy(vars, planVariables(p.Left))
+		maps.Copy(vars, planVariables(p.Right))
+	case *MinusPlan:
+		return planVariables(p.Left)
+	case *GraphPlan:
+		vars = planVariables(p.Input)
+		if p.Graph != nil && p.Graph.Variable != nil {
+			vars[p.Graph.Variable.Name] = true
+		}
+	case *FilterPlan:
+		return planVariables(p.Input)
+	case *BindPlan:
+		vars = planVariables(p.Input)
+		vars[p.Variable.Name] = true
+	case *ProjectionPlan:
+		for _, v := range p.Variables {
+			vars[v.Name] = true
+		}
+	case *OrderByPlan:
+		return planVariables(p.Input)
+	case *DistinctPlan:
+		return planVariables(p.Input)
+	case *GroupPlan:
+		for _, condition := range p.GroupBy {
+			if condition.Variable != nil {
+				vars[condition.Variable.Name] = true
+			}
+		}
+	case *LimitPlan:
+		return planVariables(p.Input)
+	case *OffsetPlan:
+		return planVariables(p.Input)
+	}
+	return vars
+}
 
-	// For now, default to nested loop (simpler to implement)
-	return JoinTypeNestedLoop
+// patternVariables returns the names of the variables in a triple pattern, including inside triple terms
+func patternVariables(pattern *parser.TriplePattern) []string {
+	var names []string
+	var collect func(t parser.TermOrVariable)
+	collect = func(t parser.TermOrVariable) {
+		if t.IsVariable() {
+			names = append(names, t.Variable.Name)
+		}
+		if t.IsTripleTerm() {
+			collect(t.TripleTerm.Subject)
+			collect(t.TripleTerm.Predicate)
+			collect(t.TripleTerm.Object)
+		}
+	}
+	collect(pattern.Subject)
+	collect(pattern.Predicate)
+	collect(pattern.Object)
+	return names
+}
+
+// scanPattern converts a triple pattern to the store pattern its scan uses
+func scanPattern(pattern *parser.TriplePattern, graph *parser.GraphTerm) *store.Pattern {
+	var convert func(t parser.TermOrVariable) any
+	convert = func(t parser.TermOrVariable) any {
+		if t.IsVariable() {
+			return store.NewVariable(t.Variable.Name)
+		}
+		if t.IsTripleTerm() {
+			return &store.TripleTermPattern{
+				Subject:   convert(t.TripleTerm.Subject),
+				Predicate: convert(t.TripleTerm.Predicate),
+				Object:    convert(t.TripleTerm.Object),
+			}
+		}
+		return t.Term
+	}
+
+	scan := &store.Pattern{
+		Subject:   convert(pattern.Subject),
+		Predicate: convert(pattern.Predicate),
+		Object:    convert(pattern.Object),
+	}
+	if graph != nil {
+		if graph.Variable != nil {
+			scan.Graph = store.NewVariable(graph.Variable.Name)
+		} else {
+			scan.Graph = graph.IRI
+		}
+	}
+	return scan
 }
@@ -0,0 +1,134 @@
+package optimizer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+)
+
+// term makes a pattern position: "?x" is a variable, anything else an IRI
+func term(value string) parser.TermOrVariable {
+	if name, ok := strings.CutPrefix(value, "?"); ok {
+		return parser.TermOrVariable{Variable: &parser.Variable{Name: name}}
+	}
+	return parser.TermOrVariable{Term: rdf.NewNamedNode("http://example.org/" + value)}
+}
+
+func scan(s, p, o string) *ScanPlan {
+	return &ScanPlan{Pattern: &parser.TriplePattern{Subject: term(s), Predicate: term(p), Object: term(o)}}
+}
+
+func graphVariable(name string) *parser.GraphTerm {
+	return &parser.GraphTerm{Variable: &parser.Variable{Name: name}}
+}
+
+func TestOrderedBy(t *testing.T) {
+	quoted := scan("?s", "p", "?o")
+	quoted.Pattern.Object = parser.TermOrVariable{TripleTerm: scan("?a", "q", "?b").Pattern}
+	bind := func(input QueryPlan, name string) *BindPlan {
+		return &BindPlan{
+			Input:      input,
+			Expression: &parser.LiteralExpression{Literal: rdf.NewLiteral("x")},
+			Variable:   &parser.Variable{Name: name},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		plan  QueryPlan
+		graph *parser.GraphTerm
+		order string
+	}{
+		{"predicate bound", scan("?s", "p", "?o"), nil, "o"},
+		{"predicate and object bound", scan("?s", "p", "o"), nil, "s"},
+		{"subject and predicate bound", scan("s", "p", "?o"), nil, "o"},
+		{"nothing bound", scan("?s", "?p", "?o"), nil, "s"},
+		{"everything bound", scan("s", "p", "o"), nil, ""},
+		{"triple term pattern first", quoted, nil, ""},
+		{"graph variable last", scan("?s", "p", "o"), graphVariable("g"), "s"},
+		{"graph plan", &GraphPlan{Input: scan("?s", "p", "o"), Graph: graphVariable("g")}, nil, "s"},
+		{"filter", &FilterPlan{Input: scan("?s", "p", "o"), Filter: &parser.Filter{}}, nil, "s"},
+		{"bind", bind(scan("?s", "p", "o"), "x"), nil, "s"},
+		{"bind replacing the order", bind(scan("?s", "p", "o"), "s"), nil, ""},
+		{"hash join keeps the left order", &JoinPlan{Left: scan("?s", "p", "o"), Right: scan("?x", "q", "?s"), Type: JoinTypeHashJoin}, nil, "s"},
+		{"nested loop keeps the left order", &JoinPlan{Left: scan("?s", "p", "o"), Right: scan("?s", "q", "?x"), Type: JoinTypeNestedLoop}, nil, "s"},
+		{"merge join", &JoinPlan{Left: scan("?s", "p", "o"), Right: scan("?s", "q", "o"), Type: JoinTypeMergeJoin, Variables: []string{"s"}}, nil, "s"},
+		{"leapfrog join", &LeapfrogJoinPlan{Variables: []string{"x", "y"}}, nil, "x"},
+		{"union", &UnionPlan{Left: scan("?s", "p", "o"), Right: scan("?s", "q", "o")}, nil, ""},
+		{"optional", &OptionalPlan{Left: scan("?s", "p", "o"), Right: scan("?s", "q", "?x")}, nil, ""},
+		{"limit", &LimitPlan{Input: scan("?s", "p", "o"), Limit: 10}, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if order := orderedBy(tt.plan, tt.graph); order != tt.order {
+				t.Errorf("expected order %q, got %q", tt.order, order)
+			}
+		})
+	}
+}
+
+func TestSelectJoinType(t *testing.T) {
+	tests := []struct {
+		name        string
+		left, right QueryPlan
+		joinType    JoinType
+		variables   string
+	}{
+		{"both ordered by the shared variable", scan("?s", "p", "o"), scan("?s", "q", "o"), JoinTypeMergeJoin, "s"},
+		{"ordered by the shared object", scan("?s", "p", "?o"), scan("?x", "q", "?o"), JoinTypeMergeJoin, "o"},
+		{"merge on one of several shared variables", scan("?s", "p", "?o"), scan("?s", "q", "?o"), JoinTypeMergeJoin, "o"},
+		{"ordered by different variables", scan("?s", "p", "?o"), scan("?s", "q", "?x"), JoinTypeHashJoin, "s"},
+		{"one side unordered", &UnionPlan{Left: scan("?s", "p", "o"), Right: scan("?s", "q", "o")}, scan("?s", "q", "o"), JoinTypeHashJoin, "s"},
+		{"bind replacing the order", &BindPlan{Input: scan("?s", "p", "o"), Expression: &parser.LiteralExpression{Literal: rdf.NewLiteral("x")}, Variable: &parser.Variable{Name: "s"}}, scan("?s", "q", "o"), JoinTypeHashJoin, "s"},
+		{"merge join input", &JoinPlan{Left: scan("?s", "p", "o"), Right: scan("?s", "q", "o"), Type: JoinTypeMergeJoin, Variables: []string{"s"}}, scan("?s", "r", "o"), JoinTypeMergeJoin, "s"},
+		{"left side of at most one row", scan("s", "p", "o"), scan("?s", "q", "?o"), JoinTypeNestedLoop, ""},
+		{"no shared variables", scan("?s", "p", "?o"), scan("?x", "q", "?y"), JoinTypeHashJoin, ""},
+	}
+
+	o := NewOptimizer(&Statistics{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			joinType, variables := o.selectJoinType(tt.left, tt.right, nil)
+			if joinType != tt.joinType {
+				t.Errorf("expected %v, got %v", tt.joinType, joinType)
+			}
+			if joined := strings.Join(variables, " "); joined != tt.variables {
+				t.Errorf("expected variables %q, got %q", tt.variables, joined)
+			}
+		})
+	}
+}
+
+func TestHideEstimates(t *testing.T) {
+	o := NewOptimizer(&Statistics{TotalTriples: 100})
+	plan := o.Explain(&JoinPlan{Left: scan("?s", "p", "?o"), Right: scan("?s", "q", "?x"), Type: JoinTypeHashJoin})
+	plan.Walk(func(node *PlanNode) {
+		if node.EstimatedRows == nil {
+			t.Errorf("expected an estimate for %s", node.Operator)
+		}
+	})
+	if !strings.Contains(plan.String(), "estimated rows=") {
+		t.Errorf("expected estimates in %q", plan.String())
+	}
+
+	plan.HideEstimates()
+	plan.Walk(func(node *PlanNode) {
+		if node.EstimatedRows != nil {
+			t.Errorf("expected no estimate for %s, got %v", node.Operator, *node.EstimatedRows)
+		}
+	})
+	if text := plan.String(); strings.Contains(text, "estimated") {
+		t.Errorf("expected no estimates in %q", text)
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("failed to marshal plan: %v", err)
+	}
+	if strings.Contains(string(data), "estimatedRows") {
+		t.Errorf("expected no estimates in %s", data)
+	}
+}
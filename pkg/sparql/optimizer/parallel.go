@@ -0,0 +1,71 @@
+package optimizer
+
+import "github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+
+// parallelScanRows is the estimated number of rows from which a scan is worth
+// splitting across goroutines
+const parallelScanRows = 50000
+
+// markParallelScans marks the large scans and the unions of a plan as Parallel.
+// A partitioned scan returns its solutions out of key order, so scans are left
+// alone when ordered is set: below a merge join, which needs its inputs in order,
+// or below LIMIT and OFFSET, which would return different solutions from one run
+// to the next; the branches of unions there run one after the other. Scans re-run
+// for every left solution of a nested loop join are small by construction and left
+// alone as well.
+func (o *Optimizer) markParallelScans(plan QueryPlan, graph *parser.GraphTerm, ordered bool) {
+	switch p := plan.(type) {
+	case *ScanPlan:
+		p.Parallel = !ordered && o.estimatePattern(p.Pattern, graph).rows >= parallelScanRows
+	case *JoinPlan:
+		switch p.Type {
+		case JoinTypeMergeJoin:
+			o.markParallelScans(p.Left, graph, true)
+			o.markParallelScans(p.Right, graph, true)
+		case JoinTypeNestedLoop:
+			o.markParallelScans(p.Left, graph, ordered)
+		default:
+			// The output keeps the order of the left side
+			o.markParallelScans(p.Left, graph, ordered)
+			o.markParallelScans(p.Right, graph, false)
+		}
+	case *OptionalPlan:
+		o.markParallelScans(p.Left, graph, ordered)
+		if p.Type != JoinTypeNestedLoop {
+			o.markParallelScans(p.Right, graph, false)
+		}
+	case *MinusPlan:
+		o.markParallelScans(p.Left, graph, ordered)
+		o.markParallelScans(p.Right, graph, false)
+	case *UnionPlan:
+		p.Parallel = !ordered
+		o.markParallelScans(p.Left, graph, ordered)
+		o.markParallelScans(p.Right, graph, ordered)
+	case *FilterPlan:
+		o.markParallelScans(p.Input, graph, ordered)
+	case *BindPlan:
+		o.markParallelScans(p.Input, graph, ordered)
+	case *ProjectionPlan:
+		o.markParallelScans(p.Input, graph, ordered)
+	case *DistinctPlan:
+		o.markParallelScans(p.Input, graph, ordered)
+	case *GraphPlan:
+		o.markParallelScans(p.Input, p.Graph, ordered)
+	case *LimitPlan:
+		o.markParallelScans(p.Input, graph, true)
+	case *OffsetPlan:
+		o.markParallelScans(p.Input, graph, true)
+	case *OrderByPlan:
+		o.markParallelScans(p.Input, graph, false)
+	case *GroupPlan:
+		o.markParallelScans(p.Input, graph, false)
+	case *ConstructPlan:
+		if p.Input != nil {
+			o.markParallelScans(p.Input, graph, false)
+		}
+	case *DescribePlan:
+		if p.Input != nil {
+			o.markParallelScans(p.Input, graph, false)
+		}
+	}
+}
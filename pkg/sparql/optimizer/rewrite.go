@@ -0,0 +1,331 @@
+package optimizer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/evaluator"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+	"github.com/aleksaelezovic/trigo/pkg/store"
+)
+
+// nonDeterministicFunctions give a different result on every call, so they are never constant
+var nonDeterministicFunctions = map[string]bool{
+	"RAND":    true,
+	"NOW":     true,
+	"UUID":    true,
+	"STRUUID": true,
+	"BNODE":   true,
+}
+
+// groupFilters returns the FILTERs of a group, with conjunctions split into separate
+// filters and trivially true filters dropped
+func (o *Optimizer) groupFilters(pattern *parser.GraphPattern) []*parser.Filter {
+	var written []*parser.Filter
+	if len(pattern.Elements) > 0 {
+		for _, elem := range pattern.Elements {
+			if elem.Filter != nil {
+				written = append(written, elem.Filter)
+			}
+		}
+	} else {
+		written = pattern.Filters
+	}
+
+	var filters []*parser.Filter
+	for _, filter := range written {
+		for _, expr := range splitConjunction(filter.Expression) {
+			if triviallyTrue(expr) {
+				continue
+			}
+			if expr == filter.Expression {
+				filters = append(filters, filter)
+			} else {
+				filters = append(filters, &parser.Filter{Expression: expr})
+			}
+		}
+	}
+	return filters
+}
+
+// splitConjunction splits A && B && ... into its operands
+func splitConjunction(expr parser.Expression) []parser.Expression {
+	if binary, ok := expr.(*parser.BinaryExpression); ok && binary.Operator == parser.OpAnd {
+		return append(splitConjunction(binary.Left), splitConjunction(binary.Right)...)
+	}
+	return []parser.Expression{expr}
+}
+
+// triviallyTrue reports whether an expression without variables evaluates to true
+func triviallyTrue(expr parser.Expression) bool {
+	if !constantExpression(expr) {
+		return false
+	}
+	result, err := evaluator.NewEvaluator().Evaluate(expr, store.NewBinding())
+	if err != nil {
+		return false
+	}
+	lit, ok := result.(*rdf.Literal)
+	return ok && lit.Datatype != nil && lit.Datatype.IRI == "http://www.w3.org/2001/XMLSchema#boolean" &&
+		(lit.Value == "true" || lit.Value == "1")
+}
+
+// constantExpression reports whether an expression has the same value for every solution
+func constantExpression(expr parser.Expression) bool {
+	names, ok := expressionVariables(expr)
+	if !ok || len(names) > 0 {
+		return false
+	}
+	deterministic := true
+	var visit func(expr parser.Expression)
+	visit = func(expr parser.Expression) {
+		switch e := expr.(type) {
+		case *parser.BinaryExpression:
+			visit(e.Left)
+			visit(e.Right)
+		case *parser.UnaryExpression:
+			visit(e.Operand)
+		case *parser.FunctionCallExpression:
+			if nonDeterministicFunctions[strings.ToUpper(e.Function)] {
+				deterministic = false
+			}
+			for _, arg := range e.Arguments {
+				visit(arg)
+			}
+		case *parser.InExpression:
+			visit(e.Expression)
+			for _, value := range e.Values {
+				visit(value)
+			}
+		}
+	}
+	visit(expr)
+	return deterministic
+}
+
+// propagateConstants finds filters that fix a variable to one term, ?x = <iri> or sameTerm(?x, term),
+// for variables used by the group's triple patterns. Those filters are removed: the term is
+// substituted into the patterns and the variable is bound to it above the scans.
+// = is only used with IRIs, as it compares literals by value rather than by term.
+func propagateConstants(pattern *parser.GraphPattern, filters []*parser.Filter, graph *parser.GraphTerm) (map[string]rdf.Term, []*parser.Filter) {
+	used := make(map[string]bool)
+	for _, elem := range pattern.Elements {
+		if elem.Triple != nil {
+			for _, name := range patternVariables(elem.Triple) {
+				used[name] = true
+			}
+		}
+	}
+	if graph != nil && graph.Variable != nil {
+		// The graph variable is bound by the scans themselves
+		delete(used, graph.Variable.Name)
+	}
+
+	constants := make(map[string]rdf.Term)
+	var remaining []*parser.Filter
+	for _, filter := range filters {
+		name, term, ok := constantEquality(filter.Expression)
+		if ok && used[name] && constants[name] == nil {
+			constants[name] = term
+			continue
+		}
+		remaining = append(remaining, filter)
+	}
+	return constants, remaining
+}
+
+// constantEquality matches ?x = <iri> and sameTerm(?x, term), either way round
+func constantEquality(expr parser.Expression) (string, rdf.Term, bool) {
+	var left, right parser.Expression
+	sameTerm := false
+	switch e := expr.(type) {
+	case *parser.BinaryExpression:
+		if e.Operator != parser.OpEqual {
+			return "", nil, false
+		}
+		left, right = e.Left, e.Right
+	case *parser.FunctionCallExpression:
+		if !strings.EqualFold(e.Function, "sameTerm") || len(e.Arguments) != 2 {
+			return "", nil, false
+		}
+		left, right = e.Arguments[0], e.Arguments[1]
+		sameTerm = true
+	default:
+		return "", nil, false
+	}
+
+	variable, ok := left.(*parser.VariableExpression)
+	if !ok {
+		left, right = right, left
+		if variable, ok = left.(*parser.VariableExpression); !ok {
+			return "", nil, false
+		}
+	}
+	constant, ok := right.(*parser.LiteralExpression)
+	if !ok || constant.Literal == nil {
+		return "", nil, false
+	}
+	if _, isIRI := constant.Literal.(*rdf.NamedNode); !isIRI && !sameTerm {
+		return "", nil, false
+	}
+	return variable.Variable.Name, constant.Literal, true
+}
+
+// substituteConstants replaces the variables of a triple pattern that have a constant,
+// returning the names of the replaced variables
+func substituteConstants(pattern *parser.TriplePattern, constants map[string]rdf.Term) (*parser.TriplePattern, []string) {
+	if len(constants) == 0 {
+		return pattern, nil
+	}
+	var names []string
+	var substitute func(t parser.TermOrVariable) parser.TermOrVariable
+	substitute = func(t parser.TermOrVariable) parser.TermOrVariable {
+		if t.IsVariable() {
+			if term, ok := constants[t.Variable.Name]; ok {
+				names = append(names, t.Variable.Name)
+				return parser.TermOrVariable{Term: term}
+			}
+		}
+		if t.IsTripleTerm() {
+			return parser.TermOrVariable{TripleTerm: &parser.TriplePattern{
+				Subject:   substitute(t.TripleTerm.Subject),
+				Predicate: substitute(t.TripleTerm.Predicate),
+				Object:    substitute(t.TripleTerm.Object),
+			}}
+		}
+		return t
+	}
+	substituted := &parser.TriplePattern{
+		Subject:   substitute(pattern.Subject),
+		Predicate: substitute(pattern.Predicate),
+		Object:    substitute(pattern.Object),
+	}
+	if len(names) == 0 {
+		return pattern, nil
+	}
+	return substituted, names
+}
+
+// bindConstants binds the constants substituted into the patterns of a scan (or leapfrog join),
+// each once per group above the first scan that used it
+func bindConstants(scan QueryPlan, constants map[string]rdf.Term, names []string, bound map[string]bool) QueryPlan {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+
+	plan := scan
+	for _, name := range names {
+		if bound[name] {
+			continue
+		}
+		bound[name] = true
+		plan = &BindPlan{
+			Input:      plan,
+			Expression: &parser.LiteralExpression{Literal: constants[name]},
+			Variable:   &parser.Variable{Name: name},
+		}
+	}
+	return plan
+}
+
+// pushFilter applies a filter as deep in a plan as its variables are bound in every solution
+func pushFilter(plan QueryPlan, filter *parser.Filter) QueryPlan {
+	switch p := plan.(type) {
+	case *UnionPlan:
+		// Each branch is filtered on its own
+		return &UnionPlan{
+			Left:  pushFilter(p.Left, filter),
+			Right: pushFilter(p.Right, filter),
+		}
+	case *JoinPlan:
+		if filterBound(filter, p.Left) {
+			p.Left = pushFilter(p.Left, filter)
+			return p
+		}
+		if filterBound(filter, p.Right) {
+			p.Right = pushFilter(p.Right, filter)
+			return p
+		}
+	case *OptionalPlan:
+		// The left side's bindings are kept as they are by the outer join
+		if filterBound(filter, p.Left) {
+			p.Left = pushFilter(p.Left, filter)
+			return p
+		}
+	case *MinusPlan:
+		if filterBound(filter, p.Left) {
+			p.Left = pushFilter(p.Left, filter)
+			return p
+		}
+	case *BindPlan:
+		if filterBound(filter, p.Input) {
+			p.Input = pushFilter(p.Input, filter)
+			return p
+		}
+	case *FilterPlan:
+		if filterBound(filter, p.Input) {
+			p.Input = pushFilter(p.Input, filter)
+			return p
+		}
+	}
+	return &FilterPlan{
+		Input:  plan,
+		Filter: filter,
+	}
+}
+
+// filterBound reports whether every variable of a filter is bound in all solutions of a plan,
+// so the filter gives the same result wherever it is applied above the plan
+func filterBound(filter *parser.Filter, plan QueryPlan) bool {
+	names, ok := expressionVariables(filter.Expression)
+	if !ok {
+		return false
+	}
+	bound := boundVariables(plan)
+	for _, name := range names {
+		if !bound[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// expressionVariables returns the variables of an expression.
+// Returns false for EXISTS, whose pattern may see any variable.
+func expressionVariables(expr parser.Expression) ([]string, bool) {
+	var names []string
+	var collect func(expr parser.Expression) bool
+	collect = func(expr parser.Expression) bool {
+		switch e := expr.(type) {
+		case nil, *parser.LiteralExpression:
+			return true
+		case *parser.VariableExpression:
+			names = append(names, e.Variable.Name)
+			return true
+		case *parser.BinaryExpression:
+			return collect(e.Left) && collect(e.Right)
+		case *parser.UnaryExpression:
+			return collect(e.Operand)
+		case *parser.FunctionCallExpression:
+			for _, arg := range e.Arguments {
+				if !collect(arg) {
+					return false
+				}
+			}
+			return true
+		case *parser.InExpression:
+			for _, value := range e.Values {
+				if !collect(value) {
+					return false
+				}
+			}
+			return collect(e.Expression)
+		default:
+			return false
+		}
+	}
+	if !collect(expr) {
+		return nil, false
+	}
+	return names, true
+}
@@ -0,0 +1,219 @@
+package optimizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+	"github.com/aleksaelezovic/trigo/pkg/sparql/parser"
+)
+
+// parseGroup parses the WHERE clause of a query using the ex: prefix
+func parseGroup(t *testing.T, where string) *parser.GraphPattern {
+	t.Helper()
+	query, err := parser.NewParser("PREFIX ex: <http://example.org/> SELECT * WHERE { " + where + " }").Parse()
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", where, err)
+	}
+	return query.Select.Where
+}
+
+// parseFilter parses a FILTER expression
+func parseFilter(t *testing.T, expression string) *parser.Filter {
+	t.Helper()
+	filters := NewOptimizer(&Statistics{}).groupFilters(parseGroup(t, "FILTER("+expression+")"))
+	if len(filters) != 1 {
+		t.Fatalf("expected one filter in %q, got %d", expression, len(filters))
+	}
+	return filters[0]
+}
+
+func TestConstantEquality(t *testing.T) {
+	tests := []struct {
+		expression string
+		name       string
+		term       string // "" when nothing is folded
+	}{
+		{"?x = ex:a", "x", "<http://example.org/a>"},
+		{"ex:a = ?x", "x", "<http://example.org/a>"},
+		{"sameTerm(?x, ex:a)", "x", "<http://example.org/a>"},
+		{`sameTerm(?x, "a"@en)`, "x", `"a"@en`},
+		{`SAMETERM("a", ?x)`, "x", `"a"`},
+		{"sameTerm(?x, 1)", "x", `"1"^^<http://www.w3.org/2001/XMLSchema#integer>`},
+		// = compares literals by value, 1 = 1.0 and "a" = "a"^^xsd:string
+		{`?x = "a"`, "", ""},
+		{"?x = 1", "", ""},
+		{"1 = ?x", "", ""},
+		{"?x != ex:a", "", ""},
+		{"?x = ?y", "", ""},
+		{"sameTerm(?x, ?y)", "", ""},
+		{"sameTerm(?x, ex:a, ex:b)", "", ""},
+		{"isIRI(?x)", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			name, term, ok := constantEquality(parseFilter(t, tt.expression).Expression)
+			if tt.term == "" {
+				if ok {
+					t.Errorf("expected nothing folded, got ?%s = %s", name, term)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("expected a constant")
+			}
+			if name != tt.name || term.String() != tt.term {
+				t.Errorf("expected ?%s = %s, got ?%s = %s", tt.name, tt.term, name, term)
+			}
+		})
+	}
+}
+
+func TestPropagateConstants(t *testing.T) {
+	o := NewOptimizer(&Statistics{})
+	group := parseGroup(t, `?s ex:p ?o . ?s ex:q ?v
+		FILTER(?o = ex:a) FILTER(?o = ex:b) FILTER(?v = "a") FILTER(sameTerm(?v, "b"))
+		FILTER(?z = ex:c) FILTER(?s != ex:d)`)
+
+	constants, remaining := propagateConstants(group, o.groupFilters(group), nil)
+	if len(constants) != 2 {
+		t.Errorf("expected 2 constants, got %v", constants)
+	}
+	if term := constants["o"]; term == nil || term.String() != "<http://example.org/a>" {
+		t.Errorf("expected ?o = ex:a, got %v", term)
+	}
+	if term := constants["v"]; term == nil || term.String() != `"b"` {
+		t.Errorf(`expected ?v = "b", got %v`, term)
+	}
+	// A second constant for a variable, = on literals, variables the patterns don't use
+	// and other comparisons stay filters
+	if len(remaining) != 4 {
+		t.Errorf("expected 4 remaining filters, got %d", len(remaining))
+	}
+
+	// The graph variable is bound by the scans, not substituted
+	group = parseGroup(t, "GRAPH ?g { ?s ex:p ?o FILTER(?g = ex:g) }").Children[0]
+	constants, remaining = propagateConstants(group, o.groupFilters(group), group.Graph)
+	if len(constants) != 0 || len(remaining) != 1 {
+		t.Errorf("expected the graph filter to stay, got constants %v and %d filters", constants, len(remaining))
+	}
+}
+
+func TestGroupFilters(t *testing.T) {
+	tests := []struct {
+		filters string
+		count   int
+	}{
+		{"FILTER(?a > 1 && (?b < 2 && ?c))", 3},
+		{"FILTER(?a > 1) FILTER(?b < 2)", 2},
+		{"FILTER(?a > 1 || ?b < 2)", 1},
+		{"FILTER(true)", 0},
+		{"FILTER(1 < 2 && ?a)", 1},
+		{"FILTER(false)", 1},
+		{"FILTER(RAND() < 2)", 1},
+		{`FILTER(STRLEN("abc") = 3)`, 0},
+	}
+
+	o := NewOptimizer(&Statistics{})
+	for _, tt := range tests {
+		t.Run(tt.filters, func(t *testing.T) {
+			if filters := o.groupFilters(parseGroup(t, "?a ?b ?c "+tt.filters)); len(filters) != tt.count {
+				t.Errorf("expected %d filters, got %d", tt.count, len(filters))
+			}
+		})
+	}
+}
+
+// filterInputs returns the plan nodes a filter was placed directly above
+func filterInputs(plan QueryPlan, filter *parser.Filter) []QueryPlan {
+	var inputs []QueryPlan
+	var visit func(plan QueryPlan)
+	visit = func(plan QueryPlan) {
+		switch p := plan.(type) {
+		case *FilterPlan:
+			if p.Filter == filter {
+				inputs = append(inputs, p.Input)
+			}
+			visit(p.Input)
+		case *JoinPlan:
+			visit(p.Left)
+			visit(p.Right)
+		case *UnionPlan:
+			visit(p.Left)
+			visit(p.Right)
+		case *OptionalPlan:
+			visit(p.Left)
+			visit(p.Right)
+		case *BindPlan:
+			visit(p.Input)
+		}
+	}
+	visit(plan)
+	return inputs
+}
+
+func TestPushFilter(t *testing.T) {
+	union := func() QueryPlan {
+		return &UnionPlan{Left: scan("?s", "p", "?o"), Right: scan("?s", "q", "?x")}
+	}
+	join := func() QueryPlan {
+		return &JoinPlan{Left: scan("?s", "p", "?o"), Right: scan("?s", "q", "?x"), Type: JoinTypeHashJoin}
+	}
+	optional := func() QueryPlan {
+		return &OptionalPlan{Left: scan("?s", "p", "?o"), Right: scan("?s", "q", "?x"), Type: JoinTypeHashJoin}
+	}
+
+	tests := []struct {
+		name   string
+		plan   QueryPlan
+		filter string
+		inputs []string // where the filter ends up, "top" for above the whole plan
+	}{
+		{"into both union branches", union(), "?s != ex:a", []string{"scan p", "scan q"}},
+		{"into union branches not binding the variable", union(), "!bound(?o)", []string{"scan p", "scan q"}},
+		{"into the join side binding the variables", join(), "?o > 1", []string{"scan p"}},
+		{"into the right join side", join(), "?x > 1", []string{"scan q"}},
+		{"above a join for variables of both sides", join(), "?o = ?x", []string{"top"}},
+		{"into the left side of an optional", optional(), "?o > 1", []string{"scan p"}},
+		{"not below an optional for its variables", optional(), "?x > 1", []string{"top"}},
+		{"!bound not below an optional", optional(), "!bound(?x)", []string{"top"}},
+		{"not below the bind of a computed variable", &BindPlan{
+			Input:      scan("?s", "p", "?o"),
+			Expression: parseFilter(t, "?o + 1").Expression,
+			Variable:   &parser.Variable{Name: "b"},
+		}, "?b > 1", []string{"top"}},
+		{"EXISTS stays above", join(), "EXISTS { ?s ex:r ?o }", []string{"top"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := parseFilter(t, tt.filter)
+			before := tt.plan
+			after := pushFilter(before, filter)
+
+			var inputs []string
+			for _, input := range filterInputs(after, filter) {
+				switch p := input.(type) {
+				case *ScanPlan:
+					iri := p.Pattern.Predicate.Term.(*rdf.NamedNode).IRI
+					inputs = append(inputs, "scan "+strings.TrimPrefix(iri, "http://example.org/"))
+				default:
+					if p == before {
+						inputs = append(inputs, "top")
+					} else {
+						inputs = append(inputs, "other")
+					}
+				}
+			}
+			if len(inputs) != len(tt.inputs) {
+				t.Fatalf("expected the filter above %v, got %v", tt.inputs, inputs)
+			}
+			for i := range inputs {
+				if inputs[i] != tt.inputs[i] {
+					t.Errorf("expected the filter above %v, got %v", tt.inputs, inputs)
+				}
+			}
+		})
+	}
+}
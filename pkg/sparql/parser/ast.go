@@ -67,13 +67,14 @@ type GraphPattern struct {
 	Elements []PatternElement
 }
 
-// PatternElement represents an element in a graph pattern (triple, BIND, or FILTER).
+// PatternElement represents an element in a graph pattern (triple, BIND, FILTER or nested group).
 // Used to preserve the ordering of elements as they appear in the query text,
-// which is necessary for correct BIND variable scoping.
+// which is necessary for correct BIND variable scoping and OPTIONAL/MINUS semantics.
 type PatternElement struct {
 	Triple *TriplePattern
 	Bind   *Bind
 	Filter *Filter
+	Group  *GraphPattern // GRAPH, OPTIONAL, MINUS, UNION or nested group, also listed in Children
 }
 
 // GraphPatternType represents the type of graph pattern
@@ -98,6 +99,9 @@ type TriplePattern struct {
 type TermOrVariable struct {
 	Term     rdf.Term
 	Variable *Variable
+	// TripleTerm is set for RDF 1.2 triple term patterns <<( s p o )>> containing variables
+	// (ground triple terms are parsed directly into Term)
+	TripleTerm *TriplePattern
 }
 
 // IsVariable returns true if this is a variable
@@ -105,6 +109,11 @@ func (t *TermOrVariable) IsVariable() bool {
 	return t.Variable != nil
 }
 
+// IsTripleTerm returns true if this is a triple term pattern containing variables
+func (t *TermOrVariable) IsTripleTerm() bool {
+	return t.TripleTerm != nil
+}
+
 // Variable represents a SPARQL variable
 type Variable struct {
 	Name string
@@ -16,6 +16,11 @@ type Parser struct {
 	length   int
 	prefixes map[string]string // Maps prefix to IRI
 	baseURI  string            // Base URI for resolving relative IRIs
+
+	// RDF 1.2 reification: rdf:reifies patterns produced by reified triples and
+	// annotations, flushed into the enclosing triples block
+	extraPatterns []*TriplePattern
+	reifierCount  int
 }
 
 // NewParser creates a new SPARQL parser
@@ -29,8 +34,17 @@ func NewParser(input string) *Parser {
 	}
 }
 
-// Parse parses a SPARQL query
+// Parse parses a SPARQL query, returning an *rdf.ParseError for syntax errors
 func (p *Parser) Parse() (*Query, error) {
+	query, err := p.parseQuery()
+	if err != nil {
+		return nil, rdf.NewParseError(p.input, p.pos, err)
+	}
+	return query, nil
+}
+
+// parseQuery parses the prologue and the query form
+func (p *Parser) parseQuery() (*Query, error) {
 	p.skipWhitespace()
 
 	// Skip PREFIX and BASE declarations
@@ -431,6 +445,7 @@ func (p *Parser) parseGraphPattern() (*GraphPattern, error) {
 				pattern.Children = []*GraphPattern{}
 			}
 			pattern.Children = append(pattern.Children, graphPattern)
+			pattern.Elements = append(pattern.Elements, PatternElement{Group: graphPattern})
 			// Skip optional '.' separator after GRAPH block
 			p.skipWhitespace()
 			if p.peek() == '.' {
@@ -482,6 +497,7 @@ func (p *Parser) parseGraphPattern() (*GraphPattern, error) {
 				pattern.Children = []*GraphPattern{}
 			}
 			pattern.Children = append(pattern.Children, optionalPattern)
+			pattern.Elements = append(pattern.Elements, PatternElement{Group: optionalPattern})
 			// Skip optional '.' separator after OPTIONAL block
 			p.skipWhitespace()
 			if p.peek() == '.' {
@@ -501,6 +517,7 @@ func (p *Parser) parseGraphPattern() (*GraphPattern, error) {
 				pattern.Children = []*GraphPattern{}
 			}
 			pattern.Children = append(pattern.Children, minusPattern)
+			pattern.Elements = append(pattern.Elements, PatternElement{Group: minusPattern})
 			// Skip optional '.' separator after MINUS block
 			p.skipWhitespace()
 			if p.peek() == '.' {
@@ -551,11 +568,12 @@ func (p *Parser) parseGraphPattern() (*GraphPattern, error) {
 			if pattern.Children == nil {
 				pattern.Children = []*GraphPattern{}
 			}
-			pattern.Children = append(pattern.Children, nestedPattern)
 
 			// Check for UNION after the nested pattern
+			// A UNION B UNION C is left-associative: (A UNION B) UNION C
+			group := nestedPattern
 			p.skipWhitespace()
-			if p.matchKeyword("UNION") {
+			for p.matchKeyword("UNION") {
 				// Parse the right side of UNION
 				rightPattern, err := p.parseGraphPattern()
 				if err != nil {
@@ -563,14 +581,14 @@ func (p *Parser) parseGraphPattern() (*GraphPattern, error) {
 				}
 
 				// Create a UNION pattern containing both sides
-				unionPattern := &GraphPattern{
+				group = &GraphPattern{
 					Type:     GraphPatternTypeUnion,
-					Children: []*GraphPattern{nestedPattern, rightPattern},
+					Children: []*GraphPattern{group, rightPattern},
 				}
-
-				// Replace the last child with the union pattern
-				pattern.Children[len(pattern.Children)-1] = unionPattern
+				p.skipWhitespace()
 			}
+			pattern.Children = append(pattern.Children, group)
+			pattern.Elements = append(pattern.Elements, PatternElement{Group: group})
 			continue
 		}
 
@@ -632,114 +650,252 @@ func (p *Parser) parseGraphGraphPattern() (*GraphPattern, error) {
 		Graph:    graphTerm,
 		Patterns: nestedPattern.Patterns,
 		Filters:  nestedPattern.Filters,
+		Binds:    nestedPattern.Binds,
 		Children: nestedPattern.Children,
+		Elements: nestedPattern.Elements,
 	}
 
 	return graphPattern, nil
 }
 
-// parseTriplePattern parses a single triple pattern
-func (p *Parser) parseTriplePattern() (*TriplePattern, error) {
-	p.skipWhitespace()
+// parseTriplePatterns parses triple patterns with property list shorthand (semicolon and comma)
+// Syntax:
+//
+//	?s ?p1 ?o1 ; ?p2 ?o2 ; ?p3 ?o3 .  (semicolon repeats subject)
+//	?s ?p ?o1 , ?o2 , ?o3 .           (comma repeats subject and predicate)
+//	?s ?p ?o ~ ?r {| ?p2 ?o2 |} .     (RDF 1.2 reifier and annotation block)
+func (p *Parser) parseTriplePatterns() ([]*TriplePattern, error) {
+	// Reified triples anywhere in this block add rdf:reifies patterns
+	savedExtra := p.extraPatterns
+	p.extraPatterns = nil
+	defer func() { p.extraPatterns = savedExtra }()
 
+	p.skipWhitespace()
 	subject, err := p.parseTermOrVariable()
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse subject: %w", err)
 	}
 
-	p.skipWhitespace()
-	predicate, err := p.parseTermOrVariable()
+	triples, err := p.parsePropertyList(*subject)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse predicate: %w", err)
-	}
-
-	p.skipWhitespace()
-	object, err := p.parseTermOrVariable()
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse object: %w", err)
+		return nil, err
 	}
 
-	return &TriplePattern{
-		Subject:   *subject,
-		Predicate: *predicate,
-		Object:    *object,
-	}, nil
+	return append(triples, p.extraPatterns...), nil
 }
 
-// parseTriplePatterns parses triple patterns with property list shorthand (semicolon and comma)
-// Syntax:
-//
-//	?s ?p1 ?o1 ; ?p2 ?o2 ; ?p3 ?o3 .  (semicolon repeats subject)
-//	?s ?p ?o1 , ?o2 , ?o3 .           (comma repeats subject and predicate)
-func (p *Parser) parseTriplePatterns() ([]*TriplePattern, error) {
+// parsePropertyList parses predicate-object lists for a subject, including annotations
+func (p *Parser) parsePropertyList(subject TermOrVariable) ([]*TriplePattern, error) {
 	var triples []*TriplePattern
 
-	// Parse first triple
-	firstTriple, err := p.parseTriplePattern()
-	if err != nil {
-		return nil, err
-	}
-	triples = append(triples, firstTriple)
-
-	// Handle property list shorthand
 	for {
 		p.skipWhitespace()
-		ch := p.peek()
+		predicate, err := p.parseTermOrVariable()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse predicate: %w", err)
+		}
 
-		if ch == ',' {
-			// Comma: same subject and predicate, new object
-			p.advance() // skip ','
+		// Object list: comma repeats subject and predicate
+		for {
 			p.skipWhitespace()
-
 			object, err := p.parseTermOrVariable()
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse object after comma: %w", err)
+				return nil, fmt.Errorf("failed to parse object: %w", err)
 			}
 
-			triples = append(triples, &TriplePattern{
-				Subject:   firstTriple.Subject,
-				Predicate: firstTriple.Predicate,
+			triple := &TriplePattern{
+				Subject:   subject,
+				Predicate: *predicate,
 				Object:    *object,
-			})
+			}
+			triples = append(triples, triple)
 
-		} else if ch == ';' {
-			// Semicolon: same subject, new predicate and object
-			p.advance() // skip ';'
-			p.skipWhitespace()
+			annotations, err := p.parseAnnotations(triple)
+			if err != nil {
+				return nil, err
+			}
+			triples = append(triples, annotations...)
 
-			// Check for end of pattern (semicolon can be trailing)
-			if p.peek() == '.' || p.peek() == '}' {
+			p.skipWhitespace()
+			if p.peek() != ',' {
 				break
 			}
+			p.advance() // skip ','
+		}
+
+		// Semicolon: same subject, new predicate and object
+		p.skipWhitespace()
+		if p.peek() != ';' {
+			break
+		}
+		for p.peek() == ';' {
+			p.advance() // skip ';'
+			p.skipWhitespace()
+		}
+
+		// Check for end of pattern (semicolon can be trailing)
+		if p.peek() == '.' || p.peek() == '}' || p.peek() == '|' {
+			break
+		}
+	}
+
+	return triples, nil
+}
+
+// parseAnnotations parses reifiers (~ r) and annotation blocks ({| ... |}) following an object
+func (p *Parser) parseAnnotations(triple *TriplePattern) ([]*TriplePattern, error) {
+	var triples []*TriplePattern
+	var reifier *TermOrVariable
 
-			predicate, err := p.parseTermOrVariable()
+	for {
+		p.skipWhitespace()
+
+		if p.peek() == '~' {
+			p.advance() // skip '~'
+			r, err := p.parseReifier()
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse predicate after semicolon: %w", err)
+				return nil, err
 			}
+			reifier = r
+			p.addReifies(*reifier, triple.Subject, triple.Predicate, triple.Object)
+			continue
+		}
 
-			p.skipWhitespace()
-			object, err := p.parseTermOrVariable()
+		if p.match("{|") {
+			// An annotation block without a directly preceding reifier gets a fresh one
+			if reifier == nil {
+				reifier = p.newReifier()
+				p.addReifies(*reifier, triple.Subject, triple.Predicate, triple.Object)
+			}
+
+			annotations, err := p.parsePropertyList(*reifier)
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse object after semicolon: %w", err)
+				return nil, fmt.Errorf("failed to parse annotation block: %w", err)
 			}
+			triples = append(triples, annotations...)
 
-			triple := &TriplePattern{
-				Subject:   firstTriple.Subject,
-				Predicate: *predicate,
-				Object:    *object,
+			p.skipWhitespace()
+			if !p.match("|}") {
+				return nil, fmt.Errorf("expected '|}' to close annotation block")
 			}
-			triples = append(triples, triple)
+			reifier = nil
+			continue
+		}
 
-			// Update firstTriple to allow comma after this predicate-object pair
-			firstTriple = triple
+		return triples, nil
+	}
+}
 
-		} else {
-			// No more comma or semicolon, done
-			break
+// parseReifier parses the optional reifier after '~', generating one if omitted
+func (p *Parser) parseReifier() (*TermOrVariable, error) {
+	p.skipWhitespace()
+	switch p.peek() {
+	case '?', '$', '<', '_', ':':
+	default:
+		if ch := p.peek(); !(ch >= 'a' && ch <= 'z') && !(ch >= 'A' && ch <= 'Z') {
+			return p.newReifier(), nil
 		}
 	}
 
-	return triples, nil
+	reifier, err := p.parseTermOrVariable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse reifier: %w", err)
+	}
+	if reifier.Term != nil {
+		switch reifier.Term.(type) {
+		case *rdf.NamedNode, *rdf.BlankNode:
+		default:
+			return nil, fmt.Errorf("reifier must be an IRI, blank node or variable")
+		}
+	}
+	return reifier, nil
+}
+
+// newReifier creates an anonymous reifier, which behaves like a blank node in patterns
+func (p *Parser) newReifier() *TermOrVariable {
+	name := fmt.Sprintf("_:r%d", p.reifierCount)
+	p.reifierCount++
+	return &TermOrVariable{Variable: &Variable{Name: name}}
+}
+
+// addReifies records a "reifier rdf:reifies <<( s p o )>>" pattern
+func (p *Parser) addReifies(reifier, subject, predicate, object TermOrVariable) {
+	p.extraPatterns = append(p.extraPatterns, &TriplePattern{
+		Subject:   reifier,
+		Predicate: TermOrVariable{Term: rdf.RDFReifies},
+		Object:    *newTripleTerm(subject, predicate, object),
+	})
+}
+
+// newTripleTerm builds a triple term, ground if none of its components contain variables
+func newTripleTerm(subject, predicate, object TermOrVariable) *TermOrVariable {
+	if subject.Term != nil && predicate.Term != nil && object.Term != nil {
+		return &TermOrVariable{Term: &rdf.TripleTerm{
+			Subject:   subject.Term,
+			Predicate: predicate.Term,
+			Object:    object.Term,
+		}}
+	}
+	return &TermOrVariable{TripleTerm: &TriplePattern{
+		Subject:   subject,
+		Predicate: predicate,
+		Object:    object,
+	}}
+}
+
+// parseTripleTermOrReifiedTriple parses <<( s p o )>> or << s p o ~ r >>
+// A reified triple stands for its reifier and adds an rdf:reifies pattern
+func (p *Parser) parseTripleTermOrReifiedTriple() (*TermOrVariable, error) {
+	if !p.match("<<") {
+		return nil, fmt.Errorf("expected '<<'")
+	}
+	p.skipWhitespace()
+
+	isTripleTerm := p.peek() == '('
+	if isTripleTerm {
+		p.advance() // skip '('
+	}
+
+	var components [3]*TermOrVariable
+	for i, name := range []string{"subject", "predicate", "object"} {
+		p.skipWhitespace()
+		component, err := p.parseTermOrVariable()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse triple term %s: %w", name, err)
+		}
+		components[i] = component
+	}
+
+	p.skipWhitespace()
+	if isTripleTerm {
+		if !p.match(")") {
+			return nil, fmt.Errorf("expected ')' to close triple term")
+		}
+		p.skipWhitespace()
+		if !p.match(">>") {
+			return nil, fmt.Errorf("expected '>>' to close triple term")
+		}
+		return newTripleTerm(*components[0], *components[1], *components[2]), nil
+	}
+
+	var reifier *TermOrVariable
+	if p.peek() == '~' {
+		p.advance() // skip '~'
+		r, err := p.parseReifier()
+		if err != nil {
+			return nil, err
+		}
+		reifier = r
+		p.skipWhitespace()
+	} else {
+		reifier = p.newReifier()
+	}
+	if !p.match(">>") {
+		return nil, fmt.Errorf("expected '>>' to close reified triple")
+	}
+
+	p.addReifies(*reifier, *components[0], *components[1], *components[2])
+	return reifier, nil
 }
 
 // parseTermOrVariable parses either an RDF term or a variable
@@ -757,6 +913,11 @@ func (p *Parser) parseTermOrVariable() (*TermOrVariable, error) {
 		return &TermOrVariable{Variable: variable}, nil
 	}
 
+	// RDF 1.2 triple term or reified triple
+	if ch == '<' && p.pos+1 < p.length && p.input[p.pos+1] == '<' {
+		return p.parseTripleTermOrReifiedTriple()
+	}
+
 	// IRI (named node)
 	if ch == '<' {
 		iri, err := p.parseIRI()
@@ -1277,14 +1438,28 @@ func (p *Parser) parseOrderBy() ([]*OrderCondition, error) {
 		p.skipWhitespace()
 
 		ascending := true
+		direction := ""
 		if p.matchKeyword("DESC") {
 			ascending = false
+			direction = "DESC"
 		} else if p.matchKeyword("ASC") {
 			ascending = true
+			direction = "ASC"
 		}
 
 		p.skipWhitespace()
+		start := p.pos
+		parenthesized := p.peek() == '('
+		if parenthesized {
+			// ASC(?var) / DESC(?var)
+			p.advance()
+			p.skipWhitespace()
+		}
 		if p.peek() != '?' && p.peek() != '$' {
+			if direction != "" {
+				return nil, fmt.Errorf("expected variable after %s in ORDER BY", direction)
+			}
+			p.pos = start
 			break
 		}
 
@@ -1292,20 +1467,24 @@ func (p *Parser) parseOrderBy() ([]*OrderCondition, error) {
 		if err != nil {
 			return nil, err
 		}
+		if parenthesized {
+			p.skipWhitespace()
+			if p.peek() != ')' {
+				return nil, fmt.Errorf("expected ')' after ORDER BY variable")
+			}
+			p.advance()
+		}
 
 		conditions = append(conditions, &OrderCondition{
 			Expression: &VariableExpression{Variable: variable},
 			Ascending:  ascending,
 		})
-
-		// Check if there are more conditions
-		p.skipWhitespace()
-		if !p.matchKeyword("LIMIT") && !p.matchKeyword("OFFSET") && p.pos < p.length {
-			continue
-		}
-		break
+		// The loop ends at the first token that is not another condition, such as LIMIT or OFFSET
 	}
 
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("expected at least one condition in ORDER BY")
+	}
 	return conditions, nil
 }
 
@@ -1903,10 +2082,19 @@ func (p *Parser) parsePrimaryExpression() (Expression, error) {
 	}
 
 	// Check for literals - parse using parseTermOrVariable
+	extraCount := len(p.extraPatterns)
 	termOrVar, err := p.parseTermOrVariable()
 	if err != nil {
 		return nil, fmt.Errorf("expected expression: %w", err)
 	}
+	if len(p.extraPatterns) != extraCount {
+		return nil, fmt.Errorf("reified triples are not allowed in expressions, use <<( s p o )>>")
+	}
+
+	// Triple term patterns with variables are built per solution by TRIPLE()
+	if termOrVar.TripleTerm != nil {
+		return tripleTermExpression(termOrVar.TripleTerm), nil
+	}
 
 	// If it's a variable, we shouldn't get here (handled above)
 	// If it's a term, wrap it in a LiteralExpression
@@ -1922,6 +2110,22 @@ func (p *Parser) parsePrimaryExpression() (Expression, error) {
 	return nil, fmt.Errorf("failed to parse expression term")
 }
 
+// tripleTermExpression converts a triple term pattern into an equivalent TRIPLE(s, p, o) call
+func tripleTermExpression(pattern *TriplePattern) Expression {
+	var args []Expression
+	for _, component := range []TermOrVariable{pattern.Subject, pattern.Predicate, pattern.Object} {
+		switch {
+		case component.Variable != nil:
+			args = append(args, &VariableExpression{Variable: component.Variable})
+		case component.TripleTerm != nil:
+			args = append(args, tripleTermExpression(component.TripleTerm))
+		default:
+			args = append(args, &LiteralExpression{Literal: component.Term})
+		}
+	}
+	return &FunctionCallExpression{Function: "TRIPLE", Arguments: args}
+}
+
 // parseFunctionCall parses a function call expression
 func (p *Parser) parseFunctionCall() (Expression, error) {
 	p.skipWhitespace()
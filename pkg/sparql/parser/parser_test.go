@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	tests := []struct {
+		name      string
+		modifiers string
+		order     string // variable names, with "-" before descending ones
+		limit     int    // -1 for no LIMIT
+		offset    int    // -1 for no OFFSET
+	}{
+		{"bare variable", "ORDER BY ?x", "x", -1, -1},
+		{"ascending", "ORDER BY ASC(?x)", "x", -1, -1},
+		{"descending", "ORDER BY DESC(?x)", "-x", -1, -1},
+		{"lower case", "order by desc(?x) asc(?y)", "-x y", -1, -1},
+		{"spaces inside parentheses", "ORDER BY DESC( ?x ) ASC(\t?y\n)", "-x y", -1, -1},
+		{"several conditions", "ORDER BY ?x DESC(?y) ASC(?z)", "x -y z", -1, -1},
+		{"dollar variable", "ORDER BY DESC($x)", "-x", -1, -1},
+		{"then LIMIT", "ORDER BY DESC(?x) LIMIT 5", "-x", 5, -1},
+		{"then OFFSET", "ORDER BY ASC(?x) OFFSET 3", "x", -1, 3},
+		{"then LIMIT and OFFSET", "ORDER BY ?y DESC(?x) LIMIT 10 OFFSET 20", "y -x", 10, 20},
+		{"LIMIT right after a variable", "ORDER BY ?x LIMIT 1", "x", 1, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := NewParser("SELECT ?x ?y ?z WHERE { ?x ?y ?z } " + tt.modifiers).Parse()
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			conditions := make([]string, len(query.Select.OrderBy))
+			for i, condition := range query.Select.OrderBy {
+				variable, ok := condition.Expression.(*VariableExpression)
+				if !ok {
+					t.Fatalf("expected a variable condition, got %T", condition.Expression)
+				}
+				conditions[i] = variable.Variable.Name
+				if !condition.Ascending {
+					conditions[i] = "-" + conditions[i]
+				}
+			}
+			if order := strings.Join(conditions, " "); order != tt.order {
+				t.Errorf("expected ORDER BY %q, got %q", tt.order, order)
+			}
+			if got := intOrNone(query.Select.Limit); got != tt.limit {
+				t.Errorf("expected LIMIT %d, got %d", tt.limit, got)
+			}
+			if got := intOrNone(query.Select.Offset); got != tt.offset {
+				t.Errorf("expected OFFSET %d, got %d", tt.offset, got)
+			}
+		})
+	}
+}
+
+func TestParseOrderByErrors(t *testing.T) {
+	for _, modifiers := range []string{
+		"ORDER BY",
+		"ORDER BY LIMIT 5",
+		"ORDER BY DESC(?x LIMIT 5",
+		"ORDER BY DESC LIMIT 5",
+		"ORDER BY ?x ASC",
+		"ORDER ?x",
+	} {
+		t.Run(modifiers, func(t *testing.T) {
+			if _, err := NewParser("SELECT ?x WHERE { ?x ?y ?z } " + modifiers).Parse(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func intOrNone(value *int) int {
+	if value == nil {
+		return -1
+	}
+	return *value
+}
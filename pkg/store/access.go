@@ -0,0 +1,98 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+)
+
+// ErrAccessDenied is returned when writing to a graph the caller cannot write
+var ErrAccessDenied = errors.New("access denied")
+
+// GraphAuthorizer decides which graphs a caller may read and write
+// The graph is rdf.DefaultGraph for the default graph
+type GraphAuthorizer interface {
+	CanRead(graph rdf.Term) bool
+	CanWrite(graph rdf.Term) bool
+}
+
+// WithAuthorizer returns a view of the store restricted by an authorizer.
+// Queries on the view never return quads from graphs the caller cannot read,
+// and writes to graphs the caller cannot write fail with ErrAccessDenied.
+// The view shares storage with the original store.
+func (s *TripleStore) WithAuthorizer(authorizer GraphAuthorizer) *TripleStore {
+	view := *s
+	view.authorizer = authorizer
+	return &view
+}
+
+// CanReadAll reports whether the caller may read every graph of the store, the default graph
+// included. Only such callers may see what is derived from all graphs, such as the statistics
+// collected by Analyze.
+func (s *TripleStore) CanReadAll() (bool, error) {
+	if s.authorizer == nil {
+		return true, nil
+	}
+	if !s.authorizer.CanRead(rdf.NewDefaultGraph()) {
+		return false, nil
+	}
+
+	txn, err := s.storage.Begin(false)
+	if err != nil {
+		return false, err
+	}
+	defer txn.Rollback()
+
+	it, err := txn.Scan(TableGraphs, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer it.Close()
+
+	graphs := newGraphReadCache(s, txn)
+	for it.Next() {
+		var graph EncodedTerm
+		copy(graph[:], it.Key())
+		if !graphs.canRead(graph) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// canRead checks read access to a graph, allowing everything without an authorizer
+func (s *TripleStore) canRead(graph rdf.Term) bool {
+	return s.authorizer == nil || s.authorizer.CanRead(graph)
+}
+
+// checkWrite checks write access to a graph
+func (s *TripleStore) checkWrite(graph rdf.Term) error {
+	if s.authorizer != nil && !s.authorizer.CanWrite(graph) {
+		return fmt.Errorf("%w: cannot write to graph %s", ErrAccessDenied, graph)
+	}
+	return nil
+}
+
+// graphReadCache caches read decisions per encoded graph while scanning
+type graphReadCache struct {
+	store     *TripleStore
+	txn       Transaction
+	decisions map[EncodedTerm]bool
+}
+
+func newGraphReadCache(s *TripleStore, txn Transaction) *graphReadCache {
+	return &graphReadCache{store: s, txn: txn, decisions: make(map[EncodedTerm]bool)}
+}
+
+// canRead checks read access to an encoded graph, decoding it at most once
+func (c *graphReadCache) canRead(graph EncodedTerm) bool {
+	if allowed, ok := c.decisions[graph]; ok {
+		return allowed
+	}
+
+	term, err := c.store.decodeTerm(c.txn, graph)
+	allowed := err == nil && c.store.canRead(term)
+	c.decisions[graph] = allowed
+	return allowed
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+)
+
+// ScanRange is a part of the keys scanned for a pattern, as returned by PartitionQuery.
+// Start and End are key suffixes after the pattern's bound prefix; nil leaves that side open.
+type ScanRange struct {
+	Start []byte
+	End   []byte
+}
+
+// PartitionQuery splits the keys a Query of the pattern reads into about n ranges,
+// to be scanned in parallel with QueryRange. The first unbound key position is split
+// by the term types present in the index, then each type evenly between its smallest
+// and largest encoded value, which spreads hashed terms and numbers alike.
+// Returns one open range if the scan can't be split.
+func (s *TripleStore) PartitionQuery(ctx context.Context, pattern *Pattern, n int) ([]ScanRange, error) {
+	whole := []ScanRange{{}}
+	if n < 2 || hasTripleTermPattern(pattern) {
+		return whole, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	table, keyPattern := selectIndex(pattern)
+	prefix, err := s.buildScanPrefix(pattern, keyPattern)
+	if err != nil {
+		return nil, err
+	}
+	const encodedTermSize = 17
+	if len(prefix) >= len(keyPattern)*encodedTermSize {
+		return whole, nil
+	}
+
+	txn, err := s.storage.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback() // #nosec G104 - read-only transaction
+
+	it, err := txn.Scan(table, prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close() // #nosec G104 - read-only iterator
+
+	// Seek from type to type to find the term types at the split position
+	var types []byte
+	target := append(append([]byte{}, prefix...), 0)
+	for it.Seek(target) {
+		key := it.Key()
+		if len(key) <= len(prefix) {
+			break
+		}
+		t := key[len(prefix)]
+		types = append(types, t)
+		if t == 0xff {
+			break
+		}
+		target[len(prefix)] = t + 1
+	}
+	if len(types) == 0 {
+		return whole, nil
+	}
+
+	perType := max(1, (n+len(types)-1)/len(types))
+	var bounds [][]byte
+	for _, t := range types {
+		bounds = append(bounds, []byte{t})
+		bounds = append(bounds, splitValues(it, append(append([]byte{}, prefix...), t), perType)...)
+	}
+
+	ranges := make([]ScanRange, len(bounds))
+	for i := range bounds {
+		if i > 0 {
+			ranges[i].Start = bounds[i]
+		}
+		if i+1 < len(bounds) {
+			ranges[i].End = bounds[i+1]
+		}
+	}
+	return ranges, nil
+}
+
+// splitValues returns up to n-1 key suffixes, after the bound prefix, that split the
+// encoded values under a prefix ending with a term type into n ranges of equal width.
+// The smallest and largest values are found by seeking, the largest byte by byte; the
+// ranges are spread over the 8 bytes from the first one where the two differ.
+func splitValues(it Iterator, typed []byte, n int) [][]byte {
+	const valueSize = 16 // encoded term bytes after the type
+	if n < 2 || !it.Seek(typed) || !bytes.HasPrefix(it.Key(), typed) || len(it.Key()) < len(typed)+valueSize {
+		return nil
+	}
+	smallest := append([]byte{}, it.Key()[len(typed):len(typed)+valueSize]...)
+
+	// The largest value byte at each position, given the bytes before it
+	largest := make([]byte, 0, valueSize)
+	differ := -1
+	for i := 0; i < valueSize && (differ < 0 || i < differ+8); i++ {
+		lo, hi := 0, 255
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			probe := append(append(append([]byte{}, typed...), largest...), byte(mid))
+			if it.Seek(probe) && bytes.HasPrefix(it.Key(), probe[:len(probe)-1]) {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		largest = append(largest, byte(lo))
+		if differ < 0 && largest[i] != smallest[i] {
+			differ = i
+		}
+	}
+	if differ < 0 {
+		return nil
+	}
+
+	// Both values as 8-byte numbers from the first byte where they differ
+	var lo, hi [8]byte
+	copy(lo[:], smallest[differ:])
+	copy(hi[:], largest[differ:])
+	from, to := binary.BigEndian.Uint64(lo[:]), binary.BigEndian.Uint64(hi[:])
+	step := (to - from) / uint64(n)
+	if step == 0 {
+		return nil
+	}
+
+	prefix := len(typed) - 1 // the type byte is part of the suffix
+	var bounds [][]byte
+	for i := 1; i < n; i++ {
+		var value [8]byte
+		binary.BigEndian.PutUint64(value[:], from+step*uint64(i))
+		bound := append(append([]byte{}, typed[prefix:]...), smallest[:differ]...)
+		bounds = append(bounds, append(bound, value[:min(8, valueSize-differ)]...))
+	}
+	return bounds
+}
+
+// QueryRange is Query restricted to one of the ranges returned by PartitionQuery for the pattern
+func (s *TripleStore) QueryRange(ctx context.Context, pattern *Pattern, r ScanRange) (QuadIterator, error) {
+	return s.query(ctx, pattern, r)
+}
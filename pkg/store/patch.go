@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+)
+
+// ErrPatchTooLarge is returned by ApplyPatch when the changes of a patch don't fit in one
+// storage transaction: with the default Badger settings, around 10,000 quads, fewer with long terms
+var ErrPatchTooLarge = errors.New("patch too large")
+
+// ApplyPatch applies the changes of an RDF Patch in a single transaction, so either all of
+// them are made or, on an error, none are. Rows of aborted patch transactions are skipped,
+// and prefix rows are ignored as the store keeps no prefixes. A patch whose transaction rows
+// aren't properly paired is rejected with an error wrapping ErrInvalidData, and one too large
+// for a single transaction with ErrPatchTooLarge; split it into several patches.
+func (s *TripleStore) ApplyPatch(patch *rdf.Patch) error {
+	changes, err := patch.Changes()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidData, err)
+	}
+
+	txn, err := s.storage.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	for i, row := range changes {
+		var err error
+		switch row.Operation {
+		case rdf.PatchAdd:
+			if err = s.insertQuadInTxn(txn, row.Quad); err != nil {
+				err = fmt.Errorf("failed to add quad: %w", err)
+			}
+		case rdf.PatchDelete:
+			if err = s.deleteQuadInTxn(txn, row.Quad); err != nil {
+				err = fmt.Errorf("failed to delete quad: %w", err)
+			}
+		}
+		if errors.Is(err, ErrTxnTooBig) {
+			return fmt.Errorf("%w: the storage transaction filled up after %d of %d changes: %w",
+				ErrPatchTooLarge, i, len(changes), err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return txn.Commit()
+}
+
+// DiffGraphs returns the patch that turns the triples of one graph of the store into those of
+// another, as changes to the first graph. A nil graph is the default graph.
+func (s *TripleStore) DiffGraphs(ctx context.Context, from, to rdf.Term) (*rdf.Patch, error) {
+	if from == nil {
+		from = rdf.NewDefaultGraph()
+	}
+	fromQuads, err := s.graphQuads(ctx, from, from)
+	if err != nil {
+		return nil, err
+	}
+	// The quads of the other graph are compared as if they were in the first
+	toQuads, err := s.graphQuads(ctx, to, from)
+	if err != nil {
+		return nil, err
+	}
+	return rdf.DiffQuads(fromQuads, toQuads), nil
+}
+
+// graphQuads reads the triples of a graph as quads in another graph
+func (s *TripleStore) graphQuads(ctx context.Context, graph, as rdf.Term) ([]*rdf.Quad, error) {
+	pattern := &Pattern{
+		Subject:   NewVariable("s"),
+		Predicate: NewVariable("p"),
+		Object:    NewVariable("o"),
+	}
+	if graph != nil && graph.Type() != rdf.TermTypeDefaultGraph {
+		pattern.Graph = graph
+	}
+
+	iter, err := s.Query(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var quads []*rdf.Quad
+	for iter.Next() {
+		quad, err := iter.Quad()
+		if err != nil {
+			return nil, err
+		}
+		quads = append(quads, rdf.NewQuad(quad.Subject, quad.Predicate, quad.Object, as))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return quads, nil
+}
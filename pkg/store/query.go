@@ -1,7 +1,10 @@
 package store
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"sync"
 
 	"github.com/aleksaelezovic/trigo/pkg/rdf"
 )
@@ -28,10 +31,46 @@ func (v *Variable) String() string {
 	return "?" + v.Name
 }
 
+// TripleTermPattern matches RDF 1.2 triple terms whose components match the
+// given subject, predicate and object (each an rdf.Term, Variable or nested TripleTermPattern)
+type TripleTermPattern struct {
+	Subject   any
+	Predicate any
+	Object    any
+}
+
+// Matches reports whether a term is a triple term matching the pattern's constant components
+func (tp *TripleTermPattern) Matches(term rdf.Term) bool {
+	tt, ok := term.(*rdf.TripleTerm)
+	if !ok {
+		return false
+	}
+	return matchesPatternTerm(tp.Subject, tt.Subject) &&
+		matchesPatternTerm(tp.Predicate, tt.Predicate) &&
+		matchesPatternTerm(tp.Object, tt.Object)
+}
+
+// matchesPatternTerm checks a decoded term against a pattern position
+func matchesPatternTerm(pattern any, term rdf.Term) bool {
+	switch p := pattern.(type) {
+	case *Variable:
+		return true
+	case *TripleTermPattern:
+		return p.Matches(term)
+	case rdf.Term:
+		return p.Equals(term)
+	default:
+		return false
+	}
+}
+
 // Binding represents a variable binding
+// Variables bound by scans hold the encoded term from the index and are only decoded
+// when their value is needed, see Term and Materialize. Vars holds the decoded values.
 type Binding struct {
 	Vars   map[string]rdf.Term
 	values map[string]EncodedTerm // internal encoded values
+	terms  *TermCache             // decodes the encoded values
 }
 
 // NewBinding creates a new empty binding
@@ -51,9 +90,244 @@ func (b *Binding) Clone() *Binding {
 	for k, v := range b.values {
 		newBinding.values[k] = v
 	}
+	newBinding.terms = b.terms
 	return newBinding
 }
 
+// Set binds a variable to a term
+func (b *Binding) Set(name string, term rdf.Term) {
+	b.Vars[name] = term
+	delete(b.values, name)
+}
+
+// SetEncoded binds a variable to a stored term, decoded by terms when its value is needed
+func (b *Binding) SetEncoded(name string, encoded EncodedTerm, terms *TermCache) {
+	b.values[name] = encoded
+	delete(b.Vars, name)
+	b.terms = terms
+}
+
+// Bound reports whether a variable is bound
+func (b *Binding) Bound(name string) bool {
+	if _, ok := b.values[name]; ok {
+		return true
+	}
+	_, ok := b.Vars[name]
+	return ok
+}
+
+// Names returns the bound variables, in no particular order
+func (b *Binding) Names() []string {
+	names := make([]string, 0, len(b.values)+len(b.Vars))
+	for name := range b.values {
+		names = append(names, name)
+	}
+	for name := range b.Vars {
+		if _, ok := b.values[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Len returns the number of bound variables
+func (b *Binding) Len() int {
+	n := len(b.values)
+	for name := range b.Vars {
+		if _, ok := b.values[name]; !ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Encoded returns the encoded term of a variable bound by a scan
+func (b *Binding) Encoded(name string) (EncodedTerm, bool) {
+	encoded, ok := b.values[name]
+	return encoded, ok
+}
+
+// Term returns the value of a variable, decoding it if it is still encoded.
+// A term that fails to decode is reported as unbound.
+func (b *Binding) Term(name string) (rdf.Term, bool) {
+	if term, ok := b.Vars[name]; ok {
+		return term, true
+	}
+	encoded, ok := b.values[name]
+	if !ok || b.terms == nil {
+		return nil, false
+	}
+	term, err := b.terms.Decode(encoded)
+	if err != nil {
+		return nil, false
+	}
+	return term, true
+}
+
+// Decode decodes the value of a variable into Vars, so later lookups don't decode it again
+func (b *Binding) Decode(name string) (rdf.Term, bool) {
+	term, ok := b.Term(name)
+	if ok {
+		b.Vars[name] = term
+	}
+	return term, ok
+}
+
+// Materialize decodes all encoded values into Vars, for code that reads Vars directly
+func (b *Binding) Materialize() error {
+	for name, encoded := range b.values {
+		if _, ok := b.Vars[name]; ok {
+			continue
+		}
+		if b.terms == nil {
+			return fmt.Errorf("no decoder for variable ?%s", name)
+		}
+		term, err := b.terms.Decode(encoded)
+		if err != nil {
+			return err
+		}
+		b.Vars[name] = term
+	}
+	return nil
+}
+
+// SameTerm reports whether two bindings bind a variable to the same term.
+// Encoded values are compared without decoding them.
+func (b *Binding) SameTerm(name string, other *Binding) bool {
+	left, leftEncoded := b.values[name]
+	right, rightEncoded := other.values[name]
+	if leftEncoded && rightEncoded && b.terms == other.terms {
+		return left == right
+	}
+	leftTerm, ok := b.Term(name)
+	if !ok {
+		return false
+	}
+	rightTerm, ok := other.Term(name)
+	if !ok {
+		return false
+	}
+	return leftTerm.Equals(rightTerm)
+}
+
+// Merge returns the union of two bindings, nil if they bind a shared variable to different terms
+func (b *Binding) Merge(other *Binding) *Binding {
+	result := b.Clone()
+	if result.terms == nil {
+		result.terms = other.terms
+	}
+	for _, name := range other.Names() {
+		if b.Bound(name) {
+			if !b.SameTerm(name, other) {
+				return nil
+			}
+			continue
+		}
+		if encoded, ok := other.values[name]; ok && other.terms == result.terms {
+			result.values[name] = encoded
+			if term, ok := other.Vars[name]; ok {
+				result.Vars[name] = term
+			}
+			continue
+		}
+		if term, ok := other.Term(name); ok {
+			result.Vars[name] = term
+		}
+	}
+	return result
+}
+
+// Project returns a binding of only the given variables, still encoded where they were
+func (b *Binding) Project(names []string) *Binding {
+	result := NewBinding()
+	result.terms = b.terms
+	for _, name := range names {
+		if encoded, ok := b.values[name]; ok {
+			result.values[name] = encoded
+		}
+		if term, ok := b.Vars[name]; ok {
+			result.Vars[name] = term
+		}
+	}
+	return result
+}
+
+// TermCache decodes the encoded terms of one query's bindings, reading the
+// id2str table through a single read transaction and remembering recent terms
+type TermCache struct {
+	store *TripleStore
+
+	mu      sync.Mutex
+	txn     Transaction // opened on the first lookup
+	closed  bool
+	terms   map[EncodedTerm]rdf.Term
+	lookups int64
+}
+
+// termCacheSize bounds the number of decoded terms a TermCache keeps
+const termCacheSize = 1 << 16
+
+// NewTermCache creates a term cache for the bindings of a query, to be closed after it
+func (s *TripleStore) NewTermCache() *TermCache {
+	return &TermCache{
+		store: s,
+		terms: make(map[EncodedTerm]rdf.Term),
+	}
+}
+
+// Decode returns the term of an encoded ID
+func (c *TermCache) Decode(encoded EncodedTerm) (rdf.Term, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if term, ok := c.terms[encoded]; ok {
+		return term, nil
+	}
+	txn := c.txn
+	if txn == nil {
+		var err error
+		if txn, err = c.store.storage.Begin(false); err != nil {
+			return nil, err
+		}
+		if c.closed {
+			// Terms decoded after the query ended each get their own transaction
+			defer txn.Rollback() // #nosec G104 - read-only transaction
+		} else {
+			c.txn = txn
+		}
+	}
+	term, err := c.store.decodeTerm(txn, encoded)
+	if err != nil {
+		return nil, err
+	}
+	c.lookups++
+	if len(c.terms) >= termCacheSize {
+		clear(c.terms)
+	}
+	c.terms[encoded] = term
+	return term, nil
+}
+
+// Lookups returns the number of terms decoded from the store so far
+func (c *TermCache) Lookups() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lookups
+}
+
+// Close ends the read transaction of the cache
+func (c *TermCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.txn == nil {
+		return nil
+	}
+	txn := c.txn
+	c.txn = nil
+	return txn.Rollback()
+}
+
 // QuadIterator iterates over quads matching a pattern
 type QuadIterator interface {
 	Next() bool
@@ -61,6 +335,14 @@ type QuadIterator interface {
 	Close() error
 }
 
+// EncodedQuadIterator is a QuadIterator that can also return the current quad
+// as encoded terms, without decoding them
+type EncodedQuadIterator interface {
+	QuadIterator
+	// EncodedQuad returns the encoded subject, predicate, object and graph
+	EncodedQuad() ([4]EncodedTerm, error)
+}
+
 // BindingIterator iterates over variable bindings
 type BindingIterator interface {
 	Next() bool
@@ -69,14 +351,33 @@ type BindingIterator interface {
 }
 
 // Query executes a pattern match and returns matching quads
-func (s *TripleStore) Query(pattern *Pattern) (QuadIterator, error) {
+// A nil Graph matches the default graph, a Variable matches all named graphs.
+// The iterator stops early once ctx is done; callers check ctx.Err() to tell it from exhaustion.
+func (s *TripleStore) Query(ctx context.Context, pattern *Pattern) (QuadIterator, error) {
+	return s.query(ctx, pattern, ScanRange{})
+}
+
+// query scans the keys of a pattern's index within a range of its bound prefix
+func (s *TripleStore) query(ctx context.Context, pattern *Pattern, r ScanRange) (QuadIterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Nothing to scan in a bound graph the caller cannot read
+	if graph, ok := pattern.Graph.(rdf.Term); ok && !s.canRead(graph) {
+		return &emptyQuadIterator{}, nil
+	}
+	if pattern.Graph == nil && !s.canRead(rdf.NewDefaultGraph()) {
+		return &emptyQuadIterator{}, nil
+	}
+
 	txn, err := s.storage.Begin(false)
 	if err != nil {
 		return nil, err
 	}
 
 	// Select the best index based on bound positions
-	table, keyPattern := s.selectIndex(pattern)
+	table, keyPattern := selectIndex(pattern)
 
 	// Build the prefix for scanning
 	prefix, err := s.buildScanPrefix(pattern, keyPattern)
@@ -92,23 +393,93 @@ func (s *TripleStore) Query(pattern *Pattern) (QuadIterator, error) {
 		return nil, err
 	}
 
-	return &quadIterator{
+	qi := &quadIterator{
+		ctx:        ctx,
 		store:      s,
 		txn:        txn,
 		it:         it,
 		pattern:    pattern,
 		keyPattern: keyPattern,
-	}, nil
+		filter:     hasTripleTermPattern(pattern),
+		graphPos:   -1,
+	}
+	if r.Start != nil {
+		qi.start = append(append([]byte{}, prefix...), r.Start...)
+	}
+	if r.End != nil {
+		qi.end = append(append([]byte{}, prefix...), r.End...)
+	}
+
+	// Graph variables range over named graphs only, and each graph is checked for read access
+	if isVariable(pattern.Graph) {
+		for i, idx := range keyPattern {
+			if idx == 3 {
+				qi.graphPos = i
+			}
+		}
+		if s.authorizer != nil {
+			qi.graphs = newGraphReadCache(s, txn)
+		}
+	}
+
+	return qi, nil
+}
+
+// OrderedBy returns the variable a scan of the pattern is ordered by, in encoded term order
+// (see TripleStore.EncodeTerm), or "" if the results aren't ordered by a single variable
+func OrderedBy(pattern *Pattern) string {
+	_, keyPattern := selectIndex(pattern)
+	positions := []any{pattern.Subject, pattern.Predicate, pattern.Object, pattern.Graph}
+	if pattern.Graph == nil {
+		positions[3] = rdf.NewDefaultGraph()
+	}
+
+	// Keys sharing the bound prefix are sorted by the first position after it
+	for _, idx := range keyPattern {
+		switch v := positions[idx].(type) {
+		case *Variable:
+			return v.Name
+		case rdf.Term:
+			continue
+		default:
+			// Triple term patterns only fix the type byte of their position
+			return ""
+		}
+	}
+	return ""
 }
 
 // selectIndex chooses the best index based on which positions are bound
-func (s *TripleStore) selectIndex(pattern *Pattern) (Table, []int) {
-	sBound := !isVariable(pattern.Subject)
-	pBound := !isVariable(pattern.Predicate)
-	oBound := !isVariable(pattern.Object)
-	gBound := pattern.Graph != nil && !isVariable(pattern.Graph)
+func selectIndex(pattern *Pattern) (Table, []int) {
+	sBound := isBound(pattern.Subject)
+	pBound := isBound(pattern.Predicate)
+	oBound := isBound(pattern.Object)
+	gBound := pattern.Graph != nil && isBound(pattern.Graph)
+
+	// Graph variable: named graph indexes with the graph last
+	if isVariable(pattern.Graph) {
+		if sBound && pBound {
+			return TableSPOG, []int{0, 1, 2, 3} // Key order: S, P, O, G
+		}
+		if pBound && oBound {
+			return TablePOSG, []int{1, 2, 0, 3} // Key order: P, O, S, G
+		}
+		if oBound && sBound {
+			return TableOSPG, []int{2, 0, 1, 3} // Key order: O, S, P, G
+		}
+		if sBound {
+			return TableSPOG, []int{0, 1, 2, 3} // Key order: S, P, O, G
+		}
+		if pBound {
+			return TablePOSG, []int{1, 2, 0, 3} // Key order: P, O, S, G
+		}
+		if oBound {
+			return TableOSPG, []int{2, 0, 1, 3} // Key order: O, S, P, G
+		}
+		return TableGSPO, []int{3, 0, 1, 2} // Key order: G, S, P, O
+	}
 
-	// If graph is not specified or is a variable, prefer default graph indexes
+	// If graph is not specified, use default graph indexes
 	if !gBound {
 		// Default graph indexes (SPO, POS, OSP)
 		// KeyPattern maps: key_position -> SPOG_position (S=0, P=1, O=2, G=3)
@@ -187,6 +558,11 @@ func (s *TripleStore) buildScanPrefix(pattern *Pattern, keyPattern []int) ([]byt
 			// Stop at first variable
 			break
 		}
+		if _, ok := term.(*TripleTermPattern); ok {
+			// Triple term patterns narrow the scan to triple terms, components are filtered later
+			prefix = append(prefix, byte(rdf.TermTypeQuotedTriple))
+			break
+		}
 
 		// Encode the term
 		encoded, _, err := s.encoder.EncodeTerm(term.(rdf.Term))
@@ -206,13 +582,37 @@ func isVariable(v any) bool {
 	return ok
 }
 
+// isBound checks if a value is a constant term usable as an index key
+func isBound(v any) bool {
+	_, ok := v.(rdf.Term)
+	return ok
+}
+
+// hasTripleTermPattern checks if any pattern position needs component filtering
+func hasTripleTermPattern(pattern *Pattern) bool {
+	for _, v := range []any{pattern.Subject, pattern.Predicate, pattern.Object, pattern.Graph} {
+		if _, ok := v.(*TripleTermPattern); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // quadIterator implements QuadIterator
 type quadIterator struct {
+	ctx        context.Context
 	store      *TripleStore
 	txn        Transaction
 	it         Iterator
 	pattern    *Pattern
 	keyPattern []int
+	filter     bool            // pattern contains triple term patterns to check
+	current    *rdf.Quad       // quad decoded while filtering
+	graphPos   int             // key position of a graph variable, -1 if none
+	graphs     *graphReadCache // read access checks for graph variables
+	start, end []byte          // key range of a partitioned scan, nil for the whole prefix
+	started    bool
+	ended      bool // the end of the range was reached
 	closed     bool
 }
 
@@ -220,13 +620,81 @@ func (qi *quadIterator) Next() bool {
 	if qi.closed {
 		return false
 	}
-	return qi.it.Next()
+	qi.current = nil
+	for qi.advance() {
+		if qi.ctx.Err() != nil {
+			return false
+		}
+		if qi.graphPos >= 0 && !qi.acceptGraph() {
+			continue
+		}
+		if !qi.filter {
+			return true
+		}
+		quad, err := qi.decodeQuad()
+		if err != nil {
+			// Leave the error to be reported by Quad()
+			return true
+		}
+		if matchesPatternTerm(qi.pattern.Subject, quad.Subject) &&
+			matchesPatternTerm(qi.pattern.Predicate, quad.Predicate) &&
+			matchesPatternTerm(qi.pattern.Object, quad.Object) {
+			qi.current = quad
+			return true
+		}
+	}
+	return false
+}
+
+// advance moves to the next key within the iterator's range
+func (qi *quadIterator) advance() bool {
+	if qi.ended {
+		return false
+	}
+	var ok bool
+	if !qi.started && qi.start != nil {
+		ok = qi.it.Seek(qi.start)
+	} else {
+		ok = qi.it.Next()
+	}
+	qi.started = true
+	if ok && qi.end != nil && bytes.Compare(qi.it.Key(), qi.end) >= 0 {
+		qi.ended = true
+		return false
+	}
+	return ok
 }
 
 func (qi *quadIterator) Quad() (*rdf.Quad, error) {
 	if qi.closed {
 		return nil, fmt.Errorf("iterator closed")
 	}
+	if qi.current != nil {
+		return qi.current, nil
+	}
+	return qi.decodeQuad()
+}
+
+// acceptGraph checks the graph at the current position: graph variables
+// don't match the default graph, and unreadable graphs are skipped
+func (qi *quadIterator) acceptGraph() bool {
+	const encodedTermSize = 17
+	key := qi.it.Key()
+	offset := qi.graphPos * encodedTermSize
+	if len(key) < offset+encodedTermSize {
+		return false
+	}
+
+	var graph EncodedTerm
+	copy(graph[:], key[offset:offset+encodedTermSize])
+	if rdf.TermType(graph[0]) == rdf.TermTypeDefaultGraph {
+		return false
+	}
+	return qi.graphs == nil || qi.graphs.canRead(graph)
+}
+
+// decodeQuad decodes the quad at the current iterator position
+func (qi *quadIterator) decodeQuad() (*rdf.Quad, error) {
 
 	key := qi.it.Key()
 	if key == nil {
@@ -287,6 +755,26 @@ func (qi *quadIterator) Quad() (*rdf.Quad, error) {
 	}, nil
 }
 
+// EncodedQuad returns the encoded terms at the current iterator position
+func (qi *quadIterator) EncodedQuad() ([4]EncodedTerm, error) {
+	var positions [4]EncodedTerm
+	if qi.closed {
+		return positions, fmt.Errorf("iterator closed")
+	}
+	key := qi.it.Key()
+	const encodedTermSize = 17
+	if len(key) < len(qi.keyPattern)*encodedTermSize {
+		return positions, fmt.Errorf("invalid key length: %d", len(key))
+	}
+	for i, idx := range qi.keyPattern {
+		copy(positions[idx][:], key[i*encodedTermSize:(i+1)*encodedTermSize])
+	}
+	if len(qi.keyPattern) == 3 {
+		positions[3][0] = byte(rdf.TermTypeDefaultGraph)
+	}
+	return positions, nil
+}
+
 func (qi *quadIterator) Close() error {
 	if qi.closed {
 		return nil
@@ -296,10 +784,34 @@ func (qi *quadIterator) Close() error {
 	return qi.txn.Rollback()
 }
 
+// emptyQuadIterator is returned for scans that cannot match anything
+type emptyQuadIterator struct{}
+
+func (ei *emptyQuadIterator) Next() bool {
+	return false
+}
+
+func (ei *emptyQuadIterator) Quad() (*rdf.Quad, error) {
+	return nil, fmt.Errorf("no current quad")
+}
+
+func (ei *emptyQuadIterator) EncodedQuad() ([4]EncodedTerm, error) {
+	return [4]EncodedTerm{}, fmt.Errorf("no current quad")
+}
+
+func (ei *emptyQuadIterator) Close() error {
+	return nil
+}
+
 // decodeTerm decodes an encoded term back to an rdf.Term
 func (s *TripleStore) decodeTerm(txn Transaction, encoded EncodedTerm) (rdf.Term, error) {
 	termType := rdf.TermType(encoded[0])
 
+	// Triple terms are stored structurally as their encoded components
+	if termType == rdf.TermTypeQuotedTriple {
+		return s.decodeTripleTerm(txn, encoded)
+	}
+
 	// For terms that need string lookup
 	var stringValue *string
 	if termType == rdf.TermTypeNamedNode || termType == rdf.TermTypeBlankNode ||
@@ -315,3 +827,29 @@ func (s *TripleStore) decodeTerm(txn Transaction, encoded EncodedTerm) (rdf.Term
 
 	return s.decoder.DecodeTerm(encoded, stringValue)
 }
+
+// decodeTripleTerm decodes an RDF 1.2 triple term from the triples table
+func (s *TripleStore) decodeTripleTerm(txn Transaction, encoded EncodedTerm) (rdf.Term, error) {
+	components, err := txn.Get(TableTriples, encoded[1:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to load triple term components: %w", err)
+	}
+
+	const encodedTermSize = 17
+	if len(components) != 3*encodedTermSize {
+		return nil, fmt.Errorf("invalid triple term components length: %d", len(components))
+	}
+
+	terms := make([]rdf.Term, 3)
+	for i := range terms {
+		var component EncodedTerm
+		copy(component[:], components[i*encodedTermSize:(i+1)*encodedTermSize])
+		term, err := s.decodeTerm(txn, component)
+		if err != nil {
+			return nil, err
+		}
+		terms[i] = term
+	}
+
+	return &rdf.TripleTerm{Subject: terms[0], Predicate: terms[1], Object: terms[2]}, nil
+}
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrDatasetNotFound    = errors.New("dataset not found")
+	ErrDatasetExists      = errors.New("dataset already exists")
+	ErrInvalidDatasetName = errors.New("invalid dataset name")
+)
+
+// datasetNamePattern restricts dataset names to values safe in URL paths and directory names
+var datasetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// StorageProvider creates, lists and removes the storage backing named datasets
+// (e.g. one Badger directory per dataset)
+type StorageProvider interface {
+	// Open opens the storage for a dataset, creating it if needed
+	Open(name string) (Storage, error)
+
+	// Remove permanently deletes the storage of a closed dataset
+	Remove(name string) error
+
+	// List returns the names of all existing datasets
+	List() ([]string, error)
+}
+
+// Registry manages several independent triplestores, one per named dataset
+type Registry struct {
+	mu       sync.RWMutex
+	provider StorageProvider
+	encoder  TermEncoder
+	decoder  TermDecoder
+	datasets map[string]*dataset
+	deleting map[string]bool // datasets waiting for their users before being removed
+}
+
+// dataset is an open triplestore and the users that acquired it
+type dataset struct {
+	store *TripleStore
+	users sync.WaitGroup
+}
+
+// NewRegistry creates a dataset registry and opens all existing datasets
+func NewRegistry(provider StorageProvider, encoder TermEncoder, decoder TermDecoder) (*Registry, error) {
+	r := &Registry{
+		provider: provider,
+		encoder:  encoder,
+		decoder:  decoder,
+		datasets: make(map[string]*dataset),
+		deleting: make(map[string]bool),
+	}
+
+	names, err := provider.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list datasets: %w", err)
+	}
+
+	for _, name := range names {
+		if !ValidDatasetName(name) {
+			continue
+		}
+		storage, err := provider.Open(name)
+		if err != nil {
+			_ = r.Close() // #nosec G104 - close error less important than open error
+			return nil, fmt.Errorf("failed to open dataset %s: %w", name, err)
+		}
+		r.datasets[name] = &dataset{store: NewTripleStore(storage, encoder, decoder)}
+	}
+
+	return r, nil
+}
+
+// ValidDatasetName checks if a name can be used for a dataset
+func ValidDatasetName(name string) bool {
+	return datasetNamePattern.MatchString(name)
+}
+
+// Get returns the triplestore of a dataset
+// The store is closed if the dataset is deleted while in use: use Acquire to keep it open.
+func (r *Registry) Get(name string) (*TripleStore, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ds, ok := r.datasets[name]
+	if !ok {
+		return nil, false
+	}
+	return ds.store, true
+}
+
+// Acquire returns the triplestore of a dataset and keeps it open until release is called.
+// Deleting the dataset waits for every acquired store to be released.
+func (r *Registry) Acquire(name string) (ts *TripleStore, release func(), err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ds, ok := r.datasets[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrDatasetNotFound, name)
+	}
+	ds.users.Add(1)
+	var once sync.Once
+	return ds.store, func() { once.Do(ds.users.Done) }, nil
+}
+
+// Names returns the names of all datasets in sorted order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.datasets))
+	for name := range r.datasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Create creates a new empty dataset
+func (r *Registry) Create(name string) (*TripleStore, error) {
+	if !ValidDatasetName(name) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDatasetName, name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.datasets[name]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrDatasetExists, name)
+	}
+	if r.deleting[name] {
+		return nil, fmt.Errorf("%w: %s is being deleted", ErrDatasetExists, name)
+	}
+
+	storage, err := r.provider.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset %s: %w", name, err)
+	}
+
+	ts := NewTripleStore(storage, r.encoder, r.decoder)
+	r.datasets[name] = &dataset{store: ts}
+	return ts, nil
+}
+
+// Delete closes a dataset and permanently removes its storage
+// The dataset can no longer be acquired once Delete is called; Delete waits for
+// the stores already acquired to be released before closing it.
+func (r *Registry) Delete(name string) error {
+	r.mu.Lock()
+	ds, exists := r.datasets[name]
+	if !exists {
+		r.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrDatasetNotFound, name)
+	}
+	delete(r.datasets, name)
+	r.deleting[name] = true
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.deleting, name)
+		r.mu.Unlock()
+	}()
+
+	ds.users.Wait()
+	if err := ds.store.Close(); err != nil {
+		return fmt.Errorf("failed to close dataset %s: %w", name, err)
+	}
+	return r.provider.Remove(name)
+}
+
+// Close closes all datasets
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	for name, ds := range r.datasets {
+		if err := ds.store.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close dataset %s: %w", name, err)
+		}
+		delete(r.datasets, name)
+	}
+	return firstErr
+}
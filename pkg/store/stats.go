@@ -0,0 +1,336 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+)
+
+// statisticsKey is the key of the statistics record in TableStats
+var statisticsKey = []byte("statistics")
+
+// maxCharacteristicSets caps the number of characteristic sets kept, the most frequent ones win
+const maxCharacteristicSets = 1000
+
+// Statistics describes the data in a store, as collected by Analyze
+// Terms are keyed by their N-Triples form, the default graph by ""
+type Statistics struct {
+	Quads      int64                           `json:"quads"`
+	Subjects   int64                           `json:"subjects"`
+	Objects    int64                           `json:"objects"`
+	Predicates map[string]*PredicateStatistics `json:"predicates"`
+	Graphs     map[string]int64                `json:"graphs"`
+
+	// CharacteristicSets are the distinct sets of predicates used by subjects
+	CharacteristicSets []*CharacteristicSet `json:"characteristicSets"`
+
+	AnalyzedAt time.Time `json:"analyzedAt"`
+}
+
+// PredicateStatistics counts the quads of one predicate
+type PredicateStatistics struct {
+	Quads    int64 `json:"quads"`
+	Subjects int64 `json:"subjects"` // distinct subjects
+	Objects  int64 `json:"objects"`  // distinct objects
+}
+
+// CharacteristicSet is a set of predicates shared by subjects that use exactly these predicates
+type CharacteristicSet struct {
+	Predicates []string `json:"predicates"` // sorted
+	Subjects   int64    `json:"subjects"`
+
+	// Occurrences[i] is the number of quads with Predicates[i] over all subjects of the set
+	Occurrences []int64 `json:"occurrences"`
+}
+
+// Analyze scans the indexes to collect statistics, persists them and returns them.
+// It sees all graphs regardless of the authorizer.
+func (s *TripleStore) Analyze(ctx context.Context) (*Statistics, error) {
+	txn, err := s.storage.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+
+	stats := &Statistics{
+		Predicates: make(map[string]*PredicateStatistics),
+		Graphs:     make(map[string]int64),
+	}
+	predicates := make(map[EncodedTerm]*PredicateStatistics)
+	graphs := make(map[EncodedTerm]int64)
+
+	if err := s.analyzeSubjects(ctx, txn, stats, predicates, graphs); err != nil {
+		return nil, err
+	}
+	if err := s.analyzeObjects(ctx, txn, stats, predicates); err != nil {
+		return nil, err
+	}
+
+	for encoded, ps := range predicates {
+		name, err := s.termKey(txn, encoded)
+		if err != nil {
+			return nil, err
+		}
+		stats.Predicates[name] = ps
+	}
+	for encoded, count := range graphs {
+		name, err := s.termKey(txn, encoded)
+		if err != nil {
+			return nil, err
+		}
+		stats.Graphs[name] = count
+	}
+	stats.AnalyzedAt = time.Now().UTC()
+
+	if err := s.saveStatistics(stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// charSetCounter accumulates one characteristic set while scanning
+type charSetCounter struct {
+	predicates  []EncodedTerm
+	subjects    int64
+	occurrences []int64
+}
+
+// analyzeSubjects scans SPOG, where the quads of a subject are contiguous and grouped by predicate
+func (s *TripleStore) analyzeSubjects(ctx context.Context, txn Transaction, stats *Statistics, predicates map[EncodedTerm]*PredicateStatistics, graphs map[EncodedTerm]int64) error {
+	it, err := txn.Scan(TableSPOG, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	const encodedTermSize = 17
+	sets := make(map[string]*charSetCounter)
+
+	var subject, predicate, graph EncodedTerm
+	var subjectPredicates []EncodedTerm // predicates of the current subject, in order
+	var subjectCounts []int64           // quads per predicate of the current subject
+	started := false
+
+	// flushSubject records the characteristic set of the current subject
+	flushSubject := func() {
+		if len(subjectPredicates) == 0 {
+			return
+		}
+		var key bytes.Buffer
+		for _, p := range subjectPredicates {
+			key.Write(p[:])
+		}
+		set, ok := sets[key.String()]
+		if !ok {
+			set = &charSetCounter{
+				predicates:  append([]EncodedTerm(nil), subjectPredicates...),
+				occurrences: make([]int64, len(subjectPredicates)),
+			}
+			sets[key.String()] = set
+		}
+		set.subjects++
+		for i, count := range subjectCounts {
+			set.occurrences[i] += count
+		}
+		subjectPredicates = subjectPredicates[:0]
+		subjectCounts = subjectCounts[:0]
+	}
+
+	for it.Next() {
+		if stats.Quads%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		key := it.Key()
+		if len(key) < 4*encodedTermSize {
+			continue
+		}
+		var subj, pred EncodedTerm
+		copy(subj[:], key[:encodedTermSize])
+		copy(pred[:], key[encodedTermSize:2*encodedTermSize])
+		copy(graph[:], key[3*encodedTermSize:4*encodedTermSize])
+
+		stats.Quads++
+		graphs[graph]++
+
+		ps, ok := predicates[pred]
+		if !ok {
+			ps = &PredicateStatistics{}
+			predicates[pred] = ps
+		}
+		ps.Quads++
+
+		newSubject := !started || subj != subject
+		if newSubject {
+			flushSubject()
+			stats.Subjects++
+		}
+		if newSubject || pred != predicate {
+			ps.Subjects++
+			subjectPredicates = append(subjectPredicates, pred)
+			subjectCounts = append(subjectCounts, 0)
+		}
+		subjectCounts[len(subjectCounts)-1]++
+		subject, predicate, started = subj, pred, true
+	}
+	flushSubject()
+
+	return s.collectCharacteristicSets(txn, stats, sets)
+}
+
+// collectCharacteristicSets decodes the most frequent characteristic sets into stats
+func (s *TripleStore) collectCharacteristicSets(txn Transaction, stats *Statistics, sets map[string]*charSetCounter) error {
+	counters := make([]*charSetCounter, 0, len(sets))
+	for _, set := range sets {
+		counters = append(counters, set)
+	}
+	sort.Slice(counters, func(i, j int) bool {
+		return counters[i].subjects > counters[j].subjects
+	})
+	if len(counters) > maxCharacteristicSets {
+		counters = counters[:maxCharacteristicSets]
+	}
+
+	for _, counter := range counters {
+		set := &CharacteristicSet{Subjects: counter.subjects}
+		names := make(map[string]int64, len(counter.predicates))
+		for i, p := range counter.predicates {
+			name, err := s.termKey(txn, p)
+			if err != nil {
+				return err
+			}
+			set.Predicates = append(set.Predicates, name)
+			names[name] = counter.occurrences[i]
+		}
+		sort.Strings(set.Predicates)
+		for _, name := range set.Predicates {
+			set.Occurrences = append(set.Occurrences, names[name])
+		}
+		stats.CharacteristicSets = append(stats.CharacteristicSets, set)
+	}
+	return nil
+}
+
+// analyzeObjects counts distinct objects per predicate from POSG and overall from OSPG
+func (s *TripleStore) analyzeObjects(ctx context.Context, txn Transaction, stats *Statistics, predicates map[EncodedTerm]*PredicateStatistics) error {
+	const encodedTermSize = 17
+
+	it, err := txn.Scan(TablePOSG, nil, nil)
+	if err != nil {
+		return err
+	}
+	var lastPO [2 * encodedTermSize]byte
+	started := false
+	n := 0
+	for it.Next() {
+		if n++; n%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				_ = it.Close() // #nosec G104 - cancellation error takes precedence
+				return err
+			}
+		}
+		key := it.Key()
+		if len(key) < 2*encodedTermSize {
+			continue
+		}
+		var po [2 * encodedTermSize]byte
+		copy(po[:], key[:2*encodedTermSize])
+		if !started || po != lastPO {
+			var p EncodedTerm
+			copy(p[:], po[:encodedTermSize])
+			if ps, ok := predicates[p]; ok {
+				ps.Objects++
+			}
+			lastPO, started = po, true
+		}
+	}
+	_ = it.Close() // #nosec G104 - read-only scan
+
+	it, err = txn.Scan(TableOSPG, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	var lastObject EncodedTerm
+	started = false
+	for it.Next() {
+		if n++; n%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		key := it.Key()
+		if len(key) < encodedTermSize {
+			continue
+		}
+		var o EncodedTerm
+		copy(o[:], key[:encodedTermSize])
+		if !started || o != lastObject {
+			stats.Objects++
+			lastObject, started = o, true
+		}
+	}
+	return nil
+}
+
+// termKey returns the key a term has in Statistics
+func (s *TripleStore) termKey(txn Transaction, encoded EncodedTerm) (string, error) {
+	if rdf.TermType(encoded[0]) == rdf.TermTypeDefaultGraph {
+		return "", nil
+	}
+	term, err := s.decodeTerm(txn, encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode term: %w", err)
+	}
+	return term.String(), nil
+}
+
+// saveStatistics persists statistics in TableStats
+func (s *TripleStore) saveStatistics(stats *Statistics) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to encode statistics: %w", err)
+	}
+
+	txn, err := s.storage.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	if err := txn.Set(TableStats, statisticsKey, data); err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// LoadStatistics returns the statistics persisted by the last Analyze, or nil if the store was never analyzed
+func (s *TripleStore) LoadStatistics() (*Statistics, error) {
+	txn, err := s.storage.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Rollback()
+
+	data, err := txn.Get(TableStats, statisticsKey)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stats Statistics
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("failed to decode statistics: %w", err)
+	}
+	return &stats, nil
+}
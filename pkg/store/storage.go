@@ -7,6 +7,11 @@ import (
 var (
 	ErrNotFound      = errors.New("key not found")
 	ErrTransactionRO = errors.New("transaction is read-only")
+
+	// ErrTxnTooBig is returned by Transaction.Set and Delete when the write would take the
+	// transaction past the storage's size limit; the transaction stays usable and what it
+	// already holds can still be committed
+	ErrTxnTooBig = errors.New("transaction too big")
 )
 
 // Storage is the interface for the underlying key-value store
@@ -55,6 +60,10 @@ type Iterator interface {
 	// Value returns the current value
 	Value() ([]byte, error)
 
+	// Seek moves to the first key at or after the given key within the scanned range,
+	// like Next it reports whether there is one
+	Seek(key []byte) bool
+
 	// Close closes the iterator
 	Close() error
 }
@@ -82,6 +91,12 @@ const (
 	// Named graphs metadata
 	TableGraphs
 
+	// RDF 1.2 triple terms: triple term ID -> encoded subject, predicate, object
+	TableTriples
+
+	// Optimizer statistics collected by Analyze
+	TableStats
+
 	// Total number of tables
 	TableCount
 )
@@ -110,6 +125,10 @@ func (t Table) String() string {
 		return "gosp"
 	case TableGraphs:
 		return "graphs"
+	case TableTriples:
+		return "triples"
+	case TableStats:
+		return "stats"
 	default:
 		return "unknown"
 	}
@@ -2,16 +2,27 @@ package store
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/aleksaelezovic/trigo/pkg/rdf"
 )
 
+// LoadBatchSize is the number of parsed quads LoadQuads buffers before inserting them;
+// each batch is split over as many transactions as the storage's size limit requires
+const LoadBatchSize = 10000
+
+// ErrInvalidData is returned by LoadQuads, wrapping the parser's error, when its input
+// can't be parsed
+var ErrInvalidData = errors.New("invalid RDF data")
+
 // TripleStore manages the RDF triplestore with 11 indexes
 type TripleStore struct {
-	storage Storage
-	encoder TermEncoder
-	decoder TermDecoder
+	storage    Storage
+	encoder    TermEncoder
+	decoder    TermDecoder
+	authorizer GraphAuthorizer // nil means unrestricted
 }
 
 // NewTripleStore creates a new triplestore
@@ -23,6 +34,12 @@ func NewTripleStore(storage Storage, encoder TermEncoder, decoder TermDecoder) *
 	}
 }
 
+// EncodeTerm returns the encoded form of a term, as used in index keys
+func (s *TripleStore) EncodeTerm(term rdf.Term) (EncodedTerm, error) {
+	encoded, _, err := s.encoder.EncodeTerm(term)
+	return encoded, err
+}
+
 // Close closes the triplestore
 func (s *TripleStore) Close() error {
 	return s.storage.Close()
@@ -56,41 +73,46 @@ func (s *TripleStore) InsertTriple(triple *rdf.Triple) error {
 
 // insertQuadInTxn inserts a quad within an existing transaction
 func (s *TripleStore) insertQuadInTxn(txn Transaction, quad *rdf.Quad) error {
-	// Encode terms
-	subjEnc, subjStr, err := s.encoder.EncodeTerm(quad.Subject)
+	if err := s.checkWrite(quad.Graph); err != nil {
+		return err
+	}
+
+	// RDF 1.2: a reified triple in subject or object position stands for its reifier,
+	// and implies an rdf:reifies statement pointing at the triple term
+	for _, term := range []rdf.Term{quad.Subject, quad.Object} {
+		if rt, ok := term.(*rdf.ReifiedTriple); ok {
+			reifies := rdf.NewQuad(rt.Identifier, rdf.RDFReifies, &rdf.TripleTerm{
+				Subject:   rt.Triple.Subject,
+				Predicate: rt.Triple.Predicate,
+				Object:    rt.Triple.Object,
+			}, quad.Graph)
+			if err := s.insertQuadInTxn(txn, reifies); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Encode terms and store their strings (and triple term components) for decoding
+	subjEnc, err := s.storeTerm(txn, quad.Subject)
 	if err != nil {
 		return fmt.Errorf("failed to encode subject: %w", err)
 	}
 
-	predEnc, predStr, err := s.encoder.EncodeTerm(quad.Predicate)
+	predEnc, err := s.storeTerm(txn, quad.Predicate)
 	if err != nil {
 		return fmt.Errorf("failed to encode predicate: %w", err)
 	}
 
-	objEnc, objStr, err := s.encoder.EncodeTerm(quad.Object)
+	objEnc, err := s.storeTerm(txn, quad.Object)
 	if err != nil {
 		return fmt.Errorf("failed to encode object: %w", err)
 	}
 
-	graphEnc, graphStr, err := s.encoder.EncodeTerm(quad.Graph)
+	graphEnc, err := s.storeTerm(txn, quad.Graph)
 	if err != nil {
 		return fmt.Errorf("failed to encode graph: %w", err)
 	}
 
-	// Store strings in id2str table
-	if err := s.storeString(txn, subjEnc, subjStr); err != nil {
-		return err
-	}
-	if err := s.storeString(txn, predEnc, predStr); err != nil {
-		return err
-	}
-	if err := s.storeString(txn, objEnc, objStr); err != nil {
-		return err
-	}
-	if err := s.storeString(txn, graphEnc, graphStr); err != nil {
-		return err
-	}
-
 	// Empty value for all index entries
 	emptyValue := []byte{}
 
@@ -141,6 +163,63 @@ func (s *TripleStore) insertQuadInTxn(txn Transaction, quad *rdf.Quad) error {
 	return nil
 }
 
+// storeTerm encodes a term and stores everything needed to decode it later:
+// its string in the id2str table and, for RDF 1.2 triple terms, the encoded
+// components in the triples table (recursively for nested triple terms)
+func (s *TripleStore) storeTerm(txn Transaction, term rdf.Term) (EncodedTerm, error) {
+	if subject, predicate, object, ok := tripleTermComponents(term); ok {
+		subjEnc, err := s.storeTerm(txn, subject)
+		if err != nil {
+			return EncodedTerm{}, err
+		}
+		predEnc, err := s.storeTerm(txn, predicate)
+		if err != nil {
+			return EncodedTerm{}, err
+		}
+		objEnc, err := s.storeTerm(txn, object)
+		if err != nil {
+			return EncodedTerm{}, err
+		}
+
+		encoded, _, err := s.encoder.EncodeTerm(term)
+		if err != nil {
+			return EncodedTerm{}, err
+		}
+
+		// Triple terms are immutable, so an existing entry never needs rewriting
+		if _, err := txn.Get(TableTriples, encoded[1:]); err == nil {
+			return encoded, nil
+		} else if err != ErrNotFound {
+			return EncodedTerm{}, err
+		}
+		if err := txn.Set(TableTriples, encoded[1:], s.encoder.EncodeQuadKey(subjEnc, predEnc, objEnc)); err != nil {
+			return EncodedTerm{}, err
+		}
+		return encoded, nil
+	}
+
+	encoded, str, err := s.encoder.EncodeTerm(term)
+	if err != nil {
+		return EncodedTerm{}, err
+	}
+	if err := s.storeString(txn, encoded, str); err != nil {
+		return EncodedTerm{}, err
+	}
+	return encoded, nil
+}
+
+// tripleTermComponents returns the components of an RDF 1.2 triple term
+func tripleTermComponents(term rdf.Term) (rdf.Term, rdf.Term, rdf.Term, bool) {
+	switch t := term.(type) {
+	case *rdf.TripleTerm:
+		return t.Subject, t.Predicate, t.Object, true
+	case *rdf.QuotedTriple:
+		return t.Subject, t.Predicate, t.Object, true
+	default:
+		return nil, nil, nil, false
+	}
+}
+
 // storeString stores a string in the id2str table if provided
 func (s *TripleStore) storeString(txn Transaction, encoded EncodedTerm, str *string) error {
 	if str == nil {
@@ -191,6 +270,10 @@ func (s *TripleStore) DeleteTriple(triple *rdf.Triple) error {
 
 // deleteQuadInTxn deletes a quad within an existing transaction
 func (s *TripleStore) deleteQuadInTxn(txn Transaction, quad *rdf.Quad) error {
+	if err := s.checkWrite(quad.Graph); err != nil {
+		return err
+	}
+
 	// Encode terms
 	subjEnc, _, err := s.encoder.EncodeTerm(quad.Subject)
 	if err != nil {
@@ -248,7 +331,7 @@ func (s *TripleStore) deleteQuadInTxn(txn Transaction, quad *rdf.Quad) error {
 		return err
 	}
 
-	// Note: We don't remove from graphs table or id2str table
+	// Note: We don't remove from graphs, id2str or triples tables
 	// as they may be referenced by other quads (no garbage collection)
 
 	return nil
@@ -256,6 +339,10 @@ func (s *TripleStore) deleteQuadInTxn(txn Transaction, quad *rdf.Quad) error {
 
 // ContainsQuad checks if a quad exists in the store
 func (s *TripleStore) ContainsQuad(quad *rdf.Quad) (bool, error) {
+	if !s.canRead(quad.Graph) {
+		return false, nil
+	}
+
 	txn, err := s.storage.Begin(false)
 	if err != nil {
 		return false, err
@@ -311,8 +398,22 @@ func (s *TripleStore) Count() (int64, error) {
 	}
 	defer it.Close()
 
+	// Only quads in readable graphs are counted
+	var graphs *graphReadCache
+	if s.authorizer != nil {
+		graphs = newGraphReadCache(s, txn)
+	}
+
+	const encodedTermSize = 17
 	count := int64(0)
 	for it.Next() {
+		if graphs != nil {
+			var graph EncodedTerm
+			copy(graph[:], it.Key()[3*encodedTermSize:])
+			if !graphs.canRead(graph) {
+				continue
+			}
+		}
 		count++
 	}
 
@@ -360,6 +461,89 @@ func (s *TripleStore) InsertTriplesBatch(triples []*rdf.Triple) error {
 	return s.InsertQuadsBatch(quads)
 }
 
+// LoadQuads streams the quads of a document into the store, inserting them in batches of
+// LoadBatchSize quads so the document never has to fit in memory. A transaction is
+// committed and a new one started whenever it reaches the storage's size limit, and
+// quads committed before an error stay in the store. It returns the number of quads
+// inserted.
+func (s *TripleStore) LoadQuads(reader io.Reader, parser rdf.StreamParser) (int, error) {
+	inserted := 0
+	batch := make([]*rdf.Quad, 0, LoadBatchSize)
+	var insertErr error
+	flush := func() error {
+		n, err := s.insertQuadsSplit(batch)
+		inserted += n
+		batch = batch[:0]
+		if err != nil {
+			insertErr = err
+			return err
+		}
+		return nil
+	}
+
+	err := parser.ParseStream(reader, func(quad *rdf.Quad) error {
+		batch = append(batch, quad)
+		if len(batch) < LoadBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if insertErr != nil {
+		return inserted, insertErr
+	}
+	if err != nil {
+		return inserted, fmt.Errorf("%w: %w", ErrInvalidData, err)
+	}
+	if err := flush(); err != nil {
+		return inserted, err
+	}
+	return inserted, nil
+}
+
+// insertQuadsSplit inserts quads over as few transactions as possible: when a quad doesn't
+// fit, the transaction is committed and the quad retried in a fresh one. Index and term
+// writes are idempotent, so the part of the quad already written before the limit was
+// hit is harmless. It returns the number of quads committed.
+func (s *TripleStore) insertQuadsSplit(quads []*rdf.Quad) (int, error) {
+	if len(quads) == 0 {
+		return 0, nil
+	}
+
+	txn, err := s.storage.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { txn.Rollback() }()
+
+	committed, pending := 0, 0
+	for i := 0; i < len(quads); {
+		err := s.insertQuadInTxn(txn, quads[i])
+		if errors.Is(err, ErrTxnTooBig) && pending > 0 {
+			if err := txn.Commit(); err != nil {
+				return committed, err
+			}
+			committed += pending
+			pending = 0
+			next, err := s.storage.Begin(true)
+			if err != nil {
+				return committed, err
+			}
+			txn = next
+			continue
+		}
+		if err != nil {
+			return committed, fmt.Errorf("failed to insert quad: %w", err)
+		}
+		pending++
+		i++
+	}
+
+	if err := txn.Commit(); err != nil {
+		return committed, err
+	}
+	return committed + pending, nil
+}
+
 // DeleteQuadsBatch deletes multiple quads in a single transaction for better performance
 func (s *TripleStore) DeleteQuadsBatch(quads []*rdf.Quad) error {
 	if len(quads) == 0 {
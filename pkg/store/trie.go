@@ -0,0 +1,161 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/aleksaelezovic/trigo/pkg/rdf"
+)
+
+// TrieOrders are the key orders of the permutation indexes, as quad positions (S=0, P=1, O=2).
+// Each can be opened as a Trie, in the default graph or within a named graph.
+var TrieOrders = [][3]int{
+	{0, 1, 2}, // SPO
+	{1, 2, 0}, // POS
+	{2, 0, 1}, // OSP
+}
+
+// trieTables maps the key orders to their indexes, without and with the graph first
+var trieTables = map[[3]int][2]Table{
+	{0, 1, 2}: {TableSPO, TableGSPO},
+	{1, 2, 0}: {TablePOS, TableGPOS},
+	{2, 0, 1}: {TableOSP, TableGOSP},
+}
+
+// Trie walks a permutation index as a trie with one level per key position,
+// the access path of the Leapfrog Triejoin. Each level holds the distinct encoded
+// terms that follow the keys of the levels above it, in sorted order, and is
+// navigated by seeking through the index rather than scanning it.
+type Trie struct {
+	ctx    context.Context
+	txn    Transaction
+	it     Iterator    // nil when the graph cannot be read
+	prefix []byte      // graph prefix and the keys of the levels above the current one
+	key    EncodedTerm // key at the current level
+	depth  int         // current level, -1 before the first Open
+	atEnd  bool
+}
+
+// OpenTrie opens the index with the given key order (one of TrieOrders) as a trie,
+// over the default graph when graph is nil or over one named graph.
+// The trie stops early once ctx is done; callers check ctx.Err() to tell it from exhaustion.
+func (s *TripleStore) OpenTrie(ctx context.Context, order [3]int, graph rdf.Term) (*Trie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	tables, ok := trieTables[order]
+	if !ok {
+		return nil, fmt.Errorf("no index with key order %v", order)
+	}
+
+	t := &Trie{ctx: ctx, depth: -1}
+	table := tables[0]
+	if graph != nil {
+		if !s.canRead(graph) {
+			return t, nil
+		}
+		encoded, err := s.EncodeTerm(graph)
+		if err != nil {
+			return nil, err
+		}
+		table = tables[1]
+		t.prefix = append(t.prefix, encoded[:]...)
+	} else if !s.canRead(rdf.NewDefaultGraph()) {
+		return t, nil
+	}
+
+	txn, err := s.storage.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	var start []byte
+	if len(t.prefix) > 0 {
+		start = t.prefix
+	}
+	it, err := txn.Scan(table, start, nil)
+	if err != nil {
+		_ = txn.Rollback() // #nosec G104 - rollback error less important than original error
+		return nil, err
+	}
+	t.txn = txn
+	t.it = it
+	return t, nil
+}
+
+// Open descends to the level below the current key, positioned at its first key
+func (t *Trie) Open() {
+	if t.depth >= 0 {
+		t.prefix = append(t.prefix, t.key[:]...)
+	}
+	t.depth++
+	t.seek(t.prefix)
+}
+
+// Up returns to the level above, positioned at the key it was opened from
+func (t *Trie) Up() {
+	t.depth--
+	if t.depth >= 0 {
+		n := len(t.prefix) - len(t.key)
+		copy(t.key[:], t.prefix[n:])
+		t.prefix = t.prefix[:n]
+	}
+	t.atEnd = false
+}
+
+// Key returns the key at the current position of the current level
+func (t *Trie) Key() EncodedTerm {
+	return t.key
+}
+
+// AtEnd reports whether the current level has no more keys
+func (t *Trie) AtEnd() bool {
+	return t.atEnd
+}
+
+// Next moves to the next key of the current level
+func (t *Trie) Next() {
+	next := t.key
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			t.seek(append(t.prefix[:len(t.prefix):len(t.prefix)], next[:]...))
+			return
+		}
+	}
+	// The key was the largest possible one
+	t.atEnd = true
+}
+
+// Seek moves to the first key of the current level at or after key
+func (t *Trie) Seek(key EncodedTerm) {
+	if t.atEnd || bytes.Compare(key[:], t.key[:]) <= 0 {
+		return
+	}
+	t.seek(append(t.prefix[:len(t.prefix):len(t.prefix)], key[:]...))
+}
+
+// seek positions the trie at the first index key at or after target,
+// which ends the level if it doesn't share the level's prefix
+func (t *Trie) seek(target []byte) {
+	t.atEnd = true
+	if t.it == nil || t.ctx.Err() != nil || !t.it.Seek(target) {
+		return
+	}
+	key := t.it.Key()
+	if len(key) < len(t.prefix)+len(t.key) || !bytes.HasPrefix(key, t.prefix) {
+		return
+	}
+	copy(t.key[:], key[len(t.prefix):])
+	t.atEnd = false
+}
+
+// Close releases the index iterator and its read transaction
+func (t *Trie) Close() error {
+	if t.it == nil {
+		return nil
+	}
+	_ = t.it.Close() // #nosec G104 - iterator close error less important than rollback
+	t.it = nil
+	return t.txn.Rollback()
+}